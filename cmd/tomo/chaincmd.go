@@ -66,6 +66,7 @@ It expects the genesis file as argument.`,
 			utils.CacheFlag,
 			utils.LightModeFlag,
 			utils.GCModeFlag,
+			utils.StateHistoryFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
 		},
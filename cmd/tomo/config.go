@@ -168,6 +168,13 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, tomoConfig) {
 		common.TIPTomoXBlock = big.NewInt(0)
 		common.TIPTomoXLendingBlock = big.NewInt(0)
 		common.TIPTomoXCancellationFeeBlock = big.NewInt(0)
+		common.TIPTomoXLendingPartialRepayBlock = big.NewInt(0)
+		common.TIPTomoXLendingOracleBlock = big.NewInt(0)
+		common.TIPTomoXLendingAuctionBlock = big.NewInt(0)
+		common.TIPTomoXLendingPoolBlock = big.NewInt(0)
+		common.TIPTomoXLendingMarginBlock = big.NewInt(0)
+		common.TIPTomoXLendingRolloverBlock = big.NewInt(0)
+		common.TIPTomoXSelfTradePreventionBlock = big.NewInt(0)
 
 		// Special SMC addresses
 		common.LendingRegistrationSMC = common.LendingRegistrationSMCTestnet
@@ -272,6 +279,11 @@ func makeFullNode(ctx *cli.Context) (*node.Node, tomoConfig) {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
 	}
 
+	// Add the GraphQL endpoint to the HTTP-RPC server if requested.
+	if ctx.GlobalBool(utils.GraphQLEnabledFlag.Name) {
+		utils.RegisterGraphQLService(stack)
+	}
+
 	return stack, cfg
 }
 
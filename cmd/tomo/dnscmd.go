@@ -0,0 +1,280 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/p2p/dnsdisc"
+	"github.com/tomochain/tomochain/p2p/enr"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dnsTimeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Timeout for DNS lookups",
+		Value: 5 * time.Second,
+	}
+	dnsDomainFlag = cli.StringFlag{
+		Name:  "domain",
+		Usage: "Domain name of the tree",
+	}
+	dnsSeqFlag = cli.UintFlag{
+		Name:  "seq",
+		Usage: "New sequence number of the tree",
+	}
+
+	devp2pCommand = cli.Command{
+		Name:     "devp2p",
+		Usage:    "P2P networking utilities",
+		Category: "MISCELLANEOUS COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:  "dns",
+				Usage: "DNS discovery commands",
+				Description: `
+Node lists for DNS discovery (EIP-1459) are kept in a tree directory holding
+two files: nodes.json, the list of node records ("enr:" text form, as shown
+by admin.nodeInfo), and enrtree-info.json, the sequence number, signature,
+URL and links of the tree.
+
+A list is published by signing the tree directory and deploying the TXT
+records printed by to-txt under the domain of the tree.`,
+				Subcommands: []cli.Command{
+					{
+						Name:      "sync",
+						Usage:     "Download a DNS discovery tree",
+						ArgsUsage: "<url> [ <directory> ]",
+						Action:    dnsSync,
+						Flags:     []cli.Flag{dnsTimeoutFlag},
+						Description: `
+Downloads the tree at the given enrtree:// URL, printing a summary of it and
+storing it in the given tree directory if any.`,
+					},
+					{
+						Name:      "sign",
+						Usage:     "Sign a DNS discovery tree",
+						ArgsUsage: "<tree-directory> <key-file>",
+						Action:    dnsSign,
+						Flags:     []cli.Flag{dnsDomainFlag, dnsSeqFlag},
+						Description: `
+Signs the tree in the given directory with the hex encoded private key in the
+key file. The sequence number is increased unless set with --seq, the domain
+is kept unless set with --domain.`,
+					},
+					{
+						Name:      "to-txt",
+						Usage:     "Create DNS TXT records for a discovery tree",
+						ArgsUsage: "<tree-directory> [ <output-file> ]",
+						Action:    dnsToTXT,
+						Description: `
+Prints the TXT records of the signed tree in the given directory as a JSON
+object mapping names to record contents, ready to be deployed to DNS.`,
+					},
+				},
+			},
+		},
+	}
+)
+
+const (
+	treeNodesFile      = "nodes.json"
+	treeMetaFile       = "enrtree-info.json"
+	treeDefinitionPerm = 0644
+)
+
+// dnsDefinition is the contents of a tree directory.
+type dnsDefinition struct {
+	Meta  dnsMetaJSON
+	Nodes []*enr.Record
+}
+
+// dnsMetaJSON is the contents of the enrtree-info.json file.
+type dnsMetaJSON struct {
+	URL   string   `json:"url,omitempty"`
+	Seq   uint     `json:"seq"`
+	Sig   string   `json:"signature,omitempty"`
+	Links []string `json:"links"`
+}
+
+// dnsSync downloads a tree and optionally stores it in a tree directory.
+func dnsSync(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need tree URL as argument")
+	}
+	url, outdir := ctx.Args().Get(0), ctx.Args().Get(1)
+
+	client := dnsdisc.NewClient(dnsdisc.Config{Timeout: ctx.Duration(dnsTimeoutFlag.Name)})
+	t, err := client.SyncTree(url)
+	if err != nil {
+		return err
+	}
+	def := &dnsDefinition{
+		Meta:  dnsMetaJSON{URL: url, Seq: t.Seq(), Sig: t.Signature(), Links: t.Links()},
+		Nodes: t.Nodes(),
+	}
+	fmt.Printf("Tree %s: seq %d, %d nodes, %d links\n", url, def.Meta.Seq, len(def.Nodes), len(def.Meta.Links))
+	if outdir == "" {
+		return nil
+	}
+	return writeTreeDefinition(outdir, def)
+}
+
+// dnsSign signs a tree directory.
+func dnsSign(ctx *cli.Context) error {
+	if ctx.NArg() < 2 {
+		return fmt.Errorf("need tree definition directory and key file as arguments")
+	}
+	defdir, keyfile := ctx.Args().Get(0), ctx.Args().Get(1)
+	def, err := loadTreeDefinition(defdir)
+	if err != nil {
+		return err
+	}
+	key, err := crypto.LoadECDSA(keyfile)
+	if err != nil {
+		return fmt.Errorf("can't load signing key: %v", err)
+	}
+	domain := ctx.String(dnsDomainFlag.Name)
+	if domain == "" && def.Meta.URL != "" {
+		if domain, _, err = dnsdisc.ParseURL(def.Meta.URL); err != nil {
+			return fmt.Errorf("invalid tree URL in %s: %v", treeMetaFile, err)
+		}
+	}
+	if domain == "" {
+		return fmt.Errorf("tree domain unknown, set it with --%s", dnsDomainFlag.Name)
+	}
+	seq := def.Meta.Seq + 1
+	if ctx.IsSet(dnsSeqFlag.Name) {
+		seq = ctx.Uint(dnsSeqFlag.Name)
+	}
+	t, err := dnsdisc.MakeTree(seq, def.Nodes, def.Meta.Links)
+	if err != nil {
+		return err
+	}
+	url, err := t.Sign(key, domain)
+	if err != nil {
+		return fmt.Errorf("can't sign: %v", err)
+	}
+	def.Meta.URL, def.Meta.Seq, def.Meta.Sig = url, t.Seq(), t.Signature()
+	fmt.Println(url)
+	return writeTreeMeta(defdir, &def.Meta)
+}
+
+// dnsToTXT prints the TXT records of a signed tree directory.
+func dnsToTXT(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need tree definition directory as argument")
+	}
+	defdir, output := ctx.Args().Get(0), ctx.Args().Get(1)
+	def, err := loadTreeDefinition(defdir)
+	if err != nil {
+		return err
+	}
+	domain, t, err := signedTree(def)
+	if err != nil {
+		return err
+	}
+	records, err := json.MarshalIndent(t.ToTXT(domain), "", "  ")
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		fmt.Println(string(records))
+		return nil
+	}
+	return ioutil.WriteFile(output, append(records, '\n'), treeDefinitionPerm)
+}
+
+// signedTree recreates the tree of a definition, verifying its signature.
+func signedTree(def *dnsDefinition) (string, *dnsdisc.Tree, error) {
+	if def.Meta.URL == "" || def.Meta.Sig == "" {
+		return "", nil, fmt.Errorf("tree is not signed")
+	}
+	domain, pubkey, err := dnsdisc.ParseURL(def.Meta.URL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid tree URL in %s: %v", treeMetaFile, err)
+	}
+	t, err := dnsdisc.MakeTree(def.Meta.Seq, def.Nodes, def.Meta.Links)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := t.SetSignature(pubkey, def.Meta.Sig); err != nil {
+		return "", nil, fmt.Errorf("tree signature is invalid, sign it again: %v", err)
+	}
+	return domain, t, nil
+}
+
+// loadTreeDefinition loads a tree directory.
+func loadTreeDefinition(dir string) (*dnsDefinition, error) {
+	def := new(dnsDefinition)
+	if err := readJSONFile(filepath.Join(dir, treeMetaFile), &def.Meta); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := readJSONFile(filepath.Join(dir, treeNodesFile), &def.Nodes); err != nil {
+		return nil, err
+	}
+	for _, link := range def.Meta.Links {
+		if _, _, err := dnsdisc.ParseURL(link); err != nil {
+			return nil, fmt.Errorf("invalid link %q: %v", link, err)
+		}
+	}
+	return def, nil
+}
+
+// writeTreeDefinition writes a tree directory, creating it if needed.
+func writeTreeDefinition(dir string, def *dnsDefinition) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeJSONFile(filepath.Join(dir, treeNodesFile), def.Nodes); err != nil {
+		return err
+	}
+	return writeTreeMeta(dir, &def.Meta)
+}
+
+func writeTreeMeta(dir string, meta *dnsMetaJSON) error {
+	if meta.Links == nil {
+		meta.Links = []string{}
+	}
+	return writeJSONFile(filepath.Join(dir, treeMetaFile), meta)
+}
+
+func readJSONFile(file string, v interface{}) error {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		return fmt.Errorf("invalid %s: %v", file, err)
+	}
+	return nil
+}
+
+func writeJSONFile(file string, v interface{}) error {
+	blob, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(blob, '\n'), treeDefinitionPerm)
+}
@@ -56,6 +56,9 @@ var (
 		utils.BootnodesFlag,
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
+		utils.DiscoveryDNSFlag,
+		utils.MasternodeMeshFlag,
+		utils.MasternodeRegistryFlag,
 		utils.DataDirFlag,
 		utils.KeyStoreDirFlag,
 		//utils.NoUSBFlag,
@@ -71,6 +74,7 @@ var (
 		utils.TomoXDBConnectionUrlFlag,
 		utils.TomoXDBReplicaSetNameFlag,
 		utils.TomoXDBNameFlag,
+		utils.TomoXRelayerMinDepositFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
@@ -81,10 +85,15 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolSponsorAllowListFlag,
 		utils.FastSyncFlag,
+		utils.SnapSyncFlag,
+		utils.SyncFromCheckpointFlag,
+		utils.SyncFromCheckpointSignersFlag,
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.StateHistoryFlag,
 		//utils.LightServFlag,
 		//utils.LightPeersFlag,
 		//utils.LightKDFFlag,
@@ -135,6 +144,7 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.GraphQLEnabledFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -174,6 +184,10 @@ func init() {
 		versionCommand,
 		// See config.go
 		dumpConfigCommand,
+		// See dnscmd.go
+		devp2pCommand,
+		// See tomoxcmd.go
+		tomoxCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
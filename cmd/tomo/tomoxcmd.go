@@ -0,0 +1,594 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tomochain/tomochain/cmd/utils"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	tomoxPairFlag = cli.StringSliceFlag{
+		Name:  "pair",
+		Usage: "Order book, as <baseToken>/<quoteToken> or order book hash (may be repeated)",
+	}
+	tomoxLendingBookFlag = cli.StringSliceFlag{
+		Name:  "lendingbook",
+		Usage: "Lending book, as <lendingToken>/<term in seconds> or lending book hash (may be repeated)",
+	}
+	tomoxSnapshotFlag = cli.StringFlag{
+		Name:  "snapshot",
+		Usage: "Replay on the books of a snapshot of the parent block written by 'tomo tomox dump'",
+	}
+
+	tomoxFlags = []cli.Flag{
+		utils.DataDirFlag,
+		utils.TomoXDataDirFlag,
+		utils.TomoTestnetFlag,
+		utils.CacheFlag,
+		configFileFlag,
+	}
+
+	tomoxCommand = cli.Command{
+		Name:     "tomox",
+		Usage:    "Inspect the TomoX trading and lending states",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The tomox commands work on the TomoX states of a stopped node, to investigate
+matching results which differ between masternodes without re-syncing.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "dump",
+				Usage:     "Dump order books and lending books at a block",
+				ArgsUsage: "<blockHash> | <blockNum>",
+				Action:    utils.MigrateFlags(tomoxDump),
+				Flags:     append([]cli.Flag{tomoxPairFlag, tomoxLendingBookFlag}, tomoxFlags...),
+				Description: `
+Prints as JSON the full trading state of the given pairs (order book info, ask
+and bid trees, resting orders and liquidation price tree) and the full lending
+state of the given lending books (book info, investing and borrowing trees,
+liquidation time tree, resting lending items and open lending trades), as of
+the given block. The output can be given to 'tomo tomox replay --snapshot'.`,
+			},
+			{
+				Name:      "replay",
+				Usage:     "Replay the orders and lending items of a block",
+				ArgsUsage: "<blockHash> | <blockNum>",
+				Action:    utils.MigrateFlags(tomoxReplay),
+				Flags:     append([]cli.Flag{tomoxPairFlag, tomoxLendingBookFlag, tomoxSnapshotFlag}, tomoxFlags...),
+				Description: `
+Matches again the order and lending transactions of the given block, outside
+the chain, on the TomoX states of its parent block, or on the books of a
+snapshot of the parent block. Prints as JSON the trades and rejected orders
+of every order, then reports the differences between the replayed and the
+recorded state roots, books touched by the block or given with --pair and
+--lendingbook, and matching results (relayer statistics) of the block.
+The state roots are not compared when replaying on a snapshot, which only
+holds some books. Exits with an error if any difference is found.`,
+			},
+		},
+	}
+)
+
+// tomoxSnapshot is the output of the dump command
+type tomoxSnapshot struct {
+	Number       uint64
+	Hash         common.Hash
+	TradingRoot  common.Hash
+	LendingRoot  common.Hash
+	OrderBooks   []*tradingstate.OrderBookSnapshot
+	LendingBooks []*lendingstate.LendingBookSnapshot
+}
+
+// tomoxReplayedOrder is the matching result of an order of a replayed block
+type tomoxReplayedOrder struct {
+	TxHash    common.Hash
+	OrderBook common.Hash
+	Hash      common.Hash
+	Trades    []map[string]string
+	Rejects   []common.Hash
+}
+
+// tomoxReplayedLendingItem is the matching result of a lending item of a
+// replayed block
+type tomoxReplayedLendingItem struct {
+	TxHash      common.Hash
+	LendingBook common.Hash
+	Hash        common.Hash
+	Trades      []*lendingstate.LendingTrade
+	Rejects     []common.Hash
+}
+
+// tomoxRootDiff holds a replayed and a recorded state root
+type tomoxRootDiff struct {
+	Replayed common.Hash
+	Recorded common.Hash
+}
+
+type tomoxOrderBookDiff struct {
+	OrderBook common.Hash
+	Replayed  *tradingstate.OrderBookSnapshot
+	Recorded  *tradingstate.OrderBookSnapshot
+}
+
+type tomoxLendingBookDiff struct {
+	LendingBook common.Hash
+	Replayed    *lendingstate.LendingBookSnapshot
+	Recorded    *lendingstate.LendingBookSnapshot
+}
+
+// tomoxReplayReport is the output of the replay command
+type tomoxReplayReport struct {
+	Number        uint64
+	Hash          common.Hash
+	Orders        []*tomoxReplayedOrder
+	LendingItems  []*tomoxReplayedLendingItem
+	TradingRoot   tomoxRootDiff
+	LendingRoot   tomoxRootDiff
+	OrderBooks    []*tomoxOrderBookDiff   `json:",omitempty"`
+	LendingBooks  []*tomoxLendingBookDiff `json:",omitempty"`
+	RelayerStats  map[common.Address]*tomox.RelayerStats
+	RecordedStats map[common.Address]*tomox.RelayerStats `json:",omitempty"`
+	Notes         []string                               `json:",omitempty"`
+	Mismatches    []string                               `json:",omitempty"`
+}
+
+// makeTomoXServices opens the chain and the TomoX services of the node,
+// without starting them.
+func makeTomoXServices(ctx *cli.Context) (*core.BlockChain, ethdb.Database, *tomox.TomoX, *tomoxlending.Lending) {
+	stack, cfg := makeConfigNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+
+	// the states are only read from the leveldb databases, never written to
+	// the SDK database
+	cfg.TomoX.DBEngine = utils.TomoXDBEngineFlag.Value
+	tomoX := tomox.New(&cfg.TomoX)
+	lending := tomoxlending.New(tomoX)
+	if engine, ok := chain.Engine().(*posv.Posv); ok {
+		engine.GetTomoXService = func() posv.TradingService {
+			return tomoX
+		}
+		engine.GetLendingService = func() posv.LendingService {
+			return lending
+		}
+	}
+	return chain, chainDb, tomoX, lending
+}
+
+// tomoxBlock returns the block given as argument of a tomox command
+func tomoxBlock(ctx *cli.Context, chain *core.BlockChain) (*types.Block, error) {
+	if len(ctx.Args()) != 1 {
+		return nil, errors.New("this command requires a block number or hash as argument")
+	}
+	var (
+		arg   = ctx.Args().First()
+		block *types.Block
+	)
+	if hashish(arg) {
+		block = chain.GetBlockByHash(common.HexToHash(arg))
+	} else {
+		num, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block number %s: %v", arg, err)
+		}
+		block = chain.GetBlockByNumber(num)
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %s not found", arg)
+	}
+	return block, nil
+}
+
+// parseBooks returns the books given with a flag, as hashes or as pairs of a
+// token address and a second value turned into a book hash by bookHash.
+func parseBooks(values []string, bookHash func(token common.Address, second string) (common.Hash, error)) ([]common.Hash, error) {
+	books := make([]common.Hash, 0, len(values))
+	for _, value := range values {
+		parts := strings.Split(value, "/")
+		switch {
+		case len(parts) == 2 && common.IsHexAddress(parts[0]):
+			book, err := bookHash(common.HexToAddress(parts[0]), parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid book %s: %v", value, err)
+			}
+			books = append(books, book)
+		case len(parts) == 1 && len(common.FromHex(value)) == common.HashLength:
+			books = append(books, common.HexToHash(value))
+		default:
+			return nil, fmt.Errorf("invalid book %s", value)
+		}
+	}
+	return books, nil
+}
+
+// tomoxBooks returns the order books and the lending books given with the
+// --pair and --lendingbook flags
+func tomoxBooks(ctx *cli.Context) ([]common.Hash, []common.Hash, error) {
+	orderBooks, err := parseBooks(ctx.StringSlice(tomoxPairFlag.Name), func(baseToken common.Address, quoteToken string) (common.Hash, error) {
+		if !common.IsHexAddress(quoteToken) {
+			return common.Hash{}, errors.New("quote token is not an address")
+		}
+		return tradingstate.GetTradingOrderBookHash(baseToken, common.HexToAddress(quoteToken)), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	lendingBooks, err := parseBooks(ctx.StringSlice(tomoxLendingBookFlag.Name), func(lendingToken common.Address, term string) (common.Hash, error) {
+		termSeconds, err := strconv.ParseUint(term, 10, 64)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		return lendingstate.GetLendingOrderBookHash(lendingToken, termSeconds), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return orderBooks, lendingBooks, nil
+}
+
+// tomoxStates returns the TomoX states of a block
+func tomoxStates(chain *core.BlockChain, tomoX *tomox.TomoX, lending *tomoxlending.Lending, block *types.Block) (*tradingstate.TradingStateDB, *lendingstate.LendingStateDB, error) {
+	author, err := chain.Engine().Author(block.Header())
+	if err != nil {
+		return nil, nil, fmt.Errorf("author of block %d unavailable: %v", block.NumberU64(), err)
+	}
+	tradingState, err := tomoX.GetTradingState(block, author)
+	if err != nil {
+		return nil, nil, fmt.Errorf("trading state of block %d unavailable: %v", block.NumberU64(), err)
+	}
+	lendingState, err := lending.GetLendingState(block, author)
+	if err != nil {
+		return nil, nil, fmt.Errorf("lending state of block %d unavailable: %v", block.NumberU64(), err)
+	}
+	return tradingState, lendingState, nil
+}
+
+func printJSON(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func tomoxDump(ctx *cli.Context) error {
+	chain, chainDb, tomoX, lending := makeTomoXServices(ctx)
+	defer chainDb.Close()
+
+	block, err := tomoxBlock(ctx, chain)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	orderBooks, lendingBooks, err := tomoxBooks(ctx)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	if len(orderBooks) == 0 && len(lendingBooks) == 0 {
+		utils.Fatalf("no book to dump, use --%s or --%s", tomoxPairFlag.Name, tomoxLendingBookFlag.Name)
+	}
+	tradingState, lendingState, err := tomoxStates(chain, tomoX, lending, block)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	snapshot := &tomoxSnapshot{
+		Number:      block.NumberU64(),
+		Hash:        block.Hash(),
+		TradingRoot: tradingState.IntermediateRoot(),
+		LendingRoot: lendingState.IntermediateRoot(),
+	}
+	for _, orderBook := range orderBooks {
+		book, err := tradingState.DumpOrderBook(orderBook)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		snapshot.OrderBooks = append(snapshot.OrderBooks, book)
+	}
+	for _, lendingBook := range lendingBooks {
+		book, err := lendingState.DumpLendingBook(lendingBook)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		snapshot.LendingBooks = append(snapshot.LendingBooks, book)
+	}
+	return printJSON(snapshot)
+}
+
+// loadTomoXSnapshot returns TomoX states holding only the books of the
+// snapshot written by the dump command at file.
+func loadTomoXSnapshot(file string, parent *types.Block) (*tradingstate.TradingStateDB, *lendingstate.LendingStateDB, *tomoxSnapshot, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	snapshot := new(tomoxSnapshot)
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid snapshot %s: %v", file, err)
+	}
+	if snapshot.Hash != parent.Hash() {
+		return nil, nil, nil, fmt.Errorf("snapshot is taken at block %d (%x), not at the parent block %d (%x)", snapshot.Number, snapshot.Hash, parent.NumberU64(), parent.Hash())
+	}
+	tradingState, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	for _, book := range snapshot.OrderBooks {
+		if err := tradingState.ImportOrderBook(book); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	lendingState, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+	for _, book := range snapshot.LendingBooks {
+		if err := lendingState.ImportLendingBook(book); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return tradingState, lendingState, snapshot, nil
+}
+
+// replayTomoXBlock matches again the orders and lending items of block on the
+// given states of its parent, the same way the blockchain does when inserting
+// the block.
+func replayTomoXBlock(chain *core.BlockChain, tomoX *tomox.TomoX, lending *tomoxlending.Lending, block, parent *types.Block, tradingState *tradingstate.TradingStateDB, lendingState *lendingstate.LendingStateDB, report *tomoxReplayReport) (*tomox.BlockRelayerStats, error) {
+	var (
+		header = block.Header()
+		epoch  = chain.Config().Posv.Epoch
+		stats  = tomox.NewBlockRelayerStats()
+	)
+	author, err := chain.Engine().Author(header)
+	if err != nil {
+		return nil, fmt.Errorf("author of block %d unavailable: %v", block.NumberU64(), err)
+	}
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	if block.NumberU64()%epoch == 0 {
+		return stats, tomoX.UpdateMediumPriceBeforeEpoch(block.NumberU64()/epoch, tradingState, statedb)
+	}
+	txMatchBatches, err := core.ExtractTradingTransactions(block.Transactions())
+	if err != nil {
+		return nil, err
+	}
+	for _, txMatchBatch := range txMatchBatches {
+		for _, txMatch := range txMatchBatch.Data {
+			order, err := txMatch.DecodeOrder()
+			if err != nil {
+				report.Notes = append(report.Notes, fmt.Sprintf("skipped corrupted order of tx %x: %v", txMatchBatch.TxHash, err))
+				continue
+			}
+			orderBook := tradingstate.GetTradingOrderBookHash(order.BaseToken, order.QuoteToken)
+			trades, rejects, err := tomoX.ApplyOrder(header, author, chain, statedb, tradingState, orderBook, order)
+			if err != nil {
+				return nil, fmt.Errorf("failed to apply order %x of tx %x: %v", order.Hash, txMatchBatch.TxHash, err)
+			}
+			stats.RecordTradingResult(order, trades, rejects)
+			replayed := &tomoxReplayedOrder{TxHash: txMatchBatch.TxHash, OrderBook: orderBook, Hash: order.Hash, Trades: trades}
+			for _, reject := range rejects {
+				replayed.Rejects = append(replayed.Rejects, reject.Hash)
+			}
+			report.Orders = append(report.Orders, replayed)
+		}
+	}
+	lendingBatches, err := core.ExtractLendingTransactions(block.Transactions())
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range lendingBatches {
+		for _, item := range batch.Data {
+			lendingBook := lendingstate.GetLendingOrderBookHash(item.LendingToken, item.Term)
+			trades, rejects, err := lending.ApplyOrder(header, author, chain, statedb, lendingState, tradingState, lendingBook, item)
+			if err != nil {
+				return nil, fmt.Errorf("failed to apply lending item %x of tx %x: %v", item.Hash, batch.TxHash, err)
+			}
+			stats.RecordLendingResult(item, trades, rejects)
+			replayed := &tomoxReplayedLendingItem{TxHash: batch.TxHash, LendingBook: lendingBook, Hash: item.Hash, Trades: trades}
+			for _, reject := range rejects {
+				replayed.Rejects = append(replayed.Rejects, reject.Hash)
+			}
+			report.LendingItems = append(report.LendingItems, replayed)
+		}
+	}
+	if block.NumberU64()%epoch == common.LiquidateLendingTradeBlock {
+		if _, _, _, _, _, err := lending.ProcessLiquidationData(header, chain, statedb, tradingState, lendingState); err != nil {
+			return nil, fmt.Errorf("failed to process liquidation data: %v", err)
+		}
+	}
+	return stats, nil
+}
+
+// sameJSON reports whether a and b have the same JSON encoding
+func sameJSON(a, b interface{}) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+func tomoxReplay(ctx *cli.Context) error {
+	chain, chainDb, tomoX, lending := makeTomoXServices(ctx)
+	defer chainDb.Close()
+
+	block, err := tomoxBlock(ctx, chain)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	orderBooks, lendingBooks, err := tomoxBooks(ctx)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	config := chain.Config()
+	if config.Posv == nil || !config.IsTIPTomoX(block.Number()) || block.NumberU64() <= config.Posv.Epoch {
+		utils.Fatalf("TomoX is not enabled at block %d", block.NumberU64())
+	}
+	parent := chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		utils.Fatalf("parent of block %d not found", block.NumberU64())
+	}
+
+	// Load the states to replay the block on
+	var (
+		tradingState *tradingstate.TradingStateDB
+		lendingState *lendingstate.LendingStateDB
+		fromSnapshot = ctx.IsSet(tomoxSnapshotFlag.Name)
+	)
+	if fromSnapshot {
+		var snapshot *tomoxSnapshot
+		if tradingState, lendingState, snapshot, err = loadTomoXSnapshot(ctx.String(tomoxSnapshotFlag.Name), parent); err != nil {
+			utils.Fatalf("%v", err)
+		}
+		for _, book := range snapshot.OrderBooks {
+			orderBooks = append(orderBooks, book.OrderBook)
+		}
+		for _, book := range snapshot.LendingBooks {
+			lendingBooks = append(lendingBooks, book.LendingBook)
+		}
+	} else if tradingState, lendingState, err = tomoxStates(chain, tomoX, lending, parent); err != nil {
+		utils.Fatalf("%v", err)
+	}
+	report := &tomoxReplayReport{Number: block.NumberU64(), Hash: block.Hash()}
+	stats, err := replayTomoXBlock(chain, tomoX, lending, block, parent, tradingState, lendingState, report)
+	if err != nil {
+		utils.Fatalf("Failed to replay block %d: %v", block.NumberU64(), err)
+	}
+	report.RelayerStats = stats.Relayers
+
+	// Compare the state roots
+	author, err := chain.Engine().Author(block.Header())
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	report.TradingRoot.Replayed = tradingState.IntermediateRoot()
+	report.TradingRoot.Recorded, _ = tomoX.GetTradingStateRoot(block, author)
+	report.LendingRoot.Replayed = lendingState.IntermediateRoot()
+	report.LendingRoot.Recorded, _ = lending.GetLendingStateRoot(block, author)
+	if fromSnapshot {
+		report.Notes = append(report.Notes, "state roots not compared, the block is replayed on a snapshot")
+	} else {
+		if report.TradingRoot.Replayed != report.TradingRoot.Recorded {
+			report.Mismatches = append(report.Mismatches, "trading state root")
+		}
+		if report.LendingRoot.Replayed != report.LendingRoot.Recorded {
+			report.Mismatches = append(report.Mismatches, "lending state root")
+		}
+	}
+
+	// Compare the books touched by the block. The replayed states are committed
+	// and reopened so that they are read back like the recorded ones.
+	tradingRoot, err := tradingState.Commit()
+	if err != nil {
+		utils.Fatalf("Failed to commit replayed trading state: %v", err)
+	}
+	if tradingState, err = tradingstate.New(tradingRoot, tradingState.Database()); err != nil {
+		utils.Fatalf("Failed to reopen replayed trading state: %v", err)
+	}
+	lendingRoot, err := lendingState.Commit()
+	if err != nil {
+		utils.Fatalf("Failed to commit replayed lending state: %v", err)
+	}
+	if lendingState, err = lendingstate.New(lendingRoot, lendingState.Database()); err != nil {
+		utils.Fatalf("Failed to reopen replayed lending state: %v", err)
+	}
+	recordedTrading, recordedLending, err := tomoxStates(chain, tomoX, lending, block)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	for _, order := range report.Orders {
+		orderBooks = append(orderBooks, order.OrderBook)
+	}
+	for _, item := range report.LendingItems {
+		lendingBooks = append(lendingBooks, item.LendingBook)
+	}
+	compared := map[common.Hash]bool{}
+	for _, orderBook := range orderBooks {
+		if compared[orderBook] {
+			continue
+		}
+		compared[orderBook] = true
+		diff := &tomoxOrderBookDiff{OrderBook: orderBook}
+		if tradingState.Exist(orderBook) {
+			if diff.Replayed, err = tradingState.DumpOrderBook(orderBook); err != nil {
+				utils.Fatalf("%v", err)
+			}
+		}
+		if recordedTrading.Exist(orderBook) {
+			if diff.Recorded, err = recordedTrading.DumpOrderBook(orderBook); err != nil {
+				utils.Fatalf("%v", err)
+			}
+		}
+		if !sameJSON(diff.Replayed, diff.Recorded) {
+			report.OrderBooks = append(report.OrderBooks, diff)
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf("order book %x", orderBook))
+		}
+	}
+	for _, lendingBook := range lendingBooks {
+		if compared[lendingBook] {
+			continue
+		}
+		compared[lendingBook] = true
+		diff := &tomoxLendingBookDiff{LendingBook: lendingBook}
+		if lendingState.Exist(lendingBook) {
+			if diff.Replayed, err = lendingState.DumpLendingBook(lendingBook); err != nil {
+				utils.Fatalf("%v", err)
+			}
+		}
+		if recordedLending.Exist(lendingBook) {
+			if diff.Recorded, err = recordedLending.DumpLendingBook(lendingBook); err != nil {
+				utils.Fatalf("%v", err)
+			}
+		}
+		if !sameJSON(diff.Replayed, diff.Recorded) {
+			report.LendingBooks = append(report.LendingBooks, diff)
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf("lending book %x", lendingBook))
+		}
+	}
+
+	// Compare the matching results recorded by the node
+	recordedStats, err := tomoX.GetBlockRelayerStats(block.Hash())
+	if err != nil {
+		utils.Fatalf("Failed to read the matching results of block %d: %v", block.NumberU64(), err)
+	}
+	switch {
+	case recordedStats == nil && len(stats.Relayers) > 0:
+		report.Notes = append(report.Notes, "no matching results recorded for the block")
+	case recordedStats != nil && !sameJSON(stats.Relayers, recordedStats):
+		report.RecordedStats = recordedStats
+		report.Mismatches = append(report.Mismatches, "matching results")
+	}
+	if err := printJSON(report); err != nil {
+		return err
+	}
+	if len(report.Mismatches) > 0 {
+		fmt.Fprintf(os.Stderr, "Replayed block %d differs from the chain: %s\n", block.NumberU64(), strings.Join(report.Mismatches, ", "))
+		return fmt.Errorf("%d mismatches", len(report.Mismatches))
+	}
+	return nil
+}
@@ -73,7 +73,11 @@ var AppHelpFlagGroups = []flagGroup{
 			//utils.TestnetFlag,
 			//utils.RinkebyFlag,
 			utils.SyncModeFlag,
+			utils.SnapSyncFlag,
+			utils.SyncFromCheckpointFlag,
+			utils.SyncFromCheckpointSignersFlag,
 			utils.GCModeFlag,
+			utils.StateHistoryFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			//utils.LightServFlag,
@@ -121,6 +125,7 @@ var AppHelpFlagGroups = []flagGroup{
 	//		utils.TxPoolAccountQueueFlag,
 	//		utils.TxPoolGlobalQueueFlag,
 	//		utils.TxPoolLifetimeFlag,
+	//		utils.TxPoolSponsorAllowListFlag,
 	//	},
 	//},
 	//{
@@ -146,6 +151,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
+			utils.GraphQLEnabledFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
@@ -166,6 +172,9 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.BootnodesFlag,
 			utils.BootnodesV4Flag,
 			utils.BootnodesV5Flag,
+			utils.DiscoveryDNSFlag,
+			utils.MasternodeMeshFlag,
+			utils.MasternodeRegistryFlag,
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
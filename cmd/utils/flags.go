@@ -32,6 +32,7 @@ import (
 	"github.com/tomochain/tomochain/accounts/keystore"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/common/fdlimit"
+	"github.com/tomochain/tomochain/common/hexutil"
 	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/consensus/ethash"
 	"github.com/tomochain/tomochain/consensus/posv"
@@ -48,6 +49,7 @@ import (
 	"github.com/tomochain/tomochain/p2p"
 	"github.com/tomochain/tomochain/p2p/discover"
 	"github.com/tomochain/tomochain/p2p/discv5"
+	"github.com/tomochain/tomochain/p2p/dnsdisc"
 	"github.com/tomochain/tomochain/p2p/nat"
 	"github.com/tomochain/tomochain/p2p/netutil"
 	"github.com/tomochain/tomochain/params"
@@ -175,6 +177,18 @@ var (
 		Name:  "fast",
 		Usage: "Enable fast syncing through state downloads",
 	}
+	SnapSyncFlag = cli.BoolFlag{
+		Name:  "snapsync",
+		Usage: "Retrieve the fast sync pivot state, including the TomoX trading and lending states, in proven ranges",
+	}
+	SyncFromCheckpointFlag = cli.StringFlag{
+		Name:  "syncfrom-checkpoint",
+		Usage: "Start syncing from a trusted epoch checkpoint (<number>:<hash> or signed checkpoint file)",
+	}
+	SyncFromCheckpointSignersFlag = cli.StringFlag{
+		Name:  "syncfrom-checkpoint.signers",
+		Usage: "Comma separated addresses trusted to sign checkpoint files",
+	}
 	LightModeFlag = cli.BoolFlag{
 		Name:  "light",
 		Usage: "Enable light client mode",
@@ -190,6 +204,11 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	StateHistoryFlag = cli.Uint64Flag{
+		Name:  "statehistory",
+		Usage: "Number of recent blocks to keep reverse state diffs for, to serve pruned states (0 = disabled)",
+		Value: eth.DefaultConfig.StateHistory,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -289,6 +308,10 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolSponsorAllowListFlag = cli.StringFlag{
+		Name:  "txpool.sponsorallowlist",
+		Usage: "Address of the contract vetting the fee payers of sponsored transactions",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -402,6 +425,10 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	GraphQLEnabledFlag = cli.BoolFlag{
+		Name:  "graphql",
+		Usage: "Enable GraphQL on the HTTP-RPC server (served at /graphql, requires --rpc)",
+	}
 	IPCDisabledFlag = cli.BoolFlag{
 		Name:  "ipcdisable",
 		Usage: "Disable the IPC-RPC server",
@@ -474,6 +501,19 @@ var (
 		Usage: "Comma separated enode URLs for P2P v5 discovery bootstrap (light server, light nodes)",
 		Value: "",
 	}
+	DiscoveryDNSFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists to dial nodes from",
+		Value: "",
+	}
+	MasternodeMeshFlag = cli.BoolFlag{
+		Name:  "masternode.mesh",
+		Usage: "Keep reserved connections to the masternodes of the current epoch, re-evaluated at each checkpoint",
+	}
+	MasternodeRegistryFlag = cli.StringFlag{
+		Name:  "masternode.registry",
+		Usage: "JSON file of (optionally signed) masternode records resolving masternodes to enode URLs",
+	}
 	NodeKeyFileFlag = cli.StringFlag{
 		Name:  "nodekey",
 		Usage: "P2P node key file",
@@ -556,6 +596,11 @@ var (
 		Name:  "tomox.dbReplicaSetName",
 		Usage: "ReplicaSetName if Master-Slave is setup",
 	}
+	TomoXRelayerMinDepositFlag = BigFlag{
+		Name:  "tomox.relayermindeposit",
+		Usage: "Minimum relayer deposit (wei) for its new orders and lendings to be pooled, at least the fees of its next matching",
+		Value: new(big.Int),
+	}
 	TomoSlaveModeFlag = cli.BoolFlag{
 		Name:  "slave",
 		Usage: "Enable slave mode",
@@ -645,6 +690,23 @@ func setBootstrapNodes(ctx *cli.Context, cfg *p2p.Config) {
 	}
 }
 
+// setDiscoveryDNS sets the DNS node lists dialed in addition to discovery.
+func setDiscoveryDNS(ctx *cli.Context, cfg *p2p.Config) {
+	if !ctx.GlobalIsSet(DiscoveryDNSFlag.Name) {
+		return
+	}
+	cfg.DiscoveryDNS = []string{}
+	for _, url := range strings.Split(ctx.GlobalString(DiscoveryDNSFlag.Name), ",") {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+		if _, _, err := dnsdisc.ParseURL(url); err != nil {
+			Fatalf("Option %q: invalid DNS node list %q: %v", DiscoveryDNSFlag.Name, url, err)
+		}
+		cfg.DiscoveryDNS = append(cfg.DiscoveryDNS, url)
+	}
+}
+
 // setBootstrapNodesV5 creates a list of bootstrap nodes from the command line
 // flags, reverting to pre-configured ones if none have been specified.
 func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
@@ -805,6 +867,46 @@ func MakeAddress(ks *keystore.KeyStore, account string) (accounts.Account, error
 
 // setEtherbase retrieves the etherbase either from the directly specified
 // command line flags or from the keystore if CLI indexed.
+// setCheckpoint parses the trusted checkpoint to start syncing from, given either
+// as a block number and hash or as a checkpoint file signed by trusted signers.
+func setCheckpoint(ctx *cli.Context, cfg *eth.Config) {
+	if !ctx.GlobalIsSet(SyncFromCheckpointFlag.Name) {
+		return
+	}
+	spec := ctx.GlobalString(SyncFromCheckpointFlag.Name)
+	parts := strings.Split(spec, ":")
+	if number, err := strconv.ParseUint(parts[0], 0, 64); err == nil && len(parts) == 2 {
+		hash, err := hexutil.Decode(parts[1])
+		if err != nil || len(hash) != common.HashLength {
+			Fatalf("Invalid checkpoint hash %q", parts[1])
+		}
+		cfg.SyncFromCheckpoint = &core.Checkpoint{Number: number, Hash: common.BytesToHash(hash)}
+	} else {
+		var signers []common.Address
+		if ctx.GlobalIsSet(SyncFromCheckpointSignersFlag.Name) {
+			for _, signer := range splitAndTrim(ctx.GlobalString(SyncFromCheckpointSignersFlag.Name)) {
+				if !common.IsHexAddress(signer) {
+					Fatalf("Invalid checkpoint signer %q", signer)
+				}
+				signers = append(signers, common.HexToAddress(signer))
+			}
+		}
+		checkpoint, err := core.LoadCheckpointFile(spec, signers)
+		if err != nil {
+			Fatalf("Failed to load checkpoint file: %v", err)
+		}
+		cfg.SyncFromCheckpoint = checkpoint
+	}
+	if cfg.SyncFromCheckpoint.Number == 0 {
+		Fatalf("Checkpoint cannot be the genesis block")
+	}
+	// The blocks above the checkpoint are imported without their ancestors' state
+	if cfg.SyncMode != downloader.FastSync {
+		log.Warn("Switching to fast sync for checkpoint sync", "mode", cfg.SyncMode)
+		cfg.SyncMode = downloader.FastSync
+	}
+}
+
 func setEtherbase(ctx *cli.Context, ks *keystore.KeyStore, cfg *eth.Config) {
 	if ctx.GlobalIsSet(EtherbaseFlag.Name) {
 		account, err := MakeAddress(ks, ctx.GlobalString(EtherbaseFlag.Name))
@@ -839,6 +941,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	setListenAddress(ctx, cfg)
 	setBootstrapNodes(ctx, cfg)
 	// setBootstrapNodesV5(ctx, cfg)
+	setDiscoveryDNS(ctx, cfg)
 
 	lightClient := ctx.GlobalBool(LightModeFlag.Name) || ctx.GlobalString(SyncModeFlag.Name) == "light"
 	lightServer := ctx.GlobalInt(LightServFlag.Name) != 0
@@ -897,6 +1000,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.ListenAddr = ":0"
 		cfg.NoDiscovery = true
 		cfg.DiscoveryV5 = false
+		cfg.DiscoveryDNS = nil
 	}
 }
 
@@ -973,6 +1077,13 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolSponsorAllowListFlag.Name) {
+		allowList := ctx.GlobalString(TxPoolSponsorAllowListFlag.Name)
+		if !common.IsHexAddress(allowList) {
+			Fatalf("Invalid sponsor allow-list address %q", allowList)
+		}
+		cfg.SponsorAllowList = common.HexToAddress(allowList)
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
@@ -1102,6 +1213,19 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	case ctx.GlobalBool(LightModeFlag.Name):
 		cfg.SyncMode = downloader.LightSync
 	}
+	if ctx.GlobalIsSet(SnapSyncFlag.Name) {
+		cfg.SnapSync = ctx.GlobalBool(SnapSyncFlag.Name)
+	}
+	setCheckpoint(ctx, cfg)
+	if ctx.GlobalIsSet(MasternodeMeshFlag.Name) {
+		cfg.MasternodeMesh = ctx.GlobalBool(MasternodeMeshFlag.Name)
+	}
+	if ctx.GlobalIsSet(MasternodeRegistryFlag.Name) {
+		cfg.MasternodeRegistry = ctx.GlobalString(MasternodeRegistryFlag.Name)
+	}
+	if cfg.MasternodeMesh && cfg.MasternodeRegistry == "" {
+		Fatalf("Option %q requires %q", MasternodeMeshFlag.Name, MasternodeRegistryFlag.Name)
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
@@ -1121,6 +1245,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	if ctx.GlobalIsSet(StateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.GlobalUint64(StateHistoryFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -1137,6 +1264,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	if ctx.GlobalIsSet(TomoXRelayerMinDepositFlag.Name) {
+		cfg.RelayerMinDeposit = GlobalBig(ctx, TomoXRelayerMinDepositFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1233,7 +1363,16 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	}
 	var engine consensus.Engine
 	if config.Posv != nil {
-		engine = posv.New(config.Posv, chainDb)
+		c := posv.New(config.Posv, chainDb)
+		// The TomoX services only run in a full node, commands needing them
+		// replace these hooks once the chain is opened.
+		c.GetTomoXService = func() posv.TradingService {
+			return nil
+		}
+		c.GetLendingService = func() posv.LendingService {
+			return nil
+		}
+		engine = c
 	} else {
 		engine = ethash.NewFaker()
 		if !ctx.GlobalBool(FakePoWFlag.Name) {
@@ -1255,6 +1394,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieNodeLimit: eth.DefaultConfig.TrieCache,
 		TrieTimeLimit: eth.DefaultConfig.TrieTimeout,
+		StateHistory:  ctx.GlobalUint64(StateHistoryFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -4,6 +4,7 @@ import (
 	"github.com/tomochain/tomochain/eth"
 	"github.com/tomochain/tomochain/eth/downloader"
 	"github.com/tomochain/tomochain/ethstats"
+	"github.com/tomochain/tomochain/graphql"
 	"github.com/tomochain/tomochain/les"
 	"github.com/tomochain/tomochain/node"
 	"github.com/tomochain/tomochain/tomox"
@@ -63,6 +64,19 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterGraphQLService adds the GraphQL endpoint to the node's HTTP-RPC server.
+func RegisterGraphQLService(stack *node.Node) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return graphql.New(ethServ.ApiBackend)
+	}); err != nil {
+		Fatalf("Failed to register the GraphQL service: %v", err)
+	}
+}
+
 func RegisterTomoXService(stack *node.Node, cfg *tomox.Config) {
 	tomoX := tomox.New(cfg)
 	if err := stack.Register(func(n *node.ServiceContext) (node.Service, error) {
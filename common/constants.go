@@ -38,6 +38,13 @@ var TIPTRC21FeeBlock = big.NewInt(13523400)
 var TIPTomoXBlock = big.NewInt(20581700)
 var TIPTomoXLendingBlock = big.NewInt(21430200)
 var TIPTomoXCancellationFeeBlock = big.NewInt(30915660)
+var TIPTomoXLendingPartialRepayBlock = big.NewInt(9999999999)
+var TIPTomoXLendingOracleBlock = big.NewInt(9999999999)
+var TIPTomoXLendingAuctionBlock = big.NewInt(9999999999)
+var TIPTomoXLendingPoolBlock = big.NewInt(9999999999)
+var TIPTomoXLendingMarginBlock = big.NewInt(9999999999)
+var TIPTomoXLendingRolloverBlock = big.NewInt(9999999999)
+var TIPTomoXSelfTradePreventionBlock = big.NewInt(9999999999)
 var IsTestnet bool = false
 var StoreRewardFolder string
 var RollbackHash Hash
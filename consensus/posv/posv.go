@@ -40,6 +40,7 @@ import (
 	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/consensus/clique"
 	"github.com/tomochain/tomochain/consensus/misc"
+	"github.com/tomochain/tomochain/core/rawdb"
 	"github.com/tomochain/tomochain/core/state"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/crypto"
@@ -76,6 +77,9 @@ type TradingService interface {
 	SyncDataToSDKNode(takerOrder *tradingstate.OrderItem, txHash common.Hash, txMatchTime time.Time, statedb *state.StateDB, trades []map[string]string, rejectedOrders []*tradingstate.OrderItem, dirtyOrderCount *uint64) error
 	RollbackReorgTxMatch(txhash common.Hash) error
 	GetTokenDecimal(chain consensus.ChainContext, statedb *state.StateDB, tokenAddr common.Address) (*big.Int, error)
+	RecordTradingResult(hashNoValidator common.Hash, order *tradingstate.OrderItem, trades []map[string]string, rejects []*tradingstate.OrderItem)
+	RecordLendingResult(hashNoValidator common.Hash, item *lendingstate.LendingItem, trades []*lendingstate.LendingTrade, rejects []*lendingstate.LendingItem)
+	CommitRelayerStats(block *types.Block) error
 }
 
 type LendingService interface {
@@ -255,6 +259,7 @@ type Posv struct {
 	validatorSignatures *lru.ARCCache // Signatures of recent blocks to speed up mining
 	verifiedHeaders     *lru.ARCCache
 	proposals           map[common.Address]bool // Current list of proposals we are pushing
+	checkpoint          common.Hash             // Trusted checkpoint the chain was seeded from, if any
 
 	signer common.Address  // Ethereum address of the signing key
 	signFn clique.SignerFn // Signer function to authorize hashes with
@@ -285,6 +290,13 @@ func New(config *params.PosvConfig, db ethdb.Database) *Posv {
 	signatures, _ := lru.NewARC(inmemorySnapshots)
 	validatorSignatures, _ := lru.NewARC(inmemorySnapshots)
 	verifiedHeaders, _ := lru.NewARC(inmemorySnapshots)
+
+	var checkpoint common.Hash
+	if db != nil {
+		if cp := rawdb.ReadSyncCheckpoint(db); cp != nil {
+			checkpoint = cp.Hash
+		}
+	}
 	return &Posv{
 		config:              &conf,
 		db:                  db,
@@ -294,6 +306,7 @@ func New(config *params.PosvConfig, db ethdb.Database) *Posv {
 		verifiedHeaders:     verifiedHeaders,
 		validatorSignatures: validatorSignatures,
 		proposals:           make(map[common.Address]bool),
+		checkpoint:          checkpoint,
 	}
 }
 
@@ -540,6 +553,47 @@ func (c *Posv) StoreSnapshot(snap *Snapshot) error {
 	return snap.store(c.db)
 }
 
+// SeedCheckpoint stores the snapshot of a trusted checkpoint header the chain
+// is seeded from, with the masternodes listed in the header's extra data as the
+// signers. The snapshots of the blocks above the checkpoint are derived from it
+// rather than from its ancestors.
+func (c *Posv) SeedCheckpoint(header *types.Header) error {
+	number := header.Number.Uint64()
+	if number == 0 || number%c.config.Epoch != 0 {
+		return fmt.Errorf("block #%d is not an epoch checkpoint", number)
+	}
+	masternodes := GetMasternodesFromCheckpointHeader(header)
+	if len(masternodes) == 0 {
+		return errInvalidCheckpointSigners
+	}
+	creator, err := ecrecover(header, c.signatures)
+	if err != nil {
+		return err
+	}
+	snap := newSnapshot(c.config, c.signatures, number, header.Hash(), masternodes)
+	snap.Recents[number] = creator
+	if err := snap.store(c.db); err != nil {
+		return err
+	}
+	c.recents.Add(snap.Hash, snap)
+
+	c.lock.Lock()
+	c.checkpoint = snap.Hash
+	c.lock.Unlock()
+
+	log.Info("Seeded masternodes from checkpoint", "number", number, "hash", snap.Hash, "masternodes", len(masternodes))
+	return nil
+}
+
+// trustedCheckpoint returns the hash of the checkpoint the chain was seeded
+// from, if any.
+func (c *Posv) trustedCheckpoint() common.Hash {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.checkpoint
+}
+
 func position(list []common.Address, x common.Address) int {
 	for i, item := range list {
 		if item == x {
@@ -631,6 +685,15 @@ func (c *Posv) snapshot(chain consensus.ChainReader, number uint64, hash common.
 				break
 			}
 		}
+		// If we reached the trusted checkpoint the chain was seeded from, use its
+		// snapshot as the ancestors might not be available
+		if hash == c.trustedCheckpoint() {
+			if s, err := loadSnapshot(c.config, c.signatures, c.db, hash); err == nil {
+				log.Trace("Loaded checkpoint snapshot from disk", "number", number, "hash", hash)
+				snap = s
+				break
+			}
+		}
 		// If we're at block zero, make a snapshot
 		if number == 0 {
 			genesis := chain.GetHeaderByNumber(0)
@@ -134,6 +134,7 @@ func (v *BlockValidator) ValidateTradingOrder(statedb *state.StateDB, tomoxState
 			Trades:  newTrades,
 			Rejects: newRejectedOrders,
 		}
+		tomoXService.RecordTradingResult(header.HashNoValidator(), order, newTrades, newRejectedOrders)
 	}
 	if tomoXService.IsSDKNode() {
 		v.bc.AddMatchingResult(txMatchBatch.TxHash, tradingResult)
@@ -169,6 +170,7 @@ func (v *BlockValidator) ValidateLendingOrder(statedb *state.StateDB, lendingSta
 			Trades:  newTrades,
 			Rejects: newRejectedOrders,
 		}
+		tomoXService.RecordLendingResult(header.HashNoValidator(), l, newTrades, newRejectedOrders)
 	}
 	if tomoXService.IsSDKNode() {
 		v.bc.AddLendingResult(batch.TxHash, lendingResult)
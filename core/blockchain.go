@@ -81,6 +81,7 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	StateHistory  uint64        // Number of recent blocks to keep reverse state diffs for (0 = disabled)
 }
 type ResultProcessBlock struct {
 	logs         []*types.Log
@@ -160,6 +161,8 @@ type BlockChain struct {
 	// cache field for tracking finality purpose, can't use for tracking block vs block relationship
 	blocksHashCache *lru.Cache
 
+	historyStates *lru.Cache // States rebuilt from the state history, keyed by root
+
 	resultTrade         *lru.Cache // trades result: key - takerOrderHash, value: trades corresponding to takerOrder
 	rejectedOrders      *lru.Cache // rejected orders: key - takerOrderHash, value: rejected orders corresponding to takerOrder
 	resultLendingTrade  *lru.Cache
@@ -186,6 +189,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	resultProcess, _ := lru.New(blockCacheLimit)
 	preparingBlock, _ := lru.New(blockCacheLimit)
 	downloadingBlock, _ := lru.New(blockCacheLimit)
+	historyStates, _ := lru.New(historyStateCacheLimit)
 
 	// for tomox
 	resultTrade, _ := lru.New(tradingstate.OrderCacheLimit)
@@ -213,6 +217,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		vmConfig:            vmConfig,
 		badBlocks:           badBlocks,
 		blocksHashCache:     blocksHashCache,
+		historyStates:       historyStates,
 		resultTrade:         resultTrade,
 		rejectedOrders:      rejectedOrders,
 		resultLendingTrade:  resultLendingTrade,
@@ -449,6 +454,181 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	return nil
 }
 
+// SyncCheckpoint returns the trusted checkpoint the chain was seeded from along
+// with the progress of backfilling the blocks below it, or nil if the chain was
+// synced from the genesis.
+func (bc *BlockChain) SyncCheckpoint() *rawdb.SyncCheckpoint {
+	return rawdb.ReadSyncCheckpoint(bc.db)
+}
+
+// InsertCheckpoint seeds an empty chain with a trusted checkpoint block, setting
+// it as the head header and fast block so the chain can be fast synced onwards
+// from there, while the blocks below it are backfilled via InsertBackfillChain.
+//
+// The total difficulty of the checkpoint is not known until the backfill links
+// it to the genesis, td is stored as an estimate until then.
+func (bc *BlockChain) InsertCheckpoint(block *types.Block, td *big.Int) error {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if bc.CurrentHeader().Number.Uint64() != 0 || bc.CurrentFastBlock().NumberU64() != 0 {
+		return errors.New("chain not empty")
+	}
+	var (
+		hash   = block.Hash()
+		number = block.NumberU64()
+		tail   = number
+		batch  = bc.db.NewBatch()
+	)
+	if number == 0 {
+		return errors.New("checkpoint is the genesis")
+	}
+	if number == 1 {
+		if block.ParentHash() != bc.genesisBlock.Hash() {
+			return fmt.Errorf("checkpoint not derived from genesis [%x…]", bc.genesisBlock.Hash().Bytes()[:4])
+		}
+		tail = 0
+	}
+	rawdb.WriteTd(batch, hash, number, td)
+	rawdb.WriteBlock(batch, block)
+	rawdb.WriteCanonicalHash(batch, hash, number)
+	rawdb.WriteTxLookupEntries(batch, block)
+	rawdb.WriteHeadFastBlockHash(batch, hash)
+	rawdb.WriteSyncCheckpoint(batch, &rawdb.SyncCheckpoint{Number: number, Hash: hash, Tail: tail})
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	bc.hc.SetCurrentHeader(block.Header())
+	bc.currentFastBlock.Store(block)
+
+	log.Info("Seeded chain from checkpoint", "number", number, "hash", hash)
+	return nil
+}
+
+// InsertBackfillChain stores a contiguous batch of blocks directly below the
+// lowest block retrieved so far of a chain seeded from a checkpoint. As the
+// checkpoint is trusted, the blocks are only verified to hash-link to it. Once
+// the batch reaches the genesis, the total difficulties of the chain are
+// recalculated.
+func (bc *BlockChain) InsertBackfillChain(chain types.Blocks) error {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	checkpoint := rawdb.ReadSyncCheckpoint(bc.db)
+	if checkpoint == nil || checkpoint.Tail == 0 {
+		return errors.New("no backfill in progress")
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	tail := bc.GetHeaderByNumber(checkpoint.Tail)
+	if tail == nil {
+		return fmt.Errorf("missing backfill tail #%d", checkpoint.Tail)
+	}
+	// Ensure the batch links into the already retrieved chain
+	last := chain[len(chain)-1]
+	if last.NumberU64()+1 != checkpoint.Tail || last.Hash() != tail.ParentHash {
+		return fmt.Errorf("non contiguous backfill: have #%d [%x…], want #%d [%x…]", last.NumberU64(), last.Hash().Bytes()[:4], checkpoint.Tail-1, tail.ParentHash.Bytes()[:4])
+	}
+	for i := len(chain) - 1; i > 0; i-- {
+		if chain[i].NumberU64() != chain[i-1].NumberU64()+1 || chain[i].ParentHash() != chain[i-1].Hash() {
+			return fmt.Errorf("non contiguous backfill: item %d is #%d [%x…], item %d is #%d [%x…] (parent [%x…])", i-1, chain[i-1].NumberU64(),
+				chain[i-1].Hash().Bytes()[:4], i, chain[i].NumberU64(), chain[i].Hash().Bytes()[:4], chain[i].ParentHash().Bytes()[:4])
+		}
+	}
+	first := chain[0]
+	if first.NumberU64() == 0 {
+		return errors.New("backfill contains the genesis")
+	}
+	if first.NumberU64() == 1 && first.ParentHash() != bc.genesisBlock.Hash() {
+		return fmt.Errorf("checkpoint not derived from genesis [%x…]", bc.genesisBlock.Hash().Bytes()[:4])
+	}
+	batch := bc.db.NewBatch()
+	for _, block := range chain {
+		rawdb.WriteBlock(batch, block)
+		rawdb.WriteCanonicalHash(batch, block.Hash(), block.NumberU64())
+		rawdb.WriteTxLookupEntries(batch, block)
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	checkpoint.Tail = first.NumberU64()
+	if first.NumberU64() == 1 {
+		checkpoint.Tail = 0
+	}
+	rawdb.WriteSyncCheckpoint(batch, checkpoint)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if checkpoint.Tail == 0 {
+		return bc.linkCheckpoint(checkpoint)
+	}
+	return nil
+}
+
+// linkCheckpoint calculates the total difficulties of the blocks up to a fully
+// backfilled checkpoint and corrects the estimated ones of the canonical blocks
+// imported on top of it.
+func (bc *BlockChain) linkCheckpoint(checkpoint *rawdb.SyncCheckpoint) error {
+	var (
+		td    = new(big.Int).Set(bc.GetTd(bc.genesisBlock.Hash(), 0))
+		batch = bc.db.NewBatch()
+	)
+	for number := uint64(1); number <= checkpoint.Number; number++ {
+		header := bc.GetHeaderByNumber(number)
+		if header == nil {
+			return fmt.Errorf("missing backfilled header #%d", number)
+		}
+		td.Add(td, header.Difficulty)
+		if number < checkpoint.Number {
+			rawdb.WriteTd(batch, header.Hash(), number, td)
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	batch.Reset()
+
+	// Shift the canonical chain above the checkpoint by the estimation error.
+	// Side chains keep their estimates, they are short lived past the head.
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	estimate := bc.GetTd(checkpoint.Hash, checkpoint.Number)
+	if estimate == nil {
+		return fmt.Errorf("missing checkpoint #%d total difficulty", checkpoint.Number)
+	}
+	delta := new(big.Int).Sub(td, estimate)
+	if delta.Sign() != 0 {
+		head := bc.CurrentHeader().Number.Uint64()
+		for number := checkpoint.Number; number <= head; number++ {
+			hash := rawdb.GetCanonicalHash(bc.db, number)
+			if old := bc.GetTd(hash, number); old != nil {
+				rawdb.WriteTd(batch, hash, number, new(big.Int).Add(old, delta))
+			}
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		bc.hc.tdCache.Purge()
+	}
+	log.Info("Linked checkpoint to the genesis", "number", checkpoint.Number, "hash", checkpoint.Hash, "td", td, "estimated", estimate)
+	return nil
+}
+
 // GasLimit returns the gas limit of the current HEAD block.
 func (bc *BlockChain) GasLimit() uint64 {
 	return bc.CurrentBlock().GasLimit()
@@ -504,6 +684,11 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(root, bc.stateCache)
 }
 
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
 // OrderStateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) OrderStateAt(block *types.Block) (*tradingstate.TradingStateDB, error) {
 	engine, ok := bc.Engine().(*posv.Posv)
@@ -1181,6 +1366,11 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	if err := rawdb.WriteBlock(batch, block); err != nil {
 		return NonStatTy, err
 	}
+	if !bc.cacheConfig.Disabled && bc.cacheConfig.StateHistory > 0 {
+		if err := bc.writeStateHistory(batch, block, state); err != nil {
+			log.Warn("Failed to write state history", "number", block.Number(), "hash", block.Hash(), "err", err)
+		}
+	}
 	root, err := state.Commit(bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
 		return NonStatTy, err
@@ -1208,6 +1398,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		tradingService = engine.GetTomoXService()
 		if tradingService != nil {
 			tradingTrieDb = tradingService.GetStateCache().TrieDB()
+			if err := tradingService.CommitRelayerStats(block); err != nil {
+				log.Warn("Failed to write relayer statistics", "number", block.NumberU64(), "hash", block.Hash(), "err", err)
+			}
 		}
 		lendingService = engine.GetLendingService()
 		if lendingService != nil {
@@ -1327,6 +1520,17 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	if err := rawdb.WriteBlockReceipts(batch, block.Hash(), block.NumberU64(), receipts); err != nil {
 		return NonStatTy, err
 	}
+	var failures []*types.TxFailure
+	for _, receipt := range receipts {
+		if receipt.Failure != nil {
+			failures = append(failures, receipt.Failure)
+		}
+	}
+	if len(failures) > 0 {
+		if err := rawdb.WriteTxFailures(batch, block.Hash(), block.NumberU64(), failures); err != nil {
+			return NonStatTy, err
+		}
+	}
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
 	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
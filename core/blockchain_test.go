@@ -17,6 +17,7 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -1412,3 +1413,53 @@ func TestAreTwoBlocksSamePath(t *testing.T) {
 	})
 
 }
+
+// Tests that the revert reason of failed transactions is stored next to the
+// receipts of the block.
+func TestTxFailureStorage(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		// Contract reverting with Error("x")
+		reverter = common.HexToAddress("0xdead")
+		code     = common.FromHex("6064600c60003960646000fd08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000017800000000000000000000000000000000000000000000000000000000000000")
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address:  {Balance: big.NewInt(1000000000000000)},
+				reverter: {Balance: common.Big0, Code: code},
+			},
+		}
+		db      = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(0, reverter, common.Big0, 100000, big.NewInt(1), nil), signer, key)
+		b.AddTx(tx)
+		tx, _ = types.SignTx(types.NewTransaction(1, common.Address{1}, common.Big1, params.TxGas, big.NewInt(1), nil), signer, key)
+		b.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+	chain, _ := NewBlockChain(diskdb, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	failures := rawdb.ReadTxFailures(diskdb, blocks[0].Hash(), blocks[0].NumberU64())
+	if len(failures) != 1 {
+		t.Fatalf("failure count mismatch: have %d, want 1", len(failures))
+	}
+	failure := failures[0]
+	if failure.TxHash != blocks[0].Transactions()[0].Hash() {
+		t.Errorf("failed transaction mismatch: have %x, want %x", failure.TxHash, blocks[0].Transactions()[0].Hash())
+	}
+	if failure.Code != types.TxFailureReverted {
+		t.Errorf("failure code mismatch: have %s, want %s", failure.Code, types.TxFailureReverted)
+	}
+	if want := code[12:]; !bytes.Equal(failure.Data, want) {
+		t.Errorf("revert data mismatch: have %x, want %x", failure.Data, want)
+	}
+}
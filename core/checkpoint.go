@@ -0,0 +1,150 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+var (
+	// ErrCheckpointMismatch is returned if a checkpoint header does not match
+	// the number and hash it was trusted with.
+	ErrCheckpointMismatch = errors.New("checkpoint header mismatch")
+
+	// ErrCheckpointSigners is returned if a signed checkpoint does not carry
+	// enough signatures from the trusted checkpoint signers.
+	ErrCheckpointSigners = errors.New("not enough trusted checkpoint signatures")
+)
+
+// Checkpoint is a trusted block a node can start syncing from instead of the
+// genesis. With PoSV the block must be an epoch checkpoint, as its header
+// carries the masternode list the following blocks are verified against.
+type Checkpoint struct {
+	Number uint64
+	Hash   common.Hash
+	Header *types.Header // Checkpoint header if known, retrieved from the network otherwise
+}
+
+// SigHash returns the hash signed by the checkpoint signers.
+func (c *Checkpoint) SigHash() common.Hash {
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], c.Number)
+	return crypto.Keccak256Hash(number[:], c.Hash[:])
+}
+
+// Verify checks that the header, if any, matches the trusted number and hash.
+func (c *Checkpoint) Verify() error {
+	if c.Header == nil {
+		return nil
+	}
+	if c.Header.Number.Uint64() != c.Number || c.Header.Hash() != c.Hash {
+		return ErrCheckpointMismatch
+	}
+	return nil
+}
+
+// checkpointJSON is the on disk format of a signed checkpoint file.
+type checkpointJSON struct {
+	Number     hexutil.Uint64  `json:"number"`
+	Hash       common.Hash     `json:"hash"`
+	Header     hexutil.Bytes   `json:"header,omitempty"`
+	Signatures []hexutil.Bytes `json:"signatures"`
+}
+
+// SignCheckpoint signs the checkpoint with the given key.
+func SignCheckpoint(c *Checkpoint, key *ecdsa.PrivateKey) ([]byte, error) {
+	return crypto.Sign(c.SigHash().Bytes(), key)
+}
+
+// WriteCheckpointFile stores a signed checkpoint in the given file.
+func WriteCheckpointFile(file string, c *Checkpoint, signatures [][]byte) error {
+	enc := checkpointJSON{
+		Number: hexutil.Uint64(c.Number),
+		Hash:   c.Hash,
+	}
+	if c.Header != nil {
+		header, err := rlp.EncodeToBytes(c.Header)
+		if err != nil {
+			return err
+		}
+		enc.Header = header
+	}
+	for _, sig := range signatures {
+		enc.Signatures = append(enc.Signatures, sig)
+	}
+	blob, err := json.MarshalIndent(enc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, blob, 0644)
+}
+
+// LoadCheckpointFile reads a signed checkpoint from the given file, accepting
+// it only if more than half of the trusted signers signed it.
+func LoadCheckpointFile(file string, signers []common.Address) (*Checkpoint, error) {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var dec checkpointJSON
+	if err := json.Unmarshal(blob, &dec); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file: %v", err)
+	}
+	checkpoint := &Checkpoint{
+		Number: uint64(dec.Number),
+		Hash:   dec.Hash,
+	}
+	if len(dec.Header) > 0 {
+		checkpoint.Header = new(types.Header)
+		if err := rlp.DecodeBytes(dec.Header, checkpoint.Header); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint header: %v", err)
+		}
+	}
+	if err := checkpoint.Verify(); err != nil {
+		return nil, err
+	}
+	// Count the distinct trusted signers of the checkpoint
+	trusted := make(map[common.Address]bool)
+	for _, signer := range signers {
+		trusted[signer] = true
+	}
+	signed := make(map[common.Address]bool)
+	for _, sig := range dec.Signatures {
+		pubkey, err := crypto.SigToPub(checkpoint.SigHash().Bytes(), sig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkpoint signature: %v", err)
+		}
+		if signer := crypto.PubkeyToAddress(*pubkey); trusted[signer] {
+			signed[signer] = true
+		}
+	}
+	if len(trusted) == 0 || len(signed) <= len(trusted)/2 {
+		return nil, fmt.Errorf("%v: have %d, need %d", ErrCheckpointSigners, len(signed), len(trusted)/2+1)
+	}
+	return checkpoint, nil
+}
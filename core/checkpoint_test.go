@@ -0,0 +1,168 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that signed checkpoint files are only accepted with signatures from a
+// majority of the trusted signers, and with a header matching the checkpoint.
+func TestCheckpointFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		keys    = make([]*ecdsa.PrivateKey, 3)
+		signers = make([]common.Address, 3)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		signers[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	header := &types.Header{Number: big.NewInt(900), Difficulty: big.NewInt(1), Extra: []byte("checkpoint")}
+	checkpoint := &Checkpoint{Number: 900, Hash: header.Hash(), Header: header}
+
+	sign := func(keys ...*ecdsa.PrivateKey) [][]byte {
+		var sigs [][]byte
+		for _, key := range keys {
+			sig, err := SignCheckpoint(checkpoint, key)
+			if err != nil {
+				t.Fatalf("failed to sign checkpoint: %v", err)
+			}
+			sigs = append(sigs, sig)
+		}
+		return sigs
+	}
+	outsider, _ := crypto.GenerateKey()
+
+	tests := []struct {
+		checkpoint *Checkpoint
+		sigs       [][]byte
+		ok         bool
+	}{
+		{checkpoint, sign(keys[0], keys[1]), true},
+		{checkpoint, sign(keys...), true},
+		{checkpoint, sign(keys[0]), false},
+		{checkpoint, sign(keys[0], keys[0]), false},
+		{checkpoint, sign(keys[0], outsider), false},
+		{&Checkpoint{Number: 901, Hash: header.Hash(), Header: header}, sign(keys...), false},
+	}
+	for i, tt := range tests {
+		file := filepath.Join(dir, "checkpoint.json")
+		if err := WriteCheckpointFile(file, tt.checkpoint, tt.sigs); err != nil {
+			t.Fatalf("test %d: failed to write checkpoint: %v", i, err)
+		}
+		loaded, err := LoadCheckpointFile(file, signers)
+		if tt.ok != (err == nil) {
+			t.Fatalf("test %d: load error mismatch: have %v, want ok %v", i, err, tt.ok)
+		}
+		if err != nil {
+			continue
+		}
+		if loaded.Number != checkpoint.Number || loaded.Hash != checkpoint.Hash || loaded.Header.Hash() != header.Hash() {
+			t.Errorf("test %d: checkpoint mismatch: have %d %x", i, loaded.Number, loaded.Hash)
+		}
+	}
+}
+
+// Tests that a chain seeded from a checkpoint is backfilled down to the genesis,
+// correcting the estimated total difficulties once linked.
+func TestCheckpointBackfill(t *testing.T) {
+	var (
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		fulldb  = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(fulldb)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), fulldb, 64, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{byte(i)})
+	})
+	full, _ := NewBlockChain(fulldb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer full.Stop()
+	if _, err := full.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	seeddb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(seeddb)
+	seeded, _ := NewBlockChain(seeddb, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer seeded.Stop()
+
+	// Seed the chain with block #40, importing a few headers on top
+	checkpoint := blocks[39]
+	if err := seeded.InsertCheckpoint(checkpoint, big.NewInt(41)); err != nil {
+		t.Fatalf("failed to insert checkpoint: %v", err)
+	}
+	if err := seeded.InsertCheckpoint(checkpoint, big.NewInt(41)); err == nil {
+		t.Fatalf("checkpoint inserted into non-empty chain")
+	}
+	if head := seeded.CurrentFastBlock(); head.Hash() != checkpoint.Hash() {
+		t.Fatalf("fast head mismatch: have #%d, want #%d", head.NumberU64(), checkpoint.NumberU64())
+	}
+	headers := make([]*types.Header, 0, 24)
+	for _, block := range blocks[40:] {
+		headers = append(headers, block.Header())
+	}
+	if _, err := seeded.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to import headers above checkpoint: %v", err)
+	}
+	// Backfill out of order and unlinked batches must be rejected
+	if err := seeded.InsertBackfillChain(blocks[10:20]); err == nil {
+		t.Fatalf("unlinked backfill accepted")
+	}
+	if err := seeded.InsertBackfillChain(blocks[20:39]); err != nil {
+		t.Fatalf("failed to backfill: %v", err)
+	}
+	if tail := seeded.SyncCheckpoint().Tail; tail != 21 {
+		t.Fatalf("backfill tail mismatch: have %d, want 21", tail)
+	}
+	if err := seeded.InsertBackfillChain(blocks[0:20]); err != nil {
+		t.Fatalf("failed to backfill: %v", err)
+	}
+	if tail := seeded.SyncCheckpoint().Tail; tail != 0 {
+		t.Fatalf("backfill not complete: tail %d", tail)
+	}
+	// Every block must be available with the total difficulty of the full chain
+	for _, block := range blocks {
+		number := block.NumberU64()
+		if have := seeded.GetHeaderByNumber(number); have == nil || have.Hash() != block.Hash() {
+			t.Fatalf("block #%d: canonical header mismatch", number)
+		}
+		if number <= checkpoint.NumberU64() && seeded.GetBlockByNumber(number) == nil {
+			t.Fatalf("block #%d: body missing", number)
+		}
+		have, want := seeded.GetTd(block.Hash(), number), full.GetTd(block.Hash(), number)
+		if have == nil || have.Cmp(want) != 0 {
+			t.Fatalf("block #%d: td mismatch: have %v, want %v", number, have, want)
+		}
+	}
+}
@@ -17,6 +17,8 @@
 package core
 
 import (
+	"math/big"
+
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/types"
 )
@@ -30,6 +32,41 @@ type OrderTxPreEvent struct{ Tx *types.OrderTransaction }
 // LendingTxPreEvent is posted when a order transaction enters the order transaction pool.
 type LendingTxPreEvent struct{ Tx *types.LendingTransaction }
 
+// TxDropReason describes why a transaction left a pool without being included
+// in a block.
+type TxDropReason string
+
+const (
+	TxDropReplaced     TxDropReason = "replaced"      // Superseded by a transaction with the same nonce
+	TxDropExpired      TxDropReason = "expired"       // Stayed in the pool longer than allowed
+	TxDropAccountLimit TxDropReason = "account-limit" // Exceeded the per-account queue allowance
+	TxDropPoolOverflow TxDropReason = "pool-overflow" // Evicted to bring the pool under its global limits
+)
+
+// OrderTxDroppedEvent is posted when an order transaction is removed from the
+// order pool without being included in a block.
+type OrderTxDroppedEvent struct {
+	Tx     *types.OrderTransaction
+	Reason TxDropReason
+}
+
+// LendingTxDroppedEvent is posted when a lending transaction is removed from
+// the lending pool without being included in a block.
+type LendingTxDroppedEvent struct {
+	Tx     *types.LendingTransaction
+	Reason TxDropReason
+}
+
+// RelayerDepositEvent is posted when the deposit of a relayer falls below what
+// the order or the lending pool requires to accept its new orders, or recovers.
+type RelayerDepositEvent struct {
+	Relayer  common.Address
+	Lending  bool // Posted by the lending pool
+	Deposit  *big.Int
+	Required *big.Int
+	Low      bool
+}
+
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
 	Logs []*types.Log
@@ -50,6 +50,7 @@ var (
 	ErrInvalidCancelledLending   = errors.New("invalid cancel lending id")
 	ErrInvalidLendingTradeID     = errors.New("invalid lending trade ID")
 	ErrInvalidLendingCollateral  = errors.New("invalid collateral")
+	ErrInvalidLendingSelfTrade   = errors.New("invalid lending self-trade prevention mode")
 )
 
 var (
@@ -68,7 +69,13 @@ type LendingPoolConfig struct {
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
+	RelayerSlots uint64 // Maximum number of transactions (pending and queued) per relayer, 0 for no limit
+	UserSlots    uint64 // Maximum number of transactions (pending and queued) per user account, 0 for no limit
+
+	RelayerMinDeposit *big.Int // Deposit under which new orders of a relayer are rejected, nil to only require the fees of its next matching
+
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+	MaxAge   time.Duration // Maximum amount of time any transaction, local ones included, stays pooled
 }
 
 // blockChain_tomox add order state
@@ -98,7 +105,11 @@ var DefaultLendingPoolConfig = LendingPoolConfig{
 	AccountQueue: 64,
 	GlobalQueue:  1024,
 
+	RelayerSlots: 2048,
+	UserSlots:    128,
+
 	Lifetime: 3 * time.Hour,
+	MaxAge:   3 * time.Hour,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -125,6 +136,7 @@ type LendingPool struct {
 	chain       blockChainLending
 
 	txFeed       event.Feed
+	dropFeed     event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -142,6 +154,9 @@ type LendingPool struct {
 	queue     map[common.Address]*lendingtxList         // Queued but non-processable transactions
 	beats     map[common.Address]time.Time              // Last heartbeat from each known account
 	all       map[common.Hash]*types.LendingTransaction // All transactions to allow lookups
+	arrivals  map[common.Hash]time.Time                 // Time each pooled transaction was first accepted
+	deposits  *relayerDeposits                          // Relayers whose deposit is too low for their new orders
+	relayers  map[common.Address]uint64                 // Number of pooled transactions per relayer
 	wg        sync.WaitGroup                            // for shutdown sync
 	homestead bool
 	IsSigner  func(address common.Address) bool
@@ -149,20 +164,23 @@ type LendingPool struct {
 
 // NewLendingPool creates a new transaction pool to gather, sort and filter inbound
 // transactions from the network.
-func NewLendingPool(chainconfig *params.ChainConfig, chain blockChainLending) *LendingPool {
+func NewLendingPool(config LendingPoolConfig, chainconfig *params.ChainConfig, chain blockChainLending) *LendingPool {
 	// Sanitize the input to ensure no vulnerable gas prices are set
-	config := (&DefaultLendingPoolConfig).sanitize()
+	config = (&config).sanitize()
 	log.Debug("NewLendingPool start...", "current block", chain.CurrentBlock().Header().Number)
 	// Create the transaction pool with its initial settings
 	pool := &LendingPool{
 		config:      config,
 		chainconfig: chainconfig,
 		chain:       chain,
-		signer:      types.LendingTxSigner{},
+		signer:      types.MakeLendingSigner(chainconfig, new(big.Int).Add(chain.CurrentBlock().Number(), common.Big1)),
 		pending:     make(map[common.Address]*lendingtxList),
 		queue:       make(map[common.Address]*lendingtxList),
 		beats:       make(map[common.Address]time.Time),
 		all:         make(map[common.Hash]*types.LendingTransaction),
+		arrivals:    make(map[common.Hash]time.Time),
+		relayers:    make(map[common.Address]uint64),
+		deposits:    newRelayerDeposits(config.RelayerMinDeposit, common.RelayerLendingFee, true),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 	}
 	pool.locals = newLendingAccountSet(pool.signer)
@@ -252,9 +270,17 @@ func (pool *LendingPool) loop() {
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					for _, tx := range pool.queue[addr].Flatten() {
 						pool.removeTx(tx.Hash())
+						pool.announceDrop(tx, TxDropExpired)
 					}
 				}
 			}
+			// Drop anything older than the maximum age and make sure the journal
+			// doesn't resurrect the evicted transactions on the next restart
+			if pool.evictExpired(time.Now()) > 0 && pool.journal != nil {
+				if err := pool.journal.rotate(pool.local()); err != nil {
+					log.Warn("Failed to rotate local tx journal", "err", err)
+				}
+			}
 			pool.mu.Unlock()
 
 			// Handle local transaction journal rotation
@@ -284,6 +310,11 @@ func (pool *LendingPool) reset(oldHead, newblock *types.Block) {
 		newblock = pool.chain.CurrentBlock()
 	}
 	newHead := newblock.Header()
+
+	// Pending transactions are signed the way the next block accepts them
+	pool.signer = types.MakeLendingSigner(pool.chainconfig, new(big.Int).Add(newHead.Number, common.Big1))
+	pool.locals.signer = pool.signer
+
 	lendingState, err := pool.chain.LendingStateAt(newblock)
 	if err != nil {
 		log.Error("Failed to reset LendingPool state", "err", err)
@@ -298,6 +329,7 @@ func (pool *LendingPool) reset(oldHead, newblock *types.Block) {
 		return
 	}
 	pool.currentRootState = state
+	pool.deposits.recheck(state, pool.relayers)
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -327,6 +359,7 @@ func (pool *LendingPool) Stop() {
 	// Unsubscribe subscriptions registered from blockchain
 	pool.chainHeadSub.Unsubscribe()
 	pool.wg.Wait()
+	pool.deposits.stop()
 
 	if pool.journal != nil {
 		pool.journal.close()
@@ -340,6 +373,27 @@ func (pool *LendingPool) SubscribeTxPreEvent(ch chan<- LendingTxPreEvent) event.
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeDroppedTxEvent registers a subscription of LendingTxDroppedEvent and
+// starts sending event to the given channel.
+func (pool *LendingPool) SubscribeDroppedTxEvent(ch chan<- LendingTxDroppedEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
+// SubscribeRelayerDepositEvent registers a subscription of RelayerDepositEvent
+// and starts sending event to the given channel.
+func (pool *LendingPool) SubscribeRelayerDepositEvent(ch chan<- RelayerDepositEvent) event.Subscription {
+	return pool.scope.Track(pool.deposits.feed.Subscribe(ch))
+}
+
+// LowDepositRelayers returns the relayers whose deposit is too low for the pool
+// to accept their new orders.
+func (pool *LendingPool) LowDepositRelayers() []RelayerDepositEvent {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.deposits.events()
+}
+
 // State returns the virtual managed state of the transaction pool.
 func (pool *LendingPool) State() *lendingstate.LendingManagedState {
 	pool.mu.RLock()
@@ -430,6 +484,11 @@ func (pool *LendingPool) validateNewLending(cloneStateDb *state.StateDB, cloneLe
 	if lendingType != LendingTypeLimit && lendingType != LendingTypeMarket {
 		return ErrInvalidLendingType
 	}
+	if mode := tx.SelfTradePrevention(); mode != "" {
+		if !pool.chain.Config().IsTIPTomoXSelfTradePrevention(pool.chain.CurrentHeader().Number) || !types.ValidSelfTradePrevention[mode] {
+			return ErrInvalidLendingSelfTrade
+		}
+	}
 	if tx.Side() == lendingstate.Borrowing {
 		if tx.CollateralToken().String() == lendingstate.EmptyAddress || tx.CollateralToken().String() == tx.LendingToken().String() {
 			return ErrInvalidLendingCollateral
@@ -459,7 +518,7 @@ func (pool *LendingPool) validateCancelledLending(cloneLendingStateDb *lendingst
 		return ErrInvalidCancelledLending
 	}
 	item := cloneLendingStateDb.GetLendingOrder(lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term()), common.Uint64ToHash(tx.LendingId()))
-	if item == lendingstate.EmptyLendingOrder {
+	if item.Hash == (common.Hash{}) {
 		log.Debug("LendingOrder not found ", "LendingId", tx.LendingId(), "LendToken", tx.LendingToken().Hex(), "CollateralToken", tx.CollateralToken().Hex(), "Term", tx.Term())
 		return ErrInvalidCancelledLending
 	}
@@ -566,6 +625,11 @@ func (pool *LendingPool) validateBalance(cloneStateDb *state.StateDB, cloneLendi
 		}
 	}
 	isTomoXLendingFork := pool.chain.Config().IsTIPTomoXLending(pool.chain.CurrentHeader().Number)
+	quantity := tx.Quantity()
+	if tx.Type() == lendingstate.Repay && !pool.chain.Config().IsTIPTomoXLendingPartialRepay(pool.chain.CurrentHeader().Number) {
+		// repay quantity is ignored before partial repayment is enabled
+		quantity = nil
+	}
 	if err := lendingstate.VerifyBalance(isTomoXLendingFork,
 		cloneStateDb,
 		cloneLendingStateDb,
@@ -576,7 +640,7 @@ func (pool *LendingPool) validateBalance(cloneStateDb *state.StateDB, cloneLendi
 		tx.RelayerAddress(),
 		tx.LendingToken(),
 		tx.CollateralToken(),
-		tx.Quantity(),
+		quantity,
 		lendingTokenDecimal,
 		collateralTokenDecimal,
 		lendTokenTOMOPrice,
@@ -590,6 +654,131 @@ func (pool *LendingPool) validateBalance(cloneStateDb *state.StateDB, cloneLendi
 	return nil
 }
 
+// validatePoolLending validates an item of the variable-rate pool of the lending token
+func (pool *LendingPool) validatePoolLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chain.Config().IsTIPTomoXLendingPool(pool.chain.CurrentHeader().Number) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != types.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 {
+		return ErrInvalidLendingQuantity
+	}
+	if !lendingstate.GetLendingPoolConfig(cloneStateDb, tx.LendingToken()).Enabled() {
+		return lendingstate.ErrPoolNotEnabled
+	}
+	collateralToken := common.HexToAddress(lendingstate.EmptyAddress)
+	if tx.Type() == types.LendingPoolBorrow {
+		if err := lendingstate.VerifyPoolCollateral(cloneStateDb, tx.LendingToken(), tx.CollateralToken()); err != nil {
+			return ErrInvalidLendingCollateral
+		}
+		collateralToken = tx.CollateralToken()
+	}
+	return pool.validateBalance(cloneStateDb, cloneLendingStateDb, tx, collateralToken)
+}
+
+// validateMarginLending validates a deposit into or a withdrawal from the margin
+// account of the user
+func (pool *LendingPool) validateMarginLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chain.Config().IsTIPTomoXLendingMargin(pool.chain.CurrentHeader().Number) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != types.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 {
+		return ErrInvalidLendingQuantity
+	}
+	if err := lendingstate.VerifyMarginCollateral(cloneStateDb, tx.CollateralToken()); err != nil {
+		return ErrInvalidLendingCollateral
+	}
+	isTomoXLendingFork := pool.chain.Config().IsTIPTomoXLending(pool.chain.CurrentHeader().Number)
+	return lendingstate.VerifyBalance(isTomoXLendingFork, cloneStateDb, cloneLendingStateDb, tx.Type(), tx.Side(), tx.Status(),
+		tx.UserAddress(), tx.RelayerAddress(), tx.LendingToken(), tx.CollateralToken(), tx.Quantity(),
+		nil, nil, nil, nil, tx.Term(), tx.LendingId(), tx.LendingTradeId())
+}
+
+// validateRolloverLending validates a rollover proposed by the borrower of a
+// trade or answered by its investor
+func (pool *LendingPool) validateRolloverLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chain.Config().IsTIPTomoXLendingRollover(pool.chain.CurrentHeader().Number) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != types.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	if tx.LendingTradeId() == 0 {
+		return ErrInvalidLendingTradeID
+	}
+	data, err := lendingstate.DecodeRolloverData(tx.ExtraData())
+	if err != nil {
+		return err
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term())
+	lendingTrade := cloneLendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(tx.LendingTradeId()))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return ErrInvalidLendingTradeID
+	}
+	switch tx.Side() {
+	case types.LendingSideBorrow:
+		if data.Decline {
+			return ErrInvalidLendingSide
+		}
+		if tx.UserAddress() != lendingTrade.Borrower {
+			return ErrInvalidLendingUserAddress
+		}
+		if tx.RelayerAddress() != lendingTrade.BorrowingRelayer {
+			return ErrInvalidLendingRelayer
+		}
+	case types.LendingSideInvest:
+		if tx.UserAddress() != lendingTrade.Investor {
+			return ErrInvalidLendingUserAddress
+		}
+		if tx.RelayerAddress() != lendingTrade.InvestingRelayer {
+			return ErrInvalidLendingRelayer
+		}
+		proposal := cloneLendingStateDb.GetRolloverProposal(lendingBook, tx.LendingTradeId())
+		if proposal == nil {
+			return lendingstate.ErrRolloverNotProposed
+		}
+		if !data.Decline && (data.Term != proposal.Term || new(big.Int).SetUint64(tx.Interest()).Cmp(proposal.Interest) != 0) {
+			return lendingstate.ErrRolloverMismatch
+		}
+	default:
+		return ErrInvalidLendingSide
+	}
+	if data.Decline {
+		return nil
+	}
+	if tx.Interest() == 0 {
+		return ErrInvalidLendingInterest
+	}
+	if valid, _ := lendingstate.IsValidPair(cloneStateDb, tx.RelayerAddress(), tx.LendingToken(), data.Term); !valid {
+		return fmt.Errorf("invalid rollover pair. Relayer: %s. LendingToken: %s. Term: %d", tx.RelayerAddress().Hex(), tx.LendingToken().Hex(), data.Term)
+	}
+	return nil
+}
+
+// validateAuctionBidLending validates a bid in the liquidation auction of a trade
+func (pool *LendingPool) validateAuctionBidLending(cloneStateDb *state.StateDB, cloneLendingStateDb *lendingstate.LendingStateDB, tx *types.LendingTransaction) error {
+	if !pool.chain.Config().IsTIPTomoXLendingAuction(pool.chain.CurrentHeader().Number) {
+		return ErrInvalidLendingType
+	}
+	if tx.Status() != types.LendingStatusNew {
+		return ErrInvalidLendingStatus
+	}
+	if tx.Quantity() == nil || tx.Quantity().Sign() <= 0 {
+		return ErrInvalidLendingQuantity
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(tx.LendingToken(), tx.Term())
+	auction := cloneLendingStateDb.GetLendingAuction(lendingBook, tx.LendingTradeId())
+	if auction == nil {
+		return ErrInvalidLendingTradeID
+	}
+	return pool.validateBalance(cloneStateDb, cloneLendingStateDb, tx, auction.Trade.CollateralToken)
+}
+
 func (pool *LendingPool) validateLending(tx *types.LendingTransaction) error {
 	cloneStateDb := pool.currentRootState.Copy()
 	cloneLendingStateDb := pool.currentLendingState.Copy()
@@ -600,10 +789,25 @@ func (pool *LendingPool) validateLending(tx *types.LendingTransaction) error {
 	if !lendingstate.IsValidRelayer(cloneStateDb, tx.RelayerAddress()) {
 		return fmt.Errorf("invalid lending relayer. ExchangeAddress: %s", tx.RelayerAddress().Hex())
 	}
+	if tx.IsPoolLending() {
+		return pool.validatePoolLending(cloneStateDb, cloneLendingStateDb, tx)
+	}
+	if tx.IsMarginLending() {
+		return pool.validateMarginLending(cloneStateDb, cloneLendingStateDb, tx)
+	}
 	if valid, _ := lendingstate.IsValidPair(cloneStateDb, tx.RelayerAddress(), tx.LendingToken(), tx.Term()); valid == false {
 		return fmt.Errorf("invalid pair. Relayer: %s. LendingToken: %s. Term: %d", tx.RelayerAddress().Hex(), tx.LendingToken().Hex(), tx.Term())
 	}
+	if tx.IsRolloverLending() {
+		return pool.validateRolloverLending(cloneStateDb, cloneLendingStateDb, tx)
+	}
+	if tx.IsAuctionBidLending() {
+		return pool.validateAuctionBidLending(cloneStateDb, cloneLendingStateDb, tx)
+	}
 	if tx.IsCreatedLending() {
+		if err := pool.deposits.check(cloneStateDb, tx.RelayerAddress()); err != nil {
+			return err
+		}
 		return pool.validateNewLending(cloneStateDb, cloneLendingStateDb, tx)
 	}
 	if tx.IsCancelledLending() {
@@ -623,10 +827,6 @@ func (pool *LendingPool) validateLending(tx *types.LendingTransaction) error {
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *LendingPool) validateTx(tx *types.LendingTransaction, local bool) error {
 
-	// check if sender is in black list
-	if tx.From() != nil && common.Blacklist[*tx.From()] {
-		return fmt.Errorf("Reject transaction with sender in black-list: %v", tx.From().Hex())
-	}
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > 32*1024 {
 		return ErrOversizedData
@@ -637,6 +837,10 @@ func (pool *LendingPool) validateTx(tx *types.LendingTransaction, local bool) er
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// check if sender is in black list
+	if common.Blacklist[from] {
+		return fmt.Errorf("Reject transaction with sender in black-list: %v", from.Hex())
+	}
 	err = pool.validateLending(tx)
 	if err != nil {
 		return err
@@ -676,10 +880,16 @@ func (pool *LendingPool) add(tx *types.LendingTransaction, local bool) (bool, er
 	}
 	from, _ := types.LendingSender(pool.signer, tx) // already validated
 
-	// If the transaction pool is full, discard underpriced transactions
-	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
-		log.Debug("Add lending transaction to pool full", "hash", hash, "nonce", tx.Nonce())
-		return false, ErrPoolOverflow
+	// A transaction reusing a pooled nonce replaces the old one in its slot, so
+	// it is exempt from the quotas but must stay with the same relayer
+	if old := pool.overlapping(from, tx.Nonce()); old != nil {
+		if old.RelayerAddress() != tx.RelayerAddress() {
+			log.Debug("Discarding cross-relayer lending replacement", "hash", hash, "nonce", tx.Nonce(), "old", old.RelayerAddress().Hex(), "new", tx.RelayerAddress().Hex())
+			return false, ErrReplaceRelayerMismatch
+		}
+	} else if err := pool.checkQuotas(from, tx); err != nil {
+		log.Debug("Discarding lending transaction over quota", "hash", hash, "nonce", tx.Nonce(), "err", err)
+		return false, err
 	}
 	// If the transaction is replacing an already pending one, do directly
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
@@ -689,10 +899,10 @@ func (pool *LendingPool) add(tx *types.LendingTransaction, local bool) (bool, er
 			return false, ErrPendingNonceTooLow
 		}
 		if old != nil {
-			delete(pool.all, old.Hash())
+			pool.dropTx(old, TxDropReplaced)
 			pendingReplaceCounter.Inc(1)
 		}
-		pool.all[tx.Hash()] = tx
+		pool.track(tx)
 		pool.journalTx(from, tx)
 
 		log.Debug("Lending Pooled new executable transaction", "hash", hash, "useraddress", tx.UserAddress(), "nonce", tx.Nonce(), "status", tx.Status(), "lendingid", tx.LendingId())
@@ -731,13 +941,118 @@ func (pool *LendingPool) enqueueTx(hash common.Hash, tx *types.LendingTransactio
 	}
 	// Discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.dropTx(old, TxDropReplaced)
 		queuedReplaceCounter.Inc(1)
 	}
-	pool.all[hash] = tx
+	pool.track(tx)
 	return old != nil, nil
 }
 
+// overlapping returns the pooled transaction of the account with the given
+// nonce, either pending or queued, or nil if the nonce is free.
+func (pool *LendingPool) overlapping(from common.Address, nonce uint64) *types.LendingTransaction {
+	if list := pool.pending[from]; list != nil {
+		if tx := list.txs.Get(nonce); tx != nil {
+			return tx
+		}
+	}
+	if list := pool.queue[from]; list != nil {
+		return list.txs.Get(nonce)
+	}
+	return nil
+}
+
+// checkQuotas verifies that a new, non-replacing transaction fits into the
+// global pool size as well as into the share of its user and its relayer.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LendingPool) checkQuotas(from common.Address, tx *types.LendingTransaction) error {
+	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
+		return ErrPoolOverflow
+	}
+	if limit := pool.config.UserSlots; limit > 0 {
+		count := 0
+		if list := pool.pending[from]; list != nil {
+			count += list.Len()
+		}
+		if list := pool.queue[from]; list != nil {
+			count += list.Len()
+		}
+		if uint64(count) >= limit {
+			return ErrUserQuotaExceeded
+		}
+	}
+	if limit := pool.config.RelayerSlots; limit > 0 && pool.relayers[tx.RelayerAddress()] >= limit {
+		return ErrRelayerQuotaExceeded
+	}
+	return nil
+}
+
+// track inserts a transaction into the global lookup, recording its arrival
+// time and accounting it to its relayer. Already tracked ones are left as is.
+func (pool *LendingPool) track(tx *types.LendingTransaction) {
+	hash := tx.Hash()
+	if pool.all[hash] != nil {
+		return
+	}
+	pool.all[hash] = tx
+	pool.arrivals[hash] = time.Now()
+	pool.relayers[tx.RelayerAddress()]++
+}
+
+// untrack removes a transaction from the global lookup, reverting everything
+// done by track.
+func (pool *LendingPool) untrack(hash common.Hash) {
+	tx := pool.all[hash]
+	if tx == nil {
+		return
+	}
+	delete(pool.all, hash)
+	delete(pool.arrivals, hash)
+
+	if relayer := tx.RelayerAddress(); pool.relayers[relayer] > 1 {
+		pool.relayers[relayer]--
+	} else {
+		delete(pool.relayers, relayer)
+	}
+}
+
+// dropTx untracks a transaction already removed from the pending or queued
+// lists and announces it as dropped.
+func (pool *LendingPool) dropTx(tx *types.LendingTransaction, reason TxDropReason) {
+	pool.untrack(tx.Hash())
+	pool.announceDrop(tx, reason)
+}
+
+// announceDrop notifies the subscribers that a transaction left the pool for
+// the given reason without being included in a block.
+func (pool *LendingPool) announceDrop(tx *types.LendingTransaction, reason TxDropReason) {
+	log.Debug("Dropped lending transaction", "hash", tx.Hash(), "addr", tx.UserAddress().Hex(), "nonce", tx.Nonce(), "relayer", tx.RelayerAddress().Hex(), "reason", reason)
+	go pool.dropFeed.Send(LendingTxDroppedEvent{Tx: tx, Reason: reason})
+}
+
+// evictExpired removes every transaction, local or not, which has been pooled
+// for longer than the configured maximum age and returns the number dropped.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *LendingPool) evictExpired(now time.Time) int {
+	if pool.config.MaxAge == 0 {
+		return 0
+	}
+	var expired types.LendingTransactions
+	for hash, arrived := range pool.arrivals {
+		if now.Sub(arrived) > pool.config.MaxAge {
+			expired = append(expired, pool.all[hash])
+		}
+	}
+	sort.Sort(types.LendingTxByNonce(expired))
+	for _, tx := range expired {
+		pool.removeTx(tx.Hash())
+		pool.announceDrop(tx, TxDropExpired)
+	}
+	return len(expired)
+}
+
 // journalTx adds the specified transaction to the local disk journal if it is
 // deemed to have been sent from a local account.
 func (pool *LendingPool) journalTx(from common.Address, tx *types.LendingTransaction) {
@@ -763,19 +1078,17 @@ func (pool *LendingPool) promoteTx(addr common.Address, hash common.Hash, tx *ty
 	inserted, old := list.Add(tx)
 	if !inserted {
 		// An older transaction was better, discard this
-		delete(pool.all, hash)
+		pool.untrack(hash)
 		pendingDiscardCounter.Inc(1)
 		return
 	}
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.dropTx(old, TxDropReplaced)
 		pendingReplaceCounter.Inc(1)
 	}
 	// Failsafe to work around direct pending inserts (tests)
-	if pool.all[hash] == nil {
-		pool.all[hash] = tx
-	}
+	pool.track(tx)
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr.Hash(), tx.Nonce()+1)
@@ -910,7 +1223,7 @@ func (pool *LendingPool) removeTx(hash common.Hash) {
 	addr, _ := types.LendingSender(pool.signer, tx) // already validated during insertion
 
 	// Remove it from the list of known transactions
-	delete(pool.all, hash)
+	pool.untrack(hash)
 
 	// Remove the transaction from the pending lists and reset the account nonce
 	if pending := pool.pending[addr]; pending != nil {
@@ -964,7 +1277,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 		for _, tx := range list.Forward(pool.currentLendingState.GetNonce(addr.Hash())) {
 			hash := tx.Hash()
 			log.Trace("Removed old queued transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.untrack(hash)
 
 		}
 
@@ -978,7 +1291,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 		if !pool.locals.contains(addr) {
 			for _, tx := range list.Cap(int(pool.config.AccountQueue)) {
 				hash := tx.Hash()
-				delete(pool.all, hash)
+				pool.dropTx(tx, TxDropAccountLimit)
 
 				queuedRateLimitCounter.Inc(1)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
@@ -1023,7 +1336,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 						for _, tx := range list.Cap(list.Len() - 1) {
 							// Drop the transaction from the global pools too
 							hash := tx.Hash()
-							delete(pool.all, hash)
+							pool.dropTx(tx, TxDropPoolOverflow)
 
 							// Update the account nonce to the dropped transaction
 							if nonce := tx.Nonce(); pool.pendingState.GetNonce(offenders[i].Hash()) > nonce {
@@ -1044,7 +1357,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 					for _, tx := range list.Cap(list.Len() - 1) {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
-						delete(pool.all, hash)
+						pool.dropTx(tx, TxDropPoolOverflow)
 
 						// Update the account nonce to the dropped transaction
 						if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr.Hash()) > nonce {
@@ -1084,6 +1397,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 			if size := uint64(list.Len()); size <= drop {
 				for _, tx := range list.Flatten() {
 					pool.removeTx(tx.Hash())
+					pool.announceDrop(tx, TxDropPoolOverflow)
 				}
 				drop -= size
 				queuedRateLimitCounter.Inc(int64(size))
@@ -1093,6 +1407,7 @@ func (pool *LendingPool) promoteExecutables(accounts []common.Address) {
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 				pool.removeTx(txs[i].Hash())
+				pool.announceDrop(txs[i], TxDropPoolOverflow)
 				drop--
 				queuedRateLimitCounter.Inc(1)
 			}
@@ -1112,7 +1427,7 @@ func (pool *LendingPool) demoteUnexecutables() {
 		for _, tx := range list.Forward(nonce) {
 			hash := tx.Hash()
 			log.Debug("Removed old pending transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.untrack(hash)
 		}
 
 		// If there's a gap in front, warn (should never happen) and postpone all transactions
@@ -285,3 +285,69 @@ func TestRecallLending(t *testing.T) {
 	testSendLending(key, nonce, USDAddress, common.HexToAddress(common.TomoNativeAddress), new(big.Int).Mul(_1E8, big.NewInt(1000)), interestRate, lendingstate.Borrowing, lendingstate.LendingStatusNew, true, 0, 0, common.Hash{}, "")
 	time.Sleep(2 * time.Second)
 }
+
+func testLendingTx(t *testing.T, seed byte, nonce uint64, relayer common.Address, quantity int64) *types.LendingTransaction {
+	key, _ := crypto.ToECDSA(common.LeftPadBytes([]byte{seed}, 32))
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	tx := types.NewLendingTransaction(nonce, big.NewInt(quantity), 100, 86400, relayer, user, USDAddress, BTCAddress, false, lendingstate.LendingStatusNew, lendingstate.Borrowing, lendingstate.Limit, common.Hash{}, 0, 0, "")
+	signed, err := types.LendingSignTx(tx, types.LendingTxSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign lending transaction: %v", err)
+	}
+	return signed
+}
+
+// Tests the lending pool mirror of the order pool replacement, quota and age
+// eviction policy.
+func TestLendingPoolReplacementAndEviction(t *testing.T) {
+	config := DefaultLendingPoolConfig
+	config.RelayerSlots = 2
+	pool := &LendingPool{
+		config:   config,
+		signer:   types.LendingTxSigner{},
+		locals:   newLendingAccountSet(types.LendingTxSigner{}),
+		pending:  make(map[common.Address]*lendingtxList),
+		queue:    make(map[common.Address]*lendingtxList),
+		beats:    make(map[common.Address]time.Time),
+		all:      make(map[common.Hash]*types.LendingTransaction),
+		arrivals: make(map[common.Hash]time.Time),
+		relayers: make(map[common.Address]uint64),
+	}
+	drops := make(chan LendingTxDroppedEvent, 2)
+	sub := pool.SubscribeDroppedTxEvent(drops)
+	defer sub.Unsubscribe()
+
+	relayer := common.HexToAddress("0x01")
+	first, second := testLendingTx(t, 1, 3, relayer, 1), testLendingTx(t, 2, 3, relayer, 1)
+	for _, tx := range []*types.LendingTransaction{first, second} {
+		if _, err := pool.enqueueTx(tx.Hash(), tx); err != nil {
+			t.Fatalf("failed to enqueue: %v", err)
+		}
+	}
+	if err := pool.checkQuotas(common.Address{}, testLendingTx(t, 3, 3, relayer, 1)); err != ErrRelayerQuotaExceeded {
+		t.Errorf("relayer quota mismatch: have %v, want %v", err, ErrRelayerQuotaExceeded)
+	}
+	replacement := testLendingTx(t, 1, 3, relayer, 2)
+	if replaced, err := pool.enqueueTx(replacement.Hash(), replacement); err != nil || !replaced {
+		t.Fatalf("replacement failed: replaced %v, err %v", replaced, err)
+	}
+	pool.arrivals[second.Hash()] = time.Now().Add(-2 * config.MaxAge)
+	if dropped := pool.evictExpired(time.Now()); dropped != 1 {
+		t.Fatalf("evicted count mismatch: have %d, want 1", dropped)
+	}
+	reasons := make(map[TxDropReason]common.Hash)
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-drops:
+			reasons[ev.Reason] = ev.Tx.Hash()
+		case <-time.After(time.Second):
+			t.Fatalf("missing drop event %d", i)
+		}
+	}
+	if reasons[TxDropReplaced] != first.Hash() || reasons[TxDropExpired] != second.Hash() {
+		t.Errorf("drop reasons mismatch: %v", reasons)
+	}
+	if len(pool.all) != 1 || pool.relayers[relayer] != 1 {
+		t.Errorf("bookkeeping mismatch: %d pooled, %d for relayer", len(pool.all), pool.relayers[relayer])
+	}
+}
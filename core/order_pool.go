@@ -49,6 +49,7 @@ var (
 	ErrInvalidOrderPrice       = errors.New("invalid order price")
 	ErrInvalidOrderHash        = errors.New("invalid order hash")
 	ErrInvalidCancelledOrder   = errors.New("invalid cancel orderid")
+	ErrInvalidOrderSelfTrade   = errors.New("invalid order self-trade prevention mode")
 )
 
 var (
@@ -63,6 +64,18 @@ var (
 var (
 	ErrPendingNonceTooLow = errors.New("pending nonce too low")
 	ErrPoolOverflow       = errors.New("Exceed pool size")
+
+	// ErrReplaceRelayerMismatch is returned if a transaction attempts to replace
+	// a pooled one with the same nonce but routes it through another relayer.
+	ErrReplaceRelayerMismatch = errors.New("replacement must use the same relayer")
+
+	// ErrRelayerQuotaExceeded is returned if a relayer already holds its maximum
+	// share of the pool.
+	ErrRelayerQuotaExceeded = errors.New("relayer pool quota exceeded")
+
+	// ErrUserQuotaExceeded is returned if an account already holds its maximum
+	// number of pooled transactions.
+	ErrUserQuotaExceeded = errors.New("user pool quota exceeded")
 )
 
 // OrderPoolConfig are the configuration parameters of the order transaction pool.
@@ -76,7 +89,13 @@ type OrderPoolConfig struct {
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
+	RelayerSlots uint64 // Maximum number of transactions (pending and queued) per relayer, 0 for no limit
+	UserSlots    uint64 // Maximum number of transactions (pending and queued) per user account, 0 for no limit
+
+	RelayerMinDeposit *big.Int // Deposit under which new orders of a relayer are rejected, nil to only require the fees of its next matching
+
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+	MaxAge   time.Duration // Maximum amount of time any transaction, local ones included, stays pooled
 }
 
 // blockChain_tomox add order state
@@ -106,7 +125,11 @@ var DefaultOrderPoolConfig = OrderPoolConfig{
 	AccountQueue: 64,
 	GlobalQueue:  1024,
 
+	RelayerSlots: 2048,
+	UserSlots:    128,
+
 	Lifetime: 3 * time.Hour,
+	MaxAge:   3 * time.Hour,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -133,6 +156,7 @@ type OrderPool struct {
 	chain       blockChainTomox
 
 	txFeed       event.Feed
+	dropFeed     event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -150,6 +174,9 @@ type OrderPool struct {
 	queue     map[common.Address]*ordertxList         // Queued but non-processable transactions
 	beats     map[common.Address]time.Time            // Last heartbeat from each known account
 	all       map[common.Hash]*types.OrderTransaction // All transactions to allow lookups
+	arrivals  map[common.Hash]time.Time               // Time each pooled transaction was first accepted
+	deposits  *relayerDeposits                        // Relayers whose deposit is too low for their new orders
+	relayers  map[common.Address]uint64               // Number of pooled transactions per relayer
 	wg        sync.WaitGroup                          // for shutdown sync
 	homestead bool
 	IsSigner  func(address common.Address) bool
@@ -157,20 +184,23 @@ type OrderPool struct {
 
 // NewOrderPool creates a new transaction pool to gather, sort and filter inbound
 // transactions from the network.
-func NewOrderPool(chainconfig *params.ChainConfig, chain blockChainTomox) *OrderPool {
+func NewOrderPool(config OrderPoolConfig, chainconfig *params.ChainConfig, chain blockChainTomox) *OrderPool {
 	// Sanitize the input to ensure no vulnerable gas prices are set
-	config := (&DefaultOrderPoolConfig).sanitize()
+	config = (&config).sanitize()
 	log.Debug("NewOrderPool start...", "current block", chain.CurrentBlock().Header().Number)
 	// Create the transaction pool with its initial settings
 	pool := &OrderPool{
 		config:      config,
 		chainconfig: chainconfig,
 		chain:       chain,
-		signer:      types.OrderTxSigner{},
+		signer:      types.MakeOrderSigner(chainconfig, new(big.Int).Add(chain.CurrentBlock().Number(), common.Big1)),
 		pending:     make(map[common.Address]*ordertxList),
 		queue:       make(map[common.Address]*ordertxList),
 		beats:       make(map[common.Address]time.Time),
 		all:         make(map[common.Hash]*types.OrderTransaction),
+		arrivals:    make(map[common.Hash]time.Time),
+		relayers:    make(map[common.Address]uint64),
+		deposits:    newRelayerDeposits(config.RelayerMinDeposit, common.RelayerFee, false),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 	}
 	pool.locals = newOrderAccountSet(pool.signer)
@@ -257,9 +287,17 @@ func (pool *OrderPool) loop() {
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					for _, tx := range pool.queue[addr].Flatten() {
 						pool.removeTx(tx.Hash())
+						pool.announceDrop(tx, TxDropExpired)
 					}
 				}
 			}
+			// Drop anything older than the maximum age and make sure the journal
+			// doesn't resurrect the evicted orders on the next restart
+			if pool.evictExpired(time.Now()) > 0 && pool.journal != nil {
+				if err := pool.journal.rotate(pool.local()); err != nil {
+					log.Warn("Failed to rotate local tx journal", "err", err)
+				}
+			}
 			pool.mu.Unlock()
 
 			// Handle local transaction journal rotation
@@ -289,6 +327,11 @@ func (pool *OrderPool) reset(oldHead, newblock *types.Block) {
 		newblock = pool.chain.CurrentBlock()
 	}
 	newHead := newblock.Header()
+
+	// Pending transactions are signed the way the next block accepts them
+	pool.signer = types.MakeOrderSigner(pool.chainconfig, new(big.Int).Add(newHead.Number, common.Big1))
+	pool.locals.signer = pool.signer
+
 	orderstate, err := pool.chain.OrderStateAt(newblock)
 	if err != nil {
 		log.Error("Failed to reset OrderPool state", "err", err)
@@ -303,6 +346,7 @@ func (pool *OrderPool) reset(oldHead, newblock *types.Block) {
 		return
 	}
 	pool.currentRootState = state
+	pool.deposits.recheck(state, pool.relayers)
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -332,6 +376,7 @@ func (pool *OrderPool) Stop() {
 	// Unsubscribe subscriptions registered from blockchain
 	pool.chainHeadSub.Unsubscribe()
 	pool.wg.Wait()
+	pool.deposits.stop()
 
 	if pool.journal != nil {
 		pool.journal.close()
@@ -345,6 +390,27 @@ func (pool *OrderPool) SubscribeTxPreEvent(ch chan<- OrderTxPreEvent) event.Subs
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeDroppedTxEvent registers a subscription of OrderTxDroppedEvent and
+// starts sending event to the given channel.
+func (pool *OrderPool) SubscribeDroppedTxEvent(ch chan<- OrderTxDroppedEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
+// SubscribeRelayerDepositEvent registers a subscription of RelayerDepositEvent
+// and starts sending event to the given channel.
+func (pool *OrderPool) SubscribeRelayerDepositEvent(ch chan<- RelayerDepositEvent) event.Subscription {
+	return pool.scope.Track(pool.deposits.feed.Subscribe(ch))
+}
+
+// LowDepositRelayers returns the relayers whose deposit is too low for the pool
+// to accept their new orders.
+func (pool *OrderPool) LowDepositRelayers() []RelayerDepositEvent {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.deposits.events()
+}
+
 // State returns the virtual managed state of the transaction pool.
 func (pool *OrderPool) State() *tradingstate.TomoXManagedState {
 	pool.mu.RLock()
@@ -458,6 +524,11 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 		if orderType != OrderTypeLimit && orderType != OrderTypeMarket {
 			return ErrInvalidOrderType
 		}
+		if mode := tx.SelfTradePrevention(); mode != "" {
+			if !pool.chainconfig.IsTIPTomoXSelfTradePrevention(pool.chain.CurrentBlock().Number()) || !types.ValidSelfTradePrevention[mode] {
+				return ErrInvalidOrderSelfTrade
+			}
+		}
 		if err := tradingstate.VerifyPair(cloneStateDb, tx.ExchangeAddress(), tx.BaseToken(), tx.QuoteToken()); err != nil {
 			return err
 		}
@@ -489,7 +560,7 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 	if orderStatus != OrderStatusNew && orderStatus != OrderStatusCancle {
 		return ErrInvalidOrderStatus
 	}
-	var signer = types.OrderTxSigner{}
+	signer := pool.signer
 
 	if !tx.IsCancelledOrder() {
 		if !common.EmptyHash(tx.OrderHash()) {
@@ -505,7 +576,7 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 			return ErrInvalidCancelledOrder
 		}
 		originOrder := cloneTomoXStateDb.GetOrder(tradingstate.GetTradingOrderBookHash(tx.BaseToken(), tx.QuoteToken()), common.BigToHash(new(big.Int).SetUint64(tx.OrderID())))
-		if originOrder == tradingstate.EmptyOrder {
+		if originOrder.Hash == (common.Hash{}) {
 			log.Debug("Order not found ", "OrderId", tx.OrderID(), "BaseToken", tx.BaseToken().Hex(), "QuoteToken", tx.QuoteToken().Hex())
 			return ErrInvalidCancelledOrder
 		}
@@ -523,6 +594,11 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 	if !tradingstate.IsValidRelayer(cloneStateDb, tx.ExchangeAddress()) {
 		return fmt.Errorf("invalid relayer. ExchangeAddress: %s", tx.ExchangeAddress().Hex())
 	}
+	if !tx.IsCancelledOrder() {
+		if err := pool.deposits.check(cloneStateDb, tx.ExchangeAddress()); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -530,11 +606,6 @@ func (pool *OrderPool) validateOrder(tx *types.OrderTransaction) error {
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *OrderPool) validateTx(tx *types.OrderTransaction, local bool) error {
-
-	// check if sender is in black list
-	if tx.From() != nil && common.Blacklist[*tx.From()] {
-		return fmt.Errorf("Reject transaction with sender in black-list: %v", tx.From().Hex())
-	}
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > 32*1024 {
 		return ErrOversizedData
@@ -545,6 +616,10 @@ func (pool *OrderPool) validateTx(tx *types.OrderTransaction, local bool) error
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// check if sender is in black list
+	if common.Blacklist[from] {
+		return fmt.Errorf("Reject transaction with sender in black-list: %v", from.Hex())
+	}
 	err = pool.validateOrder(tx)
 	if err != nil {
 		return err
@@ -584,10 +659,16 @@ func (pool *OrderPool) add(tx *types.OrderTransaction, local bool) (bool, error)
 	}
 	from, _ := types.OrderSender(pool.signer, tx) // already validated
 
-	// If the transaction pool is full, discard underpriced transactions
-	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
-		log.Debug("Add order transaction to pool full", "hash", hash, "nonce", tx.Nonce())
-		return false, ErrPoolOverflow
+	// A transaction reusing a pooled nonce replaces the old one in its slot, so
+	// it is exempt from the quotas but must stay with the same relayer
+	if old := pool.overlapping(from, tx.Nonce()); old != nil {
+		if old.ExchangeAddress() != tx.ExchangeAddress() {
+			log.Debug("Discarding cross-relayer order replacement", "hash", hash, "nonce", tx.Nonce(), "old", old.ExchangeAddress().Hex(), "new", tx.ExchangeAddress().Hex())
+			return false, ErrReplaceRelayerMismatch
+		}
+	} else if err := pool.checkQuotas(from, tx); err != nil {
+		log.Debug("Discarding order transaction over quota", "hash", hash, "nonce", tx.Nonce(), "err", err)
+		return false, err
 	}
 	// If the transaction is replacing an already pending one, do directly
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
@@ -597,10 +678,10 @@ func (pool *OrderPool) add(tx *types.OrderTransaction, local bool) (bool, error)
 			return false, ErrPendingNonceTooLow
 		}
 		if old != nil {
-			delete(pool.all, old.Hash())
+			pool.dropTx(old, TxDropReplaced)
 			pendingReplaceCounter.Inc(1)
 		}
-		pool.all[tx.Hash()] = tx
+		pool.track(tx)
 		pool.journalTx(from, tx)
 
 		log.Debug("Pooled new executable transaction", "hash", hash, "useraddress", tx.UserAddress().Hex(), "nonce", tx.Nonce(), "status", tx.Status(), "orderid", tx.OrderID())
@@ -641,13 +722,118 @@ func (pool *OrderPool) enqueueTx(hash common.Hash, tx *types.OrderTransaction) (
 	}
 	// Discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.dropTx(old, TxDropReplaced)
 		queuedReplaceCounter.Inc(1)
 	}
-	pool.all[hash] = tx
+	pool.track(tx)
 	return old != nil, nil
 }
 
+// overlapping returns the pooled transaction of the account with the given
+// nonce, either pending or queued, or nil if the nonce is free.
+func (pool *OrderPool) overlapping(from common.Address, nonce uint64) *types.OrderTransaction {
+	if list := pool.pending[from]; list != nil {
+		if tx := list.txs.Get(nonce); tx != nil {
+			return tx
+		}
+	}
+	if list := pool.queue[from]; list != nil {
+		return list.txs.Get(nonce)
+	}
+	return nil
+}
+
+// checkQuotas verifies that a new, non-replacing transaction fits into the
+// global pool size as well as into the share of its user and its relayer.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *OrderPool) checkQuotas(from common.Address, tx *types.OrderTransaction) error {
+	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
+		return ErrPoolOverflow
+	}
+	if limit := pool.config.UserSlots; limit > 0 {
+		count := 0
+		if list := pool.pending[from]; list != nil {
+			count += list.Len()
+		}
+		if list := pool.queue[from]; list != nil {
+			count += list.Len()
+		}
+		if uint64(count) >= limit {
+			return ErrUserQuotaExceeded
+		}
+	}
+	if limit := pool.config.RelayerSlots; limit > 0 && pool.relayers[tx.ExchangeAddress()] >= limit {
+		return ErrRelayerQuotaExceeded
+	}
+	return nil
+}
+
+// track inserts a transaction into the global lookup, recording its arrival
+// time and accounting it to its relayer. Already tracked ones are left as is.
+func (pool *OrderPool) track(tx *types.OrderTransaction) {
+	hash := tx.Hash()
+	if pool.all[hash] != nil {
+		return
+	}
+	pool.all[hash] = tx
+	pool.arrivals[hash] = time.Now()
+	pool.relayers[tx.ExchangeAddress()]++
+}
+
+// untrack removes a transaction from the global lookup, reverting everything
+// done by track.
+func (pool *OrderPool) untrack(hash common.Hash) {
+	tx := pool.all[hash]
+	if tx == nil {
+		return
+	}
+	delete(pool.all, hash)
+	delete(pool.arrivals, hash)
+
+	if relayer := tx.ExchangeAddress(); pool.relayers[relayer] > 1 {
+		pool.relayers[relayer]--
+	} else {
+		delete(pool.relayers, relayer)
+	}
+}
+
+// dropTx untracks a transaction already removed from the pending or queued
+// lists and announces it as dropped.
+func (pool *OrderPool) dropTx(tx *types.OrderTransaction, reason TxDropReason) {
+	pool.untrack(tx.Hash())
+	pool.announceDrop(tx, reason)
+}
+
+// announceDrop notifies the subscribers that a transaction left the pool for
+// the given reason without being included in a block.
+func (pool *OrderPool) announceDrop(tx *types.OrderTransaction, reason TxDropReason) {
+	log.Debug("Dropped order transaction", "hash", tx.Hash(), "addr", tx.UserAddress().Hex(), "nonce", tx.Nonce(), "relayer", tx.ExchangeAddress().Hex(), "reason", reason)
+	go pool.dropFeed.Send(OrderTxDroppedEvent{Tx: tx, Reason: reason})
+}
+
+// evictExpired removes every transaction, local or not, which has been pooled
+// for longer than the configured maximum age and returns the number dropped.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *OrderPool) evictExpired(now time.Time) int {
+	if pool.config.MaxAge == 0 {
+		return 0
+	}
+	var expired types.OrderTransactions
+	for hash, arrived := range pool.arrivals {
+		if now.Sub(arrived) > pool.config.MaxAge {
+			expired = append(expired, pool.all[hash])
+		}
+	}
+	sort.Sort(types.OrderTxByNonce(expired))
+	for _, tx := range expired {
+		pool.removeTx(tx.Hash())
+		pool.announceDrop(tx, TxDropExpired)
+	}
+	return len(expired)
+}
+
 // journalTx adds the specified transaction to the local disk journal if it is
 // deemed to have been sent from a local account.
 func (pool *OrderPool) journalTx(from common.Address, tx *types.OrderTransaction) {
@@ -674,19 +860,17 @@ func (pool *OrderPool) promoteTx(addr common.Address, hash common.Hash, tx *type
 	inserted, old := list.Add(tx)
 	if !inserted {
 		// An older transaction was better, discard this
-		delete(pool.all, hash)
+		pool.untrack(hash)
 		pendingDiscardCounter.Inc(1)
 		return
 	}
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.dropTx(old, TxDropReplaced)
 		pendingReplaceCounter.Inc(1)
 	}
 	// Failsafe to work around direct pending inserts (tests)
-	if pool.all[hash] == nil {
-		pool.all[hash] = tx
-	}
+	pool.track(tx)
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr.Hash(), tx.Nonce()+1)
@@ -825,7 +1009,7 @@ func (pool *OrderPool) removeTx(hash common.Hash) {
 	addr, _ := types.OrderSender(pool.signer, tx) // already validated during insertion
 
 	// Remove it from the list of known transactions
-	delete(pool.all, hash)
+	pool.untrack(hash)
 
 	// Remove the transaction from the pending lists and reset the account nonce
 	if pending := pool.pending[addr]; pending != nil {
@@ -878,7 +1062,7 @@ func (pool *OrderPool) promoteExecutables(accounts []common.Address) {
 		for _, tx := range list.Forward(pool.currentOrderState.GetNonce(addr.Hash())) {
 			hash := tx.Hash()
 			log.Debug("Removed old queued transaction", "addr", tx.UserAddress().Hex(), "nonce", tx.Nonce(), "ohash", tx.OrderHash().Hex(), "status", tx.Status(), "orderid", tx.OrderID())
-			delete(pool.all, hash)
+			pool.untrack(hash)
 
 		}
 
@@ -891,8 +1075,7 @@ func (pool *OrderPool) promoteExecutables(accounts []common.Address) {
 		// Drop all transactions over the allowed limit
 		if !pool.locals.contains(addr) {
 			for _, tx := range list.Cap(int(pool.config.AccountQueue)) {
-				hash := tx.Hash()
-				delete(pool.all, hash)
+				pool.dropTx(tx, TxDropAccountLimit)
 
 				queuedRateLimitCounter.Inc(1)
 				log.Debug("Removed cap-exceeding queued transaction", "addr", tx.UserAddress().Hex(), "nonce", tx.Nonce(), "ohash", tx.OrderHash().Hex(), "status", tx.Status(), "orderid", tx.OrderID())
@@ -937,8 +1120,7 @@ func (pool *OrderPool) promoteExecutables(accounts []common.Address) {
 						list := pool.pending[offenders[i]]
 						for _, tx := range list.Cap(list.Len() - 1) {
 							// Drop the transaction from the global pools too
-							hash := tx.Hash()
-							delete(pool.all, hash)
+							pool.dropTx(tx, TxDropPoolOverflow)
 
 							// Update the account nonce to the dropped transaction
 							if nonce := tx.Nonce(); pool.pendingState.GetNonce(offenders[i].Hash()) > nonce {
@@ -958,8 +1140,7 @@ func (pool *OrderPool) promoteExecutables(accounts []common.Address) {
 					list := pool.pending[addr]
 					for _, tx := range list.Cap(list.Len() - 1) {
 						// Drop the transaction from the global pools too
-						hash := tx.Hash()
-						delete(pool.all, hash)
+						pool.dropTx(tx, TxDropPoolOverflow)
 
 						// Update the account nonce to the dropped transaction
 						if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr.Hash()) > nonce {
@@ -999,6 +1180,7 @@ func (pool *OrderPool) promoteExecutables(accounts []common.Address) {
 			if size := uint64(list.Len()); size <= drop {
 				for _, tx := range list.Flatten() {
 					pool.removeTx(tx.Hash())
+					pool.announceDrop(tx, TxDropPoolOverflow)
 				}
 				drop -= size
 				queuedRateLimitCounter.Inc(int64(size))
@@ -1008,6 +1190,7 @@ func (pool *OrderPool) promoteExecutables(accounts []common.Address) {
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 				pool.removeTx(txs[i].Hash())
+				pool.announceDrop(txs[i], TxDropPoolOverflow)
 				drop--
 				queuedRateLimitCounter.Inc(1)
 			}
@@ -1027,7 +1210,7 @@ func (pool *OrderPool) demoteUnexecutables() {
 		for _, tx := range list.Forward(nonce) {
 			hash := tx.Hash()
 			log.Debug("demoteUnexecutables removed old queued transaction", "addr", tx.UserAddress().Hex(), "nonce", tx.Nonce(), "ohash", tx.OrderHash().Hex(), "status", tx.Status(), "orderid", tx.OrderID())
-			delete(pool.all, hash)
+			pool.untrack(hash)
 		}
 
 		// If there's a gap in front, warn (should never happen) and postpone all transactions
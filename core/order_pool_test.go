@@ -312,3 +312,104 @@ func TestCancelOrder(t *testing.T) {
 	time.Sleep(5 * time.Second)
 	//testSendOrder(t, new(big.Int).SetUint64(48), new(big.Int).SetUint64(15), "SELL", "NEW", 0)
 }
+
+// newTestOrderPool creates a bare order pool without chain backing, enough to
+// exercise the bookkeeping of queued transactions.
+func newTestOrderPool(config OrderPoolConfig) *OrderPool {
+	return &OrderPool{
+		config:   config,
+		signer:   types.OrderTxSigner{},
+		locals:   newOrderAccountSet(types.OrderTxSigner{}),
+		pending:  make(map[common.Address]*ordertxList),
+		queue:    make(map[common.Address]*ordertxList),
+		beats:    make(map[common.Address]time.Time),
+		all:      make(map[common.Hash]*types.OrderTransaction),
+		arrivals: make(map[common.Hash]time.Time),
+		relayers: make(map[common.Address]uint64),
+	}
+}
+
+func testOrderTx(t *testing.T, seed byte, nonce uint64, relayer common.Address, quantity int64) *types.OrderTransaction {
+	key, _ := crypto.ToECDSA(common.LeftPadBytes([]byte{seed}, 32))
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	tx := types.NewOrderTransaction(nonce, big.NewInt(quantity), big.NewInt(1), relayer, user, common.HexToAddress(common.TomoNativeAddress), BTCAddress, OrderStatusNew, OrderSideBid, OrderTypeLimit, common.Hash{}, 0)
+	signed, err := types.OrderSignTx(tx, types.OrderTxSigner{}, key)
+	if err != nil {
+		t.Fatalf("failed to sign order: %v", err)
+	}
+	return signed
+}
+
+// Tests that the per-user and per-relayer quotas only reject fresh nonces and
+// that replacements must stay with the relayer of the order they supersede.
+func TestOrderPoolQuotasAndReplacement(t *testing.T) {
+	config := DefaultOrderPoolConfig
+	config.UserSlots, config.RelayerSlots = 2, 3
+	pool := newTestOrderPool(config)
+
+	relayer, other := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	for i, tx := range []*types.OrderTransaction{testOrderTx(t, 1, 5, relayer, 1), testOrderTx(t, 1, 6, relayer, 1), testOrderTx(t, 2, 5, relayer, 1)} {
+		if _, err := pool.enqueueTx(tx.Hash(), tx); err != nil {
+			t.Fatalf("order %d: failed to enqueue: %v", i, err)
+		}
+	}
+	user, _ := types.OrderSender(pool.signer, testOrderTx(t, 1, 0, relayer, 1))
+	if err := pool.checkQuotas(user, testOrderTx(t, 1, 7, other, 1)); err != ErrUserQuotaExceeded {
+		t.Errorf("user quota mismatch: have %v, want %v", err, ErrUserQuotaExceeded)
+	}
+	if err := pool.checkQuotas(common.Address{}, testOrderTx(t, 3, 5, relayer, 1)); err != ErrRelayerQuotaExceeded {
+		t.Errorf("relayer quota mismatch: have %v, want %v", err, ErrRelayerQuotaExceeded)
+	}
+	if err := pool.checkQuotas(common.Address{}, testOrderTx(t, 3, 5, other, 1)); err != nil {
+		t.Errorf("unexpected quota error for another relayer: %v", err)
+	}
+	if old := pool.overlapping(user, 6); old == nil || old.ExchangeAddress() != relayer {
+		t.Fatalf("pooled order not found by nonce: %v", old)
+	}
+	// Replace an order by nonce and check the drop notification
+	drops := make(chan OrderTxDroppedEvent, 1)
+	sub := pool.SubscribeDroppedTxEvent(drops)
+	defer sub.Unsubscribe()
+
+	replacement := testOrderTx(t, 1, 6, relayer, 2)
+	if replaced, err := pool.enqueueTx(replacement.Hash(), replacement); err != nil || !replaced {
+		t.Fatalf("replacement failed: replaced %v, err %v", replaced, err)
+	}
+	select {
+	case ev := <-drops:
+		if ev.Reason != TxDropReplaced || ev.Tx.Nonce() != 6 {
+			t.Errorf("drop event mismatch: reason %s, nonce %d", ev.Reason, ev.Tx.Nonce())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no drop event for the replaced order")
+	}
+	if len(pool.all) != 3 || pool.relayers[relayer] != 3 {
+		t.Errorf("bookkeeping mismatch after replacement: %d pooled, %d for relayer", len(pool.all), pool.relayers[relayer])
+	}
+}
+
+// Tests that orders older than the maximum age are evicted, locals included.
+func TestOrderPoolAgeEviction(t *testing.T) {
+	pool := newTestOrderPool(DefaultOrderPoolConfig)
+
+	relayer := common.HexToAddress("0x01")
+	stale, fresh := testOrderTx(t, 1, 5, relayer, 1), testOrderTx(t, 2, 5, relayer, 1)
+	for _, tx := range []*types.OrderTransaction{stale, fresh} {
+		if _, err := pool.enqueueTx(tx.Hash(), tx); err != nil {
+			t.Fatalf("failed to enqueue: %v", err)
+		}
+	}
+	from, _ := types.OrderSender(pool.signer, stale)
+	pool.locals.add(from)
+	pool.arrivals[stale.Hash()] = time.Now().Add(-2 * DefaultOrderPoolConfig.MaxAge)
+
+	if dropped := pool.evictExpired(time.Now()); dropped != 1 {
+		t.Fatalf("evicted count mismatch: have %d, want 1", dropped)
+	}
+	if pool.all[stale.Hash()] != nil || pool.queue[from] != nil {
+		t.Error("expired order still pooled")
+	}
+	if pool.all[fresh.Hash()] == nil || pool.relayers[relayer] != 1 {
+		t.Error("fresh order evicted or relayer bookkeeping off")
+	}
+}
@@ -115,6 +115,21 @@ func GetTrieSyncProgress(db DatabaseReader) uint64 {
 	return new(big.Int).SetBytes(data).Uint64()
 }
 
+// ReadSyncCheckpoint retrieves the trusted checkpoint the chain was seeded from,
+// or nil if the chain was synced from the genesis.
+func ReadSyncCheckpoint(db DatabaseReader) *SyncCheckpoint {
+	data, _ := db.Get(syncCheckpointKey)
+	if len(data) == 0 {
+		return nil
+	}
+	checkpoint := new(SyncCheckpoint)
+	if err := rlp.DecodeBytes(data, checkpoint); err != nil {
+		log.Error("Invalid sync checkpoint RLP", "err", err)
+		return nil
+	}
+	return checkpoint
+}
+
 // GetHeaderRLP retrieves a block header in its raw RLP database encoding, or nil
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
@@ -345,6 +360,19 @@ func WriteTrieSyncProgress(db ethdb.KeyValueWriter, count uint64) error {
 	return nil
 }
 
+// WriteSyncCheckpoint stores the trusted checkpoint the chain was seeded from,
+// along with the backfill progress below it.
+func WriteSyncCheckpoint(db ethdb.KeyValueWriter, checkpoint *SyncCheckpoint) error {
+	data, err := rlp.EncodeToBytes(checkpoint)
+	if err != nil {
+		return err
+	}
+	if err := db.Put(syncCheckpointKey, data); err != nil {
+		log.Crit("Failed to store sync checkpoint", "err", err)
+	}
+	return nil
+}
+
 // WriteHeader serializes a block header into the database.
 func WriteHeader(db ethdb.KeyValueWriter, header *types.Header) error {
 	data, err := rlp.EncodeToBytes(header)
@@ -425,6 +453,75 @@ func WriteBlockReceipts(db ethdb.KeyValueWriter, hash common.Hash, number uint64
 	return nil
 }
 
+// ReadTxFailures retrieves the failures of the transactions of a block.
+func ReadTxFailures(db DatabaseReader, hash common.Hash, number uint64) []*types.TxFailure {
+	data, _ := db.Get(txFailuresKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	var failures []*types.TxFailure
+	if err := rlp.DecodeBytes(data, &failures); err != nil {
+		log.Error("Invalid transaction failures RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return failures
+}
+
+// WriteTxFailures stores the failures of the transactions of a block.
+func WriteTxFailures(db ethdb.KeyValueWriter, hash common.Hash, number uint64, failures []*types.TxFailure) error {
+	bytes, err := rlp.EncodeToBytes(failures)
+	if err != nil {
+		return err
+	}
+	if err := db.Put(txFailuresKey(number, hash), bytes); err != nil {
+		log.Crit("Failed to store transaction failures", "err", err)
+	}
+	return nil
+}
+
+// DeleteTxFailures removes the failures of the transactions of a block.
+func DeleteTxFailures(db DatabaseDeleter, hash common.Hash, number uint64) {
+	db.Delete(txFailuresKey(number, hash))
+}
+
+// ReadStateHistoryRLP retrieves the reverse state diff of a block in RLP encoding.
+func ReadStateHistoryRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Get(stateHistoryKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
+
+// WriteStateHistoryRLP stores the RLP encoded reverse state diff of a block.
+func WriteStateHistoryRLP(db ethdb.KeyValueWriter, hash common.Hash, number uint64, data rlp.RawValue) error {
+	if err := db.Put(stateHistoryKey(number, hash), data); err != nil {
+		log.Crit("Failed to store state history", "err", err)
+	}
+	return nil
+}
+
+// ReadStateHistoryHashes retrieves the hashes of all the blocks of a given
+// number, canonical or not, whose reverse state diff is stored.
+func ReadStateHistoryHashes(db ethdb.Iteratee, number uint64) []common.Hash {
+	prefix := append(append([]byte{}, stateHistoryPrefix...), encodeBlockNumber(number)...)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var hashes []common.Hash
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(prefix):]))
+		}
+	}
+	return hashes
+}
+
+// DeleteStateHistory removes the reverse state diff of a block.
+func DeleteStateHistory(db DatabaseDeleter, hash common.Hash, number uint64) {
+	db.Delete(stateHistoryKey(number, hash))
+}
+
 // DeleteCanonicalHash removes the number to hash canonical mapping.
 func DeleteCanonicalHash(db DatabaseDeleter, number uint64) {
 	db.Delete(headerHashKey(number))
@@ -449,6 +546,7 @@ func DeleteTd(db DatabaseDeleter, hash common.Hash, number uint64) {
 // DeleteBlock removes all block data associated with a hash.
 func DeleteBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
 	DeleteBlockReceipts(db, hash, number)
+	DeleteTxFailures(db, hash, number)
 	DeleteHeader(db, hash, number)
 	DeleteBody(db, hash, number)
 	DeleteTd(db, hash, number)
@@ -21,6 +21,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/tomochain/tomochain/common"
@@ -406,6 +407,31 @@ func TestBlockReceiptStorage(t *testing.T) {
 	}
 }
 
+// Tests that the failures of the transactions of a block can be stored and
+// retrieved.
+func TestTxFailuresStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+	hash := common.Hash{0x01}
+
+	if failures := ReadTxFailures(db, hash, 1); failures != nil {
+		t.Fatalf("non existent failures returned: %v", failures)
+	}
+	failures := []*types.TxFailure{
+		{TxHash: common.Hash{0x11}, Code: types.TxFailureReverted, Message: "execution reverted", Data: []byte{0x08, 0xc3, 0x79, 0xa0}},
+		{TxHash: common.Hash{0x22}, Code: types.TxFailureVMError, Message: "out of gas", Data: []byte{}},
+	}
+	if err := WriteTxFailures(db, hash, 1, failures); err != nil {
+		t.Fatalf("failed to write failures: %v", err)
+	}
+	if have := ReadTxFailures(db, hash, 1); !reflect.DeepEqual(have, failures) {
+		t.Fatalf("failures mismatch: have %v, want %v", have, failures)
+	}
+	DeleteBlock(db, hash, 1)
+	if have := ReadTxFailures(db, hash, 1); have != nil {
+		t.Fatalf("deleted failures returned: %v", have)
+	}
+}
+
 func checkReceiptsRLP(have, want types.Receipts) error {
 	if len(have) != len(want) {
 		return fmt.Errorf("receipts sizes mismatch: have %d, want %d", len(have), len(want))
@@ -31,6 +31,8 @@ var (
 	headFastKey   = []byte("LastFast")
 	trieSyncKey   = []byte("TrieSync")
 
+	syncCheckpointKey = []byte("SyncCheckpoint")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`).
 	headerPrefix        = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix      = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -40,6 +42,8 @@ var (
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 	txLookupPrefix      = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix     = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	stateHistoryPrefix  = []byte("S") // stateHistoryPrefix + num (uint64 big endian) + hash -> reverse state diff
+	txFailuresPrefix    = []byte("F") // txFailuresPrefix + num (uint64 big endian) + hash -> failures of the block transactions
 
 	preimagePrefix = "secure-key-"              // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
@@ -65,6 +69,14 @@ type TxLookupEntry struct {
 	Index      uint64
 }
 
+// SyncCheckpoint is the trusted checkpoint a chain was seeded from, along with
+// the progress of backfilling the blocks below it.
+type SyncCheckpoint struct {
+	Number uint64
+	Hash   common.Hash
+	Tail   uint64 // Lowest block retrieved so far, zero once linked to the genesis
+}
+
 // configKey = configPrefix + hash
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
@@ -105,6 +117,16 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// stateHistoryKey = stateHistoryPrefix + num (uint64 big endian) + hash
+func stateHistoryKey(number uint64, hash common.Hash) []byte {
+	return append(append(stateHistoryPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// txFailuresKey = txFailuresPrefix + num (uint64 big endian) + hash
+func txFailuresKey(number uint64, hash common.Hash) []byte {
+	return append(append(txFailuresPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// ErrRelayerLowDeposit is returned if the deposit of the relayer of a new order
+// is below the configured threshold or cannot cover the fees of its next
+// matching.
+var ErrRelayerLowDeposit = errors.New("relayer deposit too low")
+
+// relayerDeposits tracks the relayers whose deposit is too low for a pool to
+// accept their new orders, and posts a RelayerDepositEvent whenever a relayer
+// falls low or recovers.
+//
+// Events are queued and posted in order by a single goroutine, so that a slow
+// subscriber never blocks the pool holding its lock.
+//
+// Note, check and recheck are not thread safe, the pool lock must be held.
+type relayerDeposits struct {
+	lending  bool
+	required *big.Int                    // Minimum deposit of a relayer
+	low      map[common.Address]*big.Int // Deposit of the relayers too low
+	feed     event.Feed
+
+	queue   []RelayerDepositEvent // Events waiting to be posted, oldest first
+	queueMu sync.Mutex
+	wake    chan struct{} // Notifies the posting loop of queued events
+	quit    chan struct{}
+}
+
+// newRelayerDeposits creates a tracker requiring the deposit of a relayer to
+// reach minDeposit, and to cover the fee of a matching on both sides of a trade
+// on top of the locked fund.
+func newRelayerDeposits(minDeposit, matchingFee *big.Int, lending bool) *relayerDeposits {
+	required := new(big.Int).Mul(common.BasePrice, common.RelayerLockedFund)
+	required.Add(required, new(big.Int).Mul(matchingFee, big.NewInt(2)))
+	if minDeposit != nil && minDeposit.Cmp(required) > 0 {
+		required = new(big.Int).Set(minDeposit)
+	}
+	d := &relayerDeposits{
+		lending:  lending,
+		required: required,
+		low:      make(map[common.Address]*big.Int),
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+	}
+	go d.loop()
+	return d
+}
+
+// loop posts the queued events in the order they were queued.
+func (d *relayerDeposits) loop() {
+	for {
+		select {
+		case <-d.wake:
+			d.queueMu.Lock()
+			events := d.queue
+			d.queue = nil
+			d.queueMu.Unlock()
+
+			for _, ev := range events {
+				d.feed.Send(ev)
+			}
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// post queues ev behind the events not posted yet.
+func (d *relayerDeposits) post(ev RelayerDepositEvent) {
+	d.queueMu.Lock()
+	d.queue = append(d.queue, ev)
+	d.queueMu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stop terminates the posting loop, dropping the events not posted yet.
+func (d *relayerDeposits) stop() {
+	close(d.quit)
+}
+
+// check verifies the deposit of relayer in statedb, returning
+// ErrRelayerLowDeposit if it is too low.
+func (d *relayerDeposits) check(statedb *state.StateDB, relayer common.Address) error {
+	deposit := tradingstate.GetRelayerDeposit(relayer, statedb)
+	if deposit.Cmp(d.required) < 0 {
+		if _, ok := d.low[relayer]; !ok {
+			log.Warn("Relayer deposit too low, rejecting its orders", "relayer", relayer, "deposit", deposit, "required", d.required, "lending", d.lending)
+			d.post(RelayerDepositEvent{Relayer: relayer, Lending: d.lending, Deposit: deposit, Required: d.required, Low: true})
+		}
+		d.low[relayer] = deposit
+		return ErrRelayerLowDeposit
+	}
+	if _, ok := d.low[relayer]; ok {
+		log.Info("Relayer deposit recovered", "relayer", relayer, "deposit", deposit, "lending", d.lending)
+		delete(d.low, relayer)
+		d.post(RelayerDepositEvent{Relayer: relayer, Lending: d.lending, Deposit: deposit, Required: d.required, Low: false})
+	}
+	return nil
+}
+
+// recheck verifies the deposit of the relayers with pooled transactions and of
+// the ones already known to be low against a new head state.
+func (d *relayerDeposits) recheck(statedb *state.StateDB, pooled map[common.Address]uint64) {
+	for relayer := range d.low {
+		d.check(statedb, relayer)
+	}
+	for relayer := range pooled {
+		if _, ok := d.low[relayer]; !ok {
+			d.check(statedb, relayer)
+		}
+	}
+}
+
+// events returns the relayers whose deposit is too low.
+func (d *relayerDeposits) events() []RelayerDepositEvent {
+	events := make([]RelayerDepositEvent, 0, len(d.low))
+	for relayer, deposit := range d.low {
+		events = append(events, RelayerDepositEvent{Relayer: relayer, Lending: d.lending, Deposit: deposit, Required: d.required, Low: true})
+	}
+	return events
+}
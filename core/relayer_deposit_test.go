@@ -0,0 +1,93 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func setRelayerDeposit(statedb *state.StateDB, relayer common.Address, deposit *big.Int) {
+	loc := tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+	loc = new(big.Int).Add(loc, tradingstate.RelayerStructMappingSlot["_deposit"])
+	statedb.SetState(common.HexToAddress(common.RelayerRegistrationSMC), common.BigToHash(loc), common.BigToHash(deposit))
+}
+
+// Tests that relayers are reported once when their deposit falls below the
+// threshold or the fees of their next matching, and again once it recovers.
+func TestRelayerDeposits(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	lockedFund := new(big.Int).Mul(common.BasePrice, common.RelayerLockedFund)
+	minDeposit := new(big.Int).Add(lockedFund, common.BasePrice)
+
+	relayer := common.HexToAddress("0x0000000000000000000000000000000000000d01")
+	deposits := newRelayerDeposits(nil, common.RelayerFee, false)
+	defer deposits.stop()
+	events := make(chan RelayerDepositEvent, 3)
+	sub := deposits.feed.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	// the locked fund alone cannot cover the fees of the next matching
+	setRelayerDeposit(statedb, relayer, lockedFund)
+	if err := deposits.check(statedb, relayer); err != ErrRelayerLowDeposit {
+		t.Fatalf("deposit check mismatch: have %v, want %v", err, ErrRelayerLowDeposit)
+	}
+	deposits.check(statedb, relayer)
+	select {
+	case ev := <-events:
+		if ev.Relayer != relayer || !ev.Low || ev.Deposit.Cmp(lockedFund) != 0 {
+			t.Errorf("low deposit event mismatch: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no low deposit event")
+	}
+	if low := deposits.events(); len(low) != 1 || low[0].Relayer != relayer {
+		t.Errorf("low deposit relayers mismatch: %v", low)
+	}
+	// a new head with a refilled deposit clears the relayer
+	setRelayerDeposit(statedb, relayer, minDeposit)
+	deposits.recheck(statedb, nil)
+	select {
+	case ev := <-events:
+		if ev.Relayer != relayer || ev.Low {
+			t.Errorf("recovered deposit event mismatch: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no recovered deposit event")
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("low deposit reported twice: %+v", ev)
+	default:
+	}
+	if low := deposits.events(); len(low) != 0 {
+		t.Errorf("recovered relayer still low: %v", low)
+	}
+	// successive changes are reported in order
+	changes := []*big.Int{lockedFund, minDeposit, lockedFund}
+	for _, deposit := range changes {
+		setRelayerDeposit(statedb, relayer, deposit)
+		deposits.recheck(statedb, map[common.Address]uint64{relayer: 1})
+	}
+	for i, deposit := range changes {
+		select {
+		case ev := <-events:
+			if ev.Deposit.Cmp(deposit) != 0 || ev.Low != (i%2 == 0) {
+				t.Errorf("event %d mismatch: %+v", i, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event %d", i)
+		}
+	}
+	// the configured threshold is required on top of the fees
+	threshold := newRelayerDeposits(new(big.Int).Add(minDeposit, common.Big1), common.RelayerFee, false)
+	defer threshold.stop()
+	setRelayerDeposit(statedb, relayer, minDeposit)
+	if err := threshold.check(statedb, relayer); err != ErrRelayerLowDeposit {
+		t.Errorf("threshold check mismatch: have %v, want %v", err, ErrRelayerLowDeposit)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+// SponsorAllowListABI is the interface of the contract a node can consult to
+// decide which fee payers it accepts sponsored transactions from:
+//
+//	interface SponsorAllowList {
+//	    function isSponsorAllowed(address payer, address sender, address to) external view returns (bool);
+//	}
+//
+// The recipient is the zero address for contract creations.
+const SponsorAllowListABI = `[{"constant":true,"inputs":[{"name":"payer","type":"address"},{"name":"sender","type":"address"},{"name":"to","type":"address"}],"name":"isSponsorAllowed","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"view","type":"function"}]`
+
+const isSponsorAllowedFunction = "isSponsorAllowed"
+
+// ErrSponsorNotAllowed is returned if the sponsor allow-list contract rejects
+// the fee payer of a sponsored transaction.
+var ErrSponsorNotAllowed = errors.New("fee payer not allowed to sponsor the transaction")
+
+// ValidateSponsor asks the allow-list contract whether the fee payer may sponsor
+// a transaction of the sender to the given recipient.
+func ValidateSponsor(chain consensus.ChainContext, statedb *state.StateDB, allowList common.Address, payer common.Address, sender common.Address, to *common.Address) error {
+	contractABI, err := GetTokenAbi(SponsorAllowListABI)
+	if err != nil {
+		return err
+	}
+	var recipient common.Address
+	if to != nil {
+		recipient = *to
+	}
+	result, err := RunContract(chain, statedb, allowList, contractABI, isSponsorAllowedFunction, payer, sender, recipient)
+	if err != nil {
+		return fmt.Errorf("sponsor allow-list %s failed: %v", allowList.Hex(), err)
+	}
+	if allowed, ok := result.(bool); !ok || !allowed {
+		return ErrSponsorNotAllowed
+	}
+	return nil
+}
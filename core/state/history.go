@@ -0,0 +1,259 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// StorageHistory is the value a storage slot held before a block was applied.
+// The key is the hashed slot key as it is stored in the storage trie, an empty
+// value means the slot did not exist.
+type StorageHistory struct {
+	Key   common.Hash
+	Value common.Hash
+}
+
+// AccountHistory is the state an account had before a block was applied.
+type AccountHistory struct {
+	Address    common.Address
+	Existed    bool // Whether the account was present in the parent state
+	Destructed bool // Whether the storage of the account was wiped by the block
+	Nonce      uint64
+	Balance    *big.Int
+	CodeHash   []byte
+	Code       []byte           // Parent code, only set if the block changed it
+	Storage    []StorageHistory // Parent values of the changed slots, all of them if destructed
+}
+
+// History is the reverse state diff of a single block. Applying it on top of
+// the post state of the block yields the state of its parent.
+type History struct {
+	ParentRoot common.Hash
+	Accounts   []AccountHistory
+}
+
+// accountOrigin is the state of an account before its first change since the
+// last commit, recorded along with the journal entry of that change.
+type accountOrigin struct {
+	existed     bool
+	destructed  bool
+	nonce       uint64
+	balance     *big.Int
+	codeHash    []byte
+	storageRoot common.Hash
+	storage     map[common.Hash]common.Hash // values of the changed slots, by slot key
+}
+
+// recordSlot records the value of a slot before its first change.
+func (o *accountOrigin) recordSlot(key, prev common.Hash) {
+	if _, ok := o.storage[key]; !ok {
+		o.storage[key] = prev
+	}
+}
+
+func (o *accountOrigin) copy() *accountOrigin {
+	cpy := *o
+	if o.balance != nil {
+		cpy.balance = new(big.Int).Set(o.balance)
+	}
+	cpy.storage = make(map[common.Hash]common.Hash, len(o.storage))
+	for key, value := range o.storage {
+		cpy.storage[key] = value
+	}
+	return &cpy
+}
+
+// recordOrigin records the state of obj if it is changed for the first time
+// since the last commit, and returns the recorded origin of the account.
+func (self *StateDB) recordOrigin(obj *stateObject) *accountOrigin {
+	if origin, ok := self.historyOrigins[obj.address]; ok {
+		return origin
+	}
+	origin := &accountOrigin{
+		existed:     true,
+		nonce:       obj.data.Nonce,
+		balance:     new(big.Int).Set(obj.data.Balance),
+		codeHash:    common.CopyBytes(obj.data.CodeHash),
+		storageRoot: obj.data.Root,
+		storage:     make(map[common.Hash]common.Hash),
+	}
+	self.historyOrigins[obj.address] = origin
+	return origin
+}
+
+// recordCreated records that the account addr did not exist before its
+// creation, if it was not changed before since the last commit.
+func (self *StateDB) recordCreated(addr common.Address) {
+	if _, ok := self.historyOrigins[addr]; !ok {
+		self.historyOrigins[addr] = &accountOrigin{storage: make(map[common.Hash]common.Hash)}
+	}
+}
+
+// History builds the reverse diff between the parent state identified by
+// parentRoot and the changes accumulated in the state since the last commit,
+// from the state of the accounts recorded on their first change. The whole
+// parent storage of destructed accounts is recorded, so the diff does not rely
+// on the parent tries. It must be called before Commit, which resets the
+// change tracking.
+func (self *StateDB) History(parentRoot common.Hash) (*History, error) {
+	addrs := make([]common.Address, 0, len(self.historyOrigins))
+	for addr := range self.historyOrigins {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+
+	history := &History{ParentRoot: parentRoot, Accounts: make([]AccountHistory, 0, len(addrs))}
+	for _, addr := range addrs {
+		origin := self.historyOrigins[addr]
+		if !origin.existed {
+			history.Accounts = append(history.Accounts, AccountHistory{Address: addr})
+			continue
+		}
+		entry := AccountHistory{
+			Address:  addr,
+			Existed:  true,
+			Nonce:    origin.nonce,
+			Balance:  new(big.Int).Set(origin.balance),
+			CodeHash: common.CopyBytes(origin.codeHash),
+		}
+		post := self.stateObjects[addr]
+		if post == nil || post.deleted || !bytes.Equal(post.CodeHash(), origin.codeHash) {
+			if !bytes.Equal(origin.codeHash, emptyCodeHash) {
+				code, err := self.db.ContractCode(crypto.Keccak256Hash(addr[:]), common.BytesToHash(origin.codeHash))
+				if err != nil {
+					return nil, err
+				}
+				entry.Code = common.CopyBytes(code)
+			}
+		}
+		if origin.destructed || post == nil || post.deleted {
+			entry.Destructed = true
+			storage, err := self.parentStorage(addr, origin.storageRoot)
+			if err != nil {
+				return nil, err
+			}
+			entry.Storage = storage
+		} else {
+			for key, value := range origin.storage {
+				entry.Storage = append(entry.Storage, StorageHistory{
+					Key:   crypto.Keccak256Hash(key[:]),
+					Value: value,
+				})
+			}
+		}
+		sort.Slice(entry.Storage, func(i, j int) bool {
+			return bytes.Compare(entry.Storage[i].Key[:], entry.Storage[j].Key[:]) < 0
+		})
+		history.Accounts = append(history.Accounts, entry)
+	}
+	return history, nil
+}
+
+// parentStorage collects every slot of the parent storage trie of a destructed
+// account.
+func (self *StateDB) parentStorage(addr common.Address, root common.Hash) ([]StorageHistory, error) {
+	if root == (common.Hash{}) || root == types.EmptyRootHash {
+		return nil, nil
+	}
+	tr, err := self.db.OpenStorageTrie(crypto.Keccak256Hash(addr[:]), root)
+	if err != nil {
+		return nil, err
+	}
+	var storage []StorageHistory
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		_, content, _, err := rlp.Split(it.Value)
+		if err != nil {
+			return nil, err
+		}
+		storage = append(storage, StorageHistory{
+			Key:   common.BytesToHash(it.Key),
+			Value: common.BytesToHash(content),
+		})
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	return storage, nil
+}
+
+// Rollback reverts the changes recorded in the reverse diff of a block,
+// turning the post state of the block into the state of its parent. The
+// resulting root is verified against the parent root stored in the diff.
+func (self *StateDB) Rollback(history *History) error {
+	for _, entry := range history.Accounts {
+		addr := entry.Address
+		if !entry.Existed {
+			if obj := self.getStateObject(addr); obj != nil {
+				obj.markSuicided()
+				self.MarkStateObjectDirty(addr)
+			}
+			continue
+		}
+		obj := self.getStateObject(addr)
+		if obj == nil || entry.Destructed {
+			// A destructed account gets its whole parent storage back from the
+			// diff, on top of an empty storage
+			obj, _ = self.createObject(addr)
+		}
+		obj.setNonce(entry.Nonce)
+		obj.setBalance(entry.Balance)
+		if entry.Code != nil {
+			obj.setCode(crypto.Keccak256Hash(entry.Code), entry.Code)
+		} else if !bytes.Equal(obj.CodeHash(), entry.CodeHash) {
+			obj.data.CodeHash = entry.CodeHash
+			obj.code = nil
+		}
+		tr, ok := obj.getTrie(self.db).(*trie.SecureTrie)
+		if !ok {
+			return fmt.Errorf("unsupported storage trie for %x", addr)
+		}
+		for _, slot := range entry.Storage {
+			if (slot.Value == common.Hash{}) {
+				self.setError(tr.TryDeleteHashed(slot.Key[:]))
+				continue
+			}
+			v, _ := rlp.EncodeToBytes(bytes.TrimLeft(slot.Value[:], "\x00"))
+			self.setError(tr.TryUpdateHashed(slot.Key[:], v))
+		}
+		// Drop any cached slots, the trie is the source of truth now
+		obj.cachedStorage = make(Storage)
+		self.MarkStateObjectDirty(addr)
+	}
+	if self.dbErr != nil {
+		return self.dbErr
+	}
+	if root := self.IntermediateRoot(false); root != history.ParentRoot {
+		return fmt.Errorf("state history mismatch: have %x, want %x", root, history.ParentRoot)
+	}
+	self.clearHistory()
+	return nil
+}
+
+// clearHistory resets the change tracking used to build state histories.
+func (self *StateDB) clearHistory() {
+	self.historyOrigins = make(map[common.Address]*accountOrigin)
+}
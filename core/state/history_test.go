@@ -0,0 +1,134 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// Tests that the reverse diff of a block turns its post state back into the
+// parent state, covering balance, nonce, code and storage changes, account
+// creation, self destruction and deletion.
+func TestHistoryRollback(t *testing.T) {
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	parent, _ := New(common.Hash{}, db)
+
+	var (
+		modified  = common.BytesToAddress([]byte{0x01})
+		destroyed = common.BytesToAddress([]byte{0x02})
+		deleted   = common.BytesToAddress([]byte{0x03})
+		created   = common.BytesToAddress([]byte{0x04})
+		untouched = common.BytesToAddress([]byte{0x05})
+	)
+	for i, addr := range []common.Address{modified, destroyed, deleted, untouched} {
+		parent.SetBalance(addr, big.NewInt(int64(100*(i+1))))
+		parent.SetNonce(addr, uint64(i+1))
+		parent.SetCode(addr, []byte{byte(i), 0x60, 0x00})
+		for j := byte(1); j < 4; j++ {
+			parent.SetState(addr, common.Hash{j}, common.Hash{byte(i), j})
+		}
+	}
+	parentRoot, err := parent.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit parent state: %v", err)
+	}
+	db.TrieDB().Commit(parentRoot, false)
+
+	// Apply a "block" on top of the parent
+	post, _ := New(parentRoot, db)
+	post.AddBalance(modified, big.NewInt(42))
+	post.SetNonce(modified, 10)
+	post.SetCode(modified, []byte{0xff})
+	post.SetState(modified, common.Hash{1}, common.Hash{})
+	post.SetState(modified, common.Hash{2}, common.Hash{0xaa})
+	post.SetState(modified, common.Hash{9}, common.Hash{0xbb})
+	post.Suicide(destroyed)
+	post.DeleteAddress(deleted)
+	post.SetBalance(created, big.NewInt(7))
+	post.SetState(created, common.Hash{1}, common.Hash{1})
+	post.IntermediateRoot(false)
+
+	history, err := post.History(parentRoot)
+	if err != nil {
+		t.Fatalf("failed to build history: %v", err)
+	}
+	postRoot, err := post.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit post state: %v", err)
+	}
+	if len(history.Accounts) != 4 {
+		t.Fatalf("history account count mismatch: have %d, want 4", len(history.Accounts))
+	}
+	for _, entry := range history.Accounts {
+		if entry.Address == destroyed && len(entry.Storage) != 3 {
+			t.Fatalf("destroyed storage not recorded in full: have %d slots, want 3", len(entry.Storage))
+		}
+	}
+	// Round trip through RLP to make sure the stored form is complete
+	enc, err := rlp.EncodeToBytes(history)
+	if err != nil {
+		t.Fatalf("failed to encode history: %v", err)
+	}
+	decoded := new(History)
+	if err := rlp.DecodeBytes(enc, decoded); err != nil {
+		t.Fatalf("failed to decode history: %v", err)
+	}
+	rolled, _ := New(postRoot, db)
+	if err := rolled.Rollback(decoded); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if have := rolled.GetBalance(modified); have.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 100", have)
+	}
+	if have := rolled.GetCode(modified); !bytes.Equal(have, []byte{0, 0x60, 0x00}) {
+		t.Errorf("code mismatch: have %x", have)
+	}
+	if have := rolled.GetState(destroyed, common.Hash{3}); have != (common.Hash{1, 3}) {
+		t.Errorf("destroyed storage mismatch: have %x", have)
+	}
+	if rolled.Exist(created) {
+		t.Errorf("created account still exists after rollback")
+	}
+}
+
+// Tests that a corrupted reverse diff is detected by the root verification.
+func TestHistoryRollbackMismatch(t *testing.T) {
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	parent, _ := New(common.Hash{}, db)
+	addr := common.BytesToAddress([]byte{0x01})
+	parent.SetBalance(addr, big.NewInt(1))
+	parentRoot, _ := parent.Commit(false)
+
+	post, _ := New(parentRoot, db)
+	post.SetBalance(addr, big.NewInt(2))
+	history, err := post.History(parentRoot)
+	if err != nil {
+		t.Fatalf("failed to build history: %v", err)
+	}
+	postRoot, _ := post.Commit(false)
+
+	history.Accounts[0].Balance = big.NewInt(3)
+	rolled, _ := New(postRoot, db)
+	if err := rolled.Rollback(history); err == nil {
+		t.Fatalf("corrupted history rolled back without error")
+	}
+}
@@ -149,6 +149,7 @@ func (c *stateObject) touch() {
 		prev:      c.touched,
 		prevDirty: c.onDirty == nil,
 	})
+	c.db.recordOrigin(c)
 	if c.onDirty != nil {
 		c.onDirty(c.Address())
 		c.onDirty = nil
@@ -212,11 +213,13 @@ func (self *stateObject) GetState(db Database, key common.Hash) common.Hash {
 
 // SetState updates a value in account storage.
 func (self *stateObject) SetState(db Database, key, value common.Hash) {
+	prev := self.GetState(db, key)
 	self.db.journal = append(self.db.journal, storageChange{
 		account:  &self.address,
 		key:      key,
-		prevalue: self.GetState(db, key),
+		prevalue: prev,
 	})
+	self.db.recordOrigin(self).recordSlot(key, prev)
 	self.setState(key, value)
 }
 
@@ -230,6 +233,28 @@ func (self *stateObject) setState(key, value common.Hash) {
 	}
 }
 
+// SetStorage replaces the entire storage of the object with the given slots.
+// The previous storage trie is dropped and the change is not journaled, so it
+// should only be used for debugging and call simulation.
+func (self *stateObject) SetStorage(storage map[common.Hash]common.Hash) {
+	tr, err := self.db.db.OpenStorageTrie(self.addrHash, common.Hash{})
+	if err != nil {
+		self.setError(err)
+		return
+	}
+	self.db.recordOrigin(self).destructed = true
+	self.trie = tr
+	self.cachedStorage = make(Storage)
+	self.dirtyStorage = make(Storage)
+	for key, value := range storage {
+		self.setState(key, value)
+	}
+	if self.onDirty != nil {
+		self.onDirty(self.Address())
+		self.onDirty = nil
+	}
+}
+
 // updateTrie writes cached storage modifications into the object's storage trie.
 func (self *stateObject) updateTrie(db Database) Trie {
 	tr := self.getTrie(db)
@@ -295,6 +320,7 @@ func (self *stateObject) SetBalance(amount *big.Int) {
 		account: &self.address,
 		prev:    new(big.Int).Set(self.data.Balance),
 	})
+	self.db.recordOrigin(self)
 	self.setBalance(amount)
 }
 
@@ -355,6 +381,7 @@ func (self *stateObject) SetCode(codeHash common.Hash, code []byte) {
 		prevhash: self.CodeHash(),
 		prevcode: prevcode,
 	})
+	self.db.recordOrigin(self)
 	self.setCode(codeHash, code)
 }
 
@@ -373,6 +400,7 @@ func (self *stateObject) SetNonce(nonce uint64) {
 		account: &self.address,
 		prev:    self.data.Nonce,
 	})
+	self.db.recordOrigin(self)
 	self.setNonce(nonce)
 }
 
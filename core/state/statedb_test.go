@@ -174,6 +174,33 @@ func TestCopy(t *testing.T) {
 	}
 }
 
+// Tests that replacing the storage of an account drops all the slots that are
+// not part of the new storage, both cached and committed ones.
+func TestSetStorage(t *testing.T) {
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	state, _ := New(common.Hash{}, db)
+	addr := common.BytesToAddress([]byte{0x01})
+	state.SetState(addr, common.Hash{1}, common.Hash{1})
+	state.SetState(addr, common.Hash{2}, common.Hash{2})
+	root, _ := state.Commit(false)
+
+	state, _ = New(root, db)
+	state.SetState(addr, common.Hash{3}, common.Hash{3})
+	state.SetStorage(addr, map[common.Hash]common.Hash{{2}: {0x22}})
+
+	for key, want := range map[common.Hash]common.Hash{{1}: {}, {2}: {0x22}, {3}: {}} {
+		if have := state.GetState(addr, key); have != want {
+			t.Errorf("slot %x mismatch: have %x, want %x", key, have, want)
+		}
+	}
+	// The overridden storage must hash to the same root as a fresh one
+	fresh, _ := New(common.Hash{}, db)
+	fresh.SetState(addr, common.Hash{2}, common.Hash{0x22})
+	if have, want := state.IntermediateRoot(false), fresh.IntermediateRoot(false); have != want {
+		t.Errorf("root mismatch: have %x, want %x", have, want)
+	}
+}
+
 func TestSnapshotRandom(t *testing.T) {
 	config := &quick.Config{MaxCount: 1000}
 	err := quick.Check((*snapshotTest).run, config)
@@ -427,3 +454,31 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+// Tests that access list additions are journalled and undone on revert, and
+// that copies do not share the list with the original.
+func TestStateDBAccessList(t *testing.T) {
+	addr := common.HexToAddress("0x01")
+	slot := common.HexToHash("0x01")
+
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	state.PrepareAccessList(common.HexToAddress("0xaa"), nil, nil, nil)
+
+	snap := state.Snapshot()
+	state.AddSlotToAccessList(addr, slot)
+	if addrOk, slotOk := state.SlotInAccessList(addr, slot); !addrOk || !slotOk {
+		t.Fatalf("slot missing after add: address %v, slot %v", addrOk, slotOk)
+	}
+	cpy := state.Copy()
+
+	state.RevertToSnapshot(snap)
+	if state.AddressInAccessList(addr) {
+		t.Error("address still present after revert")
+	}
+	if !state.AddressInAccessList(common.HexToAddress("0xaa")) {
+		t.Error("sender dropped by revert")
+	}
+	if addrOk, slotOk := cpy.SlotInAccessList(addr, slot); !addrOk || !slotOk {
+		t.Error("copy lost access list entries after original was reverted")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// historyStateCacheLimit is the number of states rebuilt from the state history
+// that are kept around to serve repeated queries on the same block.
+const historyStateCacheLimit = 16
+
+var (
+	// ErrStateHistoryDisabled is returned if a pruned state is requested while the
+	// node does not record reverse state diffs.
+	ErrStateHistoryDisabled = errors.New("state history disabled")
+
+	// ErrStateHistoryUnavailable is returned if a pruned state is requested that is
+	// outside of the configured state history window.
+	ErrStateHistoryUnavailable = errors.New("state not available in history window")
+)
+
+// writeStateHistory stores the reverse state diff of a block into the batch and
+// drops the diff that just went out of the configured history window.
+func (bc *BlockChain) writeStateHistory(batch ethdb.Batch, block *types.Block, statedb *state.StateDB) error {
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	history, err := statedb.History(parent.Root)
+	if err != nil {
+		return err
+	}
+	enc, err := rlp.EncodeToBytes(history)
+	if err != nil {
+		return err
+	}
+	if err := rawdb.WriteStateHistoryRLP(batch, block.Hash(), block.NumberU64(), enc); err != nil {
+		return err
+	}
+	// Drop the diffs of every block, side chains included, that just went out
+	// of the history window
+	if number := block.NumberU64(); number > bc.cacheConfig.StateHistory {
+		stale := number - bc.cacheConfig.StateHistory
+		for _, hash := range rawdb.ReadStateHistoryHashes(bc.db, stale) {
+			rawdb.DeleteStateHistory(batch, hash, stale)
+		}
+	}
+	return nil
+}
+
+// GetStateHistory retrieves the reverse state diff of a block from the database.
+func (bc *BlockChain) GetStateHistory(hash common.Hash, number uint64) *state.History {
+	data := rawdb.ReadStateHistoryRLP(bc.db, hash, number)
+	if len(data) == 0 {
+		return nil
+	}
+	history := new(state.History)
+	if err := rlp.DecodeBytes(data, history); err != nil {
+		log.Error("Invalid state history RLP", "hash", hash, "number", number, "err", err)
+		return nil
+	}
+	return history
+}
+
+// StateAtHeader returns a new mutable state at the given canonical header. If
+// the state of the block was already pruned, it is rebuilt by rolling back the
+// reverse state diffs from the nearest later block whose state is still held.
+func (bc *BlockChain) StateAtHeader(header *types.Header) (*state.StateDB, error) {
+	statedb, err := bc.StateAt(header.Root)
+	if err == nil {
+		return statedb, nil
+	}
+	if bc.cacheConfig.Disabled {
+		return nil, err
+	}
+	if bc.cacheConfig.StateHistory == 0 {
+		return nil, ErrStateHistoryDisabled
+	}
+	if cached, ok := bc.historyStates.Get(header.Root); ok {
+		return cached.(*state.StateDB).Copy(), nil
+	}
+	number := header.Number.Uint64()
+	head := bc.CurrentBlock().NumberU64()
+	if number > head || head-number > bc.cacheConfig.StateHistory {
+		return nil, ErrStateHistoryUnavailable
+	}
+	if rawdb.GetCanonicalHash(bc.db, number) != header.Hash() {
+		return nil, fmt.Errorf("block #%d [%x…] is not canonical", number, header.Hash().Bytes()[:4])
+	}
+	// Find the closest descendant whose state is still available
+	var (
+		headers []*types.Header
+		base    *state.StateDB
+	)
+	for n := number + 1; n <= head; n++ {
+		h := bc.GetHeaderByNumber(n)
+		if h == nil {
+			return nil, ErrStateHistoryUnavailable
+		}
+		headers = append(headers, h)
+		if base, err = bc.StateAt(h.Root); err == nil {
+			break
+		}
+	}
+	if base == nil {
+		return nil, ErrStateHistoryUnavailable
+	}
+	// Roll the state back block by block, each step is verified against the parent root
+	for i := len(headers) - 1; i >= 0; i-- {
+		h := headers[i]
+		history := bc.GetStateHistory(h.Hash(), h.Number.Uint64())
+		if history == nil {
+			return nil, ErrStateHistoryUnavailable
+		}
+		if err := base.Rollback(history); err != nil {
+			return nil, fmt.Errorf("failed to roll back block #%d: %v", h.Number, err)
+		}
+	}
+	log.Debug("Rebuilt historical state", "number", number, "root", header.Root, "rollbacks", len(headers))
+	bc.historyStates.Add(header.Root, base)
+	return base.Copy(), nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that states garbage collected by a pruning node can be rebuilt from the
+// state history as long as they are within the configured window.
+func TestStateAtHeaderFromHistory(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		engine  = ethash.NewFaker()
+		signer  = types.HomesteadSigner{}
+	)
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2*triesInMemory, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{byte(i)}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		b.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	cacheConfig := &CacheConfig{TrieNodeLimit: 256 * 1024 * 1024, TrieTimeLimit: 5 * time.Minute, StateHistory: 2 * triesInMemory}
+	chain, err := NewBlockChain(diskdb, cacheConfig, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	target := blocks[9]
+	if _, err := chain.StateAt(target.Root()); err == nil {
+		t.Fatalf("state of block %d not pruned", target.NumberU64())
+	}
+	statedb, err := chain.StateAtHeader(target.Header())
+	if err != nil {
+		t.Fatalf("failed to rebuild state: %v", err)
+	}
+	if root := statedb.IntermediateRoot(false); root != target.Root() {
+		t.Fatalf("rebuilt root mismatch: have %x, want %x", root, target.Root())
+	}
+	if nonce := statedb.GetNonce(address); nonce != 10 {
+		t.Fatalf("rebuilt nonce mismatch: have %d, want 10", nonce)
+	}
+	// States beyond the history window must be rejected
+	chain.cacheConfig.StateHistory = 16
+	if _, err := chain.StateAtHeader(blocks[20].Header()); err != ErrStateHistoryUnavailable {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrStateHistoryUnavailable)
+	}
+}
+
+// Tests that the state histories of side chain blocks are dropped together with
+// the canonical ones once they go out of the history window.
+func TestStateHistoryPruneSideChain(t *testing.T) {
+	var (
+		gspec  = &Genesis{Config: params.TestChainConfig}
+		engine = ethash.NewFaker()
+	)
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 32, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	forks, _ := GenerateChain(params.TestChainConfig, blocks[3], engine, db, 2, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{2})
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	cacheConfig := &CacheConfig{TrieNodeLimit: 256 * 1024 * 1024, TrieTimeLimit: 5 * time.Minute, StateHistory: 16}
+	chain, err := NewBlockChain(diskdb, cacheConfig, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks[:10]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("failed to insert side chain: %v", err)
+	}
+	for _, block := range forks {
+		if chain.GetStateHistory(block.Hash(), block.NumberU64()) == nil {
+			t.Fatalf("side chain block %d has no state history", block.NumberU64())
+		}
+	}
+	if _, err := chain.InsertChain(blocks[10:]); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for _, block := range append(append([]*types.Block{}, blocks[:16]...), forks...) {
+		if hashes := rawdb.ReadStateHistoryHashes(diskdb, block.NumberU64()); len(hashes) != 0 {
+			t.Fatalf("state history of block %d not pruned: %x", block.NumberU64(), hashes)
+		}
+	}
+	for _, block := range blocks[16:] {
+		if chain.GetStateHistory(block.Hash(), block.NumberU64()) == nil {
+			t.Fatalf("state history of block %d missing", block.NumberU64())
+		}
+	}
+}
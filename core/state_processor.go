@@ -239,7 +239,7 @@ func ApplyTransaction(config *params.ChainConfig, tokensFee map[common.Address]*
 	}
 
 	var balanceFee *big.Int
-	if tx.To() != nil {
+	if tx.To() != nil && tx.Type() != types.SponsoredTxType {
 		if value, ok := tokensFee[*tx.To()]; ok {
 			balanceFee = value
 		}
@@ -409,7 +409,8 @@ func ApplyTransaction(config *params.ChainConfig, tokensFee map[common.Address]*
 	// End Bypass blacklist address
 
 	// Apply the transaction to the current state (included in the env)
-	_, gas, failed, err := ApplyMessage(vmenv, msg, gp, coinbaseOwner)
+	st := NewStateTransition(vmenv, msg, gp)
+	ret, gas, failed, err := st.TransitionDb(coinbaseOwner)
 
 	if err != nil {
 		return nil, 0, err, false
@@ -426,17 +427,29 @@ func ApplyTransaction(config *params.ChainConfig, tokensFee map[common.Address]*
 	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
 	// based on the eip phase, we're passing wether the root touch-delete accounts.
 	receipt := types.NewReceipt(root, failed, *usedGas)
+	receipt.Type = tx.Type()
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = gas
 	// if the transaction created a contract, store the creation address in the receipt.
 	if msg.To() == nil {
 		receipt.ContractAddress = crypto.CreateAddress(vmenv.Context.Origin, tx.Nonce())
 	}
+	receipt.FeePayer = msg.FeePayer()
 	// Set the receipt logs and create a bloom for filtering
 	receipt.Logs = statedb.GetLogs(tx.Hash())
 	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	if failed {
+		receipt.Failure = &types.TxFailure{TxHash: tx.Hash(), Code: types.TxFailureVMError, Message: st.VMError().Error()}
+		if st.VMError() == vm.ErrExecutionReverted {
+			receipt.Failure.Code, receipt.Failure.Data = types.TxFailureReverted, common.CopyBytes(ret)
+		}
+	}
 	if balanceFee != nil && failed {
 		state.PayFeeWithTRC21TxFail(statedb, msg.From(), *tx.To())
+		// A revert keeps its code, the revert data is what callers decode
+		if receipt.Failure.Code != types.TxFailureReverted {
+			receipt.Failure.Code = types.TxFailureTRC21Fee
+		}
 	}
 	return receipt, gas, err, balanceFee != nil
 }
@@ -463,6 +476,7 @@ func ApplySignTransaction(config *params.ChainConfig, statedb *state.StateDB, he
 	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
 	// based on the eip phase, we're passing wether the root touch-delete accounts.
 	receipt := types.NewReceipt(root, false, *usedGas)
+	receipt.Type = tx.Type()
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = 0
 	// if the transaction created a contract, store the creation address in the receipt.
@@ -487,6 +501,7 @@ func ApplyEmptyTransaction(config *params.ChainConfig, statedb *state.StateDB, h
 	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
 	// based on the eip phase, we're passing wether the root touch-delete accounts.
 	receipt := types.NewReceipt(root, false, *usedGas)
+	receipt.Type = tx.Type()
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = 0
 	// if the transaction created a contract, store the creation address in the receipt.
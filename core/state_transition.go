@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/core/vm"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/params"
@@ -60,6 +61,7 @@ type StateTransition struct {
 	data       []byte
 	state      vm.StateDB
 	evm        *vm.EVM
+	vmerr      error
 }
 
 // Message represents a message sent to a contract.
@@ -75,11 +77,14 @@ type Message interface {
 	Nonce() uint64
 	CheckNonce() bool
 	Data() []byte
+	AccessList() types.AccessList
 	BalanceTokenFee() *big.Int
+	FeePayer() *common.Address
 }
 
-// IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error) {
+// IntrinsicGas computes the 'intrinsic gas' for a message with the given data
+// and access list.
+func IntrinsicGas(data []byte, accessList types.AccessList, contractCreation, homestead bool) (uint64, error) {
 	// Set the starting gas for the raw transaction
 	var gas uint64
 	if contractCreation && homestead {
@@ -108,6 +113,10 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error)
 		}
 		gas += z * params.TxDataZeroGas
 	}
+	if accessList != nil {
+		gas += uint64(len(accessList)) * params.TxAccessListAddressGas
+		gas += uint64(accessList.StorageKeys()) * params.TxAccessListStorageKeyGas
+	}
 	return gas, nil
 }
 
@@ -147,6 +156,15 @@ func (st *StateTransition) balanceTokenFee() *big.Int {
 	return st.msg.BalanceTokenFee()
 }
 
+// gasPayer returns the account buying the gas in TOMO: the fee payer of
+// sponsored transactions, the sender otherwise.
+func (st *StateTransition) gasPayer() common.Address {
+	if payer := st.msg.FeePayer(); payer != nil {
+		return *payer
+	}
+	return st.from().Address()
+}
+
 func (st *StateTransition) to() vm.AccountRef {
 	if st.msg == nil {
 		return vm.AccountRef{}
@@ -176,11 +194,11 @@ func (st *StateTransition) buyGas() error {
 	var (
 		state           = st.state
 		balanceTokenFee = st.balanceTokenFee()
-		from            = st.from()
+		payer           = st.gasPayer()
 	)
 	mgval := new(big.Int).Mul(new(big.Int).SetUint64(st.msg.Gas()), st.gasPrice)
 	if balanceTokenFee == nil {
-		if state.GetBalance(from.Address()).Cmp(mgval) < 0 {
+		if state.GetBalance(payer).Cmp(mgval) < 0 {
 			return errInsufficientBalanceForGas
 		}
 	} else if balanceTokenFee.Cmp(mgval) < 0 {
@@ -193,7 +211,7 @@ func (st *StateTransition) buyGas() error {
 
 	st.initialGas = st.msg.Gas()
 	if balanceTokenFee == nil {
-		state.SubBalance(from.Address(), mgval)
+		state.SubBalance(payer, mgval)
 	}
 	return nil
 }
@@ -228,13 +246,16 @@ func (st *StateTransition) TransitionDb(owner common.Address) (ret []byte, usedG
 	contractCreation := msg.To() == nil
 
 	// Pay intrinsic gas
-	gas, err := IntrinsicGas(st.data, contractCreation, homestead)
+	gas, err := IntrinsicGas(st.data, msg.AccessList(), contractCreation, homestead)
 	if err != nil {
 		return nil, 0, false, err
 	}
 	if err = st.useGas(gas); err != nil {
 		return nil, 0, false, err
 	}
+	if rules := st.evm.ChainConfig().Rules(st.evm.BlockNumber); rules.IsTIPAccessList {
+		st.state.PrepareAccessList(msg.From(), msg.To(), vm.ActivePrecompiles(rules), msg.AccessList())
+	}
 
 	var (
 		evm = st.evm
@@ -257,6 +278,7 @@ func (st *StateTransition) TransitionDb(owner common.Address) (ret []byte, usedG
 		ret, st.gas, vmerr = evm.Call(sender, st.to().Address(), st.data, st.gas, st.value)
 		contractAction = "contract call"
 	}
+	st.vmerr = vmerr
 	if vmerr != nil {
 		log.Debug("VM returned with error", "action", contractAction, "contract address", st.to().Address(), "gas", st.gas, "gasPrice", st.gasPrice, "nonce", nonce, "err", vmerr)
 		// The only possible consensus-error would be if there wasn't
@@ -279,6 +301,12 @@ func (st *StateTransition) TransitionDb(owner common.Address) (ret []byte, usedG
 	return ret, st.gasUsed(), vmerr != nil, err
 }
 
+// VMError returns the error the EVM execution of the message failed with, if
+// any. Unlike the error returned by TransitionDb, it is not a consensus error.
+func (st *StateTransition) VMError() error {
+	return st.vmerr
+}
+
 func (st *StateTransition) refundGas() {
 	// Apply refund counter, capped to half of the used gas.
 	refund := st.gasUsed() / 2
@@ -289,10 +317,9 @@ func (st *StateTransition) refundGas() {
 
 	balanceTokenFee := st.balanceTokenFee()
 	if balanceTokenFee == nil {
-		from := st.from()
 		// Return ETH for remaining gas, exchanged at the original rate.
 		remaining := new(big.Int).Mul(new(big.Int).SetUint64(st.gas), st.gasPrice)
-		st.state.AddBalance(from.Address(), remaining)
+		st.state.AddBalance(st.gasPayer(), remaining)
 	}
 	// Also return remaining gas to the block gas counter so it is
 	// available for the next transaction.
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that the gas of a sponsored transaction is bought from and refunded to
+// the fee payer, leaving the sender charged with the value only.
+func TestSponsoredTransition(t *testing.T) {
+	config := *params.TestChainConfig
+	config.TIPSponsoredTxBlock = big.NewInt(0)
+
+	var (
+		key, _      = crypto.ToECDSA(common.LeftPadBytes([]byte{0x01}, 32))
+		payerKey, _ = crypto.ToECDSA(common.LeftPadBytes([]byte{0x02}, 32))
+		sender      = crypto.PubkeyToAddress(key.PublicKey)
+		payer       = crypto.PubkeyToAddress(payerKey.PublicKey)
+		to          = common.Address{0xaa}
+		funds       = big.NewInt(params.Ether)
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.AddBalance(sender, big.NewInt(100))
+	statedb.AddBalance(payer, funds)
+
+	signer := types.MakeSigner(&config, big.NewInt(1))
+	tx := types.NewSponsoredTransaction(config.ChainId, 0, &to, big.NewInt(100), 50000, big.NewInt(2), nil, nil, 50000)
+	tx, _ = types.SignTx(tx, signer, key)
+	tx, _ = types.SignPayer(tx, signer, payerKey)
+
+	msg, err := tx.AsMessage(signer, nil, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to convert to message: %v", err)
+	}
+	context := vm.Context{
+		CanTransfer: CanTransfer,
+		Transfer:    Transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		Origin:      sender,
+		GasPrice:    msg.GasPrice(),
+		GasLimit:    1000000,
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(0),
+		Difficulty:  big.NewInt(0),
+	}
+	evm := vm.NewEVM(context, statedb, nil, &config, vm.Config{})
+	_, gas, failed, err := ApplyMessage(evm, msg, new(GasPool).AddGas(1000000), common.Address{})
+	if err != nil || failed {
+		t.Fatalf("transition failed: err %v, failed %v", err, failed)
+	}
+	if balance := statedb.GetBalance(sender); balance.Sign() != 0 {
+		t.Errorf("sender balance mismatch: have %v, want 0", balance)
+	}
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(gas), big.NewInt(2))
+	if balance, want := statedb.GetBalance(payer), new(big.Int).Sub(funds, fee); balance.Cmp(want) != 0 {
+		t.Errorf("payer balance mismatch: have %v, want %v", balance, want)
+	}
+	if balance := statedb.GetBalance(to); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want 100", balance)
+	}
+	if nonce := statedb.GetNonce(sender); nonce != 1 {
+		t.Errorf("sender nonce mismatch: have %d, want 1", nonce)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/contracts/tomox/contract"
 	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/core/vm"
 	"github.com/tomochain/tomochain/log"
 	"math/big"
@@ -36,20 +37,50 @@ const (
 	getDecimalFunction = "decimals"
 )
 
+// Machine readable codes of token validation failures.
+const (
+	TokenErrInvalidABI     = "TOKEN_INVALID_ABI"
+	TokenErrBalanceSlot    = "TOKEN_INVALID_BALANCE_SLOT"
+	TokenErrMinFeeSlot     = "TOKEN_INVALID_MINFEE_SLOT"
+	TokenErrInvalidDecimal = "TOKEN_INVALID_DECIMAL"
+)
+
+// TokenValidationError is returned if a token applied to TomoX or TomoZ does
+// not follow the TRC21 storage layout.
+type TokenValidationError struct {
+	Code  string         // Machine readable failure code
+	Token common.Address // Token which failed the validation
+	msg   string
+}
+
+func newTokenValidationError(code string, token common.Address, format string, args ...interface{}) error {
+	return &TokenValidationError{Code: code, Token: token, msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *TokenValidationError) Error() string { return e.msg }
+
+// ErrorData returns the machine readable details of the failure, the RPC
+// server sends them as the data of the error.
+func (e *TokenValidationError) ErrorData() interface{} {
+	return map[string]interface{}{"code": e.Code, "token": e.Token}
+}
+
 // callmsg implements core.Message to allow passing it as a transaction simulator.
 type callmsg struct {
 	ethereum.CallMsg
 }
 
-func (m callmsg) From() common.Address      { return m.CallMsg.From }
-func (m callmsg) Nonce() uint64             { return 0 }
-func (m callmsg) CheckNonce() bool          { return false }
-func (m callmsg) To() *common.Address       { return m.CallMsg.To }
-func (m callmsg) GasPrice() *big.Int        { return m.CallMsg.GasPrice }
-func (m callmsg) Gas() uint64               { return m.CallMsg.Gas }
-func (m callmsg) Value() *big.Int           { return m.CallMsg.Value }
-func (m callmsg) Data() []byte              { return m.CallMsg.Data }
-func (m callmsg) BalanceTokenFee() *big.Int { return m.CallMsg.BalanceTokenFee }
+func (m callmsg) From() common.Address         { return m.CallMsg.From }
+func (m callmsg) Nonce() uint64                { return 0 }
+func (m callmsg) CheckNonce() bool             { return false }
+func (m callmsg) To() *common.Address          { return m.CallMsg.To }
+func (m callmsg) GasPrice() *big.Int           { return m.CallMsg.GasPrice }
+func (m callmsg) Gas() uint64                  { return m.CallMsg.Gas }
+func (m callmsg) Value() *big.Int              { return m.CallMsg.Value }
+func (m callmsg) Data() []byte                 { return m.CallMsg.Data }
+func (m callmsg) AccessList() types.AccessList { return m.CallMsg.AccessList }
+func (m callmsg) BalanceTokenFee() *big.Int    { return m.CallMsg.BalanceTokenFee }
+func (m callmsg) FeePayer() *common.Address    { return nil }
 
 type SimulatedBackend interface {
 	CallContractWithState(call ethereum.CallMsg, chain consensus.ChainContext, statedb *state.StateDB) ([]byte, error)
@@ -85,7 +116,7 @@ func RunContract(chain consensus.ChainContext, statedb *state.StateDB, contractA
 	return unpackResult, nil
 }
 
-//FIXME: please use copyState for this function
+// FIXME: please use copyState for this function
 // CallContractWithState executes a contract call at the given state.
 func CallContractWithState(call ethereum.CallMsg, chain consensus.ChainContext, statedb *state.StateDB) ([]byte, error) {
 	// Ensure message is initialized properly.
@@ -128,7 +159,7 @@ func ValidateTomoXApplyTransaction(chain consensus.ChainContext, blockNumber *bi
 	}
 	contractABI, err := GetTokenAbi(contract.TRC21ABI)
 	if err != nil {
-		return fmt.Errorf("ValidateTomoXApplyTransaction: cannot parse ABI. Err: %v", err)
+		return newTokenValidationError(TokenErrInvalidABI, tokenAddr, "ValidateTomoXApplyTransaction: cannot parse ABI. Err: %v", err)
 	}
 	if err := ValidateBalanceSlot(chain, copyState, tokenAddr, contractABI); err != nil {
 		return err
@@ -150,7 +181,7 @@ func ValidateTomoZApplyTransaction(chain consensus.ChainContext, blockNumber *bi
 	}
 	contractABI, err := GetTokenAbi(contract.TRC21ABI)
 	if err != nil {
-		return fmt.Errorf("ValidateTomoZApplyTransaction: cannot parse ABI. Err: %v", err)
+		return newTokenValidationError(TokenErrInvalidABI, tokenAddr, "ValidateTomoZApplyTransaction: cannot parse ABI. Err: %v", err)
 	}
 	// verify balance slot
 	if err := ValidateBalanceSlot(chain, copyState, tokenAddr, contractABI); err != nil {
@@ -177,15 +208,15 @@ func ValidateBalanceSlot(chain consensus.ChainContext, copyState *state.StateDB,
 	result, err := RunContract(chain, copyState, tokenAddr, contractABI, balanceOfFunction, addr)
 
 	if err != nil || result == nil {
-		return fmt.Errorf("cannot get balance at slot %v . Token: %s . Err: %v", state.SlotTRC21Token["balances"], tokenAddr.Hex(), err)
+		return newTokenValidationError(TokenErrBalanceSlot, tokenAddr, "cannot get balance at slot %v . Token: %s . Err: %v", state.SlotTRC21Token["balances"], tokenAddr.Hex(), err)
 	}
 	balance, ok := result.(*big.Int)
 	if !ok {
-		return fmt.Errorf("invalid balance at slot %v . Token: %s . GotBalance: %v . ResultType: %T", state.SlotTRC21Token["balances"], tokenAddr.Hex(), result, result)
+		return newTokenValidationError(TokenErrBalanceSlot, tokenAddr, "invalid balance at slot %v . Token: %s . GotBalance: %v . ResultType: %T", state.SlotTRC21Token["balances"], tokenAddr.Hex(), result, result)
 	}
 	if balance.Cmp(randBalance) != 0 {
 		log.Debug("invalid balance slot", "balance_set_at_slot_0", randBalance, "balance_get_from_abi", balance)
-		return fmt.Errorf("invalid balance slot. Token: %s", tokenAddr.Hex())
+		return newTokenValidationError(TokenErrBalanceSlot, tokenAddr, "invalid balance slot. Token: %s", tokenAddr.Hex())
 	}
 	return nil
 }
@@ -197,15 +228,15 @@ func ValidateMinFeeSlot(chain consensus.ChainContext, copyState *state.StateDB,
 
 	result, err := RunContract(chain, copyState, tokenAddr, contractABI, minFeeFunction)
 	if err != nil || result == nil {
-		return fmt.Errorf("cannot get minFee at slot %v . Token: %s. Err: %v", state.SlotTRC21Token["minFee"], tokenAddr.Hex(), err)
+		return newTokenValidationError(TokenErrMinFeeSlot, tokenAddr, "cannot get minFee at slot %v . Token: %s. Err: %v", state.SlotTRC21Token["minFee"], tokenAddr.Hex(), err)
 	}
 	minFee, ok := result.(*big.Int)
 	if !ok {
-		return fmt.Errorf("invalid minFee at slot %v . Token: %s . GotMinFee: %v . ResultType: %T", state.SlotTRC21Token["minFee"], tokenAddr.Hex(), result, result)
+		return newTokenValidationError(TokenErrMinFeeSlot, tokenAddr, "invalid minFee at slot %v . Token: %s . GotMinFee: %v . ResultType: %T", state.SlotTRC21Token["minFee"], tokenAddr.Hex(), result, result)
 	}
 	if minFee.Cmp(randomValue) != 0 {
 		log.Debug("invalid minFee slot", "minFee_set_at_slot_1", randomValue, "minFee_get_from_abi", minFee)
-		return fmt.Errorf("invalid minFee slot. Token: %s", tokenAddr.Hex())
+		return newTokenValidationError(TokenErrMinFeeSlot, tokenAddr, "invalid minFee slot. Token: %s", tokenAddr.Hex())
 	}
 	return nil
 }
@@ -213,7 +244,7 @@ func ValidateMinFeeSlot(chain consensus.ChainContext, copyState *state.StateDB,
 func ValidateTokenDecimal(chain consensus.ChainContext, copyState *state.StateDB, tokenAddr common.Address, contractABI *abi.ABI) error {
 	result, err := RunContract(chain, copyState, tokenAddr, contractABI, getDecimalFunction)
 	if err != nil || result == nil {
-		return fmt.Errorf("cannot get token decimal. Token: %s . Err: %v", tokenAddr.Hex(), err)
+		return newTokenValidationError(TokenErrInvalidDecimal, tokenAddr, "cannot get token decimal. Token: %s . Err: %v", tokenAddr.Hex(), err)
 	}
 	return nil
 }
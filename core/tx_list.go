@@ -301,7 +301,7 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64, trc21Issuers map[co
 	// Filter out all the transactions above the account's funds
 	removed := l.txs.Filter(func(tx *types.Transaction) bool {
 		maximum := costLimit
-		if tx.To() != nil {
+		if tx.To() != nil && tx.Type() != types.SponsoredTxType {
 			if feeCapacity, ok := trc21Issuers[*tx.To()]; ok {
 				return new(big.Int).Add(costLimit, feeCapacity).Cmp(tx.TRC21Cost()) < 0 || tx.Gas() > gasLimit
 			}
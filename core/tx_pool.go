@@ -63,6 +63,10 @@ var (
 	// is higher than the balance of the user's account.
 	ErrInsufficientFunds = errors.New("insufficient funds for gas * price + value")
 
+	// ErrTxTypeNotSupported is returned if a transaction type is not enabled
+	// yet at the current head.
+	ErrTxTypeNotSupported = types.ErrTxTypeNotSupported
+
 	// ErrIntrinsicGas is returned if the transaction is specified to use less gas
 	// than required to start the invocation.
 	ErrIntrinsicGas = errors.New("intrinsic gas too low")
@@ -87,6 +91,14 @@ var (
 	ErrDuplicateSpecialTransaction = errors.New("duplicate a special transaction")
 
 	ErrMinDeploySMC = errors.New("smart contract creation cost is under allowance")
+
+	// ErrInvalidPayer is returned if the fee payer signature of a sponsored
+	// transaction is invalid.
+	ErrInvalidPayer = errors.New("invalid fee payer")
+
+	// ErrInsufficientPayerFunds is returned if the fee payer of a sponsored
+	// transaction can't afford gas * price.
+	ErrInsufficientPayerFunds = errors.New("insufficient fee payer funds for gas * price")
 )
 
 var (
@@ -158,6 +170,8 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	SponsorAllowList common.Address // Contract vetting the fee payers of sponsored transactions, none if zero
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -231,6 +245,8 @@ type TxPool struct {
 	wg sync.WaitGroup // for shutdown sync
 
 	homestead        bool
+	accessList       bool // Whether typed access list transactions are accepted
+	sponsored        bool // Whether sponsored transactions are accepted
 	IsSigner         func(address common.Address) bool
 	trc21FeeCapacity map[common.Address]*big.Int
 }
@@ -246,7 +262,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		config:           config,
 		chainconfig:      chainconfig,
 		chain:            chain,
-		signer:           types.NewEIP155Signer(chainconfig.ChainId),
+		signer:           types.LatestSigner(chainconfig),
 		pending:          make(map[common.Address]*txList),
 		queue:            make(map[common.Address]*txList),
 		beats:            make(map[common.Address]time.Time),
@@ -434,6 +450,9 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.trc21FeeCapacity = state.GetTRC21FeeCapacityFromStateWithCache(newHead.Root, statedb)
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
+	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
+	pool.accessList = pool.chainconfig.IsTIPAccessList(next)
+	pool.sponsored = pool.chainconfig.IsTIPSponsoredTx(next)
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -595,6 +614,20 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		return fmt.Errorf("Reject transaction with receiver in black-list: %v", tx.To().Hex())
 	}
 
+	// Accept typed transactions only once the fork enabling them is reached
+	switch tx.Type() {
+	case types.LegacyTxType:
+	case types.AccessListTxType:
+		if !pool.accessList {
+			return ErrTxTypeNotSupported
+		}
+	case types.SponsoredTxType:
+		if !pool.sponsored {
+			return ErrTxTypeNotSupported
+		}
+	default:
+		return ErrTxTypeNotSupported
+	}
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > 32*1024 {
 		return ErrOversizedData
@@ -634,7 +667,24 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	minGasPrice := common.MinGasPrice
 	feeCapacity := big.NewInt(0)
 
-	if tx.To() != nil {
+	// The fee payer of a sponsored transaction must afford gas * price, the
+	// sender only the value
+	var payer common.Address
+	if tx.Type() == types.SponsoredTxType {
+		if payer, err = types.Payer(pool.signer, tx); err != nil {
+			if err == types.ErrPayerGasExceeded {
+				return err
+			}
+			return ErrInvalidPayer
+		}
+		fee := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+		if payer == from {
+			cost = new(big.Int).Add(cost, fee)
+		} else if pool.currentState.GetBalance(payer).Cmp(fee) < 0 {
+			return ErrInsufficientPayerFunds
+		}
+	}
+	if tx.To() != nil && tx.Type() != types.SponsoredTxType {
 		if value, ok := pool.trc21FeeCapacity[*tx.To()]; ok {
 			feeCapacity = value
 			if !state.ValidateTRC21Tx(pool.pendingState.StateDB, from, *tx.To(), tx.Data()) {
@@ -649,7 +699,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	}
 
 	if tx.To() == nil || (tx.To() != nil && !tx.IsSpecialTransaction()) {
-		intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead)
+		intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, pool.homestead)
 		if err != nil {
 			return err
 		}
@@ -676,6 +726,13 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		}
 	*/
 
+	// Consult the sponsor allow-list, if the node is configured with one
+	if tx.Type() == types.SponsoredTxType && pool.config.SponsorAllowList != (common.Address{}) {
+		copyState := pool.currentState.Copy()
+		if err := ValidateSponsor(pool.chain, copyState, pool.config.SponsorAllowList, payer, from, tx.To()); err != nil {
+			return err
+		}
+	}
 	// validate minFee slot for TomoZ
 	if tx.IsTomoZApplyTransaction() {
 		copyState := pool.currentState.Copy()
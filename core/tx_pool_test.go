@@ -1797,3 +1797,54 @@ func benchmarkPoolBatchInsert(b *testing.B, size int) {
 		pool.AddRemotes(batch)
 	}
 }
+
+// sponsoredTransaction creates a sponsored transaction signed by the sender and
+// co-signed by the fee payer.
+func sponsoredTransaction(nonce uint64, gaslimit, payerGas uint64, key, payerKey *ecdsa.PrivateKey) *types.Transaction {
+	signer := types.NewTIPSponsoredSigner(params.TestChainConfig.ChainId)
+	tx := types.NewSponsoredTransaction(params.TestChainConfig.ChainId, nonce, &common.Address{}, big.NewInt(100), gaslimit, big.NewInt(common.DefaultMinGasPrice), nil, nil, payerGas)
+	tx, _ = types.SignTx(tx, signer, key)
+	tx, _ = types.SignPayer(tx, signer, payerKey)
+	return tx
+}
+
+// Tests that sponsored transactions are only accepted once the fork is reached
+// and that the fee payer, rather than the sender, has to afford the gas.
+func TestSponsoredTransactions(t *testing.T) {
+	t.Parallel()
+
+	config := *params.TestChainConfig
+	config.TIPSponsoredTxBlock = big.NewInt(0)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+	pool := NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	payerKey, _ := crypto.GenerateKey()
+	from, payer := crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(payerKey.PublicKey)
+
+	// The sender only needs the value, the fee payer the gas
+	pool.currentState.AddBalance(from, big.NewInt(100))
+	if err := pool.AddRemote(sponsoredTransaction(0, 21000, 21000, key, payerKey)); err != ErrInsufficientPayerFunds {
+		t.Errorf("unfunded payer error mismatch: have %v, want %v", err, ErrInsufficientPayerFunds)
+	}
+	pool.currentState.AddBalance(payer, new(big.Int).Mul(big.NewInt(21000), big.NewInt(common.DefaultMinGasPrice)))
+	if err := pool.AddRemote(sponsoredTransaction(0, 21000, 20000, key, payerKey)); err != types.ErrPayerGasExceeded {
+		t.Errorf("payer gas error mismatch: have %v, want %v", err, types.ErrPayerGasExceeded)
+	}
+	if err := pool.AddRemote(sponsoredTransaction(0, 21000, 21000, key, payerKey)); err != nil {
+		t.Fatalf("failed to add sponsored transaction: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Errorf("pending transactions mismatch: have %d, want 1", pending)
+	}
+	// Before the fork the type is unknown
+	legacy := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer legacy.Stop()
+
+	if err := legacy.AddRemote(sponsoredTransaction(1, 21000, 21000, key, payerKey)); err != ErrTxTypeNotSupported {
+		t.Errorf("pre-fork error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
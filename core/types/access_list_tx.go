@@ -0,0 +1,123 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// Transaction types, the first byte of the EIP-2718 envelope. Legacy transactions
+// are not enveloped and keep their original RLP list encoding.
+const (
+	LegacyTxType     = 0x00
+	AccessListTxType = 0x01
+	SponsoredTxType  = 0x7e
+)
+
+// AccessList is an EIP-2930 access list.
+type AccessList []AccessTuple
+
+// AccessTuple is the element type of an access list.
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// StorageKeys returns the total number of storage keys in the access list.
+func (al AccessList) StorageKeys() int {
+	sum := 0
+	for _, tuple := range al {
+		sum += len(tuple.StorageKeys)
+	}
+	return sum
+}
+
+// accessListTxRLP is the consensus encoding of an access list transaction,
+// following the type byte of the envelope.
+type accessListTxRLP struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList AccessList
+	V, R, S    *big.Int
+}
+
+func (d *txdata) toAccessListRLP() *accessListTxRLP {
+	return &accessListTxRLP{
+		ChainID:    d.ChainID,
+		Nonce:      d.AccountNonce,
+		GasPrice:   d.Price,
+		Gas:        d.GasLimit,
+		To:         d.Recipient,
+		Value:      d.Amount,
+		Data:       d.Payload,
+		AccessList: d.AccessList,
+		V:          d.V,
+		R:          d.R,
+		S:          d.S,
+	}
+}
+
+func (enc *accessListTxRLP) txdata() txdata {
+	return txdata{
+		AccountNonce: enc.Nonce,
+		Price:        enc.GasPrice,
+		GasLimit:     enc.Gas,
+		Recipient:    enc.To,
+		Amount:       enc.Value,
+		Payload:      enc.Data,
+		V:            enc.V,
+		R:            enc.R,
+		S:            enc.S,
+		Type:         AccessListTxType,
+		ChainID:      enc.ChainID,
+		AccessList:   enc.AccessList,
+	}
+}
+
+// NewAccessListTransaction creates an unsigned EIP-2930 transaction. A nil
+// recipient creates a contract.
+func NewAccessListTransaction(chainID *big.Int, nonce uint64, to *common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, accessList AccessList) *Transaction {
+	tx := newTransaction(nonce, to, amount, gasLimit, gasPrice, data)
+	tx.data.Type = AccessListTxType
+	tx.data.ChainID = new(big.Int)
+	if chainID != nil {
+		tx.data.ChainID.Set(chainID)
+	}
+	tx.data.AccessList = copyAccessList(accessList)
+	return tx
+}
+
+func copyAccessList(al AccessList) AccessList {
+	if al == nil {
+		return nil
+	}
+	cpy := make(AccessList, len(al))
+	for i, tuple := range al {
+		cpy[i] = AccessTuple{
+			Address:     tuple.Address,
+			StorageKeys: append([]common.Hash(nil), tuple.StorageKeys...),
+		}
+	}
+	return cpy
+}
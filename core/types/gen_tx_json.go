@@ -24,6 +24,13 @@ func (t txdata) MarshalJSON() ([]byte, error) {
 		V            *hexutil.Big    `json:"v" gencodec:"required"`
 		R            *hexutil.Big    `json:"r" gencodec:"required"`
 		S            *hexutil.Big    `json:"s" gencodec:"required"`
+		Type         hexutil.Uint64  `json:"type"                 rlp:"-"`
+		ChainID      *hexutil.Big    `json:"chainId,omitempty"    rlp:"-"`
+		AccessList   AccessList      `json:"accessList,omitempty" rlp:"-"`
+		PayerGas     hexutil.Uint64  `json:"payerGas,omitempty" rlp:"-"`
+		PayerV       *hexutil.Big    `json:"payerV,omitempty"   rlp:"-"`
+		PayerR       *hexutil.Big    `json:"payerR,omitempty"   rlp:"-"`
+		PayerS       *hexutil.Big    `json:"payerS,omitempty"   rlp:"-"`
 		Hash         *common.Hash    `json:"hash" rlp:"-"`
 	}
 	var enc txdata
@@ -36,6 +43,13 @@ func (t txdata) MarshalJSON() ([]byte, error) {
 	enc.V = (*hexutil.Big)(t.V)
 	enc.R = (*hexutil.Big)(t.R)
 	enc.S = (*hexutil.Big)(t.S)
+	enc.Type = hexutil.Uint64(t.Type)
+	enc.ChainID = (*hexutil.Big)(t.ChainID)
+	enc.AccessList = t.AccessList
+	enc.PayerGas = hexutil.Uint64(t.PayerGas)
+	enc.PayerV = (*hexutil.Big)(t.PayerV)
+	enc.PayerR = (*hexutil.Big)(t.PayerR)
+	enc.PayerS = (*hexutil.Big)(t.PayerS)
 	enc.Hash = t.Hash
 	return json.Marshal(&enc)
 }
@@ -51,6 +65,13 @@ func (t *txdata) UnmarshalJSON(input []byte) error {
 		V            *hexutil.Big    `json:"v" gencodec:"required"`
 		R            *hexutil.Big    `json:"r" gencodec:"required"`
 		S            *hexutil.Big    `json:"s" gencodec:"required"`
+		Type         *hexutil.Uint64 `json:"type"                 rlp:"-"`
+		ChainID      *hexutil.Big    `json:"chainId,omitempty"    rlp:"-"`
+		AccessList   *AccessList     `json:"accessList,omitempty" rlp:"-"`
+		PayerGas     *hexutil.Uint64 `json:"payerGas,omitempty" rlp:"-"`
+		PayerV       *hexutil.Big    `json:"payerV,omitempty"   rlp:"-"`
+		PayerR       *hexutil.Big    `json:"payerR,omitempty"   rlp:"-"`
+		PayerS       *hexutil.Big    `json:"payerS,omitempty"   rlp:"-"`
 		Hash         *common.Hash    `json:"hash" rlp:"-"`
 	}
 	var dec txdata
@@ -92,6 +113,27 @@ func (t *txdata) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 's' for txdata")
 	}
 	t.S = (*big.Int)(dec.S)
+	if dec.Type != nil {
+		t.Type = uint8(*dec.Type)
+	}
+	if dec.ChainID != nil {
+		t.ChainID = (*big.Int)(dec.ChainID)
+	}
+	if dec.AccessList != nil {
+		t.AccessList = *dec.AccessList
+	}
+	if dec.PayerGas != nil {
+		t.PayerGas = uint64(*dec.PayerGas)
+	}
+	if dec.PayerV != nil {
+		t.PayerV = (*big.Int)(dec.PayerV)
+	}
+	if dec.PayerR != nil {
+		t.PayerR = (*big.Int)(dec.PayerR)
+	}
+	if dec.PayerS != nil {
+		t.PayerS = (*big.Int)(dec.PayerS)
+	}
 	if dec.Hash != nil {
 		t.Hash = dec.Hash
 	}
@@ -25,6 +25,7 @@ import (
 	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/crypto/sha3"
 	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/params"
 )
 
 // LendingSigner interface for lending signer transaction
@@ -86,13 +87,25 @@ func LendingSignTx(tx *LendingTransaction, s LendingSigner, prv *ecdsa.PrivateKe
 	return tx.WithSignature(s, sig)
 }
 
-//LendingTxSigner signer
-type LendingTxSigner struct{}
+//LendingTxSigner signer. The zero value hashes lending transactions the way
+// they were signed before any fork extending the signed fields.
+type LendingTxSigner struct {
+	partialRepay        bool // Whether the quantity of repayments is signed
+	selfTradePrevention bool // Whether the self-trade prevention mode is signed
+}
+
+// MakeLendingSigner returns the lending signer of the given block number.
+func MakeLendingSigner(config *params.ChainConfig, blockNumber *big.Int) LendingTxSigner {
+	return LendingTxSigner{
+		partialRepay:        config.IsTIPTomoXLendingPartialRepay(blockNumber),
+		selfTradePrevention: config.IsTIPTomoXSelfTradePrevention(blockNumber),
+	}
+}
 
 // Equal compare two signer
 func (lendingsign LendingTxSigner) Equal(s2 LendingSigner) bool {
-	_, ok := s2.(LendingSigner)
-	return ok
+	other, ok := s2.(LendingTxSigner)
+	return ok && other == lendingsign
 }
 
 //SignatureValues returns signature values. This signature needs to be in the [R || S || V] format where V is 0 or 1.
@@ -135,6 +148,9 @@ func (lendingsign LendingTxSigner) LendingCreateHash(tx *LendingTransaction) com
 		}
 		sha.Write(common.BigToHash(big.NewInt(autoTopUp)).Bytes())
 	}
+	if mode := tx.SelfTradePrevention(); lendingsign.selfTradePrevention && mode != "" {
+		sha.Write([]byte(mode))
+	}
 	return common.BytesToHash(sha.Sum(nil))
 }
 
@@ -151,7 +167,8 @@ func (lendingsign LendingTxSigner) LendingCancelHash(tx *LendingTransaction) com
 	return common.BytesToHash(sha.Sum(nil))
 }
 
-// LendingRepayHash hash of cancelled lending transaction
+// LendingRepayHash hash of repay lending transaction, covering the repaid
+// quantity since partial repayments are allowed
 func (lendingsign LendingTxSigner) LendingRepayHash(tx *LendingTransaction) common.Hash {
 	sha := sha3.NewKeccak256()
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
@@ -161,11 +178,14 @@ func (lendingsign LendingTxSigner) LendingRepayHash(tx *LendingTransaction) comm
 	sha.Write(tx.LendingToken().Bytes())
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
+	if lendingsign.partialRepay {
+		sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	}
 	sha.Write([]byte(tx.Type()))
 	return common.BytesToHash(sha.Sum(nil))
 }
 
-// LendingTopUpHash hash of cancelled lending transaction
+// LendingTopUpHash hash of topup or auction bid lending transaction
 func (lendingsign LendingTxSigner) LendingTopUpHash(tx *LendingTransaction) common.Hash {
 	sha := sha3.NewKeccak256()
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
@@ -180,6 +200,37 @@ func (lendingsign LendingTxSigner) LendingTopUpHash(tx *LendingTransaction) comm
 	return common.BytesToHash(sha.Sum(nil))
 }
 
+// LendingAccountHash hash of a pool or margin account lending transaction
+func (lendingsign LendingTxSigner) LendingAccountHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(tx.CollateralToken().Bytes())
+	sha.Write(common.BigToHash(tx.Quantity()).Bytes())
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
+// LendingRolloverHash hash of a rollover lending transaction
+func (lendingsign LendingTxSigner) LendingRolloverHash(tx *LendingTransaction) common.Hash {
+	sha := sha3.NewKeccak256()
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	sha.Write([]byte(tx.Status()))
+	sha.Write(tx.RelayerAddress().Bytes())
+	sha.Write(tx.UserAddress().Bytes())
+	sha.Write(tx.LendingToken().Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Term()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.LendingTradeId()))).Bytes())
+	sha.Write(common.BigToHash(big.NewInt(int64(tx.Interest()))).Bytes())
+	sha.Write([]byte(tx.Side()))
+	sha.Write([]byte(tx.ExtraData()))
+	sha.Write([]byte(tx.Type()))
+	return common.BytesToHash(sha.Sum(nil))
+}
+
 // Hash returns the hash to be signed by the sender.
 // It does not uniquely identify the transaction.
 func (lendingsign LendingTxSigner) Hash(tx *LendingTransaction) common.Hash {
@@ -189,12 +240,18 @@ func (lendingsign LendingTxSigner) Hash(tx *LendingTransaction) common.Hash {
 	if tx.IsCreatedLending() {
 		return lendingsign.LendingCreateHash(tx)
 	}
-	if tx.IsTopupLending() {
+	if tx.IsTopupLending() || tx.IsAuctionBidLending() {
 		return lendingsign.LendingTopUpHash(tx)
 	}
 	if tx.IsRepayLending() {
 		return lendingsign.LendingRepayHash(tx)
 	}
+	if tx.IsPoolLending() || tx.IsMarginLending() {
+		return lendingsign.LendingAccountHash(tx)
+	}
+	if tx.IsRolloverLending() {
+		return lendingsign.LendingRolloverHash(tx)
+	}
 	return common.Hash{}
 }
 
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// Tests that the quantity of a repayment is covered by its signature once
+// partial repayments are allowed, but not before.
+func TestLendingRepaySigning(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	newRepay := func(quantity int64) *LendingTransaction {
+		return NewLendingTransaction(1, big.NewInt(quantity), 0, 30, common.HexToAddress("0x01"), addr,
+			common.HexToAddress("0x02"), common.Address{}, false, "NEW", "", LendingRePay, common.Hash{}, 0, 7, "")
+	}
+	var (
+		legacy = LendingTxSigner{}
+		signer = MakeLendingSigner(params.TestChainConfig, common.TIPTomoXLendingPartialRepayBlock)
+	)
+	if signer.Equal(legacy) {
+		t.Fatalf("partial repay signer equal to the legacy one")
+	}
+	signed, err := LendingSignTx(newRepay(100), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from, err := LendingSender(signer, signed); err != nil || from != addr {
+		t.Fatalf("sender mismatch: have %x, want %x, err %v", from, addr, err)
+	}
+	// Replay the signature on a repayment of a different quantity
+	altered := newRepay(200).ImportSignature(signed.Signature())
+	if from, err := LendingSender(signer, altered); err == nil && from == addr {
+		t.Errorf("altered quantity recovered the signer")
+	}
+	if legacy.Hash(newRepay(100)) != legacy.Hash(newRepay(200)) {
+		t.Errorf("legacy repay hash covers the quantity")
+	}
+}
+
+// Tests that the self-trade prevention mode of a lending item is covered by its
+// signature once self-trade prevention is enabled, but not before.
+func TestLendingSelfTradePreventionSigning(t *testing.T) {
+	newItem := func(mode string) *LendingTransaction {
+		tx := NewLendingTransaction(1, big.NewInt(100), 10, 30, common.HexToAddress("0x01"), common.HexToAddress("0x02"),
+			common.HexToAddress("0x03"), common.Address{}, false, "NEW", LendingSideInvest, "LO", common.Hash{}, 0, 0, "")
+		tx.SetSelfTradePrevention(mode)
+		return tx
+	}
+	var (
+		legacy = MakeLendingSigner(params.TestChainConfig, new(big.Int).Sub(common.TIPTomoXSelfTradePreventionBlock, common.Big1))
+		signer = MakeLendingSigner(params.TestChainConfig, common.TIPTomoXSelfTradePreventionBlock)
+	)
+	if legacy.Hash(newItem("")) != legacy.Hash(newItem(SelfTradeCancelNewest)) {
+		t.Errorf("self-trade prevention signed before the fork")
+	}
+	if signer.Hash(newItem("")) != legacy.Hash(newItem("")) {
+		t.Errorf("hash of items without self-trade prevention changed at the fork")
+	}
+	if signer.Hash(newItem(SelfTradeCancelNewest)) == signer.Hash(newItem(SelfTradeCancelOldest)) {
+		t.Errorf("self-trade prevention not signed after the fork")
+	}
+	// the mode is left out of the encoding of items without one
+	plain, _ := rlp.EncodeToBytes(newItem(""))
+	withMode, _ := rlp.EncodeToBytes(newItem(SelfTradeCancelBoth))
+	if len(withMode) <= len(plain) {
+		t.Errorf("self-trade prevention mode not encoded")
+	}
+	decoded := new(LendingTransaction)
+	if err := rlp.DecodeBytes(plain, decoded); err != nil || decoded.SelfTradePrevention() != "" {
+		t.Errorf("item without mode decoding mismatch: mode %q, err %v", decoded.SelfTradePrevention(), err)
+	}
+	if err := rlp.DecodeBytes(withMode, decoded); err != nil || decoded.SelfTradePrevention() != SelfTradeCancelBoth {
+		t.Errorf("item with mode decoding mismatch: mode %q, err %v", decoded.SelfTradePrevention(), err)
+	}
+}
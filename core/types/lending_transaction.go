@@ -47,6 +47,14 @@ const (
 	LendingSideInvest          = "INVEST"
 	LendingRePay               = "REPAY"
 	LendingTopup               = "TOPUP"
+	LendingPoolDeposit         = "POOL_DEPOSIT"
+	LendingPoolWithdraw        = "POOL_WITHDRAW"
+	LendingPoolBorrow          = "POOL_BORROW"
+	LendingPoolRepay           = "POOL_REPAY"
+	LendingMarginDeposit       = "MARGIN_DEPOSIT"
+	LendingMarginWithdraw      = "MARGIN_WITHDRAW"
+	LendingRollover            = "ROLLOVER"
+	LendingAuctionBid          = "AUCTION_BID"
 )
 
 // LendingTransaction lending transaction
@@ -82,6 +90,10 @@ type lendingtxdata struct {
 
 	// This is only used when marshaling to JSON.
 	Hash common.Hash `json:"hash"`
+
+	// Optional self-trade prevention mode, left out of the encoding of items
+	// without one
+	SelfTradePrevention []string `json:"selfTradePrevention,omitempty" rlp:"tail"`
 }
 
 // IsCreatedLending check if tx is cancelled transaction
@@ -116,6 +128,40 @@ func (tx *LendingTransaction) IsTopupLending() bool {
 	return false
 }
 
+// IsPoolLending check if tx is a variable-rate pool lending transaction
+func (tx *LendingTransaction) IsPoolLending() bool {
+	switch tx.Type() {
+	case LendingPoolDeposit, LendingPoolWithdraw, LendingPoolBorrow, LendingPoolRepay:
+		return true
+	}
+	return false
+}
+
+// IsMarginLending check if tx is a margin account lending transaction
+func (tx *LendingTransaction) IsMarginLending() bool {
+	switch tx.Type() {
+	case LendingMarginDeposit, LendingMarginWithdraw:
+		return true
+	}
+	return false
+}
+
+// IsRolloverLending check if tx is a rollover of a lending trade
+func (tx *LendingTransaction) IsRolloverLending() bool {
+	if tx.Type() == LendingRollover {
+		return true
+	}
+	return false
+}
+
+// IsAuctionBidLending check if tx is a bid in the liquidation auction of a lending trade
+func (tx *LendingTransaction) IsAuctionBidLending() bool {
+	if tx.Type() == LendingAuctionBid {
+		return true
+	}
+	return false
+}
+
 // IsMoTypeLending check if tx type is MO lending
 func (tx *LendingTransaction) IsMoTypeLending() bool {
 	if tx.Type() == LendingTypeMo {
@@ -190,6 +236,24 @@ func (tx *LendingTransaction) Type() string { return tx.data.Type }
 // Type return extraData of lending transaction
 func (tx *LendingTransaction) ExtraData() string { return tx.data.ExtraData }
 
+// SelfTradePrevention returns the self-trade prevention mode of the lending
+// item, it is empty if the item may match the resting items of its user.
+func (tx *LendingTransaction) SelfTradePrevention() string {
+	if len(tx.data.SelfTradePrevention) == 0 {
+		return ""
+	}
+	return tx.data.SelfTradePrevention[0]
+}
+
+// SetSelfTradePrevention sets the self-trade prevention mode of the lending item.
+func (tx *LendingTransaction) SetSelfTradePrevention(mode string) {
+	if mode == "" {
+		tx.data.SelfTradePrevention = nil
+	} else {
+		tx.data.SelfTradePrevention = []string{mode}
+	}
+}
+
 // Signature return signature of lending transaction
 func (tx *LendingTransaction) Signature() (V, R, S *big.Int) { return tx.data.V, tx.data.R, tx.data.S }
 
@@ -205,21 +269,6 @@ func (tx *LendingTransaction) LendingTradeId() uint64 { return tx.data.LendingTr
 // SetLendingHash set hash of lending transaction hash
 func (tx *LendingTransaction) SetLendingHash(h common.Hash) { tx.data.Hash = h }
 
-// From get transaction from
-func (tx *LendingTransaction) From() *common.Address {
-	if tx.data.V != nil {
-		signer := LendingTxSigner{}
-		if f, err := LendingSender(signer, tx); err != nil {
-			return nil
-		} else {
-			return &f
-		}
-
-	}
-	return nil
-
-}
-
 // WithSignature returns a new transaction with the given signature.
 // This signature needs to be formatted as described in the yellow paper (v+27).
 func (tx *LendingTransaction) WithSignature(signer LendingSigner, sig []byte) (*LendingTransaction, error) {
@@ -25,6 +25,7 @@ import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/crypto/sha3"
+	"github.com/tomochain/tomochain/params"
 )
 
 // OrderSigner interface for order transaction
@@ -86,13 +87,23 @@ func OrderSignTx(tx *OrderTransaction, s OrderSigner, prv *ecdsa.PrivateKey) (*O
 	return tx.WithSignature(s, sig)
 }
 
-//OrderTxSigner signer
-type OrderTxSigner struct{}
+//OrderTxSigner signer. The zero value hashes orders the way they were signed
+// before self-trade prevention was enabled.
+type OrderTxSigner struct {
+	selfTradePrevention bool // Whether the self-trade prevention mode is signed
+}
+
+// MakeOrderSigner returns the order signer of the given block number.
+func MakeOrderSigner(config *params.ChainConfig, blockNumber *big.Int) OrderTxSigner {
+	return OrderTxSigner{
+		selfTradePrevention: config.IsTIPTomoXSelfTradePrevention(blockNumber),
+	}
+}
 
 // Equal compare two signer
 func (ordersign OrderTxSigner) Equal(s2 OrderSigner) bool {
-	_, ok := s2.(OrderSigner)
-	return ok
+	other, ok := s2.(OrderTxSigner)
+	return ok && other == ordersign
 }
 
 //SignatureValues returns signature values. This signature needs to be in the [R || S || V] format where V is 0 or 1.
@@ -123,6 +134,9 @@ func (ordersign OrderTxSigner) OrderCreateHash(tx *OrderTransaction) common.Hash
 	sha.Write([]byte(tx.Status()))
 	sha.Write([]byte(tx.Type()))
 	sha.Write(common.BigToHash(big.NewInt(int64(tx.Nonce()))).Bytes())
+	if mode := tx.SelfTradePrevention(); ordersign.selfTradePrevention && mode != "" {
+		sha.Write([]byte(mode))
+	}
 	return common.BytesToHash(sha.Sum(nil))
 }
 
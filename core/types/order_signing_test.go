@@ -0,0 +1,51 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that the self-trade prevention mode of an order is covered by its
+// signature once self-trade prevention is enabled, but not before.
+func TestOrderSelfTradePreventionSigning(t *testing.T) {
+	newOrder := func(mode string) *OrderTransaction {
+		tx := NewOrderTransaction(1, big.NewInt(100), big.NewInt(20), common.HexToAddress("0x01"), common.HexToAddress("0x02"),
+			common.HexToAddress("0x03"), common.HexToAddress("0x04"), OrderStatusNew, "BUY", OrderTypeLo, common.Hash{}, 0)
+		tx.SetSelfTradePrevention(mode)
+		return tx
+	}
+	var (
+		legacy = MakeOrderSigner(params.TestChainConfig, new(big.Int).Sub(common.TIPTomoXSelfTradePreventionBlock, common.Big1))
+		signer = MakeOrderSigner(params.TestChainConfig, common.TIPTomoXSelfTradePreventionBlock)
+	)
+	if legacy.Hash(newOrder("")) != legacy.Hash(newOrder(SelfTradeCancelNewest)) {
+		t.Errorf("self-trade prevention signed before the fork")
+	}
+	if signer.Hash(newOrder("")) != legacy.Hash(newOrder("")) {
+		t.Errorf("hash of orders without self-trade prevention changed at the fork")
+	}
+	if signer.Hash(newOrder(SelfTradeCancelNewest)) == signer.Hash(newOrder(SelfTradeCancelOldest)) {
+		t.Errorf("self-trade prevention not signed after the fork")
+	}
+	if legacy.Equal(signer) {
+		t.Errorf("signers of different forks are equal")
+	}
+}
@@ -73,6 +73,10 @@ type ordertxdata struct {
 
 	// This is only used when marshaling to JSON.
 	Hash common.Hash `json:"hash"`
+
+	// Optional self-trade prevention mode, left out of the encoding of orders
+	// without one
+	SelfTradePrevention []string `json:"selfTradePrevention,omitempty" rlp:"tail"`
 }
 
 // IsCancelledOrder check if tx is cancelled transaction
@@ -138,17 +142,21 @@ func (tx *OrderTransaction) EncodedSide() *big.Int {
 }
 func (tx *OrderTransaction) SetOrderHash(h common.Hash) { tx.data.Hash = h }
 
-// From get transaction from
-func (tx *OrderTransaction) From() *common.Address {
-	if tx.data.V != nil {
-		signer := OrderTxSigner{}
-		if f, err := OrderSender(signer, tx); err != nil {
-			return nil
-		} else {
-			return &f
-		}
+// SelfTradePrevention returns the self-trade prevention mode of the order, it is
+// empty if the order may match the resting orders of its user.
+func (tx *OrderTransaction) SelfTradePrevention() string {
+	if len(tx.data.SelfTradePrevention) == 0 {
+		return ""
+	}
+	return tx.data.SelfTradePrevention[0]
+}
+
+// SetSelfTradePrevention sets the self-trade prevention mode of the order.
+func (tx *OrderTransaction) SetSelfTradePrevention(mode string) {
+	if mode == "" {
+		tx.data.SelfTradePrevention = nil
 	} else {
-		return nil
+		tx.data.SelfTradePrevention = []string{mode}
 	}
 }
 
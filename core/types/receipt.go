@@ -36,6 +36,8 @@ import (
 var (
 	receiptStatusFailedRLP     = []byte{}
 	receiptStatusSuccessfulRLP = []byte{0x01}
+
+	errEmptyTypedReceipt = errors.New("empty typed receipt bytes")
 )
 
 const (
@@ -49,6 +51,7 @@ const (
 // Receipt represents the results of a transaction.
 type Receipt struct {
 	// Consensus fields
+	Type              uint8  `json:"type,omitempty"`
 	PostState         []byte `json:"root"`
 	Status            uint64 `json:"status"`
 	CumulativeGasUsed uint64 `json:"cumulativeGasUsed" gencodec:"required"`
@@ -65,9 +68,33 @@ type Receipt struct {
 	BlockHash        common.Hash `json:"blockHash,omitempty"`
 	BlockNumber      *big.Int    `json:"blockNumber,omitempty"`
 	TransactionIndex uint        `json:"transactionIndex"`
+
+	// FeePayer is the account which paid the fee of a sponsored transaction,
+	// derived from the transaction itself.
+	FeePayer *common.Address `json:"feePayer,omitempty"`
+
+	// Failure describes why the transaction failed. It is only known to nodes
+	// which executed the transaction and is stored apart from the receipt.
+	Failure *TxFailure `json:"-"`
+}
+
+// Machine readable codes of transaction failures.
+const (
+	TxFailureReverted = "EXECUTION_REVERTED" // EVM execution hit a REVERT
+	TxFailureVMError  = "EXECUTION_FAILED"   // EVM execution aborted with an error
+	TxFailureTRC21Fee = "TRC21_TX_FAIL"      // Execution failed without a revert, fee paid with the TRC21 token
+)
+
+// TxFailure is the diagnostic information about a failed transaction.
+type TxFailure struct {
+	TxHash  common.Hash // Hash of the failed transaction
+	Code    string      // Machine readable failure code
+	Message string      // Error the EVM execution failed with
+	Data    []byte      // Data returned by the execution, the revert reason if any
 }
 
 type receiptMarshaling struct {
+	Type              hexutil.Uint64
 	PostState         hexutil.Bytes
 	Status            hexutil.Uint64
 	CumulativeGasUsed hexutil.Uint64
@@ -114,17 +141,67 @@ func NewReceipt(root []byte, failed bool, cumulativeGasUsed uint64) *Receipt {
 
 // EncodeRLP implements rlp.Encoder, and flattens the consensus fields of a receipt
 // into an RLP stream. If no post state is present, byzantium fork is assumed.
+// Receipts of typed transactions are encoded as an RLP string holding the
+// EIP-2718 envelope.
 func (r *Receipt) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs})
+	data := &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
+	if r.Type == LegacyTxType {
+		return rlp.Encode(w, data)
+	}
+	enc, err := r.encodeTyped(data)
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, enc)
+}
+
+// encodeTyped returns the EIP-2718 envelope of a typed receipt.
+func (r *Receipt) encodeTyped(data *receiptRLP) ([]byte, error) {
+	if r.Type != AccessListTxType {
+		return nil, ErrTxTypeNotSupported
+	}
+	payload, err := rlp.EncodeToBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{r.Type}, payload...), nil
 }
 
 // DecodeRLP implements rlp.Decoder, and loads the consensus fields of a receipt
 // from an RLP stream.
 func (r *Receipt) DecodeRLP(s *rlp.Stream) error {
-	var dec receiptRLP
-	if err := s.Decode(&dec); err != nil {
+	kind, _, err := s.Kind()
+	switch {
+	case err != nil:
 		return err
+	case kind == rlp.List:
+		var dec receiptRLP
+		if err := s.Decode(&dec); err != nil {
+			return err
+		}
+		r.Type = LegacyTxType
+		return r.setFromRLP(dec)
+	default:
+		b, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 {
+			return errEmptyTypedReceipt
+		}
+		if b[0] != AccessListTxType {
+			return ErrTxTypeNotSupported
+		}
+		var dec receiptRLP
+		if err := rlp.DecodeBytes(b[1:], &dec); err != nil {
+			return err
+		}
+		r.Type = b[0]
+		return r.setFromRLP(dec)
 	}
+}
+
+func (r *Receipt) setFromRLP(dec receiptRLP) error {
 	if err := r.setStatus(dec.PostStateOrStatus); err != nil {
 		return err
 	}
@@ -260,6 +337,7 @@ func (rs Receipts) DeriveFields(config *params.ChainConfig, hash common.Hash, nu
 	}
 	for i := 0; i < len(rs); i++ {
 		// The transaction type and hash can be retrieved from the transaction itself
+		rs[i].Type = txs[i].Type()
 		rs[i].TxHash = txs[i].Hash()
 
 		// block location fields
@@ -275,6 +353,13 @@ func (rs Receipts) DeriveFields(config *params.ChainConfig, hash common.Hash, nu
 		} else {
 			rs[i].ContractAddress = common.Address{}
 		}
+		// The fee payer of sponsored transactions is recovered from its signature
+		rs[i].FeePayer = nil
+		if txs[i].Type() == SponsoredTxType {
+			if payer, err := Payer(signer, txs[i]); err == nil {
+				rs[i].FeePayer = &payer
+			}
+		}
 
 		// The used gas can be calculated based on previous r
 		if i == 0 {
@@ -296,8 +381,16 @@ func (rs Receipts) DeriveFields(config *params.ChainConfig, hash common.Hash, nu
 	return nil
 }
 
-// GetRlp returns the RLP encoding of one receipt from the list..
+// GetRlp returns the consensus encoding of one receipt from the list, which is
+// the EIP-2718 envelope for receipts of typed transactions.
 func (r Receipts) GetRlp(i int) []byte {
+	if r[i].Type != LegacyTxType {
+		bytes, err := r[i].encodeTyped(&receiptRLP{r[i].statusEncoding(), r[i].CumulativeGasUsed, r[i].Bloom, r[i].Logs})
+		if err != nil {
+			panic(err)
+		}
+		return bytes
+	}
 	bytes, err := rlp.EncodeToBytes(r[i])
 	if err != nil {
 		panic(err)
@@ -0,0 +1,18 @@
+package types
+
+// Self-trade prevention modes of an order, deciding what happens when it would
+// match a resting order of the same user.
+const (
+	SelfTradeCancelNewest       = "CN" // cancel the incoming order
+	SelfTradeCancelOldest       = "CO" // cancel the resting order
+	SelfTradeCancelBoth         = "CB" // cancel both orders
+	SelfTradeDecrementAndCancel = "DC" // decrease both orders by the smaller quantity, cancel the one exhausted
+)
+
+// ValidSelfTradePrevention lists the supported self-trade prevention modes.
+var ValidSelfTradePrevention = map[string]bool{
+	SelfTradeCancelNewest:       true,
+	SelfTradeCancelOldest:       true,
+	SelfTradeCancelBoth:         true,
+	SelfTradeDecrementAndCancel: true,
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// sponsoredTxRLP is the consensus encoding of a sponsored transaction,
+// following the type byte of the envelope. The sender signs the fields up to
+// the access list, the fee payer signs over those and the sender, committing to
+// pay for at most PayerGas gas.
+type sponsoredTxRLP struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int
+	Gas        uint64
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList AccessList
+	V, R, S    *big.Int
+
+	PayerGas               uint64
+	PayerV, PayerR, PayerS *big.Int
+}
+
+func (d *txdata) toSponsoredRLP() *sponsoredTxRLP {
+	return &sponsoredTxRLP{
+		ChainID:    d.ChainID,
+		Nonce:      d.AccountNonce,
+		GasPrice:   d.Price,
+		Gas:        d.GasLimit,
+		To:         d.Recipient,
+		Value:      d.Amount,
+		Data:       d.Payload,
+		AccessList: d.AccessList,
+		V:          d.V,
+		R:          d.R,
+		S:          d.S,
+		PayerGas:   d.PayerGas,
+		PayerV:     d.PayerV,
+		PayerR:     d.PayerR,
+		PayerS:     d.PayerS,
+	}
+}
+
+func (enc *sponsoredTxRLP) txdata() txdata {
+	return txdata{
+		AccountNonce: enc.Nonce,
+		Price:        enc.GasPrice,
+		GasLimit:     enc.Gas,
+		Recipient:    enc.To,
+		Amount:       enc.Value,
+		Payload:      enc.Data,
+		V:            enc.V,
+		R:            enc.R,
+		S:            enc.S,
+		Type:         SponsoredTxType,
+		ChainID:      enc.ChainID,
+		AccessList:   enc.AccessList,
+		PayerGas:     enc.PayerGas,
+		PayerV:       enc.PayerV,
+		PayerR:       enc.PayerR,
+		PayerS:       enc.PayerS,
+	}
+}
+
+// NewSponsoredTransaction creates a transaction whose fee is paid by a separate
+// fee payer, signed by neither party. The fee payer covers at most payerGas gas,
+// which must not be below gasLimit. A nil recipient creates a contract.
+func NewSponsoredTransaction(chainID *big.Int, nonce uint64, to *common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, accessList AccessList, payerGas uint64) *Transaction {
+	tx := NewAccessListTransaction(chainID, nonce, to, amount, gasLimit, gasPrice, data, accessList)
+	tx.data.Type = SponsoredTxType
+	tx.data.PayerGas = payerGas
+	tx.data.PayerV, tx.data.PayerR, tx.data.PayerS = new(big.Int), new(big.Int), new(big.Int)
+	return tx
+}
+
+// PayerGas returns the gas the fee payer of a sponsored transaction committed to
+// pay for, zero for other transactions.
+func (tx *Transaction) PayerGas() uint64 { return tx.data.PayerGas }
+
+// RawPayerSignatureValues returns the fee payer signature of a sponsored
+// transaction, nils for other transactions.
+func (tx *Transaction) RawPayerSignatureValues() (*big.Int, *big.Int, *big.Int) {
+	return tx.data.PayerV, tx.data.PayerR, tx.data.PayerS
+}
+
+// WithPayerSignature returns a new transaction with the given fee payer
+// signature. The sender signature must already be present, the fee payer signs
+// over it.
+func (tx *Transaction) WithPayerSignature(signer Signer, sig []byte) (*Transaction, error) {
+	s, ok := signer.(TIPSponsoredSigner)
+	if !ok || tx.Type() != SponsoredTxType {
+		return nil, ErrTxTypeNotSupported
+	}
+	r, ss, v, err := s.PayerSignatureValues(tx, sig)
+	if err != nil {
+		return nil, err
+	}
+	cpy := &Transaction{data: tx.data}
+	cpy.data.PayerR, cpy.data.PayerS, cpy.data.PayerV = r, ss, v
+	return cpy, nil
+}
@@ -34,6 +34,9 @@ import (
 
 var (
 	ErrInvalidSig               = errors.New("invalid transaction v, r, s values")
+	ErrTxTypeNotSupported       = errors.New("transaction type not supported")
+	errEmptyTypedTx             = errors.New("empty typed transaction bytes")
+	ErrPayerGasExceeded         = errors.New("gas limit exceeds the gas covered by the fee payer")
 	errNoSigner                 = errors.New("missing signing methods")
 	skipNonceDestinationAddress = map[string]bool{
 		common.TomoXAddr:                         true,
@@ -52,12 +55,25 @@ func deriveSigner(V *big.Int) Signer {
 	}
 }
 
+// deriveSigner makes a *best* guess about which signer to use for tx.
+func (tx *Transaction) deriveSigner() Signer {
+	switch tx.data.Type {
+	case LegacyTxType:
+		return deriveSigner(tx.data.V)
+	case SponsoredTxType:
+		return NewTIPSponsoredSigner(tx.data.ChainID)
+	default:
+		return NewTIPAccessListSigner(tx.data.ChainID)
+	}
+}
+
 type Transaction struct {
 	data txdata
 	// caches
-	hash atomic.Value
-	size atomic.Value
-	from atomic.Value
+	hash  atomic.Value
+	size  atomic.Value
+	from  atomic.Value
+	payer atomic.Value
 }
 
 type txdata struct {
@@ -73,6 +89,17 @@ type txdata struct {
 	R *big.Int `json:"r" gencodec:"required"`
 	S *big.Int `json:"s" gencodec:"required"`
 
+	// Typed transaction fields, they are not part of the legacy encoding.
+	Type       uint8      `json:"type"                 rlp:"-"`
+	ChainID    *big.Int   `json:"chainId,omitempty"    rlp:"-"`
+	AccessList AccessList `json:"accessList,omitempty" rlp:"-"`
+
+	// Fee payer fields of sponsored transactions.
+	PayerGas uint64   `json:"payerGas,omitempty" rlp:"-"`
+	PayerV   *big.Int `json:"payerV,omitempty"   rlp:"-"`
+	PayerR   *big.Int `json:"payerR,omitempty"   rlp:"-"`
+	PayerS   *big.Int `json:"payerS,omitempty"   rlp:"-"`
+
 	// This is only used when marshaling to JSON.
 	Hash *common.Hash `json:"hash" rlp:"-"`
 }
@@ -86,6 +113,12 @@ type txdataMarshaling struct {
 	V            *hexutil.Big
 	R            *hexutil.Big
 	S            *hexutil.Big
+	Type         hexutil.Uint64
+	ChainID      *hexutil.Big
+	PayerGas     hexutil.Uint64
+	PayerV       *hexutil.Big
+	PayerR       *hexutil.Big
+	PayerS       *hexutil.Big
 }
 
 func NewTransaction(nonce uint64, to common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) *Transaction {
@@ -123,14 +156,26 @@ func newTransaction(nonce uint64, to *common.Address, amount *big.Int, gasLimit
 
 // ChainId returns which chain id this transaction was signed for (if at all)
 func (tx *Transaction) ChainId() *big.Int {
+	if tx.data.Type != LegacyTxType {
+		return new(big.Int).Set(tx.data.ChainID)
+	}
 	return deriveChainId(tx.data.V)
 }
 
 // Protected returns whether the transaction is protected from replay protection.
 func (tx *Transaction) Protected() bool {
+	if tx.data.Type != LegacyTxType {
+		return true
+	}
 	return isProtectedV(tx.data.V)
 }
 
+// Type returns the EIP-2718 type of the transaction.
+func (tx *Transaction) Type() uint8 { return tx.data.Type }
+
+// AccessList returns the access list of the transaction, nil for legacy ones.
+func (tx *Transaction) AccessList() AccessList { return copyAccessList(tx.data.AccessList) }
+
 func isProtectedV(V *big.Int) bool {
 	if V.BitLen() <= 8 {
 		v := V.Uint64()
@@ -140,20 +185,120 @@ func isProtectedV(V *big.Int) bool {
 	return true
 }
 
-// EncodeRLP implements rlp.Encoder
+// EncodeRLP implements rlp.Encoder. Legacy transactions are encoded as an RLP
+// list, typed transactions as an RLP string holding the EIP-2718 envelope.
 func (tx *Transaction) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &tx.data)
+	if tx.data.Type == LegacyTxType {
+		return rlp.Encode(w, &tx.data)
+	}
+	enc, err := tx.encodeTyped()
+	if err != nil {
+		return err
+	}
+	return rlp.Encode(w, enc)
 }
 
 // DecodeRLP implements rlp.Decoder
 func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
-	_, size, _ := s.Kind()
-	err := s.Decode(&tx.data)
-	if err == nil {
+	kind, size, err := s.Kind()
+	switch {
+	case err != nil:
+		return err
+	case kind == rlp.List:
+		var data txdata
+		if err := s.Decode(&data); err != nil {
+			return err
+		}
+		tx.data = data
 		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+		return nil
+	default:
+		b, err := s.Bytes()
+		if err != nil {
+			return err
+		}
+		data, err := decodeTyped(b)
+		if err != nil {
+			return err
+		}
+		tx.data = data
+		tx.size.Store(common.StorageSize(len(b)))
+		return nil
+	}
+}
+
+// MarshalBinary returns the canonical encoding of the transaction: the RLP list
+// for legacy transactions and the EIP-2718 envelope for typed ones. This is the
+// format of raw transactions and of the entries of the transaction trie.
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	if tx.data.Type == LegacyTxType {
+		return rlp.EncodeToBytes(&tx.data)
+	}
+	return tx.encodeTyped()
+}
+
+// UnmarshalBinary decodes the canonical encoding of a transaction.
+func (tx *Transaction) UnmarshalBinary(b []byte) error {
+	if len(b) > 0 && b[0] > 0x7f {
+		// A legacy transaction starts with an RLP list prefix
+		var data txdata
+		if err := rlp.DecodeBytes(b, &data); err != nil {
+			return err
+		}
+		tx.data = data
+		tx.size.Store(common.StorageSize(len(b)))
+		return nil
+	}
+	data, err := decodeTyped(b)
+	if err != nil {
+		return err
 	}
+	tx.data = data
+	tx.size.Store(common.StorageSize(len(b)))
+	return nil
+}
 
-	return err
+// encodeTyped returns the EIP-2718 envelope of a typed transaction.
+func (tx *Transaction) encodeTyped() ([]byte, error) {
+	switch tx.data.Type {
+	case AccessListTxType:
+		payload, err := rlp.EncodeToBytes(tx.data.toAccessListRLP())
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{tx.data.Type}, payload...), nil
+	case SponsoredTxType:
+		payload, err := rlp.EncodeToBytes(tx.data.toSponsoredRLP())
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{tx.data.Type}, payload...), nil
+	default:
+		return nil, ErrTxTypeNotSupported
+	}
+}
+
+// decodeTyped decodes an EIP-2718 envelope.
+func decodeTyped(b []byte) (txdata, error) {
+	if len(b) == 0 {
+		return txdata{}, errEmptyTypedTx
+	}
+	switch b[0] {
+	case AccessListTxType:
+		var dec accessListTxRLP
+		if err := rlp.DecodeBytes(b[1:], &dec); err != nil {
+			return txdata{}, err
+		}
+		return dec.txdata(), nil
+	case SponsoredTxType:
+		var dec sponsoredTxRLP
+		if err := rlp.DecodeBytes(b[1:], &dec); err != nil {
+			return txdata{}, err
+		}
+		return dec.txdata(), nil
+	default:
+		return txdata{}, ErrTxTypeNotSupported
+	}
 }
 
 // MarshalJSON encodes the web3 RPC transaction format.
@@ -171,10 +316,35 @@ func (tx *Transaction) UnmarshalJSON(input []byte) error {
 		return err
 	}
 	var V byte
-	if isProtectedV(dec.V) {
+	switch {
+	case dec.Type == SponsoredTxType:
+		if dec.ChainID == nil {
+			return errors.New("missing required field 'chainId' for typed transaction")
+		}
+		if dec.PayerV == nil || dec.PayerR == nil || dec.PayerS == nil {
+			return errors.New("missing fee payer signature for sponsored transaction")
+		}
+		if dec.V.BitLen() > 8 || dec.PayerV.BitLen() > 8 {
+			return ErrInvalidSig
+		}
+		if !crypto.ValidateSignatureValues(byte(dec.PayerV.Uint64()), dec.PayerR, dec.PayerS, false) {
+			return ErrInvalidSig
+		}
+		V = byte(dec.V.Uint64())
+	case dec.Type == AccessListTxType:
+		if dec.ChainID == nil {
+			return errors.New("missing required field 'chainId' for typed transaction")
+		}
+		if dec.V.BitLen() > 8 {
+			return ErrInvalidSig
+		}
+		V = byte(dec.V.Uint64())
+	case dec.Type != LegacyTxType:
+		return ErrTxTypeNotSupported
+	case isProtectedV(dec.V):
 		chainID := deriveChainId(dec.V).Uint64()
 		V = byte(dec.V.Uint64() - 35 - 2*chainID)
-	} else {
+	default:
 		V = byte(dec.V.Uint64() - 27)
 	}
 	if !crypto.ValidateSignatureValues(V, dec.R, dec.S, false) {
@@ -203,7 +373,7 @@ func (tx *Transaction) To() *common.Address {
 
 func (tx *Transaction) From() *common.Address {
 	if tx.data.V != nil {
-		signer := deriveSigner(tx.data.V)
+		signer := tx.deriveSigner()
 		if f, err := Sender(signer, tx); err != nil {
 			return nil
 		} else {
@@ -214,28 +384,42 @@ func (tx *Transaction) From() *common.Address {
 	}
 }
 
-// Hash hashes the RLP encoding of tx.
+// Hash hashes the canonical encoding of tx, the RLP encoding for legacy
+// transactions and the EIP-2718 envelope for typed ones.
 // It uniquely identifies the transaction.
 func (tx *Transaction) Hash() common.Hash {
 	if hash := tx.hash.Load(); hash != nil {
 		return hash.(common.Hash)
 	}
-	v := rlpHash(tx)
+	v := tx.computeHash()
 	tx.hash.Store(v)
 	return v
 }
 
 func (tx *Transaction) CacheHash() {
-	v := rlpHash(tx)
+	v := tx.computeHash()
 	tx.hash.Store(v)
 }
 
+func (tx *Transaction) computeHash() common.Hash {
+	if tx.data.Type == LegacyTxType {
+		return rlpHash(tx)
+	}
+	enc, _ := tx.encodeTyped()
+	return crypto.Keccak256Hash(enc)
+}
+
 // Size returns the true RLP encoded storage size of the transaction, either by
 // encoding and returning it, or returning a previsouly cached value.
 func (tx *Transaction) Size() common.StorageSize {
 	if size := tx.size.Load(); size != nil {
 		return size.(common.StorageSize)
 	}
+	if tx.data.Type != LegacyTxType {
+		enc, _ := tx.encodeTyped()
+		tx.size.Store(common.StorageSize(len(enc)))
+		return common.StorageSize(len(enc))
+	}
 	c := writeCounter(0)
 	rlp.Encode(&c, &tx.data)
 	tx.size.Store(common.StorageSize(c))
@@ -255,11 +439,25 @@ func (tx *Transaction) AsMessage(s Signer, balanceFee *big.Int, number *big.Int)
 		to:              tx.data.Recipient,
 		amount:          tx.data.Amount,
 		data:            tx.data.Payload,
+		accessList:      tx.data.AccessList,
 		checkNonce:      true,
 		balanceTokenFee: balanceFee,
 	}
 	var err error
 	msg.from, err = Sender(s, tx)
+	if err != nil {
+		return msg, err
+	}
+	if tx.data.Type == SponsoredTxType {
+		// The fee payer pays in TOMO, even for transactions to TRC21 tokens
+		payer, err := Payer(s, tx)
+		if err != nil {
+			return msg, err
+		}
+		msg.feePayer = &payer
+		msg.balanceTokenFee = nil
+		return msg, nil
+	}
 	if balanceFee != nil {
 		if number.Cmp(common.TIPTRC21FeeBlock) > 0 {
 			msg.gasPrice = common.TRC21GasPrice
@@ -282,8 +480,13 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	return cpy, nil
 }
 
-// Cost returns amount + gasprice * gaslimit.
+// Cost returns amount + gasprice * gaslimit, the amount the sender has to
+// afford. The fee of sponsored transactions is paid by the fee payer, their
+// cost is the amount only.
 func (tx *Transaction) Cost() *big.Int {
+	if tx.data.Type == SponsoredTxType {
+		return new(big.Int).Set(tx.data.Amount)
+	}
 	total := new(big.Int).Mul(tx.data.Price, new(big.Int).SetUint64(tx.data.GasLimit))
 	total.Add(total, tx.data.Amount)
 	return total
@@ -464,7 +667,7 @@ func (tx *Transaction) String() string {
 	if tx.data.V != nil {
 		// make a best guess about the signer and use that to derive
 		// the sender.
-		signer := deriveSigner(tx.data.V)
+		signer := tx.deriveSigner()
 		if f, err := Sender(signer, tx); err != nil { // derive but don't cache
 			from = "[invalid sender: invalid sig]"
 		} else {
@@ -479,9 +682,10 @@ func (tx *Transaction) String() string {
 	} else {
 		to = fmt.Sprintf("%x", tx.data.Recipient[:])
 	}
-	enc, _ := rlp.EncodeToBytes(&tx.data)
+	enc, _ := tx.MarshalBinary()
 	return fmt.Sprintf(`
 	TX(%x)
+	Type:     %d
 	Contract: %v
 	From:     %s
 	To:       %s
@@ -496,6 +700,7 @@ func (tx *Transaction) String() string {
 	Hex:      %x
 `,
 		tx.Hash(),
+		tx.data.Type,
 		tx.data.Recipient == nil,
 		from,
 		to,
@@ -520,9 +725,10 @@ func (s Transactions) Len() int { return len(s) }
 // Swap swaps the i'th and the j'th element in s.
 func (s Transactions) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 
-// GetRlp implements Rlpable and returns the i'th element of s in rlp.
+// GetRlp implements Rlpable and returns the i'th element of s in its canonical
+// encoding, which is the EIP-2718 envelope for typed transactions.
 func (s Transactions) GetRlp(i int) []byte {
-	enc, _ := rlp.EncodeToBytes(s[i])
+	enc, _ := s[i].MarshalBinary()
 	return enc
 }
 
@@ -563,14 +769,14 @@ type TxByPrice struct {
 func (s TxByPrice) Len() int { return len(s.txs) }
 func (s TxByPrice) Less(i, j int) bool {
 	i_price := s.txs[i].data.Price
-	if s.txs[i].To() != nil {
+	if s.txs[i].To() != nil && s.txs[i].data.Type != SponsoredTxType {
 		if _, ok := s.payersSwap[*s.txs[i].To()]; ok {
 			i_price = common.TRC21GasPrice
 		}
 	}
 
 	j_price := s.txs[j].data.Price
-	if s.txs[j].To() != nil {
+	if s.txs[j].To() != nil && s.txs[j].data.Type != SponsoredTxType {
 		if _, ok := s.payersSwap[*s.txs[j].To()]; ok {
 			j_price = common.TRC21GasPrice
 		}
@@ -686,11 +892,13 @@ type Message struct {
 	gasLimit        uint64
 	gasPrice        *big.Int
 	data            []byte
+	accessList      AccessList
 	checkNonce      bool
 	balanceTokenFee *big.Int
+	feePayer        *common.Address
 }
 
-func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, checkNonce bool, balanceTokenFee *big.Int) Message {
+func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, accessList AccessList, checkNonce bool, balanceTokenFee *big.Int) Message {
 	if balanceTokenFee != nil {
 		gasPrice = common.TRC21GasPrice
 	}
@@ -702,6 +910,7 @@ func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *b
 		gasLimit:        gasLimit,
 		gasPrice:        gasPrice,
 		data:            data,
+		accessList:      accessList,
 		checkNonce:      checkNonce,
 		balanceTokenFee: balanceTokenFee,
 	}
@@ -715,4 +924,9 @@ func (m Message) Value() *big.Int           { return m.amount }
 func (m Message) Gas() uint64               { return m.gasLimit }
 func (m Message) Nonce() uint64             { return m.nonce }
 func (m Message) Data() []byte              { return m.data }
+func (m Message) AccessList() AccessList    { return m.accessList }
 func (m Message) CheckNonce() bool          { return m.checkNonce }
+
+// FeePayer returns the account paying the fee of a sponsored transaction, nil
+// if the sender pays it.
+func (m Message) FeePayer() *common.Address { return m.feePayer }
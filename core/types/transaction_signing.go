@@ -42,6 +42,10 @@ type sigCache struct {
 func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 	var signer Signer
 	switch {
+	case config.IsTIPSponsoredTx(blockNumber):
+		signer = NewTIPSponsoredSigner(config.ChainId)
+	case config.IsTIPAccessList(blockNumber):
+		signer = NewTIPAccessListSigner(config.ChainId)
 	case config.IsEIP155(blockNumber):
 		signer = NewEIP155Signer(config.ChainId)
 	case config.IsHomestead(blockNumber):
@@ -52,6 +56,20 @@ func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 	return signer
 }
 
+// LatestSigner returns the most permissive Signer available for the given
+// chain configuration, accepting every transaction type the chain schedules.
+// Use it where the current block number is unknown, like in the transaction
+// pool; use MakeSigner where it is known.
+func LatestSigner(config *params.ChainConfig) Signer {
+	if config.TIPSponsoredTxBlock != nil {
+		return NewTIPSponsoredSigner(config.ChainId)
+	}
+	if config.TIPAccessListBlock != nil {
+		return NewTIPAccessListSigner(config.ChainId)
+	}
+	return NewEIP155Signer(config.ChainId)
+}
+
 // SignTx signs the transaction using the given signer and private key
 func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
 	h := s.Hash(tx)
@@ -88,6 +106,46 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 	return addr, nil
 }
 
+// SignPayer co-signs a sponsored transaction as its fee payer. The transaction
+// must already be signed by its sender.
+func SignPayer(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	signer, ok := s.(TIPSponsoredSigner)
+	if !ok || tx.Type() != SponsoredTxType {
+		return nil, ErrTxTypeNotSupported
+	}
+	h, err := signer.PayerHash(tx)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(h[:], prv)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithPayerSignature(signer, sig)
+}
+
+// Payer returns the address of the fee payer of a sponsored transaction derived
+// from its payer signature, and an error if the signer doesn't accept sponsored
+// transactions or the signature is invalid. Like Sender, it caches the address.
+func Payer(s Signer, tx *Transaction) (common.Address, error) {
+	signer, ok := s.(TIPSponsoredSigner)
+	if !ok || tx.Type() != SponsoredTxType {
+		return common.Address{}, ErrTxTypeNotSupported
+	}
+	if sc := tx.payer.Load(); sc != nil {
+		sigCache := sc.(sigCache)
+		if sigCache.signer.Equal(signer) {
+			return sigCache.from, nil
+		}
+	}
+	addr, err := signer.Payer(tx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	tx.payer.Store(sigCache{signer: signer, from: addr})
+	return addr, nil
+}
+
 // Signer encapsulates transaction signature handling. Note that this interface is not a
 // stable API and may change at any time to accommodate new protocol rules.
 type Signer interface {
@@ -102,6 +160,169 @@ type Signer interface {
 	Equal(Signer) bool
 }
 
+// TIPAccessListSigner implements Signer for EIP-2930 access list transactions,
+// accepting legacy transactions with the EIP155 rules.
+type TIPAccessListSigner struct{ EIP155Signer }
+
+func NewTIPAccessListSigner(chainId *big.Int) TIPAccessListSigner {
+	return TIPAccessListSigner{NewEIP155Signer(chainId)}
+}
+
+func (s TIPAccessListSigner) Equal(s2 Signer) bool {
+	x, ok := s2.(TIPAccessListSigner)
+	return ok && x.chainId.Cmp(s.chainId) == 0
+}
+
+func (s TIPAccessListSigner) Sender(tx *Transaction) (common.Address, error) {
+	switch tx.Type() {
+	case LegacyTxType:
+		return s.EIP155Signer.Sender(tx)
+	case AccessListTxType:
+	default:
+		return common.Address{}, ErrTxTypeNotSupported
+	}
+	if tx.data.ChainID.Cmp(s.chainId) != 0 {
+		return common.Address{}, ErrInvalidChainId
+	}
+	// Typed transactions carry the plain recovery id (0 or 1) as V
+	V := new(big.Int).Add(tx.data.V, big27)
+	return recoverPlain(s.Hash(tx), tx.data.R, tx.data.S, V, true)
+}
+
+// SignatureValues returns signature values. This signature
+// needs to be in the [R || S || V] format where V is 0 or 1.
+func (s TIPAccessListSigner) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
+	switch tx.Type() {
+	case LegacyTxType:
+		return s.EIP155Signer.SignatureValues(tx, sig)
+	case AccessListTxType:
+	default:
+		return nil, nil, nil, ErrTxTypeNotSupported
+	}
+	if tx.data.ChainID.Sign() != 0 && tx.data.ChainID.Cmp(s.chainId) != 0 {
+		return nil, nil, nil, ErrInvalidChainId
+	}
+	R, S, _, err = HomesteadSigner{}.SignatureValues(tx, sig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	V = big.NewInt(int64(sig[64]))
+	return R, S, V, nil
+}
+
+// Hash returns the hash to be signed by the sender.
+// It does not uniquely identify the transaction.
+func (s TIPAccessListSigner) Hash(tx *Transaction) common.Hash {
+	if tx.Type() == LegacyTxType {
+		return s.EIP155Signer.Hash(tx)
+	}
+	return prefixedRlpHash(tx.Type(), []interface{}{
+		s.chainId,
+		tx.data.AccountNonce,
+		tx.data.Price,
+		tx.data.GasLimit,
+		tx.data.Recipient,
+		tx.data.Amount,
+		tx.data.Payload,
+		tx.data.AccessList,
+	})
+}
+
+// TIPSponsoredSigner implements Signer for sponsored transactions, accepting
+// access list and legacy transactions as well.
+type TIPSponsoredSigner struct{ TIPAccessListSigner }
+
+func NewTIPSponsoredSigner(chainId *big.Int) TIPSponsoredSigner {
+	return TIPSponsoredSigner{NewTIPAccessListSigner(chainId)}
+}
+
+func (s TIPSponsoredSigner) Equal(s2 Signer) bool {
+	x, ok := s2.(TIPSponsoredSigner)
+	return ok && x.chainId.Cmp(s.chainId) == 0
+}
+
+func (s TIPSponsoredSigner) Sender(tx *Transaction) (common.Address, error) {
+	if tx.Type() != SponsoredTxType {
+		return s.TIPAccessListSigner.Sender(tx)
+	}
+	if tx.data.ChainID.Cmp(s.chainId) != 0 {
+		return common.Address{}, ErrInvalidChainId
+	}
+	V := new(big.Int).Add(tx.data.V, big27)
+	return recoverPlain(s.Hash(tx), tx.data.R, tx.data.S, V, true)
+}
+
+func (s TIPSponsoredSigner) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
+	if tx.Type() != SponsoredTxType {
+		return s.TIPAccessListSigner.SignatureValues(tx, sig)
+	}
+	// Sender and fee payer signatures share the same format
+	return s.PayerSignatureValues(tx, sig)
+}
+
+// PayerSignatureValues returns the fee payer signature values of a sponsored
+// transaction, in the same format as the sender ones.
+func (s TIPSponsoredSigner) PayerSignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
+	if tx.data.ChainID.Sign() != 0 && tx.data.ChainID.Cmp(s.chainId) != 0 {
+		return nil, nil, nil, ErrInvalidChainId
+	}
+	R, S, _, err = HomesteadSigner{}.SignatureValues(tx, sig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	V = big.NewInt(int64(sig[64]))
+	return R, S, V, nil
+}
+
+// Hash returns the hash to be signed by the sender, which leaves out the fee
+// payer fields.
+func (s TIPSponsoredSigner) Hash(tx *Transaction) common.Hash {
+	if tx.Type() != SponsoredTxType {
+		return s.TIPAccessListSigner.Hash(tx)
+	}
+	return prefixedRlpHash(tx.Type(), []interface{}{
+		s.chainId,
+		tx.data.AccountNonce,
+		tx.data.Price,
+		tx.data.GasLimit,
+		tx.data.Recipient,
+		tx.data.Amount,
+		tx.data.Payload,
+		tx.data.AccessList,
+	})
+}
+
+// PayerHash returns the hash to be signed by the fee payer. It commits to the
+// sender as well, so the signature can't be attached to the same transaction
+// signed by someone else.
+func (s TIPSponsoredSigner) PayerHash(tx *Transaction) (common.Hash, error) {
+	sender, err := Sender(s, tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return prefixedRlpHash(tx.Type(), []interface{}{
+		s.Hash(tx),
+		sender,
+		tx.data.PayerGas,
+	}), nil
+}
+
+// Payer recovers the fee payer of a sponsored transaction.
+func (s TIPSponsoredSigner) Payer(tx *Transaction) (common.Address, error) {
+	if tx.Type() != SponsoredTxType {
+		return common.Address{}, ErrTxTypeNotSupported
+	}
+	if tx.data.GasLimit > tx.data.PayerGas {
+		return common.Address{}, ErrPayerGasExceeded
+	}
+	h, err := s.PayerHash(tx)
+	if err != nil {
+		return common.Address{}, err
+	}
+	V := new(big.Int).Add(tx.data.PayerV, big27)
+	return recoverPlain(h, tx.data.PayerR, tx.data.PayerS, V, true)
+}
+
 // EIP155Transaction implements Signer using the EIP155 rules.
 type EIP155Signer struct {
 	chainId, chainIdMul *big.Int
@@ -122,9 +343,15 @@ func (s EIP155Signer) Equal(s2 Signer) bool {
 	return ok && eip155.chainId.Cmp(s.chainId) == 0
 }
 
-var big8 = big.NewInt(8)
+var (
+	big8  = big.NewInt(8)
+	big27 = big.NewInt(27)
+)
 
 func (s EIP155Signer) Sender(tx *Transaction) (common.Address, error) {
+	if tx.Type() != LegacyTxType {
+		return common.Address{}, ErrTxTypeNotSupported
+	}
 	if !tx.Protected() {
 		return HomesteadSigner{}.Sender(tx)
 	}
@@ -180,6 +407,9 @@ func (hs HomesteadSigner) SignatureValues(tx *Transaction, sig []byte) (r, s, v
 }
 
 func (hs HomesteadSigner) Sender(tx *Transaction) (common.Address, error) {
+	if tx.Type() != LegacyTxType {
+		return common.Address{}, ErrTxTypeNotSupported
+	}
 	return recoverPlain(hs.Hash(tx), tx.data.R, tx.data.S, tx.data.V, true)
 }
 
@@ -216,6 +446,9 @@ func (fs FrontierSigner) Hash(tx *Transaction) common.Hash {
 }
 
 func (fs FrontierSigner) Sender(tx *Transaction) (common.Address, error) {
+	if tx.Type() != LegacyTxType {
+		return common.Address{}, ErrTxTypeNotSupported
+	}
 	return recoverPlain(fs.Hash(tx), tx.data.R, tx.data.S, tx.data.V, false)
 }
 
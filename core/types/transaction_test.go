@@ -233,3 +233,194 @@ func TestTransactionJSON(t *testing.T) {
 		}
 	}
 }
+
+func signedAccessListTx(t *testing.T) (*Transaction, common.Address) {
+	key, addr := defaultTestKey()
+	to := common.HexToAddress("b94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+	accessList := AccessList{{Address: to, StorageKeys: []common.Hash{{0}, {1}}}}
+	tx := NewAccessListTransaction(big.NewInt(88), 3, &to, big.NewInt(10), 25000, big.NewInt(1), common.FromHex("5544"), accessList)
+	tx, err := SignTx(tx, NewTIPAccessListSigner(big.NewInt(88)), key)
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	return tx, addr
+}
+
+// Tests that access list transactions survive the binary, RLP and JSON
+// encodings and still recover the original sender afterwards.
+func TestAccessListTransactionEncoding(t *testing.T) {
+	tx, addr := signedAccessListTx(t)
+	if tx.Type() != AccessListTxType {
+		t.Fatalf("type mismatch: have %d, want %d", tx.Type(), AccessListTxType)
+	}
+	bin, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("binary encode error: %v", err)
+	}
+	if bin[0] != AccessListTxType {
+		t.Fatalf("envelope type mismatch: have %#x", bin[0])
+	}
+	if tx.Hash() != crypto.Keccak256Hash(bin) {
+		t.Errorf("hash mismatch: have %x, want %x", tx.Hash(), crypto.Keccak256Hash(bin))
+	}
+	// Binary round trip
+	parsed := new(Transaction)
+	if err := parsed.UnmarshalBinary(bin); err != nil {
+		t.Fatalf("binary decode error: %v", err)
+	}
+	if parsed.Hash() != tx.Hash() {
+		t.Errorf("binary round trip hash mismatch: have %x, want %x", parsed.Hash(), tx.Hash())
+	}
+	// RLP round trip, typed transactions are wrapped into an RLP string
+	enc, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatalf("rlp encode error: %v", err)
+	}
+	parsed, err = decodeTx(enc)
+	if err != nil {
+		t.Fatalf("rlp decode error: %v", err)
+	}
+	if parsed.Hash() != tx.Hash() {
+		t.Errorf("rlp round trip hash mismatch: have %x, want %x", parsed.Hash(), tx.Hash())
+	}
+	if parsed.AccessList().StorageKeys() != 2 {
+		t.Errorf("access list mismatch: have %d storage keys, want 2", parsed.AccessList().StorageKeys())
+	}
+	// JSON round trip
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	parsed = new(Transaction)
+	if err := json.Unmarshal(data, parsed); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	if parsed.Hash() != tx.Hash() {
+		t.Errorf("json round trip hash mismatch: have %x, want %x", parsed.Hash(), tx.Hash())
+	}
+	from, err := Sender(NewTIPAccessListSigner(big.NewInt(88)), parsed)
+	if err != nil {
+		t.Fatalf("could not recover sender: %v", err)
+	}
+	if from != addr {
+		t.Errorf("sender mismatch: have %x, want %x", from, addr)
+	}
+}
+
+// Tests that signers predating the access list fork reject typed transactions
+// and that an unknown envelope type is refused.
+func TestAccessListTransactionRejected(t *testing.T) {
+	tx, _ := signedAccessListTx(t)
+	if _, err := Sender(NewEIP155Signer(big.NewInt(88)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("EIP155 signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	if _, err := Sender(NewTIPAccessListSigner(big.NewInt(89)), tx); err == nil {
+		t.Error("expected chain id mismatch error")
+	}
+	if err := new(Transaction).UnmarshalBinary([]byte{0x7f, 0xc0}); err != ErrTxTypeNotSupported {
+		t.Errorf("unknown type error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
+
+func signedSponsoredTx(t *testing.T, senderKey, payerKey *ecdsa.PrivateKey, gas, payerGas uint64) *Transaction {
+	signer := NewTIPSponsoredSigner(big.NewInt(88))
+	to := common.HexToAddress("b94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+	tx := NewSponsoredTransaction(big.NewInt(88), 3, &to, big.NewInt(10), gas, big.NewInt(1), common.FromHex("5544"), nil, payerGas)
+	tx, err := SignTx(tx, signer, senderKey)
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	if tx, err = SignPayer(tx, signer, payerKey); err != nil {
+		t.Fatalf("could not co-sign transaction: %v", err)
+	}
+	return tx
+}
+
+// Tests that sponsored transactions survive the binary and JSON encodings and
+// recover both the sender and the fee payer afterwards.
+func TestSponsoredTransaction(t *testing.T) {
+	senderKey, sender := defaultTestKey()
+	payerKey, _ := crypto.GenerateKey()
+	payer := crypto.PubkeyToAddress(payerKey.PublicKey)
+
+	tx := signedSponsoredTx(t, senderKey, payerKey, 25000, 30000)
+	if tx.Cost().Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("cost mismatch: have %v, want 10", tx.Cost())
+	}
+	bin, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("binary encode error: %v", err)
+	}
+	if bin[0] != SponsoredTxType {
+		t.Fatalf("envelope type mismatch: have %#x", bin[0])
+	}
+	fromBinary := new(Transaction)
+	if err := fromBinary.UnmarshalBinary(bin); err != nil {
+		t.Fatalf("binary decode error: %v", err)
+	}
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	fromJSON := new(Transaction)
+	if err := json.Unmarshal(data, fromJSON); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	signer := NewTIPSponsoredSigner(big.NewInt(88))
+	for i, parsed := range []*Transaction{fromBinary, fromJSON} {
+		if parsed.Hash() != tx.Hash() {
+			t.Errorf("decoding %d: hash mismatch: have %x, want %x", i, parsed.Hash(), tx.Hash())
+		}
+		if from, err := Sender(signer, parsed); err != nil || from != sender {
+			t.Errorf("decoding %d: sender mismatch: have %x (%v), want %x", i, from, err, sender)
+		}
+		if addr, err := Payer(signer, parsed); err != nil || addr != payer {
+			t.Errorf("decoding %d: payer mismatch: have %x (%v), want %x", i, addr, err, payer)
+		}
+		if parsed.PayerGas() != 30000 {
+			t.Errorf("decoding %d: payer gas mismatch: have %d, want 30000", i, parsed.PayerGas())
+		}
+	}
+	msg, err := tx.AsMessage(signer, big.NewInt(1), big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to convert to message: %v", err)
+	}
+	if msg.FeePayer() == nil || *msg.FeePayer() != payer {
+		t.Errorf("message fee payer mismatch: have %v, want %x", msg.FeePayer(), payer)
+	}
+	if msg.BalanceTokenFee() != nil {
+		t.Errorf("sponsored message paying with a TRC21 token")
+	}
+}
+
+// Tests that the fee payer signature is bound to the sender and to the gas the
+// fee payer committed to, and that older signers refuse sponsored transactions.
+func TestSponsoredTransactionRejected(t *testing.T) {
+	senderKey, _ := defaultTestKey()
+	payerKey, _ := crypto.GenerateKey()
+	payer := crypto.PubkeyToAddress(payerKey.PublicKey)
+	signer := NewTIPSponsoredSigner(big.NewInt(88))
+
+	// Re-signing the transaction by someone else must not carry over the payer
+	tx := signedSponsoredTx(t, senderKey, payerKey, 25000, 30000)
+	otherKey, _ := crypto.GenerateKey()
+	hijacked, err := SignTx(tx, signer, otherKey)
+	if err != nil {
+		t.Fatalf("could not re-sign transaction: %v", err)
+	}
+	if addr, err := Payer(signer, hijacked); err == nil && addr == payer {
+		t.Errorf("fee payer signature reused for another sender")
+	}
+	// Gas above the fee payer commitment is refused
+	tx = signedSponsoredTx(t, senderKey, payerKey, 25000, 24999)
+	if _, err := Payer(signer, tx); err != ErrPayerGasExceeded {
+		t.Errorf("payer gas error mismatch: have %v, want %v", err, ErrPayerGasExceeded)
+	}
+	// Signers predating the fork don't know the type
+	if _, err := Sender(NewTIPAccessListSigner(big.NewInt(88)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("access list signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	if _, err := Payer(NewTIPAccessListSigner(big.NewInt(88)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("access list signer payer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
@@ -105,3 +105,42 @@ func TestEIP2200(t *testing.T) {
 		}
 	}
 }
+
+var eip2929Tests = []struct {
+	input   string
+	prepare bool
+	used    uint64
+}{
+	{"0x60005450600054500000", false, 2210}, // cold then warm SLOAD of the same slot
+	{"0x60005450600054500000", true, 210},   // slot already warmed by the access list
+	{"0x60ff3b5060ff315000", false, 2710},   // cold EXTCODESIZE then warm BALANCE
+}
+
+func TestEIP2929(t *testing.T) {
+	for i, tt := range eip2929Tests {
+		address := common.BytesToAddress([]byte("contract"))
+		db := rawdb.NewMemoryDatabase()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+		statedb.CreateAccount(address)
+		statedb.SetCode(address, hexutil.MustDecode(tt.input))
+		statedb.Finalise(true)
+
+		statedb.PrepareAccessList(common.Address{}, &address, nil, nil)
+		if tt.prepare {
+			statedb.AddSlotToAccessList(address, common.Hash{})
+		}
+		vmctx := Context{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		}
+		vmenv := NewEVM(vmctx, statedb, nil, params.AllEthashProtocolChanges, Config{ExtraEips: []int{2929}})
+
+		_, gas, err := vmenv.Call(AccountRef(common.Address{}), address, nil, 100000, new(big.Int))
+		if err != nil {
+			t.Fatalf("test %d: execution failed: %v", i, err)
+		}
+		if used := 100000 - gas; used != tt.used {
+			t.Errorf("test %d: gas used mismatch: have %v, want %v", i, used, tt.used)
+		}
+	}
+}
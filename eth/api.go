@@ -19,12 +19,14 @@ package eth
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"os"
 	"strings"
 
+	"github.com/tomochain/tomochain/accounts"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/common/hexutil"
 	"github.com/tomochain/tomochain/core"
@@ -33,6 +35,7 @@ import (
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/miner"
+	"github.com/tomochain/tomochain/p2p/discover"
 	"github.com/tomochain/tomochain/params"
 	"github.com/tomochain/tomochain/rlp"
 	"github.com/tomochain/tomochain/rpc"
@@ -292,6 +295,37 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// MasternodeRecord signs a record binding the given enode URL to the etherbase,
+// for other masternodes to add to their masternode registry.
+func (api *PrivateAdminAPI) MasternodeRecord(enode string) (*MasternodeRecord, error) {
+	if _, err := discover.ParseNode(enode); err != nil {
+		return nil, fmt.Errorf("invalid enode: %v", err)
+	}
+	etherbase, err := api.eth.Etherbase()
+	if err != nil {
+		return nil, err
+	}
+	account := accounts.Account{Address: etherbase}
+	wallet, err := api.eth.AccountManager().Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := wallet.SignHash(account, masternodeRecordHash(enode))
+	if err != nil {
+		return nil, err
+	}
+	return &MasternodeRecord{Address: etherbase, Enode: enode, Signature: signature}, nil
+}
+
+// MasternodeMesh returns the connectivity to the masternodes of the current
+// epoch maintained by the masternode mesh.
+func (api *PrivateAdminAPI) MasternodeMesh() ([]*MasternodeMeshInfo, error) {
+	if api.eth.mesh == nil {
+		return nil, errors.New("masternode mesh not enabled")
+	}
+	return api.eth.mesh.info(), nil
+}
+
 // PublicDebugAPI is the collection of Ethereum full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -322,7 +356,7 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 	if block == nil {
 		return state.Dump{}, fmt.Errorf("block #%d not found", blockNr)
 	}
-	stateDb, err := api.eth.BlockChain().StateAt(block.Root())
+	stateDb, err := api.eth.BlockChain().StateAtHeader(block.Header())
 	if err != nil {
 		return state.Dump{}, err
 	}
@@ -40,11 +40,13 @@ import (
 	"github.com/tomochain/tomochain/core/vm"
 	"github.com/tomochain/tomochain/eth/downloader"
 	"github.com/tomochain/tomochain/eth/gasprice"
+	"github.com/tomochain/tomochain/eth/privtx"
 	"github.com/tomochain/tomochain/ethclient"
 	"github.com/tomochain/tomochain/ethdb"
 	"github.com/tomochain/tomochain/event"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/rlp"
 	"github.com/tomochain/tomochain/rpc"
 	"github.com/tomochain/tomochain/tomox"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
@@ -107,7 +109,7 @@ func (b *EthApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 	if header == nil || err != nil {
 		return nil, nil, err
 	}
-	stateDb, err := b.eth.BlockChain().StateAt(header.Root)
+	stateDb, err := b.eth.BlockChain().StateAtHeader(header)
 	return stateDb, header, err
 }
 
@@ -177,6 +179,33 @@ func (b *EthApiBackend) SendLendingTx(ctx context.Context, signedTx *types.Lendi
 	return b.eth.lendingPool.AddLocal(signedTx)
 }
 
+// SendPrivateTx hands a transaction to the upcoming block producers only.
+func (b *EthApiBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction, expiry uint64, fallback bool) error {
+	payload, err := signedTx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return b.eth.sendPrivate(ctx, privtx.Transaction, payload, expiry, fallback)
+}
+
+// SendPrivateOrderTx hands an order transaction to the upcoming block producers only.
+func (b *EthApiBackend) SendPrivateOrderTx(ctx context.Context, signedTx *types.OrderTransaction, expiry uint64, fallback bool) error {
+	payload, err := rlp.EncodeToBytes(signedTx)
+	if err != nil {
+		return err
+	}
+	return b.eth.sendPrivate(ctx, privtx.Order, payload, expiry, fallback)
+}
+
+// SendPrivateLendingTx hands a lending transaction to the upcoming block producers only.
+func (b *EthApiBackend) SendPrivateLendingTx(ctx context.Context, signedTx *types.LendingTransaction, expiry uint64, fallback bool) error {
+	payload, err := rlp.EncodeToBytes(signedTx)
+	if err != nil {
+		return err
+	}
+	return b.eth.sendPrivate(ctx, privtx.Lending, payload, expiry, fallback)
+}
+
 func (b *EthApiBackend) GetPoolTransactions() (types.Transactions, error) {
 	pending, err := b.eth.txPool.Pending()
 	if err != nil {
@@ -212,6 +241,31 @@ func (b *EthApiBackend) OrderStats() (pending int, queued int) {
 	return b.eth.txPool.Stats()
 }
 
+// LowDepositRelayers returns the relayers whose new orders are rejected by the
+// order or the lending pool because their deposit is too low.
+func (b *EthApiBackend) LowDepositRelayers() []core.RelayerDepositEvent {
+	return append(b.eth.orderPool.LowDepositRelayers(), b.eth.lendingPool.LowDepositRelayers()...)
+}
+
+// SubscribeRelayerDepositEvent subscribes to the deposit events of both the
+// order and the lending pool.
+func (b *EthApiBackend) SubscribeRelayerDepositEvent(ch chan<- core.RelayerDepositEvent) event.Subscription {
+	orderSub := b.eth.orderPool.SubscribeRelayerDepositEvent(ch)
+	lendingSub := b.eth.lendingPool.SubscribeRelayerDepositEvent(ch)
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer orderSub.Unsubscribe()
+		defer lendingSub.Unsubscribe()
+		select {
+		case err := <-orderSub.Err():
+			return err
+		case err := <-lendingSub.Err():
+			return err
+		case <-quit:
+			return nil
+		}
+	})
+}
+
 func (b *EthApiBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Subscription {
 	return b.eth.TxPool().SubscribeTxPreEvent(ch)
 }
@@ -496,8 +496,9 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 // If no state is locally available for the given block, a number of blocks are
 // attempted to be reexecuted to generate the desired state.
 func (api *PrivateDebugAPI) computeStateDB(block *types.Block, reexec uint64) (*state.StateDB, *tradingstate.TradingStateDB, error) {
-	// If we have the state fully available, use that
-	statedb, err := api.eth.blockchain.StateAt(block.Root())
+	// If we have the state fully available, or can roll it back from the state
+	// history, use that
+	statedb, err := api.eth.blockchain.StateAtHeader(block.Header())
 	tomoxState := &tradingstate.TradingStateDB{}
 	if err == nil {
 		tomoxState, err = api.eth.blockchain.OrderStateAt(block)
@@ -46,6 +46,8 @@ import (
 	"github.com/tomochain/tomochain/eth/downloader"
 	"github.com/tomochain/tomochain/eth/filters"
 	"github.com/tomochain/tomochain/eth/gasprice"
+	"github.com/tomochain/tomochain/eth/privtx"
+	"github.com/tomochain/tomochain/eth/snap"
 	"github.com/tomochain/tomochain/ethdb"
 	"github.com/tomochain/tomochain/event"
 	"github.com/tomochain/tomochain/internal/ethapi"
@@ -81,7 +83,10 @@ type Ethereum struct {
 	lendingPool     *core.LendingPool
 	blockchain      *core.BlockChain
 	protocolManager *ProtocolManager
+	snapSyncer      *snap.Syncer
 	lesServer       LesServer
+	mesh            *masternodeMesh // Reserved connections to the current masternodes, if enabled
+	privateTxs      *privtx.Relay   // Private submission to the upcoming block producers, posv only
 
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
@@ -164,7 +169,7 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, StateHistory: config.StateHistory}
 	)
 	if eth.chainConfig.Posv != nil {
 		c := eth.engine.(*posv.Posv)
@@ -191,8 +196,11 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
 	eth.txPool = core.NewTxPool(config.TxPool, eth.chainConfig, eth.blockchain)
-	eth.orderPool = core.NewOrderPool(eth.chainConfig, eth.blockchain)
-	eth.lendingPool = core.NewLendingPool(eth.chainConfig, eth.blockchain)
+	orderPoolConfig, lendingPoolConfig := core.DefaultOrderPoolConfig, core.DefaultLendingPoolConfig
+	orderPoolConfig.RelayerMinDeposit = config.RelayerMinDeposit
+	lendingPoolConfig.RelayerMinDeposit = config.RelayerMinDeposit
+	eth.orderPool = core.NewOrderPool(orderPoolConfig, eth.chainConfig, eth.blockchain)
+	eth.lendingPool = core.NewLendingPool(lendingPoolConfig, eth.chainConfig, eth.blockchain)
 	if common.RollbackHash != common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000") {
 		curBlock := eth.blockchain.CurrentBlock()
 		prevBlock := eth.blockchain.GetBlockByHash(common.RollbackHash)
@@ -215,6 +223,25 @@ func New(ctx *node.ServiceContext, config *Config, tomoXServ *tomox.TomoX, lendi
 	if eth.protocolManager, err = NewProtocolManagerEx(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.orderPool, eth.lendingPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	if config.SnapSync {
+		eth.snapSyncer = snap.NewSyncer(map[snap.StateKind]ethdb.KeyValueStore{
+			snap.StateTrie:   chainDb,
+			snap.TradingTrie: tomoXServ.GetLevelDB(),
+			snap.LendingTrie: tomoXServ.GetLevelDB(),
+		})
+		eth.protocolManager.downloader.SetStateSyncer(&snapStateSyncer{
+			config: eth.chainConfig,
+			engine: eth.engine,
+			syncer: eth.snapSyncer,
+		})
+	}
+	eth.protocolManager.enableCheckpointSync(config.SyncFromCheckpoint)
+
+	if engine, ok := eth.engine.(*posv.Posv); ok {
+		eth.privateTxs = privtx.NewRelay(&privateTxBackend{eth: eth, engine: engine}, privateTxFanout)
+		eth.protocolManager.SetPrivateFilter(eth.privateTxs.IsPrivate)
+	}
+
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine, ctx.GetConfig().AnnounceTxs)
 	eth.miner.SetExtra(makeExtraData(config.ExtraData))
 
@@ -811,10 +838,15 @@ func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManage
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
+	protos := append([]p2p.Protocol{}, s.protocolManager.SubProtocols...)
+	protos = append(protos, snap.MakeProtocols(&snapBackend{s}, s.snapSyncer)...)
+	if s.privateTxs != nil {
+		protos = append(protos, s.privateTxs.MakeProtocols()...)
+	}
 	if s.lesServer == nil {
-		return s.protocolManager.SubProtocols
+		return protos
 	}
-	return append(s.protocolManager.SubProtocols, s.lesServer.Protocols()...)
+	return append(protos, s.lesServer.Protocols()...)
 }
 
 // Start implements node.Service, starting all internal goroutines needed by the
@@ -834,8 +866,27 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 		}
 		maxPeers -= s.config.LightPeers
 	}
+	// Keep the masternodes of the current epoch connected if requested
+	if s.config.MasternodeMesh {
+		engine, ok := s.engine.(*posv.Posv)
+		if !ok {
+			return errors.New("masternode mesh requires the posv consensus engine")
+		}
+		masternodes := func(header *types.Header) []common.Address {
+			return engine.GetMasternodes(s.blockchain, header)
+		}
+		mesh := newMasternodeMesh(srvr, s.blockchain, masternodes, s.chainConfig.Posv.Epoch, s.config.MasternodeRegistry)
+		if err := mesh.start(); err != nil {
+			return err
+		}
+		s.mesh = mesh
+	}
 	// Start the networking layer and the light server if requested
+	s.protocolManager.SetPeerReporter(srvr)
 	s.protocolManager.Start(maxPeers)
+	if s.privateTxs != nil {
+		s.privateTxs.Start(srvr.Self().ID)
+	}
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
@@ -848,6 +899,12 @@ func (s *Ethereum) SaveData() {
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
+	if s.mesh != nil {
+		s.mesh.stop()
+	}
+	if s.privateTxs != nil {
+		s.privateTxs.Stop()
+	}
 	s.bloomIndexer.Close()
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
@@ -0,0 +1,445 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/eth/downloader"
+	"github.com/tomochain/tomochain/log"
+)
+
+const (
+	backfillTimeout  = 10 * time.Second // Time allowance for a peer to answer a backfill request
+	backfillIdle     = time.Second      // Delay before retrying when no peer can serve a request
+	backfillPenalty  = time.Minute      // Time a peer failing a request is not asked again
+	backfillProgress = 8 * time.Second  // Interval between backfill progress reports
+)
+
+var (
+	// errBackfillQuit is returned if the backfill is interrupted by a shutdown.
+	errBackfillQuit = errors.New("backfill terminated")
+
+	// errBackfillTimeout is returned if a peer did not answer a request in time.
+	errBackfillTimeout = errors.New("backfill request timed out")
+)
+
+// backfillRequest is a header or body retrieval in flight. Responses are only
+// claimed if they match the request, everything else is left to the downloader
+// and the fetcher.
+type backfillRequest struct {
+	peer    string
+	origin  common.Hash     // Hash of the first header of a header request
+	headers []*types.Header // Headers of the bodies of a body request
+
+	headerCh chan []*types.Header
+	bodyCh   chan []*types.Body
+}
+
+// checkpointSync seeds an empty chain with a trusted checkpoint block and then
+// retrieves the blocks below it, newest first, until it links to the genesis.
+// Syncing onwards from the checkpoint is held back until the blocks the PoSV
+// penalty rules of the following epochs look back at have been retrieved.
+type checkpointSync struct {
+	checkpoint *core.Checkpoint // Trusted checkpoint to seed an empty chain with
+	chain      *core.BlockChain
+	downloader *downloader.Downloader
+	peers      *peerSet
+	window     uint64 // Number of blocks below the checkpoint needed before syncing onwards
+
+	pending *backfillRequest     // Request currently in flight
+	failed  map[string]time.Time // Peers recently failing a request
+	lock    sync.Mutex
+
+	ready chan struct{} // Closed once the chain may be synced onwards
+	quit  chan struct{}
+}
+
+// newCheckpointSync creates a checkpoint syncer for the given chain. The
+// checkpoint may be nil if the chain is resuming a previous backfill.
+func newCheckpointSync(checkpoint *core.Checkpoint, chain *core.BlockChain, downloader *downloader.Downloader, peers *peerSet, quit chan struct{}) *checkpointSync {
+	var window uint64
+	if config := chain.Config().Posv; config != nil {
+		window = uint64(common.LimitPenaltyEpoch+1) * config.Epoch
+	}
+	return &checkpointSync{
+		checkpoint: checkpoint,
+		chain:      chain,
+		downloader: downloader,
+		peers:      peers,
+		window:     window,
+		failed:     make(map[string]time.Time),
+		ready:      make(chan struct{}),
+		quit:       quit,
+	}
+}
+
+// enableCheckpointSync starts seeding the chain from the given checkpoint, or
+// resumes backfilling a chain previously seeded from one.
+func (pm *ProtocolManager) enableCheckpointSync(checkpoint *core.Checkpoint) {
+	seeded := pm.blockchain.SyncCheckpoint()
+	if checkpoint == nil && (seeded == nil || seeded.Tail == 0) {
+		return
+	}
+	pm.checkpoint = newCheckpointSync(checkpoint, pm.blockchain, pm.downloader, pm.peers, pm.quitSync)
+
+	pm.wg.Add(1)
+	go func() {
+		defer pm.wg.Done()
+		pm.checkpoint.loop()
+	}()
+}
+
+// isReady returns whether the chain may be synced onwards.
+func (s *checkpointSync) isReady() bool {
+	select {
+	case <-s.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// loop seeds the chain and backfills it down to the genesis.
+func (s *checkpointSync) loop() {
+	defer func() {
+		if !s.isReady() {
+			close(s.ready)
+		}
+	}()
+	seeded, err := s.seed()
+	if err != nil {
+		if err != errBackfillQuit {
+			log.Error("Failed to seed chain from checkpoint", "err", err)
+		}
+		return
+	}
+	if seeded == nil {
+		return
+	}
+	s.downloader.SetCheckpoint(seeded.Number)
+
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		tail   = seeded.Tail
+	)
+	for tail > 1 {
+		if !s.isReady() && seeded.Number-tail >= s.window {
+			log.Info("Checkpoint ancestry retrieved, syncing onwards", "number", seeded.Number, "tail", tail)
+			close(s.ready)
+		}
+		header := s.chain.GetHeaderByNumber(tail)
+		if header == nil {
+			log.Error("Missing backfill tail", "number", tail)
+			return
+		}
+		amount := uint64(downloader.MaxHeaderFetch)
+		if amount > tail-1 {
+			amount = tail - 1
+		}
+		headers, err := s.fetchHeaders(header.ParentHash, tail-1, amount)
+		if err != nil {
+			return
+		}
+		blocks, err := s.fetchBodies(headers)
+		if err != nil {
+			return
+		}
+		// Headers are retrieved in reverse, store them in ascending order
+		for i := 0; i < len(blocks)/2; i++ {
+			blocks[i], blocks[len(blocks)-1-i] = blocks[len(blocks)-1-i], blocks[i]
+		}
+		if err := s.chain.InsertBackfillChain(blocks); err != nil {
+			log.Error("Failed to store backfilled blocks", "err", err)
+			return
+		}
+		tail = blocks[0].NumberU64()
+		if time.Since(logged) > backfillProgress {
+			log.Info("Backfilling checkpoint ancestry", "number", seeded.Number, "tail", tail, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Checkpoint ancestry backfilled", "number", seeded.Number, "hash", seeded.Hash, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// seed inserts the trusted checkpoint into an empty chain, or returns the one a
+// previous run seeded the chain with. Nil is returned if there is nothing to
+// backfill.
+func (s *checkpointSync) seed() (*rawdb.SyncCheckpoint, error) {
+	if seeded := s.chain.SyncCheckpoint(); seeded != nil {
+		if s.checkpoint != nil && s.checkpoint.Hash != seeded.Hash {
+			log.Warn("Chain seeded from a different checkpoint", "number", seeded.Number, "hash", seeded.Hash)
+		}
+		if seeded.Tail == 0 {
+			return nil, nil
+		}
+		return seeded, nil
+	}
+	if s.chain.CurrentHeader().Number.Uint64() != 0 || s.chain.CurrentFastBlock().NumberU64() != 0 {
+		log.Warn("Chain not empty, ignoring sync checkpoint", "number", s.checkpoint.Number, "hash", s.checkpoint.Hash)
+		return nil, nil
+	}
+	if err := s.checkpoint.Verify(); err != nil {
+		return nil, err
+	}
+	// Retrieve the checkpoint block unless known already
+	header := s.checkpoint.Header
+	if header == nil {
+		log.Info("Retrieving checkpoint header", "number", s.checkpoint.Number, "hash", s.checkpoint.Hash)
+		headers, err := s.fetchHeaders(s.checkpoint.Hash, s.checkpoint.Number, 1)
+		if err != nil {
+			return nil, err
+		}
+		header = headers[0]
+	}
+	blocks, err := s.fetchBodies([]*types.Header{header})
+	if err != nil {
+		return nil, err
+	}
+	if engine, ok := s.chain.Engine().(*posv.Posv); ok {
+		if err := engine.SeedCheckpoint(header); err != nil {
+			return nil, err
+		}
+	}
+	// The total difficulty is unknown until the backfill completes, every block
+	// carries at least a difficulty of one
+	genesis := s.chain.Genesis()
+	td := new(big.Int).Add(s.chain.GetTd(genesis.Hash(), 0), new(big.Int).SetUint64(header.Number.Uint64()))
+	if err := s.chain.InsertCheckpoint(blocks[0], td); err != nil {
+		return nil, err
+	}
+	return s.chain.SyncCheckpoint(), nil
+}
+
+// fetchHeaders retrieves at most amount headers in reverse, starting at the
+// given hash and number. The headers are verified to hash-link to the origin.
+func (s *checkpointSync) fetchHeaders(origin common.Hash, number uint64, amount uint64) ([]*types.Header, error) {
+	for {
+		p, err := s.selectPeer()
+		if err != nil {
+			return nil, err
+		}
+		req := &backfillRequest{peer: p.id, origin: origin, headerCh: make(chan []*types.Header, 1)}
+		s.track(req)
+		if err := p.RequestHeadersByHash(origin, int(amount), 0, true); err != nil {
+			s.fail(req)
+			continue
+		}
+		headers, err := s.waitHeaders(req)
+		if err == errBackfillTimeout {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Keep the prefix of the reply linking to the origin
+		want := origin
+		for i, header := range headers {
+			if header.Number.Uint64() != number-uint64(i) || header.Hash() != want {
+				headers = headers[:i]
+				break
+			}
+			want = header.ParentHash
+		}
+		if len(headers) == 0 {
+			p.Log().Debug("Invalid backfill headers", "origin", origin, "number", number)
+			s.fail(req)
+			continue
+		}
+		return headers, nil
+	}
+}
+
+// fetchBodies retrieves the bodies of the given headers, assembling them into
+// blocks.
+func (s *checkpointSync) fetchBodies(headers []*types.Header) ([]*types.Block, error) {
+	bodies := make([]*types.Body, len(headers))
+
+	var missing []int
+	for i, header := range headers {
+		if header.TxHash == types.EmptyRootHash && header.UncleHash == types.EmptyUncleHash {
+			bodies[i] = new(types.Body)
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	for len(missing) > 0 {
+		p, err := s.selectPeer()
+		if err != nil {
+			return nil, err
+		}
+		count := len(missing)
+		if count > downloader.MaxBodyFetch {
+			count = downloader.MaxBodyFetch
+		}
+		req := &backfillRequest{peer: p.id, bodyCh: make(chan []*types.Body, 1)}
+		hashes := make([]common.Hash, count)
+		for i, index := range missing[:count] {
+			req.headers = append(req.headers, headers[index])
+			hashes[i] = headers[index].Hash()
+		}
+		s.track(req)
+		if err := p.RequestBodies(hashes); err != nil {
+			s.fail(req)
+			continue
+		}
+		delivered, err := s.waitBodies(req)
+		if err == errBackfillTimeout {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i, body := range delivered {
+			bodies[missing[i]] = body
+		}
+		missing = missing[len(delivered):]
+	}
+	blocks := make([]*types.Block, len(headers))
+	for i, header := range headers {
+		blocks[i] = types.NewBlockWithHeader(header).WithBody(bodies[i].Transactions, bodies[i].Uncles)
+	}
+	return blocks, nil
+}
+
+// selectPeer returns the peer with the highest total difficulty which did not
+// fail a request recently, waiting for one if none is available.
+func (s *checkpointSync) selectPeer() (*peer, error) {
+	for {
+		var (
+			best   *peer
+			bestTd *big.Int
+		)
+		s.lock.Lock()
+		for _, p := range s.peers.List() {
+			if failed, ok := s.failed[p.id]; ok {
+				if time.Since(failed) < backfillPenalty {
+					continue
+				}
+				delete(s.failed, p.id)
+			}
+			if _, td := p.Head(); best == nil || td.Cmp(bestTd) > 0 {
+				best, bestTd = p, td
+			}
+		}
+		s.lock.Unlock()
+
+		if best != nil {
+			return best, nil
+		}
+		select {
+		case <-time.After(backfillIdle):
+		case <-s.quit:
+			return nil, errBackfillQuit
+		}
+	}
+}
+
+// track marks a request as being in flight.
+func (s *checkpointSync) track(req *backfillRequest) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pending = req
+}
+
+// fail drops a request and excludes its peer from the next ones for a while.
+func (s *checkpointSync) fail(req *backfillRequest) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pending == req {
+		s.pending = nil
+	}
+	s.failed[req.peer] = time.Now()
+}
+
+// waitHeaders waits for the reply to a header request.
+func (s *checkpointSync) waitHeaders(req *backfillRequest) ([]*types.Header, error) {
+	timer := time.NewTimer(backfillTimeout)
+	defer timer.Stop()
+
+	select {
+	case headers := <-req.headerCh:
+		return headers, nil
+	case <-timer.C:
+		s.fail(req)
+		return nil, errBackfillTimeout
+	case <-s.quit:
+		return nil, errBackfillQuit
+	}
+}
+
+// waitBodies waits for the reply to a body request.
+func (s *checkpointSync) waitBodies(req *backfillRequest) ([]*types.Body, error) {
+	timer := time.NewTimer(backfillTimeout)
+	defer timer.Stop()
+
+	select {
+	case bodies := <-req.bodyCh:
+		return bodies, nil
+	case <-timer.C:
+		s.fail(req)
+		return nil, errBackfillTimeout
+	case <-s.quit:
+		return nil, errBackfillQuit
+	}
+}
+
+// deliverHeaders claims a batch of headers if it answers the pending request.
+func (s *checkpointSync) deliverHeaders(peer string, headers []*types.Header) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	req := s.pending
+	if req == nil || req.peer != peer || req.headerCh == nil || len(headers) == 0 || headers[0].Hash() != req.origin {
+		return false
+	}
+	s.pending = nil
+	req.headerCh <- headers
+	return true
+}
+
+// deliverBodies claims a batch of bodies if it answers the pending request.
+func (s *checkpointSync) deliverBodies(peer string, txs [][]*types.Transaction, uncles [][]*types.Header) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	req := s.pending
+	if req == nil || req.peer != peer || req.bodyCh == nil || len(txs) == 0 || len(txs) > len(req.headers) || len(txs) != len(uncles) {
+		return false
+	}
+	bodies := make([]*types.Body, len(txs))
+	for i, header := range req.headers[:len(txs)] {
+		if types.DeriveSha(types.Transactions(txs[i])) != header.TxHash || types.CalcUncleHash(uncles[i]) != header.UncleHash {
+			return false
+		}
+		bodies[i] = &types.Body{Transactions: txs[i], Uncles: uncles[i]}
+	}
+	s.pending = nil
+	req.bodyCh <- bodies
+	return true
+}
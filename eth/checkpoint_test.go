@@ -0,0 +1,91 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/eth/downloader"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/params"
+)
+
+// Tests that a node started from a trusted checkpoint retrieves the checkpoint
+// block from the network, syncs onwards from it and backfills the blocks below
+// it down to the genesis.
+func TestCheckpointSync(t *testing.T) {
+	// Create a full chain with some transactions to backfill bodies of
+	generator := func(i int, block *core.BlockGen) {
+		if i%3 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{byte(i)}, big.NewInt(1), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
+			block.AddTx(tx)
+		}
+	}
+	pmFull, _ := newTestProtocolManagerMust(t, downloader.FullSync, 1024, generator, nil)
+	defer pmFull.Stop()
+
+	checkpoint := pmFull.blockchain.GetHeaderByNumber(600)
+
+	pmEmpty, _ := newTestProtocolManagerMust(t, downloader.FastSync, 0, nil, nil)
+	defer pmEmpty.Stop()
+	pmEmpty.enableCheckpointSync(&core.Checkpoint{Number: checkpoint.Number.Uint64(), Hash: checkpoint.Hash()})
+
+	// Syncing onwards must wait for the checkpoint to be retrieved
+	pmEmpty.synchronise(pmEmpty.peers.BestPeer())
+	if pmEmpty.checkpoint.isReady() {
+		t.Fatalf("checkpoint sync ready without peers")
+	}
+	io1, io2 := p2p.MsgPipe()
+
+	go pmFull.handle(pmFull.newPeer(63, p2p.NewPeer(discover.NodeID{}, "empty", nil), io2))
+	go pmEmpty.handle(pmEmpty.newPeer(63, p2p.NewPeer(discover.NodeID{}, "full", nil), io1))
+
+	waitFor := func(what string, cond func() bool) {
+		for start := time.Now(); !cond(); time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > 10*time.Second {
+				t.Fatalf("timeout waiting for %s", what)
+			}
+		}
+	}
+	waitFor("checkpoint", func() bool { return pmEmpty.checkpoint.isReady() })
+	if head := pmEmpty.blockchain.CurrentFastBlock(); head.Hash() != checkpoint.Hash() {
+		t.Fatalf("fast head mismatch: have #%d [%x], want #%d [%x]", head.NumberU64(), head.Hash(), checkpoint.Number, checkpoint.Hash())
+	}
+	pmEmpty.synchronise(pmEmpty.peers.BestPeer())
+	if head := pmEmpty.blockchain.CurrentBlock(); head.NumberU64() != 1024 {
+		t.Fatalf("head not synced: have #%d, want #1024", head.NumberU64())
+	}
+	waitFor("backfill", func() bool { return pmEmpty.blockchain.SyncCheckpoint().Tail == 0 })
+
+	// Every block must be available with the total difficulty of the full chain
+	for number := uint64(1); number <= 1024; number++ {
+		want := pmFull.blockchain.GetBlockByNumber(number)
+		have := pmEmpty.blockchain.GetBlockByNumber(number)
+		if have == nil || have.Hash() != want.Hash() || len(have.Transactions()) != len(want.Transactions()) {
+			t.Fatalf("block #%d: mismatch", number)
+		}
+		if have, want := pmEmpty.blockchain.GetTd(want.Hash(), number), pmFull.blockchain.GetTd(want.Hash(), number); have.Cmp(want) != 0 {
+			t.Fatalf("block #%d: td mismatch: have %v, want %v", number, have, want)
+		}
+	}
+}
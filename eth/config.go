@@ -82,6 +82,14 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 	NoPruning bool
+	SnapSync  bool // Retrieve the fast sync pivot state in proven ranges over the snap protocol
+
+	// Trusted checkpoint to seed an empty chain with instead of syncing from the genesis
+	SyncFromCheckpoint *core.Checkpoint `toml:"-"`
+
+	// Masternode mesh options
+	MasternodeMesh     bool   `toml:",omitempty"` // Keep reserved connections to the masternodes of the current epoch
+	MasternodeRegistry string `toml:",omitempty"` // JSON file of masternode records resolving masternodes to enodes
 
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
@@ -93,6 +101,7 @@ type Config struct {
 	DatabaseCache      int
 	TrieCache          int
 	TrieTimeout        time.Duration
+	StateHistory       uint64 // Number of recent blocks to keep reverse state diffs for
 
 	// Mining-related options
 	Etherbase    common.Address `toml:",omitempty"`
@@ -106,6 +115,9 @@ type Config struct {
 	// Transaction pool options
 	TxPool core.TxPoolConfig
 
+	// TomoX order and lending pool options
+	RelayerMinDeposit *big.Int `toml:",omitempty"` // Deposit under which new orders of a relayer are rejected, nil to only require the fees of its next matching
+
 	// Gas Price Oracle options
 	GPO gasprice.Config
 
@@ -33,6 +33,7 @@ import (
 	"github.com/tomochain/tomochain/event"
 	"github.com/tomochain/tomochain/log"
 	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/p2p"
 	"github.com/tomochain/tomochain/params"
 )
 
@@ -110,11 +111,14 @@ type Downloader struct {
 	syncStatsState       stateSyncStats
 	syncStatsLock        sync.RWMutex // Lock protecting the sync stats fields
 
-	lightchain LightChain
-	blockchain BlockChain
+	lightchain  LightChain
+	blockchain  BlockChain
+	stateSyncer StateSyncer // Optional range based state retriever replacing node data downloads
+	checkpoint  uint64      // Trusted checkpoint the local chain was seeded from, never synced below (atomic)
 
 	// Callbacks
-	dropPeer peerDropFn // Drops a peer for misbehaving
+	dropPeer   peerDropFn   // Drops a peer for misbehaving
+	reportPeer peerReportFn // Reports a misbehaving peer to the peer scoring
 
 	// Status
 	synchroniseMock func(id string, hash common.Hash) error // Replacement for synchronise during testing
@@ -171,6 +175,15 @@ type LightChain interface {
 	Rollback([]common.Hash)
 }
 
+// StateSyncer encapsulates an alternative retrieval of the pivot state during
+// fast sync, able to cover more than the main state trie of a block.
+type StateSyncer interface {
+	// SyncState retrieves the state belonging to the given block, returning once
+	// it is fully present locally or the cancel channel is closed. Before the
+	// pivot body is downloaded, the block only contains the header.
+	SyncState(block *types.Block, cancel <-chan struct{}) error
+}
+
 // BlockChain encapsulates functions required to sync a (full or fast) blockchain.
 type BlockChain interface {
 	Config() *params.ChainConfig
@@ -234,6 +247,31 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 	return dl
 }
 
+// SetStateSyncer replaces the node data based retrieval of the pivot state during
+// fast sync with the given syncer. It must be called before any sync starts.
+func (d *Downloader) SetStateSyncer(syncer StateSyncer) {
+	d.stateSyncer = syncer
+}
+
+// SetPeerReporter sets the callback misbehaving peers are reported to before
+// being dropped. It must be called before any sync starts.
+func (d *Downloader) SetPeerReporter(report peerReportFn) {
+	d.reportPeer = report
+}
+
+// report reports a misbehaving peer to the peer scoring, if there is any.
+func (d *Downloader) report(id string, fault p2p.Misbehaviour) {
+	if d.reportPeer != nil {
+		d.reportPeer(id, fault)
+	}
+}
+
+// SetCheckpoint marks the local chain as seeded from a trusted checkpoint with
+// the given number, rejecting common ancestors below it.
+func (d *Downloader) SetCheckpoint(number uint64) {
+	atomic.StoreUint64(&d.checkpoint, number)
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -324,6 +362,14 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
 		errInvalidAncestor, errInvalidChain:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
+		switch err {
+		case errTimeout, errStallingPeer:
+			d.report(id, p2p.StalledDelivery)
+		case errInvalidAncestor, errInvalidChain:
+			d.report(id, p2p.InvalidBlock)
+		case errBadPeer, errEmptyHeaderSet:
+			d.report(id, p2p.UselessResponse)
+		}
 		if d.dropPeer == nil {
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
 			// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
@@ -450,6 +496,14 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 				origin = pivot - 1
 			}
 		}
+		// Blocks below a trusted checkpoint are not available for import, keep
+		// the pivot above it
+		if checkpoint := atomic.LoadUint64(&d.checkpoint); origin < checkpoint {
+			origin = checkpoint
+			if pivot <= origin {
+				pivot = origin + 1
+			}
+		}
 	}
 	d.committed = 1
 	if d.mode == FastSync && pivot != 0 {
@@ -597,6 +651,9 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 	if ceil >= MaxForkAncestry {
 		floor = int64(ceil - MaxForkAncestry)
 	}
+	if checkpoint := int64(atomic.LoadUint64(&d.checkpoint)); floor < checkpoint-1 {
+		floor = checkpoint - 1
+	}
 	p.log.Debug("Looking for common ancestor", "local", ceil, "remote", height)
 
 	// Request the topmost blocks to short circuit binary ancestor lookup
@@ -863,6 +920,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, pivot uint64)
 			// Header retrieval timed out, consider the peer bad and drop
 			p.log.Debug("Header request timed out", "elapsed", ttl)
 			headerTimeoutMeter.Mark(1)
+			d.report(p.id, p2p.StalledDelivery)
 			d.dropPeer(p.id)
 
 			// Finish the sync gracefully instead of dumping the gathered data though
@@ -1083,6 +1141,7 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 							// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
 							peer.log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", pid)
 						} else {
+							d.report(pid, p2p.StalledDelivery)
 							d.dropPeer(pid)
 						}
 					}
@@ -1402,7 +1461,7 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 	// Start syncing state of the reported head block. This should get us most of
 	// the state of the pivot block.
-	stateSync := d.syncState(latest.Root)
+	stateSync := d.syncPivotState(types.NewBlockWithHeader(latest))
 	defer stateSync.Cancel()
 	go func() {
 		if err := stateSync.Wait(); err != nil && err != errCancelStateFetch {
@@ -1460,7 +1519,7 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 			if oldPivot != P {
 				stateSync.Cancel()
 
-				stateSync = d.syncState(P.Header.Root)
+				stateSync = d.syncPivotState(types.NewBlockWithHeader(P.Header).WithBody(P.Transactions, P.Uncles))
 				defer stateSync.Cancel()
 				go func() {
 					if err := stateSync.Wait(); err != nil && err != errCancelStateFetch {
@@ -25,10 +25,12 @@ import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/rawdb"
 	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/crypto/sha3"
 	"github.com/tomochain/tomochain/ethdb"
 	"github.com/tomochain/tomochain/ethdb/memorydb"
 	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p"
 	"github.com/tomochain/tomochain/trie"
 )
 
@@ -70,6 +72,47 @@ func (d *Downloader) syncState(root common.Hash) *stateSync {
 	return s
 }
 
+// syncPivotState starts downloading the state of the given block, through the
+// configured state syncer if any, or node by node otherwise.
+func (d *Downloader) syncPivotState(block *types.Block) *stateSync {
+	if d.stateSyncer == nil {
+		return d.syncState(block.Root())
+	}
+	s := &stateSync{
+		d:      d,
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+
+		// Abort the retrieval if the state sync, the sync cycle or the whole
+		// downloader is cancelled
+		cancel, finished := make(chan struct{}), make(chan struct{})
+		go func() {
+			select {
+			case <-s.cancel:
+			case <-d.cancelCh:
+			case <-d.quitCh:
+			case <-finished:
+				return
+			}
+			close(cancel)
+		}()
+		s.err = d.stateSyncer.SyncState(block, cancel)
+		close(finished)
+
+		if s.err != nil {
+			select {
+			case <-cancel:
+				s.err = errCancelStateFetch
+			default:
+			}
+		}
+	}()
+	return s
+}
+
 // stateFetcher manages the active state sync and accepts requests
 // on its behalf.
 func (d *Downloader) stateFetcher() {
@@ -306,6 +349,7 @@ func (s *stateSync) loop() error {
 				// 2 items are the minimum requested, if even that times out, we've no use of
 				// this peer at the moment.
 				log.Warn("Stalling state sync, dropping peer", "peer", req.peer.id)
+				s.d.report(req.peer.id, p2p.StalledDelivery)
 				s.d.dropPeer(req.peer.id)
 			}
 			// Process all the received blobs and check for stale delivery
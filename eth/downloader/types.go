@@ -20,11 +20,16 @@ import (
 	"fmt"
 
 	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/p2p"
 )
 
 // peerDropFn is a callback type for dropping a peer detected as malicious.
 type peerDropFn func(id string)
 
+// peerReportFn is a callback type for reporting a misbehaving peer to the peer
+// scoring of the p2p server.
+type peerReportFn func(id string, fault p2p.Misbehaviour)
+
 // dataPack is a data message returned by a peer for some query.
 type dataPack interface {
 	PeerId() string
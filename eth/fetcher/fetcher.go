@@ -27,6 +27,7 @@ import (
 	"github.com/tomochain/tomochain/consensus"
 	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
@@ -70,6 +71,10 @@ type blockPrepareFn func(block *types.Block) error
 // peerDropFn is a callback type for dropping a peer detected as malicious.
 type peerDropFn func(id string)
 
+// peerReportFn is a callback type for reporting a misbehaving peer to the peer
+// scoring of the p2p server.
+type peerReportFn func(id string, fault p2p.Misbehaviour)
+
 // announce is the hash notification of the availability of a new block in the
 // network.
 type announce struct {
@@ -139,7 +144,8 @@ type Fetcher struct {
 	chainHeight    chainHeightFn      // Retrieves the current chain's height
 	insertBlock    blockInsertFn      // Injects a batch of blocks into the chain
 	prepareBlock   blockPrepareFn
-	dropPeer       peerDropFn // Drops a peer for misbehaving
+	dropPeer       peerDropFn   // Drops a peer for misbehaving
+	reportPeer     peerReportFn // Reports a misbehaving peer to the peer scoring
 
 	// Testing hooks
 	announceChangeHook func(common.Hash, bool) // Method to call upon adding or deleting a hash from the announce list
@@ -180,6 +186,19 @@ func New(getBlock blockRetrievalFn, verifyHeader headerVerifierFn, broadcastBloc
 	}
 }
 
+// SetPeerReporter sets the callback misbehaving peers are reported to. It must
+// be called before the fetcher is started.
+func (f *Fetcher) SetPeerReporter(report peerReportFn) {
+	f.reportPeer = report
+}
+
+// report reports a misbehaving peer to the peer scoring, if there is any.
+func (f *Fetcher) report(peer string, fault p2p.Misbehaviour) {
+	if f.reportPeer != nil {
+		f.reportPeer(peer, fault)
+	}
+}
+
 // Start boots up the announcement based synchroniser, accepting and processing
 // hash notifications and block fetches until termination requested.
 func (f *Fetcher) Start() {
@@ -334,6 +353,7 @@ func (f *Fetcher) loop() {
 			if count > hashLimit {
 				log.Debug("Peer exceeded outstanding announces", "peer", notification.origin, "limit", hashLimit)
 				propAnnounceDOSMeter.Mark(1)
+				f.report(notification.origin, p2p.DuplicateMessage)
 				break
 			}
 			// If we have a valid block number, check that it's potentially useful
@@ -458,6 +478,7 @@ func (f *Fetcher) loop() {
 					// If the delivered header does not match the promised number, drop the announcer
 					if header.Number.Uint64() != announce.number {
 						log.Trace("Invalid block number fetched", "peer", announce.origin, "hash", header.Hash(), "announced", announce.number, "provided", header.Number)
+						f.report(announce.origin, p2p.UselessResponse)
 						f.dropPeer(announce.origin)
 						f.forgetHash(hash)
 						continue
@@ -617,6 +638,7 @@ func (f *Fetcher) enqueue(peer string, block *types.Block) {
 	if count > blockLimit {
 		log.Debug("Discarded propagated block, exceeded allowance", "peer", peer, "number", block.Number(), "hash", hash, "limit", blockLimit)
 		propBroadcastDOSMeter.Mark(1)
+		f.report(peer, p2p.DuplicateMessage)
 		f.forgetHash(hash)
 		return
 	}
@@ -706,6 +728,7 @@ func (f *Fetcher) insert(peer string, block *types.Block) {
 		default:
 			// Something went very wrong, drop the peer
 			log.Debug("Propagated block verification failed", "peer", peer, "number", block.Number(), "hash", hash, "err", err)
+			f.report(peer, p2p.InvalidBlock)
 			f.dropPeer(peer)
 			return
 		}
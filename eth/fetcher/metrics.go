@@ -41,3 +41,40 @@ var (
 	bodyFilterInMeter    = metrics.NewRegisteredMeter("eth/fetcher/filter/bodies/in", nil)
 	bodyFilterOutMeter   = metrics.NewRegisteredMeter("eth/fetcher/filter/bodies/out", nil)
 )
+
+// txFetcherMetrics contains the metrics collected by a transaction fetcher.
+// Each kind of pooled transaction is fetched and metered separately.
+type txFetcherMetrics struct {
+	announceIn    metrics.Meter
+	announceKnown metrics.Meter
+	announceDOS   metrics.Meter
+
+	requestOut       metrics.Meter
+	requestFail      metrics.Meter
+	requestDone      metrics.Meter
+	requestTimeout   metrics.Meter
+	replyMissing     metrics.Meter
+	replyUnrequested metrics.Meter
+
+	waitingHashes  metrics.Gauge
+	queuedHashes   metrics.Gauge
+	fetchingHashes metrics.Gauge
+}
+
+func newTxFetcherMetrics(kind string) *txFetcherMetrics {
+	prefix := "eth/fetcher/" + kind
+	return &txFetcherMetrics{
+		announceIn:       metrics.GetOrRegisterMeter(prefix+"/announces/in", nil),
+		announceKnown:    metrics.GetOrRegisterMeter(prefix+"/announces/known", nil),
+		announceDOS:      metrics.GetOrRegisterMeter(prefix+"/announces/dos", nil),
+		requestOut:       metrics.GetOrRegisterMeter(prefix+"/request/out", nil),
+		requestFail:      metrics.GetOrRegisterMeter(prefix+"/request/fail", nil),
+		requestDone:      metrics.GetOrRegisterMeter(prefix+"/request/done", nil),
+		requestTimeout:   metrics.GetOrRegisterMeter(prefix+"/request/timeout", nil),
+		replyMissing:     metrics.GetOrRegisterMeter(prefix+"/replies/missing", nil),
+		replyUnrequested: metrics.GetOrRegisterMeter(prefix+"/replies/unrequested", nil),
+		waitingHashes:    metrics.GetOrRegisterGauge(prefix+"/waiting/hashes", nil),
+		queuedHashes:     metrics.GetOrRegisterGauge(prefix+"/queued/hashes", nil),
+		fetchingHashes:   metrics.GetOrRegisterGauge(prefix+"/fetching/hashes", nil),
+	}
+}
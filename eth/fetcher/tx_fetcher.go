@@ -0,0 +1,570 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/mclock"
+	"github.com/tomochain/tomochain/log"
+)
+
+const (
+	// maxTxAnnounces is the maximum number of unique transactions a peer can
+	// have waiting or queued for retrieval.
+	maxTxAnnounces = 4096
+
+	// maxTxRetrievals is the maximum number of transactions that can be fetched
+	// in one request.
+	maxTxRetrievals = 256
+
+	// txArriveTimeout is the time allowance before an announced transaction is
+	// explicitly requested, giving a direct broadcast the chance to arrive first.
+	txArriveTimeout = 500 * time.Millisecond
+
+	// txGatherSlack is the interval used to collate almost-expired announces
+	// with network fetches.
+	txGatherSlack = 100 * time.Millisecond
+
+	// txFetchTimeout is the maximum allotted time to return an explicitly
+	// requested transaction.
+	txFetchTimeout = 5 * time.Second
+)
+
+// txKnownFn is a callback type for checking whether a transaction is already
+// known locally.
+type txKnownFn func(common.Hash) bool
+
+// txRequestFn is a callback type for sending a transaction retrieval request
+// to a remote peer.
+type txRequestFn func(peer string, hashes []common.Hash) error
+
+// txAnnounce is the notification of the availability of a batch of new
+// transactions in the network.
+type txAnnounce struct {
+	origin string        // Identifier of the peer originating the notification
+	hashes []common.Hash // Batch of transaction hashes being announced
+}
+
+// txDelivery is the notification that a batch of transactions have been added
+// to the pool and should be untracked.
+type txDelivery struct {
+	origin string        // Identifier of the peer originating the notification
+	hashes []common.Hash // Batch of transaction hashes having been delivered
+	direct bool          // Whether this is a direct reply or a broadcast
+}
+
+// txFilter is a query of the transactions of a reply that were requested from
+// the replying peer.
+type txFilter struct {
+	origin string                    // Identifier of the peer the reply arrived from
+	hashes []common.Hash             // Batch of transaction hashes in the reply
+	result chan map[common.Hash]bool // Transactions of the reply that were requested
+}
+
+// txRequest represents an in-flight transaction retrieval request destined to
+// a specific peer.
+type txRequest struct {
+	hashes []common.Hash            // Transactions having been requested
+	stolen map[common.Hash]struct{} // Deliveries by someone else (don't re-request)
+	time   mclock.AbsTime           // Timestamp of the request
+}
+
+// TxFetcher is responsible for retrieving new transactions based on hash
+// announcements. It is agnostic of the kind of transaction retrieved, so one
+// instance is used for each of the plain, order and lending transaction pools.
+//
+// Announced transactions go through three stages:
+//   - Waiting: the hash is held for txArriveTimeout in the hope that a direct
+//     broadcast delivers the transaction without an explicit request.
+//   - Queued: the hash is waiting to be requested from one of the peers that
+//     announced it.
+//   - Fetching: the hash was requested from one peer. If that peer fails to
+//     deliver it in time, it is rescheduled to an alternate announcer.
+//
+// A transaction is only ever requested from a single peer at a time and a peer
+// only ever has a single request in flight.
+type TxFetcher struct {
+	notify  chan *txAnnounce
+	cleanup chan *txDelivery
+	filter  chan *txFilter
+	drop    chan string
+	quit    chan struct{}
+
+	// Stage 1: Waiting for a direct broadcast
+	waitlist  map[common.Hash]map[string]struct{} // Announcing peers of transactions waiting for a broadcast
+	waittime  map[common.Hash]mclock.AbsTime      // Timestamps of the first announcement of the waiting transactions
+	waitslots map[string]map[common.Hash]struct{} // Waiting announcements grouped by peer (DoS protection)
+
+	// Stage 2 and 3: Queued and being fetched
+	announces map[string]map[common.Hash]struct{} // Queued or fetching announcements grouped by peer
+	announced map[common.Hash]map[string]struct{} // Announcing peers of queued or fetching transactions
+	fetching  map[common.Hash]string              // Transactions being retrieved and the peer asked
+	requests  map[string]*txRequest               // In-flight retrieval requests grouped by peer
+
+	// Callbacks
+	hasTx    txKnownFn   // Checks whether a transaction is already known locally
+	fetchTxs txRequestFn // Requests a batch of transactions from a remote peer
+
+	clock   mclock.Clock      // Time source, replaceable for testing
+	metrics *txFetcherMetrics // Metrics of the transaction kind being fetched
+}
+
+// NewTxFetcher creates a transaction fetcher for the given kind of transactions
+// ("transaction", "order" or "lending"), used to group its metrics.
+func NewTxFetcher(kind string, hasTx txKnownFn, fetchTxs txRequestFn) *TxFetcher {
+	return newTxFetcher(kind, hasTx, fetchTxs, mclock.System{})
+}
+
+// newTxFetcher creates a transaction fetcher running on the given clock.
+func newTxFetcher(kind string, hasTx txKnownFn, fetchTxs txRequestFn, clock mclock.Clock) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txAnnounce),
+		cleanup:   make(chan *txDelivery),
+		filter:    make(chan *txFilter),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		waitlist:  make(map[common.Hash]map[string]struct{}),
+		waittime:  make(map[common.Hash]mclock.AbsTime),
+		waitslots: make(map[string]map[common.Hash]struct{}),
+		announces: make(map[string]map[common.Hash]struct{}),
+		announced: make(map[common.Hash]map[string]struct{}),
+		fetching:  make(map[common.Hash]string),
+		requests:  make(map[string]*txRequest),
+		hasTx:     hasTx,
+		fetchTxs:  fetchTxs,
+		clock:     clock,
+		metrics:   newTxFetcherMetrics(kind),
+	}
+}
+
+// Notify announces the fetcher of the potential availability of a batch of
+// transactions in the network. Transactions already known locally are ignored.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
+	f.metrics.announceIn.Mark(int64(len(hashes)))
+
+	unknowns := make([]common.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if f.hasTx(hash) {
+			continue
+		}
+		unknowns = append(unknowns, hash)
+	}
+	f.metrics.announceKnown.Mark(int64(len(hashes) - len(unknowns)))
+	if len(unknowns) == 0 {
+		return nil
+	}
+	select {
+	case f.notify <- &txAnnounce{origin: peer, hashes: unknowns}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Delivered notifies the fetcher that a batch of transactions arrived from a
+// peer, either as a direct reply to a retrieval request or as a broadcast. The
+// transactions are expected to already be handed to the pool, the fetcher only
+// stops tracking them.
+func (f *TxFetcher) Delivered(peer string, hashes []common.Hash, direct bool) error {
+	select {
+	case f.cleanup <- &txDelivery{origin: peer, hashes: hashes, direct: direct}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Requested returns which of the transactions of a reply from peer are part of
+// the request in flight to that peer. Replies no request is waiting for must be
+// dropped instead of being handed to the pool.
+func (f *TxFetcher) Requested(peer string, hashes []common.Hash) map[common.Hash]bool {
+	filter := &txFilter{origin: peer, hashes: hashes, result: make(chan map[common.Hash]bool, 1)}
+	select {
+	case f.filter <- filter:
+	case <-f.quit:
+		return nil
+	}
+	select {
+	case requested := <-filter.result:
+		return requested
+	case <-f.quit:
+		return nil
+	}
+}
+
+// Drop should be called when a peer disconnects. It cleans up all the internal
+// data structures of the given node and reschedules its in-flight requests.
+func (f *TxFetcher) Drop(peer string) error {
+	select {
+	case f.drop <- peer:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Start boots up the announcement based synchroniser, accepting and processing
+// hash notifications and transaction fetches until termination requested.
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+// Stop terminates the announcement based synchroniser, canceling all pending
+// operations.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+// loop is the main fetcher loop, handling announcements, deliveries, drops and
+// the expiration of the waiting and fetching stages.
+func (f *TxFetcher) loop() {
+	var (
+		waitTimer    mclock.Timer
+		timeoutTimer mclock.Timer
+
+		waitTrigger    = make(chan struct{}, 1)
+		timeoutTrigger = make(chan struct{}, 1)
+	)
+	defer func() {
+		if waitTimer != nil {
+			waitTimer.Stop()
+		}
+		if timeoutTimer != nil {
+			timeoutTimer.Stop()
+		}
+	}()
+	for {
+		select {
+		case ann := <-f.notify:
+			// Drop part of the announcements if the peer is flooding us
+			used := len(f.waitslots[ann.origin]) + len(f.announces[ann.origin])
+			if used >= maxTxAnnounces {
+				f.metrics.announceDOS.Mark(int64(len(ann.hashes)))
+				break
+			}
+			if used+len(ann.hashes) > maxTxAnnounces {
+				f.metrics.announceDOS.Mark(int64(used + len(ann.hashes) - maxTxAnnounces))
+				ann.hashes = ann.hashes[:maxTxAnnounces-used]
+			}
+			var (
+				idleWait = len(f.waittime) == 0
+				queued   = false
+			)
+			for _, hash := range ann.hashes {
+				// Queued or being fetched already, track the peer as an alternate source
+				if f.announced[hash] != nil {
+					f.announced[hash][ann.origin] = struct{}{}
+					addHash(f.announces, ann.origin, hash)
+					queued = true
+					continue
+				}
+				// Waiting for a broadcast already, track the peer as a source
+				if f.waitlist[hash] != nil {
+					f.waitlist[hash][ann.origin] = struct{}{}
+					addHash(f.waitslots, ann.origin, hash)
+					continue
+				}
+				// Transaction unknown to the fetcher, wait for a broadcast first
+				f.waitlist[hash] = map[string]struct{}{ann.origin: {}}
+				f.waittime[hash] = f.clock.Now()
+				addHash(f.waitslots, ann.origin, hash)
+			}
+			if idleWait && len(f.waittime) > 0 {
+				f.rescheduleWait(&waitTimer, waitTrigger)
+			}
+			// The peer may be idle and able to serve an already queued transaction
+			if queued && f.requests[ann.origin] == nil {
+				f.scheduleFetches(&timeoutTimer, timeoutTrigger, map[string]struct{}{ann.origin: {}})
+			}
+
+		case <-waitTrigger:
+			// Move all the transactions which waited long enough into the queue
+			actives := make(map[string]struct{})
+			for hash, instance := range f.waittime {
+				if time.Duration(f.clock.Now()-instance)+txGatherSlack <= txArriveTimeout {
+					continue
+				}
+				for peer := range f.waitlist[hash] {
+					if f.announced[hash] == nil {
+						f.announced[hash] = make(map[string]struct{})
+					}
+					f.announced[hash][peer] = struct{}{}
+					addHash(f.announces, peer, hash)
+					removeHash(f.waitslots, peer, hash)
+					actives[peer] = struct{}{}
+				}
+				delete(f.waittime, hash)
+				delete(f.waitlist, hash)
+			}
+			if len(f.waittime) > 0 {
+				f.rescheduleWait(&waitTimer, waitTrigger)
+			}
+			if len(actives) > 0 {
+				f.scheduleFetches(&timeoutTimer, timeoutTrigger, actives)
+			}
+
+		case <-timeoutTrigger:
+			// Consider peers not delivering in time as not having the transactions
+			for peer, req := range f.requests {
+				if time.Duration(f.clock.Now()-req.time)+txGatherSlack <= txFetchTimeout {
+					continue
+				}
+				f.metrics.requestTimeout.Mark(int64(len(req.hashes)))
+				for _, hash := range req.hashes {
+					if _, ok := req.stolen[hash]; ok {
+						continue
+					}
+					f.forget(peer, hash)
+				}
+				delete(f.requests, peer)
+			}
+			f.scheduleFetches(&timeoutTimer, timeoutTrigger, nil)
+			if len(f.requests) > 0 {
+				f.rescheduleTimeout(&timeoutTimer, timeoutTrigger)
+			}
+
+		case delivery := <-f.cleanup:
+			// Stop tracking all the delivered transactions, wherever they are
+			for _, hash := range delivery.hashes {
+				if peers, ok := f.waitlist[hash]; ok {
+					for peer := range peers {
+						removeHash(f.waitslots, peer, hash)
+					}
+					delete(f.waitlist, hash)
+					delete(f.waittime, hash)
+					continue
+				}
+				for peer := range f.announced[hash] {
+					removeHash(f.announces, peer, hash)
+				}
+				delete(f.announced, hash)
+
+				// Don't re-request the transaction if someone else's request times out
+				if origin, ok := f.fetching[hash]; ok && (origin != delivery.origin || !delivery.direct) {
+					if req := f.requests[origin]; req != nil {
+						if req.stolen == nil {
+							req.stolen = make(map[common.Hash]struct{})
+						}
+						req.stolen[hash] = struct{}{}
+					}
+				}
+				delete(f.fetching, hash)
+			}
+			// In case of a direct reply, the request of the peer is done
+			if delivery.direct {
+				req := f.requests[delivery.origin]
+				if req == nil {
+					log.Debug("Unexpected transaction delivery", "peer", delivery.origin, "len", len(delivery.hashes))
+					break
+				}
+				f.metrics.requestDone.Mark(int64(len(delivery.hashes)))
+
+				delivered := make(map[common.Hash]struct{}, len(delivery.hashes))
+				for _, hash := range delivery.hashes {
+					delivered[hash] = struct{}{}
+				}
+				// Transactions missing from the reply are not available at the peer
+				missing := 0
+				for _, hash := range req.hashes {
+					if _, ok := delivered[hash]; ok {
+						continue
+					}
+					if _, ok := req.stolen[hash]; ok {
+						continue
+					}
+					f.forget(delivery.origin, hash)
+					missing++
+				}
+				f.metrics.replyMissing.Mark(int64(missing))
+				delete(f.requests, delivery.origin)
+
+				f.scheduleFetches(&timeoutTimer, timeoutTrigger, nil)
+			}
+
+		case filter := <-f.filter:
+			requested := make(map[common.Hash]bool)
+			if req := f.requests[filter.origin]; req != nil {
+				wanted := make(map[common.Hash]struct{}, len(req.hashes))
+				for _, hash := range req.hashes {
+					wanted[hash] = struct{}{}
+				}
+				for _, hash := range filter.hashes {
+					if _, ok := wanted[hash]; ok {
+						requested[hash] = true
+					}
+				}
+			}
+			f.metrics.replyUnrequested.Mark(int64(len(filter.hashes) - len(requested)))
+			filter.result <- requested
+
+		case peer := <-f.drop:
+			// Forget the peer as a source of waiting transactions
+			for hash := range f.waitslots[peer] {
+				delete(f.waitlist[hash], peer)
+				if len(f.waitlist[hash]) == 0 {
+					delete(f.waitlist, hash)
+					delete(f.waittime, hash)
+				}
+			}
+			delete(f.waitslots, peer)
+
+			// Reschedule the in-flight request and forget its queued announcements
+			if req := f.requests[peer]; req != nil {
+				for _, hash := range req.hashes {
+					if f.fetching[hash] == peer {
+						delete(f.fetching, hash)
+					}
+				}
+				delete(f.requests, peer)
+			}
+			for hash := range f.announces[peer] {
+				f.forget(peer, hash)
+			}
+			f.scheduleFetches(&timeoutTimer, timeoutTrigger, nil)
+
+		case <-f.quit:
+			return
+		}
+		f.metrics.waitingHashes.Update(int64(len(f.waitlist)))
+		f.metrics.queuedHashes.Update(int64(len(f.announced) - len(f.fetching)))
+		f.metrics.fetchingHashes.Update(int64(len(f.fetching)))
+	}
+}
+
+// forget removes peer as a source of a queued or fetching transaction. If no
+// other peer announced the transaction, it is dropped altogether.
+func (f *TxFetcher) forget(peer string, hash common.Hash) {
+	removeHash(f.announces, peer, hash)
+	if f.fetching[hash] == peer {
+		delete(f.fetching, hash)
+	}
+	if peers := f.announced[hash]; peers != nil {
+		delete(peers, peer)
+		if len(peers) == 0 {
+			delete(f.announced, hash)
+		}
+	}
+}
+
+// scheduleFetches starts a batch of retrievals for all idle peers in the given
+// set (or all peers if nil), requesting queued transactions nobody fetches yet.
+func (f *TxFetcher) scheduleFetches(timer *mclock.Timer, trigger chan struct{}, whitelist map[string]struct{}) {
+	actives := whitelist
+	if actives == nil {
+		actives = make(map[string]struct{}, len(f.announces))
+		for peer := range f.announces {
+			actives[peer] = struct{}{}
+		}
+	}
+	idle := len(f.requests) == 0
+
+	for peer := range actives {
+		if f.requests[peer] != nil {
+			continue
+		}
+		hashes := make([]common.Hash, 0, maxTxRetrievals)
+		for hash := range f.announces[peer] {
+			if _, ok := f.fetching[hash]; ok {
+				continue
+			}
+			f.fetching[hash] = peer
+			hashes = append(hashes, hash)
+			if len(hashes) == maxTxRetrievals {
+				break
+			}
+		}
+		if len(hashes) == 0 {
+			continue
+		}
+		f.requests[peer] = &txRequest{hashes: hashes, time: f.clock.Now()}
+		f.metrics.requestOut.Mark(int64(len(hashes)))
+
+		go func(peer string, hashes []common.Hash) {
+			if err := f.fetchTxs(peer, hashes); err != nil {
+				log.Debug("Failed to request transactions", "peer", peer, "err", err)
+				f.metrics.requestFail.Mark(int64(len(hashes)))
+				f.Drop(peer)
+			}
+		}(peer, hashes)
+	}
+	if idle && len(f.requests) > 0 {
+		f.rescheduleTimeout(timer, trigger)
+	}
+}
+
+// rescheduleWait resets the wait timer to fire when the oldest waiting
+// transaction expires.
+func (f *TxFetcher) rescheduleWait(timer *mclock.Timer, trigger chan struct{}) {
+	if *timer != nil {
+		(*timer).Stop()
+	}
+	now := f.clock.Now()
+	earliest := now
+	for _, instance := range f.waittime {
+		if earliest > instance {
+			earliest = instance
+		}
+	}
+	*timer = f.clock.AfterFunc(txArriveTimeout-time.Duration(now-earliest), func() {
+		f.fire(trigger)
+	})
+}
+
+// rescheduleTimeout resets the timeout timer to fire when the oldest in-flight
+// request expires.
+func (f *TxFetcher) rescheduleTimeout(timer *mclock.Timer, trigger chan struct{}) {
+	if *timer != nil {
+		(*timer).Stop()
+	}
+	now := f.clock.Now()
+	earliest := now
+	for _, req := range f.requests {
+		if earliest > req.time {
+			earliest = req.time
+		}
+	}
+	*timer = f.clock.AfterFunc(txFetchTimeout-time.Duration(now-earliest), func() {
+		f.fire(trigger)
+	})
+}
+
+// fire signals the loop that a timer expired, coalescing pending signals.
+func (f *TxFetcher) fire(trigger chan struct{}) {
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+}
+
+// addHash adds hash to the set of the given peer.
+func addHash(set map[string]map[common.Hash]struct{}, peer string, hash common.Hash) {
+	if set[peer] == nil {
+		set[peer] = make(map[common.Hash]struct{})
+	}
+	set[peer][hash] = struct{}{}
+}
+
+// removeHash removes hash from the set of the given peer, dropping the peer
+// once its set is empty.
+func removeHash(set map[string]map[common.Hash]struct{}, peer string, hash common.Hash) {
+	delete(set[peer], hash)
+	if len(set[peer]) == 0 {
+		delete(set, peer)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/mclock"
+)
+
+var (
+	testTxHashA = common.HexToHash("0x01")
+	testTxHashB = common.HexToHash("0x02")
+	testTxHashC = common.HexToHash("0x03")
+)
+
+// txFetchRequest is a retrieval request issued by the fetcher under test.
+type txFetchRequest struct {
+	peer   string
+	hashes []common.Hash
+}
+
+// txFetcherTester is a test simulator wrapping a transaction fetcher running
+// on a simulated clock.
+type txFetcherTester struct {
+	fetcher  *TxFetcher
+	clock    *mclock.Simulated
+	requests chan txFetchRequest
+	known    map[common.Hash]bool
+}
+
+func newTxFetcherTester(known ...common.Hash) *txFetcherTester {
+	tester := &txFetcherTester{
+		clock:    new(mclock.Simulated),
+		requests: make(chan txFetchRequest, 16),
+		known:    make(map[common.Hash]bool),
+	}
+	for _, hash := range known {
+		tester.known[hash] = true
+	}
+	tester.fetcher = newTxFetcher("test", func(hash common.Hash) bool {
+		return tester.known[hash]
+	}, func(peer string, hashes []common.Hash) error {
+		tester.requests <- txFetchRequest{peer: peer, hashes: hashes}
+		return nil
+	}, tester.clock)
+	tester.fetcher.Start()
+	return tester
+}
+
+// expectRequest waits for the next retrieval request and checks its content.
+func (tester *txFetcherTester) expectRequest(t *testing.T, hashes ...common.Hash) txFetchRequest {
+	t.Helper()
+	select {
+	case req := <-tester.requests:
+		have := append([]common.Hash{}, req.hashes...)
+		sort.Slice(have, func(i, j int) bool { return have[i].Big().Cmp(have[j].Big()) < 0 })
+		if len(have) != len(hashes) {
+			t.Fatalf("request size mismatch: have %v, want %v", have, hashes)
+		}
+		for i := range have {
+			if have[i] != hashes[i] {
+				t.Fatalf("request mismatch: have %v, want %v", have, hashes)
+			}
+		}
+		return req
+	case <-time.After(time.Second):
+		t.Fatalf("request timeout: want %v", hashes)
+	}
+	return txFetchRequest{}
+}
+
+// expectNoRequest checks that no retrieval request is issued.
+func (tester *txFetcherTester) expectNoRequest(t *testing.T) {
+	t.Helper()
+	select {
+	case req := <-tester.requests:
+		t.Fatalf("unexpected request: %v %v", req.peer, req.hashes)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Tests that announced transactions are requested once the arrival timeout
+// elapsed, skipping the ones already known locally.
+func TestTxFetcherAnnounceAndFetch(t *testing.T) {
+	tester := newTxFetcherTester(testTxHashC)
+	defer tester.fetcher.Stop()
+
+	if err := tester.fetcher.Notify("A", []common.Hash{testTxHashA, testTxHashB, testTxHashC}); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+	tester.clock.WaitForTimers(1)
+	tester.clock.Run(txArriveTimeout / 2)
+	tester.expectNoRequest(t)
+
+	tester.clock.Run(txArriveTimeout / 2)
+	if req := tester.expectRequest(t, testTxHashA, testTxHashB); req.peer != "A" {
+		t.Fatalf("request sent to wrong peer: have %s, want A", req.peer)
+	}
+}
+
+// Tests that transactions arriving through a broadcast before the arrival
+// timeout are not requested.
+func TestTxFetcherBroadcastBeforeFetch(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	tester.fetcher.Notify("A", []common.Hash{testTxHashA, testTxHashB})
+	tester.fetcher.Delivered("B", []common.Hash{testTxHashA}, false)
+
+	tester.clock.WaitForTimers(1)
+	tester.clock.Run(txArriveTimeout)
+	tester.expectRequest(t, testTxHashB)
+}
+
+// Tests that transactions announced by multiple peers are only requested from
+// one of them, and rescheduled to another one if missing from the reply.
+func TestTxFetcherDedupAndMissingReply(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	tester.fetcher.Notify("A", []common.Hash{testTxHashA})
+	tester.fetcher.Notify("B", []common.Hash{testTxHashA})
+
+	tester.clock.WaitForTimers(1)
+	tester.clock.Run(txArriveTimeout)
+	first := tester.expectRequest(t, testTxHashA)
+	tester.expectNoRequest(t)
+
+	// Reply without the transaction, the alternate peer should be asked
+	tester.fetcher.Delivered(first.peer, nil, true)
+	second := tester.expectRequest(t, testTxHashA)
+	if second.peer == first.peer {
+		t.Fatalf("transaction re-requested from the same peer %s", first.peer)
+	}
+	// A complete reply finishes the retrieval
+	tester.fetcher.Delivered(second.peer, []common.Hash{testTxHashA}, true)
+	tester.expectNoRequest(t)
+}
+
+// Tests that only the transactions requested from a peer are reported as
+// requested when it replies.
+func TestTxFetcherRequested(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	if requested := tester.fetcher.Requested("A", []common.Hash{testTxHashA}); len(requested) != 0 {
+		t.Fatalf("reply without request accepted: %v", requested)
+	}
+	tester.fetcher.Notify("A", []common.Hash{testTxHashA, testTxHashB})
+
+	tester.clock.WaitForTimers(1)
+	tester.clock.Run(txArriveTimeout)
+	tester.expectRequest(t, testTxHashA, testTxHashB)
+
+	requested := tester.fetcher.Requested("A", []common.Hash{testTxHashA, testTxHashC})
+	if len(requested) != 1 || !requested[testTxHashA] {
+		t.Fatalf("requested transactions mismatch: have %v, want %v", requested, testTxHashA)
+	}
+	if requested := tester.fetcher.Requested("B", []common.Hash{testTxHashA}); len(requested) != 0 {
+		t.Fatalf("reply of another peer accepted: %v", requested)
+	}
+}
+
+// Tests that requests not answered in time are rescheduled to alternate peers.
+func TestTxFetcherTimeout(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	tester.fetcher.Notify("A", []common.Hash{testTxHashA})
+	tester.fetcher.Notify("B", []common.Hash{testTxHashA})
+
+	tester.clock.WaitForTimers(1)
+	tester.clock.Run(txArriveTimeout)
+	first := tester.expectRequest(t, testTxHashA)
+
+	tester.clock.WaitForTimers(1)
+	tester.clock.Run(txFetchTimeout)
+	second := tester.expectRequest(t, testTxHashA)
+	if second.peer == first.peer {
+		t.Fatalf("transaction re-requested from the same peer %s", first.peer)
+	}
+}
+
+// Tests that the requests of dropped peers are rescheduled to alternate peers.
+func TestTxFetcherDrop(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	tester.fetcher.Notify("A", []common.Hash{testTxHashA})
+	tester.fetcher.Notify("B", []common.Hash{testTxHashA})
+
+	tester.clock.WaitForTimers(1)
+	tester.clock.Run(txArriveTimeout)
+	first := tester.expectRequest(t, testTxHashA)
+
+	tester.fetcher.Drop(first.peer)
+	second := tester.expectRequest(t, testTxHashA)
+	if second.peer == first.peer {
+		t.Fatalf("transaction re-requested from the dropped peer %s", first.peer)
+	}
+	// Dropping the last source forgets the transaction
+	tester.fetcher.Drop(second.peer)
+	tester.expectNoRequest(t)
+}
+
+// Tests that peers cannot have more than maxTxAnnounces transactions tracked.
+func TestTxFetcherAnnounceLimit(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	hashes := make([]common.Hash, maxTxAnnounces+10)
+	for i := range hashes {
+		hashes[i] = common.BigToHash(big.NewInt(int64(i + 100)))
+	}
+	tester.fetcher.Notify("A", hashes)
+	tester.fetcher.Notify("A", []common.Hash{testTxHashA})
+	tester.fetcher.Notify("B", []common.Hash{testTxHashA})
+
+	tester.clock.WaitForTimers(1)
+	tester.clock.Run(txArriveTimeout)
+
+	// Retrieve everything, the flooding peer must only be asked for its quota
+	fetched := make(map[common.Hash]struct{})
+	for {
+		var req txFetchRequest
+		select {
+		case req = <-tester.requests:
+		case <-time.After(50 * time.Millisecond):
+		}
+		if req.peer == "" {
+			break
+		}
+		for _, hash := range req.hashes {
+			if req.peer == "A" && hash == testTxHashA {
+				t.Fatalf("over quota announcement requested")
+			}
+			if req.peer == "A" {
+				fetched[hash] = struct{}{}
+			}
+		}
+		if len(req.hashes) > maxTxRetrievals {
+			t.Fatalf("request too large: have %d, max %d", len(req.hashes), maxTxRetrievals)
+		}
+		tester.fetcher.Delivered(req.peer, req.hashes, true)
+	}
+	if len(fetched) != maxTxAnnounces {
+		t.Fatalf("fetched transaction count mismatch: have %d, want %d", len(fetched), maxTxAnnounces)
+	}
+}
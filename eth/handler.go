@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sync"
 	"sync/atomic"
@@ -50,6 +51,10 @@ const (
 	// txChanSize is the size of channel listening to TxPreEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// maxPooledTxServe is the maximum number of pooled transactions served in
+	// a single reply.
+	maxPooledTxServe = 256
 )
 
 var (
@@ -60,8 +65,57 @@ var (
 // not compatible (low protocol version restrictions and high requirements).
 var errIncompatibleConfig = errors.New("incompatible configuration")
 
+// protoError is a violation of the eth protocol by a remote peer.
+type protoError struct {
+	code errCode
+	msg  string
+}
+
+func (e *protoError) Error() string {
+	return fmt.Sprintf("%v - %v", e.code, e.msg)
+}
+
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	return &protoError{code: code, msg: fmt.Sprintf(format, v...)}
+}
+
+// peerReporter is the peer scoring misbehaving peers are reported to. It is
+// implemented by the p2p server.
+type peerReporter interface {
+	ReportPeer(id discover.NodeID, fault p2p.Misbehaviour)
+}
+
+// malformedOrderErrors are the order pool errors caused by the order itself
+// rather than by the local state, which a well behaved peer never relays.
+var malformedOrderErrors = map[error]bool{
+	core.ErrInvalidOrderFormat:      true,
+	core.ErrInvalidOrderContent:     true,
+	core.ErrInvalidOrderSide:        true,
+	core.ErrInvalidOrderType:        true,
+	core.ErrInvalidOrderStatus:      true,
+	core.ErrInvalidOrderUserAddress: true,
+	core.ErrInvalidOrderQuantity:    true,
+	core.ErrInvalidOrderPrice:       true,
+	core.ErrInvalidOrderHash:        true,
+	core.ErrInvalidSender:           true,
+	core.ErrOversizedData:           true,
+}
+
+// malformedLendingErrors are the lending pool errors caused by the lending
+// transaction itself rather than by the local state.
+var malformedLendingErrors = map[error]bool{
+	core.ErrInvalidLendingSide:        true,
+	core.ErrInvalidLendingType:        true,
+	core.ErrInvalidLendingStatus:      true,
+	core.ErrInvalidLendingUserAddress: true,
+	core.ErrInvalidLendingQuantity:    true,
+	core.ErrInvalidLendingInterest:    true,
+	core.ErrInvalidLendingRelayer:     true,
+	core.ErrInvalidLendingHash:        true,
+	core.ErrInvalidLendingTradeID:     true,
+	core.ErrInvalidLendingCollateral:  true,
+	core.ErrInvalidSender:             true,
+	core.ErrOversizedData:             true,
 }
 
 type ProtocolManager struct {
@@ -77,9 +131,15 @@ type ProtocolManager struct {
 	chainconfig *params.ChainConfig
 	maxPeers    int
 
-	downloader *downloader.Downloader
-	fetcher    *fetcher.Fetcher
-	peers      *peerSet
+	downloader     *downloader.Downloader
+	fetcher        *fetcher.Fetcher
+	txFetcher      *fetcher.TxFetcher
+	orderFetcher   *fetcher.TxFetcher
+	lendingFetcher *fetcher.TxFetcher
+	peers          *peerSet
+	checkpoint     *checkpointSync // Seeds and backfills a chain synced from a trusted checkpoint
+	reporter       atomic.Value    // peerReporter misbehaving peers are reported to, if set
+	private        atomic.Value    // func(common.Hash) bool withholding private transactions from gossip, if set
 
 	SubProtocols []p2p.Protocol
 
@@ -219,14 +279,57 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	}
 	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, prepare, manager.removePeer)
 
+	manager.downloader.SetPeerReporter(manager.reportPeer)
+	manager.fetcher.SetPeerReporter(manager.reportPeer)
+
+	hasTx := func(hash common.Hash) bool {
+		return manager.knownTxs.Contains(hash) || manager.txpool.Get(hash) != nil
+	}
+	fetchTxs := func(id string, hashes []common.Hash) error {
+		p := manager.peers.Peer(id)
+		if p == nil {
+			return errNotRegistered
+		}
+		return p.RequestTxs(hashes)
+	}
+	manager.txFetcher = fetcher.NewTxFetcher("transaction", hasTx, fetchTxs)
+
 	return manager, nil
 }
 
 func (pm *ProtocolManager) addOrderPoolProtocol(orderpool orderPool) {
 	pm.orderpool = orderpool
+	if orderpool == nil {
+		return
+	}
+	hasTx := func(hash common.Hash) bool {
+		return pm.knowOrderTxs.Contains(hash) || pm.orderpool.Get(hash) != nil
+	}
+	fetchTxs := func(id string, hashes []common.Hash) error {
+		p := pm.peers.Peer(id)
+		if p == nil {
+			return errNotRegistered
+		}
+		return p.RequestOrderTxs(hashes)
+	}
+	pm.orderFetcher = fetcher.NewTxFetcher("order", hasTx, fetchTxs)
 }
 func (pm *ProtocolManager) addLendingPoolProtocol(lendingpool lendingPool) {
 	pm.lendingpool = lendingpool
+	if lendingpool == nil {
+		return
+	}
+	hasTx := func(hash common.Hash) bool {
+		return pm.knowLendingTxs.Contains(hash) || pm.lendingpool.Get(hash) != nil
+	}
+	fetchTxs := func(id string, hashes []common.Hash) error {
+		p := pm.peers.Peer(id)
+		if p == nil {
+			return errNotRegistered
+		}
+		return p.RequestLendingTxs(hashes)
+	}
+	pm.lendingFetcher = fetcher.NewTxFetcher("lending", hasTx, fetchTxs)
 }
 func (pm *ProtocolManager) removePeer(id string) {
 	// Short circuit if the peer was already removed
@@ -236,8 +339,15 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 	log.Debug("Removing Ethereum peer", "peer", id)
 
-	// Unregister the peer from the downloader and Ethereum peer set
+	// Unregister the peer from the downloader, fetchers and Ethereum peer set
 	pm.downloader.UnregisterPeer(id)
+	pm.txFetcher.Drop(id)
+	if pm.orderFetcher != nil {
+		pm.orderFetcher.Drop(id)
+	}
+	if pm.lendingFetcher != nil {
+		pm.lendingFetcher.Drop(id)
+	}
 	if err := pm.peers.Unregister(id); err != nil {
 		log.Warn("Peer removal failed", "peer", id, "err", err)
 	}
@@ -247,6 +357,34 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+// SetPeerReporter sets the peer scoring misbehaving peers are reported to.
+func (pm *ProtocolManager) SetPeerReporter(reporter peerReporter) {
+	pm.reporter.Store(reporter)
+}
+
+// SetPrivateFilter sets the check telling which pooled transactions were
+// submitted privately and must not be gossiped until published.
+func (pm *ProtocolManager) SetPrivateFilter(private func(common.Hash) bool) {
+	pm.private.Store(private)
+}
+
+// isPrivate reports whether a transaction is withheld from gossip.
+func (pm *ProtocolManager) isPrivate(hash common.Hash) bool {
+	private, _ := pm.private.Load().(func(common.Hash) bool)
+	return private != nil && private(hash)
+}
+
+// reportPeer reports a misbehaving peer to the peer scoring, if there is any.
+func (pm *ProtocolManager) reportPeer(id string, fault p2p.Misbehaviour) {
+	reporter, _ := pm.reporter.Load().(peerReporter)
+	if reporter == nil {
+		return
+	}
+	if p := pm.peers.Peer(id); p != nil {
+		reporter.ReportPeer(p.ID(), fault)
+	}
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -265,6 +403,15 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	go pm.txBroadcastLoop()
 	go pm.orderTxBroadcastLoop()
 	go pm.lendingTxBroadcastLoop()
+
+	// start the announced transaction fetchers
+	pm.txFetcher.Start()
+	if pm.orderFetcher != nil {
+		pm.orderFetcher.Start()
+	}
+	if pm.lendingFetcher != nil {
+		pm.lendingFetcher.Start()
+	}
 	// broadcast mined blocks
 	pm.minedBlockSub = pm.eventMux.Subscribe(core.NewMinedBlockEvent{})
 	go pm.minedBroadcastLoop()
@@ -292,6 +439,13 @@ func (pm *ProtocolManager) Stop() {
 
 	// Quit fetcher, txsyncLoop.
 	close(pm.quitSync)
+	pm.txFetcher.Stop()
+	if pm.orderFetcher != nil {
+		pm.orderFetcher.Stop()
+	}
+	if pm.lendingFetcher != nil {
+		pm.lendingFetcher.Stop()
+	}
 
 	// Disconnect existing sessions.
 	// This also closes the gate for any new registrations on the peer set.
@@ -373,6 +527,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	for {
 		if err := pm.handleMsg(p); err != nil {
 			p.Log().Debug("Ethereum message handling failed", "err", err)
+			if _, ok := err.(*protoError); ok {
+				pm.reportPeer(p.id, p2p.InvalidMessage)
+			}
 			return err
 		}
 	}
@@ -502,6 +659,10 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				return nil
 			}
 		}
+		// Claim the headers requested while backfilling from a checkpoint
+		if pm.checkpoint != nil && pm.checkpoint.deliverHeaders(p.id, headers) {
+			return nil
+		}
 		// Filter out any explicitly requested headers, deliver the rest to the downloader
 		filter := len(headers) == 1
 		if filter {
@@ -570,6 +731,10 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			trasactions[i] = body.Transactions
 			uncles[i] = body.Uncles
 		}
+		// Claim the bodies requested while backfilling from a checkpoint
+		if pm.checkpoint != nil && pm.checkpoint.deliverBodies(p.id, trasactions, uncles) {
+			return nil
+		}
 		// Filter out any explicitly requested bodies, deliver the rest to the downloader
 		filter := len(trasactions) > 0 || len(uncles) > 0
 		if filter {
@@ -697,6 +862,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		request.Block.ReceivedFrom = p
 
 		// Mark the peer as owning the block and schedule it for import
+		if p.knownBlocks.Contains(request.Block.Hash()) {
+			pm.reportPeer(p.id, p2p.DuplicateMessage)
+		}
 		p.MarkBlock(request.Block.Hash())
 		pm.fetcher.Enqueue(p.id, request.Block)
 
@@ -729,13 +897,16 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := msg.Decode(&txs); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
-		var unkownTxs []*types.Transaction
-
+		var (
+			unkownTxs []*types.Transaction
+			duplicate bool
+		)
 		for i, tx := range txs {
 			// Validate and mark the remote transaction
 			if tx == nil {
 				return errResp(ErrDecode, "transaction %d is nil", i)
 			}
+			duplicate = duplicate || p.knownTxs.Contains(tx.Hash())
 			p.MarkTransaction(tx.Hash())
 			exist, _ := pm.knownTxs.ContainsOrAdd(tx.Hash(), true)
 			if !exist {
@@ -745,7 +916,11 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 
 		}
+		if duplicate {
+			pm.reportPeer(p.id, p2p.DuplicateMessage)
+		}
 		pm.txpool.AddRemotes(txs)
+		pm.txFetcher.Delivered(p.id, txHashes(txs), false)
 
 	case msg.Code == OrderTxMsg:
 		// Transactions arrived, make sure we have a valid and fresh chain to handle them
@@ -757,13 +932,16 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := msg.Decode(&txs); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
-		var unkownOrderTxs []*types.OrderTransaction
-
+		var (
+			unkownOrderTxs []*types.OrderTransaction
+			duplicate      bool
+		)
 		for i, tx := range txs {
 			// Validate and mark the remote transaction
 			if tx == nil {
 				return errResp(ErrDecode, "transaction %d is nil", i)
 			}
+			duplicate = duplicate || p.knownOrderTxs.Contains(tx.Hash())
 			p.MarkOrderTransaction(tx.Hash())
 			exist, _ := pm.knowOrderTxs.ContainsOrAdd(tx.Hash(), true)
 			if !exist {
@@ -774,8 +952,12 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 		}
 
+		if duplicate {
+			pm.reportPeer(p.id, p2p.DuplicateMessage)
+		}
 		if pm.orderpool != nil {
-			pm.orderpool.AddRemotes(txs)
+			pm.reportInvalid(p, pm.orderpool.AddRemotes(txs), malformedOrderErrors, p2p.InvalidOrder)
+			pm.orderFetcher.Delivered(p.id, orderTxHashes(txs), false)
 		}
 
 	case msg.Code == LendingTxMsg:
@@ -788,13 +970,16 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := msg.Decode(&txs); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
-		var unkownLendingTxs []*types.LendingTransaction
-
+		var (
+			unkownLendingTxs []*types.LendingTransaction
+			duplicate        bool
+		)
 		for i, tx := range txs {
 			// Validate and mark the remote transaction
 			if tx == nil {
 				return errResp(ErrDecode, "transaction %d is nil", i)
 			}
+			duplicate = duplicate || p.knownLendingTxs.Contains(tx.Hash())
 			p.MarkLendingTransaction(tx.Hash())
 			exist, _ := pm.knowLendingTxs.ContainsOrAdd(tx.Hash(), true)
 			if !exist {
@@ -805,9 +990,208 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 		}
 
+		if duplicate {
+			pm.reportPeer(p.id, p2p.DuplicateMessage)
+		}
 		if pm.lendingpool != nil {
-			pm.lendingpool.AddRemotes(txs)
+			pm.reportInvalid(p, pm.lendingpool.AddRemotes(txs), malformedLendingErrors, p2p.InvalidLendingTx)
+			pm.lendingFetcher.Delivered(p.id, lendingTxHashes(txs), false)
+		}
+
+	case p.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+		// New transaction announcements arrived, schedule the unknown ones for retrieval
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
 		}
+		pm.txFetcher.Notify(p.id, hashes)
+
+	case p.version >= eth65 && msg.Code == GetPooledTransactionsMsg:
+		hashes, txs, err := pm.collectPooled(msg, func(hash common.Hash) interface{} {
+			if tx := pm.txpool.Get(hash); tx != nil {
+				return tx
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return p.SendPooledTransactionsRLP(hashes, txs)
+
+	case p.version >= eth65 && msg.Code == PooledTransactionsMsg:
+		// A batch of transactions arrived to one of our previous requests
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, tx := range txs {
+			if tx == nil {
+				return errResp(ErrDecode, "transaction %d is nil", i)
+			}
+		}
+		// Only accept the transactions we asked the peer for
+		requested := pm.txFetcher.Requested(p.id, txHashes(txs))
+		if len(requested) < len(txs) {
+			p.Log().Debug("Dropping unrequested pooled transactions", "have", len(txs), "requested", len(requested))
+			if len(requested) == 0 {
+				break
+			}
+			filtered := make([]*types.Transaction, 0, len(requested))
+			for _, tx := range txs {
+				if requested[tx.Hash()] {
+					filtered = append(filtered, tx)
+				}
+			}
+			txs = filtered
+		}
+		for _, tx := range txs {
+			p.MarkTransaction(tx.Hash())
+			pm.knownTxs.Add(tx.Hash(), true)
+		}
+		if atomic.LoadUint32(&pm.acceptTxs) == 1 {
+			pm.txpool.AddRemotes(txs)
+		}
+		pm.txFetcher.Delivered(p.id, txHashes(txs), true)
+
+	case p.version >= eth65 && msg.Code == NewPooledOrderHashesMsg:
+		// New order announcements arrived, schedule the unknown ones for retrieval
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 || pm.orderpool == nil {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for _, hash := range hashes {
+			p.MarkOrderTransaction(hash)
+		}
+		pm.orderFetcher.Notify(p.id, hashes)
+
+	case p.version >= eth65 && msg.Code == GetPooledOrdersMsg:
+		if pm.orderpool == nil {
+			return p.SendPooledOrdersRLP(nil, nil)
+		}
+		hashes, txs, err := pm.collectPooled(msg, func(hash common.Hash) interface{} {
+			if tx := pm.orderpool.Get(hash); tx != nil {
+				return tx
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return p.SendPooledOrdersRLP(hashes, txs)
+
+	case p.version >= eth65 && msg.Code == PooledOrdersMsg:
+		// A batch of order transactions arrived to one of our previous requests
+		if pm.orderpool == nil {
+			break
+		}
+		var txs []*types.OrderTransaction
+		if err := msg.Decode(&txs); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, tx := range txs {
+			if tx == nil {
+				return errResp(ErrDecode, "transaction %d is nil", i)
+			}
+		}
+		// Only accept the orders we asked the peer for
+		requested := pm.orderFetcher.Requested(p.id, orderTxHashes(txs))
+		if len(requested) < len(txs) {
+			p.Log().Debug("Dropping unrequested pooled orders", "have", len(txs), "requested", len(requested))
+			if len(requested) == 0 {
+				break
+			}
+			filtered := make([]*types.OrderTransaction, 0, len(requested))
+			for _, tx := range txs {
+				if requested[tx.Hash()] {
+					filtered = append(filtered, tx)
+				}
+			}
+			txs = filtered
+		}
+		for _, tx := range txs {
+			p.MarkOrderTransaction(tx.Hash())
+			pm.knowOrderTxs.Add(tx.Hash(), true)
+		}
+		if atomic.LoadUint32(&pm.acceptTxs) == 1 {
+			pm.reportInvalid(p, pm.orderpool.AddRemotes(txs), malformedOrderErrors, p2p.InvalidOrder)
+		}
+		pm.orderFetcher.Delivered(p.id, orderTxHashes(txs), true)
+
+	case p.version >= eth65 && msg.Code == NewPooledLendingHashesMsg:
+		// New lending announcements arrived, schedule the unknown ones for retrieval
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 || pm.lendingpool == nil {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for _, hash := range hashes {
+			p.MarkLendingTransaction(hash)
+		}
+		pm.lendingFetcher.Notify(p.id, hashes)
+
+	case p.version >= eth65 && msg.Code == GetPooledLendingsMsg:
+		if pm.lendingpool == nil {
+			return p.SendPooledLendingsRLP(nil, nil)
+		}
+		hashes, txs, err := pm.collectPooled(msg, func(hash common.Hash) interface{} {
+			if tx := pm.lendingpool.Get(hash); tx != nil {
+				return tx
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return p.SendPooledLendingsRLP(hashes, txs)
+
+	case p.version >= eth65 && msg.Code == PooledLendingsMsg:
+		// A batch of lending transactions arrived to one of our previous requests
+		if pm.lendingpool == nil {
+			break
+		}
+		var txs []*types.LendingTransaction
+		if err := msg.Decode(&txs); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, tx := range txs {
+			if tx == nil {
+				return errResp(ErrDecode, "transaction %d is nil", i)
+			}
+		}
+		// Only accept the lending transactions we asked the peer for
+		requested := pm.lendingFetcher.Requested(p.id, lendingTxHashes(txs))
+		if len(requested) < len(txs) {
+			p.Log().Debug("Dropping unrequested pooled lendings", "have", len(txs), "requested", len(requested))
+			if len(requested) == 0 {
+				break
+			}
+			filtered := make([]*types.LendingTransaction, 0, len(requested))
+			for _, tx := range txs {
+				if requested[tx.Hash()] {
+					filtered = append(filtered, tx)
+				}
+			}
+			txs = filtered
+		}
+		for _, tx := range txs {
+			p.MarkLendingTransaction(tx.Hash())
+			pm.knowLendingTxs.Add(tx.Hash(), true)
+		}
+		if atomic.LoadUint32(&pm.acceptTxs) == 1 {
+			pm.reportInvalid(p, pm.lendingpool.AddRemotes(txs), malformedLendingErrors, p2p.InvalidLendingTx)
+		}
+		pm.lendingFetcher.Delivered(p.id, lendingTxHashes(txs), true)
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -815,6 +1199,73 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	return nil
 }
 
+// reportInvalid reports a peer if any of the items it relayed in a message was
+// rejected by a pool with one of the given malformed item errors.
+func (pm *ProtocolManager) reportInvalid(p *peer, errs []error, malformed map[error]bool, fault p2p.Misbehaviour) {
+	for _, err := range errs {
+		if err != nil && malformed[err] {
+			pm.reportPeer(p.id, fault)
+			return
+		}
+	}
+}
+
+// collectPooled gathers the RLP encoding of the pooled transactions requested
+// in a retrieval message until the fetch or network limits is reached. Unknown
+// transactions are skipped.
+func (pm *ProtocolManager) collectPooled(msg p2p.Msg, get func(common.Hash) interface{}) ([]common.Hash, []rlp.RawValue, error) {
+	msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+	if _, err := msgStream.List(); err != nil {
+		return nil, nil, err
+	}
+	var (
+		hash   common.Hash
+		bytes  int
+		hashes []common.Hash
+		txs    []rlp.RawValue
+	)
+	for bytes < softResponseLimit && len(txs) < maxPooledTxServe {
+		// Retrieve the hash of the next transaction
+		if err := msgStream.Decode(&hash); err == rlp.EOL {
+			break
+		} else if err != nil {
+			return nil, nil, errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Retrieve the requested transaction, skipping if unknown to us or private
+		if pm.isPrivate(hash) {
+			continue
+		}
+		tx := get(hash)
+		if tx == nil {
+			continue
+		}
+		encoded, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			log.Error("Failed to encode transaction", "err", err)
+			continue
+		}
+		hashes = append(hashes, hash)
+		txs = append(txs, encoded)
+		bytes += len(encoded)
+	}
+	return hashes, txs, nil
+}
+
+// splitBroadcast selects the peers a new transaction is propagated to in full:
+// a square root subset of the peers plus all the peers without support for
+// transaction announcements. The remaining peers are only sent the hash.
+func splitBroadcast(peers []*peer) (direct []*peer, announce []*peer) {
+	limit := int(math.Sqrt(float64(len(peers))))
+	for _, peer := range peers {
+		if peer.version < eth65 || len(direct) < limit {
+			direct = append(direct, peer)
+		} else {
+			announce = append(announce, peer)
+		}
+	}
+	return direct, announce
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
@@ -847,40 +1298,89 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 	}
 }
 
-// BroadcastTx will propagate a transaction to all peers which are not known to
-// already have the given transaction.
+// BroadcastTx will propagate a transaction to a square root subset of the peers
+// not known to already have it, and announce its hash to the others.
 func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction) {
+	// Private transactions are only relayed to the block producers
+	if pm.isPrivate(hash) {
+		log.Trace("Withheld private transaction from broadcast", "hash", hash)
+		return
+	}
 	// Broadcast transaction to a batch of peers not knowing about it
-	peers := pm.peers.PeersWithoutTx(hash)
-	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
-	for _, peer := range peers {
+	direct, announce := splitBroadcast(pm.peers.PeersWithoutTx(hash))
+	for _, peer := range direct {
 		peer.SendTransactions(types.Transactions{tx})
 	}
-	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(peers))
+	// Announce it to the rest, they will fetch it unless it arrives otherwise
+	for _, peer := range announce {
+		peer.SendPooledTransactionHashes([]common.Hash{hash})
+	}
+	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(direct), "announced", len(announce))
 }
 
-// OrderBroadcastTx will propagate a transaction to all peers which are not known to
-// already have the given transaction.
+// OrderBroadcastTx will propagate an order transaction to a square root subset
+// of the peers not known to already have it, and announce its hash to the others.
 func (pm *ProtocolManager) OrderBroadcastTx(hash common.Hash, tx *types.OrderTransaction) {
+	// Private transactions are only relayed to the block producers
+	if pm.isPrivate(hash) {
+		log.Trace("Withheld private transaction from broadcast", "hash", hash)
+		return
+	}
 	// Broadcast transaction to a batch of peers not knowing about it
-	peers := pm.peers.OrderPeersWithoutTx(hash)
-	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
-	for _, peer := range peers {
+	direct, announce := splitBroadcast(pm.peers.OrderPeersWithoutTx(hash))
+	for _, peer := range direct {
 		peer.SendOrderTransactions(types.OrderTransactions{tx})
 	}
-	log.Trace("Broadcast order transaction", "hash", hash, "recipients", len(peers))
+	for _, peer := range announce {
+		peer.SendPooledOrderHashes([]common.Hash{hash})
+	}
+	log.Trace("Broadcast order transaction", "hash", hash, "recipients", len(direct), "announced", len(announce))
 }
 
-// LendingBroadcastTx will propagate a transaction to all peers which are not known to
-// already have the given transaction.
+// LendingBroadcastTx will propagate a lending transaction to a square root subset
+// of the peers not known to already have it, and announce its hash to the others.
 func (pm *ProtocolManager) LendingBroadcastTx(hash common.Hash, tx *types.LendingTransaction) {
+	// Private transactions are only relayed to the block producers
+	if pm.isPrivate(hash) {
+		log.Trace("Withheld private transaction from broadcast", "hash", hash)
+		return
+	}
 	// Broadcast transaction to a batch of peers not knowing about it
-	peers := pm.peers.LendingPeersWithoutTx(hash)
-	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
-	for _, peer := range peers {
+	direct, announce := splitBroadcast(pm.peers.LendingPeersWithoutTx(hash))
+	for _, peer := range direct {
 		peer.SendLendingTransactions(types.LendingTransactions{tx})
 	}
-	log.Trace("Broadcast lending transaction", "hash", hash, "recipients", len(peers))
+	for _, peer := range announce {
+		peer.SendPooledLendingHashes([]common.Hash{hash})
+	}
+	log.Trace("Broadcast lending transaction", "hash", hash, "recipients", len(direct), "announced", len(announce))
+}
+
+// txHashes returns the hashes of a batch of transactions.
+func txHashes(txs []*types.Transaction) []common.Hash {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes
+}
+
+// orderTxHashes returns the hashes of a batch of order transactions.
+func orderTxHashes(txs []*types.OrderTransaction) []common.Hash {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes
+}
+
+// lendingTxHashes returns the hashes of a batch of lending transactions.
+func lendingTxHashes(txs []*types.LendingTransaction) []common.Hash {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes
 }
 
 // minedBroadcastLoop broadcast loop
@@ -0,0 +1,327 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+var (
+	meshMasternodesGauge = metrics.NewRegisteredGauge("eth/mesh/masternodes", nil)
+	meshResolvedGauge    = metrics.NewRegisteredGauge("eth/mesh/resolved", nil)
+	meshConnectedGauge   = metrics.NewRegisteredGauge("eth/mesh/connected", nil)
+	meshDropMeter        = metrics.NewRegisteredMeter("eth/mesh/drops", nil)
+)
+
+// errMasternodeRecordSigner is returned if a masternode record is not signed by
+// the masternode it names.
+var errMasternodeRecordSigner = errors.New("masternode record not signed by masternode")
+
+// masternodeRecordPrefix separates masternode record signatures from any other
+// signature made with a coinbase key.
+var masternodeRecordPrefix = []byte("\x19TomoChain Masternode Record:\n")
+
+// MasternodeRecord binds the enode URL of a masternode's p2p server to its
+// coinbase address. A signature by the coinbase key allows the record to be
+// taken from untrusted places.
+type MasternodeRecord struct {
+	Address   common.Address `json:"address"`
+	Enode     string         `json:"enode"`
+	Signature hexutil.Bytes  `json:"signature,omitempty"`
+}
+
+// masternodeRecordHash returns the hash signed by a masternode to vouch for the
+// given enode URL.
+func masternodeRecordHash(enode string) []byte {
+	return crypto.Keccak256(masternodeRecordPrefix, []byte(enode))
+}
+
+// Verify checks that the record is signed by the masternode it names.
+func (r *MasternodeRecord) Verify() error {
+	if len(r.Signature) != 65 {
+		return errMasternodeRecordSigner
+	}
+	pubkey, err := crypto.SigToPub(masternodeRecordHash(r.Enode), r.Signature)
+	if err != nil {
+		return err
+	}
+	if crypto.PubkeyToAddress(*pubkey) != r.Address {
+		return errMasternodeRecordSigner
+	}
+	return nil
+}
+
+// loadMasternodeRegistry reads the masternode records of a registry file. Signed
+// records are only accepted if the signature matches, unsigned ones are trusted
+// as local configuration.
+func loadMasternodeRegistry(path string) (map[common.Address]*discover.Node, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []*MasternodeRecord
+	if err := json.Unmarshal(blob, &records); err != nil {
+		return nil, fmt.Errorf("invalid masternode registry %s: %v", path, err)
+	}
+	nodes := make(map[common.Address]*discover.Node, len(records))
+	for _, record := range records {
+		node, err := discover.ParseNode(record.Enode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enode of masternode %x: %v", record.Address, err)
+		}
+		if len(record.Signature) > 0 {
+			if err := record.Verify(); err != nil {
+				log.Warn("Skipping masternode record", "address", record.Address, "err", err)
+				continue
+			}
+		}
+		nodes[record.Address] = node
+	}
+	return nodes, nil
+}
+
+// meshServer is the part of the p2p server the masternode mesh maintains the
+// reserved connections with.
+type meshServer interface {
+	Self() *discover.Node
+	AddPeer(node *discover.Node)
+	RemovePeer(node *discover.Node)
+	AddTrustedPeer(node *discover.Node)
+	RemoveTrustedPeer(node *discover.Node)
+	Peers() []*p2p.Peer
+	SubscribeEvents(ch chan *p2p.PeerEvent) event.Subscription
+}
+
+// meshChain is the part of the chain the masternode mesh follows checkpoints on.
+type meshChain interface {
+	CurrentHeader() *types.Header
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// MasternodeMeshInfo represents the connectivity to a masternode of the current
+// epoch.
+type MasternodeMeshInfo struct {
+	Address   common.Address `json:"address"`
+	Enode     string         `json:"enode,omitempty"`
+	Connected bool           `json:"connected"`
+}
+
+// masternodeMesh keeps static, trusted connections to the masternodes of the
+// current epoch, so that they are always connected to each other regardless of
+// the peer limits. The set is re-evaluated at every checkpoint block.
+type masternodeMesh struct {
+	server      meshServer
+	chain       meshChain
+	masternodes func(*types.Header) []common.Address // Masternodes of the epoch of a header
+	epoch       uint64
+	registry    string // Path of the masternode registry file
+
+	current   []common.Address                  // Masternodes of the current epoch
+	reserved  map[common.Address]*discover.Node // Masternodes with a reserved connection
+	connected map[discover.NodeID]int           // Connections to reserved nodes (pair peers count twice)
+	lock      sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newMasternodeMesh(server meshServer, chain meshChain, masternodes func(*types.Header) []common.Address, epoch uint64, registry string) *masternodeMesh {
+	return &masternodeMesh{
+		server:      server,
+		chain:       chain,
+		masternodes: masternodes,
+		epoch:       epoch,
+		registry:    registry,
+		reserved:    make(map[common.Address]*discover.Node),
+		connected:   make(map[discover.NodeID]int),
+		quit:        make(chan struct{}),
+	}
+}
+
+// start reserves connections to the masternodes of the current epoch and keeps
+// them up to date in the background.
+func (m *masternodeMesh) start() error {
+	// Refuse starting with a broken registry, later failures keep the old set
+	if m.registry == "" {
+		return errors.New("masternode mesh requires a masternode registry")
+	}
+	if _, err := loadMasternodeRegistry(m.registry); err != nil {
+		return err
+	}
+	var (
+		headCh  = make(chan core.ChainHeadEvent, 16)
+		headSub = m.chain.SubscribeChainHeadEvent(headCh)
+		peerCh  = make(chan *p2p.PeerEvent, 64)
+		peerSub = m.server.SubscribeEvents(peerCh)
+	)
+	m.refresh(m.chain.CurrentHeader())
+
+	m.wg.Add(1)
+	go m.loop(headCh, headSub, peerCh, peerSub)
+	return nil
+}
+
+// stop terminates the mesh maintenance. Reserved connections are kept, as the
+// p2p server is shutting down alongside.
+func (m *masternodeMesh) stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+func (m *masternodeMesh) loop(headCh chan core.ChainHeadEvent, headSub event.Subscription, peerCh chan *p2p.PeerEvent, peerSub event.Subscription) {
+	defer m.wg.Done()
+	defer headSub.Unsubscribe()
+	defer peerSub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-headCh:
+			if ev.Block.NumberU64()%m.epoch == 0 {
+				m.refresh(ev.Block.Header())
+			}
+		case ev := <-peerCh:
+			m.peerEvent(ev)
+
+		case <-headSub.Err():
+			return
+		case <-peerSub.Err():
+			return
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// refresh reserves connections to the masternodes of the epoch of the given
+// header and releases those reserved for masternodes no longer in the set.
+func (m *masternodeMesh) refresh(header *types.Header) {
+	masternodes := m.masternodes(header)
+
+	enodes, err := loadMasternodeRegistry(m.registry)
+	if err != nil {
+		log.Warn("Failed to reload masternode registry", "err", err)
+		return
+	}
+	self := m.server.Self().ID
+	peers := m.server.Peers()
+
+	wanted := make(map[common.Address]*discover.Node)
+	for _, addr := range masternodes {
+		if node := enodes[addr]; node != nil && node.ID != self {
+			wanted[addr] = node
+		}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for addr, node := range m.reserved {
+		if wanted[addr] == nil || wanted[addr].ID != node.ID {
+			log.Debug("Releasing masternode connection", "address", addr, "enode", node)
+			m.server.RemoveTrustedPeer(node)
+			m.server.RemovePeer(node)
+			delete(m.connected, node.ID)
+			delete(m.reserved, addr)
+		}
+	}
+	for addr, node := range wanted {
+		if m.reserved[addr] == nil {
+			log.Debug("Reserving masternode connection", "address", addr, "enode", node)
+			m.server.AddTrustedPeer(node)
+			m.server.AddPeer(node)
+			m.reserved[addr] = node
+
+			// Account for masternodes connected before being reserved
+			for _, p := range peers {
+				if p.ID() == node.ID && m.connected[node.ID] == 0 {
+					m.connected[node.ID] = 1
+				}
+			}
+		}
+	}
+	m.current = masternodes
+
+	log.Info("Updated masternode mesh", "number", header.Number, "masternodes", len(masternodes), "reserved", len(m.reserved))
+	meshMasternodesGauge.Update(int64(len(masternodes)))
+	meshResolvedGauge.Update(int64(len(m.reserved)))
+	meshConnectedGauge.Update(int64(len(m.connected)))
+}
+
+// peerEvent tracks the connectivity to the reserved masternodes.
+func (m *masternodeMesh) peerEvent(ev *p2p.PeerEvent) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	reserved := false
+	for _, node := range m.reserved {
+		if node.ID == ev.Peer {
+			reserved = true
+			break
+		}
+	}
+	if !reserved {
+		return
+	}
+	switch ev.Type {
+	case p2p.PeerEventTypeAdd:
+		m.connected[ev.Peer]++
+	case p2p.PeerEventTypeDrop:
+		if m.connected[ev.Peer] <= 1 {
+			if m.connected[ev.Peer] == 1 {
+				log.Debug("Lost masternode connection", "id", ev.Peer)
+				meshDropMeter.Mark(1)
+			}
+			delete(m.connected, ev.Peer)
+		} else {
+			m.connected[ev.Peer]--
+		}
+	}
+	meshConnectedGauge.Update(int64(len(m.connected)))
+}
+
+// info returns the connectivity to the masternodes of the current epoch.
+func (m *masternodeMesh) info() []*MasternodeMeshInfo {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	infos := make([]*MasternodeMeshInfo, 0, len(m.current))
+	for _, addr := range m.current {
+		info := &MasternodeMeshInfo{Address: addr}
+		if node := m.reserved[addr]; node != nil {
+			info.Enode = node.String()
+			info.Connected = m.connected[node.ID] > 0
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Address.Hex() < infos[j].Address.Hex() })
+	return infos
+}
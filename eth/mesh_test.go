@@ -0,0 +1,236 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+// testMeshServer records the reserved connections requested by the mesh.
+type testMeshServer struct {
+	self  *discover.Node
+	feed  event.Feed
+	calls chan string
+}
+
+func (s *testMeshServer) Self() *discover.Node        { return s.self }
+func (s *testMeshServer) AddPeer(n *discover.Node)    { s.calls <- fmt.Sprintf("add %x", n.ID[:4]) }
+func (s *testMeshServer) RemovePeer(n *discover.Node) { s.calls <- fmt.Sprintf("remove %x", n.ID[:4]) }
+func (s *testMeshServer) AddTrustedPeer(n *discover.Node) {
+	s.calls <- fmt.Sprintf("trust %x", n.ID[:4])
+}
+func (s *testMeshServer) RemoveTrustedPeer(n *discover.Node) {
+	s.calls <- fmt.Sprintf("distrust %x", n.ID[:4])
+}
+func (s *testMeshServer) Peers() []*p2p.Peer { return nil }
+func (s *testMeshServer) SubscribeEvents(ch chan *p2p.PeerEvent) event.Subscription {
+	return s.feed.Subscribe(ch)
+}
+
+// testMeshChain serves the masternode sets of the epochs by checkpoint number.
+type testMeshChain struct {
+	head *types.Header
+	feed event.Feed
+
+	sets map[uint64][]common.Address
+	lock sync.Mutex
+}
+
+func (c *testMeshChain) CurrentHeader() *types.Header { return c.head }
+func (c *testMeshChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+func (c *testMeshChain) masternodes(header *types.Header) []common.Address {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.sets[header.Number.Uint64()-header.Number.Uint64()%10]
+}
+
+func newTestMasternode(t *testing.T, seed byte) (common.Address, *discover.Node, func(string) []byte) {
+	key, err := crypto.ToECDSA(common.LeftPadBytes([]byte{seed}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodekey, err := crypto.ToECDSA(common.LeftPadBytes([]byte{seed, 1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := discover.NewNode(discover.PubkeyID(&nodekey.PublicKey), []byte{10, 0, 0, seed}, 30303, 30303)
+	sign := func(enode string) []byte {
+		sig, err := crypto.Sign(masternodeRecordHash(enode), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	return crypto.PubkeyToAddress(key.PublicKey), node, sign
+}
+
+func writeTestRegistry(t *testing.T, records []*MasternodeRecord) string {
+	dir, err := ioutil.TempDir("", "tomo-mesh-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "registry.json")
+	blob, _ := json.Marshal(records)
+	if err := ioutil.WriteFile(path, blob, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMasternodeRecordVerify(t *testing.T) {
+	addr, node, sign := newTestMasternode(t, 1)
+	record := &MasternodeRecord{Address: addr, Enode: node.String(), Signature: sign(node.String())}
+	if err := record.Verify(); err != nil {
+		t.Fatalf("valid record rejected: %v", err)
+	}
+	record.Enode = discover.NewNode(node.ID, []byte{10, 0, 0, 99}, 30303, 30303).String()
+	if err := record.Verify(); err != errMasternodeRecordSigner {
+		t.Fatalf("tampered record error mismatch: have %v, want %v", err, errMasternodeRecordSigner)
+	}
+}
+
+func TestLoadMasternodeRegistry(t *testing.T) {
+	var (
+		addr1, node1, sign1 = newTestMasternode(t, 1)
+		addr2, node2, _     = newTestMasternode(t, 2)
+		addr3, node3, _     = newTestMasternode(t, 3)
+	)
+	path := writeTestRegistry(t, []*MasternodeRecord{
+		{Address: addr1, Enode: node1.String(), Signature: sign1(node1.String())}, // signed
+		{Address: addr2, Enode: node2.String()},                                   // local config
+		{Address: addr3, Enode: node3.String(), Signature: sign1(node3.String())}, // signed by someone else
+	})
+	defer os.RemoveAll(filepath.Dir(path))
+
+	nodes, err := loadMasternodeRegistry(path)
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+	if len(nodes) != 2 || nodes[addr1].ID != node1.ID || nodes[addr2].ID != node2.ID {
+		t.Errorf("registry mismatch: %v", nodes)
+	}
+}
+
+func TestMasternodeMesh(t *testing.T) {
+	var (
+		addr1, node1, _ = newTestMasternode(t, 1)
+		addr2, node2, _ = newTestMasternode(t, 2)
+		addr3, node3, _ = newTestMasternode(t, 3)
+		addr4, _, _     = newTestMasternode(t, 4) // not in the registry
+		self, nself, _  = newTestMasternode(t, 5)
+	)
+	path := writeTestRegistry(t, []*MasternodeRecord{
+		{Address: addr1, Enode: node1.String()},
+		{Address: addr2, Enode: node2.String()},
+		{Address: addr3, Enode: node3.String()},
+		{Address: self, Enode: nself.String()},
+	})
+	defer os.RemoveAll(filepath.Dir(path))
+
+	server := &testMeshServer{self: nself, calls: make(chan string, 16)}
+	chain := &testMeshChain{
+		head: &types.Header{Number: big.NewInt(15)},
+		sets: map[uint64][]common.Address{
+			10: {addr1, addr2, addr4, self},
+			20: {addr2, addr3, self},
+		},
+	}
+	mesh := newMasternodeMesh(server, chain, chain.masternodes, 10, path)
+	if err := mesh.start(); err != nil {
+		t.Fatalf("failed to start mesh: %v", err)
+	}
+	defer mesh.stop()
+
+	expect := func(calls ...string) {
+		t.Helper()
+		want := make(map[string]bool)
+		for _, call := range calls {
+			want[call] = true
+		}
+		for range calls {
+			select {
+			case call := <-server.calls:
+				if !want[call] {
+					t.Errorf("unexpected call %q", call)
+				}
+				delete(want, call)
+			case <-time.After(time.Second):
+				t.Fatalf("missing calls: %v", want)
+			}
+		}
+		select {
+		case call := <-server.calls:
+			t.Errorf("unexpected call %q", call)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	// The resolvable masternodes of the current epoch must be reserved
+	expect(
+		fmt.Sprintf("trust %x", node1.ID[:4]), fmt.Sprintf("add %x", node1.ID[:4]),
+		fmt.Sprintf("trust %x", node2.ID[:4]), fmt.Sprintf("add %x", node2.ID[:4]),
+	)
+	// Connectivity must be tracked for reserved nodes only
+	server.feed.Send(&p2p.PeerEvent{Type: p2p.PeerEventTypeAdd, Peer: node1.ID})
+	server.feed.Send(&p2p.PeerEvent{Type: p2p.PeerEventTypeAdd, Peer: node3.ID})
+	time.Sleep(50 * time.Millisecond)
+
+	infos := mesh.info()
+	if len(infos) != 4 {
+		t.Fatalf("mesh info length mismatch: have %d, want 4", len(infos))
+	}
+	connected := 0
+	for _, info := range infos {
+		if info.Connected {
+			connected++
+			if info.Address != addr1 {
+				t.Errorf("unexpected connected masternode %x", info.Address)
+			}
+		}
+	}
+	if connected != 1 {
+		t.Errorf("connected masternodes mismatch: have %d, want 1", connected)
+	}
+	// Non-checkpoint blocks must not change anything
+	chain.feed.Send(core.ChainHeadEvent{Block: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(16)})})
+	expect()
+
+	// The next checkpoint must swap the reserved connections
+	chain.feed.Send(core.ChainHeadEvent{Block: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(20)})})
+	expect(
+		fmt.Sprintf("distrust %x", node1.ID[:4]), fmt.Sprintf("remove %x", node1.ID[:4]),
+		fmt.Sprintf("trust %x", node3.ID[:4]), fmt.Sprintf("add %x", node3.ID[:4]),
+	)
+}
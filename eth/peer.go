@@ -184,6 +184,61 @@ func (p *peer) SendLendingTransactions(txs types.LendingTransactions) error {
 	return p2p.Send(p.rw, LendingTxMsg, txs)
 }
 
+// SendPooledTransactionHashes announces the availability of a batch of
+// transactions through a hash notification and includes the hashes in the
+// transaction hash set of the peer for future reference.
+func (p *peer) SendPooledTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes)
+}
+
+// SendPooledOrderHashes announces the availability of a batch of order
+// transactions through a hash notification.
+func (p *peer) SendPooledOrderHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownOrderTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, NewPooledOrderHashesMsg, hashes)
+}
+
+// SendPooledLendingHashes announces the availability of a batch of lending
+// transactions through a hash notification.
+func (p *peer) SendPooledLendingHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownLendingTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, NewPooledLendingHashesMsg, hashes)
+}
+
+// SendPooledTransactionsRLP sends requested transactions to the peer from an
+// already RLP encoded format.
+func (p *peer) SendPooledTransactionsRLP(hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, PooledTransactionsMsg, txs)
+}
+
+// SendPooledOrdersRLP sends requested order transactions to the peer from an
+// already RLP encoded format.
+func (p *peer) SendPooledOrdersRLP(hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.knownOrderTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, PooledOrdersMsg, txs)
+}
+
+// SendPooledLendingsRLP sends requested lending transactions to the peer from
+// an already RLP encoded format.
+func (p *peer) SendPooledLendingsRLP(hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.knownLendingTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, PooledLendingsMsg, txs)
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -321,6 +376,24 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	}
 }
 
+// RequestTxs fetches a batch of transactions from a remote node.
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of transactions", "count", len(hashes))
+	return p2p.Send(p.rw, GetPooledTransactionsMsg, hashes)
+}
+
+// RequestOrderTxs fetches a batch of order transactions from a remote node.
+func (p *peer) RequestOrderTxs(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of order transactions", "count", len(hashes))
+	return p2p.Send(p.rw, GetPooledOrdersMsg, hashes)
+}
+
+// RequestLendingTxs fetches a batch of lending transactions from a remote node.
+func (p *peer) RequestLendingTxs(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of lending transactions", "count", len(hashes))
+	return p2p.Send(p.rw, GetPooledLendingsMsg, hashes)
+}
+
 // Handshake executes the eth protocol handshake, negotiating version number,
 // network IDs, difficulties, head and genesis blocks.
 func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
@@ -456,6 +529,18 @@ func (ps *peerSet) Len() int {
 	return len(ps.peers)
 }
 
+// List retrieves all the registered peers.
+func (ps *peerSet) List() []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		list = append(list, p)
+	}
+	return list
+}
+
 // PeersWithoutBlock retrieves a list of peers that do not have a given block in
 // their set of known hashes.
 func (ps *peerSet) PeersWithoutBlock(hash common.Hash) []*peer {
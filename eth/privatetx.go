@@ -0,0 +1,257 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/eth/privtx"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// privateTxFanout is the number of upcoming block producers a private
+// transaction is handed to, covering a producer missing its turn.
+const privateTxFanout = 2
+
+// errPrivateTxDisabled is returned when submitting private transactions on a
+// node unable to resolve the upcoming block producers.
+var errPrivateTxDisabled = errors.New("private transactions require the posv engine and a masternode registry")
+
+// privateTxBackend gives the private transaction relay access to the chain, the
+// pools and the posv producer rotation.
+type privateTxBackend struct {
+	eth    *Ethereum
+	engine *posv.Posv
+
+	registry map[common.Address]discover.NodeID // Masternode enodes, reloaded every epoch
+	loaded   uint64                             // Epoch the registry was loaded at
+	lock     sync.Mutex
+}
+
+// CurrentHeader implements privtx.Backend.
+func (b *privateTxBackend) CurrentHeader() *types.Header {
+	return b.eth.blockchain.CurrentHeader()
+}
+
+// SubscribeChainHeadEvent implements privtx.Backend.
+func (b *privateTxBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.eth.blockchain.SubscribeChainHeadEvent(ch)
+}
+
+// Producers implements privtx.Backend, following the posv round robin from the
+// creator of the given header.
+func (b *privateTxBackend) Producers(header *types.Header, count int) []discover.NodeID {
+	registry := b.resolver(header)
+	if len(registry) == 0 {
+		return nil
+	}
+	masternodes := b.engine.GetMasternodes(b.eth.blockchain, header)
+	if len(masternodes) == 0 {
+		return nil
+	}
+	_, prev, _, _, err := b.engine.YourTurn(b.eth.blockchain, header, common.Address{})
+	if err != nil {
+		log.Debug("Failed to determine block producer order", "number", header.Number, "err", err)
+		return nil
+	}
+	var ids []discover.NodeID
+	for i := 1; i <= count && i <= len(masternodes); i++ {
+		if id, ok := registry[masternodes[(prev+i)%len(masternodes)]]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// resolver returns the masternode registry, reloading it on epoch changes to
+// pick up updated records.
+func (b *privateTxBackend) resolver(header *types.Header) map[common.Address]discover.NodeID {
+	path := b.eth.config.MasternodeRegistry
+	if path == "" {
+		return nil
+	}
+	epoch := header.Number.Uint64() / b.eth.chainConfig.Posv.Epoch
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.registry == nil || b.loaded != epoch {
+		nodes, err := loadMasternodeRegistry(path)
+		if err != nil {
+			log.Warn("Failed to load masternode registry", "err", err)
+			return b.registry
+		}
+		b.registry = make(map[common.Address]discover.NodeID, len(nodes))
+		for addr, node := range nodes {
+			b.registry[addr] = node.ID
+		}
+		b.loaded = epoch
+	}
+	return b.registry
+}
+
+// decode parses the payload of a private transaction envelope.
+func (b *privateTxBackend) decode(env *privtx.Envelope) (interface{}, error) {
+	switch env.Kind {
+	case privtx.Transaction:
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(env.Payload); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	case privtx.Order:
+		tx := new(types.OrderTransaction)
+		if err := rlp.DecodeBytes(env.Payload, tx); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	case privtx.Lending:
+		tx := new(types.LendingTransaction)
+		if err := rlp.DecodeBytes(env.Payload, tx); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	return nil, privtx.ErrUnknownKind
+}
+
+// Hash implements privtx.Backend.
+func (b *privateTxBackend) Hash(env *privtx.Envelope) (common.Hash, error) {
+	tx, err := b.decode(env)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	switch tx := tx.(type) {
+	case *types.Transaction:
+		return tx.Hash(), nil
+	case *types.OrderTransaction:
+		return tx.Hash(), nil
+	default:
+		return tx.(*types.LendingTransaction).Hash(), nil
+	}
+}
+
+// Import implements privtx.Backend, pooling the transaction as a remote one.
+func (b *privateTxBackend) Import(env *privtx.Envelope) error {
+	tx, err := b.decode(env)
+	if err != nil {
+		return err
+	}
+	switch tx := tx.(type) {
+	case *types.Transaction:
+		return b.eth.txPool.AddRemote(tx)
+	case *types.OrderTransaction:
+		return b.eth.orderPool.AddRemote(tx)
+	default:
+		return b.eth.lendingPool.AddRemote(tx.(*types.LendingTransaction))
+	}
+}
+
+// Publish implements privtx.Backend, pooling the transaction as a local one. A
+// transaction already pooled while private is broadcast explicitly, as the
+// pool only announces new arrivals.
+func (b *privateTxBackend) Publish(env *privtx.Envelope) error {
+	tx, err := b.decode(env)
+	if err != nil {
+		return err
+	}
+	pm := b.eth.protocolManager
+	switch tx := tx.(type) {
+	case *types.Transaction:
+		if b.eth.txPool.Get(tx.Hash()) != nil {
+			pm.BroadcastTx(tx.Hash(), tx)
+			return nil
+		}
+		return b.eth.txPool.AddLocal(tx)
+	case *types.OrderTransaction:
+		if b.eth.orderPool.Get(tx.Hash()) != nil {
+			pm.OrderBroadcastTx(tx.Hash(), tx)
+			return nil
+		}
+		return b.eth.orderPool.AddLocal(tx)
+	default:
+		lendingTx := tx.(*types.LendingTransaction)
+		if b.eth.lendingPool.Get(lendingTx.Hash()) != nil {
+			pm.LendingBroadcastTx(lendingTx.Hash(), lendingTx)
+			return nil
+		}
+		return b.eth.lendingPool.AddLocal(lendingTx)
+	}
+}
+
+// Included implements privtx.Backend by checking whether the account nonce at
+// the head moved past the nonce of the transaction.
+func (b *privateTxBackend) Included(env *privtx.Envelope) bool {
+	tx, err := b.decode(env)
+	if err != nil {
+		return false
+	}
+	block := b.eth.blockchain.CurrentBlock()
+	switch tx := tx.(type) {
+	case *types.Transaction:
+		from, err := types.Sender(types.MakeSigner(b.eth.chainConfig, block.Number()), tx)
+		if err != nil {
+			return false
+		}
+		statedb, err := b.eth.blockchain.State()
+		if err != nil {
+			return false
+		}
+		return statedb.GetNonce(from) > tx.Nonce()
+
+	case *types.OrderTransaction:
+		nonce, err := b.eth.ApiBackend.GetOrderNonce(tx.UserAddress().Hash())
+		return err == nil && nonce > tx.Nonce()
+
+	default:
+		if b.eth.Lending == nil {
+			return false
+		}
+		author, err := b.engine.Author(block.Header())
+		if err != nil {
+			return false
+		}
+		lendingState, err := b.eth.Lending.GetLendingState(block, author)
+		if err != nil {
+			return false
+		}
+		lendingTx := tx.(*types.LendingTransaction)
+		return lendingState.GetNonce(lendingTx.UserAddress().Hash()) > lendingTx.Nonce()
+	}
+}
+
+// sendPrivate wraps an encoded transaction into an envelope and submits it to
+// the private transaction relay. A zero expiry selects the default one.
+func (s *Ethereum) sendPrivate(ctx context.Context, kind privtx.Kind, payload []byte, expiry uint64, fallback bool) error {
+	if s.privateTxs == nil || s.config.MasternodeRegistry == "" {
+		return errPrivateTxDisabled
+	}
+	if expiry == 0 {
+		expiry = s.blockchain.CurrentHeader().Number.Uint64() + privtx.DefaultExpiry
+	}
+	_, err := s.privateTxs.Submit(&privtx.Envelope{Kind: kind, Expiry: expiry, Payload: payload}, fallback)
+	return err
+}
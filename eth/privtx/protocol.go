@@ -0,0 +1,112 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package privtx implements a private transaction submission channel, handing
+// signed transactions, orders and lending transactions directly to the
+// masternodes expected to produce the upcoming blocks instead of gossiping them
+// to every peer.
+package privtx
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	privtx1 = 1
+)
+
+// ProtocolName is the official short name of the protocol used during
+// capability negotiation.
+var ProtocolName = "privtx"
+
+// ProtocolVersions are the supported versions of the privtx protocol (first is
+// primary).
+var ProtocolVersions = []uint{privtx1}
+
+// protocolLengths are the number of implemented message corresponding to
+// different protocol versions.
+var protocolLengths = map[uint]uint64{privtx1: 1}
+
+// maxMessageSize is the maximum cap on the size of a protocol message.
+const maxMessageSize = 1024 * 1024
+
+// maxEnvelopes is the maximum number of transactions accepted in one message.
+const maxEnvelopes = 256
+
+// privtx protocol message codes
+const (
+	PrivateTxsMsg = 0x00
+)
+
+const (
+	// DefaultExpiry is the number of blocks a transaction is kept private for if
+	// the submitter doesn't specify an expiry block.
+	DefaultExpiry = 10
+
+	// MaxExpiry is the maximum number of blocks a transaction may be kept private.
+	MaxExpiry = 100
+)
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errDecode         = errors.New("invalid message")
+	errInvalidMsgCode = errors.New("invalid message code")
+
+	// ErrExpired is returned if the expiry block of a private transaction has
+	// already been reached.
+	ErrExpired = errors.New("private transaction expired")
+
+	// ErrExpiryTooFar is returned if a private transaction asks to be kept out of
+	// the public pools for longer than MaxExpiry blocks.
+	ErrExpiryTooFar = errors.New("private transaction expiry too far in the future")
+
+	// ErrUnknownKind is returned for envelopes of a transaction kind the local
+	// node cannot handle.
+	ErrUnknownKind = errors.New("unknown private transaction kind")
+)
+
+// Kind identifies the pool a private transaction is destined to.
+type Kind uint8
+
+const (
+	Transaction Kind = iota // Plain transaction for the transaction pool
+	Order                   // TomoX order for the order pool
+	Lending                 // TomoX lending transaction for the lending pool
+)
+
+// String implements fmt.Stringer.
+func (kind Kind) String() string {
+	switch kind {
+	case Transaction:
+		return "transaction"
+	case Order:
+		return "order"
+	case Lending:
+		return "lending"
+	default:
+		return fmt.Sprintf("unknown(%d)", kind)
+	}
+}
+
+// Envelope wraps a signed transaction of any kind along with the last block it
+// is kept private for.
+type Envelope struct {
+	Kind    Kind   // Pool the transaction is destined to
+	Expiry  uint64 // Last block number the transaction is kept private for
+	Payload []byte // Canonical encoding of the signed transaction
+}
@@ -0,0 +1,372 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package privtx
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+var (
+	submittedMeter = metrics.NewRegisteredMeter("eth/privtx/submitted", nil)
+	receivedMeter  = metrics.NewRegisteredMeter("eth/privtx/received", nil)
+	includedMeter  = metrics.NewRegisteredMeter("eth/privtx/included", nil)
+	publishedMeter = metrics.NewRegisteredMeter("eth/privtx/published", nil)
+	droppedMeter   = metrics.NewRegisteredMeter("eth/privtx/dropped", nil)
+)
+
+// Backend defines the chain and pool access needed by the private transaction
+// relay.
+type Backend interface {
+	// CurrentHeader retrieves the head the expiry of private transactions is
+	// measured against.
+	CurrentHeader() *types.Header
+
+	// SubscribeChainHeadEvent subscribes to new chain heads.
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+
+	// Producers returns the nodes expected to produce the blocks following the
+	// given header, in production order. Producers that cannot be resolved to a
+	// node are skipped.
+	Producers(header *types.Header, count int) []discover.NodeID
+
+	// Hash validates the payload of an envelope and returns its transaction hash.
+	Hash(env *Envelope) (common.Hash, error)
+
+	// Import adds a privately received transaction to the local pools.
+	Import(env *Envelope) error
+
+	// Publish adds a transaction whose private window lapsed to the local pools,
+	// making it subject to public gossip.
+	Publish(env *Envelope) error
+
+	// Included reports whether the transaction (or another one of the same
+	// sender and nonce) has been included in the chain.
+	Included(env *Envelope) bool
+}
+
+// outgoing is a locally submitted private transaction awaiting inclusion.
+type outgoing struct {
+	env      *Envelope
+	fallback bool                     // Whether to gossip publicly once expired
+	sent     map[discover.NodeID]bool // Producers the transaction was handed to
+}
+
+// Relay forwards locally submitted transactions to the upcoming block producers
+// and keeps the privately received ones out of the public gossip until they
+// expire.
+type Relay struct {
+	backend Backend
+	fanout  int             // Number of upcoming producers to forward to
+	self    discover.NodeID // Local node, imports directly if among the producers
+
+	peers    map[discover.NodeID]*Peer
+	outgoing map[common.Hash]*outgoing
+	private  map[common.Hash]uint64 // Transactions kept out of gossip with their expiry
+	lock     sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRelay creates a private transaction relay forwarding to the given number
+// of upcoming producers.
+func NewRelay(backend Backend, fanout int) *Relay {
+	if fanout < 1 {
+		fanout = 1
+	}
+	return &Relay{
+		backend:  backend,
+		fanout:   fanout,
+		peers:    make(map[discover.NodeID]*Peer),
+		outgoing: make(map[common.Hash]*outgoing),
+		private:  make(map[common.Hash]uint64),
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start begins following the chain to retry forwarding and expire private
+// transactions. The self ID identifies the local node among the producers.
+func (r *Relay) Start(self discover.NodeID) {
+	r.self = self
+
+	headCh := make(chan core.ChainHeadEvent, 16)
+	headSub := r.backend.SubscribeChainHeadEvent(headCh)
+
+	r.wg.Add(1)
+	go r.loop(headCh, headSub)
+}
+
+// Stop terminates the relay. Pending private transactions are forgotten.
+func (r *Relay) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+}
+
+func (r *Relay) loop(headCh chan core.ChainHeadEvent, headSub event.Subscription) {
+	defer r.wg.Done()
+	defer headSub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-headCh:
+			r.newHead(ev.Block.Header())
+		case <-headSub.Err():
+			return
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// MakeProtocols constructs the P2P protocol definitions for `privtx`.
+func (r *Relay) MakeProtocols() []p2p.Protocol {
+	protocols := make([]p2p.Protocol, len(ProtocolVersions))
+	for i, version := range ProtocolVersions {
+		version := version // Closure
+
+		protocols[i] = p2p.Protocol{
+			Name:    ProtocolName,
+			Version: version,
+			Length:  protocolLengths[version],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				return r.handle(NewPeer(version, p, rw))
+			},
+		}
+	}
+	return protocols
+}
+
+// Submit records a locally signed transaction for private delivery and hands it
+// to the upcoming producers already connected. Producers of later blocks are
+// tried as the chain progresses until the expiry block is reached, at which
+// point the transaction is gossiped publicly if fallback is set, or dropped.
+func (r *Relay) Submit(env *Envelope, fallback bool) (common.Hash, error) {
+	head := r.backend.CurrentHeader()
+	if env.Expiry <= head.Number.Uint64() {
+		return common.Hash{}, ErrExpired
+	}
+	if env.Expiry > head.Number.Uint64()+MaxExpiry {
+		return common.Hash{}, ErrExpiryTooFar
+	}
+	hash, err := r.backend.Hash(env)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	r.lock.Lock()
+	if _, known := r.outgoing[hash]; !known {
+		r.outgoing[hash] = &outgoing{env: env, fallback: fallback, sent: make(map[discover.NodeID]bool)}
+		r.private[hash] = env.Expiry
+		submittedMeter.Mark(1)
+	}
+	r.lock.Unlock()
+
+	log.Debug("Submitted private transaction", "kind", env.Kind, "hash", hash, "expiry", env.Expiry, "fallback", fallback)
+	r.forward(head)
+	return hash, nil
+}
+
+// IsPrivate reports whether a transaction must be kept out of the public gossip.
+func (r *Relay) IsPrivate(hash common.Hash) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, private := r.private[hash]
+	return private
+}
+
+// Pending returns the number of locally submitted transactions still awaiting
+// inclusion.
+func (r *Relay) Pending() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return len(r.outgoing)
+}
+
+// newHead settles the private transactions included or expired with the given
+// head and forwards the remaining ones to the new upcoming producers.
+func (r *Relay) newHead(head *types.Header) {
+	number := head.Number.Uint64()
+
+	var publish []*Envelope
+	r.lock.Lock()
+	for hash, tx := range r.outgoing {
+		switch {
+		case r.backend.Included(tx.env):
+			log.Debug("Private transaction included", "kind", tx.env.Kind, "hash", hash, "number", number)
+			includedMeter.Mark(1)
+			delete(r.outgoing, hash)
+
+		case number >= tx.env.Expiry && tx.fallback:
+			log.Debug("Private transaction expired, publishing", "kind", tx.env.Kind, "hash", hash, "expiry", tx.env.Expiry)
+			publish = append(publish, tx.env)
+			delete(r.outgoing, hash)
+
+		case number >= tx.env.Expiry:
+			log.Debug("Private transaction expired, dropping", "kind", tx.env.Kind, "hash", hash, "expiry", tx.env.Expiry)
+			droppedMeter.Mark(1)
+			delete(r.outgoing, hash)
+		}
+	}
+	for hash, expiry := range r.private {
+		if number >= expiry {
+			delete(r.private, hash)
+		}
+	}
+	r.lock.Unlock()
+
+	for _, env := range publish {
+		if err := r.backend.Publish(env); err != nil {
+			log.Debug("Failed to publish expired private transaction", "kind", env.Kind, "err", err)
+			continue
+		}
+		publishedMeter.Mark(1)
+	}
+	r.forward(head)
+}
+
+// forward hands every outgoing private transaction to those of the producers
+// following head that haven't received it yet.
+func (r *Relay) forward(head *types.Header) {
+	producers := r.backend.Producers(head, r.fanout)
+
+	var (
+		local   []*Envelope
+		batches = make(map[*Peer][]*Envelope)
+	)
+	r.lock.Lock()
+	for _, tx := range r.outgoing {
+		for _, id := range producers {
+			if tx.sent[id] {
+				continue
+			}
+			if id == r.self {
+				local = append(local, tx.env)
+				tx.sent[id] = true
+				continue
+			}
+			if peer := r.peers[id]; peer != nil {
+				batches[peer] = append(batches[peer], tx.env)
+				tx.sent[id] = true
+			}
+		}
+	}
+	r.lock.Unlock()
+
+	for _, env := range local {
+		if err := r.backend.Import(env); err != nil {
+			log.Debug("Failed to import own private transaction", "kind", env.Kind, "err", err)
+		}
+	}
+	for peer, envs := range batches {
+		for len(envs) > 0 {
+			batch := envs
+			if len(batch) > maxEnvelopes {
+				batch = batch[:maxEnvelopes]
+			}
+			envs = envs[len(batch):]
+
+			if err := peer.SendPrivateTxs(batch); err != nil {
+				peer.Log().Debug("Failed to forward private transactions", "err", err)
+				break
+			}
+		}
+	}
+}
+
+// handle is the callback invoked to manage the life cycle of a `privtx` peer.
+// When this function terminates, the peer is disconnected.
+func (r *Relay) handle(peer *Peer) error {
+	r.lock.Lock()
+	r.peers[peer.Peer.ID()] = peer
+	r.lock.Unlock()
+
+	defer func() {
+		r.lock.Lock()
+		delete(r.peers, peer.Peer.ID())
+		r.lock.Unlock()
+	}()
+	// A producer connecting late might still be in time for pending transactions
+	r.forward(r.backend.CurrentHeader())
+
+	for {
+		if err := r.handleMessage(peer); err != nil {
+			peer.Log().Debug("Message handling failed in `privtx`", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMessage is invoked whenever an inbound message is received from a
+// remote peer on the `privtx` protocol. The remote connection is torn down upon
+// returning any error.
+func (r *Relay) handleMessage(peer *Peer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := peer.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%v: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case PrivateTxsMsg:
+		var envs []*Envelope
+		if err := msg.Decode(&envs); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		if len(envs) > maxEnvelopes {
+			return fmt.Errorf("%v: %d private transactions", errMsgTooLarge, len(envs))
+		}
+		number := r.backend.CurrentHeader().Number.Uint64()
+		for _, env := range envs {
+			if env.Expiry <= number || env.Expiry > number+MaxExpiry {
+				continue
+			}
+			hash, err := r.backend.Hash(env)
+			if err != nil {
+				return fmt.Errorf("%v: %v", errDecode, err)
+			}
+			// Mark the transaction private before pooling it, the pools announce
+			// new transactions asynchronously
+			r.lock.Lock()
+			r.private[hash] = env.Expiry
+			r.lock.Unlock()
+
+			receivedMeter.Mark(1)
+			if err := r.backend.Import(env); err != nil {
+				peer.Log().Debug("Failed to import private transaction", "kind", env.Kind, "hash", hash, "err", err)
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%v: %v", errInvalidMsgCode, msg.Code)
+	}
+}
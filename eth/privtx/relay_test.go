@@ -0,0 +1,202 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package privtx
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+// testBackend is a fake chain and pool set recording what the relay does.
+type testBackend struct {
+	head      uint64
+	producers []discover.NodeID
+
+	imported  []common.Hash
+	published []common.Hash
+	included  map[common.Hash]bool
+	feed      event.Feed
+	lock      sync.Mutex
+}
+
+func newTestBackend(producers ...discover.NodeID) *testBackend {
+	return &testBackend{producers: producers, included: make(map[common.Hash]bool)}
+}
+
+func (b *testBackend) CurrentHeader() *types.Header {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return &types.Header{Number: new(big.Int).SetUint64(b.head)}
+}
+
+func (b *testBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.feed.Subscribe(ch)
+}
+
+func (b *testBackend) Producers(header *types.Header, count int) []discover.NodeID {
+	if len(b.producers) < count {
+		return b.producers
+	}
+	return b.producers[:count]
+}
+
+func (b *testBackend) Hash(env *Envelope) (common.Hash, error) {
+	return crypto.Keccak256Hash(env.Payload), nil
+}
+
+func (b *testBackend) Import(env *Envelope) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.imported = append(b.imported, crypto.Keccak256Hash(env.Payload))
+	return nil
+}
+
+func (b *testBackend) Publish(env *Envelope) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.published = append(b.published, crypto.Keccak256Hash(env.Payload))
+	return nil
+}
+
+func (b *testBackend) Included(env *Envelope) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.included[crypto.Keccak256Hash(env.Payload)]
+}
+
+func (b *testBackend) importCount() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.imported)
+}
+
+// setHead moves the fake chain to a new head and returns its header.
+func (b *testBackend) setHead(number uint64) *types.Header {
+	b.lock.Lock()
+	b.head = number
+	b.lock.Unlock()
+	return b.CurrentHeader()
+}
+
+// connect links two relays over an in-memory `privtx` connection.
+func connect(a *Relay, aID discover.NodeID, b *Relay, bID discover.NodeID) {
+	local, remote := p2p.MsgPipe()
+	go a.handle(NewPeer(privtx1, p2p.NewPeer(bID, "b", nil), local))
+	go b.handle(NewPeer(privtx1, p2p.NewPeer(aID, "a", nil), remote))
+}
+
+// Tests that private transactions are only handed to the upcoming producer,
+// which keeps them out of the gossip until they expire.
+func TestRelayForwarding(t *testing.T) {
+	var (
+		senderID   = discover.NodeID{0x01}
+		producerID = discover.NodeID{0x02}
+		otherID    = discover.NodeID{0x03}
+	)
+	senderBackend := newTestBackend(producerID)
+	producerBackend := newTestBackend(producerID)
+	otherBackend := newTestBackend(producerID)
+
+	sender := NewRelay(senderBackend, 1)
+	sender.self = senderID
+	producer := NewRelay(producerBackend, 1)
+	producer.self = producerID
+	other := NewRelay(otherBackend, 1)
+	other.self = otherID
+
+	// Submit before the producer is connected, it must be handed over on connect
+	env := &Envelope{Kind: Order, Expiry: 5, Payload: []byte{0x01}}
+	hash, err := sender.Submit(env, false)
+	if err != nil {
+		t.Fatalf("failed to submit private transaction: %v", err)
+	}
+	connect(sender, senderID, other, otherID)
+	connect(sender, senderID, producer, producerID)
+
+	for deadline := time.Now().Add(time.Second); producerBackend.importCount() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("private transaction not received by the producer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !producer.IsPrivate(hash) {
+		t.Errorf("received transaction not marked private")
+	}
+	if !sender.IsPrivate(hash) {
+		t.Errorf("submitted transaction not marked private")
+	}
+	// Give a misrouted delivery the chance to show up
+	time.Sleep(50 * time.Millisecond)
+	if n := otherBackend.importCount(); n != 0 {
+		t.Errorf("non-producer imported %d private transactions", n)
+	}
+	// Once expired, the transaction is released to the public gossip
+	producer.newHead(producerBackend.setHead(5))
+	if producer.IsPrivate(hash) {
+		t.Errorf("expired transaction still marked private")
+	}
+}
+
+// Tests the local submission rules and the settlement of private transactions
+// once included or expired.
+func TestRelayExpiry(t *testing.T) {
+	backend := newTestBackend()
+	relay := NewRelay(backend, 2)
+	backend.setHead(10)
+
+	if _, err := relay.Submit(&Envelope{Expiry: 10, Payload: []byte{0x00}}, false); err != ErrExpired {
+		t.Errorf("stale expiry: error mismatch: have %v, want %v", err, ErrExpired)
+	}
+	if _, err := relay.Submit(&Envelope{Expiry: 11 + MaxExpiry, Payload: []byte{0x00}}, false); err != ErrExpiryTooFar {
+		t.Errorf("far expiry: error mismatch: have %v, want %v", err, ErrExpiryTooFar)
+	}
+	included, _ := relay.Submit(&Envelope{Expiry: 12, Payload: []byte{0x01}}, true)
+	fallback, _ := relay.Submit(&Envelope{Expiry: 12, Payload: []byte{0x02}}, true)
+	dropped, _ := relay.Submit(&Envelope{Expiry: 12, Payload: []byte{0x03}}, false)
+	lasting, _ := relay.Submit(&Envelope{Expiry: 20, Payload: []byte{0x04}}, false)
+	if n := relay.Pending(); n != 4 {
+		t.Fatalf("pending count mismatch: have %d, want 4", n)
+	}
+	backend.included[included] = true
+	relay.newHead(backend.setHead(11))
+	if n := relay.Pending(); n != 3 {
+		t.Errorf("pending count after inclusion mismatch: have %d, want 3", n)
+	}
+	relay.newHead(backend.setHead(12))
+	if n := relay.Pending(); n != 1 {
+		t.Errorf("pending count after expiry mismatch: have %d, want 1", n)
+	}
+	if len(backend.published) != 1 || backend.published[0] != fallback {
+		t.Errorf("published transactions mismatch: have %x, want [%x]", backend.published, fallback)
+	}
+	if relay.IsPrivate(fallback) || relay.IsPrivate(dropped) {
+		t.Errorf("expired transactions still marked private")
+	}
+	if !relay.IsPrivate(lasting) {
+		t.Errorf("unexpired transaction not marked private")
+	}
+}
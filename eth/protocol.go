@@ -32,16 +32,17 @@ import (
 const (
 	eth62 = 62
 	eth63 = 63
+	eth65 = 65
 )
 
 // Official short name of the protocol used during capability negotiation.
 var ProtocolName = "eth"
 
 // Supported versions of the eth protocol (first is primary).
-var ProtocolVersions = []uint{eth63, eth62}
+var ProtocolVersions = []uint{eth65, eth63, eth62}
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{26, 17, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -63,6 +64,16 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+	// Protocol messages belonging to eth/65
+	NewPooledTransactionHashesMsg = 0x11
+	GetPooledTransactionsMsg      = 0x12
+	PooledTransactionsMsg         = 0x13
+	NewPooledOrderHashesMsg       = 0x14
+	GetPooledOrdersMsg            = 0x15
+	PooledOrdersMsg               = 0x16
+	NewPooledLendingHashesMsg     = 0x17
+	GetPooledLendingsMsg          = 0x18
+	PooledLendingsMsg             = 0x19
 )
 
 type errCode int
@@ -104,6 +115,9 @@ type txPool interface {
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.Transactions, error)
 
+	// Get should return a transaction from the pool, or nil if unknown.
+	Get(hash common.Hash) *types.Transaction
+
 	// SubscribeTxPreEvent should return an event subscription of
 	// TxPreEvent and send events to the given channel.
 	SubscribeTxPreEvent(chan<- core.TxPreEvent) event.Subscription
@@ -117,6 +131,9 @@ type orderPool interface {
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.OrderTransactions, error)
 
+	// Get should return a transaction from the pool, or nil if unknown.
+	Get(hash common.Hash) *types.OrderTransaction
+
 	// SubscribeTxPreEvent should return an event subscription of
 	// TxPreEvent and send events to the given channel.
 	SubscribeTxPreEvent(chan<- core.OrderTxPreEvent) event.Subscription
@@ -130,6 +147,9 @@ type lendingPool interface {
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.LendingTransactions, error)
 
+	// Get should return a transaction from the pool, or nil if unknown.
+	Get(hash common.Hash) *types.LendingTransaction
+
 	// SubscribeTxPreEvent should return an event subscription of
 	// TxPreEvent and send events to the given channel.
 	SubscribeTxPreEvent(chan<- core.LendingTxPreEvent) event.Subscription
@@ -27,6 +27,7 @@ import (
 	"github.com/tomochain/tomochain/crypto"
 	"github.com/tomochain/tomochain/eth/downloader"
 	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
 	"github.com/tomochain/tomochain/rlp"
 )
 
@@ -39,6 +40,7 @@ var testAccount, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6
 // Tests that handshake failures are detected and reported correctly.
 func TestStatusMsgErrors62(t *testing.T) { testStatusMsgErrors(t, 62) }
 func TestStatusMsgErrors63(t *testing.T) { testStatusMsgErrors(t, 63) }
+func TestStatusMsgErrors65(t *testing.T) { testStatusMsgErrors(t, 65) }
 
 func testStatusMsgErrors(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -95,6 +97,7 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions65(t *testing.T) { testRecvTransactions(t, 65) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -120,9 +123,68 @@ func testRecvTransactions(t *testing.T, protocol int) {
 	}
 }
 
+type peerReport struct {
+	id    discover.NodeID
+	fault p2p.Misbehaviour
+}
+
+type testPeerReporter chan peerReport
+
+func (r testPeerReporter) ReportPeer(id discover.NodeID, fault p2p.Misbehaviour) {
+	r <- peerReport{id, fault}
+}
+
+// This test checks that misbehaving peers are reported to the peer scoring.
+func TestReportPeers(t *testing.T) {
+	txAdded := make(chan []*types.Transaction, 2)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+	pm.acceptTxs = 1 // mark synced to accept transactions
+	reports := make(testPeerReporter, 1)
+	pm.SetPeerReporter(reports)
+	p, errc := newTestPeer("peer", eth63, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	expect := func(fault p2p.Misbehaviour) {
+		t.Helper()
+		select {
+		case report := <-reports:
+			if report.id != p.ID() || report.fault != fault {
+				t.Errorf("report mismatch: have %v %v, want %v %v", report.id, report.fault, p.ID(), fault)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("peer not reported for %v", fault)
+		}
+	}
+	// Relaying a transaction twice is a duplicate
+	tx := newTestTransaction(testAccount, 0, 0)
+	for i := 0; i < 2; i++ {
+		if err := p2p.Send(p.app, TxMsg, []interface{}{tx}); err != nil {
+			t.Fatalf("send error: %v", err)
+		}
+		<-txAdded
+	}
+	expect(p2p.DuplicateMessage)
+
+	// Undecodable messages violate the protocol
+	if err := p2p.Send(p.app, TxMsg, []interface{}{"garbage"}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	expect(p2p.InvalidMessage)
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Errorf("protocol returned nil error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("protocol did not shut down within 2 seconds")
+	}
+}
+
 // This test checks that pending transactions are sent.
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions65(t *testing.T) { testSendTransactions(t, 65) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -178,6 +240,101 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// This test checks that announced transactions are requested from the peer
+// and added to the local pool once delivered.
+func TestAnnouncedTransactions65(t *testing.T) {
+	txAdded := make(chan []*types.Transaction)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+	pm.acceptTxs = 1 // mark synced to accept transactions
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	if err := p2p.Send(p.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p.app, GetPooledTransactionsMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("request mismatch: %v", err)
+	}
+	if err := p2p.Send(p.app, PooledTransactionsMsg, []*types.Transaction{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("added wrong transactions: got %v, want %v", added, tx.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("no transaction added within 2 seconds")
+	}
+}
+
+// This test checks that pooled transactions nobody asked the peer for are
+// dropped instead of being added to the local pool.
+func TestUnrequestedPooledTransactions65(t *testing.T) {
+	txAdded := make(chan []*types.Transaction)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+	pm.acceptTxs = 1 // mark synced to accept transactions
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	if err := p2p.Send(p.app, PooledTransactionsMsg, []*types.Transaction{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		t.Errorf("unrequested transactions added: %v", added)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+// This test checks that pooled transactions are served by hash, skipping the
+// unknown ones.
+func TestGetPooledTransactions65(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	txs := []*types.Transaction{newTestTransaction(testAccount, 0, 0), newTestTransaction(testAccount, 1, 0)}
+	pm.txpool.AddRemotes(txs)
+
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer p.close()
+
+	// Drain the initial transaction sync
+	if err := p2p.ExpectMsg(p.app, TxMsg, txs); err != nil {
+		t.Fatalf("initial sync mismatch: %v", err)
+	}
+	if err := p2p.Send(p.app, GetPooledTransactionsMsg, []common.Hash{txs[1].Hash(), {0x01}, txs[0].Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p.app, PooledTransactionsMsg, []*types.Transaction{txs[1], txs[0]}); err != nil {
+		t.Fatalf("reply mismatch: %v", err)
+	}
+}
+
+// Tests that new transactions are only propagated in full to a square root
+// subset of the peers supporting announcements.
+func TestSplitBroadcast(t *testing.T) {
+	var peers []*peer
+	for i := 0; i < 9; i++ {
+		peers = append(peers, &peer{version: eth65})
+	}
+	peers = append(peers, &peer{version: eth63})
+
+	direct, announce := splitBroadcast(peers)
+	if len(direct) != 4 || len(announce) != 6 {
+		t.Fatalf("split mismatch: have %d direct and %d announced, want 4 and 6", len(direct), len(announce))
+	}
+	for _, p := range announce {
+		if p.version < eth65 {
+			t.Errorf("hash announced to eth/%d peer", p.version)
+		}
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
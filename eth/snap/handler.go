@@ -0,0 +1,349 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/trie"
+)
+
+const (
+	// softResponseLimit is the target maximum size of replies to data retrievals.
+	softResponseLimit = 2 * 1024 * 1024
+
+	// maxCodeLookups is the maximum number of bytecodes to serve. This number is
+	// there to limit the number of disk lookups.
+	maxCodeLookups = 1024
+
+	// maxTrieNodeLookups is the maximum number of state trie nodes to serve. This
+	// number is there to limit the number of disk lookups.
+	maxTrieNodeLookups = 1024
+)
+
+// maxHash is the largest possible trie key, used as the limit of unbounded ranges.
+var maxHash = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+// Backend defines the data retrieval methods to serve remote requests.
+type Backend interface {
+	// TrieDB retrieves the trie database holding the state of the given kind,
+	// or nil if the local node does not maintain it.
+	TrieDB(kind StateKind) *trie.Database
+}
+
+// MakeProtocols constructs the P2P protocol definitions for `snap`. The syncer
+// is optional, if nil the node will only serve state to remote peers.
+func MakeProtocols(backend Backend, syncer *Syncer) []p2p.Protocol {
+	protocols := make([]p2p.Protocol, len(ProtocolVersions))
+	for i, version := range ProtocolVersions {
+		version := version // Closure
+
+		protocols[i] = p2p.Protocol{
+			Name:    ProtocolName,
+			Version: version,
+			Length:  protocolLengths[version],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				return Handle(backend, syncer, NewPeer(version, p, rw))
+			},
+		}
+	}
+	return protocols
+}
+
+// Handle is the callback invoked to manage the life cycle of a `snap` peer.
+// When this function terminates, the peer is disconnected.
+func Handle(backend Backend, syncer *Syncer, peer *Peer) error {
+	if syncer != nil {
+		if err := syncer.Register(peer); err != nil {
+			return err
+		}
+		defer syncer.Unregister(peer.ID())
+	}
+	for {
+		if err := handleMessage(backend, syncer, peer); err != nil {
+			peer.Log().Debug("Message handling failed in `snap`", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMessage is invoked whenever an inbound message is received from a
+// remote peer on the `snap` protocol. The remote connection is torn down upon
+// returning any error.
+func handleMessage(backend Backend, syncer *Syncer, peer *Peer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := peer.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%v: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case GetAccountRangeMsg:
+		var req getAccountRangeData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		var (
+			entries []TrieEntry
+			proof   [][]byte
+		)
+		if triedb := backend.TrieDB(req.Kind); triedb != nil {
+			entries, proof, _ = serveRange(triedb, req.Root, req.Origin, req.Limit, responseLimit(req.Bytes))
+		}
+		return p2p.Send(peer.rw, AccountRangeMsg, &accountRangeData{
+			ID:      req.ID,
+			Entries: entries,
+			Proof:   proof,
+		})
+
+	case AccountRangeMsg:
+		var res accountRangeData
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		return deliver(syncer, peer, &response{id: res.ID, ranges: [][]TrieEntry{res.Entries}, proof: res.Proof})
+
+	case GetStorageRangesMsg:
+		var req getStorageRangesData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		var (
+			slots [][]TrieEntry
+			proof [][]byte
+		)
+		if triedb := backend.TrieDB(req.Kind); triedb != nil {
+			slots, proof = serveStorageRanges(triedb, &req)
+		}
+		return p2p.Send(peer.rw, StorageRangesMsg, &storageRangesData{
+			ID:    req.ID,
+			Slots: slots,
+			Proof: proof,
+		})
+
+	case StorageRangesMsg:
+		var res storageRangesData
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		return deliver(syncer, peer, &response{id: res.ID, ranges: res.Slots, proof: res.Proof})
+
+	case GetByteCodesMsg:
+		var req getByteCodesData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		var codes [][]byte
+		if triedb := backend.TrieDB(StateTrie); triedb != nil {
+			codes = serveBlobs(triedb, req.Hashes, maxCodeLookups, responseLimit(req.Bytes))
+		}
+		return p2p.Send(peer.rw, ByteCodesMsg, &byteCodesData{
+			ID:    req.ID,
+			Codes: codes,
+		})
+
+	case ByteCodesMsg:
+		var res byteCodesData
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		return deliver(syncer, peer, &response{id: res.ID, blobs: res.Codes})
+
+	case GetTrieNodesMsg:
+		var req getTrieNodesData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		var nodes [][]byte
+		if triedb := backend.TrieDB(req.Kind); triedb != nil {
+			nodes = serveBlobs(triedb, req.Hashes, maxTrieNodeLookups, responseLimit(req.Bytes))
+		}
+		return p2p.Send(peer.rw, TrieNodesMsg, &trieNodesData{
+			ID:    req.ID,
+			Nodes: nodes,
+		})
+
+	case TrieNodesMsg:
+		var res trieNodesData
+		if err := msg.Decode(&res); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		return deliver(syncer, peer, &response{id: res.ID, blobs: res.Nodes})
+
+	default:
+		return fmt.Errorf("%v: %v", errInvalidMsgCode, msg.Code)
+	}
+}
+
+// deliver hands a response over to the local syncer, if any is running.
+func deliver(syncer *Syncer, peer *Peer, res *response) error {
+	if syncer == nil {
+		peer.Log().Debug("Unrequested state response", "reqid", res.id)
+		return nil
+	}
+	res.peer = peer.ID()
+	syncer.deliver(res)
+	return nil
+}
+
+// responseLimit caps the response size requested by a remote peer.
+func responseLimit(bytes uint64) uint64 {
+	if bytes > softResponseLimit {
+		return softResponseLimit
+	}
+	return bytes
+}
+
+// serveRange collects consecutive entries of a trie starting at origin, until
+// crossing limit or filling the byte budget. Unless the range covers the whole
+// trie, it is accompanied by the proofs of its boundaries. If no entries are
+// left after origin, an empty range is returned with the proof of their absence.
+// If the trie is not available locally, nil is returned.
+func serveRange(triedb *trie.Database, root, origin, limit common.Hash, budget uint64) ([]TrieEntry, [][]byte, uint64) {
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		return nil, nil, 0
+	}
+	var (
+		entries   []TrieEntry
+		size      uint64
+		exhausted = true
+	)
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for it.Next() {
+		if len(entries) > 0 && size >= budget {
+			exhausted = false
+			break
+		}
+		entries = append(entries, TrieEntry{Key: common.BytesToHash(it.Key), Value: common.CopyBytes(it.Value)})
+		size += uint64(common.HashLength + len(it.Value))
+
+		if bytes.Compare(it.Key, limit[:]) >= 0 {
+			exhausted = false
+			break
+		}
+	}
+	if it.Err != nil {
+		return nil, nil, 0
+	}
+	if len(entries) == 0 {
+		if origin == (common.Hash{}) {
+			return nil, nil, 0
+		}
+		var proof proofList
+		if err := tr.Prove(origin[:], 0, &proof); err != nil {
+			log.Warn("Failed to prove empty range", "root", root, "origin", origin, "err", err)
+			return nil, nil, 0
+		}
+		for _, blob := range proof {
+			size += uint64(len(blob))
+		}
+		return []TrieEntry{}, proof, size
+	}
+	// A whole trie is self-proving, anything else needs its boundaries proven
+	if origin == (common.Hash{}) && exhausted {
+		return entries, nil, size
+	}
+	var proof proofList
+	if err := tr.Prove(origin[:], 0, &proof); err != nil {
+		log.Warn("Failed to prove range origin", "root", root, "origin", origin, "err", err)
+		return nil, nil, 0
+	}
+	if err := tr.Prove(entries[len(entries)-1].Key[:], 0, &proof); err != nil {
+		log.Warn("Failed to prove range end", "root", root, "last", entries[len(entries)-1].Key, "err", err)
+		return nil, nil, 0
+	}
+	for _, blob := range proof {
+		size += uint64(len(blob))
+	}
+	return entries, proof, size
+}
+
+// serveStorageRanges collects the entries of a batch of nested tries. The batch
+// is cut short at the first trie not fitting into the byte budget, which is then
+// returned partially along with the proofs of its range.
+func serveStorageRanges(triedb *trie.Database, req *getStorageRangesData) ([][]TrieEntry, [][]byte) {
+	var (
+		slots  [][]TrieEntry
+		size   uint64
+		budget = responseLimit(req.Bytes)
+	)
+	for i, root := range req.Roots {
+		if size >= budget {
+			break
+		}
+		origin, limit := common.Hash{}, maxHash
+		if i == 0 {
+			origin = req.Origin
+		}
+		if i == len(req.Roots)-1 {
+			limit = req.Limit
+		}
+		entries, proof, n := serveRange(triedb, root, origin, limit, budget-size)
+		if entries == nil {
+			break
+		}
+		slots = append(slots, entries)
+		size += n
+
+		if proof != nil {
+			return slots, proof
+		}
+	}
+	return slots, nil
+}
+
+// serveBlobs retrieves a batch of trie nodes or contract codes by hash, skipping
+// the unknown ones and stopping at the lookup or byte limit.
+func serveBlobs(triedb *trie.Database, hashes []common.Hash, lookups int, budget uint64) [][]byte {
+	var (
+		blobs [][]byte
+		size  uint64
+	)
+	for i, hash := range hashes {
+		if i >= lookups || size >= budget {
+			break
+		}
+		if blob, err := triedb.Node(hash); err == nil && len(blob) > 0 {
+			blobs = append(blobs, blob)
+			size += uint64(len(blob))
+		}
+	}
+	return blobs
+}
+
+// proofList collects the nodes of a merkle proof in the order they are generated.
+type proofList [][]byte
+
+// Put implements ethdb.KeyValueWriter.
+func (l *proofList) Put(key []byte, value []byte) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Delete implements ethdb.KeyValueWriter, panicking as proofs are append only.
+func (l *proofList) Delete(key []byte) error {
+	panic("not supported")
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snap implements a range based state synchronisation protocol, able to
+// retrieve the main state trie as well as the TomoX trading and lending state
+// tries in contiguous, proven chunks instead of node by node.
+package snap
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	snap1 = 1
+)
+
+// ProtocolName is the official short name of the protocol used during
+// capability negotiation.
+var ProtocolName = "snap"
+
+// ProtocolVersions are the supported versions of the snap protocol (first is
+// primary).
+var ProtocolVersions = []uint{snap1}
+
+// protocolLengths are the number of implemented message corresponding to
+// different protocol versions.
+var protocolLengths = map[uint]uint64{snap1: 8}
+
+// maxMessageSize is the maximum cap on the size of a protocol message.
+const maxMessageSize = 10 * 1024 * 1024
+
+// snap protocol message codes
+const (
+	GetAccountRangeMsg  = 0x00
+	AccountRangeMsg     = 0x01
+	GetStorageRangesMsg = 0x02
+	StorageRangesMsg    = 0x03
+	GetByteCodesMsg     = 0x04
+	ByteCodesMsg        = 0x05
+	GetTrieNodesMsg     = 0x06
+	TrieNodesMsg        = 0x07
+)
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errDecode         = errors.New("invalid message")
+	errInvalidMsgCode = errors.New("invalid message code")
+	errUnknownKind    = errors.New("unknown state kind")
+)
+
+// StateKind identifies the state trie family a request operates on.
+type StateKind uint8
+
+const (
+	StateTrie   StateKind = iota // Main account state trie with storage and code
+	TradingTrie                  // TomoX trading state trie with the order books
+	LendingTrie                  // TomoX lending state trie with the lending books
+)
+
+// String implements fmt.Stringer.
+func (kind StateKind) String() string {
+	switch kind {
+	case StateTrie:
+		return "state"
+	case TradingTrie:
+		return "trading"
+	case LendingTrie:
+		return "lending"
+	default:
+		return fmt.Sprintf("unknown(%d)", kind)
+	}
+}
+
+// TrieEntry is a single leaf of a trie, keyed by its (hashed for the main state,
+// raw for the TomoX tries) 32 byte path.
+type TrieEntry struct {
+	Key   common.Hash
+	Value []byte
+}
+
+// getAccountRangeData represents an account range query. The same message is
+// used to retrieve the top level trie of every state kind.
+type getAccountRangeData struct {
+	ID     uint64      // Request ID to match up responses with
+	Kind   StateKind   // State trie family to retrieve the range from
+	Root   common.Hash // Root hash of the trie to serve
+	Origin common.Hash // Key of the first entry to retrieve
+	Limit  common.Hash // Key after which to stop serving entries
+	Bytes  uint64      // Soft limit at which to stop returning data
+}
+
+// accountRangeData is the network packet for a trie range response, along with
+// the merkle proofs of its boundaries.
+type accountRangeData struct {
+	ID      uint64      // ID of the request this is a response for
+	Entries []TrieEntry // List of consecutive entries from the trie
+	Proof   [][]byte    // List of trie nodes proving the entry range
+}
+
+// getStorageRangesData represents a query for a batch of nested tries (account
+// storage or TomoX order book tries). The origin only applies to the first trie
+// and the limit to the last one.
+type getStorageRangesData struct {
+	ID     uint64        // Request ID to match up responses with
+	Kind   StateKind     // State trie family the tries belong to
+	Roots  []common.Hash // Root hashes of the tries to serve
+	Origin common.Hash   // Key of the first entry to retrieve
+	Limit  common.Hash   // Key after which to stop serving entries
+	Bytes  uint64        // Soft limit at which to stop returning data
+}
+
+// storageRangesData is the network packet for a nested trie batch response. Only
+// the last, possibly partial, trie is accompanied by a proof.
+type storageRangesData struct {
+	ID    uint64        // ID of the request this is a response for
+	Slots [][]TrieEntry // Lists of consecutive entries for the requested tries
+	Proof [][]byte      // Merkle proofs for the last returned range
+}
+
+// getByteCodesData represents a contract bytecode query.
+type getByteCodesData struct {
+	ID     uint64        // Request ID to match up responses with
+	Hashes []common.Hash // Code hashes to retrieve the code for
+	Bytes  uint64        // Soft limit at which to stop returning data
+}
+
+// byteCodesData is the network packet for a contract bytecode response.
+type byteCodesData struct {
+	ID    uint64   // ID of the request this is a response for
+	Codes [][]byte // Requested contract bytecodes
+}
+
+// getTrieNodesData represents a trie node query used to heal a state assembled
+// from ranges of different roots.
+type getTrieNodesData struct {
+	ID     uint64        // Request ID to match up responses with
+	Kind   StateKind     // State trie family the nodes belong to
+	Hashes []common.Hash // Hashes of the trie nodes to retrieve
+	Bytes  uint64        // Soft limit at which to stop returning data
+}
+
+// trieNodesData is the network packet for a trie node response.
+type trieNodesData struct {
+	ID    uint64   // ID of the request this is a response for
+	Nodes [][]byte // Requested trie nodes
+}
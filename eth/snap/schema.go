@@ -0,0 +1,155 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+	"github.com/tomochain/tomochain/trie"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+)
+
+// trieType describes the layout of the leaves of a trie, defining which nested
+// tries (and contract codes) they reference.
+type trieType uint8
+
+const (
+	plainTrie            trieType = iota // Leaves without nested data (storage slots, orders, lending items)
+	accountTrie                          // Accounts, referencing storage tries and contract codes
+	exchangeTrie                         // Trading exchanges, referencing order books and liquidation prices
+	orderListTrie                        // Price levels and lending books, referencing plain tries
+	liquidationPriceTrie                 // Liquidation prices, referencing lending book order lists
+	lendingBookTrie                      // Lending books, referencing interests, liquidation times, items and trades
+	itemListTrie                         // Interests and liquidation times, referencing plain tries
+)
+
+// subTrie is a nested trie referenced from the leaf of another one.
+type subTrie struct {
+	root common.Hash
+	typ  trieType
+}
+
+// topTrie returns the layout of the top level trie of a state kind.
+func topTrie(kind StateKind) trieType {
+	switch kind {
+	case TradingTrie:
+		return exchangeTrie
+	case LendingTrie:
+		return lendingBookTrie
+	default:
+		return accountTrie
+	}
+}
+
+// children decodes a leaf of a trie with the given layout and returns the non
+// empty nested tries and contract codes it references.
+func children(typ trieType, leaf []byte) ([]subTrie, []common.Hash, error) {
+	var (
+		subs  []subTrie
+		codes []common.Hash
+	)
+	add := func(root common.Hash, typ trieType) {
+		if root != emptyRoot && root != (common.Hash{}) {
+			subs = append(subs, subTrie{root: root, typ: typ})
+		}
+	}
+	switch typ {
+	case accountTrie:
+		var account state.Account
+		if err := rlp.DecodeBytes(leaf, &account); err != nil {
+			return nil, nil, err
+		}
+		add(account.Root, plainTrie)
+		if code := common.BytesToHash(account.CodeHash); code != emptyCode {
+			codes = append(codes, code)
+		}
+
+	case exchangeTrie:
+		asks, bids, orders, prices, err := tradingstate.ExchangeSubTries(leaf)
+		if err != nil {
+			return nil, nil, err
+		}
+		add(asks, orderListTrie)
+		add(bids, orderListTrie)
+		add(orders, plainTrie)
+		add(prices, liquidationPriceTrie)
+
+	case orderListTrie, liquidationPriceTrie:
+		root, err := tradingstate.OrderListRoot(leaf)
+		if err != nil {
+			return nil, nil, err
+		}
+		if typ == liquidationPriceTrie {
+			add(root, orderListTrie)
+		} else {
+			add(root, plainTrie)
+		}
+
+	case lendingBookTrie:
+		investing, borrowing, liquidationTime, items, trades, err := lendingstate.LendingBookSubTries(leaf)
+		if err != nil {
+			// the only other leaves are lending pools and records
+			positions, poolErr := lendingstate.LendingPoolSubTrie(leaf)
+			if poolErr != nil {
+				if lendingstate.IsLendingRecord(leaf) {
+					break
+				}
+				return nil, nil, err
+			}
+			add(positions, plainTrie)
+			break
+		}
+		add(investing, itemListTrie)
+		add(borrowing, itemListTrie)
+		add(liquidationTime, itemListTrie)
+		add(items, plainTrie)
+		add(trades, plainTrie)
+
+	case itemListTrie:
+		root, err := lendingstate.ItemListRoot(leaf)
+		if err != nil {
+			return nil, nil, err
+		}
+		add(root, plainTrie)
+	}
+	return subs, codes, nil
+}
+
+// newHealer creates the node by node download scheduler of a state kind, used
+// to fill in whatever the range retrievals left missing.
+func newHealer(kind StateKind, root common.Hash, db ethdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+	switch kind {
+	case TradingTrie:
+		return tradingstate.NewStateSync(root, db, bloom)
+	case LendingTrie:
+		return lendingstate.NewStateSync(root, db, bloom)
+	default:
+		return state.NewStateSync(root, db, bloom)
+	}
+}
@@ -0,0 +1,965 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/ethdb/memorydb"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/trie"
+)
+
+const (
+	// maxStorageBatch is the maximum number of small nested tries to request in
+	// a single query.
+	maxStorageBatch = 128
+
+	// maxCodeRequestCount is the maximum number of bytecode blobs to request in a
+	// single query.
+	maxCodeRequestCount = 384
+
+	// maxTrieRequestCount is the maximum number of trie node blobs to request in
+	// a single query.
+	maxTrieRequestCount = 384
+
+	// flushThreshold is the amount of leaf data a trie under assembly may
+	// accumulate in memory before being flushed to disk.
+	flushThreshold = 16 * 1024 * 1024
+)
+
+var (
+	// requestTimeout is the maximum time a peer is allowed to spend on serving a
+	// single request.
+	requestTimeout = 10 * time.Second
+
+	// requestBytes is the response size requested from remote peers.
+	requestBytes = uint64(softResponseLimit)
+
+	// unservedBackoff is the time a peer returning a trie range without proving
+	// it empty is skipped for, before the range is requested from it again.
+	unservedBackoff = 3 * time.Second
+)
+
+var (
+	// ErrCancelled is returned from Sync if the sync was aborted.
+	ErrCancelled = errors.New("sync cancelled")
+
+	errUnknownDatabase = errors.New("no database for state kind")
+	errRootMismatch    = errors.New("assembled trie root mismatch")
+)
+
+// Root identifies a state trie to synchronise.
+type Root struct {
+	Kind StateKind   // State trie family the root belongs to
+	Hash common.Hash // Root hash of the top level trie
+}
+
+// request tracks a pending query sent to a remote peer.
+type request struct {
+	id   uint64    // Request ID to match up the response with
+	peer string    // Peer the request was sent to
+	kind StateKind // State trie family the request belongs to
+
+	origin common.Hash     // Origin of the first requested range
+	tasks  []*rangeTask    // Range tasks the request is serving
+	hashes []common.Hash   // Bytecodes or trie nodes requested
+	heal   *healTask       // Healing task the trie nodes belong to
+	code   bool            // Whether the hashes are bytecodes or trie nodes
+	timer  *time.Timer     // Timer to fail the request if not answered in time
+	result chan *response  // Channel of the sync run to deliver the response to
+	stale  <-chan struct{} // Channel signalling the sync run has terminated
+}
+
+// response is a reply (or the lack thereof) to a request.
+type response struct {
+	id   uint64 // Request ID this response is for
+	peer string // Peer the response originated from
+
+	req    *request      // Request this response is matched to
+	ranges [][]TrieEntry // Entry ranges of range responses
+	proof  [][]byte      // Boundary proofs of the last range
+	blobs  [][]byte      // Bytecodes or trie nodes of blob responses
+	failed bool          // Whether the request timed out or the peer dropped
+}
+
+// rangeTask is a single trie being assembled from verified entry ranges.
+type rangeTask struct {
+	kind StateKind   // State trie family the trie belongs to
+	typ  trieType    // Layout of the trie leaves
+	root common.Hash // Root hash the trie must assemble to
+
+	next      common.Hash          // Key of the next entry to retrieve
+	started   bool                 // Whether any entry has been retrieved yet
+	done      bool                 // Whether all entries have been retrieved
+	inflight  bool                 // Whether a range request is in flight
+	stateless map[string]struct{}  // Peers that served invalid ranges of the trie
+	unserved  map[string]time.Time // Peers not serving the trie, skipped until the given time
+
+	trie      *trie.Trie     // Trie assembled from the verified entries
+	triedb    *trie.Database // Memory layer of the trie, flushed to disk periodically
+	unflushed int            // Size of the entries inserted since the last flush
+
+	pending int          // Number of nested tries and codes not yet retrieved
+	parents []*rangeTask // Tasks waiting for this trie to complete
+}
+
+// healTask is a node by node retrieval of whatever parts of a state trie are
+// still missing locally.
+type healTask struct {
+	kind  StateKind  // State trie family being healed
+	sched *trie.Sync // Node retrieval scheduler of the trie
+
+	queue    []common.Hash                       // Nodes scheduled but not yet requested
+	attempts map[common.Hash]map[string]struct{} // Peers a node was already requested from
+	bytes    int                                 // Size of the nodes not yet flushed to disk
+}
+
+// Syncer is a range based state synchroniser. It assembles the main state and
+// the TomoX trading and lending states from proven entry ranges retrieved from
+// remote `snap` peers, and heals the result node by node.
+type Syncer struct {
+	dbs map[StateKind]ethdb.KeyValueStore // Databases to store the state kinds into
+
+	peers  map[string]*Peer    // Currently active peers to download from
+	idlers map[string]struct{} // Peers without an in-flight request
+	update chan struct{}       // Notification channel for newly idle or joined peers
+	reqs   map[uint64]*request // Pending requests keyed by ID
+	synced map[StateKind]common.Hash
+	lock   sync.RWMutex
+
+	syncLock sync.Mutex // Ensures a single sync is running at any time
+
+	// Statistics of the current sync cycle
+	entries uint64             // Number of trie entries retrieved
+	codes   uint64             // Number of bytecodes retrieved
+	nodes   uint64             // Number of trie nodes healed
+	bytes   common.StorageSize // Volume of data retrieved
+}
+
+// NewSyncer creates a new state syncer, storing each state kind into the given
+// database.
+func NewSyncer(dbs map[StateKind]ethdb.KeyValueStore) *Syncer {
+	return &Syncer{
+		dbs:    dbs,
+		peers:  make(map[string]*Peer),
+		idlers: make(map[string]struct{}),
+		update: make(chan struct{}, 1),
+		reqs:   make(map[uint64]*request),
+		synced: make(map[StateKind]common.Hash),
+	}
+}
+
+// Register injects a new data source into the syncer's peerset.
+func (s *Syncer) Register(peer *Peer) error {
+	id := peer.ID()
+
+	s.lock.Lock()
+	if _, ok := s.peers[id]; ok {
+		s.lock.Unlock()
+		log.Error("Snap peer already registered", "id", id)
+		return errors.New("already registered")
+	}
+	s.peers[id] = peer
+	s.idlers[id] = struct{}{}
+	s.lock.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// Unregister removes a data source from the syncer's peerset, failing all its
+// pending requests.
+func (s *Syncer) Unregister(id string) error {
+	s.lock.Lock()
+	if _, ok := s.peers[id]; !ok {
+		s.lock.Unlock()
+		log.Error("Snap peer not registered", "id", id)
+		return errors.New("not registered")
+	}
+	delete(s.peers, id)
+	delete(s.idlers, id)
+
+	var dropped []*request
+	for reqid, req := range s.reqs {
+		if req.peer == id {
+			delete(s.reqs, reqid)
+			dropped = append(dropped, req)
+		}
+	}
+	s.lock.Unlock()
+
+	for _, req := range dropped {
+		req.timer.Stop()
+		s.fail(req)
+	}
+	return nil
+}
+
+// notify wakes up the running sync, if any, to assign tasks to idle peers.
+func (s *Syncer) notify() {
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+}
+
+// deliver matches an inbound response to its pending request and hands it to
+// the sync run that issued it.
+func (s *Syncer) deliver(res *response) {
+	s.lock.Lock()
+	req := s.reqs[res.id]
+	if req == nil || req.peer != res.peer {
+		s.lock.Unlock()
+		log.Debug("Unexpected snap response", "peer", res.peer, "reqid", res.id)
+		return
+	}
+	delete(s.reqs, res.id)
+	s.lock.Unlock()
+
+	req.timer.Stop()
+	res.req = req
+	go s.forward(req, res)
+}
+
+// forward hands a response over to the sync run that issued the request. It is
+// meant to run on its own goroutine, so that peer message loops are never held
+// up by the sync run (which might be blocked sending a request to them).
+func (s *Syncer) forward(req *request, res *response) {
+	select {
+	case req.result <- res:
+	case <-req.stale:
+	}
+}
+
+// fail delivers an empty, failed response for a request that timed out or whose
+// peer disconnected.
+func (s *Syncer) fail(req *request) {
+	go s.forward(req, &response{id: req.id, peer: req.peer, req: req, failed: true})
+}
+
+// Sync retrieves the given state tries, returning once they are fully present
+// in the local databases or the cancel channel is closed. Roots already present
+// locally are skipped, and roots of a state kind previously synced by this
+// syncer are healed instead of being retrieved from scratch.
+func (s *Syncer) Sync(roots []Root, cancel <-chan struct{}) error {
+	s.syncLock.Lock()
+	defer s.syncLock.Unlock()
+
+	for _, root := range roots {
+		if s.dbs[root.Kind] == nil {
+			return fmt.Errorf("%v: %v", errUnknownDatabase, root.Kind)
+		}
+	}
+	run := newSyncRun(s, roots)
+	defer run.close()
+
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		retry  = time.NewTicker(unservedBackoff)
+	)
+	defer retry.Stop()
+
+	log.Debug("Starting snap state sync", "roots", len(roots))
+	for {
+		if !run.healing && run.ranged() {
+			run.startHealing()
+		}
+		if run.healing && run.healed() {
+			break
+		}
+		run.assign()
+
+		select {
+		case <-s.update:
+		case <-retry.C:
+		case res := <-run.results:
+			if err := run.process(res); err != nil {
+				return err
+			}
+		case <-cancel:
+			return ErrCancelled
+		}
+		if time.Since(logged) > 8*time.Second {
+			s.reportProgress(false)
+			logged = time.Now()
+		}
+	}
+	s.lock.Lock()
+	for _, root := range roots {
+		s.synced[root.Kind] = root.Hash
+	}
+	s.lock.Unlock()
+
+	s.reportProgress(true)
+	log.Debug("Finished snap state sync", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// reportProgress logs the statistics of the current sync cycle.
+func (s *Syncer) reportProgress(force bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if force {
+		log.Info("Snap state sync complete", "entries", s.entries, "codes", s.codes, "nodes", s.nodes, "size", s.bytes)
+		return
+	}
+	log.Info("Snap state sync in progress", "entries", s.entries, "codes", s.codes, "nodes", s.nodes, "size", s.bytes)
+}
+
+// syncRun is the scheduling state of a single Sync invocation.
+type syncRun struct {
+	s *Syncer
+
+	roots   []Root                // Roots being synced
+	tasks   []*rangeTask          // Tries being assembled, in creation order
+	subs    map[string]*rangeTask // Tries being assembled, keyed by kind and root
+	codes   map[common.Hash][]*rangeTask
+	codeq   []common.Hash                       // Bytecodes scheduled but not yet requested
+	tried   map[common.Hash]map[string]struct{} // Peers a bytecode was already requested from
+	heals   []*healTask                         // Node retrievals, created after all ranges finish
+	healing bool                                // Whether the healing phase started
+
+	bloom   *trie.SyncBloom // Disabled bloom forcing the heal schedulers to check the databases
+	results chan *response  // Responses delivered to this run
+	stale   chan struct{}   // Closed when the run terminates
+}
+
+// newSyncRun creates the scheduling state of a sync, creating range tasks for
+// all roots not yet present locally.
+func newSyncRun(s *Syncer, roots []Root) *syncRun {
+	run := &syncRun{
+		s:       s,
+		roots:   roots,
+		subs:    make(map[string]*rangeTask),
+		codes:   make(map[common.Hash][]*rangeTask),
+		tried:   make(map[common.Hash]map[string]struct{}),
+		results: make(chan *response),
+		stale:   make(chan struct{}),
+	}
+	s.lock.Lock()
+	s.entries, s.codes, s.nodes, s.bytes = 0, 0, 0, 0
+	s.lock.Unlock()
+
+	for _, root := range roots {
+		if root.Hash == emptyRoot || root.Hash == (common.Hash{}) {
+			continue
+		}
+		if ok, _ := s.dbs[root.Kind].Has(root.Hash[:]); ok {
+			continue
+		}
+		// If a previous root of the same kind is complete, most of the state is
+		// shared, leave the differences to healing
+		s.lock.RLock()
+		prev, ok := s.synced[root.Kind]
+		s.lock.RUnlock()
+		if ok {
+			if has, _ := s.dbs[root.Kind].Has(prev[:]); has {
+				log.Debug("Healing state from previous root", "kind", root.Kind, "prev", prev, "root", root.Hash)
+				continue
+			}
+		}
+		run.schedule(nil, root.Kind, root.Hash, topTrie(root.Kind))
+	}
+	// The sync bloom is only meaningful when seeded from the database. Close it
+	// right away so the heal schedulers always consult the database instead.
+	run.bloom = trie.NewSyncBloom(1, memorydb.New())
+	run.bloom.Close()
+
+	return run
+}
+
+// close terminates the run, discarding all its pending requests.
+func (run *syncRun) close() {
+	close(run.stale)
+
+	run.s.lock.Lock()
+	for id, req := range run.s.reqs {
+		if req.stale == run.stale {
+			req.timer.Stop()
+			delete(run.s.reqs, id)
+			run.s.idlers[req.peer] = struct{}{}
+		}
+	}
+	run.s.lock.Unlock()
+}
+
+// taskKey is the identifier of a trie in the set of tasks.
+func taskKey(kind StateKind, typ trieType, root common.Hash) string {
+	return fmt.Sprintf("%d-%d-%x", kind, typ, root)
+}
+
+// schedule creates a range task for a trie, unless it's already present locally
+// or being assembled. If a parent is given, its completion is blocked until the
+// nested trie is done.
+func (run *syncRun) schedule(parent *rangeTask, kind StateKind, root common.Hash, typ trieType) {
+	key := taskKey(kind, typ, root)
+	if task, ok := run.subs[key]; ok {
+		if parent != nil {
+			task.parents = append(task.parents, parent)
+			parent.pending++
+		}
+		return
+	}
+	if parent != nil {
+		if ok, _ := run.s.dbs[kind].Has(root[:]); ok {
+			return
+		}
+	}
+	task := &rangeTask{
+		kind:      kind,
+		typ:       typ,
+		root:      root,
+		stateless: make(map[string]struct{}),
+		unserved:  make(map[string]time.Time),
+	}
+	if parent != nil {
+		task.parents = append(task.parents, parent)
+		parent.pending++
+	}
+	run.subs[key] = task
+	run.tasks = append(run.tasks, task)
+}
+
+// scheduleCode queues a contract bytecode for retrieval, unless it's already
+// present locally.
+func (run *syncRun) scheduleCode(parent *rangeTask, hash common.Hash) {
+	if parents, ok := run.codes[hash]; ok {
+		run.codes[hash] = append(parents, parent)
+		parent.pending++
+		return
+	}
+	if ok, _ := run.s.dbs[StateTrie].Has(hash[:]); ok {
+		return
+	}
+	run.codes[hash] = []*rangeTask{parent}
+	run.codeq = append(run.codeq, hash)
+	parent.pending++
+}
+
+// ranged returns whether all tries are assembled and all bytecodes retrieved.
+func (run *syncRun) ranged() bool {
+	return len(run.tasks) == 0 && len(run.codes) == 0
+}
+
+// healed returns whether all node by node retrievals are done.
+func (run *syncRun) healed() bool {
+	for _, heal := range run.heals {
+		if heal.sched.Pending() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// startHealing creates the node by node retrievals for all roots, filling in
+// whatever the ranges didn't cover.
+func (run *syncRun) startHealing() {
+	run.healing = true
+	for _, root := range run.roots {
+		if root.Hash == emptyRoot || root.Hash == (common.Hash{}) {
+			continue
+		}
+		sched := newHealer(root.Kind, root.Hash, run.s.dbs[root.Kind], run.bloom)
+		if sched.Pending() == 0 {
+			continue
+		}
+		run.heals = append(run.heals, &healTask{
+			kind:     root.Kind,
+			sched:    sched,
+			attempts: make(map[common.Hash]map[string]struct{}),
+		})
+	}
+}
+
+// assign sends retrieval requests to all idle peers for which there is work.
+func (run *syncRun) assign() {
+	s := run.s
+
+	// Fill the requests under the lock, but send them outside of it, as sending
+	// may block on the remote side delivering responses to us
+	type assignment struct {
+		peer *Peer
+		req  *request
+	}
+	var assigned []assignment
+
+	s.lock.Lock()
+	for id := range s.idlers {
+		req := &request{
+			id:     s.newRequestID(),
+			peer:   id,
+			result: run.results,
+			stale:  run.stale,
+		}
+		if !run.assignRange(req) && !run.assignCodes(req) && !run.assignHeal(req) {
+			continue
+		}
+		delete(s.idlers, id)
+		s.reqs[req.id] = req
+		req.timer = time.AfterFunc(requestTimeout, func() {
+			s.lock.Lock()
+			if _, ok := s.reqs[req.id]; !ok {
+				s.lock.Unlock()
+				return
+			}
+			delete(s.reqs, req.id)
+			s.lock.Unlock()
+
+			log.Debug("Snap request timed out", "peer", req.peer, "reqid", req.id)
+			s.fail(req)
+		})
+		assigned = append(assigned, assignment{peer: s.peers[id], req: req})
+	}
+	s.lock.Unlock()
+
+	for _, a := range assigned {
+		var (
+			peer, req = a.peer, a.req
+			err       error
+		)
+		switch {
+		case req.tasks != nil && req.tasks[0].parents == nil:
+			err = peer.RequestAccountRange(req.id, req.kind, req.tasks[0].root, req.origin, maxHash, requestBytes)
+		case req.tasks != nil:
+			roots := make([]common.Hash, len(req.tasks))
+			for i, task := range req.tasks {
+				roots[i] = task.root
+			}
+			err = peer.RequestStorageRanges(req.id, req.kind, roots, req.origin, maxHash, requestBytes)
+		case req.code:
+			err = peer.RequestByteCodes(req.id, req.hashes, requestBytes)
+		default:
+			err = peer.RequestTrieNodes(req.id, req.kind, req.hashes, requestBytes)
+		}
+		if err != nil {
+			peer.Log().Debug("Failed to send snap request", "reqid", req.id, "err", err)
+
+			s.lock.Lock()
+			_, pending := s.reqs[req.id]
+			delete(s.reqs, req.id)
+			s.lock.Unlock()
+
+			if pending {
+				req.timer.Stop()
+				s.fail(req)
+			}
+		}
+	}
+}
+
+// newRequestID generates a request ID not used by any pending request. The
+// syncer lock must be held.
+func (s *Syncer) newRequestID() uint64 {
+	for {
+		id := rand.Uint64()
+		if _, ok := s.reqs[id]; !ok {
+			return id
+		}
+	}
+}
+
+// assignRange fills a request with the next range of a trie, or with a batch of
+// small nested tries not yet started. Nested tries are preferred, so their
+// parents can be completed and flushed as soon as possible.
+func (run *syncRun) assignRange(req *request) bool {
+	for i := len(run.tasks) - 1; i >= 0; i-- {
+		task := run.tasks[i]
+		if task.inflight || task.done {
+			continue
+		}
+		if !task.servable(req.peer) {
+			continue
+		}
+		req.kind, req.origin = task.kind, task.next
+		req.tasks = append(req.tasks, task)
+		task.inflight = true
+
+		// Fresh nested tries are likely small, batch them up
+		if task.started || task.parents == nil {
+			return true
+		}
+		for j := i - 1; j >= 0 && len(req.tasks) < maxStorageBatch; j-- {
+			other := run.tasks[j]
+			if other.inflight || other.done || other.started || other.parents == nil || other.kind != task.kind {
+				continue
+			}
+			if !other.servable(req.peer) {
+				continue
+			}
+			req.tasks = append(req.tasks, other)
+			other.inflight = true
+		}
+		return true
+	}
+	return false
+}
+
+// assignCodes fills a request with a batch of bytecodes not yet requested from
+// the peer.
+func (run *syncRun) assignCodes(req *request) bool {
+	var rest []common.Hash
+	for _, hash := range run.codeq {
+		if _, ok := run.tried[hash][req.peer]; ok || len(req.hashes) >= maxCodeRequestCount {
+			rest = append(rest, hash)
+			continue
+		}
+		if run.tried[hash] == nil {
+			run.tried[hash] = make(map[string]struct{})
+		}
+		run.tried[hash][req.peer] = struct{}{}
+		req.hashes = append(req.hashes, hash)
+	}
+	run.codeq = rest
+	req.code = len(req.hashes) > 0
+	return req.code
+}
+
+// assignHeal fills a request with a batch of missing trie nodes not yet
+// requested from the peer.
+func (run *syncRun) assignHeal(req *request) bool {
+	for _, heal := range run.heals {
+		heal.queue = append(heal.queue, heal.sched.Missing(maxTrieRequestCount)...)
+
+		var rest []common.Hash
+		for _, hash := range heal.queue {
+			if _, ok := heal.attempts[hash][req.peer]; ok || len(req.hashes) >= maxTrieRequestCount {
+				rest = append(rest, hash)
+				continue
+			}
+			if heal.attempts[hash] == nil {
+				heal.attempts[hash] = make(map[string]struct{})
+			}
+			heal.attempts[hash][req.peer] = struct{}{}
+			req.hashes = append(req.hashes, hash)
+		}
+		heal.queue = rest
+		if len(req.hashes) > 0 {
+			req.kind, req.heal, req.code = heal.kind, heal, false
+			return true
+		}
+	}
+	req.hashes = nil
+	return false
+}
+
+// process handles a response (or failure) to a request of this run, marking the
+// serving peer idle again.
+func (run *syncRun) process(res *response) error {
+	req := res.req
+
+	run.s.lock.Lock()
+	if _, ok := run.s.peers[req.peer]; ok {
+		run.s.idlers[req.peer] = struct{}{}
+	}
+	run.s.lock.Unlock()
+
+	switch {
+	case req.tasks != nil:
+		return run.processRanges(req, res)
+	case req.heal != nil:
+		return run.processNodes(req, res)
+	default:
+		return run.processCodes(req, res)
+	}
+}
+
+// servable returns whether a range of the trie may be requested from a peer.
+func (task *rangeTask) servable(peer string) bool {
+	if _, ok := task.stateless[peer]; ok {
+		return false
+	}
+	if until, ok := task.unserved[peer]; ok {
+		if time.Now().Before(until) {
+			return false
+		}
+		delete(task.unserved, peer)
+	}
+	return true
+}
+
+// processRanges verifies and injects the entry ranges of a range response. The
+// first range not covered or failing verification and all subsequent ones are
+// rescheduled. A peer serving an invalid range is never asked for the trie again,
+// one serving nothing is only skipped for a while, as it may lack the state just
+// temporarily.
+func (run *syncRun) processRanges(req *request, res *response) error {
+	for _, task := range req.tasks {
+		task.inflight = false
+	}
+	if res.failed {
+		return nil
+	}
+	for i, task := range req.tasks {
+		var proof [][]byte
+		if i == len(res.ranges)-1 {
+			proof = res.proof
+		}
+		// Batches may be cut short by the response size, but if not even the
+		// first trie was served, the peer doesn't have it. An empty range is
+		// only accepted along with the proof of no more entries existing.
+		if i >= len(res.ranges) || (len(res.ranges[i]) == 0 && len(proof) == 0) {
+			if i == 0 {
+				task.unserved[req.peer] = time.Now().Add(unservedBackoff)
+			}
+			return nil
+		}
+		origin := common.Hash{}
+		if i == 0 {
+			origin = req.origin
+		}
+		if err := run.processRange(task, origin, res.ranges[i], proof); err != nil {
+			log.Debug("Invalid snap range", "peer", req.peer, "kind", task.kind, "root", task.root, "err", err)
+			task.stateless[req.peer] = struct{}{}
+			if err == errRootMismatch {
+				return err
+			}
+			return nil
+		}
+		if !task.done {
+			// A partial range terminates the batch, the rest needs retrying
+			return nil
+		}
+	}
+	return nil
+}
+
+// processRange verifies a range of entries against a trie root and inserts them
+// into the trie under assembly, scheduling the nested data they reference.
+func (run *syncRun) processRange(task *rangeTask, origin common.Hash, entries []TrieEntry, proof [][]byte) error {
+	keys := make([][]byte, len(entries))
+	values := make([][]byte, len(entries))
+
+	var size common.StorageSize
+	for i, entry := range entries {
+		keys[i] = common.CopyBytes(entry.Key[:])
+		values[i] = entry.Value
+		size += common.StorageSize(common.HashLength + len(entry.Value))
+	}
+	var (
+		err  error
+		cont bool
+	)
+	if len(proof) == 0 {
+		if origin != (common.Hash{}) {
+			return errors.New("missing range proof")
+		}
+		err, cont = trie.VerifyRangeProof(task.root, nil, keys, values, nil, nil)
+	} else {
+		proofdb := memorydb.New()
+		for _, node := range proof {
+			proofdb.Put(crypto.Keccak256(node), node)
+			size += common.StorageSize(len(node))
+		}
+		err, cont = trie.VerifyRangeProof(task.root, origin[:], keys, values, proofdb, proofdb)
+	}
+	if err != nil {
+		return err
+	}
+	// Range valid, insert it into the local trie
+	if task.trie == nil {
+		task.triedb = trie.NewDatabase(run.s.dbs[task.kind])
+		task.trie, _ = trie.New(common.Hash{}, task.triedb)
+	}
+	for i, key := range keys {
+		if err := task.trie.TryUpdate(key, values[i]); err != nil {
+			return err
+		}
+		task.unflushed += len(key) + len(values[i])
+
+		subs, codes, err := children(task.typ, values[i])
+		if err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			run.schedule(task, task.kind, sub.root, sub.typ)
+		}
+		for _, code := range codes {
+			run.scheduleCode(task, code)
+		}
+	}
+	task.started = true
+	if cont && entries[len(entries)-1].Key != maxHash {
+		next := new(big.Int).Add(entries[len(entries)-1].Key.Big(), common.Big1)
+		task.next = common.BigToHash(next)
+	} else {
+		task.done = true
+	}
+	run.s.lock.Lock()
+	run.s.entries += uint64(len(entries))
+	run.s.bytes += size
+	run.s.lock.Unlock()
+
+	return run.commit(task)
+}
+
+// commit flushes a trie under assembly to disk, if no nested data referenced by
+// its leaves is still missing, making sure that any trie node present on disk
+// always has its full subtrie available. Completed tries release their parents.
+func (run *syncRun) commit(task *rangeTask) error {
+	if task.pending > 0 {
+		return nil
+	}
+	if !task.done {
+		if task.unflushed < flushThreshold {
+			return nil
+		}
+		root, err := task.trie.Commit(nil)
+		if err != nil {
+			return err
+		}
+		task.unflushed = 0
+		return task.triedb.Commit(root, false)
+	}
+	root, err := task.trie.Commit(nil)
+	if err != nil {
+		return err
+	}
+	if root != task.root {
+		log.Error("Snap trie assembled to wrong root", "kind", task.kind, "have", root, "want", task.root)
+		return errRootMismatch
+	}
+	if err := task.triedb.Commit(root, false); err != nil {
+		return err
+	}
+	run.complete(task)
+	for _, parent := range task.parents {
+		parent.pending--
+		if err := run.commit(parent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// complete removes a finished trie from the set of tasks.
+func (run *syncRun) complete(task *rangeTask) {
+	delete(run.subs, taskKey(task.kind, task.typ, task.root))
+	for i, other := range run.tasks {
+		if other == task {
+			run.tasks = append(run.tasks[:i], run.tasks[i+1:]...)
+			break
+		}
+	}
+	task.trie, task.triedb = nil, nil
+}
+
+// processCodes verifies and stores the bytecodes of a code response, releasing
+// the tries waiting on them and rescheduling the missing ones.
+func (run *syncRun) processCodes(req *request, res *response) error {
+	delivered := make(map[common.Hash][]byte)
+	for _, code := range res.blobs {
+		delivered[crypto.Keccak256Hash(code)] = code
+	}
+	var (
+		db    = run.s.dbs[StateTrie]
+		batch = db.NewBatch()
+		size  common.StorageSize
+		done  []common.Hash
+	)
+	for _, hash := range req.hashes {
+		code, ok := delivered[hash]
+		if !ok {
+			run.codeq = append(run.codeq, hash)
+			continue
+		}
+		if err := batch.Put(hash[:], code); err != nil {
+			return err
+		}
+		size += common.StorageSize(len(code))
+		done = append(done, hash)
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	run.s.lock.Lock()
+	run.s.codes += uint64(len(done))
+	run.s.bytes += size
+	run.s.lock.Unlock()
+
+	for _, hash := range done {
+		parents := run.codes[hash]
+		delete(run.codes, hash)
+		delete(run.tried, hash)
+
+		for _, parent := range parents {
+			parent.pending--
+			if err := run.commit(parent); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// processNodes injects the trie nodes of a heal response into the scheduler,
+// rescheduling the missing ones and flushing the retrieved data periodically.
+func (run *syncRun) processNodes(req *request, res *response) error {
+	heal := req.heal
+
+	delivered := make(map[common.Hash][]byte)
+	for _, node := range res.blobs {
+		delivered[crypto.Keccak256Hash(node)] = node
+	}
+	var (
+		results []trie.SyncResult
+		size    int
+	)
+	for _, hash := range req.hashes {
+		node, ok := delivered[hash]
+		if !ok {
+			heal.queue = append(heal.queue, hash)
+			continue
+		}
+		results = append(results, trie.SyncResult{Hash: hash, Data: node})
+		size += len(node)
+		delete(heal.attempts, hash)
+	}
+	if len(results) > 0 {
+		if _, index, err := heal.sched.Process(results); err != nil {
+			log.Debug("Failed to process healed node", "kind", heal.kind, "hash", results[index].Hash, "err", err)
+		}
+	}
+	run.s.lock.Lock()
+	run.s.nodes += uint64(len(results))
+	run.s.bytes += common.StorageSize(size)
+	run.s.lock.Unlock()
+
+	heal.bytes += size
+	if heal.bytes < ethdb.IdealBatchSize && heal.sched.Pending() > 0 {
+		return nil
+	}
+	batch := run.s.dbs[heal.kind].NewBatch()
+	if err := heal.sched.Commit(batch); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	heal.bytes = 0
+	return nil
+}
@@ -0,0 +1,439 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// testExchange mirrors the consensus encoding of a trading exchange.
+type testExchange struct {
+	Nonce                  uint64
+	LastPrice              *big.Int
+	MediumPriceBeforeEpoch *big.Int
+	MediumPrice            *big.Int
+	TotalQuantity          *big.Int
+	LendingCount           *big.Int
+	AskRoot                common.Hash
+	BidRoot                common.Hash
+	OrderRoot              common.Hash
+	LiquidationPriceRoot   common.Hash
+}
+
+// testLendingBook mirrors the consensus encoding of a lending book.
+type testLendingBook struct {
+	Nonce               uint64
+	TradeNonce          uint64
+	InvestingRoot       common.Hash
+	BorrowingRoot       common.Hash
+	LiquidationTimeRoot common.Hash
+	LendingItemRoot     common.Hash
+	LendingTradeRoot    common.Hash
+}
+
+// testLendingPool mirrors the consensus encoding of a lending pool.
+type testLendingPool struct {
+	TotalDeposits      *big.Int
+	TotalDepositShares *big.Int
+	TotalBorrows       *big.Int
+	TotalBorrowShares  *big.Int
+	LastBlock          uint64
+	PositionRoot       common.Hash
+}
+
+// testList mirrors the consensus encoding of order and item lists.
+type testList struct {
+	Volume *big.Int
+	Root   common.Hash
+}
+
+// testBackend serves the state tries of a set of databases.
+type testBackend struct {
+	dbs map[StateKind]*trie.Database
+}
+
+func (b *testBackend) TrieDB(kind StateKind) *trie.Database {
+	return b.dbs[kind]
+}
+
+// testSource is a full node holding the main, trading and lending states.
+type testSource struct {
+	chaindb ethdb.Database
+	tomoxdb ethdb.Database
+	backend *testBackend
+	roots   []Root
+}
+
+func newTestSource() *testSource {
+	src := &testSource{
+		chaindb: rawdb.NewMemoryDatabase(),
+		tomoxdb: rawdb.NewMemoryDatabase(),
+	}
+	src.backend = &testBackend{dbs: map[StateKind]*trie.Database{
+		StateTrie:   trie.NewDatabase(src.chaindb),
+		TradingTrie: trie.NewDatabase(src.tomoxdb),
+		LendingTrie: trie.NewDatabase(src.tomoxdb),
+	}}
+	return src
+}
+
+// fill populates the source with accounts, exchanges and lending books, each
+// value being derived from the seed to allow creating diverging states.
+func (src *testSource) fill(t *testing.T, seed int64) {
+	// Create the main state with storage and contract codes
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(src.chaindb))
+	for i := int64(0); i < 300; i++ {
+		addr := common.BigToAddress(big.NewInt(i + 1))
+		statedb.SetBalance(addr, big.NewInt(i*seed+1))
+		if i%10 == 0 {
+			statedb.SetCode(addr, []byte{byte(i), byte(seed), 0x60, 0x00})
+			for j := int64(0); j < 20+i; j++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(j)), common.BigToHash(big.NewInt(j*seed+1)))
+			}
+		}
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	src.roots = []Root{{Kind: StateTrie, Hash: root}}
+
+	// Create the trading state with nested order books and liquidation prices
+	triedb := src.backend.dbs[TradingTrie]
+	exchanges, _ := trie.New(common.Hash{}, triedb)
+	for i := int64(0); i < 20; i++ {
+		exchange := testExchange{
+			Nonce:                  uint64(i),
+			LastPrice:              big.NewInt(seed),
+			MediumPriceBeforeEpoch: big.NewInt(0),
+			MediumPrice:            big.NewInt(0),
+			TotalQuantity:          big.NewInt(i),
+			LendingCount:           big.NewInt(0),
+			AskRoot:                src.listTrie(t, triedb, 5, seed+i, src.plainTrie(t, triedb, 10, seed+i)),
+			BidRoot:                src.listTrie(t, triedb, 5, seed-i, src.plainTrie(t, triedb, 10, seed-i)),
+			OrderRoot:              src.plainTrie(t, triedb, 40, seed*i),
+			LiquidationPriceRoot:   src.listTrie(t, triedb, 3, seed, src.listTrie(t, triedb, 2, seed, src.plainTrie(t, triedb, 5, seed))),
+		}
+		blob, _ := rlp.EncodeToBytes(&exchange)
+		exchanges.Update(common.BigToHash(big.NewInt(i)).Bytes(), blob)
+	}
+	src.roots = append(src.roots, Root{Kind: TradingTrie, Hash: src.commit(t, exchanges, triedb)})
+
+	// Create the lending state with nested item lists, items and trades
+	triedb = src.backend.dbs[LendingTrie]
+	books, _ := trie.New(common.Hash{}, triedb)
+	for i := int64(0); i < 10; i++ {
+		book := testLendingBook{
+			Nonce:               uint64(seed),
+			TradeNonce:          uint64(i),
+			InvestingRoot:       src.listTrie(t, triedb, 4, seed+i, src.plainTrie(t, triedb, 6, seed)),
+			BorrowingRoot:       src.listTrie(t, triedb, 4, seed-i, src.plainTrie(t, triedb, 6, i)),
+			LiquidationTimeRoot: src.listTrie(t, triedb, 2, i, src.plainTrie(t, triedb, 3, seed)),
+			LendingItemRoot:     src.plainTrie(t, triedb, 30, seed+i),
+			LendingTradeRoot:    common.Hash{}, // never written to
+		}
+		blob, _ := rlp.EncodeToBytes(&book)
+		books.Update(common.BigToHash(big.NewInt(i)).Bytes(), blob)
+	}
+	for i := int64(0); i < 3; i++ {
+		pool := testLendingPool{
+			TotalDeposits:      big.NewInt(seed),
+			TotalDepositShares: big.NewInt(seed),
+			TotalBorrows:       big.NewInt(i),
+			TotalBorrowShares:  big.NewInt(i),
+			LastBlock:          uint64(i),
+			PositionRoot:       src.plainTrie(t, triedb, 8, seed*10+i),
+		}
+		blob, _ := rlp.EncodeToBytes(&pool)
+		books.Update(crypto.Keccak256(big.NewInt(seed+i).Bytes(), []byte("pool")), blob)
+	}
+	src.roots = append(src.roots, Root{Kind: LendingTrie, Hash: src.commit(t, books, triedb)})
+}
+
+// plainTrie creates a trie of n leaves without nested data.
+func (src *testSource) plainTrie(t *testing.T, triedb *trie.Database, n int, seed int64) common.Hash {
+	tr, _ := trie.New(common.Hash{}, triedb)
+	for i := 0; i < n; i++ {
+		key := crypto.Keccak256Hash(big.NewInt(seed).Bytes(), big.NewInt(int64(i)).Bytes())
+		tr.Update(key[:], bytes.Repeat([]byte{byte(i + 1)}, 20))
+	}
+	return src.commit(t, tr, triedb)
+}
+
+// listTrie creates a trie of n order or item lists, all pointing to child.
+func (src *testSource) listTrie(t *testing.T, triedb *trie.Database, n int, seed int64, child common.Hash) common.Hash {
+	tr, _ := trie.New(common.Hash{}, triedb)
+	for i := 0; i < n; i++ {
+		blob, _ := rlp.EncodeToBytes(&testList{Volume: big.NewInt(seed + int64(i)), Root: child})
+		tr.Update(common.BigToHash(big.NewInt(seed*100+int64(i))).Bytes(), blob)
+	}
+	return src.commit(t, tr, triedb)
+}
+
+func (src *testSource) commit(t *testing.T, tr *trie.Trie, triedb *trie.Database) common.Hash {
+	root, err := tr.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatalf("failed to flush trie: %v", err)
+	}
+	return root
+}
+
+// newTestSyncer creates a syncer over fresh databases, sharing the TomoX one
+// between the trading and lending states like a real node does.
+func newTestSyncer() (*Syncer, map[StateKind]ethdb.KeyValueStore) {
+	chaindb, tomoxdb := rawdb.NewMemoryDatabase(), rawdb.NewMemoryDatabase()
+	dbs := map[StateKind]ethdb.KeyValueStore{
+		StateTrie:   chaindb,
+		TradingTrie: tomoxdb,
+		LendingTrie: tomoxdb,
+	}
+	return NewSyncer(dbs), dbs
+}
+
+// connect links the syncer to a remote peer serving the given backend.
+func connect(syncer *Syncer, backend Backend, id byte) func() {
+	local, remote := p2p.MsgPipe()
+
+	localPeer := NewPeer(snap1, p2p.NewPeer(discover.NodeID{id}, "remote", nil), local)
+	remotePeer := NewPeer(snap1, p2p.NewPeer(discover.NodeID{0xff}, "local", nil), remote)
+
+	go Handle(&testBackend{}, syncer, localPeer)
+	go Handle(backend, nil, remotePeer)
+
+	return func() {
+		local.Close()
+		remote.Close()
+	}
+}
+
+// checkState verifies that a state trie with all its nested data is fully
+// present in the database and matches the source.
+func checkState(t *testing.T, dbs map[StateKind]ethdb.KeyValueStore, src *testSource, root Root) {
+	t.Helper()
+
+	// Nested tries are stored in the same database as their top level trie
+	kind := root.Kind
+
+	var check func(root common.Hash, typ trieType) int
+	check = func(root common.Hash, typ trieType) int {
+		have, err := trie.New(root, trie.NewDatabase(dbs[kind]))
+		if err != nil {
+			t.Fatalf("%v trie %x missing: %v", typ, root, err)
+		}
+		want, _ := trie.New(root, src.backend.dbs[kind])
+
+		count := 0
+		hit, wit := trie.NewIterator(have.NodeIterator(nil)), trie.NewIterator(want.NodeIterator(nil))
+		for wit.Next() {
+			if !hit.Next() {
+				t.Fatalf("trie %x missing entry %x: %v", root, wit.Key, hit.Err)
+			}
+			if !bytes.Equal(hit.Key, wit.Key) || !bytes.Equal(hit.Value, wit.Value) {
+				t.Fatalf("trie %x entry mismatch: have %x, want %x", root, hit.Key, wit.Key)
+			}
+			subs, codes, err := children(typ, hit.Value)
+			if err != nil {
+				t.Fatalf("failed to decode leaf: %v", err)
+			}
+			for _, sub := range subs {
+				count += check(sub.root, sub.typ)
+			}
+			for _, code := range codes {
+				if ok, _ := dbs[StateTrie].Has(code[:]); !ok {
+					t.Fatalf("code %x missing", code)
+				}
+			}
+			count++
+		}
+		if hit.Next() {
+			t.Fatalf("trie %x has extra entry %x", root, hit.Key)
+		}
+		return count
+	}
+	if count := check(root.Hash, topTrie(root.Kind)); count == 0 {
+		t.Fatalf("%v state empty", root.Kind)
+	}
+}
+
+// Tests that all state kinds are retrieved in proven ranges, including nested
+// tries spanning multiple responses.
+func TestSyncRanges(t *testing.T) {
+	defer func(bytes uint64) { requestBytes = bytes }(requestBytes)
+	requestBytes = 1024
+
+	src := newTestSource()
+	src.fill(t, 1)
+
+	syncer, dbs := newTestSyncer()
+	defer connect(syncer, src.backend, 1)()
+
+	if err := syncer.Sync(src.roots, nil); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	for _, root := range src.roots {
+		checkState(t, dbs, src, root)
+	}
+}
+
+// Tests that peers not having the requested state are skipped in favour of
+// ones that do.
+func TestSyncStatelessPeer(t *testing.T) {
+	src := newTestSource()
+	src.fill(t, 1)
+
+	syncer, dbs := newTestSyncer()
+	defer connect(syncer, newTestSource().backend, 1)()
+	defer connect(syncer, src.backend, 2)()
+
+	if err := syncer.Sync(src.roots, nil); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	for _, root := range src.roots {
+		checkState(t, dbs, src, root)
+	}
+}
+
+// Tests that a range ending at the largest possible key completes the trie instead
+// of wrapping around, and that a range proven empty does the same.
+func TestSyncRangeEnd(t *testing.T) {
+	newTrie := func(keys ...common.Hash) (*trie.Database, common.Hash) {
+		triedb := trie.NewDatabase(rawdb.NewMemoryDatabase())
+		tr, _ := trie.New(common.Hash{}, triedb)
+		for _, key := range keys {
+			tr.Update(key[:], key[:1])
+		}
+		root, _ := tr.Commit(nil)
+		return triedb, root
+	}
+	newTask := func(root common.Hash) (*syncRun, *rangeTask) {
+		syncer, _ := newTestSyncer()
+		run := newSyncRun(syncer, nil)
+		run.schedule(nil, StateTrie, root, plainTrie)
+		return run, run.tasks[0]
+	}
+	keys := []common.Hash{{1}, {2}, {3}, {4}, {5}, {6}, {7}, {8}}
+
+	// Retrieve a trie ending at the largest key in small ranges
+	triedb, root := newTrie(append(keys, maxHash)...)
+	run, task := newTask(root)
+	for i := 0; !task.done; i++ {
+		if i > len(keys) {
+			t.Fatalf("range retrieval not terminating, next %x", task.next)
+		}
+		entries, proof, _ := serveRange(triedb, root, task.next, maxHash, 64)
+		if err := run.processRange(task, task.next, entries, proof); err != nil {
+			t.Fatalf("failed to process range: %v", err)
+		}
+	}
+	if task.next == (common.Hash{}) {
+		t.Errorf("range wrapped around")
+	}
+	// Complete a trie with a range proven empty
+	triedb, root = newTrie(keys...)
+	run, task = newTask(root)
+
+	entries, proof, _ := serveRange(triedb, root, common.Hash{}, common.Hash{4}, 1024)
+	if err := run.processRange(task, common.Hash{}, entries, proof); err != nil {
+		t.Fatalf("failed to process range: %v", err)
+	}
+	rest, _, _ := serveRange(triedb, root, common.Hash{5}, maxHash, 1024)
+	for _, entry := range rest {
+		task.trie.Update(entry.Key[:], entry.Value)
+	}
+	origin := common.Hash{9}
+
+	// An empty range without a proof leaves the trie pending, skipping the peer
+	// only for a while
+	req := &request{peer: "peer", origin: origin, tasks: []*rangeTask{task}}
+	if err := run.processRanges(req, &response{ranges: [][]TrieEntry{{}}}); err != nil {
+		t.Fatalf("failed to process unproven empty range: %v", err)
+	}
+	if task.done || task.servable("peer") {
+		t.Errorf("unproven empty range accepted")
+	}
+	if _, ok := task.stateless["peer"]; ok {
+		t.Errorf("peer dropped for an unproven empty range")
+	}
+	entries, proof, _ = serveRange(triedb, root, origin, maxHash, 1024)
+	if entries == nil || len(entries) != 0 || proof == nil {
+		t.Fatalf("empty range not proven: %d entries, %d proof nodes", len(entries), len(proof))
+	}
+	if err := run.processRanges(req, &response{ranges: [][]TrieEntry{entries}, proof: proof}); err != nil {
+		t.Fatalf("failed to process proven empty range: %v", err)
+	}
+	if !task.done {
+		t.Errorf("proven empty range not completing the trie")
+	}
+}
+
+// Tests that a moved state is healed node by node from the previously synced
+// one instead of being retrieved from scratch.
+func TestSyncHeal(t *testing.T) {
+	src := newTestSource()
+	src.fill(t, 1)
+
+	syncer, dbs := newTestSyncer()
+	defer connect(syncer, src.backend, 1)()
+
+	if err := syncer.Sync(src.roots, nil); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	src.fill(t, 2)
+	if err := syncer.Sync(src.roots, nil); err != nil {
+		t.Fatalf("heal failed: %v", err)
+	}
+	if syncer.entries != 0 || syncer.nodes == 0 {
+		t.Fatalf("state not healed: entries %d, nodes %d", syncer.entries, syncer.nodes)
+	}
+	for _, root := range src.roots {
+		checkState(t, dbs, src, root)
+	}
+}
+
+// Tests that a sync without any peer serving the state can be cancelled.
+func TestSyncCancel(t *testing.T) {
+	src := newTestSource()
+	src.fill(t, 1)
+
+	syncer, _ := newTestSyncer()
+	defer connect(syncer, newTestSource().backend, 1)()
+
+	cancel := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(cancel) })
+	if err := syncer.Sync(src.roots, cancel); err != ErrCancelled {
+		t.Fatalf("sync error mismatch: have %v, want %v", err, ErrCancelled)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/posv"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/eth/snap"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// snapBackend serves the local main, trading and lending state tries to remote
+// peers over the snap protocol.
+type snapBackend struct {
+	eth *Ethereum
+}
+
+// TrieDB implements snap.Backend.
+func (b *snapBackend) TrieDB(kind snap.StateKind) *trie.Database {
+	switch kind {
+	case snap.StateTrie:
+		return b.eth.blockchain.StateCache().TrieDB()
+	case snap.TradingTrie:
+		if b.eth.TomoX != nil && b.eth.TomoX.GetStateCache() != nil {
+			return b.eth.TomoX.GetStateCache().TrieDB()
+		}
+	case snap.LendingTrie:
+		if b.eth.Lending != nil && b.eth.Lending.GetStateCache() != nil {
+			return b.eth.Lending.GetStateCache().TrieDB()
+		}
+	}
+	return nil
+}
+
+// snapStateSyncer retrieves the fast sync pivot state over the snap protocol,
+// covering the TomoX trading and lending states along the main one.
+type snapStateSyncer struct {
+	config *params.ChainConfig
+	engine consensus.Engine
+	syncer *snap.Syncer
+}
+
+// SyncState implements downloader.StateSyncer.
+func (s *snapStateSyncer) SyncState(block *types.Block, cancel <-chan struct{}) error {
+	roots := []snap.Root{{Kind: snap.StateTrie, Hash: block.Root()}}
+
+	// The TomoX state roots are carried by the block producer's state root
+	// transaction, so they are only known once the block body is available
+	engine, ok := s.engine.(*posv.Posv)
+	if ok && len(block.Transactions()) > 0 && s.config.Posv != nil && s.config.IsTIPTomoX(block.Number()) && block.NumberU64() > s.config.Posv.Epoch {
+		author, err := engine.Author(block.Header())
+		if err != nil {
+			return err
+		}
+		if engine.GetTomoXService != nil {
+			if trading := engine.GetTomoXService(); trading != nil {
+				root, err := trading.GetTradingStateRoot(block, author)
+				if err != nil {
+					return err
+				}
+				roots = append(roots, snap.Root{Kind: snap.TradingTrie, Hash: root})
+			}
+		}
+		if engine.GetLendingService != nil {
+			if lending := engine.GetLendingService(); lending != nil {
+				root, err := lending.GetLendingStateRoot(block, author)
+				if err != nil {
+					return err
+				}
+				roots = append(roots, snap.Root{Kind: snap.LendingTrie, Hash: root})
+			}
+		}
+	}
+	return s.syncer.Sync(roots, cancel)
+}
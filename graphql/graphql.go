@@ -0,0 +1,1120 @@
+// Package graphql provides a GraphQL interface to chain data and the TomoX
+// trading and lending books.
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/eth/filters"
+	"github.com/tomochain/tomochain/internal/ethapi"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+var (
+	errBlockNotFound       = errors.New("block not found")
+	errTomoXUnavailable    = errors.New("TomoX service not found")
+	errLendingUnavailable  = errors.New("TomoX Lending service not found")
+	errTradesNotIndexed    = errors.New("trade history is only available on SDK nodes")
+	errInvalidBlockRange   = errors.New("invalid block range")
+	errBlockRangeTooLarge  = fmt.Errorf("block range exceeds %d blocks", maxBlockRange)
+	errTransactionNotFound = errors.New("transaction not found")
+)
+
+// maxBlockRange is the maximum number of blocks a single blocks query may span.
+const maxBlockRange = 1024
+
+// Backend is the chain access needed by the resolvers, it is satisfied by the
+// EthApiBackend of a full node.
+type Backend interface {
+	ethapi.Backend
+	filters.Backend
+}
+
+// Long is a 64 bit unsigned integer scalar.
+type Long uint64
+
+// ImplementsGraphQLType returns true if Long implements the provided GraphQL type.
+func (b Long) ImplementsGraphQLType(name string) bool { return name == "Long" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data.
+func (b *Long) UnmarshalGraphQL(input interface{}) error {
+	var err error
+	switch input := input.(type) {
+	case string:
+		var value uint64
+		value, err = hexutil.DecodeUint64(input)
+		if err != nil {
+			value, err = strconv.ParseUint(input, 10, 64)
+		}
+		*b = Long(value)
+	case int32:
+		if input < 0 {
+			return fmt.Errorf("negative Long value %d", input)
+		}
+		*b = Long(input)
+	case int64:
+		if input < 0 {
+			return fmt.Errorf("negative Long value %d", input)
+		}
+		*b = Long(input)
+	case float64:
+		if input < 0 {
+			return fmt.Errorf("negative Long value %v", input)
+		}
+		*b = Long(input)
+	default:
+		err = fmt.Errorf("unexpected type %T for Long", input)
+	}
+	return err
+}
+
+// Account represents an account at a specific block.
+type Account struct {
+	backend Backend
+	address common.Address
+	number  rpc.BlockNumber
+}
+
+func (a *Account) getState(ctx context.Context) (*state.StateDB, error) {
+	statedb, _, err := a.backend.StateAndHeaderByNumber(ctx, a.number)
+	if statedb == nil && err == nil {
+		err = errBlockNotFound
+	}
+	return statedb, err
+}
+
+func (a *Account) Address(ctx context.Context) (common.Address, error) {
+	return a.address, nil
+}
+
+func (a *Account) Balance(ctx context.Context) (hexutil.Big, error) {
+	statedb, err := a.getState(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*statedb.GetBalance(a.address)), statedb.Error()
+}
+
+func (a *Account) TransactionCount(ctx context.Context) (Long, error) {
+	statedb, err := a.getState(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return Long(statedb.GetNonce(a.address)), statedb.Error()
+}
+
+func (a *Account) Code(ctx context.Context) (hexutil.Bytes, error) {
+	statedb, err := a.getState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return common.CopyBytes(statedb.GetCode(a.address)), statedb.Error()
+}
+
+func (a *Account) Storage(ctx context.Context, args struct{ Slot common.Hash }) (common.Hash, error) {
+	statedb, err := a.getState(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return statedb.GetState(a.address, args.Slot), statedb.Error()
+}
+
+// Log represents an individual log message. All arguments are mandatory.
+type Log struct {
+	backend     Backend
+	transaction *Transaction
+	log         *types.Log
+}
+
+func (l *Log) Transaction(ctx context.Context) *Transaction {
+	return l.transaction
+}
+
+func (l *Log) Account(ctx context.Context, args struct{ Block *Long }) *Account {
+	return &Account{backend: l.backend, address: l.log.Address, number: blockNumberOr(args.Block, rpc.BlockNumber(l.log.BlockNumber))}
+}
+
+func (l *Log) Index(ctx context.Context) int32 {
+	return int32(l.log.Index)
+}
+
+func (l *Log) Topics(ctx context.Context) []common.Hash {
+	return l.log.Topics
+}
+
+func (l *Log) Data(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(l.log.Data)
+}
+
+// Transaction represents an Ethereum transaction. The block and index are
+// only set if the transaction has been mined.
+type Transaction struct {
+	backend Backend
+	tx      *types.Transaction
+	block   *types.Block
+	index   uint64
+}
+
+// getReceipt returns the receipt of a mined transaction, or nil if the
+// transaction is still pending.
+func (t *Transaction) getReceipt(ctx context.Context) (*types.Receipt, error) {
+	if t.block == nil {
+		return nil, nil
+	}
+	receipts, err := t.backend.GetReceipts(ctx, t.block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if int(t.index) >= len(receipts) {
+		return nil, nil
+	}
+	return receipts[t.index], nil
+}
+
+// blockNumber returns the block to resolve accounts at, the latest block for
+// pending transactions.
+func (t *Transaction) blockNumber(override *Long) rpc.BlockNumber {
+	if t.block == nil {
+		return blockNumberOr(override, rpc.LatestBlockNumber)
+	}
+	return blockNumberOr(override, rpc.BlockNumber(t.block.NumberU64()))
+}
+
+func (t *Transaction) Hash(ctx context.Context) common.Hash {
+	return t.tx.Hash()
+}
+
+func (t *Transaction) Nonce(ctx context.Context) Long {
+	return Long(t.tx.Nonce())
+}
+
+func (t *Transaction) Index(ctx context.Context) *int32 {
+	if t.block == nil {
+		return nil
+	}
+	index := int32(t.index)
+	return &index
+}
+
+func (t *Transaction) From(ctx context.Context, args struct{ Block *Long }) (*Account, error) {
+	var signer types.Signer = types.FrontierSigner{}
+	if t.tx.Protected() {
+		signer = types.NewEIP155Signer(t.tx.ChainId())
+	}
+	from, err := types.Sender(signer, t.tx)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{backend: t.backend, address: from, number: t.blockNumber(args.Block)}, nil
+}
+
+func (t *Transaction) To(ctx context.Context, args struct{ Block *Long }) *Account {
+	to := t.tx.To()
+	if to == nil {
+		return nil
+	}
+	return &Account{backend: t.backend, address: *to, number: t.blockNumber(args.Block)}
+}
+
+func (t *Transaction) Value(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*t.tx.Value())
+}
+
+func (t *Transaction) GasPrice(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*t.tx.GasPrice())
+}
+
+func (t *Transaction) Gas(ctx context.Context) Long {
+	return Long(t.tx.Gas())
+}
+
+func (t *Transaction) InputData(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(t.tx.Data())
+}
+
+func (t *Transaction) Block(ctx context.Context) *Block {
+	if t.block == nil {
+		return nil
+	}
+	return &Block{backend: t.backend, block: t.block}
+}
+
+func (t *Transaction) Status(ctx context.Context) (*Long, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	status := Long(receipt.Status)
+	return &status, nil
+}
+
+func (t *Transaction) GasUsed(ctx context.Context) (*Long, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	gasUsed := Long(receipt.GasUsed)
+	return &gasUsed, nil
+}
+
+func (t *Transaction) CumulativeGasUsed(ctx context.Context) (*Long, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	gasUsed := Long(receipt.CumulativeGasUsed)
+	return &gasUsed, nil
+}
+
+func (t *Transaction) CreatedContract(ctx context.Context, args struct{ Block *Long }) (*Account, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil || receipt.ContractAddress == (common.Address{}) {
+		return nil, err
+	}
+	return &Account{backend: t.backend, address: receipt.ContractAddress, number: t.blockNumber(args.Block)}, nil
+}
+
+func (t *Transaction) Logs(ctx context.Context) (*[]*Log, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	logs := make([]*Log, 0, len(receipt.Logs))
+	for _, log := range receipt.Logs {
+		logs = append(logs, &Log{backend: t.backend, transaction: t, log: log})
+	}
+	return &logs, nil
+}
+
+func (t *Transaction) R(ctx context.Context) hexutil.Big {
+	_, r, _ := t.tx.RawSignatureValues()
+	return hexutil.Big(*r)
+}
+
+func (t *Transaction) S(ctx context.Context) hexutil.Big {
+	_, _, s := t.tx.RawSignatureValues()
+	return hexutil.Big(*s)
+}
+
+func (t *Transaction) V(ctx context.Context) hexutil.Big {
+	v, _, _ := t.tx.RawSignatureValues()
+	return hexutil.Big(*v)
+}
+
+func (t *Transaction) Orders(ctx context.Context) ([]*Order, error) {
+	if !t.tx.IsTradingTransaction() {
+		return []*Order{}, nil
+	}
+	batch, err := tradingstate.DecodeTxMatchesBatch(t.tx.Data())
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]*Order, 0, len(batch.Data))
+	for _, txMatch := range batch.Data {
+		order, err := txMatch.DecodeOrder()
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, &Order{order: order})
+	}
+	return orders, nil
+}
+
+func (t *Transaction) LendingItems(ctx context.Context) ([]*LendingOrder, error) {
+	if !t.tx.IsLendingTransaction() {
+		return []*LendingOrder{}, nil
+	}
+	batch, err := lendingstate.DecodeTxLendingBatch(t.tx.Data())
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*LendingOrder, 0, len(batch.Data))
+	for _, item := range batch.Data {
+		items = append(items, &LendingOrder{item: item})
+	}
+	return items, nil
+}
+
+// Block represents an Ethereum block.
+type Block struct {
+	backend Backend
+	block   *types.Block
+}
+
+// chainAPI returns the JSON-RPC chain API used to resolve the PoSV specific fields.
+func (b *Block) chainAPI() *ethapi.PublicBlockChainAPI {
+	return ethapi.NewPublicBlockChainAPI(b.backend)
+}
+
+func (b *Block) Number(ctx context.Context) Long {
+	return Long(b.block.NumberU64())
+}
+
+func (b *Block) Hash(ctx context.Context) common.Hash {
+	return b.block.Hash()
+}
+
+func (b *Block) Parent(ctx context.Context) (*Block, error) {
+	if b.block.NumberU64() == 0 {
+		return nil, nil
+	}
+	parent, err := b.backend.GetBlock(ctx, b.block.ParentHash())
+	if err != nil || parent == nil {
+		return nil, err
+	}
+	return &Block{backend: b.backend, block: parent}, nil
+}
+
+func (b *Block) Nonce(ctx context.Context) hexutil.Bytes {
+	nonce := b.block.Nonce()
+	return hexutil.Bytes(new(big.Int).SetUint64(nonce).Bytes())
+}
+
+func (b *Block) TransactionsRoot(ctx context.Context) common.Hash {
+	return b.block.TxHash()
+}
+
+func (b *Block) TransactionCount(ctx context.Context) *int32 {
+	count := int32(len(b.block.Transactions()))
+	return &count
+}
+
+func (b *Block) StateRoot(ctx context.Context) common.Hash {
+	return b.block.Root()
+}
+
+func (b *Block) ReceiptsRoot(ctx context.Context) common.Hash {
+	return b.block.ReceiptHash()
+}
+
+func (b *Block) Miner(ctx context.Context, args struct{ Block *Long }) (*Account, error) {
+	author, err := b.backend.GetEngine().Author(b.block.Header())
+	if err != nil {
+		return nil, err
+	}
+	return &Account{backend: b.backend, address: author, number: blockNumberOr(args.Block, rpc.BlockNumber(b.block.NumberU64()))}, nil
+}
+
+func (b *Block) ExtraData(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(b.block.Extra())
+}
+
+func (b *Block) GasLimit(ctx context.Context) Long {
+	return Long(b.block.GasLimit())
+}
+
+func (b *Block) GasUsed(ctx context.Context) Long {
+	return Long(b.block.GasUsed())
+}
+
+func (b *Block) Timestamp(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*b.block.Time())
+}
+
+func (b *Block) LogsBloom(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(b.block.Bloom().Bytes())
+}
+
+func (b *Block) MixHash(ctx context.Context) common.Hash {
+	return b.block.MixDigest()
+}
+
+func (b *Block) Difficulty(ctx context.Context) hexutil.Big {
+	return hexutil.Big(*b.block.Difficulty())
+}
+
+func (b *Block) TotalDifficulty(ctx context.Context) (hexutil.Big, error) {
+	td := b.backend.GetTd(b.block.Hash())
+	if td == nil {
+		return hexutil.Big{}, fmt.Errorf("total difficulty not found for block %x", b.block.Hash())
+	}
+	return hexutil.Big(*td), nil
+}
+
+func (b *Block) Validator(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(b.block.Header().Validator)
+}
+
+func (b *Block) Validators(ctx context.Context) hexutil.Bytes {
+	return hexutil.Bytes(b.block.Header().Validators)
+}
+
+func (b *Block) Penalties(ctx context.Context) []common.Address {
+	penalties := common.ExtractAddressFromBytes(b.block.Header().Penalties)
+	if penalties == nil {
+		return []common.Address{}
+	}
+	return penalties
+}
+
+func (b *Block) Masternodes(ctx context.Context) ([]common.Address, error) {
+	masternodes, err := b.chainAPI().GetMasternodes(ctx, b.block)
+	if masternodes == nil {
+		masternodes = []common.Address{}
+	}
+	return masternodes, err
+}
+
+func (b *Block) Signers(ctx context.Context) ([]common.Address, error) {
+	return b.chainAPI().GetBlockSignersByHash(ctx, b.block.Hash())
+}
+
+func (b *Block) Finality(ctx context.Context) (int32, error) {
+	finality, err := b.chainAPI().GetBlockFinalityByHash(ctx, b.block.Hash())
+	return int32(finality), err
+}
+
+func (b *Block) Transactions(ctx context.Context) *[]*Transaction {
+	txs := b.block.Transactions()
+	ret := make([]*Transaction, 0, len(txs))
+	for i, tx := range txs {
+		ret = append(ret, &Transaction{backend: b.backend, tx: tx, block: b.block, index: uint64(i)})
+	}
+	return &ret
+}
+
+func (b *Block) TransactionAt(ctx context.Context, args struct{ Index int32 }) *Transaction {
+	txs := b.block.Transactions()
+	if args.Index < 0 || int(args.Index) >= len(txs) {
+		return nil
+	}
+	return &Transaction{backend: b.backend, tx: txs[args.Index], block: b.block, index: uint64(args.Index)}
+}
+
+// BlockFilterCriteria encapsulates criteria passed to a `logs` accessor inside
+// a block.
+type BlockFilterCriteria struct {
+	Addresses *[]common.Address // restricts matches to events created by specific contracts
+	Topics    *[][]common.Hash  // restricts matches to particular event topics
+}
+
+func (b *Block) Logs(ctx context.Context, args struct{ Filter BlockFilterCriteria }) ([]*Log, error) {
+	number := int64(b.block.NumberU64())
+	return runFilter(ctx, b.backend, number, number, args.Filter.Addresses, args.Filter.Topics)
+}
+
+func (b *Block) Account(ctx context.Context, args struct{ Address common.Address }) *Account {
+	return &Account{backend: b.backend, address: args.Address, number: rpc.BlockNumber(b.block.NumberU64())}
+}
+
+// PriceVolume is a price level of a spot order book.
+type PriceVolume struct {
+	price, volume *big.Int
+}
+
+func (p *PriceVolume) Price(ctx context.Context) hexutil.Big  { return hexutil.Big(*p.price) }
+func (p *PriceVolume) Volume(ctx context.Context) hexutil.Big { return hexutil.Big(*p.volume) }
+
+// InterestVolume is an interest level of a lending book.
+type InterestVolume struct {
+	interest, volume *big.Int
+}
+
+func (i *InterestVolume) Interest(ctx context.Context) hexutil.Big { return hexutil.Big(*i.interest) }
+func (i *InterestVolume) Volume(ctx context.Context) hexutil.Big   { return hexutil.Big(*i.volume) }
+
+// Order is a TomoX spot order.
+type Order struct {
+	order *tradingstate.OrderItem
+}
+
+func (o *Order) Id(ctx context.Context) Long            { return Long(o.order.OrderID) }
+func (o *Order) Hash(ctx context.Context) common.Hash   { return o.order.Hash }
+func (o *Order) TxHash(ctx context.Context) common.Hash { return o.order.TxHash }
+func (o *Order) UserAddress(ctx context.Context) common.Address {
+	return o.order.UserAddress
+}
+func (o *Order) ExchangeAddress(ctx context.Context) common.Address {
+	return o.order.ExchangeAddress
+}
+func (o *Order) BaseToken(ctx context.Context) common.Address  { return o.order.BaseToken }
+func (o *Order) QuoteToken(ctx context.Context) common.Address { return o.order.QuoteToken }
+func (o *Order) Side(ctx context.Context) string               { return o.order.Side }
+func (o *Order) Type(ctx context.Context) string               { return o.order.Type }
+func (o *Order) Status(ctx context.Context) string             { return o.order.Status }
+func (o *Order) Price(ctx context.Context) *hexutil.Big        { return (*hexutil.Big)(o.order.Price) }
+func (o *Order) Quantity(ctx context.Context) *hexutil.Big     { return (*hexutil.Big)(o.order.Quantity) }
+func (o *Order) FilledAmount(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(o.order.FilledAmount)
+}
+func (o *Order) Nonce(ctx context.Context) *hexutil.Big { return (*hexutil.Big)(o.order.Nonce) }
+
+// Trade is a TomoX spot trade.
+type Trade struct {
+	trade *tradingstate.Trade
+}
+
+func (t *Trade) Hash(ctx context.Context) common.Hash             { return t.trade.Hash }
+func (t *Trade) TxHash(ctx context.Context) common.Hash           { return t.trade.TxHash }
+func (t *Trade) Taker(ctx context.Context) common.Address         { return t.trade.Taker }
+func (t *Trade) Maker(ctx context.Context) common.Address         { return t.trade.Maker }
+func (t *Trade) BaseToken(ctx context.Context) common.Address     { return t.trade.BaseToken }
+func (t *Trade) QuoteToken(ctx context.Context) common.Address    { return t.trade.QuoteToken }
+func (t *Trade) MakerOrderHash(ctx context.Context) common.Hash   { return t.trade.MakerOrderHash }
+func (t *Trade) TakerOrderHash(ctx context.Context) common.Hash   { return t.trade.TakerOrderHash }
+func (t *Trade) MakerExchange(ctx context.Context) common.Address { return t.trade.MakerExchange }
+func (t *Trade) TakerExchange(ctx context.Context) common.Address { return t.trade.TakerExchange }
+func (t *Trade) Price(ctx context.Context) *hexutil.Big           { return (*hexutil.Big)(t.trade.PricePoint) }
+func (t *Trade) Amount(ctx context.Context) *hexutil.Big          { return (*hexutil.Big)(t.trade.Amount) }
+func (t *Trade) MakeFee(ctx context.Context) *hexutil.Big         { return (*hexutil.Big)(t.trade.MakeFee) }
+func (t *Trade) TakeFee(ctx context.Context) *hexutil.Big         { return (*hexutil.Big)(t.trade.TakeFee) }
+func (t *Trade) Status(ctx context.Context) string                { return t.trade.Status }
+func (t *Trade) TakerOrderSide(ctx context.Context) string        { return t.trade.TakerOrderSide }
+func (t *Trade) TakerOrderType(ctx context.Context) string        { return t.trade.TakerOrderType }
+func (t *Trade) MakerOrderType(ctx context.Context) string        { return t.trade.MakerOrderType }
+
+// OrderBook is a TomoX spot order book at a specific block.
+type OrderBook struct {
+	backend    Backend
+	block      *types.Block
+	state      *tradingstate.TradingStateDB
+	baseToken  common.Address
+	quoteToken common.Address
+}
+
+func (o *OrderBook) hash() common.Hash {
+	return tradingstate.GetTradingOrderBookHash(o.baseToken, o.quoteToken)
+}
+
+func (o *OrderBook) BaseToken(ctx context.Context) common.Address  { return o.baseToken }
+func (o *OrderBook) QuoteToken(ctx context.Context) common.Address { return o.quoteToken }
+
+func (o *OrderBook) Block(ctx context.Context) *Block {
+	return &Block{backend: o.backend, block: o.block}
+}
+
+func (o *OrderBook) LastPrice(ctx context.Context) *hexutil.Big {
+	return nonZeroBig(o.state.GetLastPrice(o.hash()))
+}
+
+func (o *OrderBook) MediumPrice(ctx context.Context) *hexutil.Big {
+	price, _ := o.state.GetMediumPriceAndTotalAmount(o.hash())
+	return nonZeroBig(price)
+}
+
+func (o *OrderBook) MediumPriceBeforeEpoch(ctx context.Context) *hexutil.Big {
+	return nonZeroBig(o.state.GetMediumPriceBeforeEpoch(o.hash()))
+}
+
+func (o *OrderBook) BestBid(ctx context.Context) *PriceVolume {
+	price, volume := o.state.GetBestBidPrice(o.hash())
+	if price == nil || price.Sign() == 0 {
+		return nil
+	}
+	return &PriceVolume{price: price, volume: volume}
+}
+
+func (o *OrderBook) BestAsk(ctx context.Context) *PriceVolume {
+	price, volume := o.state.GetBestAskPrice(o.hash())
+	if price == nil || price.Sign() == 0 {
+		return nil
+	}
+	return &PriceVolume{price: price, volume: volume}
+}
+
+func (o *OrderBook) Bids(ctx context.Context) ([]*PriceVolume, error) {
+	bids, err := o.state.GetBids(o.hash())
+	if err != nil {
+		return nil, err
+	}
+	// Bids are listed from the highest to the lowest price
+	levels := priceLevels(bids)
+	for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+		levels[i], levels[j] = levels[j], levels[i]
+	}
+	return levels, nil
+}
+
+func (o *OrderBook) Asks(ctx context.Context) ([]*PriceVolume, error) {
+	asks, err := o.state.GetAsks(o.hash())
+	if err != nil {
+		return nil, err
+	}
+	return priceLevels(asks), nil
+}
+
+func (o *OrderBook) Order(ctx context.Context, args struct{ Id Long }) *Order {
+	orderId := common.BigToHash(new(big.Int).SetUint64(uint64(args.Id)))
+	item := o.state.GetOrder(o.hash(), orderId)
+	if item.Quantity == nil || item.Quantity.Sign() == 0 {
+		return nil
+	}
+	return &Order{order: &item}
+}
+
+// LendingOrder is a TomoX lending order.
+type LendingOrder struct {
+	item *lendingstate.LendingItem
+}
+
+func (l *LendingOrder) Id(ctx context.Context) Long            { return Long(l.item.LendingId) }
+func (l *LendingOrder) Hash(ctx context.Context) common.Hash   { return l.item.Hash }
+func (l *LendingOrder) TxHash(ctx context.Context) common.Hash { return l.item.TxHash }
+func (l *LendingOrder) UserAddress(ctx context.Context) common.Address {
+	return l.item.UserAddress
+}
+func (l *LendingOrder) Relayer(ctx context.Context) common.Address      { return l.item.Relayer }
+func (l *LendingOrder) LendingToken(ctx context.Context) common.Address { return l.item.LendingToken }
+func (l *LendingOrder) CollateralToken(ctx context.Context) common.Address {
+	return l.item.CollateralToken
+}
+func (l *LendingOrder) Term(ctx context.Context) Long { return Long(l.item.Term) }
+func (l *LendingOrder) Interest(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(l.item.Interest)
+}
+func (l *LendingOrder) Side(ctx context.Context) string   { return l.item.Side }
+func (l *LendingOrder) Type(ctx context.Context) string   { return l.item.Type }
+func (l *LendingOrder) Status(ctx context.Context) string { return l.item.Status }
+func (l *LendingOrder) Quantity(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(l.item.Quantity)
+}
+func (l *LendingOrder) FilledAmount(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(l.item.FilledAmount)
+}
+func (l *LendingOrder) AutoTopUp(ctx context.Context) bool     { return l.item.AutoTopUp }
+func (l *LendingOrder) Nonce(ctx context.Context) *hexutil.Big { return (*hexutil.Big)(l.item.Nonce) }
+func (l *LendingOrder) TradeId(ctx context.Context) Long       { return Long(l.item.LendingTradeId) }
+
+// LendingTrade is a TomoX lending trade.
+type LendingTrade struct {
+	trade *lendingstate.LendingTrade
+}
+
+func (l *LendingTrade) Id(ctx context.Context) Long                 { return Long(l.trade.TradeId) }
+func (l *LendingTrade) Hash(ctx context.Context) common.Hash        { return l.trade.Hash }
+func (l *LendingTrade) TxHash(ctx context.Context) common.Hash      { return l.trade.TxHash }
+func (l *LendingTrade) Borrower(ctx context.Context) common.Address { return l.trade.Borrower }
+func (l *LendingTrade) Investor(ctx context.Context) common.Address { return l.trade.Investor }
+func (l *LendingTrade) LendingToken(ctx context.Context) common.Address {
+	return l.trade.LendingToken
+}
+func (l *LendingTrade) CollateralToken(ctx context.Context) common.Address {
+	return l.trade.CollateralToken
+}
+func (l *LendingTrade) BorrowingOrderHash(ctx context.Context) common.Hash {
+	return l.trade.BorrowingOrderHash
+}
+func (l *LendingTrade) InvestingOrderHash(ctx context.Context) common.Hash {
+	return l.trade.InvestingOrderHash
+}
+func (l *LendingTrade) BorrowingRelayer(ctx context.Context) common.Address {
+	return l.trade.BorrowingRelayer
+}
+func (l *LendingTrade) InvestingRelayer(ctx context.Context) common.Address {
+	return l.trade.InvestingRelayer
+}
+func (l *LendingTrade) Term(ctx context.Context) Long     { return Long(l.trade.Term) }
+func (l *LendingTrade) Interest(ctx context.Context) Long { return Long(l.trade.Interest) }
+func (l *LendingTrade) CollateralPrice(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(l.trade.CollateralPrice)
+}
+func (l *LendingTrade) LiquidationPrice(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(l.trade.LiquidationPrice)
+}
+func (l *LendingTrade) CollateralLockedAmount(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(l.trade.CollateralLockedAmount)
+}
+func (l *LendingTrade) AutoTopUp(ctx context.Context) bool { return l.trade.AutoTopUp }
+func (l *LendingTrade) LiquidationTime(ctx context.Context) Long {
+	return Long(l.trade.LiquidationTime)
+}
+func (l *LendingTrade) Amount(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(l.trade.Amount)
+}
+func (l *LendingTrade) BorrowingFee(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(l.trade.BorrowingFee)
+}
+func (l *LendingTrade) InvestingFee(ctx context.Context) *hexutil.Big {
+	return (*hexutil.Big)(l.trade.InvestingFee)
+}
+func (l *LendingTrade) Status(ctx context.Context) string { return l.trade.Status }
+
+// LendingBook is a TomoX lending book at a specific block.
+type LendingBook struct {
+	backend      Backend
+	block        *types.Block
+	state        *lendingstate.LendingStateDB
+	lendingToken common.Address
+	term         uint64
+}
+
+func (l *LendingBook) hash() common.Hash {
+	return lendingstate.GetLendingOrderBookHash(l.lendingToken, l.term)
+}
+
+func (l *LendingBook) LendingToken(ctx context.Context) common.Address { return l.lendingToken }
+func (l *LendingBook) Term(ctx context.Context) Long                   { return Long(l.term) }
+
+func (l *LendingBook) Block(ctx context.Context) *Block {
+	return &Block{backend: l.backend, block: l.block}
+}
+
+func (l *LendingBook) BestInvesting(ctx context.Context) *InterestVolume {
+	interest, volume := l.state.GetBestInvestingRate(l.hash())
+	if interest == nil || interest.Sign() == 0 {
+		return nil
+	}
+	return &InterestVolume{interest: interest, volume: volume}
+}
+
+func (l *LendingBook) BestBorrowing(ctx context.Context) *InterestVolume {
+	interest, volume := l.state.GetBestBorrowRate(l.hash())
+	if interest == nil || interest.Sign() == 0 {
+		return nil
+	}
+	return &InterestVolume{interest: interest, volume: volume}
+}
+
+func (l *LendingBook) Investing(ctx context.Context) ([]*InterestVolume, error) {
+	levels, err := l.state.GetInvestings(l.hash())
+	if err != nil {
+		return nil, err
+	}
+	return interestLevels(levels), nil
+}
+
+func (l *LendingBook) Borrowing(ctx context.Context) ([]*InterestVolume, error) {
+	levels, err := l.state.GetBorrowings(l.hash())
+	if err != nil {
+		return nil, err
+	}
+	return interestLevels(levels), nil
+}
+
+func (l *LendingBook) Order(ctx context.Context, args struct{ Id Long }) *LendingOrder {
+	lendingId := common.BigToHash(new(big.Int).SetUint64(uint64(args.Id)))
+	item := l.state.GetLendingOrder(l.hash(), lendingId)
+	if item.LendingId != uint64(args.Id) {
+		return nil
+	}
+	return &LendingOrder{item: &item}
+}
+
+func (l *LendingBook) Trade(ctx context.Context, args struct{ Id Long }) *LendingTrade {
+	tradeId := common.BigToHash(new(big.Int).SetUint64(uint64(args.Id)))
+	trade := l.state.GetLendingTrade(l.hash(), tradeId)
+	if trade.TradeId != uint64(args.Id) {
+		return nil
+	}
+	return &LendingTrade{trade: &trade}
+}
+
+// Resolver is the top-level object in the GraphQL hierarchy.
+type Resolver struct {
+	backend Backend
+}
+
+func (r *Resolver) Block(ctx context.Context, args struct {
+	Number *Long
+	Hash   *common.Hash
+}) (*Block, error) {
+	var (
+		block *types.Block
+		err   error
+	)
+	switch {
+	case args.Hash != nil:
+		block, err = r.backend.GetBlock(ctx, *args.Hash)
+	case args.Number != nil:
+		block, err = r.backend.BlockByNumber(ctx, rpc.BlockNumber(*args.Number))
+	default:
+		block, err = r.backend.BlockByNumber(ctx, rpc.LatestBlockNumber)
+	}
+	if err != nil || block == nil {
+		return nil, err
+	}
+	return &Block{backend: r.backend, block: block}, nil
+}
+
+func (r *Resolver) Blocks(ctx context.Context, args struct {
+	From Long
+	To   *Long
+}) ([]*Block, error) {
+	to := Long(r.backend.CurrentBlock().NumberU64())
+	if args.To != nil {
+		to = *args.To
+	}
+	if to < args.From {
+		return nil, errInvalidBlockRange
+	}
+	if to-args.From >= maxBlockRange {
+		return nil, errBlockRangeTooLarge
+	}
+	ret := make([]*Block, 0, to-args.From+1)
+	for number := args.From; number <= to; number++ {
+		block, err := r.backend.BlockByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			break
+		}
+		ret = append(ret, &Block{backend: r.backend, block: block})
+	}
+	return ret, nil
+}
+
+func (r *Resolver) Transaction(ctx context.Context, args struct{ Hash common.Hash }) (*Transaction, error) {
+	tx, blockHash, _, index := rawdb.GetTransaction(r.backend.ChainDb(), args.Hash)
+	if tx == nil {
+		if tx = r.backend.GetPoolTransaction(args.Hash); tx == nil {
+			return nil, nil
+		}
+		return &Transaction{backend: r.backend, tx: tx}, nil
+	}
+	block, err := r.backend.GetBlock(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{backend: r.backend, tx: tx, block: block, index: index}, nil
+}
+
+// FilterCriteria encapsulates the arguments to `logs` on the root resolver object.
+type FilterCriteria struct {
+	FromBlock *Long             // beginning of the queried range, nil means latest block
+	ToBlock   *Long             // end of the range, nil means latest block
+	Addresses *[]common.Address // restricts matches to events created by specific contracts
+	Topics    *[][]common.Hash  // restricts matches to particular event topics
+}
+
+func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) ([]*Log, error) {
+	begin, end := rpc.LatestBlockNumber.Int64(), rpc.LatestBlockNumber.Int64()
+	if args.Filter.FromBlock != nil {
+		begin = int64(*args.Filter.FromBlock)
+	}
+	if args.Filter.ToBlock != nil {
+		end = int64(*args.Filter.ToBlock)
+	}
+	return runFilter(ctx, r.backend, begin, end, args.Filter.Addresses, args.Filter.Topics)
+}
+
+func (r *Resolver) GasPrice(ctx context.Context) (hexutil.Big, error) {
+	price, err := r.backend.SuggestPrice(ctx)
+	if err != nil {
+		return hexutil.Big{}, err
+	}
+	return hexutil.Big(*price), nil
+}
+
+func (r *Resolver) ProtocolVersion(ctx context.Context) int32 {
+	return int32(r.backend.ProtocolVersion())
+}
+
+// blockAt returns the block the TomoX books are read from together with its author.
+func (r *Resolver) blockAt(ctx context.Context, number *Long) (*types.Block, common.Address, error) {
+	block, err := r.backend.BlockByNumber(ctx, blockNumberOr(number, rpc.LatestBlockNumber))
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	if block == nil {
+		return nil, common.Address{}, errBlockNotFound
+	}
+	author, err := r.backend.GetEngine().Author(block.Header())
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return block, author, nil
+}
+
+func (r *Resolver) OrderBook(ctx context.Context, args struct {
+	BaseToken  common.Address
+	QuoteToken common.Address
+	Block      *Long
+}) (*OrderBook, error) {
+	tomoxService := r.backend.TomoxService()
+	if tomoxService == nil {
+		return nil, errTomoXUnavailable
+	}
+	block, author, err := r.blockAt(ctx, args.Block)
+	if err != nil {
+		return nil, err
+	}
+	tradingState, err := tomoxService.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderBook{backend: r.backend, block: block, state: tradingState, baseToken: args.BaseToken, quoteToken: args.QuoteToken}, nil
+}
+
+func (r *Resolver) LendingBook(ctx context.Context, args struct {
+	LendingToken common.Address
+	Term         Long
+	Block        *Long
+}) (*LendingBook, error) {
+	lendingService := r.backend.LendingService()
+	if lendingService == nil {
+		return nil, errLendingUnavailable
+	}
+	block, author, err := r.blockAt(ctx, args.Block)
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := lendingService.GetLendingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	return &LendingBook{backend: r.backend, block: block, state: lendingState, lendingToken: args.LendingToken, term: uint64(args.Term)}, nil
+}
+
+func (r *Resolver) Trades(ctx context.Context, args struct{ TxHash common.Hash }) ([]*Trade, error) {
+	tomoxService := r.backend.TomoxService()
+	if tomoxService == nil {
+		return nil, errTomoXUnavailable
+	}
+	if !tomoxService.IsSDKNode() {
+		return nil, errTradesNotIndexed
+	}
+	items, _ := tomoxService.GetMongoDB().GetListItemByTxHash(args.TxHash, &tradingstate.Trade{}).([]*tradingstate.Trade)
+	trades := make([]*Trade, 0, len(items))
+	for _, item := range items {
+		trades = append(trades, &Trade{trade: item})
+	}
+	return trades, nil
+}
+
+// LendingTrades returns the lending trades opened or updated by a lending
+// transaction. The trades are looked up in the lending state of the block the
+// transaction was included in.
+func (r *Resolver) LendingTrades(ctx context.Context, args struct{ TxHash common.Hash }) ([]*LendingTrade, error) {
+	lendingService := r.backend.LendingService()
+	if lendingService == nil {
+		return nil, errLendingUnavailable
+	}
+	if tomoxService := r.backend.TomoxService(); tomoxService != nil && tomoxService.IsSDKNode() {
+		items, _ := lendingService.GetMongoDB().GetListItemByTxHash(args.TxHash, &lendingstate.LendingTrade{}).([]*lendingstate.LendingTrade)
+		trades := make([]*LendingTrade, 0, len(items))
+		for _, item := range items {
+			trades = append(trades, &LendingTrade{trade: item})
+		}
+		return trades, nil
+	}
+	tx, blockHash, _, _ := rawdb.GetTransaction(r.backend.ChainDb(), args.TxHash)
+	if tx == nil {
+		return nil, errTransactionNotFound
+	}
+	if !tx.IsLendingTransaction() {
+		return []*LendingTrade{}, nil
+	}
+	block, err := r.backend.GetBlock(ctx, blockHash)
+	if err != nil || block == nil {
+		return nil, errBlockNotFound
+	}
+	author, err := r.backend.GetEngine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := lendingService.GetLendingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := lendingstate.DecodeTxLendingBatch(tx.Data())
+	if err != nil {
+		return nil, err
+	}
+	trades := []*LendingTrade{}
+	for _, item := range batch.Data {
+		if item.LendingTradeId == 0 {
+			continue
+		}
+		lendingBook := lendingstate.GetLendingOrderBookHash(item.LendingToken, item.Term)
+		trade := lendingState.GetLendingTrade(lendingBook, common.BigToHash(new(big.Int).SetUint64(item.LendingTradeId)))
+		if trade.TradeId == item.LendingTradeId {
+			trades = append(trades, &LendingTrade{trade: &trade})
+		}
+	}
+	return trades, nil
+}
+
+func (r *Resolver) SendRawTransaction(ctx context.Context, args struct{ Data hexutil.Bytes }) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(args.Data); err != nil {
+		return common.Hash{}, err
+	}
+	if err := r.backend.SendTx(ctx, tx); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+// runFilter runs a log filter over the given block range and wraps the results.
+func runFilter(ctx context.Context, backend Backend, begin, end int64, addresses *[]common.Address, topics *[][]common.Hash) ([]*Log, error) {
+	var (
+		addrs     []common.Address
+		topicSets [][]common.Hash
+	)
+	if addresses != nil {
+		addrs = *addresses
+	}
+	if topics != nil {
+		topicSets = *topics
+	}
+	logs, err := filters.New(backend, begin, end, addrs, topicSets).Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*Log, 0, len(logs))
+	for _, log := range logs {
+		tx, _, _, index := rawdb.GetTransaction(backend.ChainDb(), log.TxHash)
+		if tx == nil {
+			continue
+		}
+		block, err := backend.GetBlock(ctx, log.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &Log{
+			backend:     backend,
+			transaction: &Transaction{backend: backend, tx: tx, block: block, index: index},
+			log:         log,
+		})
+	}
+	return ret, nil
+}
+
+// blockNumberOr returns the requested block number, or the fallback if none was given.
+func blockNumberOr(number *Long, fallback rpc.BlockNumber) rpc.BlockNumber {
+	if number == nil {
+		return fallback
+	}
+	return rpc.BlockNumber(*number)
+}
+
+// nonZeroBig converts a big integer to its GraphQL form, nil for unset or zero values.
+func nonZeroBig(n *big.Int) *hexutil.Big {
+	if n == nil || n.Sign() == 0 {
+		return nil
+	}
+	return (*hexutil.Big)(n)
+}
+
+// priceLevels turns an order book side into a list of levels sorted by ascending price.
+func priceLevels(levels map[*big.Int]*big.Int) []*PriceVolume {
+	ret := make([]*PriceVolume, 0, len(levels))
+	for price, volume := range levels {
+		ret = append(ret, &PriceVolume{price: price, volume: volume})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].price.Cmp(ret[j].price) < 0 })
+	return ret
+}
+
+// interestLevels turns a lending book side into a list of levels sorted by ascending interest.
+func interestLevels(levels map[*big.Int]*big.Int) []*InterestVolume {
+	ret := make([]*InterestVolume, 0, len(levels))
+	for interest, volume := range levels {
+		ret = append(ret, &InterestVolume{interest: interest, volume: volume})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].interest.Cmp(ret[j].interest) < 0 })
+	return ret
+}
@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+// Tests that all fields of the schema are bound to a resolver.
+func TestBuildSchema(t *testing.T) {
+	if _, err := graphql.ParseSchema(schema, &Resolver{}); err != nil {
+		t.Fatalf("could not create schema: %v", err)
+	}
+}
+
+func TestLongUnmarshal(t *testing.T) {
+	tests := []struct {
+		input interface{}
+		want  Long
+		fail  bool
+	}{
+		{input: int32(42), want: 42},
+		{input: float64(1000000), want: 1000000},
+		{input: "0x10", want: 16},
+		{input: "123", want: 123},
+		{input: int32(-1), fail: true},
+		{input: "0xzz", fail: true},
+		{input: true, fail: true},
+	}
+	for i, tt := range tests {
+		var l Long
+		err := l.UnmarshalGraphQL(tt.input)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: expected error for %v", i, tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		} else if l != tt.want {
+			t.Errorf("test %d: value mismatch: have %d, want %d", i, l, tt.want)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/bloombits"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/event"
+	"github.com/tomochain/tomochain/internal/ethapi"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	testBaseToken    = common.HexToAddress("0x0000000000000000000000000000000000000b01")
+	testQuoteToken   = common.HexToAddress("0x0000000000000000000000000000000000000b02")
+	testLendingToken = common.HexToAddress("0x0000000000000000000000000000000000000b03")
+)
+
+// testBackend serves the resolvers from a local chain, the methods not needed
+// by the queries are left to the nil embedded interface.
+type testBackend struct {
+	ethapi.Backend
+
+	db      ethdb.Database
+	chain   *core.BlockChain
+	tomox   *tomox.TomoX
+	lending *tomoxlending.Lending
+}
+
+func (b *testBackend) ChainDb() ethdb.Database               { return b.db }
+func (b *testBackend) CurrentBlock() *types.Block            { return b.chain.CurrentBlock() }
+func (b *testBackend) GetEngine() consensus.Engine           { return b.chain.Engine() }
+func (b *testBackend) GetTd(hash common.Hash) *big.Int       { return b.chain.GetTdByHash(hash) }
+func (b *testBackend) TomoxService() *tomox.TomoX            { return b.tomox }
+func (b *testBackend) LendingService() *tomoxlending.Lending { return b.lending }
+
+func (b *testBackend) GetPoolTransaction(hash common.Hash) *types.Transaction { return nil }
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	block, err := b.BlockByNumber(ctx, number)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (b *testBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(hash), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, err := b.HeaderByNumber(ctx, number)
+	if header == nil || err != nil {
+		return nil, nil, err
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return rawdb.GetBlockReceipts(b.db, hash, rawdb.GetBlockNumber(b.db, hash), b.chain.Config()), nil
+}
+
+func (b *testBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	receipts, _ := b.GetReceipts(ctx, hash)
+	logs := make([][]*types.Log, len(receipts))
+	for i, receipt := range receipts {
+		logs[i] = receipt.Logs
+	}
+	return logs, nil
+}
+
+func (b *testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
+	return nil
+}
+func (b *testBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription         { return nil }
+func (b *testBackend) BloomStatus() (uint64, uint64)                                        { return 0, 0 }
+func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {}
+
+// newTestBackend creates a chain of three blocks: a value transfer in the first,
+// and the trading and lending roots of the books committed by the miner in the
+// second.
+func newTestBackend(t *testing.T) (*testBackend, *types.Transaction) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		engine  = ethash.NewFaker()
+		signer  = types.HomesteadSigner{}
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{testAddress: {Balance: big.NewInt(1000000000000000000)}}}
+		genesis = gspec.MustCommit(db)
+	)
+	// Open a spot order book and a lending book
+	tradingDB := tradingstate.NewDatabase(rawdb.NewMemoryDatabase())
+	tradingState, _ := tradingstate.New(tradingstate.EmptyRoot, tradingDB)
+	orderBook := tradingstate.GetTradingOrderBookHash(testBaseToken, testQuoteToken)
+	for i, side := range []string{tradingstate.Bid, tradingstate.Ask} {
+		id := uint64(i + 1)
+		tradingState.InsertOrderItem(orderBook, common.Uint64ToHash(id), tradingstate.OrderItem{
+			OrderID:     id,
+			Quantity:    big.NewInt(int64(10 * id)),
+			Price:       big.NewInt(int64(100 + id)),
+			Side:        side,
+			BaseToken:   testBaseToken,
+			QuoteToken:  testQuoteToken,
+			UserAddress: testAddress,
+			Status:      tradingstate.OrderStatusOpen,
+			Signature:   &tradingstate.Signature{V: 27},
+		})
+	}
+	tradingState.SetNonce(orderBook, 2)
+	tradingState.SetLastPrice(orderBook, big.NewInt(101))
+	tradingRoot, err := tradingState.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit trading state: %v", err)
+	}
+	lendingDB := lendingstate.NewDatabase(rawdb.NewMemoryDatabase())
+	lendingState, _ := lendingstate.New(lendingstate.EmptyRoot, lendingDB)
+	lendingBook := lendingstate.GetLendingOrderBookHash(testLendingToken, 30)
+	lendingState.InsertLendingItem(lendingBook, common.Uint64ToHash(1), lendingstate.LendingItem{
+		LendingId:    1,
+		Quantity:     big.NewInt(500),
+		Interest:     big.NewInt(7),
+		Side:         lendingstate.Investing,
+		Term:         30,
+		LendingToken: testLendingToken,
+		UserAddress:  testAddress,
+		Status:       lendingstate.LendingStatusOpen,
+		Signature:    &lendingstate.Signature{V: 27},
+	})
+	lendingState.SetNonce(lendingBook, 1)
+	lendingRoot, err := lendingState.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit lending state: %v", err)
+	}
+
+	var transfer *types.Transaction
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, engine, db, 3, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(testAddress)
+		switch i {
+		case 0:
+			transfer, _ = types.SignTx(types.NewTransaction(b.TxNonce(testAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, testKey)
+			b.AddTx(transfer)
+		case 1:
+			data := append(tradingRoot.Bytes(), lendingRoot.Bytes()...)
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddress), common.HexToAddress(common.TradingStateAddr), new(big.Int), 100000, nil, data), signer, testKey)
+			b.AddTx(tx)
+		}
+	})
+	chain, err := core.NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return &testBackend{
+		db:      db,
+		chain:   chain,
+		tomox:   &tomox.TomoX{StateCache: tradingDB},
+		lending: &tomoxlending.Lending{StateCache: lendingDB},
+	}, transfer
+}
+
+// query posts a GraphQL query to the handler and decodes the returned data.
+func query(t *testing.T, handler http.Handler, q string, result interface{}) []interface{} {
+	body, _ := json.Marshal(map[string]string{"query": q})
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query %q: status %d", q, rec.Code)
+	}
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []interface{}   `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("query %q: invalid response: %v", q, err)
+	}
+	if result != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, result); err != nil {
+			t.Fatalf("query %q: invalid data %s: %v", q, resp.Data, err)
+		}
+	}
+	return resp.Errors
+}
+
+func TestQueryBlocks(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	var latest struct {
+		Block struct {
+			Number           uint64
+			Hash             common.Hash
+			TransactionCount int
+			Miner            struct{ Address common.Address }
+			Parent           struct{ Number uint64 }
+		}
+	}
+	if errs := query(t, handler, `{ block { number hash transactionCount miner { address } parent { number } } }`, &latest); len(errs) != 0 {
+		t.Fatalf("query failed: %v", errs)
+	}
+	head := backend.chain.CurrentBlock()
+	if latest.Block.Hash != head.Hash() || latest.Block.Number != 3 || latest.Block.Parent.Number != 2 {
+		t.Errorf("latest block mismatch: %+v", latest.Block)
+	}
+	if latest.Block.Miner.Address != testAddress {
+		t.Errorf("miner mismatch: have %x, want %x", latest.Block.Miner.Address, testAddress)
+	}
+
+	var ranged struct {
+		Blocks []struct{ Number uint64 }
+	}
+	if errs := query(t, handler, `{ blocks(from: 1, to: 2) { number } }`, &ranged); len(errs) != 0 {
+		t.Fatalf("query failed: %v", errs)
+	}
+	if len(ranged.Blocks) != 2 || ranged.Blocks[0].Number != 1 || ranged.Blocks[1].Number != 2 {
+		t.Errorf("block range mismatch: %+v", ranged.Blocks)
+	}
+	if errs := query(t, handler, `{ blocks(from: 3, to: 1) { number } }`, nil); len(errs) == 0 {
+		t.Errorf("inverted block range accepted")
+	}
+	var missing struct {
+		Block *struct{ Number uint64 }
+	}
+	if errs := query(t, handler, `{ block(number: 100) { number } }`, &missing); len(errs) != 0 || missing.Block != nil {
+		t.Errorf("unknown block returned: %v %v", missing.Block, errs)
+	}
+}
+
+func TestQueryTransaction(t *testing.T) {
+	backend, transfer := newTestBackend(t)
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	var result struct {
+		Transaction struct {
+			Hash    common.Hash
+			Index   int
+			Value   string
+			Status  uint64
+			GasUsed uint64
+			From    struct {
+				Address          common.Address
+				TransactionCount uint64
+			}
+			To    struct{ Address common.Address }
+			Block struct{ Number uint64 }
+		}
+	}
+	q := `{ transaction(hash: "` + transfer.Hash().Hex() + `") { hash index value status gasUsed from { address transactionCount } to { address } block { number } } }`
+	if errs := query(t, handler, q, &result); len(errs) != 0 {
+		t.Fatalf("query failed: %v", errs)
+	}
+	tx := result.Transaction
+	if tx.Hash != transfer.Hash() || tx.Index != 0 || tx.Block.Number != 1 {
+		t.Errorf("transaction position mismatch: %+v", tx)
+	}
+	if tx.Value != "0x3e8" || tx.Status != 1 || tx.GasUsed != params.TxGas {
+		t.Errorf("transaction execution mismatch: %+v", tx)
+	}
+	if tx.From.Address != testAddress || tx.From.TransactionCount != 1 || tx.To.Address != (common.Address{0x01}) {
+		t.Errorf("transaction accounts mismatch: %+v", tx)
+	}
+
+	var missing struct {
+		Transaction *struct{ Hash common.Hash }
+	}
+	if errs := query(t, handler, `{ transaction(hash: "`+common.Hash{0xff}.Hex()+`") { hash } }`, &missing); len(errs) != 0 || missing.Transaction != nil {
+		t.Errorf("unknown transaction returned: %v %v", missing.Transaction, errs)
+	}
+}
+
+func TestQueryBooks(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	defer backend.chain.Stop()
+
+	handler, err := newHandler(backend)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	type priceVolume struct{ Price, Volume string }
+	var spot struct {
+		OrderBook struct {
+			LastPrice string
+			BestBid   priceVolume
+			BestAsk   priceVolume
+			Bids      []priceVolume
+			Asks      []priceVolume
+			Order     struct {
+				Id       uint64
+				Side     string
+				Quantity string
+			}
+		}
+	}
+	q := `{ orderBook(baseToken: "` + testBaseToken.Hex() + `", quoteToken: "` + testQuoteToken.Hex() + `", block: 2) {
+		lastPrice bestBid { price volume } bestAsk { price volume } bids { price volume } asks { price volume }
+		order(id: 2) { id side quantity } } }`
+	if errs := query(t, handler, q, &spot); len(errs) != 0 {
+		t.Fatalf("query failed: %v", errs)
+	}
+	book := spot.OrderBook
+	if book.LastPrice != "0x65" {
+		t.Errorf("last price mismatch: have %s, want 0x65", book.LastPrice)
+	}
+	if book.BestBid != (priceVolume{"0x65", "0xa"}) || book.BestAsk != (priceVolume{"0x66", "0x14"}) {
+		t.Errorf("best prices mismatch: bid %+v, ask %+v", book.BestBid, book.BestAsk)
+	}
+	if len(book.Bids) != 1 || len(book.Asks) != 1 {
+		t.Errorf("depth mismatch: %d bids, %d asks", len(book.Bids), len(book.Asks))
+	}
+	if book.Order.Id != 2 || book.Order.Side != tradingstate.Ask || book.Order.Quantity != "0x14" {
+		t.Errorf("order mismatch: %+v", book.Order)
+	}
+
+	// The books are read from the miner's state root at the requested block
+	var empty struct {
+		OrderBook struct{ LastPrice *string }
+	}
+	q = `{ orderBook(baseToken: "` + testBaseToken.Hex() + `", quoteToken: "` + testQuoteToken.Hex() + `", block: 1) { lastPrice } }`
+	if errs := query(t, handler, q, &empty); len(errs) != 0 {
+		t.Fatalf("query failed: %v", errs)
+	}
+	if empty.OrderBook.LastPrice != nil {
+		t.Errorf("order book exists before it was committed: last price %s", *empty.OrderBook.LastPrice)
+	}
+
+	var lending struct {
+		LendingBook struct {
+			Term          uint64
+			BestInvesting struct{ Interest, Volume string }
+			BestBorrowing *struct{ Interest, Volume string }
+			Order         struct {
+				Id           uint64
+				Side         string
+				LendingToken common.Address
+			}
+		}
+	}
+	q = `{ lendingBook(lendingToken: "` + testLendingToken.Hex() + `", term: 30, block: 2) {
+		term bestInvesting { interest volume } bestBorrowing { interest volume } order(id: 1) { id side lendingToken } } }`
+	if errs := query(t, handler, q, &lending); len(errs) != 0 {
+		t.Fatalf("query failed: %v", errs)
+	}
+	lbook := lending.LendingBook
+	if lbook.Term != 30 || lbook.BestInvesting.Interest != "0x7" || lbook.BestInvesting.Volume != "0x1f4" || lbook.BestBorrowing != nil {
+		t.Errorf("lending book mismatch: %+v", lbook)
+	}
+	if lbook.Order.Id != 1 || lbook.Order.Side != lendingstate.Investing || lbook.Order.LendingToken != testLendingToken {
+		t.Errorf("lending order mismatch: %+v", lbook.Order)
+	}
+
+	// Nodes without TomoX report the books as unavailable
+	backend.tomox = nil
+	if errs := query(t, handler, `{ orderBook(baseToken: "`+testBaseToken.Hex()+`", quoteToken: "`+testQuoteToken.Hex()+`") { lastPrice } }`, nil); len(errs) == 0 {
+		t.Errorf("order book served without TomoX")
+	}
+}
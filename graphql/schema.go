@@ -0,0 +1,368 @@
+package graphql
+
+const schema string = `
+    # Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
+    scalar Bytes32
+    # Address is a 20 byte Ethereum address, represented as 0x-prefixed hexadecimal.
+    scalar Address
+    # Bytes is an arbitrary length binary string, represented as 0x-prefixed hexadecimal.
+    # An empty byte string is represented as '0x'. Byte strings must have an even number of hexadecimal nybbles.
+    scalar Bytes
+    # BigInt is a large integer. Input is accepted as either a JSON number or as a string.
+    # Strings may be either decimal or 0x-prefixed hexadecimal. Output values are all
+    # 0x-prefixed hexadecimal.
+    scalar BigInt
+    # Long is a 64 bit unsigned integer.
+    scalar Long
+
+    schema {
+        query: Query
+        mutation: Mutation
+    }
+
+    # Account is an account at a particular block.
+    type Account {
+        # Address is the address owning the account.
+        address: Address!
+        # Balance is the balance of the account, in wei.
+        balance: BigInt!
+        # TransactionCount is the number of transactions sent from this account,
+        # or in the case of a contract, the number of contracts created. Otherwise
+        # known as the nonce.
+        transactionCount: Long!
+        # Code contains the smart contract code for this account, if the account
+        # is a (non-self-destructed) contract.
+        code: Bytes!
+        # Storage provides access to the storage of a contract account, indexed
+        # by its 32 byte slot identifier.
+        storage(slot: Bytes32!): Bytes32!
+    }
+
+    # Log is an Ethereum event log.
+    type Log {
+        # Index is the index of this log in the block.
+        index: Int!
+        # Account is the account which generated this log - this will always
+        # be a contract account.
+        account(block: Long): Account!
+        # Topics is a list of 0-4 indexed topics for the log.
+        topics: [Bytes32!]!
+        # Data is unindexed data for this log.
+        data: Bytes!
+        # Transaction is the transaction that generated this log entry.
+        transaction: Transaction!
+    }
+
+    # Transaction is an Ethereum transaction.
+    type Transaction {
+        # Hash is the hash of this transaction.
+        hash: Bytes32!
+        # Nonce is the nonce of the account this transaction was generated with.
+        nonce: Long!
+        # Index is the index of this transaction in the parent block. This will
+        # be null if the transaction has not yet been mined.
+        index: Int
+        # From is the account that sent this transaction - this will always be
+        # an externally owned account.
+        from(block: Long): Account!
+        # To is the account the transaction was sent to. This is null for
+        # contract-creating transactions.
+        to(block: Long): Account
+        # Value is the value, in wei, sent along with this transaction.
+        value: BigInt!
+        # GasPrice is the price offered to miners for gas, in wei per unit.
+        gasPrice: BigInt!
+        # Gas is the maximum amount of gas this transaction can consume.
+        gas: Long!
+        # InputData is the data supplied to the target of the transaction.
+        inputData: Bytes!
+        # Block is the block this transaction was mined in. This will be null if
+        # the transaction has not yet been mined.
+        block: Block
+        # Status is the return status of the transaction. This will be 1 if the
+        # transaction succeeded, or 0 if it failed (due to a revert, or due to
+        # running out of gas). If the transaction has not yet been mined, this
+        # field will be null.
+        status: Long
+        # GasUsed is the amount of gas that was used processing this transaction.
+        # If the transaction has not yet been mined, this field will be null.
+        gasUsed: Long
+        # CumulativeGasUsed is the total gas used in the block up to and including
+        # this transaction. If the transaction has not yet been mined, this field
+        # will be null.
+        cumulativeGasUsed: Long
+        # CreatedContract is the account that was created by a contract creation
+        # transaction. If the transaction was not a contract creation transaction,
+        # or it has not yet been mined, this field will be null.
+        createdContract(block: Long): Account
+        # Logs is a list of log entries emitted by this transaction. If the
+        # transaction has not yet been mined, this field will be null.
+        logs: [Log!]
+        # R, S and V are the signature values of the transaction.
+        r: BigInt!
+        s: BigInt!
+        v: BigInt!
+        # Orders is the list of TomoX orders matched by this transaction. It is
+        # empty unless the transaction is a TomoX matching transaction.
+        orders: [Order!]!
+        # LendingItems is the list of lending orders matched by this transaction.
+        # It is empty unless the transaction is a TomoX lending transaction.
+        lendingItems: [LendingOrder!]!
+    }
+
+    # Block is an Ethereum block.
+    type Block {
+        # Number is the number of this block, starting at 0 for the genesis block.
+        number: Long!
+        # Hash is the block hash of this block.
+        hash: Bytes32!
+        # Parent is the parent block of this block.
+        parent: Block
+        # Nonce is the block nonce, an 8 byte sequence determined by the miner.
+        nonce: Bytes!
+        # TransactionsRoot is the keccak256 hash of the root of the trie of transactions in this block.
+        transactionsRoot: Bytes32!
+        # TransactionCount is the number of transactions in this block.
+        transactionCount: Int
+        # StateRoot is the keccak256 hash of the state trie after this block was processed.
+        stateRoot: Bytes32!
+        # ReceiptsRoot is the keccak256 hash of the trie of transaction receipts in this block.
+        receiptsRoot: Bytes32!
+        # Miner is the account that mined this block.
+        miner(block: Long): Account!
+        # ExtraData is an arbitrary data field supplied by the miner.
+        extraData: Bytes!
+        # GasLimit is the maximum amount of gas that was available to transactions in this block.
+        gasLimit: Long!
+        # GasUsed is the amount of gas that was used executing transactions in this block.
+        gasUsed: Long!
+        # Timestamp is the unix timestamp at which this block was mined.
+        timestamp: BigInt!
+        # LogsBloom is a bloom filter that can be used to check if a block may
+        # contain log entries matching a filter.
+        logsBloom: Bytes!
+        # MixHash is the hash that was used as an input to the PoW process.
+        mixHash: Bytes32!
+        # Difficulty is a measure of the difficulty of mining this block.
+        difficulty: BigInt!
+        # TotalDifficulty is the sum of all difficulty values up to and including
+        # this block.
+        totalDifficulty: BigInt!
+        # Validator is the signature of the double validator of this block.
+        validator: Bytes!
+        # Validators is the encoded list of validators set at checkpoint blocks.
+        validators: Bytes!
+        # Penalties is the list of masternodes penalized at this checkpoint block.
+        penalties: [Address!]!
+        # Masternodes is the masternode set of the epoch this block belongs to.
+        masternodes: [Address!]!
+        # Signers is the list of masternodes that signed this block.
+        signers: [Address!]!
+        # Finality is the percentage of the masternode set that signed this block.
+        finality: Int!
+        # Transactions is a list of transactions associated with this block.
+        transactions: [Transaction!]
+        # TransactionAt returns the transaction at the specified index.
+        transactionAt(index: Int!): Transaction
+        # Logs returns a filtered set of logs from this block.
+        logs(filter: BlockFilterCriteria!): [Log!]!
+        # Account fetches an Ethereum account at the current block's state.
+        account(address: Address!): Account!
+    }
+
+    # BlockFilterCriteria encapsulates log filter criteria for a filter applied
+    # to a single block.
+    input BlockFilterCriteria {
+        # Addresses is list of addresses that are of interest. If this list is
+        # empty, results will not be filtered by address.
+        addresses: [Address!]
+        # Topics list restricts matches to particular event topics. Each event has a list
+        # of topics. Topics matches a prefix of that list. An empty element array matches any
+        # topic. Non-empty elements represent an alternative that matches any of the
+        # contained topics.
+        topics: [[Bytes32!]!]
+    }
+
+    # FilterCriteria encapsulates log filter criteria for searching log entries.
+    input FilterCriteria {
+        # FromBlock is the block at which to start searching, inclusive. Defaults
+        # to the latest block if not supplied.
+        fromBlock: Long
+        # ToBlock is the block at which to stop searching, inclusive. Defaults
+        # to the latest block if not supplied.
+        toBlock: Long
+        # Addresses is a list of addresses that are of interest. If this list is
+        # empty, results will not be filtered by address.
+        addresses: [Address!]
+        # Topics list restricts matches to particular event topics.
+        topics: [[Bytes32!]!]
+    }
+
+    # PriceVolume is a price level of a TomoX order book.
+    type PriceVolume {
+        price: BigInt!
+        volume: BigInt!
+    }
+
+    # InterestVolume is an interest level of a TomoX lending book.
+    type InterestVolume {
+        interest: BigInt!
+        volume: BigInt!
+    }
+
+    # Order is a TomoX spot order.
+    type Order {
+        id: Long!
+        hash: Bytes32!
+        txHash: Bytes32!
+        userAddress: Address!
+        exchangeAddress: Address!
+        baseToken: Address!
+        quoteToken: Address!
+        side: String!
+        type: String!
+        status: String!
+        price: BigInt
+        quantity: BigInt
+        filledAmount: BigInt
+        nonce: BigInt
+    }
+
+    # Trade is a TomoX spot trade, served by SDK nodes only.
+    type Trade {
+        hash: Bytes32!
+        txHash: Bytes32!
+        taker: Address!
+        maker: Address!
+        baseToken: Address!
+        quoteToken: Address!
+        makerOrderHash: Bytes32!
+        takerOrderHash: Bytes32!
+        makerExchange: Address!
+        takerExchange: Address!
+        price: BigInt
+        amount: BigInt
+        makeFee: BigInt
+        takeFee: BigInt
+        status: String!
+        takerOrderSide: String!
+        takerOrderType: String!
+        makerOrderType: String!
+    }
+
+    # OrderBook is a TomoX spot order book at a particular block.
+    type OrderBook {
+        baseToken: Address!
+        quoteToken: Address!
+        # Block is the block whose trading state the order book is read from.
+        block: Block!
+        # LastPrice is the price of the last trade.
+        lastPrice: BigInt
+        # MediumPrice is the average trade price of the current epoch.
+        mediumPrice: BigInt
+        # MediumPriceBeforeEpoch is the average trade price of the previous epoch.
+        mediumPriceBeforeEpoch: BigInt
+        bestBid: PriceVolume
+        bestAsk: PriceVolume
+        bids: [PriceVolume!]!
+        asks: [PriceVolume!]!
+        # Order returns an open order of the book by its id.
+        order(id: Long!): Order
+    }
+
+    # LendingOrder is a TomoX lending order.
+    type LendingOrder {
+        id: Long!
+        hash: Bytes32!
+        txHash: Bytes32!
+        userAddress: Address!
+        relayer: Address!
+        lendingToken: Address!
+        collateralToken: Address!
+        term: Long!
+        interest: BigInt
+        side: String!
+        type: String!
+        status: String!
+        quantity: BigInt
+        filledAmount: BigInt
+        autoTopUp: Boolean!
+        nonce: BigInt
+        tradeId: Long!
+    }
+
+    # LendingTrade is a TomoX lending trade.
+    type LendingTrade {
+        id: Long!
+        hash: Bytes32!
+        txHash: Bytes32!
+        borrower: Address!
+        investor: Address!
+        lendingToken: Address!
+        collateralToken: Address!
+        borrowingOrderHash: Bytes32!
+        investingOrderHash: Bytes32!
+        borrowingRelayer: Address!
+        investingRelayer: Address!
+        term: Long!
+        interest: Long!
+        collateralPrice: BigInt
+        liquidationPrice: BigInt
+        collateralLockedAmount: BigInt
+        autoTopUp: Boolean!
+        liquidationTime: Long!
+        amount: BigInt
+        borrowingFee: BigInt
+        investingFee: BigInt
+        status: String!
+    }
+
+    # LendingBook is a TomoX lending book at a particular block.
+    type LendingBook {
+        lendingToken: Address!
+        term: Long!
+        # Block is the block whose lending state the book is read from.
+        block: Block!
+        bestInvesting: InterestVolume
+        bestBorrowing: InterestVolume
+        investing: [InterestVolume!]!
+        borrowing: [InterestVolume!]!
+        # Order returns an open lending order of the book by its id.
+        order(id: Long!): LendingOrder
+        # Trade returns an open lending trade of the book by its id.
+        trade(id: Long!): LendingTrade
+    }
+
+    type Query {
+        # Block fetches an Ethereum block by number or by hash. If neither is
+        # supplied, the most recent known block is returned.
+        block(number: Long, hash: Bytes32): Block
+        # Blocks returns all the blocks between two numbers, inclusive. If
+        # to is not supplied, it defaults to the most recent known block.
+        blocks(from: Long!, to: Long): [Block!]!
+        # Transaction returns a transaction specified by its hash.
+        transaction(hash: Bytes32!): Transaction
+        # Logs returns log entries matching the provided filter.
+        logs(filter: FilterCriteria!): [Log!]!
+        # GasPrice returns the node's estimate of a gas price sufficient to
+        # ensure a transaction is mined in a timely fashion.
+        gasPrice: BigInt!
+        # ProtocolVersion returns the current wire protocol version number.
+        protocolVersion: Int!
+        # OrderBook returns a TomoX spot order book. If block is not supplied,
+        # the most recent known block is used.
+        orderBook(baseToken: Address!, quoteToken: Address!, block: Long): OrderBook
+        # LendingBook returns a TomoX lending book. If block is not supplied,
+        # the most recent known block is used.
+        lendingBook(lendingToken: Address!, term: Long!, block: Long): LendingBook
+        # Trades returns the spot trades produced by a transaction.
+        trades(txHash: Bytes32!): [Trade!]!
+        # LendingTrades returns the lending trades produced by a transaction.
+        lendingTrades(txHash: Bytes32!): [LendingTrade!]!
+    }
+
+    type Mutation {
+        # SendRawTransaction sends an RLP-encoded transaction to the network.
+        sendRawTransaction(data: Bytes!): Bytes32!
+    }
+`
@@ -0,0 +1,57 @@
+package graphql
+
+import (
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/tomochain/tomochain/p2p"
+	"github.com/tomochain/tomochain/rpc"
+)
+
+// Service encapsulates a GraphQL service, it is served by the node's HTTP
+// server next to JSON-RPC.
+type Service struct {
+	handler http.Handler // Handler serving GraphQL queries
+}
+
+// New constructs a new GraphQL service on top of the given chain backend.
+func New(backend Backend) (*Service, error) {
+	handler, err := newHandler(backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{handler: handler}, nil
+}
+
+// newHandler parses the schema, binds the resolvers and returns the HTTP
+// handler executing the queries.
+func newHandler(backend Backend) (http.Handler, error) {
+	s, err := graphql.ParseSchema(schema, &Resolver{backend})
+	if err != nil {
+		return nil, err
+	}
+	return &relay.Handler{Schema: s}, nil
+}
+
+// Protocols returns the list of protocols exported by this service.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs returns the list of APIs exported by this service.
+func (s *Service) APIs() []rpc.API { return nil }
+
+// HTTPHandlers returns the HTTP handlers mounted on the node's HTTP server.
+func (s *Service) HTTPHandlers() map[string]http.Handler {
+	return map[string]http.Handler{"/graphql": s.handler}
+}
+
+// Start is called after all services have been constructed and the networking
+// layer was also initialized to spawn any goroutines required by the service.
+func (s *Service) Start(server *p2p.Server) error { return nil }
+
+// SaveData is a no-op, the service holds no state of its own.
+func (s *Service) SaveData() {}
+
+// Stop terminates all goroutines belonging to the service, blocking until they
+// are all terminated.
+func (s *Service) Stop() error { return nil }
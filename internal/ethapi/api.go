@@ -413,7 +413,7 @@ func (s *PrivateAccountAPI) SignTransaction(ctx context.Context, args SendTxArgs
 	if err != nil {
 		return nil, err
 	}
-	data, err := rlp.EncodeToBytes(signed)
+	data, err := signed.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -1024,24 +1024,165 @@ type CallArgs struct {
 	GasPrice hexutil.Big     `json:"gasPrice"`
 	Value    hexutil.Big     `json:"value"`
 	Data     hexutil.Bytes   `json:"data"`
+
+	AccessList *types.AccessList `json:"accessList"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
-	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+// accessList returns the access list of the call, if any.
+func (args *CallArgs) accessList() types.AccessList {
+	if args.AccessList == nil {
+		return nil
+	}
+	return *args.AccessList
+}
+
+// OverrideAccount indicates the overriding fields of account during the execution
+// of a message call.
+// Note, state and stateDiff can't be specified at the same time. If state is
+// set, message execution will only use the data in the given state. Otherwise
+// if statDiff is set, all diff will be applied first and then execute the call
+// message.
+type OverrideAccount struct {
+	Nonce     *hexutil.Uint64              `json:"nonce"`
+	Code      *hexutil.Bytes               `json:"code"`
+	Balance   **hexutil.Big                `json:"balance"`
+	State     *map[common.Hash]common.Hash `json:"state"`
+	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
+}
+
+// StateOverride is the collection of overridden accounts.
+type StateOverride map[common.Address]OverrideAccount
+
+// Apply overrides the fields of specified accounts into the given state.
+func (diff *StateOverride) Apply(state *state.StateDB) error {
+	if diff == nil {
+		return nil
+	}
+	for addr, account := range *diff {
+		// Override account nonce.
+		if account.Nonce != nil {
+			state.SetNonce(addr, uint64(*account.Nonce))
+		}
+		// Override account(contract) code.
+		if account.Code != nil {
+			state.SetCode(addr, *account.Code)
+		}
+		// Override account balance.
+		if account.Balance != nil {
+			state.SetBalance(addr, (*big.Int)(*account.Balance))
+		}
+		if account.State != nil && account.StateDiff != nil {
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+		}
+		// Replace entire state if caller requires.
+		if account.State != nil {
+			state.SetStorage(addr, *account.State)
+		}
+		// Apply state diff into specified accounts.
+		if account.StateDiff != nil {
+			for key, value := range *account.StateDiff {
+				state.SetState(addr, key, value)
+			}
+		}
+	}
+	return state.Error()
+}
+
+// hasBalance reports whether the balance of the given account is overridden.
+func (diff *StateOverride) hasBalance(addr common.Address) bool {
+	if diff == nil {
+		return false
+	}
+	account, ok := (*diff)[addr]
+	return ok && account.Balance != nil
+}
+
+// BlockOverrides is a set of header fields to override when executing calls.
+type BlockOverrides struct {
+	Number     *hexutil.Big    `json:"number"`
+	Time       *hexutil.Big    `json:"time"`
+	GasLimit   *hexutil.Uint64 `json:"gasLimit"`
+	Coinbase   *common.Address `json:"coinbase"`
+	Difficulty *hexutil.Big    `json:"difficulty"`
+}
+
+// Apply returns a copy of the header with the overridden fields replaced.
+func (o *BlockOverrides) Apply(header *types.Header) *types.Header {
+	if o == nil {
+		return header
+	}
+	header = types.CopyHeader(header)
+	if o.Number != nil {
+		header.Number = new(big.Int).Set(o.Number.ToInt())
+	}
+	if o.Time != nil {
+		header.Time = new(big.Int).Set(o.Time.ToInt())
+	}
+	if o.GasLimit != nil {
+		header.GasLimit = uint64(*o.GasLimit)
+	}
+	if o.Difficulty != nil {
+		header.Difficulty = new(big.Int).Set(o.Difficulty.ToInt())
+	}
+	return header
+}
 
-	statedb, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+// callState retrieves the states and the header a call at the given block is
+// executed on.
+func callState(ctx context.Context, b Backend, blockNr rpc.BlockNumber) (*state.StateDB, *tradingstate.TradingStateDB, *types.Header, error) {
+	statedb, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
 	if statedb == nil || err != nil {
-		return nil, 0, false, err
+		return nil, nil, nil, err
 	}
-	// Set sender address or use a default if none specified
+	block, err := b.BlockByNumber(ctx, blockNr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	author, err := b.GetEngine().Author(block.Header())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tomoxState, err := b.TomoxService().GetTradingState(block, author)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return statedb, tomoxState, header, nil
+}
+
+// callSender returns the sender of a call, the first account of the node if none
+// is specified.
+func callSender(b Backend, args CallArgs) common.Address {
 	addr := args.From
 	if addr == (common.Address{}) {
-		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
+		if wallets := b.AccountManager().Wallets(); len(wallets) > 0 {
 			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
 				addr = accounts[0].Address
 			}
 		}
 	}
+	return addr
+}
+
+// callResult is the outcome of a call executed on top of a state.
+type callResult struct {
+	ReturnData []byte // Data returned by the call, the revert data if it reverted
+	UsedGas    uint64 // Gas used by the call
+	Err        error  // Error the EVM execution failed with, if any
+}
+
+// Failed returns whether the EVM execution of the call failed.
+func (r *callResult) Failed() bool { return r.Err != nil }
+
+// applyCall executes a call message on top of the given states. The states are
+// modified by the call, so callers must pass copies if they need the originals.
+// A failed EVM execution is reported in the result, the returned error is only
+// set if the call could not be executed at all.
+func applyCall(ctx context.Context, b Backend, args CallArgs, statedb *state.StateDB, tomoxState *tradingstate.TradingStateDB, header *types.Header, overrides *StateOverride, blockOverrides *BlockOverrides, vmCfg vm.Config, timeout time.Duration) (*callResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+
+	// Set sender address or use a default if none specified
+	addr := callSender(b, args)
+
 	// Set default gas & gas price if none were set
 	gas, gasPrice := uint64(args.Gas), args.GasPrice.ToInt()
 	if gas == 0 {
@@ -1053,7 +1194,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	balanceTokenFee := big.NewInt(0).SetUint64(gas)
 	balanceTokenFee = balanceTokenFee.Mul(balanceTokenFee, gasPrice)
 	// Create new call message
-	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false, balanceTokenFee)
+	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, args.accessList(), false, balanceTokenFee)
 
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
@@ -1067,22 +1208,20 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// this makes sure resources are cleaned up.
 	defer cancel()
 
-	block, err := s.b.BlockByNumber(ctx, blockNr)
-	if err != nil {
-		return nil, 0, false, err
-	}
-	author, err := s.b.GetEngine().Author(block.Header())
+	// The backend funds the sender for the call, keep an overridden balance intact
+	balance := new(big.Int).Set(statedb.GetBalance(addr))
+
+	// Get a new instance of the EVM.
+	evm, vmError, err := b.GetEVM(ctx, msg, statedb, tomoxState, blockOverrides.Apply(header), vmCfg)
 	if err != nil {
-		return nil, 0, false, err
+		return nil, err
 	}
-	tomoxState, err := s.b.TomoxService().GetTradingState(block, author)
-	if err != nil {
-		return nil, 0, false, err
+	if overrides.hasBalance(addr) {
+		statedb.SetBalance(addr, balance)
 	}
-	// Get a new instance of the EVM.
-	evm, vmError, err := s.b.GetEVM(ctx, msg, statedb, tomoxState, header, vmCfg)
-	if err != nil {
-		return nil, 0, false, err
+	funded := new(big.Int).Set(statedb.GetBalance(addr))
+	if blockOverrides != nil && blockOverrides.Coinbase != nil {
+		evm.Coinbase = *blockOverrides.Coinbase
 	}
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
@@ -1095,23 +1234,58 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// and apply the message.
 	gp := new(core.GasPool).AddGas(math.MaxUint64)
 	owner := common.Address{}
-	res, gas, failed, err := core.ApplyMessage(evm, msg, gp, owner)
+	st := core.NewStateTransition(evm, msg, gp)
+	res, gas, _, err := st.TransitionDb(owner)
+
+	// Take the funds back so that they don't leak into later calls on the same
+	// state, only the balance change of the call itself is kept
+	spent := new(big.Int).Sub(funded, statedb.GetBalance(addr))
+	if balance.Cmp(spent) < 0 {
+		statedb.SetBalance(addr, new(big.Int))
+	} else {
+		statedb.SetBalance(addr, balance.Sub(balance, spent))
+	}
 	if err := vmError(); err != nil {
-		return nil, 0, false, err
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &callResult{ReturnData: res, UsedGas: gas, Err: st.VMError()}, nil
+}
+
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride, vmCfg vm.Config, timeout time.Duration) (*callResult, error) {
+	statedb, tomoxState, header, err := callState(ctx, s.b, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	if statedb == nil {
+		return &callResult{}, nil
 	}
-	return res, gas, failed, err
+	if err := overrides.Apply(statedb); err != nil {
+		return nil, err
+	}
+	return applyCall(ctx, s.b, args, statedb, tomoxState, header, overrides, nil, vmCfg, timeout)
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
-	return (hexutil.Bytes)(result), err
+//
+// Additionally, the caller can specify a batch of contract for fields overriding.
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride) (hexutil.Bytes, error) {
+	result, err := s.doCall(ctx, args, blockNr, overrides, vm.Config{}, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if result.Failed() {
+		return nil, newCallError(result.ReturnData, result.Err)
+	}
+	return (hexutil.Bytes)(result.ReturnData), nil
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
 // given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (hexutil.Uint64, error) {
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, overrides *StateOverride) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
 		lo  uint64 = params.TxGas - 1
@@ -1131,11 +1305,17 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	cap = hi
 
 	// Create a helper to check if a gas allowance results in an executable transaction
+	var failure *callResult
 	executable := func(gas uint64) bool {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.LatestBlockNumber, vm.Config{}, 0)
-		if err != nil || failed {
+		result, err := s.doCall(ctx, args, rpc.LatestBlockNumber, overrides, vm.Config{}, 0)
+		if err != nil {
+			failure = nil
+			return false
+		}
+		if result.Failed() {
+			failure = result
 			return false
 		}
 		return true
@@ -1152,12 +1332,192 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
 		if !executable(hi) {
+			if failure != nil && failure.Err == vm.ErrExecutionReverted && len(failure.ReturnData) > 0 {
+				return 0, newRevertError(failure.ReturnData)
+			}
 			return 0, fmt.Errorf("gas required exceeds allowance or always failing transaction")
 		}
 	}
 	return hexutil.Uint64(hi), nil
 }
 
+// AccessListResult is the result of eth_createAccessList. Error holds the
+// reason the transaction failed with the returned access list, if it did.
+type AccessListResult struct {
+	AccessList *types.AccessList `json:"accessList"`
+	Error      string            `json:"error,omitempty"`
+	GasUsed    hexutil.Uint64    `json:"gasUsed"`
+}
+
+// CreateAccessList creates an EIP-2930 access list for the given transaction,
+// executed on the state of the given block, the latest one if none is given.
+// The returned gas used accounts for the access list.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args CallArgs, blockNr *rpc.BlockNumber) (*AccessListResult, error) {
+	number := rpc.LatestBlockNumber
+	if blockNr != nil {
+		number = *blockNr
+	}
+	acl, res, err := createAccessList(ctx, s.b, number, args)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccessListResult{AccessList: &acl, GasUsed: hexutil.Uint64(res.UsedGas)}
+	if res.Failed() {
+		result.Error = res.Err.Error()
+	}
+	return result, nil
+}
+
+// createAccessList runs the call repeatedly, each time with the access list
+// collected by the previous run, until the list no longer changes. The
+// sender, the recipient and the precompiles are warm anyway and are left out.
+// The result of the last run is returned along with the list.
+func createAccessList(ctx context.Context, b Backend, blockNr rpc.BlockNumber, args CallArgs) (types.AccessList, *callResult, error) {
+	statedb, tomoxState, header, err := callState(ctx, b, blockNr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if statedb == nil {
+		return nil, &callResult{}, nil
+	}
+	from := callSender(b, args)
+	args.From = from
+
+	var to common.Address
+	if args.To != nil {
+		to = *args.To
+	} else {
+		to = crypto.CreateAddress(from, statedb.GetNonce(from))
+	}
+	precompiles := vm.ActivePrecompiles(b.ChainConfig().Rules(header.Number))
+
+	prevTracer := vm.NewAccessListTracer(args.accessList(), from, to, precompiles)
+	for {
+		accessList := prevTracer.AccessList()
+		args.AccessList = &accessList
+
+		// Every run starts from the original states
+		var tradingState *tradingstate.TradingStateDB
+		if tomoxState != nil {
+			tradingState = tomoxState.Copy()
+		}
+		tracer := vm.NewAccessListTracer(accessList, from, to, precompiles)
+		config := vm.Config{Debug: true, Tracer: tracer}
+		result, err := applyCall(ctx, b, args, statedb.Copy(), tradingState, header, nil, nil, config, 5*time.Second)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to apply transaction: %v", err)
+		}
+		if tracer.Equal(prevTracer) {
+			return accessList, result, nil
+		}
+		prevTracer = tracer
+	}
+}
+
+// maxMulticallCalls is the maximum number of calls a single multicall may run.
+const maxMulticallCalls = 256
+
+// PublicTomoAPI provides Tomochain specific APIs that are publicly available.
+type PublicTomoAPI struct {
+	b Backend
+}
+
+// NewPublicTomoAPI creates a new Tomochain specific API.
+func NewPublicTomoAPI(b Backend) *PublicTomoAPI {
+	return &PublicTomoAPI{b}
+}
+
+// MulticallResult is the outcome of a single call of a multicall. If the call
+// failed, ReturnData holds the revert data of the call, if any.
+type MulticallResult struct {
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Failed     bool           `json:"failed"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Multicall executes an ordered list of calls on a shared state at the given
+// block. Each call sees the state changes of the calls before it, nothing is
+// persisted. The state and the block header the calls run on can optionally be
+// overridden.
+func (s *PublicTomoAPI) Multicall(ctx context.Context, calls []CallArgs, blockNr rpc.BlockNumber, overrides *StateOverride, blockOverrides *BlockOverrides) ([]MulticallResult, error) {
+	if len(calls) == 0 {
+		return nil, errors.New("no calls specified")
+	}
+	if len(calls) > maxMulticallCalls {
+		return nil, fmt.Errorf("too many calls: have %d, max %d", len(calls), maxMulticallCalls)
+	}
+	statedb, tomoxState, header, err := callState(ctx, s.b, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(statedb); err != nil {
+		return nil, err
+	}
+	// The whole batch shares the timeout of a single eth_call
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	results := make([]MulticallResult, 0, len(calls))
+	for i, args := range calls {
+		res, err := applyCall(ctx, s.b, args, statedb, tomoxState, header, overrides, blockOverrides, vm.Config{}, 0)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("execution aborted at call %d (timeout = 5s)", i)
+		}
+		var result MulticallResult
+		if err != nil {
+			result.Failed, result.Error = true, err.Error()
+		} else {
+			result.ReturnData, result.GasUsed = res.ReturnData, hexutil.Uint64(res.UsedGas)
+			if res.Failed() {
+				result.Failed, result.Error = true, newCallError(res.ReturnData, res.Err).Error()
+			}
+		}
+		results = append(results, result)
+		statedb.Finalise(true)
+	}
+	return results, nil
+}
+
+// PrivateTxArgs configures the private submission of a transaction.
+type PrivateTxArgs struct {
+	// MaxBlockNumber is the last block the transaction is kept private for,
+	// defaulting to a few blocks after the current head.
+	MaxBlockNumber *hexutil.Uint64 `json:"maxBlockNumber"`
+	// Fallback makes the transaction gossiped publicly once MaxBlockNumber is
+	// reached without inclusion, it is dropped otherwise.
+	Fallback bool `json:"fallback"`
+}
+
+// expiry returns the private transaction expiry block, 0 selecting the default.
+func (args *PrivateTxArgs) expiry() (uint64, bool) {
+	if args == nil {
+		return 0, false
+	}
+	if args.MaxBlockNumber == nil {
+		return 0, args.Fallback
+	}
+	return uint64(*args.MaxBlockNumber), args.Fallback
+}
+
+// SendPrivateTransaction hands a signed transaction to the masternodes expected
+// to produce the upcoming blocks instead of gossiping it to the network.
+func (s *PublicTomoAPI) SendPrivateTransaction(ctx context.Context, encodedTx hexutil.Bytes, args *PrivateTxArgs) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(encodedTx); err != nil {
+		return common.Hash{}, err
+	}
+	if tx.To() != nil && tx.IsSpecialTransaction() {
+		return common.Hash{}, errors.New("Dont allow transaction sent to BlockSigners & RandomizeSMC smart contract via API")
+	}
+	expiry, fallback := args.expiry()
+	if err := s.b.SendPrivateTx(ctx, tx, expiry, fallback); err != nil {
+		return common.Hash{}, err
+	}
+	log.Trace("Submitted private transaction", "fullhash", tx.Hash().Hex(), "expiry", expiry, "fallback", fallback)
+	return tx.Hash(), nil
+}
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
 // execution status, the amount of gas used and the return value
@@ -1443,19 +1803,38 @@ type RPCTransaction struct {
 	V                *hexutil.Big    `json:"v"`
 	R                *hexutil.Big    `json:"r"`
 	S                *hexutil.Big    `json:"s"`
+
+	Type       hexutil.Uint64    `json:"type"`
+	ChainID    *hexutil.Big      `json:"chainId,omitempty"`
+	AccessList *types.AccessList `json:"accessList,omitempty"`
+
+	FeePayer *common.Address `json:"feePayer,omitempty"`
+	PayerGas *hexutil.Uint64 `json:"payerGas,omitempty"`
+}
+
+// txSigner returns a signer able to recover the sender of tx regardless of the
+// block the transaction is included in.
+func txSigner(tx *types.Transaction) types.Signer {
+	switch {
+	case tx.Type() == types.SponsoredTxType:
+		return types.NewTIPSponsoredSigner(tx.ChainId())
+	case tx.Type() != types.LegacyTxType:
+		return types.NewTIPAccessListSigner(tx.ChainId())
+	case tx.Protected():
+		return types.NewEIP155Signer(tx.ChainId())
+	default:
+		return types.FrontierSigner{}
+	}
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
 // representation, with the given location metadata set (if available).
 func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64) *RPCTransaction {
-	var signer types.Signer = types.FrontierSigner{}
-	if tx.Protected() {
-		signer = types.NewEIP155Signer(tx.ChainId())
-	}
-	from, _ := types.Sender(signer, tx)
+	from, _ := types.Sender(txSigner(tx), tx)
 	v, r, s := tx.RawSignatureValues()
 
 	result := &RPCTransaction{
+		Type:     hexutil.Uint64(tx.Type()),
 		From:     from,
 		Gas:      hexutil.Uint64(tx.Gas()),
 		GasPrice: (*hexutil.Big)(tx.GasPrice()),
@@ -1473,6 +1852,21 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		result.BlockNumber = (*hexutil.Big)(new(big.Int).SetUint64(blockNumber))
 		result.TransactionIndex = hexutil.Uint(index)
 	}
+	if tx.Type() != types.LegacyTxType {
+		al := tx.AccessList()
+		if al == nil {
+			al = types.AccessList{}
+		}
+		result.ChainID = (*hexutil.Big)(tx.ChainId())
+		result.AccessList = &al
+	}
+	if tx.Type() == types.SponsoredTxType {
+		payerGas := hexutil.Uint64(tx.PayerGas())
+		if payer, err := types.Payer(txSigner(tx), tx); err == nil {
+			result.FeePayer = &payer
+		}
+		result.PayerGas = &payerGas
+	}
 	return result
 }
 
@@ -1496,7 +1890,7 @@ func newRPCRawTransactionFromBlockIndex(b *types.Block, index uint64) hexutil.By
 	if index >= uint64(len(txs)) {
 		return nil
 	}
-	blob, _ := rlp.EncodeToBytes(txs[index])
+	blob, _ := txs[index].MarshalBinary()
 	return blob
 }
 
@@ -1617,8 +2011,8 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 			return nil, nil
 		}
 	}
-	// Serialize to RLP and return
-	return rlp.EncodeToBytes(tx)
+	// Serialize to the canonical encoding and return
+	return tx.MarshalBinary()
 }
 
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
@@ -1636,13 +2030,10 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	}
 	receipt := receipts[index]
 
-	var signer types.Signer = types.FrontierSigner{}
-	if tx.Protected() {
-		signer = types.NewEIP155Signer(tx.ChainId())
-	}
-	from, _ := types.Sender(signer, tx)
+	from, _ := types.Sender(txSigner(tx), tx)
 
 	fields := map[string]interface{}{
+		"type":              hexutil.Uint(tx.Type()),
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
 		"transactionHash":   hash,
@@ -1669,6 +2060,21 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// Sponsored transactions report the account which paid their fee
+	if tx.Type() == types.SponsoredTxType {
+		if payer, err := types.Payer(txSigner(tx), tx); err == nil {
+			fields["feePayer"] = payer
+		}
+	}
+	// Add the failure reason if the node executed the failed transaction
+	if receipt.Status == types.ReceiptStatusFailed {
+		if failure := readTxFailure(s.b.ChainDb(), hash, blockHash, blockNumber); failure != nil {
+			fields["failureCode"] = failure.Code
+			if failure.Reason != "" {
+				fields["revertReason"] = failure.Reason
+			}
+		}
+	}
 	return fields, nil
 }
 
@@ -1701,6 +2107,10 @@ type SendTxArgs struct {
 	// newer name and should be preferred by clients.
 	Data  *hexutil.Bytes `json:"data"`
 	Input *hexutil.Bytes `json:"input"`
+
+	// Setting an access list makes an EIP-2930 access list transaction.
+	AccessList *types.AccessList `json:"accessList"`
+	ChainID    *hexutil.Big      `json:"chainId"`
 }
 
 // setDefaults is a helper function that fills in default values for unspecified tx fields.
@@ -1741,6 +2151,14 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 			return errors.New(`contract creation without any data provided`)
 		}
 	}
+	if args.AccessList != nil {
+		id := b.ChainConfig().ChainId
+		if args.ChainID == nil {
+			args.ChainID = (*hexutil.Big)(id)
+		} else if have := (*big.Int)(args.ChainID); have.Cmp(id) != 0 {
+			return fmt.Errorf("chainId does not match node's (have=%v, want=%v)", have, id)
+		}
+	}
 	return nil
 }
 
@@ -1751,6 +2169,9 @@ func (args *SendTxArgs) toTransaction() *types.Transaction {
 	} else if args.Input != nil {
 		input = *args.Input
 	}
+	if args.AccessList != nil {
+		return types.NewAccessListTransaction((*big.Int)(args.ChainID), uint64(*args.Nonce), args.To, (*big.Int)(args.Value), uint64(*args.Gas), (*big.Int)(args.GasPrice), input, *args.AccessList)
+	}
 	if args.To == nil {
 		return types.NewContractCreation(uint64(*args.Nonce), (*big.Int)(args.Value), uint64(*args.Gas), (*big.Int)(args.GasPrice), input)
 	}
@@ -1838,7 +2259,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
 	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+	if err := tx.UnmarshalBinary(encodedTx); err != nil {
 		return common.Hash{}, err
 	}
 	return submitTransaction(ctx, s.b, tx)
@@ -1975,6 +2396,8 @@ type OrderMsg struct {
 	Side            string         `json:"side,omitempty"`
 	Type            string         `json:"type,omitempty"`
 	OrderID         hexutil.Uint64 `json:"orderid,omitempty"`
+	// Optional self-trade prevention mode: CN, CO, CB or DC
+	SelfTradePrevention string `json:"selfTradePrevention,omitempty"`
 	// Signature values
 	V hexutil.Big `json:"v" gencodec:"required"`
 	R hexutil.Big `json:"r" gencodec:"required"`
@@ -2001,6 +2424,8 @@ type LendingMsg struct {
 	LendingId       hexutil.Uint64 `json:"lendingId,omitempty"`
 	LendingTradeId  hexutil.Uint64 `json:"tradeId,omitempty"`
 	ExtraData       string         `json:"extraData,omitempty"`
+	// Optional self-trade prevention mode of a new lending item: CN, CO, CB or DC
+	SelfTradePrevention string `json:"selfTradePrevention,omitempty"`
 
 	// Signature values
 	V hexutil.Big `json:"v" gencodec:"required"`
@@ -2025,6 +2450,7 @@ type InterestVolume struct {
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTomoXTransactionPoolAPI) SendOrder(ctx context.Context, msg OrderMsg) (common.Hash, error) {
 	tx := types.NewOrderTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), msg.Price.ToInt(), msg.ExchangeAddress, msg.UserAddress, msg.BaseToken, msg.QuoteToken, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.OrderID))
+	tx.SetSelfTradePrevention(msg.SelfTradePrevention)
 	tx = tx.ImportSignature(msg.V.ToInt(), msg.R.ToInt(), msg.S.ToInt())
 	return submitOrderTransaction(ctx, s.b, tx)
 }
@@ -2033,10 +2459,39 @@ func (s *PublicTomoXTransactionPoolAPI) SendOrder(ctx context.Context, msg Order
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTomoXTransactionPoolAPI) SendLending(ctx context.Context, msg LendingMsg) (common.Hash, error) {
 	tx := types.NewLendingTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), uint64(msg.Interest), uint64(msg.Term), msg.RelayerAddress, msg.UserAddress, msg.LendingToken, msg.CollateralToken, msg.AutoTopUp, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.LendingId), uint64(msg.LendingTradeId), msg.ExtraData)
+	tx.SetSelfTradePrevention(msg.SelfTradePrevention)
 	tx = tx.ImportSignature(msg.V.ToInt(), msg.R.ToInt(), msg.S.ToInt())
 	return submitLendingTransaction(ctx, s.b, tx)
 }
 
+// SendPrivateOrder hands a signed order transaction to the masternodes expected
+// to produce the upcoming blocks instead of gossiping it to the network.
+func (s *PublicTomoXTransactionPoolAPI) SendPrivateOrder(ctx context.Context, msg OrderMsg, args *PrivateTxArgs) (common.Hash, error) {
+	tx := types.NewOrderTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), msg.Price.ToInt(), msg.ExchangeAddress, msg.UserAddress, msg.BaseToken, msg.QuoteToken, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.OrderID))
+	tx.SetSelfTradePrevention(msg.SelfTradePrevention)
+	tx = tx.ImportSignature(msg.V.ToInt(), msg.R.ToInt(), msg.S.ToInt())
+
+	expiry, fallback := args.expiry()
+	if err := s.b.SendPrivateOrderTx(ctx, tx, expiry, fallback); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+// SendPrivateLending hands a signed lending transaction to the masternodes
+// expected to produce the upcoming blocks instead of gossiping it to the network.
+func (s *PublicTomoXTransactionPoolAPI) SendPrivateLending(ctx context.Context, msg LendingMsg, args *PrivateTxArgs) (common.Hash, error) {
+	tx := types.NewLendingTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), uint64(msg.Interest), uint64(msg.Term), msg.RelayerAddress, msg.UserAddress, msg.LendingToken, msg.CollateralToken, msg.AutoTopUp, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.LendingId), uint64(msg.LendingTradeId), msg.ExtraData)
+	tx.SetSelfTradePrevention(msg.SelfTradePrevention)
+	tx = tx.ImportSignature(msg.V.ToInt(), msg.R.ToInt(), msg.S.ToInt())
+
+	expiry, fallback := args.expiry()
+	if err := s.b.SendPrivateLendingTx(ctx, tx, expiry, fallback); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
 // GetOrderCount returns the number of transactions the given address has sent for the given block number
 func (s *PublicTomoXTransactionPoolAPI) GetOrderCount(ctx context.Context, addr common.Address) (*hexutil.Uint64, error) {
 
@@ -2734,7 +3189,7 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 	if err != nil {
 		return nil, err
 	}
-	data, err := rlp.EncodeToBytes(tx)
+	data, err := tx.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -2832,6 +3287,17 @@ func (api *PublicDebugAPI) GetBlockRlp(ctx context.Context, number uint64) (stri
 	return fmt.Sprintf("%x", encoded), nil
 }
 
+// GetTransactionFailure returns why a transaction included in the chain failed,
+// along with its decoded revert reason. It returns nil if the transaction
+// succeeded or the failure is not known to this node.
+func (api *PublicDebugAPI) GetTransactionFailure(ctx context.Context, hash common.Hash) (*TxFailureResult, error) {
+	tx, blockHash, blockNumber, _ := rawdb.GetTransaction(api.b.ChainDb(), hash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %#x not found", hash)
+	}
+	return readTxFailure(api.b.ChainDb(), hash, blockHash, blockNumber), nil
+}
+
 // PrintBlock retrieves a block and returns its pretty printed form.
 func (api *PublicDebugAPI) PrintBlock(ctx context.Context, number uint64) (string, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
@@ -0,0 +1,189 @@
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/common/math"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/consensus/ethash"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/core/vm"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// testBackend executes calls on the genesis state of a local chain, the methods
+// not needed by the calls are left to the nil embedded interface.
+type testBackend struct {
+	Backend
+
+	chain *core.BlockChain
+	tomox *tomox.TomoX
+}
+
+func newTestBackend(t *testing.T, alloc core.GenesisAlloc) *testBackend {
+	db := rawdb.NewMemoryDatabase()
+	gspec := &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	return &testBackend{
+		chain: chain,
+		tomox: &tomox.TomoX{StateCache: tradingstate.NewDatabase(rawdb.NewMemoryDatabase())},
+	}
+}
+
+func (b *testBackend) GetEngine() consensus.Engine { return b.chain.Engine() }
+func (b *testBackend) TomoxService() *tomox.TomoX  { return b.tomox }
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	block, _ := b.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, nil, nil
+	}
+	statedb, err := b.chain.StateAt(block.Root())
+	return statedb, block.Header(), err
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, tomoxState *tradingstate.TradingStateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256)
+	context := core.NewEVMContext(msg, header, b.chain, nil)
+	return vm.NewEVM(context, state, tomoxState, b.chain.Config(), vmCfg), state.Error, nil
+}
+
+func TestStateOverrideApply(t *testing.T) {
+	var (
+		addr    = common.HexToAddress("0x0000000000000000000000000000000000000a01")
+		nonce   = hexutil.Uint64(7)
+		code    = hexutil.Bytes{0x60, 0x00}
+		balance = (*hexutil.Big)(big.NewInt(12345))
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.SetState(addr, common.Hash{1}, common.Hash{1})
+	statedb.SetState(addr, common.Hash{2}, common.Hash{2})
+
+	diff := map[common.Hash]common.Hash{{2}: {0x22}, {3}: {0x33}}
+	overrides := &StateOverride{addr: {Nonce: &nonce, Code: &code, Balance: &balance, StateDiff: &diff}}
+	if err := overrides.Apply(statedb); err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+	if have := statedb.GetNonce(addr); have != 7 {
+		t.Errorf("nonce mismatch: have %d, want 7", have)
+	}
+	if have := statedb.GetCode(addr); !bytes.Equal(have, code) {
+		t.Errorf("code mismatch: have %x, want %x", have, code)
+	}
+	if have := statedb.GetBalance(addr); have.Cmp(big.NewInt(12345)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 12345", have)
+	}
+	// A state diff only touches the given slots
+	for key, want := range map[common.Hash]common.Hash{{1}: {1}, {2}: {0x22}, {3}: {0x33}} {
+		if have := statedb.GetState(addr, key); have != want {
+			t.Errorf("slot %x mismatch after diff: have %x, want %x", key, have, want)
+		}
+	}
+	// A full state replaces the whole storage
+	storage := map[common.Hash]common.Hash{{4}: {0x44}}
+	overrides = &StateOverride{addr: {State: &storage}}
+	if err := overrides.Apply(statedb); err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+	for key, want := range map[common.Hash]common.Hash{{1}: {}, {2}: {}, {3}: {}, {4}: {0x44}} {
+		if have := statedb.GetState(addr, key); have != want {
+			t.Errorf("slot %x mismatch after replacement: have %x, want %x", key, have, want)
+		}
+	}
+	overrides = &StateOverride{addr: {State: &storage, StateDiff: &diff}}
+	if err := overrides.Apply(statedb); err == nil {
+		t.Errorf("applied both state and state diff")
+	}
+	if err := (*StateOverride)(nil).Apply(statedb); err != nil {
+		t.Errorf("failed to apply nil overrides: %v", err)
+	}
+}
+
+func TestMulticall(t *testing.T) {
+	var (
+		funds    = big.NewInt(1000000000000000000)
+		caller   = common.HexToAddress("0x0000000000000000000000000000000000000a01")
+		sender   = common.HexToAddress("0x0000000000000000000000000000000000000a02")
+		receiver = common.HexToAddress("0x0000000000000000000000000000000000000a03")
+		balances = common.HexToAddress("0x0000000000000000000000000000000000000b01")
+		reverter = common.HexToAddress("0x0000000000000000000000000000000000000b02")
+	)
+	backend := newTestBackend(t, core.GenesisAlloc{
+		caller: {Balance: funds},
+		sender: {Balance: funds},
+		// Returns the balance of the address in the calldata
+		balances: {Code: common.Hex2Bytes("6000353160005260206000f3"), Balance: new(big.Int)},
+		// Reverts with empty data
+		reverter: {Code: common.Hex2Bytes("60006000fd"), Balance: new(big.Int)},
+	})
+	defer backend.chain.Stop()
+	api := NewPublicTomoAPI(backend)
+
+	balanceOf := func(addr common.Address) CallArgs {
+		return CallArgs{From: caller, To: &balances, Gas: 100000, Data: common.LeftPadBytes(addr.Bytes(), 32)}
+	}
+	transfer := CallArgs{From: sender, To: &receiver, Gas: hexutil.Uint64(params.TxGas), Value: hexutil.Big(*big.NewInt(1000))}
+
+	// Later calls see the transfer, but not the funds the sender is given for
+	// the execution
+	results, err := api.Multicall(context.Background(), []CallArgs{transfer, balanceOf(sender), balanceOf(receiver), {From: caller, To: &reverter, Gas: 100000}}, rpc.LatestBlockNumber, nil, nil)
+	if err != nil {
+		t.Fatalf("multicall failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("result count mismatch: have %d, want 4", len(results))
+	}
+	if results[0].Failed || results[0].GasUsed != hexutil.Uint64(params.TxGas) {
+		t.Errorf("transfer result mismatch: %+v", results[0])
+	}
+	want := new(big.Int).Sub(funds, big.NewInt(1000))
+	if have := new(big.Int).SetBytes(results[1].ReturnData); have.Cmp(want) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", have, want)
+	}
+	if have := new(big.Int).SetBytes(results[2].ReturnData); have.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("receiver balance mismatch: have %v, want 1000", have)
+	}
+	if !results[3].Failed || results[3].Error == "" {
+		t.Errorf("reverted call not reported: %+v", results[3])
+	}
+
+	// An overridden sender balance is what the call is executed with
+	override := (*hexutil.Big)(big.NewInt(100000))
+	results, err = api.Multicall(context.Background(), []CallArgs{transfer, balanceOf(sender)}, rpc.LatestBlockNumber, &StateOverride{sender: {Balance: &override}}, nil)
+	if err != nil {
+		t.Fatalf("multicall failed: %v", err)
+	}
+	if have := new(big.Int).SetBytes(results[1].ReturnData); have.Cmp(big.NewInt(99000)) != 0 {
+		t.Errorf("overridden sender balance mismatch: have %v, want 99000", have)
+	}
+
+	if _, err := api.Multicall(context.Background(), nil, rpc.LatestBlockNumber, nil, nil); err == nil {
+		t.Errorf("empty multicall accepted")
+	}
+	if _, err := api.Multicall(context.Background(), make([]CallArgs, maxMulticallCalls+1), rpc.LatestBlockNumber, nil, nil); err == nil {
+		t.Errorf("oversized multicall accepted")
+	}
+}
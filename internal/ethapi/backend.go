@@ -80,6 +80,15 @@ type Backend interface {
 	OrderTxPoolContent() (map[common.Address]types.OrderTransactions, map[common.Address]types.OrderTransactions)
 	OrderStats() (pending int, queued int)
 	SendLendingTx(ctx context.Context, signedTx *types.LendingTransaction) error
+	LowDepositRelayers() []core.RelayerDepositEvent
+	SubscribeRelayerDepositEvent(ch chan<- core.RelayerDepositEvent) event.Subscription
+
+	// Private submission to the upcoming block producers, expiring at the given
+	// block (0 for the default) after which the transaction is gossiped publicly
+	// if fallback is set, or dropped otherwise.
+	SendPrivateTx(ctx context.Context, signedTx *types.Transaction, expiry uint64, fallback bool) error
+	SendPrivateOrderTx(ctx context.Context, signedTx *types.OrderTransaction, expiry uint64, fallback bool) error
+	SendPrivateLendingTx(ctx context.Context, signedTx *types.LendingTransaction, expiry uint64, fallback bool) error
 
 	ChainConfig() *params.ChainConfig
 	CurrentBlock() *types.Block
@@ -109,6 +118,11 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicBlockChainAPI(apiBackend),
 			Public:    true,
+		}, {
+			Namespace: "tomo",
+			Version:   "1.0",
+			Service:   NewPublicTomoAPI(apiBackend),
+			Public:    true,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
@@ -119,6 +133,11 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicTomoXTransactionPoolAPI(apiBackend, nonceLock),
 			Public:    true,
+		}, {
+			Namespace: "tomoxlending",
+			Version:   "1.0",
+			Service:   NewPublicTomoXLendingPoolAPI(apiBackend),
+			Public:    true,
 		}, {
 			Namespace: "txpool",
 			Version:   "1.0",
@@ -0,0 +1,78 @@
+package ethapi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tomochain/tomochain/accounts/abi"
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/vm"
+)
+
+// revertError is an API error that encompasses an EVM revert with JSON error
+// code and a binary data blob.
+type revertError struct {
+	error
+	reason string // revert reason hex encoded
+}
+
+// ErrorCode returns the JSON error code for a revert.
+// See: https://github.com/ethereum/wiki/wiki/JSON-RPC-Error-Codes-Improvement-Proposal
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+// ErrorData returns the hex encoded revert reason.
+func (e *revertError) ErrorData() interface{} {
+	return e.reason
+}
+
+// newRevertError creates a revertError instance with the provided revert data,
+// the reason is decoded into the message if it follows the Error(string) or
+// Panic(uint256) encoding.
+func newRevertError(data []byte) *revertError {
+	err := errors.New("execution reverted")
+	if reason, errUnpack := abi.UnpackRevert(data); errUnpack == nil {
+		err = fmt.Errorf("execution reverted: %v", reason)
+	}
+	return &revertError{
+		error:  err,
+		reason: hexutil.Encode(data),
+	}
+}
+
+// newCallError converts the error an EVM execution failed with into the error
+// returned over the API, carrying the revert data if there is any.
+func newCallError(result []byte, vmErr error) error {
+	if vmErr == vm.ErrExecutionReverted && len(result) > 0 {
+		return newRevertError(result)
+	}
+	return vmErr
+}
+
+// TxFailureResult describes why a transaction included in the chain failed.
+type TxFailureResult struct {
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Data    hexutil.Bytes `json:"data"`
+	Reason  string        `json:"reason,omitempty"`
+}
+
+// readTxFailure retrieves the failure of a transaction from the failures of
+// its block, returning nil if the transaction did not fail or the failure is
+// unknown, e.g. because the block was fast synced.
+func readTxFailure(db rawdb.DatabaseReader, hash common.Hash, blockHash common.Hash, blockNumber uint64) *TxFailureResult {
+	for _, failure := range rawdb.ReadTxFailures(db, blockHash, blockNumber) {
+		if failure.TxHash != hash {
+			continue
+		}
+		result := &TxFailureResult{Code: failure.Code, Message: failure.Message, Data: failure.Data}
+		if reason, err := abi.UnpackRevert(failure.Data); err == nil {
+			result.Reason = reason
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tomochain/tomochain/tomoxlending"
+)
+
+// GetLiquidationAuctions returns the liquidation auctions of lending trades
+// waiting for bids or settlement, priced for the next block.
+func (s *PublicTomoXLendingPoolAPI) GetLiquidationAuctions(ctx context.Context) ([]*tomoxlending.LendingAuctionInfo, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	env, err := newSimulationEnv(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := lendingService.GetLendingState(env.block, env.author)
+	if err != nil {
+		return nil, err
+	}
+	return lendingService.GetLiquidationAuctions(env.header, lendingState), nil
+}
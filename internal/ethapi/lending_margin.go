@@ -0,0 +1,42 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending"
+)
+
+// GetMarginAccount returns the health of the margin account of borrower and
+// the liquidation threshold and price of each of its collaterals, priced for
+// the next block.
+func (s *PublicTomoXLendingPoolAPI) GetMarginAccount(ctx context.Context, borrower common.Address) (*tomoxlending.MarginAccountInfo, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	env, err := newSimulationEnv(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := lendingService.GetLendingState(env.block, env.author)
+	if err != nil {
+		return nil, err
+	}
+	return lendingService.GetMarginAccountInfo(env.header, env.chain, env.statedb, lendingState, env.trading, borrower)
+}
+
+// GetMarginThresholds returns the liquidation threshold of every collateral in
+// margin accounts
+func (s *PublicTomoXLendingPoolAPI) GetMarginThresholds(ctx context.Context) ([]*tomoxlending.MarginThreshold, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	env, err := newSimulationEnv(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	return lendingService.GetMarginThresholds(env.statedb), nil
+}
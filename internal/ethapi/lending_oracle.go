@@ -0,0 +1,36 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// GetCollateralOracles explains how the collateral price oracle prices every
+// lending pair, or the pairs of the given collateral, on top of the latest
+// state: the price of each source, whether it is stale, the median, its
+// deviation from the average price and whether liquidations are paused.
+func (s *PublicTomoXLendingPoolAPI) GetCollateralOracles(ctx context.Context, collateralToken *common.Address) ([]*lendingstate.OracleReport, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	env, err := newSimulationEnv(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := lendingstate.GetAllLendingPairs(env.statedb)
+	if err != nil {
+		return nil, err
+	}
+	reports := []*lendingstate.OracleReport{}
+	for _, pair := range pairs {
+		if collateralToken != nil && pair.CollateralToken != *collateralToken {
+			continue
+		}
+		reports = append(reports, lendingService.GetOracleReport(env.header, env.chain, env.statedb, env.trading, pair.CollateralToken, pair.LendingToken))
+	}
+	return reports, nil
+}
@@ -0,0 +1,59 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// GetLendingPools returns the utilization, the supply and borrow rates and the
+// APYs of every variable-rate lending pool, with the interest accrued up to the
+// next block.
+func (s *PublicTomoXLendingPoolAPI) GetLendingPools(ctx context.Context) ([]*tomoxlending.LendingPoolInfo, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	env, err := newSimulationEnv(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := lendingService.GetLendingState(env.block, env.author)
+	if err != nil {
+		return nil, err
+	}
+	pools := []*tomoxlending.LendingPoolInfo{}
+	for _, token := range lendingstate.GetLendingPoolTokens(env.statedb) {
+		pools = append(pools, lendingService.GetLendingPoolInfo(env.header, env.chain, env.statedb, lendingState, token))
+	}
+	return pools, nil
+}
+
+// GetLendingPoolPositions returns the deposits, debts and collaterals of user
+// in the variable-rate lending pools, with the interest accrued up to the next
+// block.
+func (s *PublicTomoXLendingPoolAPI) GetLendingPoolPositions(ctx context.Context, user common.Address) ([]*tomoxlending.LendingPoolPosition, error) {
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	env, err := newSimulationEnv(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	lendingState, err := lendingService.GetLendingState(env.block, env.author)
+	if err != nil {
+		return nil, err
+	}
+	positions := []*tomoxlending.LendingPoolPosition{}
+	for _, token := range lendingstate.GetLendingPoolTokens(env.statedb) {
+		position := lendingService.GetLendingPoolPosition(env.header, env.chain, env.statedb, lendingState, token, user)
+		if position.Deposit.Sign() > 0 || position.Debt.Sign() > 0 || position.CollateralAmount.Sign() > 0 {
+			positions = append(positions, position)
+		}
+	}
+	return positions, nil
+}
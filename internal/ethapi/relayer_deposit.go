@@ -0,0 +1,75 @@
+package ethapi
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core"
+	"github.com/tomochain/tomochain/rpc"
+)
+
+// RelayerDepositStatus reports a relayer whose deposit is too low for the order
+// or the lending pool to accept its new orders.
+type RelayerDepositStatus struct {
+	Relayer  common.Address `json:"relayer"`
+	Pool     string         `json:"pool"` // "trading" or "lending"
+	Deposit  *big.Int       `json:"deposit"`
+	Required *big.Int       `json:"required"`
+	Low      bool           `json:"low"`
+}
+
+func newRelayerDepositStatus(ev core.RelayerDepositEvent) *RelayerDepositStatus {
+	pool := "trading"
+	if ev.Lending {
+		pool = "lending"
+	}
+	return &RelayerDepositStatus{
+		Relayer:  ev.Relayer,
+		Pool:     pool,
+		Deposit:  ev.Deposit,
+		Required: ev.Required,
+		Low:      ev.Low,
+	}
+}
+
+// GetLowDepositRelayers returns the relayers whose new orders are rejected by
+// the pools because their deposit is too low.
+func (s *PublicTomoXTransactionPoolAPI) GetLowDepositRelayers(ctx context.Context) []*RelayerDepositStatus {
+	relayers := []*RelayerDepositStatus{}
+	for _, ev := range s.b.LowDepositRelayers() {
+		relayers = append(relayers, newRelayerDepositStatus(ev))
+	}
+	return relayers
+}
+
+// RelayerDeposit creates a subscription that fires each time the deposit of a
+// relayer falls too low for the pools to accept its new orders, or recovers.
+func (s *PublicTomoXTransactionPoolAPI) RelayerDeposit(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan core.RelayerDepositEvent)
+		eventsSub := s.b.SubscribeRelayerDepositEvent(events)
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, newRelayerDepositStatus(ev))
+			case <-rpcSub.Err():
+				eventsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				eventsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
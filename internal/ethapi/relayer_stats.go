@@ -0,0 +1,60 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+const maxRelayerStatsRange = 100000
+
+// GetRelayerStats returns the trading and lending volume, the fees and the
+// matching results of relayer between fromBlock and toBlock, with its deposit
+// at toBlock and the rate at which the fees burn it.
+func (s *PublicTomoXTransactionPoolAPI) GetRelayerStats(ctx context.Context, relayer common.Address, fromBlock, toBlock rpc.BlockNumber) (*tomox.RelayerStats, error) {
+	tomoxService := s.b.TomoxService()
+	if tomoxService == nil {
+		return nil, errors.New("TomoX service not found")
+	}
+	statedb, to, err := s.b.StateAndHeaderByNumber(ctx, toBlock)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	from, err := s.b.HeaderByNumber(ctx, fromBlock)
+	if from == nil || err != nil {
+		return nil, err
+	}
+	if from.Number.Cmp(to.Number) > 0 {
+		return nil, fmt.Errorf("invalid block range: %v > %v", from.Number, to.Number)
+	}
+	if blocks := new(big.Int).Sub(to.Number, from.Number).Uint64() + 1; blocks > maxRelayerStatsRange {
+		return nil, fmt.Errorf("block range too large: %d > %d", blocks, maxRelayerStatsRange)
+	}
+	stats := tomox.NewRelayerStats(relayer)
+	stats.FromBlock, stats.ToBlock = from.Number.Uint64(), to.Number.Uint64()
+	for number := stats.FromBlock; number <= stats.ToBlock; number++ {
+		header, err := s.b.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if header == nil || err != nil {
+			return nil, fmt.Errorf("block %d not found", number)
+		}
+		blockStats, err := tomoxService.GetBlockRelayerStats(header.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if relayerStats := blockStats[relayer]; relayerStats != nil {
+			stats.Merge(relayerStats)
+		}
+	}
+	stats.Deposit = tradingstate.GetRelayerDeposit(relayer, statedb)
+	stats.BurnRate = new(big.Int).Div(stats.DepositFees, new(big.Int).SetUint64(stats.ToBlock-stats.FromBlock+1))
+	if stats.BurnRate.Sign() > 0 {
+		stats.RemainingBlocks = new(big.Int).Div(stats.Deposit, stats.BurnRate).Uint64()
+	}
+	return stats, nil
+}
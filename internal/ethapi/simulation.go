@@ -0,0 +1,217 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/hexutil"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/rpc"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// maxSimulatedOrders is the maximum number of orders simulated in one request.
+const maxSimulatedOrders = 100
+
+// SimulateOrderArgs is an order to simulate. It is either given as an order
+// message or as an RLP encoded order transaction. Orders without signature
+// are simulated without verifying it.
+type SimulateOrderArgs struct {
+	OrderMsg
+	Raw hexutil.Bytes `json:"raw,omitempty"`
+}
+
+// tx returns the order transaction described by the arguments.
+func (args *SimulateOrderArgs) tx() (*types.OrderTransaction, error) {
+	if len(args.Raw) > 0 {
+		tx := new(types.OrderTransaction)
+		if err := rlp.DecodeBytes(args.Raw, tx); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	msg := args.OrderMsg
+	tx := types.NewOrderTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), msg.Price.ToInt(), msg.ExchangeAddress, msg.UserAddress, msg.BaseToken, msg.QuoteToken, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.OrderID))
+	tx.SetSelfTradePrevention(msg.SelfTradePrevention)
+	return tx.ImportSignature(msg.V.ToInt(), msg.R.ToInt(), msg.S.ToInt()), nil
+}
+
+// SimulateLendingArgs is a lending item to simulate. It is either given as a
+// lending message or as an RLP encoded lending transaction. Items without
+// signature are simulated without verifying it.
+type SimulateLendingArgs struct {
+	LendingMsg
+	Raw hexutil.Bytes `json:"raw,omitempty"`
+}
+
+// tx returns the lending transaction described by the arguments.
+func (args *SimulateLendingArgs) tx() (*types.LendingTransaction, error) {
+	if len(args.Raw) > 0 {
+		tx := new(types.LendingTransaction)
+		if err := rlp.DecodeBytes(args.Raw, tx); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	msg := args.LendingMsg
+	tx := types.NewLendingTransaction(uint64(msg.AccountNonce), msg.Quantity.ToInt(), uint64(msg.Interest), uint64(msg.Term), msg.RelayerAddress, msg.UserAddress, msg.LendingToken, msg.CollateralToken, msg.AutoTopUp, msg.Status, msg.Side, msg.Type, msg.Hash, uint64(msg.LendingId), uint64(msg.LendingTradeId), msg.ExtraData)
+	tx.SetSelfTradePrevention(msg.SelfTradePrevention)
+	return tx.ImportSignature(msg.V.ToInt(), msg.R.ToInt(), msg.S.ToInt()), nil
+}
+
+// simulationEnv is the chain state orders are simulated on: the latest block
+// with its states and a header for the block to be produced on top of it.
+type simulationEnv struct {
+	block   *types.Block
+	header  *types.Header
+	author  common.Address
+	chain   consensus.ChainContext
+	statedb *state.StateDB
+	trading *tradingstate.TradingStateDB
+}
+
+// newSimulationEnv retrieves the states of the latest block. The matching fee
+// is attributed to the author of that block, standing in for the unknown
+// producer of the next one.
+func newSimulationEnv(ctx context.Context, b Backend) (*simulationEnv, error) {
+	tomoxService := b.TomoxService()
+	if tomoxService == nil {
+		return nil, errors.New("TomoX service not found")
+	}
+	block := b.CurrentBlock()
+	if block == nil {
+		return nil, errors.New("Current block not found")
+	}
+	author, err := b.GetEngine().Author(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	statedb, _, err := b.StateAndHeaderByNumber(ctx, rpc.BlockNumber(block.NumberU64()))
+	if err != nil {
+		return nil, err
+	}
+	trading, err := tomoxService.GetTradingState(block, author)
+	if err != nil {
+		return nil, err
+	}
+	header := types.CopyHeader(block.Header())
+	header.ParentHash = block.Hash()
+	header.Number = new(big.Int).Add(block.Number(), common.Big1)
+	header.Time = new(big.Int).SetInt64(time.Now().Unix())
+	if header.Time.Cmp(block.Time()) <= 0 {
+		header.Time = new(big.Int).Add(block.Time(), common.Big1)
+	}
+	header.Coinbase = author
+	return &simulationEnv{
+		block:   block,
+		header:  header,
+		author:  author,
+		chain:   &chainContext{ctx: ctx, b: b},
+		statedb: statedb,
+		trading: trading,
+	}, nil
+}
+
+// chainContext adapts a Backend to the consensus.ChainContext required by the
+// matching engines.
+type chainContext struct {
+	ctx context.Context
+	b   Backend
+}
+
+func (c *chainContext) Engine() consensus.Engine { return c.b.GetEngine() }
+
+func (c *chainContext) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header, err := c.b.HeaderByNumber(c.ctx, rpc.BlockNumber(number))
+	if err != nil || header == nil || header.Hash() != hash {
+		return nil
+	}
+	return header
+}
+
+func (c *chainContext) CurrentHeader() *types.Header { return c.b.CurrentBlock().Header() }
+
+func (c *chainContext) Config() *params.ChainConfig { return c.b.ChainConfig() }
+
+// SimulateOrders runs the given orders in sequence through the matching engine
+// on top of the latest trading state and returns the expected trades, rejected
+// orders, fees and balance settlements. Nothing is committed or broadcast.
+func (s *PublicTomoXTransactionPoolAPI) SimulateOrders(ctx context.Context, args []SimulateOrderArgs) (*tomox.SimulationResult, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no orders to simulate")
+	}
+	if len(args) > maxSimulatedOrders {
+		return nil, fmt.Errorf("too many orders: have %d, max %d", len(args), maxSimulatedOrders)
+	}
+	env, err := newSimulationEnv(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	signer := types.MakeOrderSigner(s.b.ChainConfig(), env.header.Number)
+	orders := make([]*tradingstate.OrderItem, 0, len(args))
+	for i := range args {
+		tx, err := args[i].tx()
+		if err != nil {
+			return nil, fmt.Errorf("order %d: %v", i, err)
+		}
+		orders = append(orders, tomox.OrderItemFromTx(tx, signer))
+	}
+	return s.b.TomoxService().SimulateOrders(env.header, env.author, env.chain, env.statedb, env.trading, orders), nil
+}
+
+// PublicTomoXLendingPoolAPI exposes lending methods in the tomoxlending
+// namespace next to the ones of the lending service.
+type PublicTomoXLendingPoolAPI struct {
+	b Backend
+}
+
+// NewPublicTomoXLendingPoolAPI creates a new RPC service for TomoX lending.
+func NewPublicTomoXLendingPoolAPI(b Backend) *PublicTomoXLendingPoolAPI {
+	return &PublicTomoXLendingPoolAPI{b}
+}
+
+// SimulateLendings runs the given lending items in sequence through the
+// lending engine on top of the latest lending state and returns the expected
+// lending trades, rejected items, fees and balance settlements. Nothing is
+// committed or broadcast.
+func (s *PublicTomoXLendingPoolAPI) SimulateLendings(ctx context.Context, args []SimulateLendingArgs) (*tomoxlending.SimulationResult, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no lending items to simulate")
+	}
+	if len(args) > maxSimulatedOrders {
+		return nil, fmt.Errorf("too many lending items: have %d, max %d", len(args), maxSimulatedOrders)
+	}
+	lendingService := s.b.LendingService()
+	if lendingService == nil {
+		return nil, errors.New("TomoX Lending service not found")
+	}
+	env, err := newSimulationEnv(ctx, s.b)
+	if err != nil {
+		return nil, err
+	}
+	signer := types.MakeLendingSigner(s.b.ChainConfig(), env.header.Number)
+	items := make([]*lendingstate.LendingItem, 0, len(args))
+	for i := range args {
+		tx, err := args[i].tx()
+		if err != nil {
+			return nil, fmt.Errorf("lending item %d: %v", i, err)
+		}
+		items = append(items, tomoxlending.LendingItemFromTx(tx, signer))
+	}
+	lending, err := lendingService.GetLendingState(env.block, env.author)
+	if err != nil {
+		return nil, err
+	}
+	return lendingService.SimulateLendings(env.header, env.author, env.chain, env.statedb, lending, env.trading, items), nil
+}
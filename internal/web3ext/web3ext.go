@@ -32,6 +32,7 @@ var Modules = map[string]string{
 	"tomox":        TomoX_JS,
 	"tomoxlending": TomoXLending_JS,
 	"swarmfs":      SWARMFS_JS,
+	"tomo":         Tomo_JS,
 	"txpool":       TxPool_JS,
 }
 
@@ -162,6 +163,32 @@ web3._extend({
 			call: 'admin_removePeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'addTrustedPeer',
+			call: 'admin_addTrustedPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removeTrustedPeer',
+			call: 'admin_removeTrustedPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'masternodeRecord',
+			call: 'admin_masternodeRecord',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unbanPeer',
+			call: 'admin_unbanPeer',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -208,6 +235,18 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'peerScores',
+			getter: 'admin_peerScores'
+		}),
+		new web3._extend.Property({
+			name: 'peerBans',
+			getter: 'admin_peerBans'
+		}),
+		new web3._extend.Property({
+			name: 'masternodeMesh',
+			getter: 'admin_masternodeMesh'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
@@ -230,6 +269,11 @@ web3._extend({
 			call: 'debug_getBlockRlp',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getTransactionFailure',
+			call: 'debug_getTransactionFailure',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'setHead',
 			call: 'debug_setHead',
@@ -467,6 +511,12 @@ web3._extend({
 			call: 'eth_getRawTransactionByHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'createAccessList',
+			call: 'eth_createAccessList',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter],
+		}),
 		new web3._extend.Method({
 			name: 'getRewardByHash',
 			call: 'eth_getRewardByHash',
@@ -676,6 +726,21 @@ web3._extend({
 		new web3._extend.Method({
             name: 'sendLendingTransaction',
             call: 'tomox_sendLending',
+            params: 1
+		}),
+		new web3._extend.Method({
+            name: 'sendPrivateOrder',
+            call: 'tomox_sendPrivateOrder',
+            params: 2
+		}),
+		new web3._extend.Method({
+            name: 'sendPrivateLending',
+            call: 'tomox_sendPrivateLending',
+            params: 2
+		}),
+		new web3._extend.Method({
+            name: 'simulateOrders',
+            call: 'tomox_simulateOrders',
             params: 1
 		}),
 		new web3._extend.Method({
@@ -833,6 +898,17 @@ web3._extend({
             call: 'tomox_getLendingTradeById',
             params: 3
 		}),
+		new web3._extend.Method({
+            name: 'getRelayerStats',
+            call: 'tomox_getRelayerStats',
+            params: 3,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+            name: 'getLowDepositRelayers',
+            call: 'tomox_getLowDepositRelayers',
+            params: 0
+		}),
 	]
 });
 `
@@ -870,6 +946,43 @@ web3._extend({
             params: 1
         }),
 		new web3._extend.Method({
+            name: 'simulateLendings',
+            call: 'tomoxlending_simulateLendings',
+            params: 1
+		}),
+		new web3._extend.Method({
+            name: 'getCollateralOracles',
+            call: 'tomoxlending_getCollateralOracles',
+            params: 1,
+            inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+            name: 'getLendingPools',
+            call: 'tomoxlending_getLendingPools',
+            params: 0
+		}),
+		new web3._extend.Method({
+            name: 'getLendingPoolPositions',
+            call: 'tomoxlending_getLendingPoolPositions',
+            params: 1
+		}),
+		new web3._extend.Method({
+            name: 'getMarginAccount',
+            call: 'tomoxlending_getMarginAccount',
+            params: 1,
+            inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+            name: 'getMarginThresholds',
+            call: 'tomoxlending_getMarginThresholds',
+            params: 0
+		}),
+		new web3._extend.Method({
+            name: 'getLiquidationAuctions',
+            call: 'tomoxlending_getLiquidationAuctions',
+            params: 0
+		}),
+		new web3._extend.Method({
             name: 'getOrderNonce',
             call: 'tomoxlending_getOrderNonce',
             params: 1,
@@ -980,6 +1093,25 @@ web3._extend({
 });
 `
 
+const Tomo_JS = `
+web3._extend({
+	property: 'tomo',
+	methods: [
+		new web3._extend.Method({
+			name: 'multicall',
+			call: 'tomo_multicall',
+			params: 4,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'sendPrivateTransaction',
+			call: 'tomo_sendPrivateTransaction',
+			params: 2
+		}),
+	]
+});
+`
+
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
@@ -121,6 +121,16 @@ func (b *LesApiBackend) SendLendingTx(ctx context.Context, signedTx *types.Lendi
 	return nil
 }
 
+func (b *LesApiBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction, expiry uint64, fallback bool) error {
+	return errors.New("private transactions are not supported in light mode")
+}
+func (b *LesApiBackend) SendPrivateOrderTx(ctx context.Context, signedTx *types.OrderTransaction, expiry uint64, fallback bool) error {
+	return errors.New("private transactions are not supported in light mode")
+}
+func (b *LesApiBackend) SendPrivateLendingTx(ctx context.Context, signedTx *types.LendingTransaction, expiry uint64, fallback bool) error {
+	return errors.New("private transactions are not supported in light mode")
+}
+
 func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.eth.txPool.RemoveTx(txHash)
 }
@@ -152,6 +162,17 @@ func (b *LesApiBackend) OrderStats() (pending int, queued int) {
 	return 0, 0
 }
 
+func (b *LesApiBackend) LowDepositRelayers() []core.RelayerDepositEvent {
+	return nil
+}
+
+func (b *LesApiBackend) SubscribeRelayerDepositEvent(ch chan<- core.RelayerDepositEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
 func (b *LesApiBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Subscription {
 	return b.eth.txPool.SubscribeTxPreEvent(ch)
 }
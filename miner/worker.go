@@ -30,6 +30,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/tomochain/tomochain/tomox"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 
 	mapset "github.com/deckarep/golang-set"
@@ -97,6 +98,8 @@ type Work struct {
 	txs      []*types.Transaction
 	receipts []*types.Receipt
 
+	relayerStats *tomox.BlockRelayerStats // statistics of the relayers, written with the block
+
 	createdAt time.Time
 }
 
@@ -357,6 +360,11 @@ func (self *worker) wait() {
 			for _, log := range work.state.Logs() {
 				log.BlockHash = block.Hash()
 			}
+			if work.relayerStats != nil {
+				if tomoX := self.eth.GetTomoX(); tomoX != nil {
+					tomoX.SetRelayerStats(block.HashNoValidator(), work.relayerStats)
+				}
+			}
 			self.currentMu.Lock()
 			stat, err := self.chain.WriteBlockWithState(block, work.receipts, work.state, work.tradingState, work.lendingState)
 			self.currentMu.Unlock()
@@ -674,6 +682,19 @@ func (self *worker) commitNewWork() {
 					lendingOrderPending, _ := self.eth.LendingPool().Pending()
 					lendingInput, lendingMatchingResults = tomoXLending.ProcessOrderPending(header, self.coinbase, self.chain, lendingOrderPending, work.state, work.lendingState, work.tradingState)
 					log.Debug("lending transaction matches found", "lendingInput", len(lendingInput), "lendingMatchingResults", len(lendingMatchingResults))
+					work.relayerStats = tomox.NewBlockRelayerStats()
+					for _, txMatch := range tradingTxMatches {
+						order, err := txMatch.DecodeOrder()
+						if err != nil {
+							continue
+						}
+						result := tradingMatchingResults[tradingstate.GetMatchingResultCacheKey(order)]
+						work.relayerStats.RecordTradingResult(order, result.Trades, result.Rejects)
+					}
+					for _, item := range lendingInput {
+						result := lendingMatchingResults[lendingstate.GetLendingCacheKey(item)]
+						work.relayerStats.RecordLendingResult(item, result.Trades, result.Rejects)
+					}
 					if header.Number.Uint64()%self.config.Posv.Epoch == common.LiquidateLendingTradeBlock {
 						updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err = tomoXLending.ProcessLiquidationData(header, self.chain, work.state, work.tradingState, work.lendingState)
 						if err != nil {
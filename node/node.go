@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"github.com/tomochain/tomochain/core/rawdb"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -59,10 +60,11 @@ type Node struct {
 	ipcListener net.Listener // IPC RPC listener socket to serve API requests
 	ipcHandler  *rpc.Server  // IPC RPC request handler to process the API requests
 
-	httpEndpoint  string       // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
-	httpWhitelist []string     // HTTP RPC modules to allow through this endpoint
-	httpListener  net.Listener // HTTP RPC listener socket to server API requests
-	httpHandler   *rpc.Server  // HTTP RPC request handler to process the API requests
+	httpEndpoint  string                  // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
+	httpWhitelist []string                // HTTP RPC modules to allow through this endpoint
+	httpListener  net.Listener            // HTTP RPC listener socket to server API requests
+	httpHandler   *rpc.Server             // HTTP RPC request handler to process the API requests
+	httpRoutes    map[string]http.Handler // Additional HTTP handlers served next to JSON-RPC, keyed by path
 
 	wsEndpoint string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
 	wsListener net.Listener // Websocket RPC listener socket to server API requests
@@ -253,9 +255,19 @@ func (n *Node) openDataDir() error {
 func (n *Node) startRPC(services map[reflect.Type]Service) error {
 	// Gather all the possible APIs to surface
 	apis := n.apis()
+	routes := make(map[string]http.Handler)
 	for _, service := range services {
 		apis = append(apis, service.APIs()...)
+		if provider, ok := service.(HTTPHandlerService); ok {
+			for path, handler := range provider.HTTPHandlers() {
+				if _, exists := routes[path]; exists {
+					return fmt.Errorf("duplicate HTTP handler for path %s", path)
+				}
+				routes[path] = handler
+			}
+		}
 	}
+	n.httpRoutes = routes
 	// Start the various API endpoints, terminating all in case of errors
 	if err := n.startInProc(apis); err != nil {
 		return err
@@ -394,7 +406,17 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, vhosts, handler).Serve(listener)
+	var served http.Handler = handler
+	if len(n.httpRoutes) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/", handler)
+		for path, route := range n.httpRoutes {
+			mux.Handle(path, route)
+			n.log.Debug("HTTP handler registered", "path", path)
+		}
+		served = mux
+	}
+	go rpc.NewHTTPServer(cors, vhosts, served).Serve(listener)
 	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
@@ -18,6 +18,7 @@ package node
 
 import (
 	"github.com/tomochain/tomochain/core/rawdb"
+	"net/http"
 	"reflect"
 
 	"github.com/tomochain/tomochain/accounts"
@@ -103,3 +104,10 @@ type Service interface {
 	// are all terminated.
 	Stop() error
 }
+
+// HTTPHandlerService is implemented by services that serve additional HTTP
+// endpoints (e.g. GraphQL) next to JSON-RPC on the node's HTTP server. The
+// handlers are keyed by the path they are mounted on.
+type HTTPHandlerService interface {
+	HTTPHandlers() map[string]http.Handler
+}
@@ -82,6 +82,11 @@ type dialstate struct {
 
 	start     time.Time        // time when the dialer was first used
 	bootnodes []*discover.Node // default dials when there are no peers
+
+	source        nodeSource // additional source of dynamic dial candidates
+	sourceRunning bool
+
+	banned func(discover.NodeID) bool // reports peers refused connections, if set
 }
 
 type discoverTable interface {
@@ -92,6 +97,15 @@ type discoverTable interface {
 	ReadRandomNodes([]*discover.Node) int
 }
 
+// nodeSource yields dial candidates found by means other than the discovery
+// table, such as DNS node lists. Next blocks until a node is available and
+// returns false once the source has been closed.
+type nodeSource interface {
+	Next() bool
+	Node() *discover.Node
+	Close()
+}
+
 // the dial history remembers recent dials.
 type dialHistory []pastDial
 
@@ -121,6 +135,14 @@ type discoverTask struct {
 	results []*discover.Node
 }
 
+// sourceTask pulls dial candidates from the node source.
+// Only one sourceTask is active at any time.
+type sourceTask struct {
+	source  nodeSource
+	want    int
+	results []*discover.Node
+}
+
 // A waitExpireTask is generated if there are no other tasks
 // to keep the loop in Server.run ticking.
 type waitExpireTask struct {
@@ -218,7 +240,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	// Use random nodes from the table for half of the necessary
 	// dynamic dials.
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
@@ -236,10 +258,15 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	}
 	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
 	// Launch a discovery lookup if more candidates are needed.
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
+	if len(s.lookupBuf) < needDynDials && !s.lookupRunning && s.ntab != nil {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &discoverTask{})
 	}
+	// Pull candidates from the node source alongside the lookups.
+	if len(s.lookupBuf) < needDynDials && !s.sourceRunning && s.source != nil {
+		s.sourceRunning = true
+		newtasks = append(newtasks, &sourceTask{source: s.source, want: needDynDials - len(s.lookupBuf)})
+	}
 
 	// Launch a timer to wait for the next node to expire if all
 	// candidates have been tried and no task is currently active.
@@ -258,6 +285,7 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errBanned           = errors.New("banned")
 )
 
 func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer) error {
@@ -276,6 +304,8 @@ func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer)
 		return errNotWhitelisted
 	case s.hist.contains(n.ID):
 		return errRecentlyDialed
+	case s.banned != nil && s.banned(n.ID):
+		return errBanned
 	}
 	return nil
 }
@@ -288,6 +318,9 @@ func (s *dialstate) taskDone(t task, now time.Time) {
 	case *discoverTask:
 		s.lookupRunning = false
 		s.lookupBuf = append(s.lookupBuf, t.results...)
+	case *sourceTask:
+		s.sourceRunning = false
+		s.lookupBuf = append(s.lookupBuf, t.results...)
 	}
 }
 
@@ -399,6 +432,28 @@ func (t *discoverTask) String() string {
 	return s
 }
 
+func (t *sourceTask) Do(srv *Server) {
+	// Like lookups, pulls from the source are throttled. Small node lists are
+	// cycled through over and over, which would otherwise spin the event loop
+	// once all of their nodes are connected.
+	next := srv.lastSourcePull.Add(lookupInterval)
+	if now := time.Now(); now.Before(next) {
+		time.Sleep(next.Sub(now))
+	}
+	srv.lastSourcePull = time.Now()
+	for len(t.results) < t.want && t.source.Next() {
+		t.results = append(t.results, t.source.Node())
+	}
+}
+
+func (t *sourceTask) String() string {
+	s := "node source pull"
+	if len(t.results) > 0 {
+		s += fmt.Sprintf(" (%d results)", len(t.results))
+	}
+	return s
+}
+
 func (t waitExpireTask) Do(*Server) {
 	time.Sleep(t.Duration)
 }
@@ -84,6 +84,12 @@ func (t fakeTable) Lookup(discover.NodeID) []*discover.Node  { return nil }
 func (t fakeTable) Resolve(discover.NodeID) *discover.Node   { return nil }
 func (t fakeTable) ReadRandomNodes(buf []*discover.Node) int { return copy(buf, t) }
 
+type fakeSource struct{}
+
+func (s *fakeSource) Next() bool           { return false }
+func (s *fakeSource) Node() *discover.Node { return nil }
+func (s *fakeSource) Close()               {}
+
 // This test checks that dynamic dials are launched from discovery results.
 func TestDialStateDynDial(t *testing.T) {
 	runDialTest(t, dialtest{
@@ -407,6 +413,61 @@ func TestDialStateDynDialFromTable(t *testing.T) {
 	})
 }
 
+// This test checks that dynamic dials are launched from node source results
+// when the discovery table is disabled.
+func TestDialStateDynDialFromSource(t *testing.T) {
+	source := new(fakeSource)
+	state := newDialState(nil, nil, nil, 3, nil)
+	state.source = source
+
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			// A source pull is launched for all dynamic dials.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+				},
+				new: []task{&sourceTask{source: source, want: 2}},
+			},
+			// Dynamic dials are launched when it completes.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+				},
+				done: []task{
+					&sourceTask{source: source, want: 2, results: []*discover.Node{
+						{ID: uintID(2)},
+						{ID: uintID(3)},
+					}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+				},
+			},
+			// No more pulls are launched while the dials are running.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+				},
+			},
+			// Another pull is launched once a dial fails.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+					{rw: &conn{flags: dynDialedConn, id: uintID(2)}},
+				},
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+				},
+				new: []task{&sourceTask{source: source, want: 1}},
+			},
+		},
+	})
+}
+
 // This test checks that candidates that do not match the netrestrict list are not dialed.
 func TestDialStateNetRestrict(t *testing.T) {
 	// This table always returns the same random nodes
@@ -437,6 +498,31 @@ func TestDialStateNetRestrict(t *testing.T) {
 	})
 }
 
+func TestDialStateBanned(t *testing.T) {
+	// This table always returns the same random nodes
+	// in the order given below.
+	table := fakeTable{
+		{ID: uintID(1), IP: net.ParseIP("127.0.0.1")},
+		{ID: uintID(2), IP: net.ParseIP("127.0.0.2")},
+		{ID: uintID(3), IP: net.ParseIP("127.0.0.3")},
+	}
+	state := newDialState([]*discover.Node{{ID: uintID(4), IP: net.ParseIP("127.0.0.4")}}, nil, table, 10, nil)
+	state.banned = func(id discover.NodeID) bool { return id == uintID(2) || id == uintID(4) }
+
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: table[0]},
+					&dialTask{flags: dynDialedConn, dest: table[2]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
 // This test checks that static dials are launched.
 func TestDialStateStaticDial(t *testing.T) {
 	wantStatic := []*discover.Node{
@@ -56,6 +56,7 @@ type nodeDB struct {
 var (
 	nodeDBVersionKey = []byte("version") // Version of the database to flush if changes
 	nodeDBItemPrefix = []byte("n:")      // Identifier to prefix node entries with
+	nodeDBBanPrefix  = []byte("ban:")    // Identifier to prefix peer ban entries with
 
 	nodeDBDiscoverRoot      = ":discover"
 	nodeDBDiscoverPing      = nodeDBDiscoverRoot + ":lastping"
@@ -303,6 +304,53 @@ func (db *nodeDB) updateFindFails(id NodeID, fails int) error {
 	return db.storeInt64(makeKey(id, nodeDBDiscoverFindFails), int64(fails))
 }
 
+// banKey generates the leveldb key-blob of a node's ban entry. Bans are kept
+// outside of the node item space so that expiring a node doesn't lift them.
+func banKey(id NodeID) []byte {
+	return append(append([]byte{}, nodeDBBanPrefix...), id[:]...)
+}
+
+// banExpiry retrieves the time until which a node is banned, or the zero time
+// if there is no ban recorded.
+func (db *nodeDB) banExpiry(id NodeID) time.Time {
+	if until := db.fetchInt64(banKey(id)); until != 0 {
+		return time.Unix(until, 0)
+	}
+	return time.Time{}
+}
+
+// updateBan records a ban of a node until the given time. A zero time lifts it.
+func (db *nodeDB) updateBan(id NodeID, until time.Time) error {
+	if until.IsZero() {
+		return db.lvl.Delete(banKey(id), nil)
+	}
+	return db.storeInt64(banKey(id), until.Unix())
+}
+
+// bans retrieves all node bans that are still in effect at the given time,
+// deleting the ones that already expired.
+func (db *nodeDB) bans(now time.Time) map[NodeID]time.Time {
+	it := db.lvl.NewIterator(util.BytesPrefix(nodeDBBanPrefix), nil)
+	defer it.Release()
+
+	bans := make(map[NodeID]time.Time)
+	for it.Next() {
+		var id NodeID
+		if len(it.Key()) != len(nodeDBBanPrefix)+len(id) {
+			continue
+		}
+		copy(id[:], it.Key()[len(nodeDBBanPrefix):])
+
+		val, read := binary.Varint(it.Value())
+		if until := time.Unix(val, 0); read > 0 && until.After(now) {
+			bans[id] = until
+			continue
+		}
+		db.lvl.Delete(it.Key(), nil)
+	}
+	return bans
+}
+
 // querySeeds retrieves random nodes to be used as potential seed nodes
 // for bootstrapping.
 func (db *nodeDB) querySeeds(n int, maxAge time.Duration) []*Node {
@@ -378,3 +378,44 @@ func TestNodeDBSelfExpiration(t *testing.T) {
 		t.Errorf("self not evacuated")
 	}
 }
+
+func TestNodeDBBans(t *testing.T) {
+	db, _ := newNodeDB("", Version, NodeID{})
+	defer db.close()
+
+	var (
+		now     = time.Now()
+		active  = MustHexID("0x1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439")
+		expired = MustHexID("0x2dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439")
+	)
+	if ban := db.banExpiry(active); !ban.IsZero() {
+		t.Errorf("non-existing ban: have %v, want zero time", ban)
+	}
+	if err := db.updateBan(active, now.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to store ban: %v", err)
+	}
+	if err := db.updateBan(expired, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("failed to store ban: %v", err)
+	}
+	if ban := db.banExpiry(active); ban.Unix() != now.Add(time.Hour).Unix() {
+		t.Errorf("ban mismatch: have %v, want %v", ban, now.Add(time.Hour))
+	}
+	// Bans must survive node expiration, but expired bans must be dropped
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	bans := db.bans(now)
+	if len(bans) != 1 || bans[active].IsZero() {
+		t.Errorf("active bans mismatch: have %v, want only %x", bans, active[:8])
+	}
+	if ban := db.banExpiry(expired); !ban.IsZero() {
+		t.Errorf("expired ban not deleted: have %v", ban)
+	}
+	// Lift the remaining ban
+	if err := db.updateBan(active, time.Time{}); err != nil {
+		t.Fatalf("failed to lift ban: %v", err)
+	}
+	if bans := db.bans(now); len(bans) != 0 {
+		t.Errorf("bans left after lifting: %v", bans)
+	}
+}
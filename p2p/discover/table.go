@@ -218,6 +218,17 @@ func (tab *Table) Close() {
 	}
 }
 
+// Ban records in the node database that the given node is banned from
+// connecting until the given time. A zero time lifts the ban.
+func (tab *Table) Ban(id NodeID, until time.Time) error {
+	return tab.db.updateBan(id, until)
+}
+
+// Bans returns all bans recorded in the node database that are still in effect.
+func (tab *Table) Bans() map[NodeID]time.Time {
+	return tab.db.bans(time.Now())
+}
+
 // setFallbackNodes sets the initial points of contact. These nodes
 // are used to connect to the network if the table is empty and there
 // are no known nodes in the database.
@@ -0,0 +1,297 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/common/mclock"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/p2p/discover"
+	"github.com/tomochain/tomochain/p2p/enr"
+)
+
+const (
+	signingKeySeed = 0x111111
+	nodesSeed1     = 0x2945237
+	nodesSeed2     = 0x4567299
+)
+
+func TestClientSyncTree(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 30)
+	tree, url := makeTestTree("n", nodes, []string{"enrtree://AKPYQIUQIL7PSIACI32J7FGZW56E5FKHEFCCOFHILBIMW3M6LWXS2@morenodes.example.org"})
+	r := mapResolver(tree.ToTXT("n"))
+
+	c := NewClient(Config{Resolver: r})
+	stree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !recordsEqual(stree.Nodes(), tree.Nodes()) {
+		t.Errorf("wrong nodes in synced tree")
+	}
+	if !stringsEqual(stree.Links(), tree.Links()) {
+		t.Errorf("wrong links in synced tree: %v", stree.Links())
+	}
+	if stree.Seq() != tree.Seq() || stree.Signature() != tree.Signature() {
+		t.Errorf("wrong root in synced tree: seq %d", stree.Seq())
+	}
+	for name, txt := range stree.ToTXT("n") {
+		if r[name] != txt {
+			t.Errorf("synced tree record %s mismatch", name)
+		}
+	}
+}
+
+// In this test, syncing the tree fails because it contains an invalid ENR entry.
+func TestClientSyncTreeBadNode(t *testing.T) {
+	r := mapResolver{
+		"n":                            "enrtree-root:v1 e=INDMVBZEEQ4ESVYAKGIYU74EAA l=C7HRFPF3BLGF3YR4DY5KX3SMBE seq=3 sig=Vl3AmunLur0JZ3sIyJPSH6A3Vvdp4F40jWQeCmkIhmcgwE4VC5U9wpK8C_uL_CMY29fd6FAhspRvq2z_VysTLAA",
+		"C7HRFPF3BLGF3YR4DY5KX3SMBE.n": "enrtree://AM5FCQLWIZX2QFPNJAP7VUERCCRNGRHWZG3YYHIUV7BVDQ5FDPRT2@morenodes.example.org",
+		"INDMVBZEEQ4ESVYAKGIYU74EAA.n": "enr:-----",
+	}
+	c := NewClient(Config{Resolver: r})
+	_, err := c.SyncTree("enrtree://AKPYQIUQIL7PSIACI32J7FGZW56E5FKHEFCCOFHILBIMW3M6LWXS2@n")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+// In this test, syncing the tree fails because the root is not signed by the
+// key in the tree URL.
+func TestClientSyncTreeBadSignature(t *testing.T) {
+	tree, _ := makeTestTree("n", testNodes(nodesSeed1, 3), nil)
+	other := testKey(nodesSeed2)
+	url := newLinkEntry("n", &other.PublicKey).String()
+
+	c := NewClient(Config{Resolver: mapResolver(tree.ToTXT("n"))})
+	_, err := c.SyncTree(url)
+	if want := (entryError{"root", errInvalidSig}); err != want {
+		t.Fatalf("wrong error %v, want %v", err, want)
+	}
+}
+
+// This test checks that the iterator visits all nodes of the tree and the
+// trees it links to.
+func TestIterator(t *testing.T) {
+	nodes := testNodes(nodesSeed1, 30)
+	linked := testNodes(nodesSeed2, 10)
+	ltree, lurl := makeTestTree("l", linked, nil)
+	tree, url := makeTestTree("n", nodes, []string{lurl})
+	r := mapResolver(tree.ToTXT("n"))
+	r.add(ltree.ToTXT("l"))
+
+	c := NewClient(Config{Resolver: r})
+	it, err := c.NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	checkIterator(t, it, append(nodes, linked...))
+}
+
+// This test checks that the iterator picks up changes to the tree once the
+// root is rechecked.
+func TestIteratorRootRecheck(t *testing.T) {
+	var (
+		clock    = new(mclock.Simulated)
+		nodes    = testNodes(nodesSeed1, 4)
+		resolver = newMapResolver()
+		c        = NewClient(Config{Resolver: resolver, RecheckInterval: 20 * time.Minute})
+	)
+	c.clock = clock
+	tree1, url := makeTestTree("n", nodes[:2], nil)
+	tree2, _ := makeTestTree("n", nodes[2:], nil)
+	resolver.add(tree1.ToTXT("n"))
+
+	it, err := c.NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	checkIterator(t, it, nodes[:2])
+
+	// Update the tree, the iterator only sees the new nodes after the recheck.
+	resolver.clear()
+	resolver.add(tree2.ToTXT("n"))
+	clock.Run(c.cfg.RecheckInterval + 1*time.Second)
+	checkIterator(t, it, nodes[2:])
+}
+
+// This test checks that closing the iterator interrupts a pending Next call.
+func TestIteratorClose(t *testing.T) {
+	_, url := makeTestTree("n", testNodes(nodesSeed1, 1), nil)
+	c := NewClient(Config{Resolver: newMapResolver()})
+	it, err := c.NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan bool)
+	go func() { done <- it.Next() }()
+
+	time.Sleep(50 * time.Millisecond)
+	it.Close()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("Next returned true after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Next did not return after Close")
+	}
+}
+
+func TestIteratorInvalidURL(t *testing.T) {
+	c := NewClient(Config{Resolver: newMapResolver()})
+	if _, err := c.NewIterator("enrtree://nodes.example.org"); err == nil {
+		t.Fatal("expected error for URL without public key")
+	}
+}
+
+// checkIterator reads nodes from the iterator until all wanted nodes have been seen.
+func checkIterator(t *testing.T, it *Iterator, wantNodes []*enr.Record) {
+	t.Helper()
+
+	want := make(map[discover.NodeID]bool)
+	for _, r := range wantNodes {
+		n, err := nodeFromRecord(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[n.ID] = true
+	}
+	for i := 0; i < len(wantNodes)*20 && len(want) > 0; i++ {
+		if !it.Next() {
+			t.Fatal("iterator closed unexpectedly")
+		}
+		delete(want, it.Node().ID)
+	}
+	if len(want) > 0 {
+		t.Fatalf("%d nodes were not visited", len(want))
+	}
+}
+
+func makeTestTree(domain string, nodes []*enr.Record, links []string) (*Tree, string) {
+	tree, err := MakeTree(1, nodes, links)
+	if err != nil {
+		panic(err)
+	}
+	url, err := tree.Sign(testKey(signingKeySeed), domain)
+	if err != nil {
+		panic(err)
+	}
+	return tree, url
+}
+
+// testKeys creates deterministic private keys for testing.
+func testKeys(seed int64, n int) []*ecdsa.PrivateKey {
+	rand := rand.New(rand.NewSource(seed))
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := 0; i < n; i++ {
+		seed := make([]byte, 32)
+		rand.Read(seed)
+		key, err := crypto.ToECDSA(seed)
+		if err != nil {
+			panic("can't generate key: " + err.Error())
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func testKey(seed int64) *ecdsa.PrivateKey {
+	return testKeys(seed, 1)[0]
+}
+
+func testNodes(seed int64, n int) []*enr.Record {
+	keys := testKeys(seed, n)
+	nodes := make([]*enr.Record, n)
+	for i, key := range keys {
+		var r enr.Record
+		r.Set(enr.IP4(net.IP{127, 0, 0, byte(i)}))
+		r.Set(enr.TCP(30303))
+		r.Set(enr.UDP(30303))
+		if err := r.Sign(key); err != nil {
+			panic(err)
+		}
+		nodes[i] = &r
+	}
+	return nodes
+}
+
+func mustDecodeB64(s string) []byte {
+	b, err := b64format.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func recordsEqual(a, b []*enr.Record) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		ta, _ := a[i].MarshalText()
+		tb, _ := b[i].MarshalText()
+		if string(ta) != string(tb) {
+			return false
+		}
+	}
+	return true
+}
+
+func stringsEqual(a, b []string) bool {
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+// mapResolver is an in-process stand-in for DNS, serving TXT records from a map.
+type mapResolver map[string]string
+
+func newMapResolver(maps ...map[string]string) mapResolver {
+	mr := make(mapResolver)
+	for _, m := range maps {
+		mr.add(m)
+	}
+	return mr
+}
+
+func (mr mapResolver) clear() {
+	for k := range mr {
+		delete(mr, k)
+	}
+}
+
+func (mr mapResolver) add(m map[string]string) {
+	for k, v := range m {
+		mr[k] = v
+	}
+}
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, errors.New("not found")
+}
@@ -30,6 +30,7 @@ package enr
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -54,6 +55,7 @@ var (
 	errTooBig         = fmt.Errorf("record bigger than %d bytes", SizeLimit)
 	errEncodeUnsigned = errors.New("can't encode unsigned record")
 	errNotFound       = errors.New("no such key in record")
+	errMissingPrefix  = errors.New("missing 'enr:' prefix for text record")
 )
 
 // Record represents a node record. The zero value is an empty record.
@@ -205,6 +207,30 @@ func (r *Record) DecodeRLP(s *rlp.Stream) error {
 	return nil
 }
 
+// textPrefix is the prefix of the text form of a record.
+const textPrefix = "enr:"
+
+// MarshalText implements encoding.TextMarshaler. The text form of a record is its
+// RLP encoding in URL-safe base64 without padding, prefixed with "enr:".
+func (r Record) MarshalText() ([]byte, error) {
+	if !r.Signed() {
+		return nil, errEncodeUnsigned
+	}
+	return []byte(textPrefix + base64.RawURLEncoding.EncodeToString(r.raw)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Decoding verifies the signature.
+func (r *Record) UnmarshalText(text []byte) error {
+	if !bytes.HasPrefix(text, []byte(textPrefix)) {
+		return errMissingPrefix
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(string(text[len(textPrefix):]))
+	if err != nil {
+		return err
+	}
+	return rlp.DecodeBytes(raw, r)
+}
+
 type s256raw []byte
 
 func (s256raw) ENRKey() string { return "secp256k1" }
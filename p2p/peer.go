@@ -163,7 +163,16 @@ func (p *Peer) String() string {
 
 // Inbound returns true if the peer is an inbound connection
 func (p *Peer) Inbound() bool {
-	return p.rw.flags&inboundConn != 0
+	return p.rw.is(inboundConn)
+}
+
+// setTrusted marks the connection to the peer, and to its pair if there is
+// one, as trusted or not.
+func (p *Peer) setTrusted(trusted bool) {
+	p.rw.set(trustedConn, trusted)
+	if p.PairPeer != nil {
+		p.PairPeer.rw.set(trustedConn, trusted)
+	}
 }
 
 func newPeer(conn *conn, protocols []Protocol) *Peer {
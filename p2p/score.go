@@ -0,0 +1,416 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tomochain/tomochain/metrics"
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+// Misbehaviour is a kind of peer misbehaviour reported into the peer scoring of
+// the server by the protocols running on top of it.
+type Misbehaviour uint8
+
+const (
+	InvalidMessage   Misbehaviour = iota // Malformed or protocol violating message
+	InvalidBlock                         // Block or header failing verification
+	InvalidOrder                         // TomoX order failing validation
+	InvalidLendingTx                     // TomoX lending transaction failing validation
+	StalledDelivery                      // Requested data not delivered in time
+	UselessResponse                      // Delivered data not matching the request
+	DuplicateMessage                     // Data already announced by the peer resent
+	numMisbehaviours
+)
+
+// misbehaviourWeights is the score penalty applied for each misbehaviour.
+var misbehaviourWeights = [numMisbehaviours]float64{
+	InvalidMessage:   50,
+	InvalidBlock:     100,
+	InvalidOrder:     10,
+	InvalidLendingTx: 10,
+	StalledDelivery:  20,
+	UselessResponse:  10,
+	DuplicateMessage: 1,
+}
+
+var misbehaviourNames = [numMisbehaviours]string{
+	InvalidMessage:   "invalid message",
+	InvalidBlock:     "invalid block",
+	InvalidOrder:     "invalid order",
+	InvalidLendingTx: "invalid lending tx",
+	StalledDelivery:  "stalled delivery",
+	UselessResponse:  "useless response",
+	DuplicateMessage: "duplicate message",
+}
+
+func (m Misbehaviour) String() string {
+	if m < numMisbehaviours {
+		return misbehaviourNames[m]
+	}
+	return "unknown misbehaviour"
+}
+
+const (
+	defaultScoreHalfLife = 10 * time.Minute
+	defaultDropScore     = -50
+	defaultBanScore      = -100
+	defaultBanDuration   = time.Hour
+
+	// maxTrackedScores is the number of peers whose score is remembered. Once
+	// exceeded, the scores closest to neutral are forgotten first.
+	maxTrackedScores = 1024
+)
+
+var (
+	scoreDropMeter = metrics.NewRegisteredMeter("p2p/score/drops", nil)
+	scoreBanMeter  = metrics.NewRegisteredMeter("p2p/score/bans", nil)
+)
+
+// ScoreConfig holds the peer scoring options. Zero values select the defaults.
+type ScoreConfig struct {
+	// HalfLife is the time it takes for a score to decay halfway back to zero.
+	HalfLife time.Duration `toml:",omitempty"`
+
+	// DropScore is the score at or below which a peer is disconnected.
+	DropScore float64 `toml:",omitempty"`
+
+	// BanScore is the score at or below which a peer is banned.
+	BanScore float64 `toml:",omitempty"`
+
+	// BanDuration is how long a peer is refused connections once banned.
+	BanDuration time.Duration `toml:",omitempty"`
+}
+
+func (c ScoreConfig) withDefaults() ScoreConfig {
+	if c.HalfLife <= 0 {
+		c.HalfLife = defaultScoreHalfLife
+	}
+	if c.DropScore == 0 {
+		c.DropScore = defaultDropScore
+	}
+	if c.BanScore == 0 {
+		c.BanScore = defaultBanScore
+	}
+	if c.BanDuration <= 0 {
+		c.BanDuration = defaultBanDuration
+	}
+	return c
+}
+
+// PeerScoreInfo represents a short summary of the reputation of a peer.
+type PeerScoreInfo struct {
+	ID      string            `json:"id"`      // Unique node identifier
+	Score   float64           `json:"score"`   // Current (decayed) score, zero is neutral
+	Updated time.Time         `json:"updated"` // Time of the last reported misbehaviour
+	Faults  map[string]uint64 `json:"faults"`  // Number of reports per misbehaviour
+}
+
+// BanInfo represents a peer refused connections until a point in time.
+type BanInfo struct {
+	ID    string    `json:"id"`    // Unique node identifier
+	Until time.Time `json:"until"` // Time the ban is lifted
+}
+
+// banStore persists bans across restarts. It is implemented by the discovery
+// table, which keeps them in the node database.
+type banStore interface {
+	Ban(id discover.NodeID, until time.Time) error
+	Bans() map[discover.NodeID]time.Time
+}
+
+// scoreAction is the consequence of a misbehaviour report.
+type scoreAction int
+
+const (
+	scoreKeep scoreAction = iota
+	scoreDrop
+	scoreBan
+)
+
+type peerScore struct {
+	value   float64
+	updated time.Time
+	faults  [numMisbehaviours]uint64
+}
+
+// peerScoring keeps the reputation of peers and the bans derived from it.
+type peerScoring struct {
+	cfg    ScoreConfig
+	store  banStore                 // Optional persistence of bans
+	exempt map[discover.NodeID]bool // Peers never banned automatically
+	now    func() time.Time
+
+	lock   sync.Mutex
+	scores map[discover.NodeID]*peerScore
+	bans   map[discover.NodeID]time.Time
+}
+
+func newPeerScoring(cfg ScoreConfig, store banStore, exempt []*discover.Node) *peerScoring {
+	ps := &peerScoring{
+		cfg:    cfg.withDefaults(),
+		store:  store,
+		exempt: make(map[discover.NodeID]bool, len(exempt)),
+		now:    time.Now,
+		scores: make(map[discover.NodeID]*peerScore),
+		bans:   make(map[discover.NodeID]time.Time),
+	}
+	for _, n := range exempt {
+		ps.exempt[n.ID] = true
+	}
+	if store != nil {
+		for id, until := range store.Bans() {
+			ps.bans[id] = until
+		}
+	}
+	return ps
+}
+
+// decay brings a score towards zero according to the time passed since it was
+// last updated.
+func (ps *peerScoring) decay(s *peerScore, now time.Time) float64 {
+	elapsed := now.Sub(s.updated)
+	if elapsed <= 0 {
+		return s.value
+	}
+	return s.value * math.Exp2(-float64(elapsed)/float64(ps.cfg.HalfLife))
+}
+
+// report applies the penalty of a misbehaviour to the score of a peer and
+// returns the new score along with what should happen to the peer.
+func (ps *peerScoring) report(id discover.NodeID, m Misbehaviour) (float64, scoreAction) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	now := ps.now()
+	s := ps.scores[id]
+	if s == nil {
+		if len(ps.scores) >= maxTrackedScores {
+			ps.evict(now)
+		}
+		s = new(peerScore)
+		ps.scores[id] = s
+	}
+	s.value = ps.decay(s, now)
+	if m < numMisbehaviours {
+		s.value -= misbehaviourWeights[m]
+		s.faults[m]++
+	}
+	s.updated = now
+
+	switch {
+	case s.value <= ps.cfg.BanScore && !ps.exempt[id]:
+		return s.value, scoreBan
+	case s.value <= ps.cfg.DropScore:
+		return s.value, scoreDrop
+	default:
+		return s.value, scoreKeep
+	}
+}
+
+// evict forgets the score closest to neutral to make room for a new one.
+func (ps *peerScoring) evict(now time.Time) {
+	var (
+		victim discover.NodeID
+		best   = math.Inf(-1)
+	)
+	for id, s := range ps.scores {
+		if v := ps.decay(s, now); v > best {
+			victim, best = id, v
+		}
+	}
+	delete(ps.scores, victim)
+}
+
+// ban refuses connections from and to a peer until the given time.
+func (ps *peerScoring) ban(id discover.NodeID, until time.Time) error {
+	ps.lock.Lock()
+	ps.bans[id] = until
+	ps.lock.Unlock()
+
+	if ps.store != nil {
+		return ps.store.Ban(id, until)
+	}
+	return nil
+}
+
+// unban lifts the ban of a peer and resets its score.
+func (ps *peerScoring) unban(id discover.NodeID) error {
+	ps.lock.Lock()
+	delete(ps.bans, id)
+	delete(ps.scores, id)
+	ps.lock.Unlock()
+
+	if ps.store != nil {
+		return ps.store.Ban(id, time.Time{})
+	}
+	return nil
+}
+
+// banned reports whether a peer is currently banned.
+func (ps *peerScoring) banned(id discover.NodeID) bool {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	until, ok := ps.bans[id]
+	if !ok {
+		return false
+	}
+	if !until.After(ps.now()) {
+		delete(ps.bans, id)
+		return false
+	}
+	return true
+}
+
+// scoreInfos returns the current scores of all tracked peers, worst first.
+func (ps *peerScoring) scoreInfos() []*PeerScoreInfo {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	now := ps.now()
+	infos := make([]*PeerScoreInfo, 0, len(ps.scores))
+	for id, s := range ps.scores {
+		info := &PeerScoreInfo{
+			ID:      id.String(),
+			Score:   ps.decay(s, now),
+			Updated: s.updated,
+			Faults:  make(map[string]uint64),
+		}
+		for m, n := range s.faults {
+			if n > 0 {
+				info.Faults[Misbehaviour(m).String()] = n
+			}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Score < infos[j].Score })
+	return infos
+}
+
+// banInfos returns all bans still in effect, soonest lifted first.
+func (ps *peerScoring) banInfos() []*BanInfo {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	now := ps.now()
+	infos := make([]*BanInfo, 0, len(ps.bans))
+	for id, until := range ps.bans {
+		if !until.After(now) {
+			delete(ps.bans, id)
+			continue
+		}
+		infos = append(infos, &BanInfo{ID: id.String(), Until: until})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Until.Before(infos[j].Until) })
+	return infos
+}
+
+// ReportPeer applies the penalty of a misbehaviour to the score of a peer. The
+// peer is disconnected once its score falls to the drop threshold, and banned
+// for a while once it falls to the ban threshold. Trusted peers are never
+// banned automatically.
+func (srv *Server) ReportPeer(id discover.NodeID, m Misbehaviour) {
+	scoring := srv.peerScoring()
+	if scoring == nil {
+		return
+	}
+	score, action := scoring.report(id, m)
+	switch action {
+	case scoreBan:
+		until := time.Now().Add(scoring.cfg.BanDuration)
+		srv.log.Debug("Banning misbehaving peer", "id", id, "fault", m, "score", score, "until", until)
+		if err := scoring.ban(id, until); err != nil {
+			srv.log.Warn("Failed to persist peer ban", "id", id, "err", err)
+		}
+		scoreBanMeter.Mark(1)
+		srv.disconnect(id, DiscUselessPeer)
+	case scoreDrop:
+		srv.log.Debug("Dropping misbehaving peer", "id", id, "fault", m, "score", score)
+		scoreDropMeter.Mark(1)
+		srv.disconnect(id, DiscUselessPeer)
+	default:
+		srv.log.Trace("Peer misbehaved", "id", id, "fault", m, "score", score)
+	}
+}
+
+// PeerScores returns the scores of all peers that misbehaved recently.
+func (srv *Server) PeerScores() []*PeerScoreInfo {
+	if scoring := srv.peerScoring(); scoring != nil {
+		return scoring.scoreInfos()
+	}
+	return nil
+}
+
+// Bans returns all peers currently refused connections.
+func (srv *Server) Bans() []*BanInfo {
+	if scoring := srv.peerScoring(); scoring != nil {
+		return scoring.banInfos()
+	}
+	return nil
+}
+
+// BanPeer disconnects a peer and refuses connections from and to it for the
+// given duration, or the configured ban duration if zero.
+func (srv *Server) BanPeer(id discover.NodeID, duration time.Duration) error {
+	scoring := srv.peerScoring()
+	if scoring == nil {
+		return errServerStopped
+	}
+	if duration <= 0 {
+		duration = scoring.cfg.BanDuration
+	}
+	err := scoring.ban(id, time.Now().Add(duration))
+	srv.disconnect(id, DiscRequested)
+	return err
+}
+
+// UnbanPeer lifts the ban of a peer and resets its score.
+func (srv *Server) UnbanPeer(id discover.NodeID) error {
+	scoring := srv.peerScoring()
+	if scoring == nil {
+		return errServerStopped
+	}
+	return scoring.unban(id)
+}
+
+func (srv *Server) peerScoring() *peerScoring {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		return nil
+	}
+	return srv.scoring
+}
+
+// disconnect drops the connection to a peer, if there is one.
+func (srv *Server) disconnect(id discover.NodeID, reason DiscReason) {
+	select {
+	case srv.peerOp <- func(peers map[discover.NodeID]*Peer) {
+		if p := peers[id]; p != nil {
+			p.Disconnect(reason)
+		}
+	}:
+		<-srv.peerOpDone
+	case <-srv.quit:
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tomochain/tomochain/p2p/discover"
+)
+
+type memoryBanStore map[discover.NodeID]time.Time
+
+func (s memoryBanStore) Ban(id discover.NodeID, until time.Time) error {
+	if until.IsZero() {
+		delete(s, id)
+	} else {
+		s[id] = until
+	}
+	return nil
+}
+
+func (s memoryBanStore) Bans() map[discover.NodeID]time.Time {
+	bans := make(map[discover.NodeID]time.Time)
+	for id, until := range s {
+		bans[id] = until
+	}
+	return bans
+}
+
+func newTestScoring(store banStore, exempt ...*discover.Node) (*peerScoring, *time.Time) {
+	now := time.Unix(1600000000, 0)
+	ps := newPeerScoring(ScoreConfig{}, store, exempt)
+	ps.now = func() time.Time { return now }
+	return ps, &now
+}
+
+func TestPeerScoringThresholds(t *testing.T) {
+	var (
+		ps, _  = newTestScoring(nil)
+		id     = randomID()
+		checks = []struct {
+			fault  Misbehaviour
+			score  float64
+			action scoreAction
+		}{
+			{DuplicateMessage, -1, scoreKeep},
+			{StalledDelivery, -21, scoreKeep},
+			{StalledDelivery, -41, scoreKeep},
+			{UselessResponse, -51, scoreDrop},
+			{InvalidMessage, -101, scoreBan},
+		}
+	)
+	for i, check := range checks {
+		score, action := ps.report(id, check.fault)
+		if score != check.score || action != check.action {
+			t.Errorf("report %d (%v): have score %v action %d, want score %v action %d", i, check.fault, score, action, check.score, check.action)
+		}
+	}
+	infos := ps.scoreInfos()
+	if len(infos) != 1 || infos[0].Faults[StalledDelivery.String()] != 2 {
+		t.Errorf("score infos mismatch: %+v", infos)
+	}
+}
+
+func TestPeerScoringExempt(t *testing.T) {
+	var (
+		node  = &discover.Node{ID: randomID()}
+		ps, _ = newTestScoring(nil, node)
+	)
+	if _, action := ps.report(node.ID, InvalidBlock); action != scoreDrop {
+		t.Errorf("exempt peer action mismatch: have %d, want %d", action, scoreDrop)
+	}
+}
+
+func TestPeerScoringDecay(t *testing.T) {
+	var (
+		ps, now = newTestScoring(nil)
+		id      = randomID()
+	)
+	ps.report(id, InvalidBlock)
+
+	*now = now.Add(defaultScoreHalfLife)
+	if score, action := ps.report(id, DuplicateMessage); score != -51 || action != scoreDrop {
+		t.Errorf("decayed score mismatch: have %v action %d, want -51 action %d", score, action, scoreDrop)
+	}
+	*now = now.Add(10 * defaultScoreHalfLife)
+	if score, action := ps.report(id, DuplicateMessage); score > -1 || score < -1.1 || action != scoreKeep {
+		t.Errorf("decayed score mismatch: have %v action %d, want ~-1 action %d", score, action, scoreKeep)
+	}
+}
+
+func TestPeerScoringEviction(t *testing.T) {
+	var (
+		ps, _ = newTestScoring(nil)
+		worst = randomID()
+	)
+	ps.report(worst, InvalidBlock)
+	for i := 0; i < maxTrackedScores; i++ {
+		ps.report(randomID(), DuplicateMessage)
+	}
+	if len(ps.scores) != maxTrackedScores {
+		t.Errorf("tracked scores mismatch: have %d, want %d", len(ps.scores), maxTrackedScores)
+	}
+	if ps.scores[worst] == nil {
+		t.Errorf("worst score evicted")
+	}
+}
+
+func TestPeerScoringBans(t *testing.T) {
+	var (
+		store   = make(memoryBanStore)
+		ps, now = newTestScoring(store)
+		id      = randomID()
+	)
+	if err := ps.ban(id, now.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to ban: %v", err)
+	}
+	if !ps.banned(id) {
+		t.Errorf("peer not banned")
+	}
+	if _, ok := store[id]; !ok {
+		t.Errorf("ban not persisted")
+	}
+	// Bans must be restored from the store
+	restored, rnow := newTestScoring(store)
+	*rnow = *now
+	if !restored.banned(id) {
+		t.Errorf("ban not restored")
+	}
+	// Bans must be lifted once expired
+	*rnow = now.Add(time.Hour)
+	if restored.banned(id) {
+		t.Errorf("expired ban still in effect")
+	}
+	// Unbanning must drop the persisted ban too
+	if err := ps.unban(id); err != nil {
+		t.Fatalf("failed to unban: %v", err)
+	}
+	if ps.banned(id) || len(store) != 0 {
+		t.Errorf("ban still in effect after unban")
+	}
+}
+
+func TestServerReportPeer(t *testing.T) {
+	connected := make(chan *Peer, 1)
+	remid := randomID()
+	srv := startTestServer(t, remid, func(p *Peer) { connected <- p })
+	defer srv.Stop()
+
+	conn, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("server did not accept within one second")
+	}
+	// Misbehaving badly enough must disconnect and ban the peer
+	srv.ReportPeer(remid, InvalidBlock)
+	for start := time.Now(); srv.PeerCount() != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatal("peer not disconnected within one second")
+		}
+	}
+	if bans := srv.Bans(); len(bans) != 1 || bans[0].ID != remid.String() {
+		t.Fatalf("bans mismatch: %+v", bans)
+	}
+	if scores := srv.PeerScores(); len(scores) != 1 || scores[0].Score > -99 {
+		t.Fatalf("scores mismatch: %+v", scores)
+	}
+	// A banned peer must not be able to reconnect
+	conn2, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn2.Close()
+
+	select {
+	case <-connected:
+		t.Fatal("banned peer accepted")
+	case <-time.After(200 * time.Millisecond):
+	}
+	// Unless it is unbanned
+	if err := srv.UnbanPeer(remid); err != nil {
+		t.Fatalf("failed to unban: %v", err)
+	}
+	conn3, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn3.Close()
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("unbanned peer not accepted within one second")
+	}
+}
@@ -378,6 +378,53 @@ func TestServerAtCap(t *testing.T) {
 		t.Error("Server did not set trusted flag")
 	}
 
+	// Remove from trusted set and try again
+	srv.RemoveTrustedPeer(&discover.Node{ID: trustedID})
+	c = newconn(trustedID)
+	if err := srv.checkpoint(c, srv.posthandshake); err != DiscTooManyPeers {
+		t.Error("wrong error for insert:", err)
+	}
+
+	// Add anotherID to trusted set and try again
+	anotherID := randomID()
+	srv.AddTrustedPeer(&discover.Node{ID: anotherID})
+	c = newconn(anotherID)
+	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
+		t.Error("unexpected error for trusted conn @posthandshake:", err)
+	}
+	if !c.is(trustedConn) {
+		t.Error("Server did not set trusted flag")
+	}
+}
+
+func TestServerTrustedPeerFlag(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	id := randomID()
+	fd, _ := net.Pipe()
+	c := &conn{fd: fd, transport: newTestTransport(id, fd), flags: inboundConn, id: id, cont: make(chan error)}
+	if err := srv.checkpoint(c, srv.addpeer); err != nil {
+		t.Fatalf("could not add conn: %v", err)
+	}
+	// Trusting a connected peer must mark its connection
+	srv.AddTrustedPeer(&discover.Node{ID: id})
+	if peers := srv.Peers(); len(peers) != 1 || !peers[0].Info().Network.Trusted {
+		t.Error("connected peer not marked trusted")
+	}
+	srv.RemoveTrustedPeer(&discover.Node{ID: id})
+	if peers := srv.Peers(); len(peers) != 1 || peers[0].Info().Network.Trusted {
+		t.Error("connected peer still marked trusted")
+	}
 }
 
 func TestServerSetupConn(t *testing.T) {
@@ -213,6 +213,8 @@ type ChainConfig struct {
 	TIPTomoXBlock                *big.Int `json:"tipTomoXBlock,omitempty"`                // TIPTomoX switch block (nil = no fork, 0 = already activated)
 	TIPTomoXLendingBlock         *big.Int `json:"tipTomoXLendingBlock,omitempty"`         // TIPTomoXLending switch block (nil = no fork, 0 = already activated)
 	TIPTomoXCancellationFeeBlock *big.Int `json:"tipTomoXCancellationFeeBlock,omitempty"` // TIPTomoXCancellationFee switch block (nil = no fork, 0 = already activated)
+	TIPAccessListBlock           *big.Int `json:"tipAccessListBlock,omitempty"`           // TIPAccessList switch block (nil = no fork, 0 = already activated)
+	TIPSponsoredTxBlock          *big.Int `json:"tipSponsoredTxBlock,omitempty"`          // TIPSponsoredTx switch block (nil = no fork, 0 = already activated)
 
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
@@ -353,6 +355,68 @@ func (c *ChainConfig) IsTIPTomoXCancellationFee(num *big.Int) bool {
 	return isForked(common.TIPTomoXCancellationFeeBlock, num)
 }
 
+// IsTIPTomoXLendingPartialRepay returns whether num is either equal to the
+// partial repayment fork block or greater. The fork lets a repay item settle
+// only part of a lending trade.
+func (c *ChainConfig) IsTIPTomoXLendingPartialRepay(num *big.Int) bool {
+	return isForked(common.TIPTomoXLendingPartialRepayBlock, num)
+}
+
+// IsTIPTomoXLendingOracle returns whether num is either equal to the lending
+// price oracle fork block or greater. The fork prices collaterals with an
+// oracle configured in the lending registration contract.
+func (c *ChainConfig) IsTIPTomoXLendingOracle(num *big.Int) bool {
+	return isForked(common.TIPTomoXLendingOracleBlock, num)
+}
+
+// IsTIPTomoXLendingAuction returns whether num is either equal to the auction
+// liquidation fork block or greater. The fork sells the collateral of a
+// liquidated trade in an auction if the collateral has a liquidation penalty.
+func (c *ChainConfig) IsTIPTomoXLendingAuction(num *big.Int) bool {
+	return isForked(common.TIPTomoXLendingAuctionBlock, num)
+}
+
+// IsTIPTomoXLendingPool returns whether num is either equal to the lending pool
+// fork block or greater. The fork enables the variable-rate lending pools.
+func (c *ChainConfig) IsTIPTomoXLendingPool(num *big.Int) bool {
+	return isForked(common.TIPTomoXLendingPoolBlock, num)
+}
+
+// IsTIPTomoXLendingMargin returns whether num is either equal to the portfolio
+// margin fork block or greater. The fork enables margin accounts pooling the
+// collaterals of a borrower across its lending trades.
+func (c *ChainConfig) IsTIPTomoXLendingMargin(num *big.Int) bool {
+	return isForked(common.TIPTomoXLendingMarginBlock, num)
+}
+
+// IsTIPTomoXLendingRollover returns whether num is either equal to the lending
+// rollover fork block or greater. The fork lets borrowers and investors extend
+// a lending trade to a new term and interest.
+func (c *ChainConfig) IsTIPTomoXLendingRollover(num *big.Int) bool {
+	return isForked(common.TIPTomoXLendingRolloverBlock, num)
+}
+
+// IsTIPTomoXSelfTradePrevention returns whether num is either equal to the
+// self-trade prevention fork block or greater. The fork stops an order of a user
+// from matching the resting orders of the same user in the order books.
+func (c *ChainConfig) IsTIPTomoXSelfTradePrevention(num *big.Int) bool {
+	return isForked(common.TIPTomoXSelfTradePreventionBlock, num)
+}
+
+// IsTIPAccessList returns whether num is either equal to the TIPAccessList fork
+// block or greater. The fork enables typed transactions with access lists and
+// the warm/cold state access gas schedule.
+func (c *ChainConfig) IsTIPAccessList(num *big.Int) bool {
+	return isForked(c.TIPAccessListBlock, num)
+}
+
+// IsTIPSponsoredTx returns whether num is either equal to the TIPSponsoredTx
+// fork block or greater. The fork enables transactions whose fee is paid by a
+// separate, co-signing fee payer.
+func (c *ChainConfig) IsTIPSponsoredTx(num *big.Int) bool {
+	return isForked(c.TIPSponsoredTxBlock, num)
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -440,6 +504,12 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.TIPTomoXCancellationFeeBlock, newcfg.TIPTomoXCancellationFeeBlock, head) {
 		return newCompatError("TIPTomoXCancellationFee fork block", c.TIPTomoXCancellationFeeBlock, newcfg.TIPTomoXCancellationFeeBlock)
 	}
+	if isForkIncompatible(c.TIPAccessListBlock, newcfg.TIPAccessListBlock, head) {
+		return newCompatError("TIPAccessList fork block", c.TIPAccessListBlock, newcfg.TIPAccessListBlock)
+	}
+	if isForkIncompatible(c.TIPSponsoredTxBlock, newcfg.TIPSponsoredTxBlock, head) {
+		return newCompatError("TIPSponsoredTx fork block", c.TIPSponsoredTxBlock, newcfg.TIPSponsoredTxBlock)
+	}
 	return nil
 }
 
@@ -509,7 +579,8 @@ type Rules struct {
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
 	IsTIP2019, IsTIPSigning, IsTIPRandomize, IsBlackListHF  bool
 	IsTIPTRC21Fee, IsTIPTomoX, IsTIPTomoXLending            bool
-	IsTIPTomoXCancellationFee                               bool
+	IsTIPTomoXCancellationFee, IsTIPAccessList              bool
+	IsTIPSponsoredTx                                        bool
 }
 
 func (c *ChainConfig) Rules(num *big.Int) Rules {
@@ -535,5 +606,7 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsTIPTomoX:                c.IsTIPTomoX(num),
 		IsTIPTomoXLending:         c.IsTIPTomoXLending(num),
 		IsTIPTomoXCancellationFee: c.IsTIPTomoXCancellationFee(num),
+		IsTIPAccessList:           c.IsTIPAccessList(num),
+		IsTIPSponsoredTx:          c.IsTIPSponsoredTx(num),
 	}
 }
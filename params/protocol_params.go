@@ -107,6 +107,13 @@ const (
 	SstoreCleanRefundEIP2200 uint64 = 4200  // Once per SSTORE operation for resetting to the original non-zero value
 	SstoreClearRefundEIP2200 uint64 = 15000 // Once per SSTORE operation for clearing an originally existing storage slot
 
+	ColdAccountAccessCostEIP2929 uint64 = 2600 // Cost of accessing an account not yet in the access list
+	ColdSloadCostEIP2929         uint64 = 2100 // Cost of loading a storage slot not yet in the access list
+	WarmStorageReadCostEIP2929   uint64 = 100  // Cost of reading an account or storage slot already in the access list
+
+	TxAccessListAddressGas    uint64 = 2400 // Per address specified in an EIP 2930 access list
+	TxAccessListStorageKeyGas uint64 = 1900 // Per storage key specified in an EIP 2930 access list
+
 	Create2Gas               uint64 = 32000 // Once per CREATE2 operation
 	SelfdestructRefundGas    uint64 = 24000 // Refunded following a selfdestruct operation.
 	TxDataNonZeroGasFrontier uint64 = 68    // Per byte of data attached to a transaction that is not equal to zero. NOTE: Not payable on data of calls between transactions.
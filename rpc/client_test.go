@@ -49,6 +49,40 @@ func TestClientRequest(t *testing.T) {
 	}
 }
 
+type testDataError struct{}
+
+func (e *testDataError) Error() string          { return "test error" }
+func (e *testDataError) ErrorCode() int         { return 3 }
+func (e *testDataError) ErrorData() interface{} { return "0xdeadbeef" }
+
+type DataErrorService struct{}
+
+func (s *DataErrorService) Fail() (string, error) {
+	return "", &testDataError{}
+}
+
+func TestClientErrorData(t *testing.T) {
+	server := newTestServer("service", new(DataErrorService))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp string
+	err := client.Call(&resp, "service_fail")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if err.Error() != "test error" {
+		t.Errorf("wrong error message: %q", err.Error())
+	}
+	if code := err.(Error).ErrorCode(); code != 3 {
+		t.Errorf("wrong error code: have %d, want 3", code)
+	}
+	if data := err.(DataError).ErrorData(); data != "0xdeadbeef" {
+		t.Errorf("wrong error data: have %v, want 0xdeadbeef", data)
+	}
+}
+
 func TestClientBatchRequest(t *testing.T) {
 	server := newTestServer("service", new(Service))
 	defer server.Stop()
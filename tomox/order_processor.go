@@ -30,6 +30,12 @@ func (tomox *TomoX) CommitOrder(header *types.Header, coinbase common.Address, c
 }
 
 func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+	return tomox.applyOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order, true)
+}
+
+// applyOrder processes an order against the given states, the signature of the
+// order is only checked if signed is set.
+func (tomox *TomoX) applyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem, signed bool) ([]map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		rejects []*tradingstate.OrderItem
 		trades  []map[string]string
@@ -56,7 +62,12 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 		}
 	}()
 
-	if err := order.VerifyOrder(statedb); err != nil {
+	verify := order.VerifyUnsignedOrder
+	if signed {
+		signer := types.MakeOrderSigner(chain.Config(), header.Number)
+		verify = func(statedb *state.StateDB) error { return order.VerifyOrder(statedb, signer) }
+	}
+	if err := verify(statedb); err != nil {
 		rejects = append(rejects, order)
 		return trades, rejects, nil
 	}
@@ -84,7 +95,7 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 	// if we do not use auto-increment orderid, we must set price slot to avoid conflict
 	if orderType == tradingstate.Market {
 		log.Debug("Process maket order", "side", order.Side, "quantity", order.Quantity, "price", order.Price)
-		trades, rejects, err = tomox.processMarketOrder(coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		trades, rejects, err = tomox.processMarketOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
 		if err != nil {
 			log.Debug("Reject market order", "err", err, "order", tradingstate.ToJSON(order))
 			trades = []map[string]string{}
@@ -92,7 +103,7 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 		}
 	} else {
 		log.Debug("Process limit order", "side", order.Side, "quantity", order.Quantity, "price", order.Price)
-		trades, rejects, err = tomox.processLimitOrder(coinbase, chain, statedb, tradingStateDB, orderBook, order)
+		trades, rejects, err = tomox.processLimitOrder(header, coinbase, chain, statedb, tradingStateDB, orderBook, order)
 		if err != nil {
 			log.Debug("Reject limit order", "err", err, "order", tradingstate.ToJSON(order))
 			trades = []map[string]string{}
@@ -104,7 +115,7 @@ func (tomox *TomoX) ApplyOrder(header *types.Header, coinbase common.Address, ch
 }
 
 // processMarketOrder : process the market order
-func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+func (tomox *TomoX) processMarketOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		trades     []map[string]string
 		newTrades  []map[string]string
@@ -120,7 +131,7 @@ func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.
 		bestPrice, volume := tradingStateDB.GetBestAskPrice(orderBook)
 		log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && bestPrice.Cmp(zero) > 0 {
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, bestPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, bestPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -133,7 +144,7 @@ func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.
 		bestPrice, volume := tradingStateDB.GetBestBidPrice(orderBook)
 		log.Debug("processMarketOrder ", "side", side, "bestPrice", bestPrice, "quantityToTrade", quantityToTrade, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && bestPrice.Cmp(zero) > 0 {
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, bestPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, bestPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -148,7 +159,7 @@ func (tomox *TomoX) processMarketOrder(coinbase common.Address, chain consensus.
 
 // processLimitOrder : process the limit order, can change the quote
 // If not care for performance, we should make a copy of quote to prevent further reference problem
-func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
+func (tomox *TomoX) processLimitOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, order *tradingstate.OrderItem) ([]map[string]string, []*tradingstate.OrderItem, error) {
 	var (
 		trades     []map[string]string
 		newTrades  []map[string]string
@@ -168,7 +179,7 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 		log.Debug("processLimitOrder ", "side", side, "minPrice", minPrice, "orderPrice", price, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && price.Cmp(minPrice) >= 0 && minPrice.Cmp(zero) > 0 {
 			log.Debug("Min price in asks tree", "price", minPrice.String())
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, minPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Ask, orderBook, minPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -183,7 +194,7 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 		log.Debug("processLimitOrder ", "side", side, "maxPrice", maxPrice, "orderPrice", price, "volume", volume)
 		for quantityToTrade.Cmp(zero) > 0 && price.Cmp(maxPrice) <= 0 && maxPrice.Cmp(zero) > 0 {
 			log.Debug("Max price in bids tree", "price", maxPrice.String())
-			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, maxPrice, quantityToTrade, order)
+			quantityToTrade, newTrades, newRejects, err = tomox.processOrderList(header, coinbase, chain, statedb, tradingStateDB, tradingstate.Bid, orderBook, maxPrice, quantityToTrade, order)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -207,7 +218,7 @@ func (tomox *TomoX) processLimitOrder(coinbase common.Address, chain consensus.C
 }
 
 // processOrderList : process the order list
-func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, side string, orderBook common.Hash, price *big.Int, quantityStillToTrade *big.Int, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
+func (tomox *TomoX) processOrderList(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, side string, orderBook common.Hash, price *big.Int, quantityStillToTrade *big.Int, order *tradingstate.OrderItem) (*big.Int, []map[string]string, []*tradingstate.OrderItem, error) {
 	quantityToTrade := tradingstate.CloneBigInt(quantityStillToTrade)
 	log.Debug("Process matching between order and orderlist", "quantityToTrade", quantityToTrade)
 	var (
@@ -225,6 +236,25 @@ func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.Ch
 		if oldestOrder.Quantity == nil || oldestOrder.Quantity.Sign() == 0 && amount.Sign() == 0 {
 			break
 		}
+		if oldestOrder.UserAddress == order.UserAddress && chain.Config().IsTIPTomoXSelfTradePrevention(header.Number) {
+			if mode := order.SelfTradePrevention(); mode != "" {
+				var (
+					cancelTaker bool
+					cancels     []*tradingstate.OrderItem
+					err         error
+				)
+				quantityToTrade, cancelTaker, cancels, err = preventSelfTrade(tradingStateDB, orderBook, side, price, orderId, amount, quantityToTrade, order, &oldestOrder, mode)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				// the cancelled orders go along the rejected ones, told apart by their status
+				rejects = append(rejects, cancels...)
+				if cancelTaker {
+					break
+				}
+				continue
+			}
+		}
 		var (
 			tradedQuantity    *big.Int
 			maxTradedQuantity *big.Int
@@ -361,6 +391,63 @@ func (tomox *TomoX) processOrderList(coinbase common.Address, chain consensus.Ch
 	return quantityToTrade, trades, rejects, nil
 }
 
+// preventSelfTrade applies the self-trade prevention mode of the taker order to
+// a resting maker order of the same user instead of matching them. It returns
+// the quantity of the taker order still to trade, whether the taker order is
+// cancelled and the orders cancelled, which carry the cancelled status to be
+// told apart from rejected orders.
+func preventSelfTrade(tradingStateDB *tradingstate.TradingStateDB, orderBook common.Hash, side string, price *big.Int, orderId common.Hash, amount *big.Int, quantityToTrade *big.Int, order *tradingstate.OrderItem, oldestOrder *tradingstate.OrderItem, mode string) (*big.Int, bool, []*tradingstate.OrderItem, error) {
+	var (
+		cancels     []*tradingstate.OrderItem
+		cancelTaker bool
+		cancelMaker bool
+	)
+	switch mode {
+	case types.SelfTradeCancelNewest:
+		cancelTaker = true
+	case types.SelfTradeCancelOldest:
+		cancelMaker = true
+	case types.SelfTradeCancelBoth:
+		cancelTaker, cancelMaker = true, true
+	case types.SelfTradeDecrementAndCancel:
+		decrement := tradingstate.CloneBigInt(amount)
+		if quantityToTrade.Cmp(amount) < 0 {
+			decrement = tradingstate.CloneBigInt(quantityToTrade)
+		}
+		quantityToTrade = tradingstate.Sub(quantityToTrade, decrement)
+		if err := tradingStateDB.SubAmountOrderItem(orderBook, orderId, price, decrement, side); err != nil {
+			return nil, false, nil, err
+		}
+		cancelTaker = quantityToTrade.Sign() == 0
+		if decrement.Cmp(amount) == 0 {
+			// the maker order is already out of the order book
+			cancels = append(cancels, selfTradeCancelled(oldestOrder))
+		}
+	default:
+		return nil, false, nil, tradingstate.ErrInvalidSelfTradePrevention
+	}
+	log.Debug("Prevent self-trade", "mode", mode, "taker", order.Hash, "maker", oldestOrder.Hash, "cancelTaker", cancelTaker, "cancelMaker", cancelMaker)
+	if cancelMaker {
+		if err := tradingStateDB.CancelOrder(orderBook, oldestOrder); err != nil {
+			return nil, false, nil, err
+		}
+		cancels = append(cancels, selfTradeCancelled(oldestOrder))
+	}
+	if cancelTaker {
+		cancels = append(cancels, selfTradeCancelled(order))
+		quantityToTrade = new(big.Int)
+	}
+	return quantityToTrade, cancelTaker, cancels, nil
+}
+
+// selfTradeCancelled returns a copy of an order cancelled by the self-trade
+// prevention, reported with the cancelled status.
+func selfTradeCancelled(order *tradingstate.OrderItem) *tradingstate.OrderItem {
+	cancelled := *order
+	cancelled.Status = tradingstate.OrderStatusCancelled
+	return &cancelled
+}
+
 func (tomox *TomoX) getTradeQuantity(quotePrice *big.Int, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, takerOrder *tradingstate.OrderItem, makerOrder *tradingstate.OrderItem, quantityToTrade *big.Int) (*big.Int, bool, *tradingstate.SettleBalance, error) {
 	baseTokenDecimal, err := tomox.GetTokenDecimal(chain, statedb, makerOrder.BaseToken)
 	if err != nil || baseTokenDecimal.Sign() == 0 {
@@ -620,7 +707,7 @@ func (tomox *TomoX) ProcessCancelOrder(header *types.Header, tradingStateDB *tra
 	// order: basic order information (includes orderId, orderHash, baseToken, quoteToken) which user send to tomox to cancel order
 	// originOrder: full order information getting from order trie
 	originOrder := tradingStateDB.GetOrder(orderBook, common.BigToHash(new(big.Int).SetUint64(order.OrderID)))
-	if originOrder == tradingstate.EmptyOrder {
+	if originOrder.Hash == (common.Hash{}) {
 		return fmt.Errorf("order not found. OrderId: %v. Base: %s. Quote: %s", order.OrderID, order.BaseToken.Hex(), order.QuoteToken.Hex()), false
 	}
 	var tokenBalance *big.Int
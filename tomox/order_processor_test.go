@@ -3,6 +3,7 @@ package tomox
 import (
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/types"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 	"math/big"
 	"reflect"
@@ -572,3 +573,65 @@ func TestGetTradeQuantity(t *testing.T) {
 		})
 	}
 }
+
+func TestPreventSelfTrade(t *testing.T) {
+	user := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	orderBook := tradingstate.GetTradingOrderBookHash(common.HexToAddress("0x1000000000000000000000000000000000000002"), common.HexToAddress(common.TomoNativeAddress))
+	price := big.NewInt(10)
+	makerId := common.BigToHash(big.NewInt(1))
+
+	tests := []struct {
+		mode         string
+		quantity     int64
+		wantQuantity int64 // quantity of the taker order still to trade
+		wantMaker    int64 // amount of the maker order left in the order book
+		cancelTaker  bool
+		wantCancels  int
+	}{
+		{types.SelfTradeCancelNewest, 60, 0, 100, true, 1},
+		{types.SelfTradeCancelOldest, 60, 60, 0, false, 1},
+		{types.SelfTradeCancelBoth, 60, 0, 0, true, 2},
+		{types.SelfTradeDecrementAndCancel, 60, 0, 40, true, 1},
+		{types.SelfTradeDecrementAndCancel, 150, 50, 0, false, 1},
+		{types.SelfTradeDecrementAndCancel, 100, 0, 0, true, 2},
+	}
+	for _, tt := range tests {
+		tradingStateDb, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+		maker := tradingstate.OrderItem{
+			UserAddress: user,
+			Side:        tradingstate.Ask,
+			Price:       price,
+			Quantity:    big.NewInt(100),
+			OrderID:     1,
+			Hash:        common.HexToHash("0x01"),
+		}
+		tradingStateDb.InsertOrderItem(orderBook, makerId, maker)
+		taker := &tradingstate.OrderItem{
+			UserAddress: user,
+			Side:        tradingstate.Bid,
+			Price:       price,
+			Quantity:    big.NewInt(tt.quantity),
+			Hash:        common.HexToHash("0x02"),
+		}
+		taker.SetSelfTradePrevention(tt.mode)
+		if mode := taker.SelfTradePrevention(); mode != tt.mode {
+			t.Fatalf("self-trade prevention mode mismatch: have %s, want %s", mode, tt.mode)
+		}
+		quantity, cancelTaker, cancels, err := preventSelfTrade(tradingStateDb, orderBook, tradingstate.Ask, price, makerId, big.NewInt(100), taker.Quantity, taker, &maker, tt.mode)
+		if err != nil {
+			t.Fatalf("%s %d: failed to prevent self-trade: %v", tt.mode, tt.quantity, err)
+		}
+		if quantity.Int64() != tt.wantQuantity || cancelTaker != tt.cancelTaker || len(cancels) != tt.wantCancels {
+			t.Errorf("%s %d: result mismatch: quantity %v, cancel taker %v, cancels %d", tt.mode, tt.quantity, quantity, cancelTaker, len(cancels))
+		}
+		for _, cancel := range cancels {
+			if cancel.Status != tradingstate.OrderStatusCancelled {
+				t.Errorf("%s %d: cancellation status mismatch: have %s, want %s", tt.mode, tt.quantity, cancel.Status, tradingstate.OrderStatusCancelled)
+			}
+		}
+		_, amount, _ := tradingStateDb.GetBestOrderIdAndAmount(orderBook, price, tradingstate.Ask)
+		if amount.Int64() != tt.wantMaker {
+			t.Errorf("%s %d: maker amount mismatch: have %v, want %d", tt.mode, tt.quantity, amount, tt.wantMaker)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package tomox
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Relayer statistics: the volume, the fees and the matching results of every
+// relayer are recorded while the orders of a block are processed, and stored in
+// the tomox database by block hash once the block is written to the chain.
+
+const relayerStatsCacheLimit = 256
+
+var relayerStatsPrefix = []byte("relayerStats")
+
+// PairStats is the trading volume of a relayer in a pair
+type PairStats struct {
+	BaseToken  common.Address `json:"baseToken"`
+	QuoteToken common.Address `json:"quoteToken"`
+	Volume     *big.Int       `json:"volume"` // traded quantity, in base token
+	Trades     uint64         `json:"trades"`
+}
+
+// LendingBookStats is the lending volume of a relayer in a lending book
+type LendingBookStats struct {
+	LendingToken common.Address `json:"lendingToken"`
+	Term         uint64         `json:"term"`
+	Volume       *big.Int       `json:"volume"` // lent amount, in lending token
+	Trades       uint64         `json:"trades"`
+}
+
+// RelayerStats is the activity of a relayer over a range of blocks
+type RelayerStats struct {
+	Relayer         common.Address                    `json:"relayer"`
+	FromBlock       uint64                            `json:"fromBlock"`
+	ToBlock         uint64                            `json:"toBlock"`
+	Trading         map[common.Hash]*PairStats        `json:"trading"`
+	Lending         map[common.Hash]*LendingBookStats `json:"lending"`
+	MatchedOrders   uint64                            `json:"matchedOrders"`
+	RejectedOrders  uint64                            `json:"rejectedOrders"`
+	CancelledOrders uint64                            `json:"cancelledOrders"`
+	MakerFees       map[common.Address]*big.Int       `json:"makerFees"`  // fees of maker orders, by token
+	TakerFees       map[common.Address]*big.Int       `json:"takerFees"`  // fees of taker orders, by token
+	CancelFees      map[common.Address]*big.Int       `json:"cancelFees"` // fees of cancelled orders, by token
+	DepositFees     *big.Int                          `json:"depositFees"`
+	Deposit         *big.Int                          `json:"deposit,omitempty"`
+	BurnRate        *big.Int                          `json:"burnRate,omitempty"`        // deposit fees per block
+	RemainingBlocks uint64                            `json:"remainingBlocks,omitempty"` // blocks until the deposit is burnt
+}
+
+// NewRelayerStats returns empty statistics of relayer
+func NewRelayerStats(relayer common.Address) *RelayerStats {
+	return &RelayerStats{
+		Relayer:     relayer,
+		Trading:     map[common.Hash]*PairStats{},
+		Lending:     map[common.Hash]*LendingBookStats{},
+		MakerFees:   map[common.Address]*big.Int{},
+		TakerFees:   map[common.Address]*big.Int{},
+		CancelFees:  map[common.Address]*big.Int{},
+		DepositFees: new(big.Int),
+	}
+}
+
+func addTokenAmount(amounts map[common.Address]*big.Int, token common.Address, amount *big.Int) {
+	if amount == nil || amount.Sign() == 0 {
+		return
+	}
+	if amounts[token] == nil {
+		amounts[token] = new(big.Int)
+	}
+	amounts[token].Add(amounts[token], amount)
+}
+
+func (s *RelayerStats) addTrade(baseToken, quoteToken common.Address, quantity *big.Int) {
+	pair := tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+	if s.Trading[pair] == nil {
+		s.Trading[pair] = &PairStats{BaseToken: baseToken, QuoteToken: quoteToken, Volume: new(big.Int)}
+	}
+	s.Trading[pair].Volume.Add(s.Trading[pair].Volume, quantity)
+	s.Trading[pair].Trades++
+}
+
+func (s *RelayerStats) addLendingTrade(lendingToken common.Address, term uint64, amount *big.Int) {
+	book := lendingstate.GetLendingOrderBookHash(lendingToken, term)
+	if s.Lending[book] == nil {
+		s.Lending[book] = &LendingBookStats{LendingToken: lendingToken, Term: term, Volume: new(big.Int)}
+	}
+	s.Lending[book].Volume.Add(s.Lending[book].Volume, amount)
+	s.Lending[book].Trades++
+}
+
+// Merge adds the statistics of other to s
+func (s *RelayerStats) Merge(other *RelayerStats) {
+	for pair, stats := range other.Trading {
+		if s.Trading[pair] == nil {
+			s.Trading[pair] = &PairStats{BaseToken: stats.BaseToken, QuoteToken: stats.QuoteToken, Volume: new(big.Int)}
+		}
+		s.Trading[pair].Volume.Add(s.Trading[pair].Volume, stats.Volume)
+		s.Trading[pair].Trades += stats.Trades
+	}
+	for book, stats := range other.Lending {
+		if s.Lending[book] == nil {
+			s.Lending[book] = &LendingBookStats{LendingToken: stats.LendingToken, Term: stats.Term, Volume: new(big.Int)}
+		}
+		s.Lending[book].Volume.Add(s.Lending[book].Volume, stats.Volume)
+		s.Lending[book].Trades += stats.Trades
+	}
+	s.MatchedOrders += other.MatchedOrders
+	s.RejectedOrders += other.RejectedOrders
+	s.CancelledOrders += other.CancelledOrders
+	for token, amount := range other.MakerFees {
+		addTokenAmount(s.MakerFees, token, amount)
+	}
+	for token, amount := range other.TakerFees {
+		addTokenAmount(s.TakerFees, token, amount)
+	}
+	for token, amount := range other.CancelFees {
+		addTokenAmount(s.CancelFees, token, amount)
+	}
+	if other.DepositFees != nil {
+		s.DepositFees.Add(s.DepositFees, other.DepositFees)
+	}
+}
+
+// BlockRelayerStats is the activity of the relayers in a block
+type BlockRelayerStats struct {
+	Relayers map[common.Address]*RelayerStats
+
+	tradingRecorded map[common.Hash]bool
+	lendingRecorded map[common.Hash]bool
+	lock            sync.Mutex
+}
+
+// NewBlockRelayerStats returns empty statistics of a block
+func NewBlockRelayerStats() *BlockRelayerStats {
+	return &BlockRelayerStats{
+		Relayers:        map[common.Address]*RelayerStats{},
+		tradingRecorded: map[common.Hash]bool{},
+		lendingRecorded: map[common.Hash]bool{},
+	}
+}
+
+func (b *BlockRelayerStats) relayer(relayer common.Address) *RelayerStats {
+	if b.Relayers[relayer] == nil {
+		b.Relayers[relayer] = NewRelayerStats(relayer)
+	}
+	return b.Relayers[relayer]
+}
+
+// decodeCancelFee returns the fee paid by the user of a cancelled order
+func decodeCancelFee(extraData string) *big.Int {
+	var data struct {
+		CancelFee string
+	}
+	if err := json.Unmarshal([]byte(extraData), &data); err != nil {
+		return nil
+	}
+	fee, ok := new(big.Int).SetString(data.CancelFee, 10)
+	if !ok {
+		return nil
+	}
+	return fee
+}
+
+// RecordTradingResult records the trades and the rejected orders of an order.
+// An order is recorded only once, so that a block validated again does not
+// count twice.
+func (b *BlockRelayerStats) RecordTradingResult(order *tradingstate.OrderItem, trades []map[string]string, rejects []*tradingstate.OrderItem) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	key := tradingstate.GetMatchingResultCacheKey(order)
+	if b.tradingRecorded[key] {
+		return
+	}
+	b.tradingRecorded[key] = true
+
+	for _, reject := range rejects {
+		if order.Status != tradingstate.OrderStatusCancelled && reject.Status == tradingstate.OrderStatusCancelled {
+			// cancelled by the self-trade prevention
+			b.relayer(reject.ExchangeAddress).CancelledOrders++
+			continue
+		}
+		b.relayer(reject.ExchangeAddress).RejectedOrders++
+	}
+	taker := b.relayer(order.ExchangeAddress)
+	if order.Status == tradingstate.OrderStatusCancelled {
+		if len(rejects) > 0 {
+			return
+		}
+		taker.CancelledOrders++
+		taker.DepositFees.Add(taker.DepositFees, common.RelayerCancelFee)
+		feeToken := order.QuoteToken
+		if order.Side == tradingstate.Ask {
+			feeToken = order.BaseToken
+		}
+		addTokenAmount(taker.CancelFees, feeToken, decodeCancelFee(order.ExtraData))
+		return
+	}
+	if len(trades) > 0 {
+		taker.MatchedOrders++
+	}
+	for _, trade := range trades {
+		quantity := tradingstate.ToBigInt(trade[tradingstate.TradeQuantity])
+		baseToken := common.HexToAddress(trade[tradingstate.TradeBaseToken])
+		quoteToken := common.HexToAddress(trade[tradingstate.TradeQuoteToken])
+		maker := b.relayer(common.HexToAddress(trade[tradingstate.TradeMakerExchange]))
+
+		taker.addTrade(baseToken, quoteToken, quantity)
+		if maker != taker {
+			maker.addTrade(baseToken, quoteToken, quantity)
+		}
+		maker.MatchedOrders++
+		addTokenAmount(taker.TakerFees, quoteToken, tradingstate.ToBigInt(trade[tradingstate.TakerFee]))
+		addTokenAmount(maker.MakerFees, quoteToken, tradingstate.ToBigInt(trade[tradingstate.MakerFee]))
+		taker.DepositFees.Add(taker.DepositFees, common.RelayerFee)
+		maker.DepositFees.Add(maker.DepositFees, common.RelayerFee)
+	}
+}
+
+// RecordLendingResult records the trades and the rejected items of a lending
+// item. Only the matching of borrowing and investing orders is counted.
+func (b *BlockRelayerStats) RecordLendingResult(item *lendingstate.LendingItem, trades []*lendingstate.LendingTrade, rejects []*lendingstate.LendingItem) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	key := lendingstate.GetLendingCacheKey(item)
+	if b.lendingRecorded[key] {
+		return
+	}
+	b.lendingRecorded[key] = true
+
+	for _, reject := range rejects {
+		if item.Status != lendingstate.LendingStatusCancelled && reject.Status == lendingstate.LendingStatusCancelled {
+			// cancelled by the self-trade prevention
+			b.relayer(reject.Relayer).CancelledOrders++
+			continue
+		}
+		b.relayer(reject.Relayer).RejectedOrders++
+	}
+	taker := b.relayer(item.Relayer)
+	if item.Status == lendingstate.LendingStatusCancelled {
+		if len(rejects) > 0 {
+			return
+		}
+		taker.CancelledOrders++
+		taker.DepositFees.Add(taker.DepositFees, common.RelayerLendingCancelFee)
+		feeToken := item.CollateralToken
+		if item.Side == lendingstate.Investing {
+			feeToken = item.LendingToken
+		}
+		addTokenAmount(taker.CancelFees, feeToken, decodeCancelFee(item.ExtraData))
+		return
+	}
+	if item.Type != lendingstate.Limit && item.Type != lendingstate.Market {
+		return
+	}
+	if len(trades) > 0 {
+		taker.MatchedOrders++
+	}
+	for _, trade := range trades {
+		borrowing := b.relayer(trade.BorrowingRelayer)
+		investing := b.relayer(trade.InvestingRelayer)
+		maker := investing
+		if item.Side == lendingstate.Investing {
+			maker = borrowing
+		}
+
+		borrowing.addLendingTrade(trade.LendingToken, trade.Term, trade.Amount)
+		if investing != borrowing {
+			investing.addLendingTrade(trade.LendingToken, trade.Term, trade.Amount)
+		}
+		maker.MatchedOrders++
+		// only the relayer of the borrower charges fees
+		if trade.TakerOrderSide == lendingstate.Borrowing {
+			addTokenAmount(borrowing.TakerFees, trade.LendingToken, trade.BorrowingFee)
+		} else {
+			addTokenAmount(borrowing.MakerFees, trade.LendingToken, trade.BorrowingFee)
+		}
+		borrowing.DepositFees.Add(borrowing.DepositFees, common.RelayerLendingFee)
+	}
+}
+
+func relayerStatsKey(blockHash common.Hash) []byte {
+	return append(append([]byte{}, relayerStatsPrefix...), blockHash.Bytes()...)
+}
+
+func (tomox *TomoX) pendingRelayerStats(hashNoValidator common.Hash) *BlockRelayerStats {
+	if stats, ok := tomox.relayerStats.Get(hashNoValidator); ok {
+		return stats.(*BlockRelayerStats)
+	}
+	stats := NewBlockRelayerStats()
+	tomox.relayerStats.Add(hashNoValidator, stats)
+	return stats
+}
+
+// RecordTradingResult records the matching result of an order of a block being
+// validated. Blocks are identified by their hash without the validator
+// signature, as they may be processed before they are signed by a validator.
+func (tomox *TomoX) RecordTradingResult(hashNoValidator common.Hash, order *tradingstate.OrderItem, trades []map[string]string, rejects []*tradingstate.OrderItem) {
+	tomox.pendingRelayerStats(hashNoValidator).RecordTradingResult(order, trades, rejects)
+}
+
+// RecordLendingResult records the matching result of a lending item of a block
+// being validated
+func (tomox *TomoX) RecordLendingResult(hashNoValidator common.Hash, item *lendingstate.LendingItem, trades []*lendingstate.LendingTrade, rejects []*lendingstate.LendingItem) {
+	tomox.pendingRelayerStats(hashNoValidator).RecordLendingResult(item, trades, rejects)
+}
+
+// SetRelayerStats sets the statistics of a block, used by the miner which
+// processes the orders before the block is sealed
+func (tomox *TomoX) SetRelayerStats(hashNoValidator common.Hash, stats *BlockRelayerStats) {
+	tomox.relayerStats.Add(hashNoValidator, stats)
+}
+
+// CommitRelayerStats writes the statistics of a block written to the chain.
+// The statistics are kept in memory, as the same block may be written again
+// once signed by a validator.
+func (tomox *TomoX) CommitRelayerStats(block *types.Block) error {
+	stats, ok := tomox.relayerStats.Get(block.HashNoValidator())
+	if !ok {
+		return nil
+	}
+	blockStats := stats.(*BlockRelayerStats)
+	blockStats.lock.Lock()
+	data, err := json.Marshal(blockStats.Relayers)
+	blockStats.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return tomox.db.Put(relayerStatsKey(block.Hash()), data)
+}
+
+// GetBlockRelayerStats returns the statistics of the relayers active in a block
+func (tomox *TomoX) GetBlockRelayerStats(blockHash common.Hash) (map[common.Address]*RelayerStats, error) {
+	key := relayerStatsKey(blockHash)
+	if ok, err := tomox.db.Has(key); err != nil || !ok {
+		return nil, err
+	}
+	data, err := tomox.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	stats := map[common.Address]*RelayerStats{}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,118 @@
+package tomox
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestRecordRelayerStats(t *testing.T) {
+	var (
+		takerRelayer = common.HexToAddress("0x0000000000000000000000000000000000000f01")
+		makerRelayer = common.HexToAddress("0x0000000000000000000000000000000000000f02")
+		user         = common.HexToAddress("0x0000000000000000000000000000000000000f03")
+		baseToken    = common.HexToAddress("0x0000000000000000000000000000000000000f04")
+		quoteToken   = common.HexToAddress("0x0000000000000000000000000000000000000f05")
+		pair         = tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+	)
+	stats := NewBlockRelayerStats()
+
+	order := &tradingstate.OrderItem{
+		UserAddress:     user,
+		Nonce:           big.NewInt(1),
+		ExchangeAddress: takerRelayer,
+		BaseToken:       baseToken,
+		QuoteToken:      quoteToken,
+		Side:            tradingstate.Bid,
+		Status:          tradingstate.OrderStatusNew,
+	}
+	trades := []map[string]string{}
+	for i := 0; i < 2; i++ {
+		trades = append(trades, map[string]string{
+			tradingstate.TradeQuantity:      "100",
+			tradingstate.TradeBaseToken:     baseToken.Hex(),
+			tradingstate.TradeQuoteToken:    quoteToken.Hex(),
+			tradingstate.TradeMakerExchange: makerRelayer.Hex(),
+			tradingstate.MakerFee:           "1",
+			tradingstate.TakerFee:           "2",
+		})
+	}
+	reject := &tradingstate.OrderItem{ExchangeAddress: makerRelayer}
+	stats.RecordTradingResult(order, trades, []*tradingstate.OrderItem{reject})
+	// a block validated again does not count twice
+	stats.RecordTradingResult(order, trades, []*tradingstate.OrderItem{reject})
+
+	cancel := &tradingstate.OrderItem{
+		UserAddress:     user,
+		Nonce:           big.NewInt(2),
+		ExchangeAddress: takerRelayer,
+		BaseToken:       baseToken,
+		QuoteToken:      quoteToken,
+		Side:            tradingstate.Ask,
+		Status:          tradingstate.OrderStatusCancelled,
+		ExtraData:       `{"CancelFee":"7","TokenPriceInTOMO":"0"}`,
+	}
+	stats.RecordTradingResult(cancel, nil, nil)
+
+	taker, maker := stats.Relayers[takerRelayer], stats.Relayers[makerRelayer]
+	if taker.Trading[pair].Volume.Int64() != 200 || taker.Trading[pair].Trades != 2 || maker.Trading[pair].Volume.Int64() != 200 {
+		t.Errorf("trading volume mismatch: taker %+v, maker %+v", taker.Trading[pair], maker.Trading[pair])
+	}
+	if taker.MatchedOrders != 1 || maker.MatchedOrders != 2 || maker.RejectedOrders != 1 || taker.CancelledOrders != 1 {
+		t.Errorf("order counts mismatch: taker %+v, maker %+v", taker, maker)
+	}
+	if taker.TakerFees[quoteToken].Int64() != 4 || maker.MakerFees[quoteToken].Int64() != 2 || taker.CancelFees[baseToken].Int64() != 7 {
+		t.Errorf("fees mismatch: taker %v %v, maker %v", taker.TakerFees, taker.CancelFees, maker.MakerFees)
+	}
+	wantDepositFees := new(big.Int).Add(new(big.Int).Mul(common.RelayerFee, big.NewInt(2)), common.RelayerCancelFee)
+	if taker.DepositFees.Cmp(wantDepositFees) != 0 {
+		t.Errorf("deposit fees mismatch: have %v, want %v", taker.DepositFees, wantDepositFees)
+	}
+
+	lendingToken := quoteToken
+	book := lendingstate.GetLendingOrderBookHash(lendingToken, common.OneYear)
+	item := &lendingstate.LendingItem{
+		UserAddress: user,
+		Nonce:       big.NewInt(1),
+		Relayer:     takerRelayer,
+		Side:        lendingstate.Borrowing,
+		Type:        lendingstate.Limit,
+		Status:      lendingstate.LendingStatusNew,
+	}
+	trade := &lendingstate.LendingTrade{
+		BorrowingRelayer: takerRelayer,
+		InvestingRelayer: makerRelayer,
+		LendingToken:     lendingToken,
+		Term:             common.OneYear,
+		Amount:           big.NewInt(1000),
+		BorrowingFee:     big.NewInt(3),
+		TakerOrderSide:   lendingstate.Borrowing,
+	}
+	stats.RecordLendingResult(item, []*lendingstate.LendingTrade{trade}, nil)
+	if taker.Lending[book].Volume.Int64() != 1000 || maker.Lending[book].Trades != 1 {
+		t.Errorf("lending volume mismatch: taker %+v, maker %+v", taker.Lending[book], maker.Lending[book])
+	}
+	if taker.TakerFees[lendingToken].Int64() != 7 || maker.MatchedOrders != 3 {
+		t.Errorf("lending fees mismatch: taker %v, maker matched %d", taker.TakerFees, maker.MatchedOrders)
+	}
+
+	// statistics of blocks are stored as JSON and merged over a range
+	data, err := json.Marshal(stats.Relayers)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	decoded := map[common.Address]*RelayerStats{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	merged := NewRelayerStats(takerRelayer)
+	merged.Merge(decoded[takerRelayer])
+	merged.Merge(decoded[takerRelayer])
+	if merged.Trading[pair].Volume.Int64() != 400 || merged.TakerFees[quoteToken].Int64() != 14 || merged.DepositFees.Cmp(new(big.Int).Mul(taker.DepositFees, big.NewInt(2))) != 0 {
+		t.Errorf("merged stats mismatch: %+v", merged)
+	}
+}
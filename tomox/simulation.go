@@ -0,0 +1,105 @@
+package tomox
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// OrderSimulation is the expected outcome of a single simulated order.
+type OrderSimulation struct {
+	Order   *tradingstate.OrderItem   `json:"order"`
+	Trades  []map[string]string       `json:"trades"`
+	Rejects []*tradingstate.OrderItem `json:"rejects"`
+	Error   string                    `json:"error,omitempty"`
+}
+
+// SimulationResult is the expected outcome of a batch of simulated orders.
+type SimulationResult struct {
+	Orders   []OrderSimulation            `json:"orders"`
+	Balances []tradingstate.BalanceChange `json:"balances"`
+	Relayers []tradingstate.DepositChange `json:"relayers"`
+}
+
+// OrderItemFromTx converts an order transaction into the order item processed
+// by the matching engine. Transactions without signature are converted into
+// unsigned orders, their hash is derived from the order content if missing.
+func OrderItemFromTx(tx *types.OrderTransaction, signer types.OrderSigner) *tradingstate.OrderItem {
+	order := &tradingstate.OrderItem{
+		Nonce:           new(big.Int).SetUint64(tx.Nonce()),
+		Quantity:        tx.Quantity(),
+		Price:           tx.Price(),
+		ExchangeAddress: tx.ExchangeAddress(),
+		UserAddress:     tx.UserAddress(),
+		BaseToken:       tx.BaseToken(),
+		QuoteToken:      tx.QuoteToken(),
+		Status:          tx.Status(),
+		Side:            tx.Side(),
+		Type:            tx.Type(),
+		Hash:            tx.OrderHash(),
+		OrderID:         tx.OrderID(),
+	}
+	order.SetSelfTradePrevention(tx.SelfTradePrevention())
+	if V, R, S := tx.Signature(); V.Sign() != 0 || R.Sign() != 0 || S.Sign() != 0 {
+		order.Signature = &tradingstate.Signature{
+			V: byte(V.Uint64()),
+			R: common.BigToHash(R),
+			S: common.BigToHash(S),
+		}
+	}
+	if order.Hash == (common.Hash{}) {
+		order.Hash = signer.Hash(tx)
+	}
+	return order
+}
+
+// SimulateOrders applies the orders in sequence on copies of the given states
+// and reports the trades, rejections and balance settlements they would lead
+// to. The signature of unsigned orders is not verified. The given states are
+// left untouched.
+func (tomox *TomoX) SimulateOrders(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDB *tradingstate.TradingStateDB, orders []*tradingstate.OrderItem) *SimulationResult {
+	var (
+		simState   = statedb.Copy()
+		simTrading = tradingStateDB.Copy()
+		tracker    = tradingstate.NewBalanceTracker(statedb)
+		result     = &SimulationResult{Orders: make([]OrderSimulation, 0, len(orders))}
+	)
+	tracker.TrackBalance(statedb.GetOwner(coinbase), common.HexToAddress(common.TomoNativeAddress))
+
+	for _, order := range orders {
+		sim := OrderSimulation{Order: order}
+		trackOrder(tracker, statedb, order.UserAddress, order.ExchangeAddress, order.BaseToken, order.QuoteToken)
+
+		// Mirror CommitOrder, rolling back the states if the order fails
+		tradingSnap, dbSnap := simTrading.Snapshot(), simState.Snapshot()
+		orderBook := tradingstate.GetTradingOrderBookHash(order.BaseToken, order.QuoteToken)
+		trades, rejects, err := tomox.applyOrder(header, coinbase, chain, simState, simTrading, orderBook, order, order.Signature != nil)
+		if err != nil {
+			simTrading.RevertToSnapshot(tradingSnap)
+			simState.RevertToSnapshot(dbSnap)
+			sim.Error = err.Error()
+		}
+		for _, trade := range trades {
+			trackOrder(tracker, statedb, common.HexToAddress(trade[tradingstate.TradeMaker]), common.HexToAddress(trade[tradingstate.TradeMakerExchange]), order.BaseToken, order.QuoteToken)
+		}
+		sim.Trades, sim.Rejects = trades, rejects
+		result.Orders = append(result.Orders, sim)
+	}
+	result.Balances, result.Relayers = tracker.Changes(simState)
+	return result
+}
+
+// trackOrder tracks the balances touched when settling an order of user placed
+// through the given relayer.
+func trackOrder(tracker *tradingstate.BalanceTracker, statedb *state.StateDB, user, relayer, baseToken, quoteToken common.Address) {
+	owner := tradingstate.GetRelayerOwner(relayer, statedb)
+	for _, token := range []common.Address{baseToken, quoteToken} {
+		tracker.TrackBalance(user, token)
+		tracker.TrackBalance(owner, token)
+	}
+	tracker.TrackRelayer(relayer)
+}
@@ -0,0 +1,208 @@
+package tomox
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestOrderItemFromTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	signer := types.MakeOrderSigner(params.TestChainConfig, common.TIPTomoXSelfTradePreventionBlock)
+	newTx := func() *types.OrderTransaction {
+		return types.NewOrderTransaction(1, big.NewInt(1000), big.NewInt(20), common.HexToAddress("0x01"), user,
+			common.HexToAddress("0x02"), common.HexToAddress("0x03"), tradingstate.OrderNew, tradingstate.Bid, tradingstate.Limit, common.Hash{}, 0)
+	}
+	// Unsigned orders carry no signature but get a hash derived from the content
+	tx := newTx()
+	order := OrderItemFromTx(tx, signer)
+	if order.Signature != nil {
+		t.Fatalf("unsigned order has signature: %v", order.Signature)
+	}
+	if want := signer.Hash(tx); order.Hash != want {
+		t.Fatalf("order hash mismatch: have %x, want %x", order.Hash, want)
+	}
+	if err := order.VerifyBasicOrderInfo(signer); err != tradingstate.ErrInvalidSignature {
+		t.Fatalf("unsigned order verification error mismatch: have %v, want %v", err, tradingstate.ErrInvalidSignature)
+	}
+	// Signed orders keep their signature, which must verify
+	signed, err := types.OrderSignTx(newTx(), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign order: %v", err)
+	}
+	order = OrderItemFromTx(signed, signer)
+	if order.Signature == nil {
+		t.Fatalf("signed order lost its signature")
+	}
+	if err := order.VerifyBasicOrderInfo(signer); err != nil {
+		t.Fatalf("signed order verification failed: %v", err)
+	}
+	// The self-trade prevention mode is carried over and covered by the signature
+	tx = newTx()
+	tx.SetSelfTradePrevention(types.SelfTradeCancelOldest)
+	if signed, err = types.OrderSignTx(tx, signer, key); err != nil {
+		t.Fatalf("failed to sign order: %v", err)
+	}
+	order = OrderItemFromTx(signed, signer)
+	if mode := order.SelfTradePrevention(); mode != types.SelfTradeCancelOldest {
+		t.Fatalf("self-trade prevention mode mismatch: have %s, want %s", mode, types.SelfTradeCancelOldest)
+	}
+	if err := order.VerifyBasicOrderInfo(signer); err != nil {
+		t.Fatalf("signed order verification failed: %v", err)
+	}
+	// The extra data written on cancellation leaves the mode untouched
+	order.ExtraData = `{"cancelFee":"1"}`
+	if mode := order.SelfTradePrevention(); mode != types.SelfTradeCancelOldest {
+		t.Fatalf("self-trade prevention mode mismatch after cancellation: have %s, want %s", mode, types.SelfTradeCancelOldest)
+	}
+	order.SetSelfTradePrevention(types.SelfTradeCancelNewest)
+	if err := order.VerifyBasicOrderInfo(signer); err != tradingstate.ErrInvalidSignature {
+		t.Fatalf("altered order verification error mismatch: have %v, want %v", err, tradingstate.ErrInvalidSignature)
+	}
+	order.SetSelfTradePrevention("XX")
+	if err := order.VerifyBasicOrderInfo(signer); err != tradingstate.ErrInvalidSelfTradePrevention {
+		t.Fatalf("invalid mode verification error mismatch: have %v, want %v", err, tradingstate.ErrInvalidSelfTradePrevention)
+	}
+}
+
+// testChain is the chain context the test orders are matched in.
+type testChain struct{}
+
+func (testChain) Engine() consensus.Engine                    { return nil }
+func (testChain) GetHeader(common.Hash, uint64) *types.Header { return nil }
+func (testChain) CurrentHeader() *types.Header                { return nil }
+func (testChain) Config() *params.ChainConfig                 { return params.TestChainConfig }
+
+// setTestRelayer registers relayer in the relayer contract with the given owner,
+// deposit and trading fee, listing a single pair.
+func setTestRelayer(statedb *state.StateDB, relayer, owner common.Address, deposit *big.Int, fee int64, baseToken, quoteToken common.Address) {
+	contract := common.HexToAddress(common.RelayerRegistrationSMC)
+	loc := tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+	field := func(name string) common.Hash {
+		return common.BigToHash(new(big.Int).Add(loc, tradingstate.RelayerStructMappingSlot[name]))
+	}
+	statedb.SetState(contract, field("_deposit"), common.BigToHash(deposit))
+	statedb.SetState(contract, field("_fee"), common.BigToHash(big.NewInt(fee)))
+	statedb.SetState(contract, field("_owner"), owner.Hash())
+	statedb.SetState(contract, field("_fromTokens"), common.BigToHash(common.Big1))
+	statedb.SetState(contract, state.GetLocDynamicArrAtElement(field("_fromTokens"), 0, 1), baseToken.Hash())
+	statedb.SetState(contract, field("_toTokens"), common.BigToHash(common.Big1))
+	statedb.SetState(contract, state.GetLocDynamicArrAtElement(field("_toTokens"), 0, 1), quoteToken.Hash())
+	statedb.AddBalance(contract, deposit)
+}
+
+// Tests that simulated orders lead to the trades, rejections and settlements
+// of actually applying them, without touching the given states.
+func TestSimulateOrders(t *testing.T) {
+	var (
+		makerKey, _ = crypto.GenerateKey()
+		takerKey, _ = crypto.GenerateKey()
+		maker       = crypto.PubkeyToAddress(makerKey.PublicKey)
+		taker       = crypto.PubkeyToAddress(takerKey.PublicKey)
+		relayer     = common.HexToAddress("0x0000000000000000000000000000000000000d01")
+		owner       = common.HexToAddress("0x0000000000000000000000000000000000000d02")
+		coinbase    = common.HexToAddress("0x0000000000000000000000000000000000000d03")
+		baseToken   = common.HexToAddress("0x0000000000000000000000000000000000000e01")
+		quoteToken  = common.HexToAddress(common.TomoNativeAddress)
+		orderBook   = tradingstate.GetTradingOrderBookHash(baseToken, quoteToken)
+		header      = &types.Header{Number: big.NewInt(1)}
+		funds       = new(big.Int).Mul(big.NewInt(100), common.BasePrice)
+		signer      = types.MakeOrderSigner(params.TestChainConfig, header.Number)
+	)
+	tomox := New(&DefaultConfig)
+	tomox.SetTokenDecimal(baseToken, common.BasePrice)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+
+	deposit := new(big.Int).Mul(new(big.Int).Add(common.RelayerLockedFund, big.NewInt(10)), common.BasePrice)
+	setTestRelayer(statedb, relayer, owner, deposit, 10, baseToken, quoteToken)
+	statedb.SetNonce(baseToken, 1)
+	tradingstate.SetTokenBalance(maker, funds, baseToken, statedb)
+	statedb.SetBalance(taker, funds)
+
+	newOrder := func(key *ecdsa.PrivateKey, nonce uint64, quantity, price int64, side string) func() *tradingstate.OrderItem {
+		tx := types.NewOrderTransaction(nonce, new(big.Int).Mul(big.NewInt(quantity), common.BasePrice), new(big.Int).Mul(big.NewInt(price), common.BasePrice),
+			relayer, crypto.PubkeyToAddress(key.PublicKey), baseToken, quoteToken, tradingstate.OrderNew, side, tradingstate.Limit, common.Hash{}, 0)
+		signed, err := types.OrderSignTx(tx, signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign order: %v", err)
+		}
+		// Matching updates the order, every run gets its own copy
+		return func() *tradingstate.OrderItem { return OrderItemFromTx(signed, signer) }
+	}
+	ask := newOrder(makerKey, 0, 10, 1, tradingstate.Ask)
+	if _, rejects, err := tomox.ApplyOrder(header, coinbase, testChain{}, statedb, tradingStateDB, orderBook, ask()); err != nil || len(rejects) != 0 {
+		t.Fatalf("failed to place maker order: rejects %v, err %v", rejects, err)
+	}
+	bid := newOrder(takerKey, 0, 4, 1, tradingstate.Bid)
+	invalid := newOrder(takerKey, 1, 4, 0, tradingstate.Bid)
+
+	root, tradingRoot := statedb.IntermediateRoot(false), tradingStateDB.IntermediateRoot()
+	result := tomox.SimulateOrders(header, coinbase, testChain{}, statedb, tradingStateDB, []*tradingstate.OrderItem{bid(), invalid()})
+	if have := statedb.IntermediateRoot(false); have != root {
+		t.Errorf("simulation changed the state: have root %x, want %x", have, root)
+	}
+	if have := tradingStateDB.IntermediateRoot(); have != tradingRoot {
+		t.Errorf("simulation changed the trading state: have root %x, want %x", have, tradingRoot)
+	}
+	if len(result.Orders) != 2 {
+		t.Fatalf("simulated order count mismatch: have %d, want 2", len(result.Orders))
+	}
+	// Apply the same orders for real and compare the outcomes
+	for i, order := range []*tradingstate.OrderItem{bid(), invalid()} {
+		trades, rejects, err := tomox.ApplyOrder(header, coinbase, testChain{}, statedb, tradingStateDB, orderBook, order)
+		if err != nil {
+			t.Fatalf("order %d: failed to apply: %v", i, err)
+		}
+		sim := result.Orders[i]
+		if sim.Error != "" {
+			t.Errorf("order %d: simulation failed: %v", i, sim.Error)
+		}
+		for _, trade := range append(trades, sim.Trades...) {
+			delete(trade, tradingstate.TradeTimestamp)
+		}
+		if len(trades) != len(sim.Trades) || (len(trades) > 0 && !reflect.DeepEqual(trades, sim.Trades)) {
+			t.Errorf("order %d: trades mismatch: have %v, want %v", i, sim.Trades, trades)
+		}
+		if len(rejects) != len(sim.Rejects) {
+			t.Fatalf("order %d: rejects mismatch: have %d, want %d", i, len(sim.Rejects), len(rejects))
+		}
+		for j := range rejects {
+			if sim.Rejects[j].Hash != rejects[j].Hash {
+				t.Errorf("order %d: reject %d mismatch: have %x, want %x", i, j, sim.Rejects[j].Hash, rejects[j].Hash)
+			}
+		}
+	}
+	if trades := result.Orders[0].Trades; len(trades) != 1 || trades[0][tradingstate.TradeQuantity] != new(big.Int).Mul(big.NewInt(4), common.BasePrice).String() {
+		t.Errorf("taker order trades mismatch: %v", trades)
+	}
+	if rejects := result.Orders[1].Rejects; len(rejects) != 1 || rejects[0].Hash != invalid().Hash {
+		t.Errorf("invalid order not rejected: %v", rejects)
+	}
+	// The reported settlements are the balances after applying the orders
+	if len(result.Balances) == 0 || len(result.Relayers) != 1 {
+		t.Fatalf("settlement count mismatch: have %d balances, %d relayers", len(result.Balances), len(result.Relayers))
+	}
+	for _, change := range result.Balances {
+		if have := tradingstate.GetTokenBalance(change.Address, change.Token, statedb); have.Cmp(change.After) != 0 {
+			t.Errorf("balance of %x in %x mismatch: have %v, simulated %v", change.Address, change.Token, have, change.After)
+		}
+	}
+	for _, change := range result.Relayers {
+		if have := tradingstate.GetRelayerDeposit(change.Relayer, statedb); have.Cmp(change.After) != 0 {
+			t.Errorf("deposit of %x mismatch: have %v, simulated %v", change.Relayer, have, change.After)
+		}
+	}
+}
@@ -62,6 +62,7 @@ type TomoX struct {
 	settings          syncmap.Map // holds configuration settings that can be dynamically changed
 	tokenDecimalCache *lru.Cache
 	orderCache        *lru.Cache
+	relayerStats      *lru.Cache // statistics of the relayers in blocks not yet written to the chain
 }
 
 func (tomox *TomoX) Protocols() []p2p.Protocol {
@@ -97,11 +98,13 @@ func NewMongoDBEngine(cfg *Config) *tomoxDAO.MongoDatabase {
 func New(cfg *Config) *TomoX {
 	tokenDecimalCache, _ := lru.New(defaultCacheLimit)
 	orderCache, _ := lru.New(tradingstate.OrderCacheLimit)
+	relayerStats, _ := lru.New(relayerStatsCacheLimit)
 	tomoX := &TomoX{
 		orderNonce:        make(map[common.Address]*big.Int),
 		Triegc:            prque.New(),
 		tokenDecimalCache: tokenDecimalCache,
 		orderCache:        orderCache,
+		relayerStats:      relayerStats,
 	}
 
 	// default DBEngine: levelDB
@@ -158,7 +161,7 @@ func (tomox *TomoX) ProcessOrderPending(header *types.Header, coinbase common.Ad
 	txMatches := []tradingstate.TxDataMatch{}
 	matchingResults := map[common.Hash]tradingstate.MatchingResult{}
 
-	txs := types.NewOrderTransactionByNonce(types.OrderTxSigner{}, pending)
+	txs := types.NewOrderTransactionByNonce(types.MakeOrderSigner(chain.Config(), header.Number), pending)
 	numberTx := 0
 	for {
 		tx := txs.Peek()
@@ -192,12 +195,13 @@ func (tomox *TomoX) ProcessOrderPending(header *types.Header, coinbase common.Ad
 			Type:            tx.Type(),
 			Hash:            tx.OrderHash(),
 			OrderID:         tx.OrderID(),
-			Signature: &tradingstate.Signature{
+				Signature: &tradingstate.Signature{
 				V: byte(n),
 				R: common.BigToHash(R),
 				S: common.BigToHash(S),
 			},
 		}
+		order.SetSelfTradePrevention(tx.SelfTradePrevention())
 		cancel := false
 		if order.Status == tradingstate.OrderStatusCancelled {
 			cancel = true
@@ -489,14 +493,19 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 		}
 	}
 
-	// 3. put rejected orders to db and update status REJECTED
+	// 3. put rejected orders to db and update status REJECTED, or CANCELLED for
+	// the orders cancelled by the self-trade prevention
 	log.Debug("Got rejected orders", "number", len(rejectedOrders), "rejectedOrders", rejectedOrders)
 
 	if len(rejectedOrders) > 0 {
 		var rejectedHashes []string
+		cancelled := make(map[common.Hash]bool)
 		// updateRejectedOrders
 		for _, rejectedOrder := range rejectedOrders {
 			rejectedHashes = append(rejectedHashes, rejectedOrder.Hash.Hex())
+			if rejectedOrder.Status == tradingstate.OrderStatusCancelled {
+				cancelled[rejectedOrder.Hash] = true
+			}
 			if updatedTakerOrder.Hash == rejectedOrder.Hash && !txMatchTime.Before(updatedTakerOrder.UpdatedAt) {
 				// cache order history for handling reorg
 				orderHistoryRecord := tradingstate.OrderHistoryItem{
@@ -508,7 +517,9 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 				tomox.UpdateOrderCache(updatedTakerOrder.BaseToken, updatedTakerOrder.QuoteToken, updatedTakerOrder.Hash, txHash, orderHistoryRecord)
 				// if whole order is rejected, status = REJECTED
 				// otherwise, status = FILLED
-				if updatedTakerOrder.FilledAmount.Sign() > 0 {
+				if cancelled[updatedTakerOrder.Hash] {
+					updatedTakerOrder.Status = tradingstate.OrderStatusCancelled
+				} else if updatedTakerOrder.FilledAmount.Sign() > 0 {
 					updatedTakerOrder.Status = tradingstate.OrderStatusFilled
 				} else {
 					updatedTakerOrder.Status = tradingstate.OrderStatusRejected
@@ -542,7 +553,9 @@ func (tomox *TomoX) SyncDataToSDKNode(takerOrderInTx *tradingstate.OrderItem, tx
 				}
 				// if whole order is rejected, status = REJECTED
 				// otherwise, status = FILLED
-				if order.FilledAmount.Sign() > 0 {
+				if cancelled[order.Hash] {
+					order.Status = tradingstate.OrderStatusCancelled
+				} else if order.FilledAmount.Sign() > 0 {
 					order.Status = tradingstate.OrderStatusFilled
 				} else {
 					order.Status = tradingstate.OrderStatusRejected
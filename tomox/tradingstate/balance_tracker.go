@@ -0,0 +1,89 @@
+package tradingstate
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+// BalanceChange is the settlement of a token balance of an account.
+type BalanceChange struct {
+	Address common.Address `json:"address"`
+	Token   common.Address `json:"token"`
+	Before  *big.Int       `json:"before"`
+	After   *big.Int       `json:"after"`
+}
+
+// DepositChange is the settlement of the TOMO deposit of a relayer.
+type DepositChange struct {
+	Relayer common.Address `json:"relayer"`
+	Before  *big.Int       `json:"before"`
+	After   *big.Int       `json:"after"`
+}
+
+// BalanceTracker remembers token balances and relayer deposits of a state to
+// report how they were settled once orders have been applied to a copy of it.
+type BalanceTracker struct {
+	statedb  *state.StateDB
+	balances map[common.Address]map[common.Address]*big.Int
+	deposits map[common.Address]*big.Int
+}
+
+// NewBalanceTracker creates a tracker reading the original values from the
+// given state, which must not be modified while tracking.
+func NewBalanceTracker(statedb *state.StateDB) *BalanceTracker {
+	return &BalanceTracker{
+		statedb:  statedb,
+		balances: make(map[common.Address]map[common.Address]*big.Int),
+		deposits: make(map[common.Address]*big.Int),
+	}
+}
+
+// TrackBalance starts tracking the balance of token held by addr.
+func (t *BalanceTracker) TrackBalance(addr common.Address, token common.Address) {
+	if t.balances[addr] == nil {
+		t.balances[addr] = make(map[common.Address]*big.Int)
+	}
+	if _, ok := t.balances[addr][token]; !ok {
+		t.balances[addr][token] = GetTokenBalance(addr, token, t.statedb)
+	}
+}
+
+// TrackRelayer starts tracking the deposit of a relayer.
+func (t *BalanceTracker) TrackRelayer(relayer common.Address) {
+	if _, ok := t.deposits[relayer]; !ok {
+		t.deposits[relayer] = GetRelayerDeposit(relayer, t.statedb)
+	}
+}
+
+// Changes compares the tracked values with the given state and returns the
+// ones which differ, sorted by address and token.
+func (t *BalanceTracker) Changes(statedb *state.StateDB) ([]BalanceChange, []DepositChange) {
+	balances := []BalanceChange{}
+	for addr, tokens := range t.balances {
+		for token, before := range tokens {
+			if after := GetTokenBalance(addr, token, statedb); after.Cmp(before) != 0 {
+				balances = append(balances, BalanceChange{Address: addr, Token: token, Before: before, After: after})
+			}
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if c := bytes.Compare(balances[i].Address[:], balances[j].Address[:]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(balances[i].Token[:], balances[j].Token[:]) < 0
+	})
+	deposits := []DepositChange{}
+	for relayer, before := range t.deposits {
+		if after := GetRelayerDeposit(relayer, statedb); after.Cmp(before) != 0 {
+			deposits = append(deposits, DepositChange{Relayer: relayer, Before: before, After: after})
+		}
+	}
+	sort.Slice(deposits, func(i, j int) bool {
+		return bytes.Compare(deposits[i].Relayer[:], deposits[j].Relayer[:]) < 0
+	})
+	return balances, deposits
+}
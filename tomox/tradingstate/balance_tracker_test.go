@@ -0,0 +1,58 @@
+package tradingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+func TestBalanceTracker(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		user    = common.HexToAddress("0x0000000000000000000000000000000000000aaa")
+		other   = common.HexToAddress("0x0000000000000000000000000000000000000bbb")
+		token   = common.HexToAddress("0x0000000000000000000000000000000000000ccc")
+		native  = common.HexToAddress(common.TomoNativeAddress)
+		relayer = common.HexToAddress("0x0000000000000000000000000000000000000ddd")
+	)
+	statedb.GetOrNewStateObject(token)
+	SetTokenBalance(user, big.NewInt(100), token, statedb)
+	statedb.SetBalance(user, big.NewInt(50))
+
+	tracker := NewBalanceTracker(statedb)
+	tracker.TrackBalance(user, token)
+	tracker.TrackBalance(other, token)
+
+	simulated := statedb.Copy()
+	SetTokenBalance(user, big.NewInt(40), token, simulated)
+	SetTokenBalance(other, big.NewInt(60), token, simulated)
+	simulated.SetBalance(user, big.NewInt(10))
+
+	// Tracking after the fact must still report the original values
+	tracker.TrackBalance(user, native)
+	tracker.TrackRelayer(relayer)
+
+	balances, deposits := tracker.Changes(simulated)
+	want := []BalanceChange{
+		{Address: user, Token: native, Before: big.NewInt(50), After: big.NewInt(10)},
+		{Address: user, Token: token, Before: big.NewInt(100), After: big.NewInt(40)},
+		{Address: other, Token: token, Before: big.NewInt(0), After: big.NewInt(60)},
+	}
+	if len(balances) != len(want) {
+		t.Fatalf("balance change count mismatch: have %d, want %d", len(balances), len(want))
+	}
+	for i, change := range balances {
+		if change.Address != want[i].Address || change.Token != want[i].Token || change.Before.Cmp(want[i].Before) != 0 || change.After.Cmp(want[i].After) != 0 {
+			t.Errorf("balance change %d mismatch: have %+v, want %+v", i, change, want[i])
+		}
+	}
+	if len(deposits) != 0 {
+		t.Errorf("unexpected deposit changes: %+v", deposits)
+	}
+	if have := GetTokenBalance(user, token, statedb); have.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("original state modified: have %v, want 100", have)
+	}
+}
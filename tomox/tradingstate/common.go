@@ -50,6 +50,8 @@ var (
 	ErrInvalidOrderSide = errors.New("verify order: invalid order side")
 	ErrInvalidStatus    = errors.New("verify order: invalid status")
 
+	ErrInvalidSelfTradePrevention = errors.New("verify order: unsupported self-trade prevention mode")
+
 	// supported order types
 	MatchingOrderType = map[string]bool{
 		Market: true,
@@ -214,6 +216,12 @@ func GetTradingOrderBookHash(baseToken common.Address, quoteToken common.Address
 	return common.BytesToHash(append(baseToken[:16], quoteToken[4:]...))
 }
 
+// GetOracleHash returns the key of the exchange object keeping the collateral
+// oracle price of a lending pair
+func GetOracleHash(collateralToken common.Address, lendingToken common.Address) common.Hash {
+	return crypto.Keccak256Hash([]byte("oracle"), collateralToken.Bytes(), lendingToken.Bytes())
+}
+
 func GetMatchingResultCacheKey(order *OrderItem) common.Hash {
 	return crypto.Keccak256Hash(order.UserAddress.Bytes(), order.Nonce.Bytes())
 }
@@ -1,6 +1,7 @@
 package tradingstate
 
 import (
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -41,3 +42,30 @@ func TestTxMatchesBatch(t *testing.T) {
 		t.Error("txMatchesBatch is different from originalTxMatchesBatch", "txMatchesBatch", txMatchesBatch, "originalTxMatchesBatch", originalTxMatchesBatch)
 	}
 }
+
+// Tests that the self-trade prevention mode of an order survives the encoding
+// of the order, and that orders without one decode as before.
+func TestOrderItemSelfTradePreventionEncoding(t *testing.T) {
+	order := OrderItem{Quantity: big.NewInt(1), Price: big.NewInt(2), Nonce: big.NewInt(3), FilledAmount: new(big.Int), Signature: &Signature{V: 27}, ExtraData: "extra"}
+	plain, err := EncodeBytesItem(order)
+	if err != nil {
+		t.Fatalf("failed to encode order: %v", err)
+	}
+	order.SetSelfTradePrevention("DC")
+	withMode, err := EncodeBytesItem(order)
+	if err != nil {
+		t.Fatalf("failed to encode order: %v", err)
+	}
+	for _, tt := range []struct {
+		enc  []byte
+		mode string
+	}{{plain, ""}, {withMode, "DC"}} {
+		var decoded OrderItem
+		if err := DecodeBytesItem(tt.enc, &decoded); err != nil {
+			t.Fatalf("failed to decode order: %v", err)
+		}
+		if mode := decoded.SelfTradePrevention(); mode != tt.mode || decoded.ExtraData != order.ExtraData {
+			t.Errorf("decoded order mismatch: mode %q, extra data %q, want %q, %q", mode, decoded.ExtraData, tt.mode, order.ExtraData)
+		}
+	}
+}
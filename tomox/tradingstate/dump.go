@@ -381,3 +381,34 @@ func (self *TradingStateDB) DumpLiquidationPriceTrie(orderBook common.Hash) (map
 	}
 	return mapResult, nil
 }
+
+func (self *TradingStateDB) DumpOrderTrie(orderBook common.Hash) (map[*big.Int]OrderItem, error) {
+	exhangeObject := self.getStateExchangeObject(orderBook)
+	if exhangeObject == nil {
+		return nil, fmt.Errorf("Order book not found orderBook : %v ", orderBook.Hex())
+	}
+	mapResult := map[*big.Int]OrderItem{}
+	it := trie.NewIterator(exhangeObject.getOrdersTrie(self.db).NodeIterator(nil))
+	for it.Next() {
+		orderIdHash := common.BytesToHash(it.Key)
+		if common.EmptyHash(orderIdHash) {
+			continue
+		}
+		orderId := new(big.Int).SetBytes(orderIdHash.Bytes())
+		if _, exist := exhangeObject.stateOrderObjects[orderIdHash]; exist {
+			continue
+		} else {
+			var data OrderItem
+			if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+				return nil, fmt.Errorf("Fail when decode order item orderBook : %v ,orderId :%v ", orderBook.Hex(), orderId)
+			}
+			mapResult[orderId] = data
+		}
+	}
+	for orderIdHash, stateOrderItem := range exhangeObject.stateOrderObjects {
+		if !stateOrderItem.empty() {
+			mapResult[new(big.Int).SetBytes(orderIdHash.Bytes())] = stateOrderItem.data
+		}
+	}
+	return mapResult, nil
+}
@@ -43,6 +43,11 @@ type OrderItem struct {
 	UpdatedAt       time.Time      `json:"updatedAt,omitempty"`
 	OrderID         uint64         `json:"orderID,omitempty"`
 	ExtraData       string         `json:"extraData,omitempty"`
+
+	// Optional self-trade prevention mode, kept apart from the extra data
+	// overwritten by cancellations and left out of the encoding of orders
+	// without one
+	SelfTradeMode []string `json:"selfTradePrevention,omitempty" rlp:"tail"`
 }
 
 // Signature struct
@@ -77,6 +82,8 @@ type OrderItemBSON struct {
 	UpdatedAt       time.Time        `json:"updatedAt,omitempty" bson:"updatedAt"`
 	OrderID         string           `json:"orderID,omitempty" bson:"orderID"`
 	ExtraData       string           `json:"extraData,omitempty" bson:"extraData"`
+
+	SelfTradePrevention string `json:"selfTradePrevention,omitempty" bson:"selfTradePrevention,omitempty"`
 }
 
 func (o *OrderItem) GetBSON() (interface{}, error) {
@@ -97,6 +104,8 @@ func (o *OrderItem) GetBSON() (interface{}, error) {
 		UpdatedAt:       o.UpdatedAt,
 		OrderID:         strconv.FormatUint(o.OrderID, 10),
 		ExtraData:       o.ExtraData,
+
+		SelfTradePrevention: o.SelfTradePrevention(),
 	}
 
 	if o.FilledAmount != nil {
@@ -137,6 +146,8 @@ func (o *OrderItem) SetBSON(raw bson.Raw) error {
 		UpdatedAt       time.Time        `json:"updatedAt" bson:"updatedAt"`
 		OrderID         string           `json:"orderID" bson:"orderID"`
 		ExtraData       string           `json:"extraData,omitempty" bson:"extraData"`
+
+		SelfTradePrevention string `json:"selfTradePrevention,omitempty" bson:"selfTradePrevention,omitempty"`
 	})
 
 	err := raw.Unmarshal(decoded)
@@ -184,14 +195,29 @@ func (o *OrderItem) SetBSON(raw bson.Raw) error {
 	}
 	o.OrderID = uint64(orderID)
 	o.ExtraData = decoded.ExtraData
+	o.SetSelfTradePrevention(decoded.SelfTradePrevention)
 	return nil
 }
 
 // VerifyOrder verify orderItem
-func (o *OrderItem) VerifyOrder(state *state.StateDB) error {
-	if err := o.VerifyBasicOrderInfo(); err != nil {
+func (o *OrderItem) VerifyOrder(state *state.StateDB, signer types.OrderSigner) error {
+	if err := o.VerifyBasicOrderInfo(signer); err != nil {
 		return err
 	}
+	return o.verifyOrderState(state)
+}
+
+// VerifyUnsignedOrder verify orderItem without checking its signature, it is
+// used to simulate orders which have not been signed yet
+func (o *OrderItem) VerifyUnsignedOrder(state *state.StateDB) error {
+	if err := o.verifyUnsignedOrderInfo(); err != nil {
+		return err
+	}
+	return o.verifyOrderState(state)
+}
+
+// verifyOrderState verify the relayer and the pair of the order against state
+func (o *OrderItem) verifyOrderState(state *state.StateDB) error {
 	if err := o.verifyRelayer(state); err != nil {
 		return err
 	}
@@ -204,8 +230,15 @@ func (o *OrderItem) VerifyOrder(state *state.StateDB) error {
 }
 
 // VerifyBasicOrderInfo verify basic info
-func (o *OrderItem) VerifyBasicOrderInfo() error {
+func (o *OrderItem) VerifyBasicOrderInfo(signer types.OrderSigner) error {
+	if err := o.verifyUnsignedOrderInfo(); err != nil {
+		return err
+	}
+	return o.verifySignature(signer)
+}
 
+// verifyUnsignedOrderInfo verify basic info except signature
+func (o *OrderItem) verifyUnsignedOrderInfo() error {
 	if o.Status == OrderNew {
 		if o.Type == Limit {
 			if err := o.verifyPrice(); err != nil {
@@ -221,14 +254,11 @@ func (o *OrderItem) VerifyBasicOrderInfo() error {
 		if err := o.verifyOrderType(); err != nil {
 			return err
 		}
+		if err := o.verifySelfTradePrevention(); err != nil {
+			return err
+		}
 	}
-	if err := o.verifyStatus(); err != nil {
-		return err
-	}
-	if err := o.verifySignature(); err != nil {
-		return err
-	}
-	return nil
+	return o.verifyStatus()
 }
 
 // verify whether the exchange applies to become relayer
@@ -240,7 +270,10 @@ func (o *OrderItem) verifyRelayer(state *state.StateDB) error {
 }
 
 //verify signatures
-func (o *OrderItem) verifySignature() error {
+func (o *OrderItem) verifySignature(signer types.OrderSigner) error {
+	if o.Signature == nil {
+		return ErrInvalidSignature
+	}
 	bigstr := o.Nonce.String()
 	n, err := strconv.ParseInt(bigstr, 10, 64)
 	if err != nil {
@@ -252,8 +285,9 @@ func (o *OrderItem) verifySignature() error {
 
 	tx := types.NewOrderTransaction(uint64(n), o.Quantity, o.Price, o.ExchangeAddress, o.UserAddress,
 		o.BaseToken, o.QuoteToken, o.Status, o.Side, o.Type, o.Hash, o.OrderID)
+	tx.SetSelfTradePrevention(o.SelfTradePrevention())
 	tx.ImportSignature(V, R, S)
-	from, _ := types.OrderSender(types.OrderTxSigner{}, tx)
+	from, _ := types.OrderSender(signer, tx)
 	if from != tx.UserAddress() {
 		return ErrInvalidSignature
 	}
@@ -269,6 +303,33 @@ func (o *OrderItem) verifyOrderType() error {
 	return nil
 }
 
+// verify self-trade prevention mode
+func (o *OrderItem) verifySelfTradePrevention() error {
+	if mode := o.SelfTradePrevention(); mode != "" && !types.ValidSelfTradePrevention[mode] {
+		log.Debug("Invalid self-trade prevention", "mode", mode)
+		return ErrInvalidSelfTradePrevention
+	}
+	return nil
+}
+
+// SelfTradePrevention returns the self-trade prevention mode of the order, it is
+// empty if the order may match the resting orders of its user
+func (o *OrderItem) SelfTradePrevention() string {
+	if len(o.SelfTradeMode) == 0 {
+		return ""
+	}
+	return o.SelfTradeMode[0]
+}
+
+// SetSelfTradePrevention sets the self-trade prevention mode of the order
+func (o *OrderItem) SetSelfTradePrevention(mode string) {
+	if mode == "" {
+		o.SelfTradeMode = nil
+	} else {
+		o.SelfTradeMode = []string{mode}
+	}
+}
+
 //verify order side
 func (o *OrderItem) verifyOrderSide() error {
 
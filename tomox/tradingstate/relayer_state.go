@@ -129,6 +129,16 @@ func GetAllTradingPairs(statedb *state.StateDB) (map[common.Hash]bool, error) {
 	return allPairs, nil
 }
 
+// GetRelayerDeposit returns the TOMO deposit of the relayer which the
+// matching and cancellation fees are paid from.
+func GetRelayerDeposit(relayer common.Address, statedb *state.StateDB) *big.Int {
+	slot := RelayerMappingSlot["RELAYER_LIST"]
+	locBig := GetLocMappingAtKey(relayer.Hash(), slot)
+	locBig = new(big.Int).Add(locBig, RelayerStructMappingSlot["_deposit"])
+	locHash := common.BigToHash(locBig)
+	return statedb.GetState(common.HexToAddress(common.RelayerRegistrationSMC), locHash).Big()
+}
+
 func SubRelayerFee(relayer common.Address, fee *big.Int, statedb *state.StateDB) error {
 	slot := RelayerMappingSlot["RELAYER_LIST"]
 	locBig := GetLocMappingAtKey(relayer.Hash(), slot)
@@ -0,0 +1,98 @@
+package tradingstate
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// OrderBookSnapshot is the full trading state of a pair: the order book info,
+// the ask and bid trees, the resting orders and the liquidation price tree of
+// the lending trades collateralized in the pair. It can be exported to JSON and
+// imported into another trading state.
+type OrderBookSnapshot struct {
+	OrderBook         common.Hash
+	Info              *DumpOrderBookInfo
+	Asks              map[*big.Int]DumpOrderList
+	Bids              map[*big.Int]DumpOrderList
+	LiquidationPrices map[*big.Int]DumpLendingBook
+	Orders            map[*big.Int]OrderItem
+}
+
+// DumpOrderBook returns the snapshot of the trading state of orderBook.
+func (self *TradingStateDB) DumpOrderBook(orderBook common.Hash) (*OrderBookSnapshot, error) {
+	var (
+		snapshot = &OrderBookSnapshot{OrderBook: orderBook}
+		err      error
+	)
+	if snapshot.Info, err = self.DumpOrderBookInfo(orderBook); err != nil {
+		return nil, err
+	}
+	if snapshot.Asks, err = self.DumpAskTrie(orderBook); err != nil {
+		return nil, err
+	}
+	if snapshot.Bids, err = self.DumpBidTrie(orderBook); err != nil {
+		return nil, err
+	}
+	if snapshot.LiquidationPrices, err = self.DumpLiquidationPriceTrie(orderBook); err != nil {
+		return nil, err
+	}
+	if snapshot.Orders, err = self.DumpOrderTrie(orderBook); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// ImportOrderBook rebuilds the trading state of the pair of snapshot, which
+// must not exist yet in the state.
+func (self *TradingStateDB) ImportOrderBook(snapshot *OrderBookSnapshot) error {
+	orderBook := snapshot.OrderBook
+	if self.getStateExchangeObject(orderBook) != nil {
+		return fmt.Errorf("Order book already exists orderBook : %v ", orderBook.Hex())
+	}
+	if snapshot.Info == nil {
+		return fmt.Errorf("Order book info missing orderBook : %v ", orderBook.Hex())
+	}
+	self.createExchangeObject(orderBook)
+	for orderId, order := range snapshot.Orders {
+		if order.Quantity == nil || order.Quantity.Sign() == 0 {
+			continue
+		}
+		if order.OrderID != orderId.Uint64() {
+			return fmt.Errorf("Order id mismatch orderBook : %v , key : %v , orderId : %v ", orderBook.Hex(), orderId, order.OrderID)
+		}
+		self.InsertOrderItem(orderBook, common.BigToHash(orderId), order)
+	}
+	for price, liquidationPrice := range snapshot.LiquidationPrices {
+		for lendingBook, tradeIds := range liquidationPrice.LendingBooks {
+			for tradeId := range tradeIds.Orders {
+				self.InsertLiquidationPrice(orderBook, price, lendingBook, tradeId.Uint64())
+			}
+		}
+	}
+	info := snapshot.Info
+	self.SetNonce(orderBook, info.Nonce)
+	if info.LastPrice != nil {
+		self.SetLastPrice(orderBook, info.LastPrice)
+	}
+	if info.MediumPrice != nil && info.TotalQuantity != nil {
+		self.SetMediumPrice(orderBook, info.MediumPrice, info.TotalQuantity)
+	}
+	if info.MediumPriceBeforeEpoch != nil {
+		self.SetMediumPriceBeforeEpoch(orderBook, info.MediumPriceBeforeEpoch)
+	}
+	// the trees are rebuilt from the orders and the lending trades, verify they
+	// hold what was exported
+	for side, want := range map[string]map[*big.Int]DumpOrderList{Ask: snapshot.Asks, Bid: snapshot.Bids} {
+		for price, orderList := range want {
+			if volume := self.GetVolume(orderBook, price, side); orderList.Volume != nil && volume.Cmp(orderList.Volume) != 0 {
+				return fmt.Errorf("Order list volume mismatch orderBook : %v , side : %s , price : %v , have : %v , want : %v ", orderBook.Hex(), side, price, volume, orderList.Volume)
+			}
+		}
+	}
+	if lendingCount := self.getStateExchangeObject(orderBook).data.LendingCount; info.LendingCount != nil && lendingCount.Cmp(info.LendingCount) != 0 {
+		return fmt.Errorf("Lending count mismatch orderBook : %v , have : %v , want : %v ", orderBook.Hex(), lendingCount, info.LendingCount)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package tradingstate
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestOrderBookSnapshot(t *testing.T) {
+	orderBook := common.StringToHash("BTC/TOMO")
+	lendingBook := common.StringToHash("BTC/30days")
+	statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	for i := uint64(1); i <= 6; i++ {
+		side := Ask
+		if i%2 == 0 {
+			side = Bid
+		}
+		statedb.InsertOrderItem(orderBook, common.BigToHash(new(big.Int).SetUint64(i)), OrderItem{
+			OrderID:     i,
+			Quantity:    new(big.Int).SetUint64(10 * i),
+			Price:       new(big.Int).SetUint64(100 + i%3),
+			Side:        side,
+			UserAddress: common.BigToAddress(new(big.Int).SetUint64(i)),
+			Signature:   &Signature{V: 1, R: common.HexToHash("1111"), S: common.HexToHash("2222")},
+		})
+	}
+	statedb.SetNonce(orderBook, 6)
+	statedb.SetLastPrice(orderBook, big.NewInt(101))
+	statedb.SetMediumPrice(orderBook, big.NewInt(100), big.NewInt(30))
+	statedb.SetMediumPriceBeforeEpoch(orderBook, big.NewInt(99))
+	statedb.InsertLiquidationPrice(orderBook, big.NewInt(50), lendingBook, 1)
+	statedb.InsertLiquidationPrice(orderBook, big.NewInt(50), lendingBook, 2)
+	statedb.InsertLiquidationPrice(orderBook, big.NewInt(60), lendingBook, 3)
+
+	snapshot, err := statedb.DumpOrderBook(orderBook)
+	if err != nil {
+		t.Fatalf("failed to dump order book: %v", err)
+	}
+	if len(snapshot.Orders) != 6 || len(snapshot.Asks) == 0 || len(snapshot.Bids) == 0 || len(snapshot.LiquidationPrices) != 2 {
+		t.Fatalf("incomplete snapshot: %d orders, %d asks, %d bids, %d liquidation prices", len(snapshot.Orders), len(snapshot.Asks), len(snapshot.Bids), len(snapshot.LiquidationPrices))
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("failed to encode snapshot: %v", err)
+	}
+	decoded := new(OrderBookSnapshot)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+
+	imported, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	if err := imported.ImportOrderBook(decoded); err != nil {
+		t.Fatalf("failed to import order book: %v", err)
+	}
+	if have, want := imported.IntermediateRoot(), statedb.IntermediateRoot(); have != want {
+		t.Fatalf("trading root mismatch: have %x, want %x", have, want)
+	}
+	reimported, err := imported.DumpOrderBook(orderBook)
+	if err != nil {
+		t.Fatalf("failed to dump imported order book: %v", err)
+	}
+	have, _ := json.Marshal(reimported)
+	if !reflect.DeepEqual(have, data) {
+		t.Fatalf("imported snapshot mismatch:\nhave %s\nwant %s", have, data)
+	}
+	if err := imported.ImportOrderBook(decoded); err == nil {
+		t.Fatalf("imported an existing order book")
+	}
+}
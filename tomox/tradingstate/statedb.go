@@ -183,6 +183,30 @@ func (self *TradingStateDB) SetMediumPriceBeforeEpoch(addr common.Hash, price *b
 	}
 }
 
+// GetOraclePrice returns the last accepted collateral oracle price, the time
+// weighted average of the accepted prices up to the time the last one was
+// accepted, that time and the epoch it was accepted in. The oracle object
+// reuses the price fields of an exchange object: LastPrice keeps the price,
+// MediumPriceBeforeEpoch the average, MediumPrice the time and Nonce the epoch.
+func (self *TradingStateDB) GetOraclePrice(addr common.Hash) (price *big.Int, twap *big.Int, updatedAt uint64, epoch uint64) {
+	stateObject := self.getStateExchangeObject(addr)
+	if stateObject == nil || stateObject.data.LastPrice == nil {
+		return Zero, Zero, 0, 0
+	}
+	if stateObject.data.MediumPrice != nil {
+		updatedAt = stateObject.data.MediumPrice.Uint64()
+	}
+	return stateObject.data.LastPrice, stateObject.data.MediumPriceBeforeEpoch, updatedAt, stateObject.Nonce()
+}
+
+// SetOraclePrice records the accepted collateral oracle price of an epoch
+func (self *TradingStateDB) SetOraclePrice(addr common.Hash, price *big.Int, twap *big.Int, updatedAt uint64, epoch uint64) {
+	self.SetLastPrice(addr, price)
+	self.SetMediumPriceBeforeEpoch(addr, twap)
+	self.SetMediumPrice(addr, new(big.Int).SetUint64(updatedAt), new(big.Int))
+	self.SetNonce(addr, epoch)
+}
+
 func (self *TradingStateDB) InsertOrderItem(orderBook common.Hash, orderId common.Hash, order OrderItem) {
 	priceHash := common.BigToHash(order.Price)
 	stateExchange := self.getStateExchangeObject(orderBook)
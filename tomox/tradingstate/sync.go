@@ -0,0 +1,90 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tradingstate
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// ExchangeSubTries decodes an exchange object stored in the trading state trie
+// and returns the roots of its ask, bid, order and liquidation price tries.
+func ExchangeSubTries(leaf []byte) (asks, bids, orders, liquidationPrices common.Hash, err error) {
+	var exchange tradingExchangeObject
+	if err := rlp.DecodeBytes(leaf, &exchange); err != nil {
+		return common.Hash{}, common.Hash{}, common.Hash{}, common.Hash{}, err
+	}
+	return exchange.AskRoot, exchange.BidRoot, exchange.OrderRoot, exchange.LiquidationPriceRoot, nil
+}
+
+// OrderListRoot decodes a price level stored in an ask, bid or liquidation
+// price trie (or a lending book of a liquidation price level) and returns the
+// root of the trie it points to.
+func OrderListRoot(leaf []byte) (common.Hash, error) {
+	var list orderList
+	if err := rlp.DecodeBytes(leaf, &list); err != nil {
+		return common.Hash{}, err
+	}
+	return list.Root, nil
+}
+
+// NewStateSync creates a new trading state trie download scheduler, covering
+// the order books and liquidation price tries nested into every exchange.
+func NewStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+	var syncer *trie.Sync
+
+	// Nested tries never written to are referenced by an empty hash, skip them
+	addSubTrie := func(root, parent common.Hash, callback trie.LeafCallback) {
+		if root != EmptyHash {
+			syncer.AddSubTrie(root, 64, parent, callback)
+		}
+	}
+
+	// Order lists (price levels and lending books) point to plain leaf tries,
+	// liquidation prices to a trie of lending books
+	orderLists := func(leaf []byte, parent common.Hash) error {
+		root, err := OrderListRoot(leaf)
+		if err != nil {
+			return err
+		}
+		addSubTrie(root, parent, nil)
+		return nil
+	}
+	liquidationPrices := func(leaf []byte, parent common.Hash) error {
+		root, err := OrderListRoot(leaf)
+		if err != nil {
+			return err
+		}
+		addSubTrie(root, parent, orderLists)
+		return nil
+	}
+	callback := func(leaf []byte, parent common.Hash) error {
+		asks, bids, orders, prices, err := ExchangeSubTries(leaf)
+		if err != nil {
+			return err
+		}
+		addSubTrie(asks, parent, orderLists)
+		addSubTrie(bids, parent, orderLists)
+		addSubTrie(orders, parent, nil)
+		addSubTrie(prices, parent, liquidationPrices)
+		return nil
+	}
+	syncer = trie.NewSync(root, database, callback, bloom)
+	return syncer
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tomoxlending
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// LendingAuctionInfo describes the liquidation auction of a trade
+type LendingAuctionInfo struct {
+	LendingBook     common.Hash    `json:"lendingBook"`
+	TradeId         uint64         `json:"tradeId"`
+	LendingToken    common.Address `json:"lendingToken"`
+	CollateralToken common.Address `json:"collateralToken"`
+	Term            uint64         `json:"term"`
+	StartTime       uint64         `json:"startTime"`
+	EndTime         uint64         `json:"endTime"`
+	StartPrice      *big.Int       `json:"startPrice"`
+	Price           *big.Int       `json:"price"` // at the time of the next block
+	Collateral      *big.Int       `json:"collateral"`
+	Remaining       *big.Int       `json:"remaining"` // lending token still to raise
+	Closed          bool           `json:"closed"`
+}
+
+// GetLiquidationAuctions returns the open liquidation auctions priced at the
+// time of header
+func (l *Lending) GetLiquidationAuctions(header *types.Header, lendingStateDB *lendingstate.LendingStateDB) []*LendingAuctionInfo {
+	now := header.Time.Uint64()
+	auctions := []*LendingAuctionInfo{}
+	for _, auction := range lendingStateDB.GetLendingAuctions() {
+		auctions = append(auctions, &LendingAuctionInfo{
+			LendingBook:     auction.LendingBook,
+			TradeId:         auction.Trade.TradeId,
+			LendingToken:    auction.Trade.LendingToken,
+			CollateralToken: auction.Trade.CollateralToken,
+			Term:            auction.Trade.Term,
+			StartTime:       auction.StartTime,
+			EndTime:         auction.StartTime + lendingstate.LendingAuctionDuration,
+			StartPrice:      auction.StartPrice,
+			Price:           auction.Price(now),
+			Collateral:      auction.Collateral,
+			Remaining:       auction.Remaining(),
+			Closed:          auction.Closed(now),
+		})
+	}
+	return auctions
+}
+
+// OpenLiquidationAuction liquidates a trade by putting its collateral up for a
+// Dutch auction starting at collateralPrice. The auction raises principal,
+// interest and the liquidation penalty of the collateral for the investor. The
+// trade leaves the lending book, its collateral stays locked until the auction
+// is settled by ProcessLiquidationAuctions.
+func (l *Lending) OpenLiquidationAuction(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingstateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64, collateralPrice *big.Int, penalty *big.Int, reason uint64) error {
+	lendingTradeIdHash := common.Uint64ToHash(lendingTradeId)
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, lendingTradeIdHash)
+	if lendingTrade.TradeId != lendingTradeId {
+		return fmt.Errorf("Lending Trade Id not found : %d ", lendingTradeId)
+	}
+	if collateralPrice == nil || collateralPrice.Sign() <= 0 {
+		return fmt.Errorf("OpenLiquidationAuction: invalid collateral price %v", collateralPrice)
+	}
+	// debt = principal + interest, penaltyAmount = debt * penalty / BaseLiquidationPenalty
+	debtAmount := lendingstate.CalculateTotalRepayValue(header.Time.Uint64(), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+	penaltyAmount := new(big.Int).Mul(debtAmount, penalty)
+	penaltyAmount = new(big.Int).Div(penaltyAmount, lendingstate.BaseLiquidationPenalty)
+
+	err := lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTradeId, lendingTrade.LiquidationTime)
+	if err != nil {
+		log.Debug("OpenLiquidationAuction RemoveLiquidationTime", "err", err)
+		return err
+	}
+	err = tradingstateDB.RemoveLiquidationPrice(lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, &lendingTrade), lendingTrade.LiquidationPrice, lendingBook, lendingTradeId)
+	if err != nil {
+		log.Debug("OpenLiquidationAuction RemoveLiquidationPrice", "err", err)
+		return err
+	}
+	err = lendingStateDB.CancelLendingTrade(lendingBook, lendingTradeId)
+	if err != nil {
+		log.Debug("OpenLiquidationAuction CancelLendingTrade", "err", err)
+		return err
+	}
+	lendingStateDB.SetLendingAuction(&lendingstate.LendingAuction{
+		LendingBook: lendingBook,
+		Trade:       lendingTrade,
+		Reason:      reason,
+		StartTime:   header.Time.Uint64(),
+		StartPrice:  new(big.Int).Set(collateralPrice),
+		Debt:        debtAmount,
+		Penalty:     penaltyAmount,
+		Collateral:  new(big.Int).Set(lendingTrade.CollateralLockedAmount),
+		Raised:      new(big.Int),
+	})
+	log.Debug("OpenLiquidationAuction", "lendingBook", lendingBook.Hex(), "tradeId", lendingTradeId, "reason", reason, "price", collateralPrice, "debt", debtAmount, "penalty", penaltyAmount)
+	return nil
+}
+
+// ProcessAuctionBid buys collateral in the liquidation auction of a trade. The
+// bidder pays the auction price in lending token to the investor of the trade.
+func (l *Lending) ProcessAuctionBid(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, order *lendingstate.LendingItem) error {
+	if !chain.Config().IsTIPTomoXLendingAuction(header.Number) {
+		return fmt.Errorf("ProcessAuctionBid: liquidation auctions are not enabled. Type: %s", order.Type)
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(order.LendingToken, order.Term)
+	auction := lendingStateDB.GetLendingAuction(lendingBook, order.LendingTradeId)
+	if auction == nil {
+		return lendingstate.ErrAuctionNotFound
+	}
+	collateralToken := auction.Trade.CollateralToken
+	collateralTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, collateralToken)
+	if err != nil || collateralTokenDecimal == nil || collateralTokenDecimal.Sign() <= 0 {
+		return fmt.Errorf("ProcessAuctionBid: failed to get decimal of collateral %s: %v", collateralToken.Hex(), err)
+	}
+	amount, cost, err := auction.Bid(header.Time.Uint64(), order.Quantity, collateralTokenDecimal)
+	if err != nil {
+		return err
+	}
+	if balance := lendingstate.GetTokenBalance(order.UserAddress, order.LendingToken, statedb); balance.Cmp(cost) < 0 {
+		return fmt.Errorf("ProcessAuctionBid: not enough balance. User: %s. Token: %s. Expected: %v. Have: %v", order.UserAddress.Hex(), order.LendingToken.Hex(), cost, balance)
+	}
+	lendingstate.SubTokenBalance(order.UserAddress, cost, order.LendingToken, statedb)
+	lendingstate.AddTokenBalance(auction.Trade.Investor, cost, order.LendingToken, statedb)
+	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), amount, collateralToken, statedb)
+	lendingstate.AddTokenBalance(order.UserAddress, amount, collateralToken, statedb)
+	lendingStateDB.SetLendingAuction(auction)
+
+	log.Debug("ProcessAuctionBid", "lendingBook", lendingBook.Hex(), "tradeId", order.LendingTradeId, "bidder", order.UserAddress.Hex(), "amount", amount, "cost", cost, "remaining", auction.Remaining())
+	return nil
+}
+
+// ProcessLiquidationAuctions settles the liquidation auctions which raised the
+// debt and penalty or expired. The investor gets what the bids didn't raise in
+// collateral at the floor price, and the borrower gets the rest. It returns
+// the trades of the settled auctions.
+func (l *Lending) ProcessLiquidationAuctions(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB) []*lendingstate.LendingTrade {
+	now := header.Time.Uint64()
+	trades := []*lendingstate.LendingTrade{}
+	for _, auction := range lendingStateDB.GetLendingAuctions() {
+		if !auction.Closed(now) {
+			continue
+		}
+		trade := auction.Trade
+		collateralTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, trade.CollateralToken)
+		if err != nil || collateralTokenDecimal == nil || collateralTokenDecimal.Sign() <= 0 {
+			// leave the auction for the next epoch
+			log.Error("Fail when get collateral decimal to settle an auction", "CollateralToken", trade.CollateralToken.Hex(), "tradeId", trade.TradeId, "error", err)
+			continue
+		}
+		investorAmount, borrowerAmount := auction.Settle(collateralTokenDecimal)
+		lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), auction.Collateral, trade.CollateralToken, statedb)
+		lendingstate.AddTokenBalance(trade.Investor, investorAmount, trade.CollateralToken, statedb)
+		lendingstate.AddTokenBalance(trade.Borrower, borrowerAmount, trade.CollateralToken, statedb)
+		lendingStateDB.DeleteLendingAuction(auction.LendingBook, trade.TradeId)
+
+		trade.Status = lendingstate.TradeStatusLiquidated
+		liquidationData := lendingstate.LiquidationData{
+			RecallAmount:      borrowerAmount,
+			LiquidationAmount: investorAmount,
+			CollateralPrice:   auction.StartPrice,
+			Reason:            lendingstate.LiquidatedByAuction,
+			DebtAmount:        auction.Debt,
+			PenaltyAmount:     auction.Penalty,
+			AuctionProceeds:   auction.Raised,
+		}
+		extraData, _ := json.Marshal(liquidationData)
+		trade.ExtraData = string(extraData)
+		trades = append(trades, &trade)
+		log.Debug("ProcessLiquidationAuctions", "lendingBook", auction.LendingBook.Hex(), "tradeId", trade.TradeId, "reason", auction.Reason, "raised", auction.Raised, "investorAmount", investorAmount, "borrowerAmount", borrowerAmount)
+	}
+	return trades
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/common/math"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+// Liquidation auctions: after the auction fork, the collateral of a liquidated
+// trade whose collateral has a liquidation penalty is sold in a Dutch auction
+// instead of being paid to the investor. The auction price starts at the
+// collateral price and falls by up to LendingAuctionMaxDiscount over
+// LendingAuctionDuration. Bidders buy collateral with the lending token, which
+// goes to the investor, until the principal, interest and penalty are raised.
+// The next liquidation pass settles the auctions which are raised or expired:
+// what the bids didn't raise is paid to the investor in collateral at the floor
+// price, and the rest of the collateral goes back to the borrower. Meanwhile
+// the collateral stays in common.LendingLockAddress.
+
+// LendingAuctionDuration is the time the auction price takes to fall to its
+// floor, in seconds
+const LendingAuctionDuration = uint64(30 * 60)
+
+// LendingAuctionMaxDiscount is the discount of the floor price on the starting
+// price of an auction, in basis points of BaseLiquidationPenalty
+var LendingAuctionMaxDiscount = big.NewInt(2000)
+
+var (
+	ErrAuctionNotFound    = errors.New("no liquidation auction for the lending trade")
+	ErrAuctionClosed      = errors.New("liquidation auction is closed")
+	ErrAuctionBidTooSmall = errors.New("bid buys no collateral at the auction price")
+)
+
+// LendingAuction is the Dutch auction of the collateral of a liquidated trade
+type LendingAuction struct {
+	LendingBook common.Hash
+	Trade       LendingTrade // as of the opening of the auction
+	Reason      uint64       // what the trade was liquidated by, time or price
+	StartTime   uint64
+	StartPrice  *big.Int // collateral price when the auction opened
+	Debt        *big.Int // principal and interest, in lending token
+	Penalty     *big.Int // in lending token
+	Collateral  *big.Int // collateral left for sale
+	Raised      *big.Int // lending token paid by the bidders
+}
+
+// LendingAuctionRef identifies the auction of a trade
+type LendingAuctionRef struct {
+	LendingBook common.Hash
+	TradeId     uint64
+}
+
+// lendingAuctionList lists the open auctions in their opening order
+type lendingAuctionList struct {
+	Auctions []LendingAuctionRef
+}
+
+// Remaining returns the lending token the auction has yet to raise
+func (a *LendingAuction) Remaining() *big.Int {
+	remaining := new(big.Int).Add(a.Debt, a.Penalty)
+	remaining.Sub(remaining, a.Raised)
+	if remaining.Sign() < 0 {
+		return new(big.Int)
+	}
+	return remaining
+}
+
+// Expired returns whether the price of the auction fell to its floor at now
+func (a *LendingAuction) Expired(now uint64) bool {
+	return now >= a.StartTime+LendingAuctionDuration
+}
+
+// Closed returns whether the auction takes no more bids at now
+func (a *LendingAuction) Closed(now uint64) bool {
+	return a.Remaining().Sign() == 0 || a.Collateral.Sign() == 0 || a.Expired(now)
+}
+
+// Price returns the auction price of the collateral at now
+func (a *LendingAuction) Price(now uint64) *big.Int {
+	elapsed := LendingAuctionDuration
+	if now < a.StartTime+LendingAuctionDuration {
+		elapsed = 0
+		if now > a.StartTime {
+			elapsed = now - a.StartTime
+		}
+	}
+	discount := new(big.Int).Mul(LendingAuctionMaxDiscount, new(big.Int).SetUint64(elapsed))
+	discount.Div(discount, new(big.Int).SetUint64(LendingAuctionDuration))
+	price := new(big.Int).Mul(a.StartPrice, new(big.Int).Sub(BaseLiquidationPenalty, discount))
+	return price.Div(price, BaseLiquidationPenalty)
+}
+
+// collateralFor returns the collateral paying for value at price, rounded up
+func collateralFor(value, price, collateralTokenDecimal *big.Int) *big.Int {
+	amount := new(big.Int).Mul(value, collateralTokenDecimal)
+	amount.Add(amount, new(big.Int).Sub(price, common.Big1))
+	return amount.Div(amount, price)
+}
+
+// Bid sells up to quantity of collateral at the auction price at now, but no
+// more than needed to raise the rest of the debt and penalty. It returns the
+// collateral sold and its cost in lending token.
+func (a *LendingAuction) Bid(now uint64, quantity, collateralTokenDecimal *big.Int) (*big.Int, *big.Int, error) {
+	if a.Closed(now) {
+		return nil, nil, ErrAuctionClosed
+	}
+	price := a.Price(now)
+	remaining := a.Remaining()
+	needed := collateralFor(remaining, price, collateralTokenDecimal)
+	amount := math.BigMin(math.BigMin(quantity, a.Collateral), needed)
+	cost := remaining
+	if amount.Cmp(needed) < 0 {
+		cost = math.BigMin(new(big.Int).Div(new(big.Int).Mul(amount, price), collateralTokenDecimal), remaining)
+	}
+	if amount.Sign() <= 0 || cost.Sign() == 0 {
+		return nil, nil, ErrAuctionBidTooSmall
+	}
+	a.Collateral = new(big.Int).Sub(a.Collateral, amount)
+	a.Raised = new(big.Int).Add(a.Raised, cost)
+	return new(big.Int).Set(amount), cost, nil
+}
+
+// Settle splits the collateral left between the investor, who gets what the
+// bids didn't raise at the floor price, and the borrower.
+func (a *LendingAuction) Settle(collateralTokenDecimal *big.Int) (investorAmount, borrowerAmount *big.Int) {
+	investorAmount = new(big.Int)
+	if remaining := a.Remaining(); remaining.Sign() > 0 {
+		floor := a.Price(a.StartTime + LendingAuctionDuration)
+		investorAmount = math.BigMin(collateralFor(remaining, floor, collateralTokenDecimal), a.Collateral)
+	}
+	return investorAmount, new(big.Int).Sub(a.Collateral, investorAmount)
+}
+
+// GetLendingAuctionHash returns the key of the auction of a trade in the
+// lending state trie
+func GetLendingAuctionHash(lendingBook common.Hash, tradeId uint64) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), common.Uint64ToHash(tradeId).Bytes(), []byte("auction"))
+}
+
+var lendingAuctionListHash = crypto.Keccak256Hash([]byte("auctions"))
+
+func (self *LendingStateDB) getLendingAuctionList() []LendingAuctionRef {
+	var list lendingAuctionList
+	self.getRecord(lendingAuctionListHash, auctionListRecord, &list)
+	return list.Auctions
+}
+
+func (self *LendingStateDB) setLendingAuctionList(refs []LendingAuctionRef) {
+	if len(refs) == 0 {
+		self.deleteRecord(lendingAuctionListHash)
+		return
+	}
+	self.setRecord(lendingAuctionListHash, auctionListRecord, lendingAuctionList{Auctions: refs})
+}
+
+// GetLendingAuction returns the auction of a trade, or nil
+func (self *LendingStateDB) GetLendingAuction(lendingBook common.Hash, tradeId uint64) *LendingAuction {
+	auction := new(LendingAuction)
+	if !self.getRecord(GetLendingAuctionHash(lendingBook, tradeId), auctionRecord, auction) {
+		return nil
+	}
+	return auction
+}
+
+// GetLendingAuctions returns the open auctions in their opening order
+func (self *LendingStateDB) GetLendingAuctions() []*LendingAuction {
+	auctions := []*LendingAuction{}
+	for _, ref := range self.getLendingAuctionList() {
+		if auction := self.GetLendingAuction(ref.LendingBook, ref.TradeId); auction != nil {
+			auctions = append(auctions, auction)
+		}
+	}
+	return auctions
+}
+
+// SetLendingAuction stores an auction, listing it if it is new
+func (self *LendingStateDB) SetLendingAuction(auction *LendingAuction) {
+	tradeId := auction.Trade.TradeId
+	if self.GetLendingAuction(auction.LendingBook, tradeId) == nil {
+		refs := self.getLendingAuctionList()
+		self.setLendingAuctionList(append(refs, LendingAuctionRef{LendingBook: auction.LendingBook, TradeId: tradeId}))
+	}
+	self.setRecord(GetLendingAuctionHash(auction.LendingBook, tradeId), auctionRecord, auction)
+}
+
+// DeleteLendingAuction removes the auction of a trade
+func (self *LendingStateDB) DeleteLendingAuction(lendingBook common.Hash, tradeId uint64) {
+	refs := []LendingAuctionRef{}
+	for _, ref := range self.getLendingAuctionList() {
+		if ref.LendingBook != lendingBook || ref.TradeId != tradeId {
+			refs = append(refs, ref)
+		}
+	}
+	self.setLendingAuctionList(refs)
+	self.deleteRecord(GetLendingAuctionHash(lendingBook, tradeId))
+}
@@ -0,0 +1,114 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func newTestAuction() *LendingAuction {
+	return &LendingAuction{
+		LendingBook: common.HexToHash("0xa1"),
+		Trade:       LendingTrade{TradeId: 1, CollateralLockedAmount: big.NewInt(5000), Amount: big.NewInt(1000)},
+		StartTime:   1000,
+		StartPrice:  big.NewInt(350),
+		Debt:        big.NewInt(1050),
+		Penalty:     big.NewInt(52),
+		Collateral:  big.NewInt(5000),
+		Raised:      new(big.Int),
+	}
+}
+
+func TestLendingAuctionBid(t *testing.T) {
+	decimal := big.NewInt(1000)
+	auction := newTestAuction()
+
+	// the price falls linearly to 80% of the starting price
+	for _, tt := range []struct {
+		elapsed uint64
+		want    int64
+	}{
+		{0, 350}, {LendingAuctionDuration / 2, 315}, {LendingAuctionDuration, 280}, {2 * LendingAuctionDuration, 280},
+	} {
+		if have := auction.Price(auction.StartTime + tt.elapsed); have.Int64() != tt.want {
+			t.Errorf("price after %ds mismatch: have %v, want %d", tt.elapsed, have, tt.want)
+		}
+	}
+	// a bid above the target only buys the collateral raising the rest
+	if amount, cost, err := auction.Bid(auction.StartTime, big.NewInt(1000), decimal); err != nil || amount.Int64() != 1000 || cost.Int64() != 350 {
+		t.Fatalf("first bid mismatch: amount %v cost %v err %v", amount, cost, err)
+	}
+	if amount, cost, err := auction.Bid(auction.StartTime, big.NewInt(5000), decimal); err != nil || amount.Int64() != 2149 || cost.Int64() != 752 {
+		t.Fatalf("second bid mismatch: amount %v cost %v err %v", amount, cost, err)
+	}
+	if !auction.Closed(auction.StartTime) {
+		t.Error("raised auction still open")
+	}
+	if _, _, err := auction.Bid(auction.StartTime, big.NewInt(1), decimal); err != ErrAuctionClosed {
+		t.Errorf("bid in a raised auction: have %v, want %v", err, ErrAuctionClosed)
+	}
+	if investor, borrower := auction.Settle(decimal); investor.Sign() != 0 || borrower.Int64() != 1851 {
+		t.Errorf("settlement mismatch: investor %v borrower %v, want 0 and 1851", investor, borrower)
+	}
+
+	// without bids the investor gets the target at the floor price, capped
+	// to the collateral
+	auction = newTestAuction()
+	if investor, borrower := auction.Settle(decimal); investor.Int64() != 3936 || borrower.Int64() != 1064 {
+		t.Errorf("settlement mismatch: investor %v borrower %v, want 3936 and 1064", investor, borrower)
+	}
+	auction.Collateral = big.NewInt(3000)
+	if investor, borrower := auction.Settle(decimal); investor.Int64() != 3000 || borrower.Sign() != 0 {
+		t.Errorf("settlement mismatch: investor %v borrower %v, want 3000 and 0", investor, borrower)
+	}
+	if _, _, err := auction.Bid(auction.StartTime, big.NewInt(1), big.NewInt(1000000)); err != ErrAuctionBidTooSmall {
+		t.Errorf("bid below the price: have %v, want %v", err, ErrAuctionBidTooSmall)
+	}
+}
+
+func TestLendingAuctionState(t *testing.T) {
+	lendingCache := NewDatabase(rawdb.NewMemoryDatabase())
+	lendingStateDb, _ := New(common.Hash{}, lendingCache)
+
+	// auctions are kept in the lending state trie next to the lending books
+	lendingStateDb.InsertTradingItem(common.HexToHash("0xa2"), 2, LendingTrade{TradeId: 2, Amount: big.NewInt(1)})
+	first, second := newTestAuction(), newTestAuction()
+	second.Trade.TradeId = 2
+	lendingStateDb.SetLendingAuction(first)
+	lendingStateDb.SetLendingAuction(second)
+	root, err := lendingStateDb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit the lending state: %v", err)
+	}
+	if lendingStateDb, err = New(root, lendingCache); err != nil {
+		t.Fatalf("failed to reopen the lending state: %v", err)
+	}
+	if auctions := lendingStateDb.GetLendingAuctions(); len(auctions) != 2 || auctions[0].Trade.TradeId != 1 || auctions[1].Debt.Int64() != 1050 {
+		t.Fatalf("committed auctions mismatch: %v", auctions)
+	}
+
+	// a reverted bid and deletion leave the auctions untouched
+	snap := lendingStateDb.Snapshot()
+	auction := lendingStateDb.GetLendingAuction(first.LendingBook, 1)
+	auction.Raised = big.NewInt(100)
+	lendingStateDb.SetLendingAuction(auction)
+	lendingStateDb.DeleteLendingAuction(second.LendingBook, 2)
+	if auctions := lendingStateDb.GetLendingAuctions(); len(auctions) != 1 || auctions[0].Raised.Int64() != 100 {
+		t.Fatalf("updated auctions mismatch: %v", auctions)
+	}
+	lendingStateDb.RevertToSnapshot(snap)
+	if auctions := lendingStateDb.GetLendingAuctions(); len(auctions) != 2 || auctions[0].Raised.Sign() != 0 {
+		t.Fatalf("reverted auctions mismatch: %v", auctions)
+	}
+	if lendingStateDb.IntermediateRoot() != root {
+		t.Error("reverted lending state root mismatch")
+	}
+
+	lendingStateDb.DeleteLendingAuction(first.LendingBook, 1)
+	lendingStateDb.DeleteLendingAuction(second.LendingBook, 2)
+	if lendingStateDb.GetLendingAuction(first.LendingBook, 1) != nil || len(lendingStateDb.GetLendingAuctions()) != 0 {
+		t.Error("deleted auctions still in the lending state")
+	}
+}
@@ -40,17 +40,54 @@ type lendingObject struct {
 	LendingTradeRoot    common.Hash
 }
 
+// lendingPoolObject is the variable-rate pool of a lending token, stored in the
+// lending state trie next to the lending books. The positions of the users are
+// kept in a nested trie, keyed by their address.
+type lendingPoolObject struct {
+	TotalDeposits      *big.Int
+	TotalDepositShares *big.Int
+	TotalBorrows       *big.Int
+	TotalBorrowShares  *big.Int
+	LastBlock          uint64
+	PositionRoot       common.Hash
+}
+
 // liquidation reasons
 const (
-	LiquidatedByTime  = uint64(0)
-	LiquidatedByPrice = uint64(1)
+	LiquidatedByTime    = uint64(0)
+	LiquidatedByPrice   = uint64(1)
+	LiquidatedByAuction = uint64(2)
+	LiquidatedByMargin  = uint64(3)
 )
 
+// BaseCollateralRate is the base of the deposit, liquidation and recall rates
+// of the collaterals, in percent
+var BaseCollateralRate = big.NewInt(100)
+
+// BaseLiquidationPenalty is the base of the auction liquidation penalty, in basis points
+var BaseLiquidationPenalty = big.NewInt(10000)
+
 type LiquidationData struct {
 	RecallAmount      *big.Int
 	LiquidationAmount *big.Int
 	CollateralPrice   *big.Int
 	Reason            uint64
+	// auction liquidations only, in lending token
+	DebtAmount      *big.Int `json:",omitempty"`
+	PenaltyAmount   *big.Int `json:",omitempty"`
+	AuctionProceeds *big.Int `json:",omitempty"` // paid by the bidders to the investor
+	// margin liquidations only, pooled collaterals paid to the investor.
+	// CollateralPrice is then the price of the collateral in TOMO.
+	PooledAmounts map[common.Address]*big.Int `json:",omitempty"`
+}
+
+// PartialRepayData is the extra data of a partially repaid lending trade
+type PartialRepayData struct {
+	RepayAmount     *big.Int // paid by the borrower, in lending token
+	PrincipalAmount *big.Int // part of RepayAmount which reduces the trade amount
+	Profit          *big.Int // part of RepayAmount which pays interest to the investor
+	ReleasedAmount  *big.Int // collateral returned to the borrower
+	RemainingAmount *big.Int // trade amount left after the repayment
 }
 
 var (
@@ -113,6 +150,7 @@ type LendingItemHistoryItem struct {
 
 type LendingTradeHistoryItem struct {
 	TxHash                 common.Hash
+	Amount                 *big.Int
 	CollateralLockedAmount *big.Int
 	LiquidationPrice       *big.Int
 	Status                 string
@@ -182,6 +220,12 @@ func GetLendingOrderBookHash(lendingToken common.Address, term uint64) common.Ha
 	return crypto.Keccak256Hash(append(common.Uint64ToHash(term).Bytes(), lendingToken.Bytes()...))
 }
 
+// GetLendingPoolHash returns the key of the variable-rate pool of lendingToken
+// in the lending state trie
+func GetLendingPoolHash(lendingToken common.Address) common.Hash {
+	return crypto.Keccak256Hash(lendingToken.Bytes(), []byte("pool"))
+}
+
 func EncodeTxLendingBatch(batch TxLendingBatch) ([]byte, error) {
 	data, err := json.Marshal(batch)
 	if err != nil || data == nil {
@@ -73,6 +73,24 @@ type (
 		tradeId   common.Hash
 		prev      *big.Int
 	}
+	tradeAmountChange struct {
+		orderBook common.Hash
+		tradeId   common.Hash
+		prev      *big.Int
+	}
+	lendingPoolChange struct {
+		lendingToken common.Address
+		prev         *LendingPool
+	}
+	poolPositionChange struct {
+		lendingToken common.Address
+		user         common.Address
+		prev         PoolPosition
+	}
+	recordChange struct {
+		hash common.Hash
+		prev []byte
+	}
 )
 
 func (ch insertOrder) undo(s *LendingStateDB) {
@@ -136,3 +154,27 @@ func (ch collateralLockedAmount) undo(s *LendingStateDB) {
 	}
 	stateLendingTrade.SetCollateralLockedAmount(ch.prev)
 }
+
+func (ch tradeAmountChange) undo(s *LendingStateDB) {
+	stateOrderBook := s.getLendingExchange(ch.orderBook)
+	if stateOrderBook == nil {
+		return
+	}
+	stateLendingTrade := stateOrderBook.getLendingTrade(s.db, ch.tradeId)
+	if stateLendingTrade == nil {
+		return
+	}
+	stateLendingTrade.SetAmount(ch.prev)
+}
+
+func (ch lendingPoolChange) undo(s *LendingStateDB) {
+	s.getLendingPool(ch.lendingToken).setPool(ch.prev)
+}
+
+func (ch poolPositionChange) undo(s *LendingStateDB) {
+	s.getLendingPool(ch.lendingToken).setPosition(ch.user.Hash(), ch.prev)
+}
+
+func (ch recordChange) undo(s *LendingStateDB) {
+	s.putRecord(ch.hash, ch.prev)
+}
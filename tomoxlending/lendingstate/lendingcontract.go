@@ -17,6 +17,7 @@ var (
 	SupportedBaseSlot         = uint64(3)
 	SupportedTermSlot         = uint64(4)
 	ILOCollateralSlot         = uint64(5)
+	LendingPoolMapSlot        = uint64(6)
 	LendingRelayerStructSlots = map[string]*big.Int{
 		"fee":         big.NewInt(0),
 		"bases":       big.NewInt(1),
@@ -28,6 +29,18 @@ var (
 		"liquidationRate": big.NewInt(1),
 		"recallRate":      big.NewInt(2),
 		"price":           big.NewInt(3),
+		// price oracle, see OracleConfig
+		"oracleSources":      big.NewInt(4),
+		"oracleMaxAge":       big.NewInt(5),
+		"oracleMaxDeviation": big.NewInt(6),
+		// auction liquidation penalty in basis points, 0 = liquidate all collateral
+		"liquidationPenalty": big.NewInt(7),
+	}
+	LendingPoolStructSlots = map[string]*big.Int{
+		"baseRate":           big.NewInt(0),
+		"slope1":             big.NewInt(1),
+		"slope2":             big.NewInt(2),
+		"optimalUtilization": big.NewInt(3),
 	}
 	PriceStructSlots = map[string]*big.Int{
 		"price":       big.NewInt(0),
@@ -202,6 +215,62 @@ func GetCollateralPrice(statedb *state.StateDB, collateralToken common.Address,
 	return price, blockNumber
 }
 
+// @function GetCollateralOracleConfig
+// @param statedb : current state
+// @param token: address of collateral token
+// @return: price oracle configuration of the collateral, disabled if no source is set
+func GetCollateralOracleConfig(statedb *state.StateDB, token common.Address) OracleConfig {
+	collateralState := GetLocMappingAtKey(token.Hash(), CollateralMapSlot)
+	locSources := state.GetLocOfStructElement(collateralState, CollateralStructSlots["oracleSources"])
+	locMaxAge := state.GetLocOfStructElement(collateralState, CollateralStructSlots["oracleMaxAge"])
+	locMaxDeviation := state.GetLocOfStructElement(collateralState, CollateralStructSlots["oracleMaxDeviation"])
+	return OracleConfig{
+		Sources:      statedb.GetState(common.HexToAddress(common.LendingRegistrationSMC), locSources).Big().Uint64(),
+		MaxAge:       statedb.GetState(common.HexToAddress(common.LendingRegistrationSMC), locMaxAge).Big().Uint64(),
+		MaxDeviation: statedb.GetState(common.HexToAddress(common.LendingRegistrationSMC), locMaxDeviation).Big(),
+	}
+}
+
+// @function GetCollateralLiquidationPenalty
+// @param statedb : current state
+// @param token: address of collateral token
+// @return: liquidation penalty of the collateral in basis points, auction liquidation is disabled if it is zero
+func GetCollateralLiquidationPenalty(statedb *state.StateDB, token common.Address) *big.Int {
+	collateralState := GetLocMappingAtKey(token.Hash(), CollateralMapSlot)
+	locPenalty := state.GetLocOfStructElement(collateralState, CollateralStructSlots["liquidationPenalty"])
+	return statedb.GetState(common.HexToAddress(common.LendingRegistrationSMC), locPenalty).Big()
+}
+
+// @function GetLendingPoolConfig
+// @param statedb : current state
+// @param lendingToken: address of lending token
+// @return: utilization curve of the variable-rate pool of the token, disabled if no optimal utilization is set
+func GetLendingPoolConfig(statedb *state.StateDB, lendingToken common.Address) PoolConfig {
+	poolState := GetLocMappingAtKey(lendingToken.Hash(), LendingPoolMapSlot)
+	get := func(field string) *big.Int {
+		return statedb.GetState(common.HexToAddress(common.LendingRegistrationSMC), state.GetLocOfStructElement(poolState, LendingPoolStructSlots[field])).Big()
+	}
+	return PoolConfig{
+		BaseRate:           get("baseRate"),
+		Slope1:             get("slope1"),
+		Slope2:             get("slope2"),
+		OptimalUtilization: get("optimalUtilization"),
+	}
+}
+
+// @function GetLendingPoolTokens
+// @param statedb : current state
+// @return: list of lending tokens which have a variable-rate pool
+func GetLendingPoolTokens(statedb *state.StateDB) []common.Address {
+	tokens := []common.Address{}
+	for _, token := range GetSupportedBaseToken(statedb) {
+		if (token != common.Address{}) && GetLendingPoolConfig(statedb, token).Enabled() {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
 // @function GetSupportedTerms
 // @param statedb : current state
 // @return: list of terms which tomoxlending supports
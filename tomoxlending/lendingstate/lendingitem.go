@@ -26,6 +26,14 @@ const (
 	LendingStatusCancelled     = "CANCELLED"
 	Market                     = "MO"
 	Limit                      = "LO"
+	PoolDeposit                = "POOL_DEPOSIT"
+	PoolWithdraw               = "POOL_WITHDRAW"
+	PoolBorrow                 = "POOL_BORROW"
+	PoolRepay                  = "POOL_REPAY"
+	MarginDeposit              = "MARGIN_DEPOSIT"
+	MarginWithdraw             = "MARGIN_WITHDRAW"
+	Rollover                   = "ROLLOVER"
+	AuctionBid                 = "AUCTION_BID"
 )
 
 var ValidInputLendingStatus = map[string]bool{
@@ -39,6 +47,28 @@ var ValidInputLendingType = map[string]bool{
 	Repay:  true,
 	TopUp:  true,
 	Recall: true,
+
+	PoolDeposit:  true,
+	PoolWithdraw: true,
+	PoolBorrow:   true,
+	PoolRepay:    true,
+
+	MarginDeposit:  true,
+	MarginWithdraw: true,
+
+	Rollover: true,
+
+	AuctionBid: true,
+}
+
+// IsPoolLendingType returns whether lendingType is an item of a variable-rate pool
+func IsPoolLendingType(lendingType string) bool {
+	return lendingType == PoolDeposit || lendingType == PoolWithdraw || lendingType == PoolBorrow || lendingType == PoolRepay
+}
+
+// IsMarginLendingType returns whether lendingType is an item of a margin account
+func IsMarginLendingType(lendingType string) bool {
+	return lendingType == MarginDeposit || lendingType == MarginWithdraw
 }
 
 // Signature struct
@@ -76,6 +106,11 @@ type LendingItem struct {
 	LendingId       uint64         `bson:"lendingId" json:"lendingId"`
 	LendingTradeId  uint64         `bson:"tradeId" json:"tradeId"`
 	ExtraData       string         `bson:"extraData" json:"extraData"`
+
+	// Optional self-trade prevention mode, kept apart from the extra data
+	// overwritten by cancellations and left out of the encoding of items
+	// without one
+	SelfTradeMode []string `bson:"selfTradePrevention,omitempty" json:"selfTradePrevention,omitempty" rlp:"tail"`
 }
 
 type LendingItemBSON struct {
@@ -100,6 +135,8 @@ type LendingItemBSON struct {
 	LendingId       string           `bson:"lendingId" json:"lendingId"`
 	LendingTradeId  string           `bson:"tradeId" json:"tradeId"`
 	ExtraData       string           `bson:"extraData" json:"extraData"`
+
+	SelfTradePrevention string `bson:"selfTradePrevention,omitempty" json:"selfTradePrevention,omitempty"`
 }
 
 func (l *LendingItem) GetBSON() (interface{}, error) {
@@ -123,6 +160,8 @@ func (l *LendingItem) GetBSON() (interface{}, error) {
 		LendingId:       strconv.FormatUint(l.LendingId, 10),
 		LendingTradeId:  strconv.FormatUint(l.LendingTradeId, 10),
 		ExtraData:       l.ExtraData,
+
+		SelfTradePrevention: l.SelfTradePrevention(),
 	}
 
 	if l.FilledAmount != nil {
@@ -191,13 +230,29 @@ func (l *LendingItem) SetBSON(raw bson.Raw) error {
 	}
 	l.LendingTradeId = uint64(lendingTradeId)
 	l.ExtraData = decoded.ExtraData
+	l.SetSelfTradePrevention(decoded.SelfTradePrevention)
 	return nil
 }
 
-func (l *LendingItem) VerifyLendingItem(state *state.StateDB) error {
+func (l *LendingItem) VerifyLendingItem(state *state.StateDB, signer types.LendingSigner) error {
+	if err := l.VerifyUnsignedLendingItem(state); err != nil {
+		return err
+	}
+	return l.VerifyLendingSignature(signer)
+}
+
+// VerifyUnsignedLendingItem verify lendingItem without checking its signature,
+// it is used to simulate lending orders which have not been signed yet
+func (l *LendingItem) VerifyUnsignedLendingItem(state *state.StateDB) error {
 	if err := l.VerifyLendingStatus(); err != nil {
 		return err
 	}
+	if IsPoolLendingType(l.Type) {
+		return l.VerifyPoolLendingItem(state)
+	}
+	if IsMarginLendingType(l.Type) {
+		return l.VerifyMarginLendingItem(state)
+	}
 	if valid, _ := IsValidPair(state, l.Relayer, l.LendingToken, l.Term); valid == false {
 		return fmt.Errorf("invalid pair . LendToken %s . Term: %v", l.LendingToken.Hex(), l.Term)
 	}
@@ -205,7 +260,7 @@ func (l *LendingItem) VerifyLendingItem(state *state.StateDB) error {
 		if err := l.VerifyLendingType(); err != nil {
 			return err
 		}
-		if l.Type != Repay {
+		if l.Type != Repay && l.Type != Rollover {
 			if err := l.VerifyLendingQuantity(); err != nil {
 				return err
 			}
@@ -214,6 +269,9 @@ func (l *LendingItem) VerifyLendingItem(state *state.StateDB) error {
 			if err := l.VerifyLendingSide(); err != nil {
 				return err
 			}
+			if err := l.VerifyLendingSelfTradePrevention(); err != nil {
+				return err
+			}
 			if l.Side == Borrowing {
 				if err := l.VerifyCollateral(state); err != nil {
 					return err
@@ -225,13 +283,105 @@ func (l *LendingItem) VerifyLendingItem(state *state.StateDB) error {
 				return err
 			}
 		}
+		if l.Type == Rollover {
+			if err := l.VerifyRolloverLendingItem(state); err != nil {
+				return err
+			}
+		}
+	}
+	if !IsValidRelayer(state, l.Relayer) {
+		return fmt.Errorf("VerifyLendingItem: invalid relayer. address: %s", l.Relayer.Hex())
+	}
+	return nil
+}
+
+// VerifyPoolLendingItem verifies an item of the variable-rate pool of the lending token
+func (l *LendingItem) VerifyPoolLendingItem(state *state.StateDB) error {
+	if l.Status != LendingStatusNew {
+		return fmt.Errorf("VerifyPoolLendingItem: invalid status of pool item. Status: %s", l.Status)
+	}
+	if !GetLendingPoolConfig(state, l.LendingToken).Enabled() {
+		return fmt.Errorf("VerifyPoolLendingItem: %v. LendToken %s", ErrPoolNotEnabled, l.LendingToken.Hex())
+	}
+	if err := l.VerifyLendingQuantity(); err != nil {
+		return err
+	}
+	if l.Type == PoolBorrow {
+		if err := VerifyPoolCollateral(state, l.LendingToken, l.CollateralToken); err != nil {
+			return err
+		}
+	}
+	if !IsValidRelayer(state, l.Relayer) {
+		return fmt.Errorf("VerifyLendingItem: invalid relayer. address: %s", l.Relayer.Hex())
+	}
+	return nil
+}
+
+// VerifyPoolCollateral checks that collateralToken is a registered collateral
+// which can back a pool borrow of lendingToken
+func VerifyPoolCollateral(state *state.StateDB, lendingToken, collateralToken common.Address) error {
+	if collateralToken.String() == EmptyAddress || collateralToken.String() == lendingToken.String() {
+		return fmt.Errorf("invalid collateral %s", collateralToken.Hex())
+	}
+	if depositRate, _, _ := GetCollateralDetail(state, collateralToken); depositRate == nil || depositRate.Sign() <= 0 {
+		return fmt.Errorf("invalid collateral %s", collateralToken.Hex())
+	}
+	return nil
+}
+
+// VerifyMarginLendingItem verifies a deposit into or a withdrawal from the
+// margin account of the user
+func (l *LendingItem) VerifyMarginLendingItem(state *state.StateDB) error {
+	if l.Status != LendingStatusNew {
+		return fmt.Errorf("VerifyMarginLendingItem: invalid status of margin item. Status: %s", l.Status)
+	}
+	if err := l.VerifyLendingQuantity(); err != nil {
+		return err
+	}
+	if err := VerifyMarginCollateral(state, l.CollateralToken); err != nil {
+		return err
 	}
 	if !IsValidRelayer(state, l.Relayer) {
 		return fmt.Errorf("VerifyLendingItem: invalid relayer. address: %s", l.Relayer.Hex())
 	}
-	if err := l.VerifyLendingSignature(); err != nil {
+	return nil
+}
+
+// VerifyMarginCollateral checks that collateralToken is a registered collateral
+// which can be pooled in a margin account
+func VerifyMarginCollateral(state *state.StateDB, collateralToken common.Address) error {
+	if collateralToken.String() == EmptyAddress {
+		return fmt.Errorf("invalid collateral %s", collateralToken.Hex())
+	}
+	depositRate, liquidationRate, _ := GetCollateralDetail(state, collateralToken)
+	if depositRate == nil || depositRate.Sign() <= 0 || liquidationRate == nil || liquidationRate.Sign() <= 0 {
+		return fmt.Errorf("invalid collateral %s", collateralToken.Hex())
+	}
+	return nil
+}
+
+// VerifyRolloverLendingItem verifies a rollover proposed by the borrower of a
+// trade or answered by its investor
+func (l *LendingItem) VerifyRolloverLendingItem(state *state.StateDB) error {
+	if err := l.VerifyLendingSide(); err != nil {
+		return err
+	}
+	data, err := DecodeRolloverData(l.ExtraData)
+	if err != nil {
 		return err
 	}
+	if data.Decline {
+		if l.Side != Investing {
+			return fmt.Errorf("VerifyRolloverLendingItem: only the investor can decline a rollover")
+		}
+		return nil
+	}
+	if err := l.VerifyLendingInterest(); err != nil {
+		return err
+	}
+	if valid, _ := IsValidPair(state, l.Relayer, l.LendingToken, data.Term); !valid {
+		return fmt.Errorf("VerifyRolloverLendingItem: invalid pair. LendToken %s . Term: %v", l.LendingToken.Hex(), data.Term)
+	}
 	return nil
 }
 
@@ -242,6 +392,31 @@ func (l *LendingItem) VerifyLendingSide() error {
 	return nil
 }
 
+func (l *LendingItem) VerifyLendingSelfTradePrevention() error {
+	if mode := l.SelfTradePrevention(); mode != "" && !types.ValidSelfTradePrevention[mode] {
+		return fmt.Errorf("VerifyLendingSelfTradePrevention: unsupported self-trade prevention mode . Mode: %s", mode)
+	}
+	return nil
+}
+
+// SelfTradePrevention returns the self-trade prevention mode of the lending
+// item, it is empty if the item may match the resting items of its user
+func (l *LendingItem) SelfTradePrevention() string {
+	if len(l.SelfTradeMode) == 0 {
+		return ""
+	}
+	return l.SelfTradeMode[0]
+}
+
+// SetSelfTradePrevention sets the self-trade prevention mode of the lending item
+func (l *LendingItem) SetSelfTradePrevention(mode string) {
+	if mode == "" {
+		l.SelfTradeMode = nil
+	} else {
+		l.SelfTradeMode = []string{mode}
+	}
+}
+
 func (l *LendingItem) VerifyCollateral(state *state.StateDB) error {
 	if l.CollateralToken.String() == EmptyAddress || l.CollateralToken.String() == l.LendingToken.String() {
 		return fmt.Errorf("invalid collateral %s", l.CollateralToken.Hex())
@@ -330,7 +505,10 @@ func (l *LendingItem) EncodedSide() *big.Int {
 }
 
 //verify signatures
-func (l *LendingItem) VerifyLendingSignature() error {
+func (l *LendingItem) VerifyLendingSignature(signer types.LendingSigner) error {
+	if l.Signature == nil {
+		return fmt.Errorf("verify lending item: missing signature")
+	}
 	V := big.NewInt(int64(l.Signature.V))
 	R := l.Signature.R.Big()
 	S := l.Signature.S.Big()
@@ -338,8 +516,9 @@ func (l *LendingItem) VerifyLendingSignature() error {
 	//(nonce uint64, quantity *big.Int, interest, duration uint64, relayerAddress, userAddress, lendingToken, collateralToken common.Address, status, side, typeLending string, hash common.Hash, id uint64
 	tx := types.NewLendingTransaction(l.Nonce.Uint64(), l.Quantity, l.Interest.Uint64(), l.Term, l.Relayer, l.UserAddress,
 		l.LendingToken, l.CollateralToken, l.AutoTopUp, l.Status, l.Side, l.Type, l.Hash, l.LendingId, l.LendingTradeId, l.ExtraData)
+	tx.SetSelfTradePrevention(l.SelfTradePrevention())
 	tx.ImportSignature(V, R, S)
-	from, _ := types.LendingSender(types.LendingTxSigner{}, tx)
+	from, _ := types.LendingSender(signer, tx)
 	if from != tx.UserAddress() {
 		return fmt.Errorf("verify lending item: invalid signature")
 	}
@@ -372,6 +551,10 @@ func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingState
 		}
 		tokenBalance := GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
 		paymentBalance := CalculateTotalRepayValue(uint64(time.Now().Unix()), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+		// partial repayment: borrower only needs the requested amount
+		if quantity != nil && quantity.Sign() > 0 && quantity.Cmp(paymentBalance) < 0 {
+			paymentBalance = quantity
+		}
 
 		if tokenBalance.Cmp(paymentBalance) < 0 {
 			return fmt.Errorf("VerifyBalance: not enough balance to process payment for lendingTrade."+
@@ -379,6 +562,81 @@ func VerifyBalance(isTomoXLendingFork bool, statedb *state.StateDB, lendingState
 				lendingTradeId, lendingTrade.LendingToken.Hex(), paymentBalance.String(), tokenBalance.String())
 
 		}
+	case AuctionBid:
+		auction := lendingStateDb.GetLendingAuction(GetLendingOrderBookHash(lendingToken, term), lendingTradeId)
+		if auction == nil {
+			return fmt.Errorf("VerifyBalance: %v. lendingTradeId: %v", ErrAuctionNotFound, lendingTradeId)
+		}
+		now := uint64(time.Now().Unix())
+		if auction.Closed(now) {
+			return fmt.Errorf("VerifyBalance: %v. lendingTradeId: %v", ErrAuctionClosed, lendingTradeId)
+		}
+		// the bidder pays at most the current price of the quantity
+		expectedBalance := new(big.Int).Mul(quantity, auction.Price(now))
+		expectedBalance = new(big.Int).Div(expectedBalance, collateralTokenDecimal)
+		if remaining := auction.Remaining(); expectedBalance.Cmp(remaining) > 0 {
+			expectedBalance = remaining
+		}
+		if tokenBalance := GetTokenBalance(userAddress, lendingToken, statedb); tokenBalance.Cmp(expectedBalance) < 0 {
+			return fmt.Errorf("VerifyBalance: not enough balance to bid in the auction of lendingTrade."+
+				"lendingTradeId: %v. Token: %s. ExpectedBalance: %s. ActualBalance: %s",
+				lendingTradeId, lendingToken.Hex(), expectedBalance.String(), tokenBalance.String())
+		}
+	case Rollover:
+		lendingBook := GetLendingOrderBookHash(lendingToken, term)
+		lendingTrade := lendingStateDb.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+		if lendingTrade == EmptyLendingTrade {
+			return fmt.Errorf("VerifyBalance: rollover of emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+		}
+		if side == Investing && lendingStateDb.GetRolloverProposal(lendingBook, lendingTradeId) == nil {
+			return fmt.Errorf("VerifyBalance: %v. lendingTradeId: %v", ErrRolloverNotProposed, lendingTradeId)
+		}
+	case PoolDeposit:
+		if balance := GetTokenBalance(userAddress, lendingToken, statedb); balance.Cmp(quantity) < 0 {
+			return fmt.Errorf("VerifyBalance: investor doesn't have enough lendingToken. User: %s. Token: %s. Expected: %v. Have: %v", userAddress.Hex(), lendingToken.Hex(), quantity, balance)
+		}
+	case PoolRepay:
+		position := lendingStateDb.GetPoolPosition(lendingToken, userAddress)
+		if position.BorrowShares.Sign() == 0 {
+			return fmt.Errorf("VerifyBalance: %v. User: %s. Token: %s", ErrPoolEmptyPosition, userAddress.Hex(), lendingToken.Hex())
+		}
+		// borrower only pays the debt if it is less than the requested amount
+		paymentBalance := lendingStateDb.GetLendingPool(lendingToken).BorrowValue(position.BorrowShares)
+		if quantity.Cmp(paymentBalance) < 0 {
+			paymentBalance = quantity
+		}
+		if tokenBalance := GetTokenBalance(userAddress, lendingToken, statedb); tokenBalance.Cmp(paymentBalance) < 0 {
+			return fmt.Errorf("VerifyBalance: not enough balance to repay the pool. User: %s. Token: %s. ExpectedBalance: %s. ActualBalance: %s",
+				userAddress.Hex(), lendingToken.Hex(), paymentBalance.String(), tokenBalance.String())
+		}
+	case PoolWithdraw:
+		if position := lendingStateDb.GetPoolPosition(lendingToken, userAddress); position.DepositShares.Sign() == 0 {
+			return fmt.Errorf("VerifyBalance: %v. User: %s. Token: %s", ErrPoolEmptyPosition, userAddress.Hex(), lendingToken.Hex())
+		}
+	case PoolBorrow:
+		if collateralPrice == nil || collateralPrice.Sign() <= 0 {
+			return ErrInvalidCollateralPrice
+		}
+		pool := lendingStateDb.GetLendingPool(lendingToken)
+		if quantity.Cmp(pool.Liquidity()) > 0 {
+			return fmt.Errorf("VerifyBalance: %v. Token: %s. Expected: %v. Have: %v", ErrPoolInsufficientLiquidity, lendingToken.Hex(), quantity, pool.Liquidity())
+		}
+		position := lendingStateDb.GetPoolPosition(lendingToken, userAddress)
+		depositRate, _, _ := GetCollateralDetail(statedb, collateralToken)
+		debt := new(big.Int).Add(pool.BorrowValue(position.BorrowShares), quantity)
+		expectedBalance := new(big.Int).Sub(PoolCollateralAmount(debt, collateralTokenDecimal, collateralPrice, depositRate), position.CollateralAmount)
+		if actualBalance := GetTokenBalance(userAddress, collateralToken, statedb); actualBalance.Cmp(expectedBalance) < 0 {
+			return fmt.Errorf("VerifyBalance: borrower doesn't have enough collateral token.  User: %s. CollateralToken: %s . ExpectedBalance: %s . ActualBalance: %s",
+				userAddress.Hex(), collateralToken.Hex(), expectedBalance.String(), actualBalance.String())
+		}
+	case MarginDeposit:
+		if balance := GetTokenBalance(userAddress, collateralToken, statedb); balance.Cmp(quantity) < 0 {
+			return fmt.Errorf("VerifyBalance: borrower doesn't have enough collateral token. User: %s. CollateralToken: %s. Expected: %v. Have: %v", userAddress.Hex(), collateralToken.Hex(), quantity, balance)
+		}
+	case MarginWithdraw:
+		if pooled := lendingStateDb.GetMarginCollateral(userAddress, collateralToken); pooled.Cmp(quantity) < 0 {
+			return fmt.Errorf("VerifyBalance: %v. User: %s. CollateralToken: %s. Expected: %v. Have: %v", ErrMarginInsufficientCollateral, userAddress.Hex(), collateralToken.Hex(), quantity, pooled)
+		}
 	case Market, Limit:
 		switch side {
 		case Investing:
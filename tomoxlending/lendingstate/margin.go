@@ -0,0 +1,311 @@
+package lendingstate
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+// Portfolio margin: a borrower may open a margin account by depositing
+// collateral into it. Lending trades opened by the borrower while the account
+// is enabled join the account: their liquidation prices are kept in a margin
+// liquidation book, so they are not liquidated one by one, and the account is
+// liquidated as a whole once its health across all its trades drops below 1.
+// Accounts are kept in the lending state, the pooled collateral in the balance
+// of common.LendingMarginAddress.
+
+// BaseMarginHealth is the health of an account whose collaterals exactly cover
+// its debts, in basis points
+var BaseMarginHealth = big.NewInt(10000)
+
+var (
+	ErrMarginNotEnabled             = errors.New("margin account is not enabled")
+	ErrMarginInsufficientCollateral = errors.New("not enough collateral in the margin account")
+	ErrMarginUnhealthy              = errors.New("margin account health would drop below 1")
+)
+
+// margin account status
+const (
+	marginAccountNone     = uint64(0)
+	marginAccountEnabled  = uint64(1)
+	marginAccountDisabled = uint64(2)
+)
+
+// MarginTrade identifies a lending trade of a margin account
+type MarginTrade struct {
+	LendingBook common.Hash
+	TradeId     uint64
+}
+
+// MarginAsset is a collateral of a margin account, pooled or locked in its
+// trades, priced in TOMO
+type MarginAsset struct {
+	Token           common.Address
+	Amount          *big.Int
+	Decimal         *big.Int
+	Price           *big.Int // of one token
+	LiquidationRate *big.Int // in percent
+}
+
+// Value returns the value of the asset in TOMO
+func (a *MarginAsset) Value() *big.Int {
+	if a.Decimal == nil || a.Decimal.Sign() <= 0 || a.Price == nil {
+		return new(big.Int)
+	}
+	value := new(big.Int).Mul(a.Amount, a.Price)
+	return value.Div(value, a.Decimal)
+}
+
+// LiquidationThreshold returns the share of the value of the asset which can
+// back debts, in basis points
+func (a *MarginAsset) LiquidationThreshold() *big.Int {
+	if a.LiquidationRate == nil || a.LiquidationRate.Sign() <= 0 {
+		return new(big.Int)
+	}
+	threshold := new(big.Int).Mul(BaseMarginHealth, BaseCollateralRate)
+	return threshold.Div(threshold, a.LiquidationRate)
+}
+
+// adjustedValue returns the debt in TOMO the asset can back
+func (a *MarginAsset) adjustedValue() *big.Int {
+	value := new(big.Int).Mul(a.Value(), a.LiquidationThreshold())
+	return value.Div(value, BaseMarginHealth)
+}
+
+// MarginHealth returns the adjusted value of the assets over debtValue, in
+// basis points. It is nil for an account without debt.
+func MarginHealth(assets []*MarginAsset, debtValue *big.Int) *big.Int {
+	if debtValue.Sign() <= 0 {
+		return nil
+	}
+	collateralValue := new(big.Int)
+	for _, asset := range assets {
+		collateralValue.Add(collateralValue, asset.adjustedValue())
+	}
+	health := new(big.Int).Mul(collateralValue, BaseMarginHealth)
+	return health.Div(health, debtValue)
+}
+
+// MarginLiquidationPrice returns the price of assets[index] at which the health
+// of the account drops to 1, the other prices being unchanged. It is zero when
+// the other assets cover the debts on their own.
+func MarginLiquidationPrice(assets []*MarginAsset, index int, debtValue *big.Int) *big.Int {
+	asset := assets[index]
+	threshold := asset.LiquidationThreshold()
+	if asset.Amount.Sign() <= 0 || threshold.Sign() <= 0 {
+		return new(big.Int)
+	}
+	uncovered := new(big.Int).Set(debtValue)
+	for i, other := range assets {
+		if i != index {
+			uncovered.Sub(uncovered, other.adjustedValue())
+		}
+	}
+	if uncovered.Sign() <= 0 {
+		return new(big.Int)
+	}
+	// uncovered = amount * price / decimal * threshold / BaseMarginHealth
+	price := new(big.Int).Mul(uncovered, asset.Decimal)
+	price.Mul(price, BaseMarginHealth)
+	return price.Div(price, new(big.Int).Mul(asset.Amount, threshold))
+}
+
+// GetMarginLiquidationBook returns the key of the liquidation prices of the
+// margin trades of a lending pair in the trading state
+func GetMarginLiquidationBook(collateralToken common.Address, lendingToken common.Address) common.Hash {
+	return crypto.Keccak256Hash([]byte("margin"), tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken).Bytes())
+}
+
+// GetLiquidationPriceBook returns the key of the liquidation price of a trade of
+// lendingBook in the trading state: the book of its pair, or its margin book if
+// the trade is part of a margin account.
+func GetLiquidationPriceBook(lendingStateDB *LendingStateDB, lendingBook common.Hash, trade *LendingTrade) common.Hash {
+	if lendingStateDB.GetMarginTradeOwner(lendingBook, trade.TradeId) != (common.Address{}) {
+		return GetMarginLiquidationBook(trade.CollateralToken, trade.LendingToken)
+	}
+	return tradingstate.GetTradingOrderBookHash(trade.CollateralToken, trade.LendingToken)
+}
+
+// marginAccount is the margin account of a borrower in the lending state
+type marginAccount struct {
+	Status      uint64
+	Collaterals []marginCollateral // in the order they were first pooled
+	Trades      []MarginTrade      // closed trades stay until PruneMarginTrades
+}
+
+type marginCollateral struct {
+	Token  common.Address
+	Amount *big.Int
+}
+
+// marginTradeOwner is the margin account holding a trade
+type marginTradeOwner struct {
+	Borrower common.Address
+}
+
+// marginAccountList lists the borrowers who ever enabled a margin account
+type marginAccountList struct {
+	Accounts []common.Address
+}
+
+// GetMarginAccountHash returns the key of the margin account of borrower in
+// the lending state trie
+func GetMarginAccountHash(borrower common.Address) common.Hash {
+	return crypto.Keccak256Hash(borrower.Bytes(), []byte("margin"))
+}
+
+// GetMarginTradeHash returns the key of the margin account owning a trade in
+// the lending state trie
+func GetMarginTradeHash(lendingBook common.Hash, tradeId uint64) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), common.Uint64ToHash(tradeId).Bytes(), []byte("margin"))
+}
+
+var marginAccountListHash = crypto.Keccak256Hash([]byte("margins"))
+
+func marginAddress() common.Address {
+	return common.HexToAddress(common.LendingMarginAddress)
+}
+
+func (self *LendingStateDB) getMarginAccount(borrower common.Address) *marginAccount {
+	account := new(marginAccount)
+	self.getRecord(GetMarginAccountHash(borrower), marginAccountRecord, account)
+	return account
+}
+
+func (self *LendingStateDB) setMarginAccount(borrower common.Address, account *marginAccount) {
+	self.setRecord(GetMarginAccountHash(borrower), marginAccountRecord, account)
+}
+
+// IsMarginAccountEnabled returns whether new trades of borrower join its
+// margin account
+func (self *LendingStateDB) IsMarginAccountEnabled(borrower common.Address) bool {
+	return self.getMarginAccount(borrower).Status == marginAccountEnabled
+}
+
+// SetMarginAccountEnabled enables or disables the margin account of borrower.
+// An account is listed the first time it is enabled.
+func (self *LendingStateDB) SetMarginAccountEnabled(borrower common.Address, enabled bool) {
+	account := self.getMarginAccount(borrower)
+	status := marginAccountDisabled
+	if enabled {
+		status = marginAccountEnabled
+		if account.Status == marginAccountNone {
+			var list marginAccountList
+			self.getRecord(marginAccountListHash, marginAccountListRecord, &list)
+			list.Accounts = append(list.Accounts, borrower)
+			self.setRecord(marginAccountListHash, marginAccountListRecord, list)
+		}
+	}
+	account.Status = status
+	self.setMarginAccount(borrower, account)
+}
+
+// GetMarginAccounts returns the borrowers who ever enabled a margin account
+func (self *LendingStateDB) GetMarginAccounts() []common.Address {
+	var list marginAccountList
+	self.getRecord(marginAccountListHash, marginAccountListRecord, &list)
+	return list.Accounts
+}
+
+// GetMarginCollateral returns the collateral token pooled by borrower
+func (self *LendingStateDB) GetMarginCollateral(borrower common.Address, token common.Address) *big.Int {
+	for _, collateral := range self.getMarginAccount(borrower).Collaterals {
+		if collateral.Token == token {
+			return collateral.Amount
+		}
+	}
+	return new(big.Int)
+}
+
+// GetMarginCollateralTokens returns the tokens ever pooled by borrower
+func (self *LendingStateDB) GetMarginCollateralTokens(borrower common.Address) []common.Address {
+	var tokens []common.Address
+	for _, collateral := range self.getMarginAccount(borrower).Collaterals {
+		tokens = append(tokens, collateral.Token)
+	}
+	return tokens
+}
+
+func (self *LendingStateDB) setMarginCollateral(borrower common.Address, token common.Address, amount *big.Int) {
+	account := self.getMarginAccount(borrower)
+	for i := range account.Collaterals {
+		if account.Collaterals[i].Token == token {
+			account.Collaterals[i].Amount = amount
+			self.setMarginAccount(borrower, account)
+			return
+		}
+	}
+	account.Collaterals = append(account.Collaterals, marginCollateral{Token: token, Amount: amount})
+	self.setMarginAccount(borrower, account)
+}
+
+// GetMarginTradeOwner returns the borrower whose margin account holds a trade,
+// or the empty address
+func (self *LendingStateDB) GetMarginTradeOwner(lendingBook common.Hash, tradeId uint64) common.Address {
+	var owner marginTradeOwner
+	self.getRecord(GetMarginTradeHash(lendingBook, tradeId), marginTradeRecord, &owner)
+	return owner.Borrower
+}
+
+// GetMarginTrades returns the trades which joined the margin account of
+// borrower. Closed trades stay listed until PruneMarginTrades.
+func (self *LendingStateDB) GetMarginTrades(borrower common.Address) []MarginTrade {
+	return self.getMarginAccount(borrower).Trades
+}
+
+// AddMarginTrade adds a trade to the margin account of borrower
+func (self *LendingStateDB) AddMarginTrade(borrower common.Address, lendingBook common.Hash, tradeId uint64) {
+	self.setRecord(GetMarginTradeHash(lendingBook, tradeId), marginTradeRecord, marginTradeOwner{Borrower: borrower})
+	account := self.getMarginAccount(borrower)
+	account.Trades = append(account.Trades, MarginTrade{LendingBook: lendingBook, TradeId: tradeId})
+	self.setMarginAccount(borrower, account)
+}
+
+// PruneMarginTrades keeps only the given trades in the list of the margin
+// account of borrower
+func (self *LendingStateDB) PruneMarginTrades(borrower common.Address, trades []MarginTrade) {
+	account := self.getMarginAccount(borrower)
+	account.Trades = trades
+	self.setMarginAccount(borrower, account)
+}
+
+// DepositMarginCollateral moves amount of token from borrower into its margin
+// account and enables the account
+func DepositMarginCollateral(statedb *state.StateDB, lendingStateDB *LendingStateDB, borrower common.Address, token common.Address, amount *big.Int) error {
+	if err := SubTokenBalance(borrower, amount, token, statedb); err != nil {
+		return err
+	}
+	if err := AddTokenBalance(marginAddress(), amount, token, statedb); err != nil {
+		return err
+	}
+	lendingStateDB.SetMarginAccountEnabled(borrower, true)
+	lendingStateDB.setMarginCollateral(borrower, token, new(big.Int).Add(lendingStateDB.GetMarginCollateral(borrower, token), amount))
+	return nil
+}
+
+// WithdrawMarginCollateral moves amount of token out of the margin account of
+// borrower. The caller checks the health of the account.
+func WithdrawMarginCollateral(statedb *state.StateDB, lendingStateDB *LendingStateDB, borrower common.Address, token common.Address, amount *big.Int) error {
+	return TransferMarginCollateral(statedb, lendingStateDB, borrower, token, amount, borrower)
+}
+
+// TransferMarginCollateral moves amount of token out of the margin account of
+// borrower to recipient
+func TransferMarginCollateral(statedb *state.StateDB, lendingStateDB *LendingStateDB, borrower common.Address, token common.Address, amount *big.Int, recipient common.Address) error {
+	pooled := lendingStateDB.GetMarginCollateral(borrower, token)
+	if pooled.Cmp(amount) < 0 {
+		return ErrMarginInsufficientCollateral
+	}
+	if err := SubTokenBalance(marginAddress(), amount, token, statedb); err != nil {
+		return err
+	}
+	if err := AddTokenBalance(recipient, amount, token, statedb); err != nil {
+		return err
+	}
+	lendingStateDB.setMarginCollateral(borrower, token, new(big.Int).Sub(pooled, amount))
+	return nil
+}
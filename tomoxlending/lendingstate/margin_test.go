@@ -0,0 +1,135 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+)
+
+func TestMarginHealth(t *testing.T) {
+	assets := []*MarginAsset{
+		// 1000 tokens at 2 TOMO, liquidated at 125%: backs 1600 TOMO
+		{Amount: big.NewInt(1000), Decimal: big.NewInt(1), Price: big.NewInt(2), LiquidationRate: big.NewInt(125)},
+		// 500 tokens at 1 TOMO, liquidated at 100%: backs 500 TOMO
+		{Amount: big.NewInt(500), Decimal: big.NewInt(1), Price: big.NewInt(1), LiquidationRate: big.NewInt(100)},
+	}
+	if have := assets[0].LiquidationThreshold(); have.Int64() != 8000 {
+		t.Errorf("liquidation threshold mismatch: have %v, want 8000", have)
+	}
+	if have := MarginHealth(assets, new(big.Int)); have != nil {
+		t.Errorf("health without debt: have %v, want nil", have)
+	}
+	if have := MarginHealth(assets, big.NewInt(1500)); have.Int64() != 14000 {
+		t.Errorf("health mismatch: have %v, want 14000", have)
+	}
+	// the first asset must back 1000 TOMO: 1000 * price * 80% = 1000
+	if have := MarginLiquidationPrice(assets, 0, big.NewInt(1500)); have.Int64() != 1 {
+		t.Errorf("liquidation price mismatch: have %v, want 1", have)
+	}
+	// the first asset covers the debt on its own
+	if have := MarginLiquidationPrice(assets, 1, big.NewInt(1500)); have.Sign() != 0 {
+		t.Errorf("liquidation price mismatch: have %v, want 0", have)
+	}
+	if have := MarginHealth(assets, big.NewInt(2200)); have.Cmp(BaseMarginHealth) >= 0 {
+		t.Errorf("health above 1 with a debt of 2200: %v", have)
+	}
+}
+
+func TestMarginAccount(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingCache := NewDatabase(rawdb.NewMemoryDatabase())
+	lendingStateDb, _ := New(common.Hash{}, lendingCache)
+	var (
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000e01")
+		lendingToken    = common.HexToAddress("0x0000000000000000000000000000000000000e02")
+		collateralToken = common.HexToAddress("0x0000000000000000000000000000000000000e03")
+		term            = common.OneYear
+		lendingBook     = GetLendingOrderBookHash(lendingToken, term)
+	)
+	statedb.SetNonce(collateralToken, 1)
+	AddTokenBalance(borrower, big.NewInt(1000), collateralToken, statedb)
+
+	if lendingStateDb.IsMarginAccountEnabled(borrower) {
+		t.Fatalf("margin account enabled before any deposit")
+	}
+	if err := DepositMarginCollateral(statedb, lendingStateDb, borrower, collateralToken, big.NewInt(600)); err != nil {
+		t.Fatalf("failed to deposit: %v", err)
+	}
+	if err := DepositMarginCollateral(statedb, lendingStateDb, borrower, collateralToken, big.NewInt(200)); err != nil {
+		t.Fatalf("failed to deposit: %v", err)
+	}
+	if !lendingStateDb.IsMarginAccountEnabled(borrower) {
+		t.Errorf("margin account not enabled by a deposit")
+	}
+	if have := lendingStateDb.GetMarginCollateral(borrower, collateralToken); have.Int64() != 800 {
+		t.Errorf("pooled collateral mismatch: have %v, want 800", have)
+	}
+	if tokens := lendingStateDb.GetMarginCollateralTokens(borrower); len(tokens) != 1 || tokens[0] != collateralToken {
+		t.Errorf("collateral tokens mismatch: %v", tokens)
+	}
+	if accounts := lendingStateDb.GetMarginAccounts(); len(accounts) != 1 || accounts[0] != borrower {
+		t.Errorf("margin accounts mismatch: %v", accounts)
+	}
+	if err := WithdrawMarginCollateral(statedb, lendingStateDb, borrower, collateralToken, big.NewInt(900)); err != ErrMarginInsufficientCollateral {
+		t.Errorf("withdrawal above the pooled collateral: have %v, want %v", err, ErrMarginInsufficientCollateral)
+	}
+	if err := WithdrawMarginCollateral(statedb, lendingStateDb, borrower, collateralToken, big.NewInt(300)); err != nil {
+		t.Fatalf("failed to withdraw: %v", err)
+	}
+	if have := GetTokenBalance(borrower, collateralToken, statedb); have.Int64() != 500 {
+		t.Errorf("borrower balance mismatch: have %v, want 500", have)
+	}
+
+	// trades of the account are kept in the margin liquidation book
+	trade := &LendingTrade{TradeId: 1, LendingToken: lendingToken, CollateralToken: collateralToken, Term: term}
+	if have := GetLiquidationPriceBook(lendingStateDb, lendingBook, trade); have != tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken) {
+		t.Errorf("liquidation book of a trade outside the account: %x", have)
+	}
+	lendingStateDb.AddMarginTrade(borrower, lendingBook, 1)
+	lendingStateDb.AddMarginTrade(borrower, lendingBook, 2)
+	lendingStateDb.AddMarginTrade(borrower, lendingBook, 3)
+	if have := GetLiquidationPriceBook(lendingStateDb, lendingBook, trade); have != GetMarginLiquidationBook(collateralToken, lendingToken) {
+		t.Errorf("liquidation book of a margin trade: %x", have)
+	}
+	lendingStateDb.PruneMarginTrades(borrower, []MarginTrade{{lendingBook, 3}})
+	if trades := lendingStateDb.GetMarginTrades(borrower); len(trades) != 1 || trades[0].TradeId != 3 || trades[0].LendingBook != lendingBook {
+		t.Errorf("trades mismatch after pruning: %v", trades)
+	}
+	if owner := lendingStateDb.GetMarginTradeOwner(lendingBook, 1); owner != borrower {
+		t.Errorf("owner of a pruned trade mismatch: have %x, want %x", owner, borrower)
+	}
+
+	// accounts are kept in the lending state trie
+	root, err := lendingStateDb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit the lending state: %v", err)
+	}
+	if lendingStateDb, err = New(root, lendingCache); err != nil {
+		t.Fatalf("failed to reopen the lending state: %v", err)
+	}
+	if have := lendingStateDb.GetMarginCollateral(borrower, collateralToken); have.Int64() != 500 {
+		t.Errorf("committed pooled collateral mismatch: have %v, want 500", have)
+	}
+	if trades := lendingStateDb.GetMarginTrades(borrower); len(trades) != 1 || trades[0].TradeId != 3 {
+		t.Errorf("committed trades mismatch: %v", trades)
+	}
+	snap := lendingStateDb.Snapshot()
+	lendingStateDb.SetMarginAccountEnabled(borrower, false)
+	lendingStateDb.RevertToSnapshot(snap)
+	if !lendingStateDb.IsMarginAccountEnabled(borrower) || lendingStateDb.IntermediateRoot() != root {
+		t.Errorf("reverted margin account mismatch")
+	}
+
+	lendingStateDb.SetMarginAccountEnabled(borrower, false)
+	if lendingStateDb.IsMarginAccountEnabled(borrower) {
+		t.Errorf("margin account still enabled")
+	}
+	lendingStateDb.SetMarginAccountEnabled(borrower, true)
+	if accounts := lendingStateDb.GetMarginAccounts(); len(accounts) != 1 {
+		t.Errorf("margin account listed twice: %v", accounts)
+	}
+}
@@ -0,0 +1,151 @@
+package lendingstate
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// collateral price oracle sources, enabled per collateral by the oracleSources
+// bitmask of the lending registration contract
+const (
+	OracleSourceContract        = uint64(1 << 0) // collateral/lending price set in the contract
+	OracleSourceInverseContract = uint64(1 << 1) // lending/collateral price set in the contract
+	OracleSourceTomoX           = uint64(1 << 2) // medium price of the direct pair in the last epoch
+	OracleSourceTomoCross       = uint64(1 << 3) // cross price via the collateral/TOMO and lendToken/TOMO pairs
+)
+
+// OracleTWAPWindow is the period the reference price is averaged over, in
+// seconds. Every accepted price weighs the time it stood in that period.
+const OracleTWAPWindow = uint64(2 * 60 * 60)
+
+// BaseOracleDeviation is the base of the oracle deviation, in basis points
+var BaseOracleDeviation = big.NewInt(10000)
+
+var OracleSourceNames = map[uint64]string{
+	OracleSourceContract:        "contract",
+	OracleSourceInverseContract: "inverseContract",
+	OracleSourceTomoX:           "tomox",
+	OracleSourceTomoCross:       "tomoCross",
+}
+
+var (
+	ErrOracleNoPrice   = errors.New("no fresh collateral price")
+	ErrOracleDeviation = errors.New("collateral price deviates too much from its average")
+	ErrOraclePaused    = errors.New("collateral oracle paused")
+)
+
+// OracleConfig is the price oracle configuration of a collateral
+type OracleConfig struct {
+	Sources      uint64   // bitmask of the enabled sources
+	MaxAge       uint64   // maximum age of a source price in blocks, 0 = unlimited
+	MaxDeviation *big.Int // maximum deviation per epoch from the average price in basis points, 0 = unlimited
+}
+
+// Enabled returns whether the collateral is priced by the oracle
+func (c OracleConfig) Enabled() bool {
+	return c.Sources != 0
+}
+
+// OracleSourcePrice is the collateral price given by a single source
+type OracleSourcePrice struct {
+	Source string   `json:"source"`
+	Price  *big.Int `json:"price"`
+	Age    uint64   `json:"age"`
+	Stale  bool     `json:"stale"`
+}
+
+// OracleReport explains how the oracle priced a collateral/lending pair
+type OracleReport struct {
+	CollateralToken common.Address      `json:"collateralToken"`
+	LendingToken    common.Address      `json:"lendingToken"`
+	Enabled         bool                `json:"enabled"`
+	Price           *big.Int            `json:"price"`
+	Reference       *big.Int            `json:"reference"` // time weighted average of the accepted prices
+	Deviation       *big.Int            `json:"deviation"` // from the reference, in basis points
+	Sources         []OracleSourcePrice `json:"sources"`
+	Paused          bool                `json:"paused"` // liquidations of the pair are paused
+	Reason          string              `json:"reason,omitempty"`
+}
+
+// Evaluate marks stale sources, takes the median of the fresh ones as price
+// and trips the circuit breaker if there is no fresh price or the price
+// deviates from the reference by more than allowed.
+func (r *OracleReport) Evaluate(cfg OracleConfig, reference *big.Int) {
+	prices := []*big.Int{}
+	for i := range r.Sources {
+		source := &r.Sources[i]
+		source.Stale = cfg.MaxAge > 0 && source.Age > cfg.MaxAge
+		if !source.Stale {
+			prices = append(prices, source.Price)
+		}
+	}
+	if len(prices) == 0 {
+		r.Paused = true
+		r.Reason = ErrOracleNoPrice.Error()
+		return
+	}
+	r.Price = MedianPrice(prices)
+	if reference == nil || reference.Sign() <= 0 {
+		return
+	}
+	r.Reference = reference
+	r.Deviation = PriceDeviation(r.Price, reference)
+	if cfg.MaxDeviation != nil && cfg.MaxDeviation.Sign() > 0 && r.Deviation.Cmp(cfg.MaxDeviation) > 0 {
+		r.Paused = true
+		r.Reason = ErrOracleDeviation.Error()
+	}
+}
+
+// MedianPrice returns the median of the given prices
+func MedianPrice(prices []*big.Int) *big.Int {
+	sorted := make([]*big.Int, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Cmp(sorted[j]) < 0
+	})
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return new(big.Int).Set(sorted[middle])
+	}
+	median := new(big.Int).Add(sorted[middle-1], sorted[middle])
+	return median.Div(median, common.Big2)
+}
+
+// PriceDeviation returns |price - reference| / reference in basis points
+func PriceDeviation(price, reference *big.Int) *big.Int {
+	deviation := new(big.Int).Sub(price, reference)
+	deviation.Abs(deviation)
+	deviation.Mul(deviation, BaseOracleDeviation)
+	return deviation.Div(deviation, reference)
+}
+
+// ClampPrice bounds price to reference +/- maxDeviation basis points
+func ClampPrice(price, reference, maxDeviation *big.Int) *big.Int {
+	delta := new(big.Int).Mul(reference, maxDeviation)
+	delta.Div(delta, BaseOracleDeviation)
+	if upper := new(big.Int).Add(reference, delta); price.Cmp(upper) > 0 {
+		return upper
+	}
+	if lower := new(big.Int).Sub(reference, delta); price.Cmp(lower) < 0 {
+		return lower
+	}
+	return new(big.Int).Set(price)
+}
+
+// OracleTWAP returns the time weighted average of the accepted prices over the
+// last OracleTWAPWindow seconds, from the average twap up to the time the last
+// price was accepted and the elapsed seconds the last price stood since.
+func OracleTWAP(twap, lastPrice *big.Int, elapsed uint64) *big.Int {
+	if lastPrice == nil || lastPrice.Sign() <= 0 {
+		return twap
+	}
+	if twap == nil || twap.Sign() <= 0 || elapsed >= OracleTWAPWindow {
+		return new(big.Int).Set(lastPrice)
+	}
+	next := new(big.Int).Mul(twap, new(big.Int).SetUint64(OracleTWAPWindow-elapsed))
+	next.Add(next, new(big.Int).Mul(lastPrice, new(big.Int).SetUint64(elapsed)))
+	return next.Div(next, new(big.Int).SetUint64(OracleTWAPWindow))
+}
@@ -0,0 +1,80 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestMedianPrice(t *testing.T) {
+	tests := []struct {
+		prices []int64
+		want   int64
+	}{
+		{[]int64{7}, 7},
+		{[]int64{9, 1, 5}, 5},
+		{[]int64{10, 2, 4, 100}, 7},
+	}
+	for _, tt := range tests {
+		prices := []*big.Int{}
+		for _, p := range tt.prices {
+			prices = append(prices, big.NewInt(p))
+		}
+		if have := MedianPrice(prices); have.Int64() != tt.want {
+			t.Errorf("MedianPrice(%v) = %v, want %d", tt.prices, have, tt.want)
+		}
+	}
+}
+
+func TestOracleReportEvaluate(t *testing.T) {
+	config := OracleConfig{Sources: OracleSourceContract | OracleSourceTomoX, MaxAge: 100, MaxDeviation: big.NewInt(1000)}
+	sources := func() []OracleSourcePrice {
+		return []OracleSourcePrice{
+			{Source: "contract", Price: big.NewInt(1000), Age: 10},
+			{Source: "inverseContract", Price: big.NewInt(1100), Age: 20},
+			{Source: "tomox", Price: big.NewInt(5000), Age: 500},
+		}
+	}
+	// the stale TomoX price is ignored
+	report := &OracleReport{Sources: sources()}
+	report.Evaluate(config, big.NewInt(1000))
+	if report.Paused || report.Price.Int64() != 1050 {
+		t.Fatalf("price mismatch: have %v paused %v, want 1050", report.Price, report.Paused)
+	}
+	if !report.Sources[2].Stale || report.Sources[0].Stale {
+		t.Errorf("staleness mismatch: %+v", report.Sources)
+	}
+	if report.Deviation.Int64() != 500 {
+		t.Errorf("deviation mismatch: have %v, want 500", report.Deviation)
+	}
+	// 1050 is 16% above 900
+	report = &OracleReport{Sources: sources()}
+	report.Evaluate(config, big.NewInt(900))
+	if !report.Paused || report.Reason != ErrOracleDeviation.Error() {
+		t.Errorf("deviation not detected: paused %v reason %q", report.Paused, report.Reason)
+	}
+	// no fresh source
+	config.MaxAge = 1
+	report = &OracleReport{Sources: sources()}
+	report.Evaluate(config, big.NewInt(1000))
+	if !report.Paused || report.Price != nil || report.Reason != ErrOracleNoPrice.Error() {
+		t.Errorf("stale sources not detected: price %v paused %v reason %q", report.Price, report.Paused, report.Reason)
+	}
+}
+
+func TestOracleTWAP(t *testing.T) {
+	if have := ClampPrice(big.NewInt(2000), big.NewInt(1000), big.NewInt(1000)); have.Int64() != 1100 {
+		t.Errorf("upper clamp mismatch: have %v, want 1100", have)
+	}
+	if have := ClampPrice(big.NewInt(500), big.NewInt(1000), big.NewInt(1000)); have.Int64() != 900 {
+		t.Errorf("lower clamp mismatch: have %v, want 900", have)
+	}
+	if have := OracleTWAP(nil, big.NewInt(1000), 0); have.Int64() != 1000 {
+		t.Errorf("initial average mismatch: have %v, want 1000", have)
+	}
+	if have := OracleTWAP(big.NewInt(1000), big.NewInt(2000), OracleTWAPWindow/4); have.Int64() != 1250 {
+		t.Errorf("average mismatch: have %v, want 1250", have)
+	}
+	if have := OracleTWAP(big.NewInt(1000), big.NewInt(2000), OracleTWAPWindow); have.Int64() != 2000 {
+		t.Errorf("full window average mismatch: have %v, want 2000", have)
+	}
+}
@@ -0,0 +1,373 @@
+package lendingstate
+
+import (
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+// Variable-rate lending pools: investors deposit a lending token into its pool
+// and borrowers draw from it against collateral. Interest accrues per block at
+// a rate following the utilization of the pool. Pools and positions are kept in
+// the lending state, their liquidity in the balance of common.LendingPoolAddress.
+// Borrows whose collateral falls under the liquidation rate are liquidated, the
+// seized collateral is paid to the depositors.
+
+// BasePoolRate is the base of the pool rates and utilization, in basis points
+var BasePoolRate = big.NewInt(10000)
+
+var (
+	ErrPoolNotEnabled             = errors.New("lending pool is not enabled")
+	ErrPoolInsufficientLiquidity  = errors.New("not enough liquidity in the lending pool")
+	ErrPoolInsufficientCollateral = errors.New("not enough collateral for the pool borrow")
+	ErrPoolCollateralMismatch     = errors.New("pool position is collateralized by another token")
+	ErrPoolEmptyPosition          = errors.New("empty pool position")
+)
+
+// PoolConfig is the utilization curve of a pool, in basis points. The borrow
+// rate grows from BaseRate by Slope1 up to the optimal utilization, then by
+// Slope2 up to full utilization.
+type PoolConfig struct {
+	BaseRate           *big.Int
+	Slope1             *big.Int
+	Slope2             *big.Int
+	OptimalUtilization *big.Int
+}
+
+// Enabled returns whether the lending token has a pool
+func (c PoolConfig) Enabled() bool {
+	return c.OptimalUtilization != nil && c.OptimalUtilization.Sign() > 0 && c.OptimalUtilization.Cmp(BasePoolRate) <= 0
+}
+
+// BorrowRate returns the annual borrow rate at the given utilization
+func (c PoolConfig) BorrowRate(utilization *big.Int) *big.Int {
+	rate := new(big.Int).Set(c.BaseRate)
+	if utilization.Cmp(c.OptimalUtilization) <= 0 {
+		slope := new(big.Int).Mul(c.Slope1, utilization)
+		return rate.Add(rate, slope.Div(slope, c.OptimalUtilization))
+	}
+	rate.Add(rate, c.Slope1)
+	if excess := new(big.Int).Sub(BasePoolRate, c.OptimalUtilization); excess.Sign() > 0 {
+		slope := new(big.Int).Mul(c.Slope2, new(big.Int).Sub(utilization, c.OptimalUtilization))
+		rate.Add(rate, slope.Div(slope, excess))
+	}
+	return rate
+}
+
+// SupplyRate returns the annual rate earned by deposits at the given utilization
+func (c PoolConfig) SupplyRate(utilization *big.Int) *big.Int {
+	rate := new(big.Int).Mul(c.BorrowRate(utilization), utilization)
+	return rate.Div(rate, BasePoolRate)
+}
+
+// LendingPool is the state of the pool of a lending token. Deposits and
+// borrows are tracked in shares, whose value grows with the accrued interest.
+type LendingPool struct {
+	LendingToken       common.Address
+	TotalDeposits      *big.Int
+	TotalDepositShares *big.Int
+	TotalBorrows       *big.Int
+	TotalBorrowShares  *big.Int
+	LastBlock          uint64
+}
+
+// Utilization returns the borrowed share of the deposits
+func (p *LendingPool) Utilization() *big.Int {
+	if p.TotalDeposits.Sign() == 0 {
+		return new(big.Int)
+	}
+	utilization := new(big.Int).Mul(p.TotalBorrows, BasePoolRate)
+	return utilization.Div(utilization, p.TotalDeposits)
+}
+
+// Liquidity returns the amount which can be withdrawn or borrowed
+func (p *LendingPool) Liquidity() *big.Int {
+	liquidity := new(big.Int).Sub(p.TotalDeposits, p.TotalBorrows)
+	if liquidity.Sign() < 0 {
+		return new(big.Int)
+	}
+	return liquidity
+}
+
+// Accrue adds the interest of the borrows since the last accrual to both the
+// borrows and the deposits, and returns it.
+func (p *LendingPool) Accrue(cfg PoolConfig, number uint64, blocksPerYear uint64) *big.Int {
+	interest := new(big.Int)
+	if number <= p.LastBlock {
+		return interest
+	}
+	if p.TotalBorrows.Sign() > 0 && blocksPerYear > 0 {
+		interest.Mul(p.TotalBorrows, cfg.BorrowRate(p.Utilization()))
+		interest.Mul(interest, new(big.Int).SetUint64(number-p.LastBlock))
+		interest.Div(interest, new(big.Int).Mul(BasePoolRate, new(big.Int).SetUint64(blocksPerYear)))
+		p.TotalBorrows = new(big.Int).Add(p.TotalBorrows, interest)
+		p.TotalDeposits = new(big.Int).Add(p.TotalDeposits, interest)
+	}
+	p.LastBlock = number
+	return interest
+}
+
+// DepositValue returns the amount the given deposit shares are worth
+func (p *LendingPool) DepositValue(shares *big.Int) *big.Int {
+	return shareValue(shares, p.TotalDeposits, p.TotalDepositShares, false)
+}
+
+// BorrowValue returns the debt of the given borrow shares, rounded up
+func (p *LendingPool) BorrowValue(shares *big.Int) *big.Int {
+	return shareValue(shares, p.TotalBorrows, p.TotalBorrowShares, true)
+}
+
+// PoolPosition is the deposit and the borrow of a user in a pool
+type PoolPosition struct {
+	DepositShares    *big.Int
+	BorrowShares     *big.Int
+	CollateralToken  common.Address
+	CollateralAmount *big.Int
+}
+
+func (p PoolPosition) empty() bool {
+	return p.DepositShares.Sign() == 0 && p.BorrowShares.Sign() == 0 && p.CollateralAmount.Sign() == 0
+}
+
+func shareValue(shares, total, totalShares *big.Int, roundUp bool) *big.Int {
+	if totalShares.Sign() == 0 {
+		return new(big.Int)
+	}
+	value := new(big.Int).Mul(shares, total)
+	if roundUp {
+		value.Add(value, new(big.Int).Sub(totalShares, common.Big1))
+	}
+	return value.Div(value, totalShares)
+}
+
+// sharesOf returns the shares minted for amount, or burnt for it if roundUp
+func sharesOf(amount, total, totalShares *big.Int, roundUp bool) *big.Int {
+	if totalShares.Sign() == 0 || total.Sign() == 0 {
+		return new(big.Int).Set(amount)
+	}
+	shares := new(big.Int).Mul(amount, totalShares)
+	if roundUp {
+		shares.Add(shares, new(big.Int).Sub(total, common.Big1))
+	}
+	return shares.Div(shares, total)
+}
+
+// PoolCollateralAmount returns the collateral required to borrow debt:
+// debt * collateral Token Decimal / collateralPrice * deposit rate
+func PoolCollateralAmount(debt, collateralTokenDecimal, collateralPrice, depositRate *big.Int) *big.Int {
+	amount := new(big.Int).Mul(debt, collateralTokenDecimal)
+	amount = new(big.Int).Mul(amount, depositRate)
+	amount = new(big.Int).Div(amount, BaseCollateralRate)
+	return amount.Div(amount, collateralPrice)
+}
+
+// PoolAPY returns the yearly yield in percent of an annual rate in basis
+// points compounded every block
+func PoolAPY(rate *big.Int, blocksPerYear uint64) float64 {
+	if blocksPerYear == 0 {
+		return 0
+	}
+	apr, _ := new(big.Float).SetInt(rate).Float64()
+	n := float64(blocksPerYear)
+	return (math.Pow(1+apr/float64(BasePoolRate.Int64())/n, n) - 1) * 100
+}
+
+func poolAddress() common.Address {
+	return common.HexToAddress(common.LendingPoolAddress)
+}
+
+// DepositToPool moves amount from user into the pool
+func DepositToPool(statedb *state.StateDB, lendingStateDb *LendingStateDB, pool *LendingPool, user common.Address, amount *big.Int) error {
+	if err := SubTokenBalance(user, amount, pool.LendingToken, statedb); err != nil {
+		return err
+	}
+	if err := AddTokenBalance(poolAddress(), amount, pool.LendingToken, statedb); err != nil {
+		return err
+	}
+	position := lendingStateDb.GetPoolPosition(pool.LendingToken, user)
+	shares := sharesOf(amount, pool.TotalDeposits, pool.TotalDepositShares, false)
+	position.DepositShares = new(big.Int).Add(position.DepositShares, shares)
+	pool.TotalDeposits = new(big.Int).Add(pool.TotalDeposits, amount)
+	pool.TotalDepositShares = new(big.Int).Add(pool.TotalDepositShares, shares)
+	lendingStateDb.SetPoolPosition(pool.LendingToken, user, position)
+	lendingStateDb.SetLendingPool(pool)
+	return nil
+}
+
+// WithdrawFromPool moves up to amount of the deposit of user out of the pool
+// and returns the withdrawn amount
+func WithdrawFromPool(statedb *state.StateDB, lendingStateDb *LendingStateDB, pool *LendingPool, user common.Address, amount *big.Int) (*big.Int, error) {
+	position := lendingStateDb.GetPoolPosition(pool.LendingToken, user)
+	if position.DepositShares.Sign() == 0 {
+		return nil, ErrPoolEmptyPosition
+	}
+	shares := position.DepositShares
+	if value := pool.DepositValue(position.DepositShares); amount.Cmp(value) < 0 {
+		shares = sharesOf(amount, pool.TotalDeposits, pool.TotalDepositShares, true)
+	} else {
+		amount = value
+	}
+	if amount.Cmp(pool.Liquidity()) > 0 {
+		return nil, ErrPoolInsufficientLiquidity
+	}
+	if err := SubTokenBalance(poolAddress(), amount, pool.LendingToken, statedb); err != nil {
+		return nil, err
+	}
+	if err := AddTokenBalance(user, amount, pool.LendingToken, statedb); err != nil {
+		return nil, err
+	}
+	position.DepositShares = new(big.Int).Sub(position.DepositShares, shares)
+	pool.TotalDeposits = new(big.Int).Sub(pool.TotalDeposits, amount)
+	pool.TotalDepositShares = new(big.Int).Sub(pool.TotalDepositShares, shares)
+	lendingStateDb.SetPoolPosition(pool.LendingToken, user, position)
+	lendingStateDb.SetLendingPool(pool)
+	return amount, nil
+}
+
+// BorrowFromPool lends amount to user and locks the collateral needed for the
+// whole debt of the position
+func BorrowFromPool(statedb *state.StateDB, lendingStateDb *LendingStateDB, pool *LendingPool, user common.Address, amount *big.Int, collateralToken common.Address, collateralTokenDecimal, collateralPrice, depositRate *big.Int) error {
+	if amount.Cmp(pool.Liquidity()) > 0 {
+		return ErrPoolInsufficientLiquidity
+	}
+	position := lendingStateDb.GetPoolPosition(pool.LendingToken, user)
+	if position.CollateralAmount.Sign() > 0 && position.CollateralToken != collateralToken {
+		return ErrPoolCollateralMismatch
+	}
+	debt := new(big.Int).Add(pool.BorrowValue(position.BorrowShares), amount)
+	required := PoolCollateralAmount(debt, collateralTokenDecimal, collateralPrice, depositRate)
+	if topUp := new(big.Int).Sub(required, position.CollateralAmount); topUp.Sign() > 0 {
+		if GetTokenBalance(user, collateralToken, statedb).Cmp(topUp) < 0 {
+			return ErrPoolInsufficientCollateral
+		}
+		if err := SubTokenBalance(user, topUp, collateralToken, statedb); err != nil {
+			return err
+		}
+		if err := AddTokenBalance(common.HexToAddress(common.LendingLockAddress), topUp, collateralToken, statedb); err != nil {
+			return err
+		}
+		position.CollateralAmount = required
+	}
+	if err := SubTokenBalance(poolAddress(), amount, pool.LendingToken, statedb); err != nil {
+		return err
+	}
+	if err := AddTokenBalance(user, amount, pool.LendingToken, statedb); err != nil {
+		return err
+	}
+	shares := sharesOf(amount, pool.TotalBorrows, pool.TotalBorrowShares, true)
+	position.BorrowShares = new(big.Int).Add(position.BorrowShares, shares)
+	position.CollateralToken = collateralToken
+	pool.TotalBorrows = new(big.Int).Add(pool.TotalBorrows, amount)
+	pool.TotalBorrowShares = new(big.Int).Add(pool.TotalBorrowShares, shares)
+	lendingStateDb.SetPoolPosition(pool.LendingToken, user, position)
+	lendingStateDb.SetLendingPool(pool)
+	return nil
+}
+
+// RepayToPool repays up to amount of the debt of user and releases the
+// collateral in proportion. It returns the repaid amount.
+func RepayToPool(statedb *state.StateDB, lendingStateDb *LendingStateDB, pool *LendingPool, user common.Address, amount *big.Int) (*big.Int, error) {
+	position := lendingStateDb.GetPoolPosition(pool.LendingToken, user)
+	if position.BorrowShares.Sign() == 0 {
+		return nil, ErrPoolEmptyPosition
+	}
+	debt := pool.BorrowValue(position.BorrowShares)
+	shares := position.BorrowShares
+	released := position.CollateralAmount
+	if amount.Cmp(debt) < 0 {
+		shares = sharesOf(amount, pool.TotalBorrows, pool.TotalBorrowShares, false)
+		released = new(big.Int).Mul(position.CollateralAmount, amount)
+		released = new(big.Int).Div(released, debt)
+	} else {
+		amount = debt
+	}
+	if err := SubTokenBalance(user, amount, pool.LendingToken, statedb); err != nil {
+		return nil, err
+	}
+	if err := AddTokenBalance(poolAddress(), amount, pool.LendingToken, statedb); err != nil {
+		return nil, err
+	}
+	if released.Sign() > 0 {
+		if err := SubTokenBalance(common.HexToAddress(common.LendingLockAddress), released, position.CollateralToken, statedb); err != nil {
+			return nil, err
+		}
+		if err := AddTokenBalance(user, released, position.CollateralToken, statedb); err != nil {
+			return nil, err
+		}
+	}
+	position.BorrowShares = new(big.Int).Sub(position.BorrowShares, shares)
+	position.CollateralAmount = new(big.Int).Sub(position.CollateralAmount, released)
+	pool.TotalBorrows = new(big.Int).Sub(pool.TotalBorrows, amount)
+	if pool.TotalBorrows.Sign() < 0 {
+		pool.TotalBorrows = new(big.Int)
+	}
+	pool.TotalBorrowShares = new(big.Int).Sub(pool.TotalBorrowShares, shares)
+	lendingStateDb.SetPoolPosition(pool.LendingToken, user, position)
+	lendingStateDb.SetLendingPool(pool)
+	return amount, nil
+}
+
+// LiquidatePoolPosition writes the debt of user off the pool and seizes up to
+// liquidationAmount of its collateral, all of it if liquidationAmount is nil.
+// The seized collateral is paid to the depositors in proportion to their
+// shares, the rest is returned to user. It returns the debt and the seized amount.
+func LiquidatePoolPosition(statedb *state.StateDB, lendingStateDb *LendingStateDB, pool *LendingPool, user common.Address, liquidationAmount *big.Int) (*big.Int, *big.Int, error) {
+	position := lendingStateDb.GetPoolPosition(pool.LendingToken, user)
+	if position.BorrowShares.Sign() == 0 {
+		return nil, nil, ErrPoolEmptyPosition
+	}
+	debt := pool.BorrowValue(position.BorrowShares)
+	seized := position.CollateralAmount
+	if liquidationAmount != nil && liquidationAmount.Cmp(seized) < 0 {
+		seized = liquidationAmount
+	}
+	if err := SubTokenBalance(common.HexToAddress(common.LendingLockAddress), position.CollateralAmount, position.CollateralToken, statedb); err != nil {
+		return nil, nil, err
+	}
+	if recall := new(big.Int).Sub(position.CollateralAmount, seized); recall.Sign() > 0 {
+		if err := AddTokenBalance(user, recall, position.CollateralToken, statedb); err != nil {
+			return nil, nil, err
+		}
+	}
+	users, positions := lendingStateDb.GetPoolPositions(pool.LendingToken)
+	depositors := []common.Address{}
+	for _, depositor := range users {
+		if positions[depositor].DepositShares.Sign() > 0 {
+			depositors = append(depositors, depositor)
+		}
+	}
+	if len(depositors) == 0 {
+		// nobody to pay, the collateral is kept by the pool
+		depositors = append(depositors, poolAddress())
+	}
+	remaining := new(big.Int).Set(seized)
+	for i, depositor := range depositors {
+		// the last depositor gets the rounding remainder
+		amount := remaining
+		if i < len(depositors)-1 {
+			amount = new(big.Int).Mul(seized, positions[depositor].DepositShares)
+			amount = amount.Div(amount, pool.TotalDepositShares)
+		}
+		if err := AddTokenBalance(depositor, amount, position.CollateralToken, statedb); err != nil {
+			return nil, nil, err
+		}
+		remaining = new(big.Int).Sub(remaining, amount)
+	}
+	pool.TotalBorrows = new(big.Int).Sub(pool.TotalBorrows, debt)
+	if pool.TotalBorrows.Sign() < 0 {
+		pool.TotalBorrows = new(big.Int)
+	}
+	pool.TotalBorrowShares = new(big.Int).Sub(pool.TotalBorrowShares, position.BorrowShares)
+	pool.TotalDeposits = new(big.Int).Sub(pool.TotalDeposits, debt)
+	if pool.TotalDeposits.Sign() < 0 {
+		pool.TotalDeposits = new(big.Int)
+	}
+	position.BorrowShares = new(big.Int)
+	position.CollateralAmount = new(big.Int)
+	lendingStateDb.SetPoolPosition(pool.LendingToken, user, position)
+	lendingStateDb.SetLendingPool(pool)
+	return debt, seized, nil
+}
@@ -0,0 +1,197 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+)
+
+var testPoolConfig = PoolConfig{
+	BaseRate:           big.NewInt(200),
+	Slope1:             big.NewInt(800),
+	Slope2:             big.NewInt(10000),
+	OptimalUtilization: big.NewInt(8000),
+}
+
+func TestPoolBorrowRate(t *testing.T) {
+	tests := []struct {
+		utilization int64
+		borrowRate  int64
+		supplyRate  int64
+	}{
+		{0, 200, 0},
+		{4000, 600, 240},
+		{8000, 1000, 800},
+		{9000, 6000, 5400},
+		{10000, 11000, 11000},
+	}
+	for _, tt := range tests {
+		utilization := big.NewInt(tt.utilization)
+		if have := testPoolConfig.BorrowRate(utilization); have.Int64() != tt.borrowRate {
+			t.Errorf("borrow rate mismatch at %d: have %v, want %d", tt.utilization, have, tt.borrowRate)
+		}
+		if have := testPoolConfig.SupplyRate(utilization); have.Int64() != tt.supplyRate {
+			t.Errorf("supply rate mismatch at %d: have %v, want %d", tt.utilization, have, tt.supplyRate)
+		}
+	}
+}
+
+func TestLendingPool(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingCache := NewDatabase(rawdb.NewMemoryDatabase())
+	lendingStateDb, _ := New(common.Hash{}, lendingCache)
+	var (
+		investor        = common.HexToAddress("0x0000000000000000000000000000000000000d01")
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000d02")
+		lendingToken    = common.HexToAddress("0x0000000000000000000000000000000000000d03")
+		collateralToken = common.HexToAddress(common.TomoNativeAddress)
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+	)
+	// a TRC21 token has to exist to keep balances
+	statedb.SetNonce(lendingToken, 1)
+	AddTokenBalance(investor, big.NewInt(10000), lendingToken, statedb)
+	AddTokenBalance(borrower, big.NewInt(20000), collateralToken, statedb)
+
+	pool := lendingStateDb.GetLendingPool(lendingToken)
+	pool.Accrue(testPoolConfig, 1, 1000)
+	if err := DepositToPool(statedb, lendingStateDb, pool, investor, big.NewInt(10000)); err != nil {
+		t.Fatalf("failed to deposit: %v", err)
+	}
+	// the pool is kept in the lending state trie
+	root, err := lendingStateDb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit the lending state: %v", err)
+	}
+	if lendingStateDb, err = New(root, lendingCache); err != nil {
+		t.Fatalf("failed to reopen the lending state: %v", err)
+	}
+	if have := lendingStateDb.GetLendingPool(lendingToken).TotalDeposits; have.Int64() != 10000 {
+		t.Errorf("committed deposits mismatch: have %v, want 10000", have)
+	}
+	if have := lendingStateDb.GetPoolPosition(lendingToken, investor).DepositShares; have.Int64() != 10000 {
+		t.Errorf("committed shares mismatch: have %v, want 10000", have)
+	}
+	// collateral = debt * 2 (price 0.5) * 150%
+	if err := BorrowFromPool(statedb, lendingStateDb, pool, borrower, big.NewInt(12000), collateralToken, big.NewInt(1000), big.NewInt(500), big.NewInt(150)); err != ErrPoolInsufficientLiquidity {
+		t.Errorf("borrow above liquidity: have %v, want %v", err, ErrPoolInsufficientLiquidity)
+	}
+	if err := BorrowFromPool(statedb, lendingStateDb, pool, borrower, big.NewInt(5000), collateralToken, big.NewInt(1000), big.NewInt(500), big.NewInt(150)); err != nil {
+		t.Fatalf("failed to borrow: %v", err)
+	}
+	if have := GetTokenBalance(lockAddress, collateralToken, statedb); have.Int64() != 15000 {
+		t.Errorf("locked collateral mismatch: have %v, want 15000", have)
+	}
+	if have := lendingStateDb.GetLendingPool(lendingToken).Utilization(); have.Int64() != 5000 {
+		t.Errorf("utilization mismatch: have %v, want 5000", have)
+	}
+
+	// a reverted repayment leaves the pool untouched
+	snap, lendingSnap := statedb.Snapshot(), lendingStateDb.Snapshot()
+	if _, err := RepayToPool(statedb, lendingStateDb, pool, borrower, big.NewInt(1000)); err != nil {
+		t.Fatalf("failed to repay: %v", err)
+	}
+	statedb.RevertToSnapshot(snap)
+	lendingStateDb.RevertToSnapshot(lendingSnap)
+	if have := lendingStateDb.GetPoolPosition(lendingToken, borrower).CollateralAmount; have.Int64() != 15000 {
+		t.Errorf("reverted collateral mismatch: have %v, want 15000", have)
+	}
+	if have := lendingStateDb.GetLendingPool(lendingToken).TotalBorrows; have.Int64() != 5000 {
+		t.Errorf("reverted borrows mismatch: have %v, want 5000", have)
+	}
+
+	// 50% utilization borrows at 7%, 100 of 1000 blocks a year accrue 35
+	pool = lendingStateDb.GetLendingPool(lendingToken)
+	if interest := pool.Accrue(testPoolConfig, 101, 1000); interest.Int64() != 35 {
+		t.Fatalf("interest mismatch: have %v, want 35", interest)
+	}
+	if have := pool.DepositValue(lendingStateDb.GetPoolPosition(lendingToken, investor).DepositShares); have.Int64() != 10035 {
+		t.Errorf("deposit mismatch: have %v, want 10035", have)
+	}
+	if _, err := WithdrawFromPool(statedb, lendingStateDb, pool, investor, big.NewInt(6000)); err != ErrPoolInsufficientLiquidity {
+		t.Errorf("withdrawal above liquidity: have %v, want %v", err, ErrPoolInsufficientLiquidity)
+	}
+
+	// repaying 2517 of 5035 releases 15000 * 2517 / 5035 of the collateral
+	AddTokenBalance(borrower, big.NewInt(35), lendingToken, statedb)
+	repaid, err := RepayToPool(statedb, lendingStateDb, pool, borrower, big.NewInt(2517))
+	if err != nil || repaid.Int64() != 2517 {
+		t.Fatalf("failed to repay: %v %v", repaid, err)
+	}
+	if have := lendingStateDb.GetPoolPosition(lendingToken, borrower).CollateralAmount; have.Int64() != 7502 {
+		t.Errorf("collateral mismatch: have %v, want 7502", have)
+	}
+	if repaid, err = RepayToPool(statedb, lendingStateDb, pool, borrower, big.NewInt(1000000)); err != nil || repaid.Int64() != 2518 {
+		t.Fatalf("failed to repay the debt: %v %v", repaid, err)
+	}
+	position := lendingStateDb.GetPoolPosition(lendingToken, borrower)
+	if position.BorrowShares.Sign() != 0 || position.CollateralAmount.Sign() != 0 {
+		t.Errorf("position not closed: %+v", position)
+	}
+	if have := GetTokenBalance(borrower, collateralToken, statedb); have.Int64() != 20000 {
+		t.Errorf("collateral not released: have %v, want 20000", have)
+	}
+
+	withdrawn, err := WithdrawFromPool(statedb, lendingStateDb, pool, investor, big.NewInt(1000000))
+	if err != nil || withdrawn.Int64() != 10035 {
+		t.Fatalf("failed to withdraw: %v %v", withdrawn, err)
+	}
+	if have := GetTokenBalance(investor, lendingToken, statedb); have.Int64() != 10035 {
+		t.Errorf("investor balance mismatch: have %v, want 10035", have)
+	}
+}
+
+func TestLiquidatePoolPosition(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	lendingStateDb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	var (
+		investor1       = common.HexToAddress("0x0000000000000000000000000000000000000d01")
+		investor2       = common.HexToAddress("0x0000000000000000000000000000000000000d02")
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000d03")
+		lendingToken    = common.HexToAddress("0x0000000000000000000000000000000000000d04")
+		collateralToken = common.HexToAddress(common.TomoNativeAddress)
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+	)
+	statedb.SetNonce(lendingToken, 1)
+	AddTokenBalance(investor1, big.NewInt(3000), lendingToken, statedb)
+	AddTokenBalance(investor2, big.NewInt(1000), lendingToken, statedb)
+	AddTokenBalance(borrower, big.NewInt(6000), collateralToken, statedb)
+
+	pool := lendingStateDb.GetLendingPool(lendingToken)
+	DepositToPool(statedb, lendingStateDb, pool, investor1, big.NewInt(3000))
+	DepositToPool(statedb, lendingStateDb, pool, investor2, big.NewInt(1000))
+	// collateral = debt * 2 (price 0.5) * 150%
+	if err := BorrowFromPool(statedb, lendingStateDb, pool, borrower, big.NewInt(2000), collateralToken, big.NewInt(1000), big.NewInt(500), big.NewInt(150)); err != nil {
+		t.Fatalf("failed to borrow: %v", err)
+	}
+
+	// 4400 of the 6000 collateral are seized, 3/4 paid to investor1
+	debt, seized, err := LiquidatePoolPosition(statedb, lendingStateDb, pool, borrower, big.NewInt(4400))
+	if err != nil || debt.Int64() != 2000 || seized.Int64() != 4400 {
+		t.Fatalf("failed to liquidate: %v %v %v", debt, seized, err)
+	}
+	if have := GetTokenBalance(investor1, collateralToken, statedb); have.Int64() != 3300 {
+		t.Errorf("investor1 collateral mismatch: have %v, want 3300", have)
+	}
+	if have := GetTokenBalance(investor2, collateralToken, statedb); have.Int64() != 1100 {
+		t.Errorf("investor2 collateral mismatch: have %v, want 1100", have)
+	}
+	if have := GetTokenBalance(borrower, collateralToken, statedb); have.Int64() != 1600 {
+		t.Errorf("returned collateral mismatch: have %v, want 1600", have)
+	}
+	if have := GetTokenBalance(lockAddress, collateralToken, statedb); have.Sign() != 0 {
+		t.Errorf("collateral left locked: %v", have)
+	}
+	pool = lendingStateDb.GetLendingPool(lendingToken)
+	if pool.TotalBorrows.Sign() != 0 || pool.TotalBorrowShares.Sign() != 0 || pool.TotalDeposits.Int64() != 2000 {
+		t.Errorf("pool mismatch: %+v", pool)
+	}
+	if position := lendingStateDb.GetPoolPosition(lendingToken, borrower); !position.empty() {
+		t.Errorf("position not closed: %+v", position)
+	}
+	if _, _, err := LiquidatePoolPosition(statedb, lendingStateDb, pool, borrower, nil); err != ErrPoolEmptyPosition {
+		t.Errorf("liquidation of a closed position: have %v, want %v", err, ErrPoolEmptyPosition)
+	}
+}
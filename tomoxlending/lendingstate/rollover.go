@@ -0,0 +1,89 @@
+package lendingstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/crypto"
+)
+
+// Lending trade rollover: the borrower of a trade proposes to extend it to a
+// new term and interest with a ROLLOVER item. The investor accepts the proposal
+// with a ROLLOVER item of the same term and interest, or declines it, in which
+// case the trade is refinanced against the book of the new term. A proposal the
+// investor didn't answer is refinanced the same way when the trade matures.
+// Pending proposals are kept in the lending state.
+
+var (
+	ErrRolloverNotProposed = errors.New("no rollover proposed for the lending trade")
+	ErrRolloverMismatch    = errors.New("rollover does not match the proposal")
+	ErrRolloverNotFilled   = errors.New("rollover is not fully matched in the book of the new term")
+)
+
+// RolloverData is the extra data of a rollover item
+type RolloverData struct {
+	Term    uint64 `json:"term"`              // new term of the trade
+	Decline bool   `json:"decline,omitempty"` // investor declines the proposal
+}
+
+// DecodeRolloverData decodes the extra data of a rollover item
+func DecodeRolloverData(extraData string) (RolloverData, error) {
+	var data RolloverData
+	if err := json.Unmarshal([]byte(extraData), &data); err != nil {
+		return data, fmt.Errorf("invalid rollover data: %v", err)
+	}
+	if !data.Decline && data.Term == 0 {
+		return data, fmt.Errorf("invalid rollover term: %d", data.Term)
+	}
+	return data, nil
+}
+
+// RolloverProposal is a rollover of a trade proposed by its borrower
+type RolloverProposal struct {
+	Term     uint64
+	Interest *big.Int
+	Relayer  common.Address
+}
+
+// RolloverTradeData is the extra data of a trade extended by a rollover
+type RolloverTradeData struct {
+	PreviousTerm    uint64
+	PreviousTradeId uint64
+	InterestPaid    *big.Int
+	Refinanced      bool `json:",omitempty"`
+}
+
+// GetRolloverProposalHash returns the key of the pending rollover of a trade
+// in the lending state trie
+func GetRolloverProposalHash(lendingBook common.Hash, tradeId uint64) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), common.Uint64ToHash(tradeId).Bytes(), []byte("rollover"))
+}
+
+// GetRefinanceItemHash returns the hash of the borrowing item refinancing a
+// trade, which tells the trades of the refinancing from the refinanced one
+func GetRefinanceItemHash(lendingBook common.Hash, tradeId uint64) common.Hash {
+	return crypto.Keccak256Hash(lendingBook.Bytes(), common.Uint64ToHash(tradeId).Bytes(), []byte("refinance"))
+}
+
+// GetRolloverProposal returns the pending rollover of a trade, or nil
+func (self *LendingStateDB) GetRolloverProposal(lendingBook common.Hash, tradeId uint64) *RolloverProposal {
+	proposal := new(RolloverProposal)
+	if !self.getRecord(GetRolloverProposalHash(lendingBook, tradeId), rolloverProposalRecord, proposal) {
+		return nil
+	}
+	return proposal
+}
+
+// SetRolloverProposal stores the pending rollover of a trade, replacing any
+// previous proposal
+func (self *LendingStateDB) SetRolloverProposal(lendingBook common.Hash, tradeId uint64, proposal *RolloverProposal) {
+	self.setRecord(GetRolloverProposalHash(lendingBook, tradeId), rolloverProposalRecord, proposal)
+}
+
+// DeleteRolloverProposal removes the pending rollover of a trade
+func (self *LendingStateDB) DeleteRolloverProposal(lendingBook common.Hash, tradeId uint64) {
+	self.deleteRecord(GetRolloverProposalHash(lendingBook, tradeId))
+}
@@ -0,0 +1,56 @@
+package lendingstate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestDecodeRolloverData(t *testing.T) {
+	if data, err := DecodeRolloverData(`{"term":2592000}`); err != nil || data.Term != 2592000 || data.Decline {
+		t.Errorf("rollover data mismatch: %+v, %v", data, err)
+	}
+	if data, err := DecodeRolloverData(`{"decline":true}`); err != nil || !data.Decline {
+		t.Errorf("decline data mismatch: %+v, %v", data, err)
+	}
+	if _, err := DecodeRolloverData(`{}`); err == nil {
+		t.Errorf("rollover without term accepted")
+	}
+}
+
+func TestRolloverProposal(t *testing.T) {
+	lendingCache := NewDatabase(rawdb.NewMemoryDatabase())
+	lendingStateDb, _ := New(common.Hash{}, lendingCache)
+	var (
+		lendingBook = GetLendingOrderBookHash(common.HexToAddress("0x0000000000000000000000000000000000000e31"), common.OneYear)
+		relayer     = common.HexToAddress("0x0000000000000000000000000000000000000e32")
+	)
+	if proposal := lendingStateDb.GetRolloverProposal(lendingBook, 1); proposal != nil {
+		t.Fatalf("proposal before any rollover: %+v", proposal)
+	}
+	lendingStateDb.SetRolloverProposal(lendingBook, 1, &RolloverProposal{Term: 2 * common.OneYear, Interest: big.NewInt(12), Relayer: relayer})
+	proposal := lendingStateDb.GetRolloverProposal(lendingBook, 1)
+	if proposal == nil || proposal.Term != 2*common.OneYear || proposal.Interest.Int64() != 12 || proposal.Relayer != relayer {
+		t.Fatalf("proposal mismatch: %+v", proposal)
+	}
+	// proposals are kept in the lending state trie
+	root, err := lendingStateDb.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit the lending state: %v", err)
+	}
+	if lendingStateDb, err = New(root, lendingCache); err != nil {
+		t.Fatalf("failed to reopen the lending state: %v", err)
+	}
+	if committed := lendingStateDb.GetRolloverProposal(lendingBook, 1); committed == nil || committed.Interest.Int64() != 12 {
+		t.Fatalf("committed proposal mismatch: %+v", committed)
+	}
+	if other := lendingStateDb.GetRolloverProposal(lendingBook, 2); other != nil {
+		t.Errorf("proposal of another trade: %+v", other)
+	}
+	lendingStateDb.DeleteRolloverProposal(lendingBook, 1)
+	if proposal := lendingStateDb.GetRolloverProposal(lendingBook, 1); proposal != nil {
+		t.Errorf("proposal after deletion: %+v", proposal)
+	}
+}
@@ -0,0 +1,137 @@
+package lendingstate
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+)
+
+// LendingBookSnapshot is the full lending state of a lending book: the book
+// info, the investing and borrowing trees, the liquidation time tree, the
+// resting lending items and the open lending trades. It can be exported to
+// JSON and imported into another lending state.
+type LendingBookSnapshot struct {
+	LendingBook      common.Hash
+	Info             *DumpOrderBookInfo
+	Investing        map[*big.Int]DumpOrderList
+	Borrowing        map[*big.Int]DumpOrderList
+	LiquidationTimes map[*big.Int]DumpOrderList
+	Items            map[*big.Int]LendingItem
+	Trades           map[*big.Int]LendingTrade
+}
+
+// DumpLendingBook returns the snapshot of the lending state of lendingBook.
+func (self *LendingStateDB) DumpLendingBook(lendingBook common.Hash) (*LendingBookSnapshot, error) {
+	var (
+		snapshot = &LendingBookSnapshot{LendingBook: lendingBook}
+		err      error
+	)
+	if snapshot.Info, err = self.DumpOrderBookInfo(lendingBook); err != nil {
+		return nil, err
+	}
+	if snapshot.Investing, err = self.DumpInvestingTrie(lendingBook); err != nil {
+		return nil, err
+	}
+	if snapshot.Borrowing, err = self.DumpBorrowingTrie(lendingBook); err != nil {
+		return nil, err
+	}
+	if snapshot.LiquidationTimes, err = self.DumpLiquidationTimeTrie(lendingBook); err != nil {
+		return nil, err
+	}
+	if snapshot.Items, err = self.DumpLendingOrderTrie(lendingBook); err != nil {
+		return nil, err
+	}
+	if snapshot.Trades, err = self.DumpLendingTradeTrie(lendingBook); err != nil {
+		return nil, err
+	}
+	// the item and trade tries keep the cached entries which were emptied
+	for lendingId, item := range snapshot.Items {
+		if item.Quantity == nil || item.Quantity.Sign() == 0 {
+			delete(snapshot.Items, lendingId)
+		}
+	}
+	for tradeId, trade := range snapshot.Trades {
+		if trade.Amount == nil || trade.Amount.Sign() == 0 {
+			delete(snapshot.Trades, tradeId)
+		}
+	}
+	return snapshot, nil
+}
+
+// ImportLendingBook rebuilds the lending state of the lending book of snapshot,
+// which must not exist yet in the state.
+func (self *LendingStateDB) ImportLendingBook(snapshot *LendingBookSnapshot) error {
+	lendingBook := snapshot.LendingBook
+	if self.getLendingExchange(lendingBook) != nil {
+		return fmt.Errorf("lending book already exists : %s ", lendingBook.Hex())
+	}
+	if snapshot.Info == nil {
+		return fmt.Errorf("lending book info missing : %s ", lendingBook.Hex())
+	}
+	self.createLendingExchangeObject(lendingBook)
+	for lendingId, item := range snapshot.Items {
+		if item.Quantity == nil || item.Quantity.Sign() == 0 {
+			continue
+		}
+		if item.LendingId != lendingId.Uint64() {
+			return fmt.Errorf("lending id mismatch : %s , key : %v , lendingId : %d ", lendingBook.Hex(), lendingId, item.LendingId)
+		}
+		self.InsertLendingItem(lendingBook, common.BigToHash(lendingId), item)
+	}
+	for tradeId, trade := range snapshot.Trades {
+		if trade.Amount == nil || trade.Amount.Sign() == 0 {
+			continue
+		}
+		if trade.TradeId != tradeId.Uint64() {
+			return fmt.Errorf("lending trade id mismatch : %s , key : %v , tradeId : %d ", lendingBook.Hex(), tradeId, trade.TradeId)
+		}
+		self.InsertTradingItem(lendingBook, trade.TradeId, trade)
+	}
+	for liquidationTime, tradeIds := range snapshot.LiquidationTimes {
+		for tradeId := range tradeIds.Orders {
+			self.InsertLiquidationTime(lendingBook, liquidationTime, tradeId.Uint64())
+		}
+	}
+	self.SetNonce(lendingBook, snapshot.Info.Nonce)
+	self.SetTradeNonce(lendingBook, snapshot.Info.TradeNonce)
+	// the interest trees are rebuilt from the lending items, verify they hold
+	// what was exported
+	for side, want := range map[string]map[*big.Int]DumpOrderList{Investing: snapshot.Investing, Borrowing: snapshot.Borrowing} {
+		have, err := self.dumpItemListTrie(lendingBook, side)
+		if err != nil {
+			return err
+		}
+		for interest, itemList := range want {
+			if itemList.Volume == nil {
+				continue
+			}
+			if volume := have[interest.String()]; volume == nil || volume.Cmp(itemList.Volume) != 0 {
+				return fmt.Errorf("lending volume mismatch : %s , side : %s , interest : %v , have : %v , want : %v ", lendingBook.Hex(), side, interest, volume, itemList.Volume)
+			}
+		}
+	}
+	return nil
+}
+
+// dumpItemListTrie returns the volume of the lending items of a side of the
+// lending book, keyed by interest.
+func (self *LendingStateDB) dumpItemListTrie(lendingBook common.Hash, side string) (map[string]*big.Int, error) {
+	var (
+		itemLists map[*big.Int]DumpOrderList
+		err       error
+	)
+	if side == Investing {
+		itemLists, err = self.DumpInvestingTrie(lendingBook)
+	} else {
+		itemLists, err = self.DumpBorrowingTrie(lendingBook)
+	}
+	if err != nil {
+		return nil, err
+	}
+	volumes := make(map[string]*big.Int, len(itemLists))
+	for interest, itemList := range itemLists {
+		volumes[interest.String()] = itemList.Volume
+	}
+	return volumes, nil
+}
@@ -0,0 +1,86 @@
+package lendingstate
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+)
+
+func TestLendingBookSnapshot(t *testing.T) {
+	lendingBook := common.StringToHash("USDT/30days")
+	statedb, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	for i := uint64(1); i <= 6; i++ {
+		side := Investing
+		if i%2 == 0 {
+			side = Borrowing
+		}
+		statedb.InsertLendingItem(lendingBook, common.Uint64ToHash(i), LendingItem{
+			LendingId:   i,
+			Quantity:    new(big.Int).SetUint64(10 * i),
+			Interest:    new(big.Int).SetUint64(5 + i%3),
+			Side:        side,
+			Term:        30,
+			UserAddress: common.BigToAddress(new(big.Int).SetUint64(i)),
+			Signature:   &Signature{V: 1, R: common.HexToHash("1111"), S: common.HexToHash("2222")},
+		})
+	}
+	for i := uint64(1); i <= 3; i++ {
+		statedb.InsertTradingItem(lendingBook, i, LendingTrade{
+			TradeId:                i,
+			Term:                   30,
+			Interest:               5,
+			Amount:                 new(big.Int).SetUint64(100 * i),
+			CollateralPrice:        big.NewInt(2),
+			LiquidationPrice:       big.NewInt(1),
+			CollateralLockedAmount: big.NewInt(300),
+			DepositRate:            big.NewInt(150),
+			LiquidationRate:        big.NewInt(110),
+			RecallRate:             big.NewInt(200),
+			BorrowingFee:           big.NewInt(1),
+			InvestingFee:           big.NewInt(1),
+			LiquidationTime:        1000 + i%2,
+		})
+		statedb.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(1000+i%2), i)
+	}
+	statedb.SetNonce(lendingBook, 6)
+	statedb.SetTradeNonce(lendingBook, 3)
+
+	snapshot, err := statedb.DumpLendingBook(lendingBook)
+	if err != nil {
+		t.Fatalf("failed to dump lending book: %v", err)
+	}
+	if len(snapshot.Items) != 6 || len(snapshot.Trades) != 3 || len(snapshot.LiquidationTimes) != 2 {
+		t.Fatalf("incomplete snapshot: %d items, %d trades, %d liquidation times", len(snapshot.Items), len(snapshot.Trades), len(snapshot.LiquidationTimes))
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("failed to encode snapshot: %v", err)
+	}
+	decoded := new(LendingBookSnapshot)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+
+	imported, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()))
+	if err := imported.ImportLendingBook(decoded); err != nil {
+		t.Fatalf("failed to import lending book: %v", err)
+	}
+	if have, want := imported.IntermediateRoot(), statedb.IntermediateRoot(); have != want {
+		t.Fatalf("lending root mismatch: have %x, want %x", have, want)
+	}
+	reimported, err := imported.DumpLendingBook(lendingBook)
+	if err != nil {
+		t.Fatalf("failed to dump imported lending book: %v", err)
+	}
+	have, _ := json.Marshal(reimported)
+	if !reflect.DeepEqual(have, data) {
+		t.Fatalf("imported snapshot mismatch:\nhave %s\nwant %s", have, data)
+	}
+	if err := imported.ImportLendingBook(decoded); err == nil {
+		t.Fatalf("imported an existing lending book")
+	}
+}
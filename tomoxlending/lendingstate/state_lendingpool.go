@@ -0,0 +1,185 @@
+package lendingstate
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+type lendingPoolState struct {
+	hash common.Hash
+	data lendingPoolObject
+
+	// DB error.
+	// State objects are used by the consensus core and VM which are
+	// unable to deal with database-level errors. Any error that occurs
+	// during a database read is memoized here and will eventually be returned
+	// by LendingStateDB.Commit.
+	dbErr error
+
+	// Write caches.
+	trie Trie // position trie, which becomes non-nil on first access
+
+	cachedPositions map[common.Hash]PoolPosition
+	dirtyPositions  map[common.Hash]PoolPosition
+
+	onDirty func(hash common.Hash) // Callback method to mark a state object newly dirty
+}
+
+func newLendingPoolState(hash common.Hash, data lendingPoolObject, onDirty func(hash common.Hash)) *lendingPoolState {
+	return &lendingPoolState{
+		hash:            hash,
+		data:            data,
+		cachedPositions: make(map[common.Hash]PoolPosition),
+		dirtyPositions:  make(map[common.Hash]PoolPosition),
+		onDirty:         onDirty,
+	}
+}
+
+// EncodeRLP implements rlp.Encoder.
+func (self *lendingPoolState) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, self.data)
+}
+
+func (self *lendingPoolState) setError(err error) {
+	if self.dbErr == nil {
+		self.dbErr = err
+	}
+}
+
+func (self *lendingPoolState) markDirty() {
+	if self.onDirty != nil {
+		self.onDirty(self.hash)
+		self.onDirty = nil
+	}
+}
+
+func (self *lendingPoolState) getTrie(db Database) Trie {
+	if self.trie == nil {
+		var err error
+		self.trie, err = db.OpenStorageTrie(self.hash, self.data.PositionRoot)
+		if err != nil {
+			self.trie, _ = db.OpenStorageTrie(self.hash, EmptyHash)
+			self.setError(fmt.Errorf("can't create pool position trie: %v", err))
+		}
+	}
+	return self.trie
+}
+
+func (self *lendingPoolState) getPosition(db Database, user common.Hash) PoolPosition {
+	if position, exists := self.cachedPositions[user]; exists {
+		return position
+	}
+	position := PoolPosition{DepositShares: new(big.Int), BorrowShares: new(big.Int), CollateralAmount: new(big.Int)}
+	enc, err := self.getTrie(db).TryGet(user[:])
+	if err != nil {
+		self.setError(err)
+		return position
+	}
+	if len(enc) > 0 {
+		if err := rlp.DecodeBytes(enc, &position); err != nil {
+			self.setError(err)
+		}
+	}
+	self.cachedPositions[user] = position
+	return position
+}
+
+// getAllPositions returns the non-empty positions of the pool, keyed by user
+func (self *lendingPoolState) getAllPositions(db Database) map[common.Hash]PoolPosition {
+	positions := map[common.Hash]PoolPosition{}
+	it := trie.NewIterator(self.getTrie(db).NodeIterator(nil))
+	for it.Next() {
+		user := common.BytesToHash(it.Key)
+		if _, exist := self.cachedPositions[user]; exist {
+			continue
+		}
+		var position PoolPosition
+		if err := rlp.DecodeBytes(it.Value, &position); err != nil {
+			self.setError(err)
+			continue
+		}
+		positions[user] = position
+	}
+	for user, position := range self.cachedPositions {
+		if !position.empty() {
+			positions[user] = position
+		}
+	}
+	return positions
+}
+
+func (self *lendingPoolState) setPosition(user common.Hash, position PoolPosition) {
+	self.cachedPositions[user] = position
+	self.dirtyPositions[user] = position
+	self.markDirty()
+}
+
+func (self *lendingPoolState) setPool(pool *LendingPool) {
+	self.data.TotalDeposits = pool.TotalDeposits
+	self.data.TotalDepositShares = pool.TotalDepositShares
+	self.data.TotalBorrows = pool.TotalBorrows
+	self.data.TotalBorrowShares = pool.TotalBorrowShares
+	self.data.LastBlock = pool.LastBlock
+	self.markDirty()
+}
+
+func (self *lendingPoolState) pool(lendingToken common.Address) *LendingPool {
+	return &LendingPool{
+		LendingToken:       lendingToken,
+		TotalDeposits:      new(big.Int).Set(self.data.TotalDeposits),
+		TotalDepositShares: new(big.Int).Set(self.data.TotalDepositShares),
+		TotalBorrows:       new(big.Int).Set(self.data.TotalBorrows),
+		TotalBorrowShares:  new(big.Int).Set(self.data.TotalBorrowShares),
+		LastBlock:          self.data.LastBlock,
+	}
+}
+
+func (self *lendingPoolState) updateTrie(db Database) Trie {
+	tr := self.getTrie(db)
+	for user, position := range self.dirtyPositions {
+		delete(self.dirtyPositions, user)
+		if position.empty() {
+			self.setError(tr.TryDelete(user[:]))
+			continue
+		}
+		v, _ := rlp.EncodeToBytes(position)
+		self.setError(tr.TryUpdate(user[:], v))
+	}
+	return tr
+}
+
+func (self *lendingPoolState) updateRoot(db Database) {
+	self.updateTrie(db)
+	self.data.PositionRoot = self.trie.Hash()
+}
+
+func (self *lendingPoolState) CommitPositionTrie(db Database) error {
+	self.updateTrie(db)
+	if self.dbErr != nil {
+		return self.dbErr
+	}
+	root, err := self.trie.Commit(nil)
+	if err == nil {
+		self.data.PositionRoot = root
+	}
+	return err
+}
+
+func (self *lendingPoolState) deepCopy(db *LendingStateDB, onDirty func(hash common.Hash)) *lendingPoolState {
+	statePool := newLendingPoolState(self.hash, self.data, onDirty)
+	if self.trie != nil {
+		statePool.trie = db.db.CopyTrie(self.trie)
+	}
+	for user, position := range self.dirtyPositions {
+		statePool.dirtyPositions[user] = position
+	}
+	for user, position := range self.cachedPositions {
+		statePool.cachedPositions[user] = position
+	}
+	return statePool
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018 Tomochain
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"fmt"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/rlp"
+)
+
+// kinds of the lending records
+const (
+	auctionRecord           = uint64(1)
+	auctionListRecord       = uint64(2)
+	marginAccountRecord     = uint64(3)
+	marginAccountListRecord = uint64(4)
+	marginTradeRecord       = uint64(5)
+	rolloverProposalRecord  = uint64(6)
+)
+
+// lendingRecord is an object of the lending state trie other than the lending
+// books and pools. Records have no nested trie and are written to the trie as
+// soon as they change. Being a list of two elements, a record never decodes as
+// a lending book or pool.
+type lendingRecord struct {
+	Kind uint64
+	Data []byte
+}
+
+// getRecord decodes the record of the given kind stored at hash into val, and
+// returns whether there is one.
+func (self *LendingStateDB) getRecord(hash common.Hash, kind uint64, val interface{}) bool {
+	enc, err := self.trie.TryGet(hash[:])
+	if err != nil {
+		self.setError(err)
+		return false
+	}
+	if len(enc) == 0 {
+		return false
+	}
+	var record lendingRecord
+	if err := rlp.DecodeBytes(enc, &record); err != nil {
+		self.setError(fmt.Errorf("can't decode lending record at %x: %v", hash[:], err))
+		return false
+	}
+	if record.Kind != kind {
+		self.setError(fmt.Errorf("lending record kind mismatch at %x: have %d, want %d", hash[:], record.Kind, kind))
+		return false
+	}
+	if err := rlp.DecodeBytes(record.Data, val); err != nil {
+		self.setError(fmt.Errorf("can't decode lending record at %x: %v", hash[:], err))
+		return false
+	}
+	return true
+}
+
+// setRecord stores val as the record of the given kind at hash.
+func (self *LendingStateDB) setRecord(hash common.Hash, kind uint64, val interface{}) {
+	data, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		panic(fmt.Errorf("can't encode lending record at %x: %v", hash[:], err))
+	}
+	enc, _ := rlp.EncodeToBytes(lendingRecord{Kind: kind, Data: data})
+	self.writeRecord(hash, enc)
+}
+
+// deleteRecord removes the record at hash.
+func (self *LendingStateDB) deleteRecord(hash common.Hash) {
+	self.writeRecord(hash, nil)
+}
+
+func (self *LendingStateDB) writeRecord(hash common.Hash, enc []byte) {
+	prev, err := self.trie.TryGet(hash[:])
+	if err != nil {
+		self.setError(err)
+	}
+	self.journal = append(self.journal, recordChange{hash: hash, prev: common.CopyBytes(prev)})
+	self.putRecord(hash, enc)
+}
+
+// putRecord writes the encoded record to the trie, deleting it if it is empty.
+func (self *LendingStateDB) putRecord(hash common.Hash, enc []byte) {
+	if len(enc) == 0 {
+		self.setError(self.trie.TryDelete(hash[:]))
+		return
+	}
+	self.setError(self.trie.TryUpdate(hash[:], enc))
+}
@@ -18,6 +18,7 @@
 package lendingstate
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"sort"
@@ -40,6 +41,8 @@ type LendingStateDB struct {
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	lendingExchangeStates      map[common.Hash]*lendingExchangeState
 	lendingExchangeStatesDirty map[common.Hash]struct{}
+	lendingPools               map[common.Hash]*lendingPoolState
+	lendingPoolsDirty          map[common.Hash]struct{}
 
 	// DB error.
 	// State objects are used by the consensus core and VM which are
@@ -69,6 +72,8 @@ func New(root common.Hash, db Database) (*LendingStateDB, error) {
 		trie:                       tr,
 		lendingExchangeStates:      make(map[common.Hash]*lendingExchangeState),
 		lendingExchangeStatesDirty: make(map[common.Hash]struct{}),
+		lendingPools:               make(map[common.Hash]*lendingPoolState),
+		lendingPoolsDirty:          make(map[common.Hash]struct{}),
 	}, nil
 }
 
@@ -213,6 +218,20 @@ func (self *LendingStateDB) UpdateCollateralLockedAmount(orderBook common.Hash,
 	})
 	stateLendingTrade.SetCollateralLockedAmount(amount)
 }
+func (self *LendingStateDB) UpdateTradeAmount(orderBook common.Hash, tradeId uint64, amount *big.Int) {
+	tradeIdHash := common.Uint64ToHash(tradeId)
+	stateExchange := self.getLendingExchange(orderBook)
+	if stateExchange == nil {
+		stateExchange = self.createLendingExchangeObject(orderBook)
+	}
+	stateLendingTrade := stateExchange.getLendingTrade(self.db, tradeIdHash)
+	self.journal = append(self.journal, tradeAmountChange{
+		orderBook: orderBook,
+		tradeId:   tradeIdHash,
+		prev:      stateLendingTrade.data.Amount,
+	})
+	stateLendingTrade.SetAmount(amount)
+}
 func (self *LendingStateDB) GetLendingOrder(orderBook common.Hash, orderId common.Hash) LendingItem {
 	stateObject := self.GetOrNewLendingExchangeObject(orderBook)
 	if stateObject == nil {
@@ -471,6 +490,8 @@ func (self *LendingStateDB) Copy() *LendingStateDB {
 		trie:                       self.db.CopyTrie(self.trie),
 		lendingExchangeStates:      make(map[common.Hash]*lendingExchangeState, len(self.lendingExchangeStatesDirty)),
 		lendingExchangeStatesDirty: make(map[common.Hash]struct{}, len(self.lendingExchangeStatesDirty)),
+		lendingPools:               make(map[common.Hash]*lendingPoolState, len(self.lendingPools)),
+		lendingPoolsDirty:          make(map[common.Hash]struct{}, len(self.lendingPoolsDirty)),
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.lendingExchangeStatesDirty {
@@ -479,6 +500,12 @@ func (self *LendingStateDB) Copy() *LendingStateDB {
 	for addr, exchangeObject := range self.lendingExchangeStates {
 		state.lendingExchangeStates[addr] = exchangeObject.deepCopy(state, state.MarkLendingExchangeObjectDirty)
 	}
+	for hash := range self.lendingPoolsDirty {
+		state.lendingPoolsDirty[hash] = struct{}{}
+	}
+	for hash, poolObject := range self.lendingPools {
+		state.lendingPools[hash] = poolObject.deepCopy(state, state.MarkLendingPoolDirty)
+	}
 
 	return state
 }
@@ -534,6 +561,12 @@ func (s *LendingStateDB) Finalise() {
 			//delete(s.investingStatesDirty, addr)
 		}
 	}
+	for hash, stateObject := range s.lendingPools {
+		if _, isDirty := s.lendingPoolsDirty[hash]; isDirty {
+			stateObject.updateRoot(s.db)
+			s.updateLendingPool(stateObject)
+		}
+	}
 	s.clearJournalAndRefund()
 }
 
@@ -572,10 +605,25 @@ func (s *LendingStateDB) Commit() (root common.Hash, err error) {
 			delete(s.lendingExchangeStatesDirty, addr)
 		}
 	}
+	for hash, stateObject := range s.lendingPools {
+		if _, isDirty := s.lendingPoolsDirty[hash]; isDirty {
+			if err := stateObject.CommitPositionTrie(s.db); err != nil {
+				return EmptyHash, err
+			}
+			s.updateLendingPool(stateObject)
+			delete(s.lendingPoolsDirty, hash)
+		}
+	}
 	// Write trie changes.
 	root, err = s.trie.Commit(func(leaf []byte, parent common.Hash) error {
 		var exchange lendingObject
 		if err := rlp.DecodeBytes(leaf, &exchange); err != nil {
+			// lending pools are the only other objects of the trie with a
+			// nested trie, records have none
+			var pool lendingPoolObject
+			if err := rlp.DecodeBytes(leaf, &pool); err == nil && pool.PositionRoot != EmptyRoot {
+				s.db.TrieDB().Reference(pool.PositionRoot, parent)
+			}
 			return nil
 		}
 		if exchange.InvestingRoot != EmptyRoot {
@@ -666,3 +714,92 @@ func (self *LendingStateDB) CancelLendingTrade(orderBook common.Hash, tradeId ui
 	lendingTrade.SetAmount(Zero)
 	return nil
 }
+
+// updateLendingPool writes the given pool to the trie.
+func (self *LendingStateDB) updateLendingPool(stateObject *lendingPoolState) {
+	hash := stateObject.hash
+	data, err := rlp.EncodeToBytes(stateObject)
+	if err != nil {
+		panic(fmt.Errorf("can't encode lending pool at %x: %v", hash[:], err))
+	}
+	self.setError(self.trie.TryUpdate(hash[:], data))
+}
+
+// getLendingPool retrieves the pool of lendingToken, or creates an empty one.
+func (self *LendingStateDB) getLendingPool(lendingToken common.Address) *lendingPoolState {
+	hash := GetLendingPoolHash(lendingToken)
+	if obj := self.lendingPools[hash]; obj != nil {
+		return obj
+	}
+	data := lendingPoolObject{
+		TotalDeposits:      new(big.Int),
+		TotalDepositShares: new(big.Int),
+		TotalBorrows:       new(big.Int),
+		TotalBorrowShares:  new(big.Int),
+	}
+	enc, err := self.trie.TryGet(hash[:])
+	if err != nil {
+		self.setError(err)
+	}
+	if len(enc) > 0 {
+		if err := rlp.DecodeBytes(enc, &data); err != nil {
+			log.Error("Failed to decode lending pool", "lendingToken", lendingToken.Hex(), "err", err)
+		}
+	}
+	obj := newLendingPoolState(hash, data, self.MarkLendingPoolDirty)
+	self.lendingPools[hash] = obj
+	return obj
+}
+
+// MarkLendingPoolDirty adds the specified pool to the dirty map
+func (self *LendingStateDB) MarkLendingPoolDirty(hash common.Hash) {
+	self.lendingPoolsDirty[hash] = struct{}{}
+}
+
+// GetLendingPool returns the pool of lendingToken as of its last accrual
+func (self *LendingStateDB) GetLendingPool(lendingToken common.Address) *LendingPool {
+	return self.getLendingPool(lendingToken).pool(lendingToken)
+}
+
+// SetLendingPool stores the state of a pool
+func (self *LendingStateDB) SetLendingPool(pool *LendingPool) {
+	stateObject := self.getLendingPool(pool.LendingToken)
+	self.journal = append(self.journal, lendingPoolChange{
+		lendingToken: pool.LendingToken,
+		prev:         stateObject.pool(pool.LendingToken),
+	})
+	stateObject.setPool(pool)
+}
+
+// GetPoolPosition returns the position of user in the pool of lendingToken
+func (self *LendingStateDB) GetPoolPosition(lendingToken common.Address, user common.Address) *PoolPosition {
+	position := self.getLendingPool(lendingToken).getPosition(self.db, user.Hash())
+	return &position
+}
+
+// SetPoolPosition stores the position of user in the pool of lendingToken
+func (self *LendingStateDB) SetPoolPosition(lendingToken common.Address, user common.Address, position *PoolPosition) {
+	stateObject := self.getLendingPool(lendingToken)
+	self.journal = append(self.journal, poolPositionChange{
+		lendingToken: lendingToken,
+		user:         user,
+		prev:         stateObject.getPosition(self.db, user.Hash()),
+	})
+	stateObject.setPosition(user.Hash(), *position)
+}
+
+// GetPoolPositions returns the users having a position in the pool of
+// lendingToken, in ascending order of address, and their positions
+func (self *LendingStateDB) GetPoolPositions(lendingToken common.Address) ([]common.Address, map[common.Address]*PoolPosition) {
+	users := []common.Address{}
+	positions := map[common.Address]*PoolPosition{}
+	for hash, position := range self.getLendingPool(lendingToken).getAllPositions(self.db) {
+		user, position := common.BytesToAddress(hash.Bytes()), position
+		users = append(users, user)
+		positions[user] = &position
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return bytes.Compare(users[i].Bytes(), users[j].Bytes()) < 0
+	})
+	return users, positions
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lendingstate
+
+import (
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/ethdb"
+	"github.com/tomochain/tomochain/rlp"
+	"github.com/tomochain/tomochain/trie"
+)
+
+// LendingBookSubTries decodes a lending book stored in the lending state trie
+// and returns the roots of its investing, borrowing, liquidation time, lending
+// item and lending trade tries.
+func LendingBookSubTries(leaf []byte) (investing, borrowing, liquidationTime, items, trades common.Hash, err error) {
+	var book lendingObject
+	if err := rlp.DecodeBytes(leaf, &book); err != nil {
+		return common.Hash{}, common.Hash{}, common.Hash{}, common.Hash{}, common.Hash{}, err
+	}
+	return book.InvestingRoot, book.BorrowingRoot, book.LiquidationTimeRoot, book.LendingItemRoot, book.LendingTradeRoot, nil
+}
+
+// LendingPoolSubTrie decodes a lending pool stored in the lending state trie and
+// returns the root of its position trie.
+func LendingPoolSubTrie(leaf []byte) (common.Hash, error) {
+	var pool lendingPoolObject
+	if err := rlp.DecodeBytes(leaf, &pool); err != nil {
+		return common.Hash{}, err
+	}
+	return pool.PositionRoot, nil
+}
+
+// IsLendingRecord returns whether a leaf of the lending state trie is a record,
+// which has no nested trie.
+func IsLendingRecord(leaf []byte) bool {
+	var record lendingRecord
+	return rlp.DecodeBytes(leaf, &record) == nil
+}
+
+// ItemListRoot decodes an interest or liquidation time entry stored in the
+// investing, borrowing or liquidation time tries and returns the root of the
+// trie it points to.
+func ItemListRoot(leaf []byte) (common.Hash, error) {
+	var list itemList
+	if err := rlp.DecodeBytes(leaf, &list); err != nil {
+		return common.Hash{}, err
+	}
+	return list.Root, nil
+}
+
+// NewStateSync creates a new lending state trie download scheduler, covering
+// the item lists, lending items and trades nested into every lending book and
+// the positions of every lending pool. Records have nothing nested.
+func NewStateSync(root common.Hash, database ethdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+	var syncer *trie.Sync
+
+	// Nested tries never written to are referenced by an empty hash, skip them
+	addSubTrie := func(root, parent common.Hash, callback trie.LeafCallback) {
+		if root != EmptyHash {
+			syncer.AddSubTrie(root, 64, parent, callback)
+		}
+	}
+
+	itemLists := func(leaf []byte, parent common.Hash) error {
+		root, err := ItemListRoot(leaf)
+		if err != nil {
+			return err
+		}
+		addSubTrie(root, parent, nil)
+		return nil
+	}
+	callback := func(leaf []byte, parent common.Hash) error {
+		investing, borrowing, liquidationTime, items, trades, err := LendingBookSubTries(leaf)
+		if err != nil {
+			positions, poolErr := LendingPoolSubTrie(leaf)
+			if poolErr != nil {
+				if IsLendingRecord(leaf) {
+					return nil
+				}
+				return err
+			}
+			addSubTrie(positions, parent, nil)
+			return nil
+		}
+		addSubTrie(investing, parent, itemLists)
+		addSubTrie(borrowing, parent, itemLists)
+		addSubTrie(liquidationTime, parent, itemLists)
+		addSubTrie(items, parent, nil)
+		addSubTrie(trades, parent, nil)
+		return nil
+	}
+	syncer = trie.NewSync(root, database, callback, bloom)
+	return syncer
+}
@@ -16,6 +16,10 @@ const (
 	TradeStatusOpen       = "OPEN"
 	TradeStatusClosed     = "CLOSED"
 	TradeStatusLiquidated = "LIQUIDATED"
+
+	// TradeStatusPartialRepaid marks a trade which is still open after the
+	// borrower settled part of it
+	TradeStatusPartialRepaid = "PARTIAL_REPAID"
 )
 
 type LendingTrade struct {
@@ -0,0 +1,355 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// MarginAccountInfo describes the margin account of a borrower. Values are in
+// TOMO.
+type MarginAccountInfo struct {
+	Borrower        common.Address     `json:"borrower"`
+	Enabled         bool               `json:"enabled"`
+	CollateralValue *big.Int           `json:"collateralValue"`
+	DebtValue       *big.Int           `json:"debtValue"`
+	Health          *big.Int           `json:"health"` // in basis points, null without debt
+	Liquidatable    bool               `json:"liquidatable"`
+	Assets          []*MarginAssetInfo `json:"assets"`
+	Trades          []*MarginTradeInfo `json:"trades"`
+}
+
+// MarginAssetInfo describes a collateral of a margin account
+type MarginAssetInfo struct {
+	Token                common.Address `json:"token"`
+	Pooled               *big.Int       `json:"pooled"`
+	Locked               *big.Int       `json:"locked"` // in the trades of the account
+	Price                *big.Int       `json:"price"`
+	Value                *big.Int       `json:"value"`
+	LiquidationThreshold *big.Int       `json:"liquidationThreshold"` // in basis points
+	LiquidationPrice     *big.Int       `json:"liquidationPrice"`
+}
+
+// MarginTradeInfo describes an open lending trade of a margin account
+type MarginTradeInfo struct {
+	LendingBook     common.Hash    `json:"lendingBook"`
+	TradeId         uint64         `json:"tradeId"`
+	LendingToken    common.Address `json:"lendingToken"`
+	CollateralToken common.Address `json:"collateralToken"`
+	Debt            *big.Int       `json:"debt"` // in lending token
+	DebtValue       *big.Int       `json:"debtValue"`
+}
+
+// MarginThreshold is the liquidation threshold of a collateral in margin accounts
+type MarginThreshold struct {
+	Token                common.Address `json:"token"`
+	LiquidationRate      *big.Int       `json:"liquidationRate"`      // in percent
+	LiquidationThreshold *big.Int       `json:"liquidationThreshold"` // in basis points
+}
+
+// marginAccount is a margin account priced for evaluation
+type marginAccount struct {
+	info   *MarginAccountInfo
+	assets []*lendingstate.MarginAsset
+	trades []*lendingstate.LendingTrade
+}
+
+// getMarginTOMOPrice returns the price of one token in TOMO, from the oracle of
+// token/TOMO if the token has one
+func (l *Lending) getMarginTOMOPrice(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, token common.Address) (*big.Int, error) {
+	if token == common.HexToAddress(common.TomoNativeAddress) {
+		return common.BasePrice, nil
+	}
+	if report := l.GetOracleReport(header, chain, statedb, tradingStateDb, token, common.HexToAddress(common.TomoNativeAddress)); report.Enabled {
+		if report.Paused || report.Price == nil || report.Price.Sign() <= 0 {
+			return nil, fmt.Errorf("oracle of %s paused: %s", token.Hex(), report.Reason)
+		}
+		return report.Price, nil
+	}
+	price, err := l.GetTOMOBasePrices(header, chain, statedb, tradingStateDb, token)
+	if err != nil {
+		return nil, err
+	}
+	if price == nil || price.Sign() <= 0 {
+		return nil, fmt.Errorf("no TOMO price for %s", token.Hex())
+	}
+	return price, nil
+}
+
+// openMarginTrades returns the trades of the margin account of borrower which
+// are still open
+func openMarginTrades(lendingStateDB *lendingstate.LendingStateDB, borrower common.Address) []lendingstate.MarginTrade {
+	open := []lendingstate.MarginTrade{}
+	for _, ref := range lendingStateDB.GetMarginTrades(borrower) {
+		trade := lendingStateDB.GetLendingTrade(ref.LendingBook, common.Uint64ToHash(ref.TradeId))
+		if trade.TradeId == ref.TradeId && trade.Borrower == borrower {
+			open = append(open, ref)
+		}
+	}
+	return open
+}
+
+// getMarginAccount prices the collaterals and debts of the margin account of
+// borrower. Trades closed since they joined the account are left out.
+func (l *Lending) getMarginAccount(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, borrower common.Address) (*marginAccount, error) {
+	account := &marginAccount{
+		info: &MarginAccountInfo{
+			Borrower:        borrower,
+			Enabled:         lendingStateDB.IsMarginAccountEnabled(borrower),
+			CollateralValue: new(big.Int),
+			DebtValue:       new(big.Int),
+			Assets:          []*MarginAssetInfo{},
+			Trades:          []*MarginTradeInfo{},
+		},
+	}
+	assets := map[common.Address]int{}
+	addAsset := func(token common.Address) (*lendingstate.MarginAsset, *MarginAssetInfo, error) {
+		if index, ok := assets[token]; ok {
+			return account.assets[index], account.info.Assets[index], nil
+		}
+		decimal, err := l.tomox.GetTokenDecimal(chain, statedb, token)
+		if err != nil || decimal == nil || decimal.Sign() <= 0 {
+			return nil, nil, fmt.Errorf("failed to get decimal of %s: %v", token.Hex(), err)
+		}
+		price, err := l.getMarginTOMOPrice(header, chain, statedb, tradingStateDb, token)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, liquidationRate, _ := lendingstate.GetCollateralDetail(statedb, token)
+		pooled := lendingStateDB.GetMarginCollateral(borrower, token)
+		asset := &lendingstate.MarginAsset{
+			Token:           token,
+			Amount:          new(big.Int).Set(pooled),
+			Decimal:         decimal,
+			Price:           price,
+			LiquidationRate: liquidationRate,
+		}
+		info := &MarginAssetInfo{
+			Token:                token,
+			Pooled:               pooled,
+			Locked:               new(big.Int),
+			Price:                price,
+			LiquidationThreshold: asset.LiquidationThreshold(),
+		}
+		assets[token] = len(account.assets)
+		account.assets = append(account.assets, asset)
+		account.info.Assets = append(account.info.Assets, info)
+		return asset, info, nil
+	}
+	for _, token := range lendingStateDB.GetMarginCollateralTokens(borrower) {
+		if lendingStateDB.GetMarginCollateral(borrower, token).Sign() == 0 {
+			continue
+		}
+		if _, _, err := addAsset(token); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range openMarginTrades(lendingStateDB, borrower) {
+		trade := lendingStateDB.GetLendingTrade(ref.LendingBook, common.Uint64ToHash(ref.TradeId))
+		asset, info, err := addAsset(trade.CollateralToken)
+		if err != nil {
+			return nil, err
+		}
+		asset.Amount.Add(asset.Amount, trade.CollateralLockedAmount)
+		info.Locked.Add(info.Locked, trade.CollateralLockedAmount)
+
+		lendingTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, trade.LendingToken)
+		if err != nil || lendingTokenDecimal == nil || lendingTokenDecimal.Sign() <= 0 {
+			return nil, fmt.Errorf("failed to get decimal of %s: %v", trade.LendingToken.Hex(), err)
+		}
+		lendingTokenPrice, err := l.getMarginTOMOPrice(header, chain, statedb, tradingStateDb, trade.LendingToken)
+		if err != nil {
+			return nil, err
+		}
+		debt := lendingstate.CalculateTotalRepayValue(header.Time.Uint64(), trade.LiquidationTime, trade.Term, trade.Interest, trade.Amount)
+		debtValue := new(big.Int).Mul(debt, lendingTokenPrice)
+		debtValue.Div(debtValue, lendingTokenDecimal)
+		account.info.DebtValue.Add(account.info.DebtValue, debtValue)
+		account.info.Trades = append(account.info.Trades, &MarginTradeInfo{
+			LendingBook:     ref.LendingBook,
+			TradeId:         ref.TradeId,
+			LendingToken:    trade.LendingToken,
+			CollateralToken: trade.CollateralToken,
+			Debt:            debt,
+			DebtValue:       debtValue,
+		})
+		tradeCopy := trade
+		account.trades = append(account.trades, &tradeCopy)
+	}
+	for i, asset := range account.assets {
+		account.info.Assets[i].Value = asset.Value()
+		account.info.Assets[i].LiquidationPrice = lendingstate.MarginLiquidationPrice(account.assets, i, account.info.DebtValue)
+		account.info.CollateralValue.Add(account.info.CollateralValue, account.info.Assets[i].Value)
+	}
+	account.info.Health = lendingstate.MarginHealth(account.assets, account.info.DebtValue)
+	account.info.Liquidatable = account.info.Health != nil && account.info.Health.Cmp(lendingstate.BaseMarginHealth) < 0
+	return account, nil
+}
+
+// GetMarginAccountInfo returns the health of the margin account of borrower
+// and the liquidation threshold and price of each of its collaterals
+func (l *Lending) GetMarginAccountInfo(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, borrower common.Address) (*MarginAccountInfo, error) {
+	account, err := l.getMarginAccount(header, chain, statedb, lendingStateDB, tradingStateDb, borrower)
+	if err != nil {
+		return nil, err
+	}
+	return account.info, nil
+}
+
+// GetMarginThresholds returns the liquidation threshold of every registered
+// collateral in margin accounts
+func (l *Lending) GetMarginThresholds(statedb *state.StateDB) []*MarginThreshold {
+	thresholds := []*MarginThreshold{}
+	for _, token := range lendingstate.GetAllCollateral(statedb) {
+		_, liquidationRate, _ := lendingstate.GetCollateralDetail(statedb, token)
+		asset := &lendingstate.MarginAsset{Token: token, LiquidationRate: liquidationRate}
+		thresholds = append(thresholds, &MarginThreshold{
+			Token:                token,
+			LiquidationRate:      liquidationRate,
+			LiquidationThreshold: asset.LiquidationThreshold(),
+		})
+	}
+	return thresholds
+}
+
+// ProcessMarginItem applies a deposit into or a withdrawal from the margin
+// account of the user of the item. A withdrawal may not drop the health of the
+// account below 1.
+func (l *Lending) ProcessMarginItem(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, order *lendingstate.LendingItem) error {
+	if !chain.Config().IsTIPTomoXLendingMargin(header.Number) {
+		return fmt.Errorf("ProcessMarginItem: margin accounts are not enabled. Type: %s", order.Type)
+	}
+	switch order.Type {
+	case lendingstate.MarginDeposit:
+		if err := lendingstate.DepositMarginCollateral(statedb, lendingStateDB, order.UserAddress, order.CollateralToken, order.Quantity); err != nil {
+			return err
+		}
+	case lendingstate.MarginWithdraw:
+		if err := lendingstate.WithdrawMarginCollateral(statedb, lendingStateDB, order.UserAddress, order.CollateralToken, order.Quantity); err != nil {
+			return err
+		}
+		if len(openMarginTrades(lendingStateDB, order.UserAddress)) > 0 {
+			account, err := l.getMarginAccount(header, chain, statedb, lendingStateDB, tradingStateDb, order.UserAddress)
+			if err != nil {
+				return fmt.Errorf("ProcessMarginItem: failed to evaluate the margin account. User: %s. Err: %v", order.UserAddress.Hex(), err)
+			}
+			if account.info.Liquidatable {
+				return lendingstate.ErrMarginUnhealthy
+			}
+			break
+		}
+		// an account left without trades or collateral stops taking new trades
+		for _, token := range lendingStateDB.GetMarginCollateralTokens(order.UserAddress) {
+			if lendingStateDB.GetMarginCollateral(order.UserAddress, token).Sign() > 0 {
+				return nil
+			}
+		}
+		lendingStateDB.SetMarginAccountEnabled(order.UserAddress, false)
+	default:
+		return fmt.Errorf("ProcessMarginItem: invalid type %s", order.Type)
+	}
+	log.Debug("ProcessMarginItem", "type", order.Type, "user", order.UserAddress.Hex(), "collateralToken", order.CollateralToken.Hex(), "quantity", order.Quantity)
+	return nil
+}
+
+// ProcessMarginLiquidation liquidates every margin account whose health
+// dropped below 1. The collateral locked in each trade goes to its investor,
+// and the pooled collaterals cover what the locked collateral doesn't.
+// Accounts which can't be priced are left for the next epoch.
+func (l *Lending) ProcessMarginLiquidation(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB) ([]*lendingstate.LendingTrade, error) {
+	liquidatedTrades := []*lendingstate.LendingTrade{}
+	for _, borrower := range lendingStateDB.GetMarginAccounts() {
+		refs := lendingStateDB.GetMarginTrades(borrower)
+		if len(refs) == 0 {
+			continue
+		}
+		account, err := l.getMarginAccount(header, chain, statedb, lendingStateDB, tradingStateDb, borrower)
+		if err != nil {
+			log.Warn("Fail when evaluate margin account", "borrower", borrower.Hex(), "err", err)
+			continue
+		}
+		if !account.info.Liquidatable {
+			if len(account.trades) < len(refs) {
+				lendingStateDB.PruneMarginTrades(borrower, openMarginTrades(lendingStateDB, borrower))
+			}
+			continue
+		}
+		log.Debug("ProcessMarginLiquidation", "borrower", borrower.Hex(), "health", account.info.Health, "debtValue", account.info.DebtValue)
+		trades, err := l.liquidateMarginAccount(statedb, lendingStateDB, tradingStateDb, account)
+		if err != nil {
+			return liquidatedTrades, err
+		}
+		liquidatedTrades = append(liquidatedTrades, trades...)
+		lendingStateDB.PruneMarginTrades(borrower, nil)
+	}
+	return liquidatedTrades, nil
+}
+
+// liquidateMarginAccount liquidates all the trades of a margin account
+func (l *Lending) liquidateMarginAccount(statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, account *marginAccount) ([]*lendingstate.LendingTrade, error) {
+	assets := map[common.Address]*lendingstate.MarginAsset{}
+	for _, asset := range account.assets {
+		assets[asset.Token] = asset
+	}
+	liquidatedTrades := []*lendingstate.LendingTrade{}
+	for i, trade := range account.trades {
+		info := account.info.Trades[i]
+		newTrade, err := l.LiquidationTrade(lendingStateDB, statedb, tradingStateDb, info.LendingBook, trade.TradeId)
+		if err != nil {
+			log.Error("Fail when liquidate margin trade", "borrower", trade.Borrower.Hex(), "lendingBook", info.LendingBook.Hex(), "tradeId", trade.TradeId, "err", err)
+			return nil, err
+		}
+		collateral := assets[trade.CollateralToken]
+		lockedValue := new(big.Int).Mul(trade.CollateralLockedAmount, collateral.Price)
+		lockedValue.Div(lockedValue, collateral.Decimal)
+
+		// cover the rest of the debt with the pooled collaterals
+		pooledAmounts := map[common.Address]*big.Int{}
+		shortfall := new(big.Int).Sub(info.DebtValue, lockedValue)
+		for _, asset := range account.assets {
+			if shortfall.Sign() <= 0 {
+				break
+			}
+			pooled := lendingStateDB.GetMarginCollateral(trade.Borrower, asset.Token)
+			if pooled.Sign() == 0 {
+				continue
+			}
+			amount := new(big.Int).Mul(shortfall, asset.Decimal)
+			amount.Div(amount, asset.Price)
+			if amount.Cmp(pooled) > 0 {
+				amount = pooled
+			}
+			if amount.Sign() == 0 {
+				continue
+			}
+			if err := lendingstate.TransferMarginCollateral(statedb, lendingStateDB, trade.Borrower, asset.Token, amount, trade.Investor); err != nil {
+				return nil, err
+			}
+			pooledAmounts[asset.Token] = amount
+			value := new(big.Int).Mul(amount, asset.Price)
+			shortfall.Sub(shortfall, value.Div(value, asset.Decimal))
+		}
+
+		newTrade.Status = lendingstate.TradeStatusLiquidated
+		liquidationData := lendingstate.LiquidationData{
+			RecallAmount:      common.Big0,
+			LiquidationAmount: newTrade.CollateralLockedAmount,
+			CollateralPrice:   collateral.Price,
+			Reason:            lendingstate.LiquidatedByMargin,
+			DebtAmount:        info.Debt,
+			PooledAmounts:     pooledAmounts,
+		}
+		extraData, _ := json.Marshal(liquidationData)
+		newTrade.ExtraData = string(extraData)
+		liquidatedTrades = append(liquidatedTrades, newTrade)
+	}
+	return liquidatedTrades, nil
+}
@@ -0,0 +1,104 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestLiquidateMarginAccount(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingState, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingState, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000e11")
+		investor        = common.HexToAddress("0x0000000000000000000000000000000000000e12")
+		lendingToken    = common.HexToAddress("0x0000000000000000000000000000000000000e13")
+		pooledToken     = common.HexToAddress("0x0000000000000000000000000000000000000e14")
+		collateralToken = common.HexToAddress(common.TomoNativeAddress)
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+		term            = common.OneYear
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		marginBook      = lendingstate.GetMarginLiquidationBook(collateralToken, lendingToken)
+		tradeId         = uint64(1)
+	)
+	statedb.SetNonce(pooledToken, 1)
+	lendingstate.AddTokenBalance(borrower, big.NewInt(1000), pooledToken, statedb)
+	if err := lendingstate.DepositMarginCollateral(statedb, lendingState, borrower, pooledToken, big.NewInt(1000)); err != nil {
+		t.Fatalf("failed to deposit: %v", err)
+	}
+	lendingstate.AddTokenBalance(lockAddress, big.NewInt(800), collateralToken, statedb)
+
+	trade := lendingstate.LendingTrade{
+		Borrower:               borrower,
+		Investor:               investor,
+		LendingToken:           lendingToken,
+		CollateralToken:        collateralToken,
+		Term:                   term,
+		LiquidationPrice:       big.NewInt(400),
+		CollateralLockedAmount: big.NewInt(800),
+		Amount:                 big.NewInt(1000),
+		TradeId:                tradeId,
+	}
+	lendingState.AddMarginTrade(borrower, lendingBook, tradeId)
+	lendingState.InsertTradingItem(lendingBook, tradeId, trade)
+	lendingState.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(trade.LiquidationTime), tradeId)
+	tradingState.InsertLiquidationPrice(marginBook, trade.LiquidationPrice, lendingBook, tradeId)
+
+	// a debt of 1050 TOMO: the 800 TOMO locked and 125 pooled tokens at 2 TOMO
+	account := &marginAccount{
+		info: &MarginAccountInfo{
+			Trades: []*MarginTradeInfo{{LendingBook: lendingBook, TradeId: tradeId, Debt: big.NewInt(1050), DebtValue: big.NewInt(1050)}},
+		},
+		assets: []*lendingstate.MarginAsset{
+			{Token: pooledToken, Amount: big.NewInt(1000), Decimal: big.NewInt(1), Price: big.NewInt(2), LiquidationRate: big.NewInt(150)},
+			{Token: collateralToken, Amount: big.NewInt(800), Decimal: big.NewInt(1), Price: big.NewInt(1), LiquidationRate: big.NewInt(110)},
+		},
+		trades: []*lendingstate.LendingTrade{&trade},
+	}
+	l := &Lending{}
+	trades, err := l.liquidateMarginAccount(statedb, lendingState, tradingState, account)
+	if err != nil {
+		t.Fatalf("failed to liquidate: %v", err)
+	}
+	if len(trades) != 1 || trades[0].Status != lendingstate.TradeStatusLiquidated {
+		t.Fatalf("liquidated trades mismatch: %v", trades)
+	}
+	balances := []struct {
+		addr  common.Address
+		token common.Address
+		want  int64
+	}{
+		{investor, collateralToken, 800},
+		{investor, pooledToken, 125},
+		{lockAddress, collateralToken, 0},
+		{common.HexToAddress(common.LendingMarginAddress), pooledToken, 875},
+	}
+	for _, b := range balances {
+		if have := lendingstate.GetTokenBalance(b.addr, b.token, statedb); have.Cmp(big.NewInt(b.want)) != 0 {
+			t.Errorf("balance mismatch of %s in %s: have %v, want %d", b.addr.Hex(), b.token.Hex(), have, b.want)
+		}
+	}
+	if have := lendingState.GetMarginCollateral(borrower, pooledToken); have.Int64() != 875 {
+		t.Errorf("pooled collateral mismatch: have %v, want 875", have)
+	}
+	var extra lendingstate.LiquidationData
+	if err := json.Unmarshal([]byte(trades[0].ExtraData), &extra); err != nil {
+		t.Fatalf("failed to decode extra data: %v", err)
+	}
+	if extra.Reason != lendingstate.LiquidatedByMargin || extra.PooledAmounts[pooledToken].Cmp(big.NewInt(125)) != 0 {
+		t.Errorf("extra data mismatch: %+v", extra)
+	}
+	tradingState.Commit()
+	if price, _ := tradingState.GetHighestLiquidationPriceData(marginBook, common.Big0); price.Sign() != 0 {
+		t.Errorf("liquidated trade still in the margin liquidation book at price %v", price)
+	}
+}
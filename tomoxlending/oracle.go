@@ -0,0 +1,161 @@
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// oracleSources returns the prices of collateral/lendToken given by the
+// sources enabled for the collateral. Contract prices are as old as the block
+// they were set in, TomoX prices as old as the current epoch.
+func (l *Lending) oracleSources(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address, sources uint64) []lendingstate.OracleSourcePrice {
+	number := header.Number.Uint64()
+	epochAge := number % chain.Config().Posv.Epoch
+	contractAge := func(updatedBlock *big.Int) uint64 {
+		if updatedBlock.Uint64() > number {
+			return 0
+		}
+		return number - updatedBlock.Uint64()
+	}
+	result := []lendingstate.OracleSourcePrice{}
+	add := func(source uint64, price *big.Int, age uint64) {
+		if price != nil && price.Sign() > 0 {
+			result = append(result, lendingstate.OracleSourcePrice{Source: lendingstate.OracleSourceNames[source], Price: price, Age: age})
+		}
+	}
+	// token decimals are only needed to convert inverse and cross prices
+	decimals := func() (lendingTokenDecimal, collateralTokenDecimal *big.Int, ok bool) {
+		lendingTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, lendingToken)
+		if err != nil || lendingTokenDecimal == nil || lendingTokenDecimal.Sign() == 0 {
+			log.Debug("Oracle: failed to get lendingToken decimal", "lendingToken", lendingToken.Hex(), "err", err)
+			return nil, nil, false
+		}
+		collateralTokenDecimal, err = l.tomox.GetTokenDecimal(chain, statedb, collateralToken)
+		if err != nil || collateralTokenDecimal == nil || collateralTokenDecimal.Sign() == 0 {
+			log.Debug("Oracle: failed to get collateral decimal", "collateral", collateralToken.Hex(), "err", err)
+			return nil, nil, false
+		}
+		return lendingTokenDecimal, collateralTokenDecimal, true
+	}
+	if sources&lendingstate.OracleSourceContract != 0 {
+		price, updatedBlock := lendingstate.GetCollateralPrice(statedb, collateralToken, lendingToken)
+		add(lendingstate.OracleSourceContract, price, contractAge(updatedBlock))
+	}
+	if sources&lendingstate.OracleSourceInverseContract != 0 {
+		inversePrice, updatedBlock := lendingstate.GetCollateralPrice(statedb, lendingToken, collateralToken)
+		if inversePrice.Sign() > 0 {
+			if lendingTokenDecimal, collateralTokenDecimal, ok := decimals(); ok {
+				price := new(big.Int).Mul(lendingTokenDecimal, collateralTokenDecimal)
+				price = new(big.Int).Div(price, inversePrice)
+				add(lendingstate.OracleSourceInverseContract, price, contractAge(updatedBlock))
+			}
+		}
+	}
+	if sources&lendingstate.OracleSourceTomoX != 0 {
+		price, err := l.GetMediumTradePriceBeforeEpoch(chain, statedb, tradingStateDb, collateralToken, lendingToken)
+		if err != nil {
+			log.Debug("Oracle: failed to get TomoX price", "collateral", collateralToken.Hex(), "lendingToken", lendingToken.Hex(), "err", err)
+		}
+		add(lendingstate.OracleSourceTomoX, price, epochAge)
+	}
+	if sources&lendingstate.OracleSourceTomoCross != 0 {
+		collateralTOMOPrice, err := l.GetTOMOBasePrices(header, chain, statedb, tradingStateDb, collateralToken)
+		if err != nil {
+			log.Debug("Oracle: failed to get collateral/TOMO price", "collateral", collateralToken.Hex(), "err", err)
+		}
+		lendTokenTOMOPrice, err := l.GetTOMOBasePrices(header, chain, statedb, tradingStateDb, lendingToken)
+		if err != nil {
+			log.Debug("Oracle: failed to get lendToken/TOMO price", "lendingToken", lendingToken.Hex(), "err", err)
+		}
+		if collateralTOMOPrice != nil && lendTokenTOMOPrice != nil && lendTokenTOMOPrice.Sign() > 0 {
+			if lendingTokenDecimal, _, ok := decimals(); ok {
+				price := new(big.Int).Mul(collateralTOMOPrice, lendingTokenDecimal)
+				price = new(big.Int).Div(price, lendTokenTOMOPrice)
+				add(lendingstate.OracleSourceTomoCross, price, epochAge)
+			}
+		}
+	}
+	return result
+}
+
+// GetOracleReport prices collateral/lendToken with the oracle configured for
+// the collateral and explains the result. It doesn't change any state. The
+// report is disabled before the oracle fork or if the collateral has no
+// oracle, in which case GetCollateralPrices applies.
+func (l *Lending) GetOracleReport(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) *lendingstate.OracleReport {
+	report := &lendingstate.OracleReport{
+		CollateralToken: collateralToken,
+		LendingToken:    lendingToken,
+		Sources:         []lendingstate.OracleSourcePrice{},
+	}
+	if !chain.Config().IsTIPTomoXLendingOracle(header.Number) {
+		return report
+	}
+	config := lendingstate.GetCollateralOracleConfig(statedb, collateralToken)
+	if !config.Enabled() {
+		return report
+	}
+	report.Enabled = true
+	report.Sources = l.oracleSources(header, chain, statedb, tradingStateDb, collateralToken, lendingToken, config.Sources)
+	report.Evaluate(config, oracleReference(header, tradingStateDb, collateralToken, lendingToken))
+	return report
+}
+
+// liquidationCollateralPrice returns the price of collateral/lendToken to
+// liquidate at. Unlike GetCollateralPrices, it fails with ErrOraclePaused while
+// the circuit breaker of the oracle of the collateral is tripped.
+func (l *Lending) liquidationCollateralPrice(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) (*big.Int, error) {
+	if report := l.GetOracleReport(header, chain, statedb, tradingStateDb, collateralToken, lendingToken); report.Paused {
+		log.Debug("Oracle paused liquidation", "collateralToken", collateralToken.Hex(), "lendingToken", lendingToken.Hex(), "reason", report.Reason)
+		return nil, lendingstate.ErrOraclePaused
+	}
+	_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingStateDb, collateralToken, lendingToken)
+	return collateralPrice, err
+}
+
+// oracleReference returns the time weighted average of the prices accepted by
+// the oracle of collateral/lendToken, up to the time of the header.
+func oracleReference(header *types.Header, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) *big.Int {
+	price, twap, updatedAt, _ := tradingStateDb.GetOraclePrice(tradingstate.GetOracleHash(collateralToken, lendingToken))
+	elapsed := uint64(0)
+	if now := header.Time.Uint64(); now > updatedAt {
+		elapsed = now - updatedAt
+	}
+	return lendingstate.OracleTWAP(twap, price, elapsed)
+}
+
+// UpdateOraclePrice evaluates the oracle of collateral/lendToken and records
+// the accepted price of the epoch. While the circuit breaker is tripped by a
+// deviation, the accepted price only moves towards the new price by the
+// maximum deviation, so the breaker resets once the price settles.
+func (l *Lending) UpdateOraclePrice(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) *lendingstate.OracleReport {
+	report := l.GetOracleReport(header, chain, statedb, tradingStateDb, collateralToken, lendingToken)
+	if !report.Enabled || report.Price == nil {
+		return report
+	}
+	epoch := header.Number.Uint64() / chain.Config().Posv.Epoch
+	oracleHash := tradingstate.GetOracleHash(collateralToken, lendingToken)
+	price, _, _, updatedEpoch := tradingStateDb.GetOraclePrice(oracleHash)
+	if updatedEpoch == epoch && price.Sign() > 0 {
+		return report
+	}
+	accepted := report.Price
+	if report.Paused {
+		config := lendingstate.GetCollateralOracleConfig(statedb, collateralToken)
+		accepted = lendingstate.ClampPrice(report.Price, report.Reference, config.MaxDeviation)
+		log.Warn("Collateral oracle circuit breaker tripped, liquidations paused", "collateral", collateralToken.Hex(), "lendingToken", lendingToken.Hex(), "price", report.Price, "reference", report.Reference, "deviation", report.Deviation)
+	}
+	twap := report.Reference
+	if twap == nil {
+		twap = accepted
+	}
+	tradingStateDb.SetOraclePrice(oracleHash, accepted, twap, header.Time.Uint64(), epoch)
+	return report
+}
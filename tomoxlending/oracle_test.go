@@ -0,0 +1,75 @@
+package tomoxlending
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// Tests that collateral prices are read through the oracle after its fork, and
+// that its circuit breaker only pauses liquidations.
+func TestGuardedCollateralPrices(t *testing.T) {
+	var (
+		relayer         = common.HexToAddress("0x0000000000000000000000000000000000000d11")
+		lendingToken    = common.HexToAddress(common.TomoNativeAddress)
+		collateralToken = common.HexToAddress("0x0000000000000000000000000000000000000e31")
+		price           = new(big.Int).Mul(big.NewInt(2), common.BasePrice)
+		header          = &types.Header{Number: new(big.Int).Set(common.TIPTomoXLendingOracleBlock), Time: big.NewInt(1000)}
+		config          = *params.TestChainConfig
+	)
+	config.Posv = &params.PosvConfig{Epoch: 900}
+	chain := testChain{config: &config}
+
+	tomoX := tomox.New(&tomox.DefaultConfig)
+	tomoX.SetTokenDecimal(collateralToken, common.BasePrice)
+	l := New(tomoX)
+
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+	setTestLendingRelayer(statedb, relayer, relayer, common.BasePrice, lendingToken, common.OneYear, collateralToken, price, header.Number)
+
+	// Without an oracle the contract price applies unguarded
+	oracleHash := tradingstate.GetOracleHash(collateralToken, lendingToken)
+	tradingStateDB.SetOraclePrice(oracleHash, common.BasePrice, common.BasePrice, header.Time.Uint64(), 0)
+	if _, have, err := l.GetCollateralPrices(header, chain, statedb, tradingStateDB, collateralToken, lendingToken); err != nil || have.Cmp(price) != 0 {
+		t.Fatalf("unguarded price mismatch: have %v, want %v, err %v", have, price, err)
+	}
+	// With an oracle, a price deviating from the average by more than 10% trips
+	// the circuit breaker
+	loc := lendingstate.GetLocMappingAtKey(collateralToken.Hash(), lendingstate.CollateralMapSlot)
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	statedb.SetState(contract, state.GetLocOfStructElement(loc, lendingstate.CollateralStructSlots["oracleSources"]), common.BigToHash(new(big.Int).SetUint64(lendingstate.OracleSourceContract)))
+	statedb.SetState(contract, state.GetLocOfStructElement(loc, lendingstate.CollateralStructSlots["oracleMaxDeviation"]), common.BigToHash(big.NewInt(1000)))
+
+	if _, err := l.liquidationCollateralPrice(header, chain, statedb, tradingStateDB, collateralToken, lendingToken); err != lendingstate.ErrOraclePaused {
+		t.Fatalf("deviating price error mismatch: have %v, want %v", err, lendingstate.ErrOraclePaused)
+	}
+	if _, have, err := l.GetCollateralPrices(header, chain, statedb, tradingStateDB, collateralToken, lendingToken); err != nil || have.Cmp(price) != 0 {
+		t.Fatalf("paused price mismatch outside liquidations: have %v, want %v, err %v", have, price, err)
+	}
+	// The average catches up with the price after it stood for the whole window
+	later := &types.Header{Number: header.Number, Time: new(big.Int).Add(header.Time, new(big.Int).SetUint64(lendingstate.OracleTWAPWindow))}
+	tradingStateDB.SetOraclePrice(oracleHash, price, common.BasePrice, header.Time.Uint64(), 0)
+	if _, err := l.liquidationCollateralPrice(later, chain, statedb, tradingStateDB, collateralToken, lendingToken); err != nil {
+		t.Fatalf("settled price still paused: %v", err)
+	}
+	tradingStateDB.SetOraclePrice(oracleHash, price, price, header.Time.Uint64(), 0)
+	if have, err := l.liquidationCollateralPrice(header, chain, statedb, tradingStateDB, collateralToken, lendingToken); err != nil || have.Cmp(price) != 0 {
+		t.Fatalf("oracle price mismatch: have %v, want %v, err %v", have, price, err)
+	}
+	// Before the fork the oracle is ignored
+	tradingStateDB.SetOraclePrice(oracleHash, common.BasePrice, common.BasePrice, header.Time.Uint64(), 0)
+	before := &types.Header{Number: new(big.Int).Sub(header.Number, common.Big1), Time: header.Time}
+	if _, err := l.liquidationCollateralPrice(before, chain, statedb, tradingStateDB, collateralToken, lendingToken); err == lendingstate.ErrOraclePaused {
+		t.Fatalf("oracle applied before its fork")
+	}
+}
@@ -28,6 +28,12 @@ func (l *Lending) CommitOrder(header *types.Header, coinbase common.Address, cha
 }
 
 func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
+	return l.applyOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingOrderBook, order, true)
+}
+
+// applyOrder processes a lending order against the given states, the signature
+// of the order is only checked if signed is set.
+func (l *Lending) applyOrder(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingOrderBook common.Hash, order *lendingstate.LendingItem, signed bool) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
 	var (
 		rejects []*lendingstate.LendingItem
 		trades  []*lendingstate.LendingTrade
@@ -56,12 +62,62 @@ func (l *Lending) ApplyOrder(header *types.Header, coinbase common.Address, chai
 		}
 	}()
 
-	if err := order.VerifyLendingItem(statedb); err != nil {
+	verify := order.VerifyUnsignedLendingItem
+	if signed {
+		signer := types.MakeLendingSigner(chain.Config(), header.Number)
+		verify = func(statedb *state.StateDB) error { return order.VerifyLendingItem(statedb, signer) }
+	}
+	if err := verify(statedb); err != nil {
 		log.Debug("invalid lending order", "order", lendingstate.ToJSON(order), "err", err)
 		rejects = append(rejects, order)
 		return trades, rejects, nil
 	}
 
+	if lendingstate.IsPoolLendingType(order.Type) {
+		snap, lendingSnap := statedb.Snapshot(), lendingStateDB.Snapshot()
+		if err := l.ProcessPoolItem(header, chain, statedb, lendingStateDB, tradingStateDb, order); err != nil {
+			log.Debug("Can not process pool item", "err", err)
+			statedb.RevertToSnapshot(snap)
+			lendingStateDB.RevertToSnapshot(lendingSnap)
+			rejects = append(rejects, order)
+		}
+		return trades, rejects, nil
+	}
+	if lendingstate.IsMarginLendingType(order.Type) {
+		snap := statedb.Snapshot()
+		if err := l.ProcessMarginItem(header, chain, statedb, lendingStateDB, tradingStateDb, order); err != nil {
+			log.Debug("Can not process margin item", "err", err)
+			statedb.RevertToSnapshot(snap)
+			rejects = append(rejects, order)
+		}
+		return trades, rejects, nil
+	}
+	if order.Type == lendingstate.AuctionBid {
+		snap, lendingSnap := statedb.Snapshot(), lendingStateDB.Snapshot()
+		if err := l.ProcessAuctionBid(header, chain, statedb, lendingStateDB, order); err != nil {
+			log.Debug("Can not process auction bid", "err", err)
+			statedb.RevertToSnapshot(snap)
+			lendingStateDB.RevertToSnapshot(lendingSnap)
+			rejects = append(rejects, order)
+		}
+		return trades, rejects, nil
+	}
+	if order.Type == lendingstate.Rollover {
+		lendingSnap := lendingStateDB.Snapshot()
+		tradingSnap := tradingStateDb.Snapshot()
+		snap := statedb.Snapshot()
+		newTrades, newRejects, err := l.ProcessRollover(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, order)
+		if err != nil {
+			log.Debug("Can not process rollover", "err", err)
+			lendingStateDB.RevertToSnapshot(lendingSnap)
+			tradingStateDb.RevertToSnapshot(tradingSnap)
+			statedb.RevertToSnapshot(snap)
+			rejects = append(rejects, order)
+			return trades, rejects, nil
+		}
+		return newTrades, newRejects, nil
+	}
+
 	switch order.Type {
 	case lendingstate.TopUp:
 		err, reject, newLendingTrade := l.ProcessTopUp(lendingStateDB, statedb, tradingStateDb, order)
@@ -247,6 +303,24 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 		if oldestOrder.Quantity == nil || oldestOrder.Quantity.Sign() == 0 && amount.Sign() == 0 {
 			break
 		}
+		if oldestOrder.UserAddress == order.UserAddress && chain.Config().IsTIPTomoXSelfTradePrevention(header.Number) {
+			if mode := order.SelfTradePrevention(); mode != "" {
+				var (
+					cancelTaker bool
+					cancels     []*lendingstate.LendingItem
+				)
+				quantityToTrade, cancelTaker, cancels, err = preventSelfTrade(lendingStateDB, lendingOrderBook, side, Interest, orderId, amount, quantityToTrade, order, &oldestOrder, mode)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				// the cancelled items go along the rejected ones, told apart by their status
+				rejects = append(rejects, cancels...)
+				if cancelTaker {
+					break
+				}
+				continue
+			}
+		}
 		var (
 			tradedQuantity    *big.Int
 			maxTradedQuantity *big.Int
@@ -402,8 +476,14 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 			lendingStateDB.InsertLiquidationTime(lendingOrderBook, new(big.Int).SetUint64(liquidationTime), tradingId)
 			log.Debug("SetTradeNonce", "lendingOrderBook", lendingOrderBook.Hex(), "nonce", tradingId+1)
 			lendingStateDB.SetTradeNonce(lendingOrderBook, tradingId)
-			log.Debug("InsertLiquidationPrice", "TradingOrderBookHash", tradingstate.GetTradingOrderBookHash(collateralToken, order.LendingToken).Hex(), "tradingId", tradingId, "lendingOrderBook", lendingOrderBook.Hex(), "liquidationPrice", liquidationPrice)
-			tradingStateDb.InsertLiquidationPrice(tradingstate.GetTradingOrderBookHash(collateralToken, order.LendingToken), liquidationPrice, lendingOrderBook, tradingId)
+			// trades of a margin account are liquidated with the account
+			if chain.Config().IsTIPTomoXLendingMargin(header.Number) && lendingStateDB.IsMarginAccountEnabled(lendingTrade.Borrower) {
+				log.Debug("AddMarginTrade", "borrower", lendingTrade.Borrower.Hex(), "lendingOrderBook", lendingOrderBook.Hex(), "tradingId", tradingId)
+				lendingStateDB.AddMarginTrade(lendingTrade.Borrower, lendingOrderBook, tradingId)
+			}
+			liquidationBook := lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingOrderBook, &lendingTrade)
+			log.Debug("InsertLiquidationPrice", "TradingOrderBookHash", liquidationBook.Hex(), "tradingId", tradingId, "lendingOrderBook", lendingOrderBook.Hex(), "liquidationPrice", liquidationPrice)
+			tradingStateDb.InsertLiquidationPrice(liquidationBook, liquidationPrice, lendingOrderBook, tradingId)
 			trades = append(trades, &lendingTrade)
 		}
 		if rejectMaker {
@@ -417,6 +497,63 @@ func (l *Lending) processOrderList(header *types.Header, coinbase common.Address
 	return quantityToTrade, trades, rejects, nil
 }
 
+// preventSelfTrade applies the self-trade prevention mode of the taker item to
+// a resting maker item of the same user instead of matching them. It returns
+// the quantity of the taker item still to trade, whether the taker item is
+// cancelled and the items cancelled, which carry the cancelled status to be
+// told apart from rejected items.
+func preventSelfTrade(lendingStateDB *lendingstate.LendingStateDB, lendingOrderBook common.Hash, side string, Interest *big.Int, orderId common.Hash, amount *big.Int, quantityToTrade *big.Int, order *lendingstate.LendingItem, oldestOrder *lendingstate.LendingItem, mode string) (*big.Int, bool, []*lendingstate.LendingItem, error) {
+	var (
+		cancels     []*lendingstate.LendingItem
+		cancelTaker bool
+		cancelMaker bool
+	)
+	switch mode {
+	case types.SelfTradeCancelNewest:
+		cancelTaker = true
+	case types.SelfTradeCancelOldest:
+		cancelMaker = true
+	case types.SelfTradeCancelBoth:
+		cancelTaker, cancelMaker = true, true
+	case types.SelfTradeDecrementAndCancel:
+		decrement := lendingstate.CloneBigInt(amount)
+		if quantityToTrade.Cmp(amount) < 0 {
+			decrement = lendingstate.CloneBigInt(quantityToTrade)
+		}
+		quantityToTrade = lendingstate.Sub(quantityToTrade, decrement)
+		if err := lendingStateDB.SubAmountLendingItem(lendingOrderBook, orderId, Interest, decrement, side); err != nil {
+			return nil, false, nil, err
+		}
+		cancelTaker = quantityToTrade.Sign() == 0
+		if decrement.Cmp(amount) == 0 {
+			// the maker item is already out of the order book
+			cancels = append(cancels, selfTradeCancelled(oldestOrder))
+		}
+	default:
+		return nil, false, nil, fmt.Errorf("unsupported self-trade prevention mode %s", mode)
+	}
+	log.Debug("Prevent self-trade", "mode", mode, "taker", order.Hash, "maker", oldestOrder.Hash, "cancelTaker", cancelTaker, "cancelMaker", cancelMaker)
+	if cancelMaker {
+		if err := lendingStateDB.CancelLendingOrder(lendingOrderBook, oldestOrder); err != nil {
+			return nil, false, nil, err
+		}
+		cancels = append(cancels, selfTradeCancelled(oldestOrder))
+	}
+	if cancelTaker {
+		cancels = append(cancels, selfTradeCancelled(order))
+		quantityToTrade = new(big.Int)
+	}
+	return quantityToTrade, cancelTaker, cancels, nil
+}
+
+// selfTradeCancelled returns a copy of a lending item cancelled by the
+// self-trade prevention, reported with the cancelled status.
+func selfTradeCancelled(item *lendingstate.LendingItem) *lendingstate.LendingItem {
+	cancelled := *item
+	cancelled.Status = lendingstate.LendingStatusCancelled
+	return &cancelled
+}
+
 func (l *Lending) getLendQuantity(
 	lendTokenTOMOPrice,
 	collateralPrice,
@@ -677,7 +814,7 @@ func DoSettleBalance(coinbase common.Address, takerOrder, makerOrder *lendingsta
 
 func (l *Lending) ProcessCancelOrder(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, chain consensus.ChainContext, coinbase common.Address, lendingOrderBook common.Hash, order *lendingstate.LendingItem) (error, bool) {
 	originOrder := lendingStateDB.GetLendingOrder(lendingOrderBook, common.BigToHash(new(big.Int).SetUint64(order.LendingId)))
-	if originOrder == lendingstate.EmptyLendingOrder {
+	if originOrder.Hash == (common.Hash{}) {
 		return fmt.Errorf("lendingOrder not found. Id: %v. LendToken: %s . Term: %v. CollateralToken: %v", order.LendingId, order.LendingToken.Hex(), order.Term, order.CollateralToken.Hex()), false
 	}
 	if originOrder.Hash != order.Hash {
@@ -798,6 +935,12 @@ func (l *Lending) ProcessRepay(header *types.Header, chain consensus.ChainContex
 	if order.Relayer.String() != lendingTrade.BorrowingRelayer.String() {
 		return nil, fmt.Errorf("ProcessRepay: invalid relayerAddress . Got: %s . Expect: %s", order.Relayer.Hex(), lendingTrade.BorrowingRelayer.Hex())
 	}
+	if chain.Config().IsTIPTomoXLendingPartialRepay(header.Number) && order.Quantity != nil && order.Quantity.Sign() > 0 && lendingTrade.LiquidationTime > header.Time.Uint64() {
+		paymentBalance := lendingstate.CalculateTotalRepayValue(header.Time.Uint64(), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+		if order.Quantity.Cmp(paymentBalance) < 0 {
+			return l.ProcessPartialRepayLendingTrade(header, lendingStateDB, statedb, tradingstateDB, lendingBook, lendingTradeId, order.Quantity)
+		}
+	}
 	return l.ProcessRepayLendingTrade(header, chain, lendingStateDB, statedb, tradingstateDB, lendingBook, lendingTradeId)
 }
 
@@ -810,7 +953,23 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 	}
 	repayAmount := lendingTrade.CollateralLockedAmount
 
-	_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingstateDB, lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	collateralPrice, err := l.liquidationCollateralPrice(header, chain, statedb, tradingstateDB, lendingTrade.CollateralToken, lendingTrade.LendingToken)
+	if err == lendingstate.ErrOraclePaused {
+		// the term is over regardless of the circuit breaker, settle at the
+		// last price accepted by the oracle
+		collateralPrice, _, _, _ = tradingstateDB.GetOraclePrice(tradingstate.GetOracleHash(lendingTrade.CollateralToken, lendingTrade.LendingToken))
+		err = nil
+	}
+	if err == nil && collateralPrice != nil && collateralPrice.Sign() > 0 {
+		penalty, err := l.liquidationPenalty(header, chain, statedb, lendingTrade.CollateralToken)
+		if err != nil {
+			return nil, err
+		}
+		if penalty.Sign() > 0 {
+			// the trade is liquidated when its auction is settled
+			return nil, l.OpenLiquidationAuction(header, lendingStateDB, statedb, tradingstateDB, lendingBook, lendingTradeId, collateralPrice, penalty, lendingstate.LiquidatedByTime)
+		}
+	}
 	if err != nil || collateralPrice == nil || collateralPrice.Sign() <= 0 {
 		// if cannot get collateralPrice, liquidate all collateral
 		log.Error("LiquidationExpiredTrade: cannot get collateralPrice", "err", err)
@@ -841,7 +1000,7 @@ func (l *Lending) LiquidationExpiredTrade(header *types.Header, chain consensus.
 		log.Debug("LiquidationTrade RemoveLiquidationTime", "err", err)
 		return nil, err
 	}
-	err = tradingstateDB.RemoveLiquidationPrice(tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken), lendingTrade.LiquidationPrice, lendingBook, lendingTradeId)
+	err = tradingstateDB.RemoveLiquidationPrice(lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, &lendingTrade), lendingTrade.LiquidationPrice, lendingBook, lendingTradeId)
 	if err != nil {
 		log.Debug("LiquidationTrade RemoveLiquidationPrice", "err", err)
 		return nil, err
@@ -878,7 +1037,7 @@ func (l *Lending) LiquidationTrade(lendingStateDB *lendingstate.LendingStateDB,
 		log.Debug("LiquidationTrade RemoveLiquidationTime", "err", err)
 		return nil, err
 	}
-	err = tradingstateDB.RemoveLiquidationPrice(tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken), lendingTrade.LiquidationPrice, lendingBook, lendingTradeId)
+	err = tradingstateDB.RemoveLiquidationPrice(lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, &lendingTrade), lendingTrade.LiquidationPrice, lendingBook, lendingTradeId)
 	if err != nil {
 		log.Debug("LiquidationTrade RemoveLiquidationPrice", "err", err)
 		return nil, err
@@ -891,6 +1050,24 @@ func (l *Lending) LiquidationTrade(lendingStateDB *lendingstate.LendingStateDB,
 	return &lendingTrade, nil
 }
 
+// liquidationPenalty returns the liquidation penalty of a collateral, or a zero
+// penalty if the collateral is liquidated by the legacy rules. A collateral
+// with a penalty is liquidated by auction, which needs its decimal.
+func (l *Lending) liquidationPenalty(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, collateralToken common.Address) (*big.Int, error) {
+	if !chain.Config().IsTIPTomoXLendingAuction(header.Number) {
+		return common.Big0, nil
+	}
+	penalty := lendingstate.GetCollateralLiquidationPenalty(statedb, collateralToken)
+	if penalty.Sign() <= 0 {
+		return common.Big0, nil
+	}
+	collateralTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, collateralToken)
+	if err != nil || collateralTokenDecimal == nil || collateralTokenDecimal.Sign() <= 0 {
+		return nil, fmt.Errorf("failed to get decimal of collateral %s: %v", collateralToken.Hex(), err)
+	}
+	return penalty, nil
+}
+
 // cancellation fee = 1/10 borrowing fee
 // deprecated after hardfork at TIPTomoXCancellationFee
 func getCancelFeeV1(collateralTokenDecimal *big.Int, collateralPrice, borrowFee *big.Int, order *lendingstate.LendingItem) *big.Int {
@@ -958,11 +1135,25 @@ func (l *Lending) GetMediumTradePriceBeforeEpoch(chain consensus.ChainContext, s
 //- Have pairs with TOMO:
 //-  lendToken/TOMO and CollateralToken/TOMO
 //-  TOMO/lendToken and TOMO/CollateralToken
+//After the oracle fork, collaterals priced by the oracle take the oracle price.
+//Its circuit breaker only pauses liquidations, see liquidationCollateralPrice
 func (l *Lending) GetCollateralPrices(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, collateralToken common.Address, lendingToken common.Address) (*big.Int, *big.Int, error) {
 	// lendTokenTOMOPrice: price of ticker lendToken/TOMO
 	// collateralTOMOPrice: price of ticker collateralToken/TOMO
 	// collateralPrice: price of ticker collateralToken/lendToken
 
+	if report := l.GetOracleReport(header, chain, statedb, tradingStateDb, collateralToken, lendingToken); report.Enabled {
+		if report.Price == nil {
+			log.Debug("GetCollateralPrices: no oracle price", "collateralToken", collateralToken.Hex(), "lendingToken", lendingToken.Hex(), "reason", report.Reason)
+			return nil, nil, lendingstate.ErrOracleNoPrice
+		}
+		lendTokenTOMOPrice, err := l.GetTOMOBasePrices(header, chain, statedb, tradingStateDb, lendingToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		return lendTokenTOMOPrice, report.Price, nil
+	}
+
 	collateralPriceFromContract, updatedBlock := lendingstate.GetCollateralPrice(statedb, collateralToken, lendingToken)
 	collateralPriceUpdatedFromContract := updatedBlock.Uint64()/chain.Config().Posv.Epoch == header.Number.Uint64()/chain.Config().Posv.Epoch
 
@@ -1093,7 +1284,7 @@ func (l *Lending) ProcessTopUpLendingTrade(lendingStateDB *lendingstate.LendingS
 		log.Debug("not enough balance deposit", "Quantity", quantity, "tokenBalance", tokenBalance)
 		return fmt.Errorf("not enough balance deposit. lendingTradeId: %v , Quantity : %v , tokenBalance : %v", lendingTradeId.Hex(), quantity, tokenBalance), true, nil
 	}
-	err := tradingStateDb.RemoveLiquidationPrice(tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken), lendingTrade.LiquidationPrice, lendingBook, lendingTrade.TradeId)
+	err := tradingStateDb.RemoveLiquidationPrice(lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, &lendingTrade), lendingTrade.LiquidationPrice, lendingBook, lendingTrade.TradeId)
 	if err != nil {
 		return err, true, nil
 	}
@@ -1105,7 +1296,7 @@ func (l *Lending) ProcessTopUpLendingTrade(lendingStateDB *lendingstate.LendingS
 	newLiquidationPrice = new(big.Int).Div(newLiquidationPrice, newLockedAmount)
 	lendingStateDB.UpdateLiquidationPrice(lendingBook, lendingTrade.TradeId, newLiquidationPrice)
 	lendingStateDB.UpdateCollateralLockedAmount(lendingBook, lendingTrade.TradeId, newLockedAmount)
-	tradingStateDb.InsertLiquidationPrice(tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken), newLiquidationPrice, lendingBook, lendingTrade.TradeId)
+	tradingStateDb.InsertLiquidationPrice(lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, &lendingTrade), newLiquidationPrice, lendingBook, lendingTrade.TradeId)
 	newLendingTrade := lendingTrade
 	newLendingTrade.LiquidationPrice = newLiquidationPrice
 	newLendingTrade.CollateralLockedAmount = newLockedAmount
@@ -1164,7 +1355,7 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 			log.Debug("ProcessRepay RemoveLiquidationTime", "err", err, "lendingHash", lendingTrade.Hash, "trade", lendingstate.ToJSON(lendingTrade))
 			return nil, err
 		}
-		err = tradingstateDB.RemoveLiquidationPrice(tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken), lendingTrade.LiquidationPrice, lendingBook, lendingTradeId)
+		err = tradingstateDB.RemoveLiquidationPrice(lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, &lendingTrade), lendingTrade.LiquidationPrice, lendingBook, lendingTradeId)
 		if err != nil {
 			log.Debug("ProcessRepay RemoveLiquidationPrice", "err", err)
 			return nil, err
@@ -1185,6 +1376,79 @@ func (l *Lending) ProcessRepayLendingTrade(header *types.Header, chain consensus
 	return &lendingTrade, nil
 }
 
+// ProcessPartialRepayLendingTrade settles repayAmount of an open lending trade.
+// The payment covers principal and interest pro rata: the trade amount is
+// reduced by the principal part and the same share of the locked collateral is
+// released to the borrower.
+func (l *Lending) ProcessPartialRepayLendingTrade(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingstateDB *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64, repayAmount *big.Int) (*lendingstate.LendingTrade, error) {
+	lendingTradeIdHash := common.Uint64ToHash(lendingTradeId)
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, lendingTradeIdHash)
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+	}
+	time := header.Time.Uint64()
+	if lendingTrade.LiquidationTime <= time {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade: lendingTrade expired. lendingTradeId: %v , liquidationTime: %v", lendingTradeId, lendingTrade.LiquidationTime)
+	}
+	paymentBalance := lendingstate.CalculateTotalRepayValue(time, lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+	if repayAmount.Sign() <= 0 || repayAmount.Cmp(paymentBalance) >= 0 {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade: invalid repay amount. Got: %v . TotalRepayValue: %v", repayAmount, paymentBalance)
+	}
+	tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb)
+	if tokenBalance.Cmp(repayAmount) < 0 {
+		return nil, fmt.Errorf("Not enough balance need : %s , have : %s ", repayAmount, tokenBalance)
+	}
+
+	// principalAmount = repayAmount * Amount / totalRepayValue
+	principalAmount := new(big.Int).Mul(repayAmount, lendingTrade.Amount)
+	principalAmount = new(big.Int).Div(principalAmount, paymentBalance)
+	// releasedAmount = CollateralLockedAmount * principalAmount / Amount
+	releasedAmount := new(big.Int).Mul(lendingTrade.CollateralLockedAmount, principalAmount)
+	releasedAmount = new(big.Int).Div(releasedAmount, lendingTrade.Amount)
+	newAmount := new(big.Int).Sub(lendingTrade.Amount, principalAmount)
+	newLockedAmount := new(big.Int).Sub(lendingTrade.CollateralLockedAmount, releasedAmount)
+	if principalAmount.Sign() <= 0 || newAmount.Sign() <= 0 || newLockedAmount.Sign() <= 0 {
+		return nil, fmt.Errorf("ProcessPartialRepayLendingTrade: repay amount is out of range. lendingTradeId: %v , repayAmount: %v", lendingTradeId, repayAmount)
+	}
+	// liquidation price is proportional to the debt per locked collateral
+	// newLiquidationPrice = LiquidationPrice * newAmount * CollateralLockedAmount / (Amount * newLockedAmount)
+	newLiquidationPrice := new(big.Int).Mul(lendingTrade.LiquidationPrice, newAmount)
+	newLiquidationPrice = new(big.Int).Mul(newLiquidationPrice, lendingTrade.CollateralLockedAmount)
+	newLiquidationPrice = new(big.Int).Div(newLiquidationPrice, new(big.Int).Mul(lendingTrade.Amount, newLockedAmount))
+	log.Debug("ProcessPartialRepay", "repayAmount", repayAmount, "principalAmount", principalAmount, "releasedAmount", releasedAmount, "newAmount", newAmount, "newLockedAmount", newLockedAmount, "newLiquidationPrice", newLiquidationPrice)
+
+	tradingOrderBook := lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, &lendingTrade)
+	if err := tradingstateDB.RemoveLiquidationPrice(tradingOrderBook, lendingTrade.LiquidationPrice, lendingBook, lendingTradeId); err != nil {
+		log.Debug("ProcessPartialRepay RemoveLiquidationPrice", "err", err)
+		return nil, err
+	}
+	lendingstate.SubTokenBalance(lendingTrade.Borrower, repayAmount, lendingTrade.LendingToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, repayAmount, lendingTrade.LendingToken, statedb)
+
+	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), releasedAmount, lendingTrade.CollateralToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Borrower, releasedAmount, lendingTrade.CollateralToken, statedb)
+
+	lendingStateDB.UpdateTradeAmount(lendingBook, lendingTradeId, newAmount)
+	lendingStateDB.UpdateCollateralLockedAmount(lendingBook, lendingTradeId, newLockedAmount)
+	lendingStateDB.UpdateLiquidationPrice(lendingBook, lendingTradeId, newLiquidationPrice)
+	tradingstateDB.InsertLiquidationPrice(tradingOrderBook, newLiquidationPrice, lendingBook, lendingTradeId)
+
+	newLendingTrade := lendingTrade
+	newLendingTrade.Amount = newAmount
+	newLendingTrade.CollateralLockedAmount = newLockedAmount
+	newLendingTrade.LiquidationPrice = newLiquidationPrice
+	newLendingTrade.Status = lendingstate.TradeStatusPartialRepaid
+	extraData, _ := json.Marshal(lendingstate.PartialRepayData{
+		RepayAmount:     repayAmount,
+		PrincipalAmount: principalAmount,
+		Profit:          new(big.Int).Sub(repayAmount, principalAmount),
+		ReleasedAmount:  releasedAmount,
+		RemainingAmount: newAmount,
+	})
+	newLendingTrade.ExtraData = string(extraData)
+	return &newLendingTrade, nil
+}
+
 func (l *Lending) ProcessRecallLendingTrade(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId common.Hash, newLiquidationPrice *big.Int) (error, bool, *lendingstate.LendingTrade) {
 	log.Debug("ProcessRecallLendingTrade", "lendingTradeId", lendingTradeId.Hex(), "lendingBook", lendingBook.Hex(), "newLiquidationPrice", newLiquidationPrice)
 	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, lendingTradeId)
@@ -1198,7 +1462,7 @@ func (l *Lending) ProcessRecallLendingTrade(lendingStateDB *lendingstate.Lending
 	newLockedAmount = new(big.Int).Div(newLockedAmount, newLiquidationPrice)
 	recallAmount := new(big.Int).Sub(lendingTrade.CollateralLockedAmount, newLockedAmount)
 	log.Debug("ProcessRecallLendingTrade", "newLockedAmount", newLockedAmount, "recallAmount", recallAmount, "oldLiquidationPrice", lendingTrade.LiquidationPrice, "newLiquidationPrice", newLiquidationPrice)
-	err := tradingStateDb.RemoveLiquidationPrice(tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken), lendingTrade.LiquidationPrice, lendingBook, lendingTrade.TradeId)
+	err := tradingStateDb.RemoveLiquidationPrice(lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, &lendingTrade), lendingTrade.LiquidationPrice, lendingBook, lendingTrade.TradeId)
 	if err != nil {
 		return err, true, nil
 	}
@@ -1207,7 +1471,7 @@ func (l *Lending) ProcessRecallLendingTrade(lendingStateDB *lendingstate.Lending
 
 	lendingStateDB.UpdateLiquidationPrice(lendingBook, lendingTrade.TradeId, newLiquidationPrice)
 	lendingStateDB.UpdateCollateralLockedAmount(lendingBook, lendingTrade.TradeId, newLockedAmount)
-	tradingStateDb.InsertLiquidationPrice(tradingstate.GetTradingOrderBookHash(lendingTrade.CollateralToken, lendingTrade.LendingToken), newLiquidationPrice, lendingBook, lendingTrade.TradeId)
+	tradingStateDb.InsertLiquidationPrice(lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, &lendingTrade), newLiquidationPrice, lendingBook, lendingTrade.TradeId)
 	newLendingTrade := lendingTrade
 	newLendingTrade.LiquidationPrice = newLiquidationPrice
 	newLendingTrade.CollateralLockedAmount = newLockedAmount
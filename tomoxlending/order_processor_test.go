@@ -1,8 +1,12 @@
 package tomoxlending
 
 import (
+	"encoding/json"
 	"github.com/tomochain/tomochain/common"
 	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
 	"github.com/tomochain/tomochain/tomox"
 	"github.com/tomochain/tomochain/tomox/tradingstate"
 	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
@@ -539,3 +543,311 @@ func TestGetLendQuantity(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessPartialRepayLendingTrade(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingState, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingState, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000b01")
+		investor        = common.HexToAddress("0x0000000000000000000000000000000000000b02")
+		lendingToken    = common.HexToAddress("0x0000000000000000000000000000000000000b03")
+		collateralToken = common.HexToAddress(common.TomoNativeAddress)
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+		term            = common.OneYear
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		tradingBook     = tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken)
+		tradeId         = uint64(1)
+		now             = uint64(1600000000)
+	)
+	// a TRC21 token has to exist to keep balances
+	statedb.SetNonce(lendingToken, 1)
+	lendingstate.AddTokenBalance(borrower, big.NewInt(1000), lendingToken, statedb)
+	lendingstate.AddTokenBalance(lockAddress, big.NewInt(2000), collateralToken, statedb)
+
+	// 10% APR, repaid immediately: total repay value is 1050 for an amount of 1000
+	trade := lendingstate.LendingTrade{
+		Borrower:               borrower,
+		Investor:               investor,
+		LendingToken:           lendingToken,
+		CollateralToken:        collateralToken,
+		Term:                   term,
+		Interest:               10 * common.BaseLendingInterest.Uint64(),
+		LiquidationPrice:       big.NewInt(400),
+		CollateralLockedAmount: big.NewInt(2000),
+		LiquidationTime:        now + term,
+		Amount:                 big.NewInt(1000),
+		TradeId:                tradeId,
+	}
+	lendingState.InsertTradingItem(lendingBook, tradeId, trade)
+	tradingState.InsertLiquidationPrice(tradingBook, trade.LiquidationPrice, lendingBook, tradeId)
+
+	l := &Lending{}
+	header := &types.Header{Number: big.NewInt(1), Time: new(big.Int).SetUint64(now)}
+	if _, err := l.ProcessPartialRepayLendingTrade(header, lendingState, statedb, tradingState, lendingBook, tradeId, big.NewInt(1050)); err == nil {
+		t.Fatal("full repay value accepted as a partial repayment")
+	}
+	newTrade, err := l.ProcessPartialRepayLendingTrade(header, lendingState, statedb, tradingState, lendingBook, tradeId, big.NewInt(525))
+	if err != nil {
+		t.Fatalf("failed to partially repay: %v", err)
+	}
+	if newTrade.Status != lendingstate.TradeStatusPartialRepaid {
+		t.Errorf("status mismatch: have %s, want %s", newTrade.Status, lendingstate.TradeStatusPartialRepaid)
+	}
+	stored := lendingState.GetLendingTrade(lendingBook, common.Uint64ToHash(tradeId))
+	if stored.Amount.Cmp(big.NewInt(500)) != 0 || stored.CollateralLockedAmount.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("trade mismatch: have amount %v locked %v, want 500 and 1000", stored.Amount, stored.CollateralLockedAmount)
+	}
+	if stored.LiquidationPrice.Cmp(newTrade.LiquidationPrice) != 0 {
+		t.Errorf("liquidation price mismatch: have %v, want %v", stored.LiquidationPrice, newTrade.LiquidationPrice)
+	}
+	tradingState.Commit()
+	if price, data := tradingState.GetHighestLiquidationPriceData(tradingBook, common.Big0); price.Cmp(newTrade.LiquidationPrice) != 0 || len(data[lendingBook]) != 1 {
+		t.Errorf("liquidation tree mismatch: have price %v with %d trades, want %v with 1", price, len(data[lendingBook]), newTrade.LiquidationPrice)
+	}
+	balances := []struct {
+		addr  common.Address
+		token common.Address
+		want  int64
+	}{
+		{borrower, lendingToken, 475},
+		{investor, lendingToken, 525},
+		{borrower, collateralToken, 1000},
+		{lockAddress, collateralToken, 1000},
+	}
+	for _, b := range balances {
+		if have := lendingstate.GetTokenBalance(b.addr, b.token, statedb); have.Cmp(big.NewInt(b.want)) != 0 {
+			t.Errorf("balance mismatch of %s in %s: have %v, want %d", b.addr.Hex(), b.token.Hex(), have, b.want)
+		}
+	}
+	var extra lendingstate.PartialRepayData
+	if err := json.Unmarshal([]byte(newTrade.ExtraData), &extra); err != nil {
+		t.Fatalf("failed to decode extra data: %v", err)
+	}
+	if extra.Profit.Cmp(big.NewInt(25)) != 0 || extra.ReleasedAmount.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("extra data mismatch: have profit %v released %v, want 25 and 1000", extra.Profit, extra.ReleasedAmount)
+	}
+	// expired trades can only be repaid in full or liquidated
+	header.Time = new(big.Int).SetUint64(now + term)
+	if _, err := l.ProcessPartialRepayLendingTrade(header, lendingState, statedb, tradingState, lendingBook, tradeId, big.NewInt(100)); err == nil {
+		t.Error("partial repayment of an expired trade accepted")
+	}
+}
+
+// Tests that trades of collaterals with a liquidation penalty are liquidated by
+// auction, whether by price or by time. Bidders raise the debt and the penalty
+// for the investor, and the borrower gets the collateral left at settlement.
+func TestLiquidationAuction(t *testing.T) {
+	var (
+		borrower    = common.HexToAddress("0x0000000000000000000000000000000000000c01")
+		investor    = common.HexToAddress("0x0000000000000000000000000000000000000c02")
+		token       = common.HexToAddress("0x0000000000000000000000000000000000000c03")
+		bidder      = common.HexToAddress("0x0000000000000000000000000000000000000c04")
+		lockAddress = common.HexToAddress(common.LendingLockAddress)
+		term        = common.OneYear
+		tradeId     = uint64(1)
+		now         = uint64(1600000000)
+		penalty     = big.NewInt(500)
+	)
+	config := *params.TestChainConfig
+	config.Posv = &params.PosvConfig{Epoch: 900}
+	chain := testChain{config: &config}
+
+	// 1 collateral = 0.35 lending token, 10% APR on an amount of 1000
+	lendingToken, collateralToken := common.HexToAddress(common.TomoNativeAddress), token
+	lendingBook := lendingstate.GetLendingOrderBookHash(lendingToken, term)
+	tradingBook := tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken)
+	newTrade := func(number *big.Int) (*Lending, *state.StateDB, *lendingstate.LendingStateDB, *tradingstate.TradingStateDB) {
+		db := rawdb.NewMemoryDatabase()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+		lendingState, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+		tradingState, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+		statedb.SetNonce(token, 1)
+		lendingstate.AddTokenBalance(lockAddress, big.NewInt(5000), collateralToken, statedb)
+		trade := lendingstate.LendingTrade{
+			Borrower:               borrower,
+			Investor:               investor,
+			LendingToken:           lendingToken,
+			CollateralToken:        collateralToken,
+			Term:                   term,
+			Interest:               10 * common.BaseLendingInterest.Uint64(),
+			LiquidationPrice:       big.NewInt(400),
+			CollateralLockedAmount: big.NewInt(5000),
+			LiquidationTime:        now + term,
+			Amount:                 big.NewInt(1000),
+			TradeId:                tradeId,
+			Hash:                   common.HexToHash("0xc1"),
+		}
+		lendingState.InsertTradingItem(lendingBook, tradeId, trade)
+		lendingState.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(trade.LiquidationTime), tradeId)
+		tradingState.InsertLiquidationPrice(tradingBook, trade.LiquidationPrice, lendingBook, tradeId)
+
+		tomoX := tomox.New(&tomox.DefaultConfig)
+		tomoX.SetTokenDecimal(collateralToken, big.NewInt(1000))
+		setTestLendingRelayer(statedb, common.Address{}, common.Address{}, common.Big0, lendingToken, term, collateralToken, big.NewInt(350), number)
+		loc := lendingstate.GetLocMappingAtKey(collateralToken.Hash(), lendingstate.CollateralMapSlot)
+		statedb.SetState(common.HexToAddress(common.LendingRegistrationSMC), state.GetLocOfStructElement(loc, lendingstate.CollateralStructSlots["liquidationPenalty"]), common.BigToHash(penalty))
+		return New(tomoX), statedb, lendingState, tradingState
+	}
+	checkOpen := func(name string, lendingState *lendingstate.LendingStateDB, tradingState *tradingstate.TradingStateDB, reason uint64, debt, penalty int64) {
+		auction := lendingState.GetLendingAuction(lendingBook, tradeId)
+		if auction == nil {
+			t.Fatalf("%s: no auction opened", name)
+		}
+		if auction.Reason != reason || auction.Debt.Int64() != debt || auction.Penalty.Int64() != penalty || auction.Collateral.Int64() != 5000 {
+			t.Errorf("%s: auction mismatch: %+v", name, auction)
+		}
+		if trade := lendingState.GetLendingTrade(lendingBook, common.Uint64ToHash(tradeId)); trade != lendingstate.EmptyLendingTrade {
+			t.Errorf("%s: auctioned trade still in the lending book", name)
+		}
+		tradingState.Commit()
+		if price, _ := tradingState.GetHighestLiquidationPriceData(tradingBook, common.Big0); price.Sign() != 0 {
+			t.Errorf("%s: auctioned trade still in the liquidation tree at price %v", name, price)
+		}
+	}
+	checkSettled := func(name string, statedb *state.StateDB, lendingState *lendingstate.LendingStateDB, trades []*lendingstate.LendingTrade, debt, penalty, raised, paid, recalled int64) {
+		if len(trades) != 1 {
+			t.Fatalf("%s: settled trades mismatch: have %d, want 1", name, len(trades))
+		}
+		if trades[0].Status != lendingstate.TradeStatusLiquidated {
+			t.Errorf("%s: status mismatch: have %s, want %s", name, trades[0].Status, lendingstate.TradeStatusLiquidated)
+		}
+		balances := []struct {
+			addr common.Address
+			want int64
+		}{
+			{investor, paid},
+			{borrower, recalled},
+			{lockAddress, 0},
+		}
+		for _, b := range balances {
+			if have := lendingstate.GetTokenBalance(b.addr, collateralToken, statedb); have.Cmp(big.NewInt(b.want)) != 0 {
+				t.Errorf("%s: balance mismatch of %s: have %v, want %d", name, b.addr.Hex(), have, b.want)
+			}
+		}
+		var extra lendingstate.LiquidationData
+		if err := json.Unmarshal([]byte(trades[0].ExtraData), &extra); err != nil {
+			t.Fatalf("%s: failed to decode extra data: %v", name, err)
+		}
+		if extra.Reason != lendingstate.LiquidatedByAuction || extra.DebtAmount.Int64() != debt || extra.PenaltyAmount.Int64() != penalty ||
+			extra.AuctionProceeds.Int64() != raised || extra.LiquidationAmount.Int64() != paid || extra.RecallAmount.Int64() != recalled {
+			t.Errorf("%s: extra data mismatch: %+v", name, extra)
+		}
+		if len(lendingState.GetLendingAuctions()) != 0 {
+			t.Errorf("%s: settled auction still listed", name)
+		}
+	}
+
+	// Liquidated by price right away: debt is 1050 and the 5% penalty 52
+	header := &types.Header{Number: new(big.Int).Set(common.TIPTomoXLendingAuctionBlock), Time: new(big.Int).SetUint64(now)}
+	l, statedb, lendingState, tradingState := newTrade(header.Number)
+	if err := l.OpenLiquidationAuction(header, lendingState, statedb, tradingState, lendingBook, tradeId, big.NewInt(350), penalty, lendingstate.LiquidatedByPrice); err != nil {
+		t.Fatalf("failed to open the auction: %v", err)
+	}
+	checkOpen("price", lendingState, tradingState, lendingstate.LiquidatedByPrice, 1050, 52)
+	if trades := l.ProcessLiquidationAuctions(header, chain, statedb, lendingState); len(trades) != 0 {
+		t.Errorf("open auction settled")
+	}
+
+	// Halfway the price is 315: 1000 collateral raise 315, then 2499 collateral
+	// raise the 787 left. The borrower gets the 1501 collateral left.
+	statedb.AddBalance(bidder, big.NewInt(2000))
+	header.Time = new(big.Int).SetUint64(now + lendingstate.LendingAuctionDuration/2)
+	bid := &lendingstate.LendingItem{UserAddress: bidder, LendingToken: lendingToken, Term: term, LendingTradeId: tradeId, Quantity: big.NewInt(1000), Type: lendingstate.AuctionBid}
+	if err := l.ProcessAuctionBid(header, chain, statedb, lendingState, bid); err != nil {
+		t.Fatalf("failed to bid: %v", err)
+	}
+	bid.Quantity = big.NewInt(5000)
+	if err := l.ProcessAuctionBid(header, chain, statedb, lendingState, bid); err != nil {
+		t.Fatalf("failed to bid: %v", err)
+	}
+	if err := l.ProcessAuctionBid(header, chain, statedb, lendingState, bid); err != lendingstate.ErrAuctionClosed {
+		t.Errorf("bid in a raised auction: have %v, want %v", err, lendingstate.ErrAuctionClosed)
+	}
+	if have := statedb.GetBalance(investor); have.Cmp(big.NewInt(1102)) != 0 {
+		t.Errorf("investor proceeds mismatch: have %v, want 1102", have)
+	}
+	if have := lendingstate.GetTokenBalance(bidder, collateralToken, statedb); have.Cmp(big.NewInt(3499)) != 0 {
+		t.Errorf("bidder collateral mismatch: have %v, want 3499", have)
+	}
+	checkSettled("price", statedb, lendingState, l.ProcessLiquidationAuctions(header, chain, statedb, lendingState), 1050, 52, 1102, 0, 1501)
+
+	// Liquidated by time without bids: debt is 1100 and the 5% penalty 55,
+	// paid at the floor price of 280: 4125 collateral
+	header = &types.Header{Number: new(big.Int).Set(common.TIPTomoXLendingAuctionBlock), Time: new(big.Int).SetUint64(now + term + 1)}
+	l, statedb, lendingState, tradingState = newTrade(header.Number)
+	trade, err := l.ProcessRepayLendingTrade(header, chain, lendingState, statedb, tradingState, lendingBook, tradeId)
+	if err != nil || trade != nil {
+		t.Fatalf("failed to liquidate by time: trade %v, err %v", trade, err)
+	}
+	checkOpen("time", lendingState, tradingState, lendingstate.LiquidatedByTime, 1100, 55)
+	header.Time = new(big.Int).SetUint64(now + term + 1 + lendingstate.LendingAuctionDuration)
+	checkSettled("time", statedb, lendingState, l.ProcessLiquidationAuctions(header, chain, statedb, lendingState), 1100, 55, 0, 4125, 875)
+}
+
+func TestPreventSelfTrade(t *testing.T) {
+	user := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	lendingBook := lendingstate.GetLendingOrderBookHash(common.HexToAddress("0x1000000000000000000000000000000000000002"), common.OneYear)
+	interest := big.NewInt(10)
+	makerId := common.BigToHash(big.NewInt(1))
+
+	tests := []struct {
+		mode         string
+		quantity     int64
+		wantQuantity int64 // quantity of the taker item still to trade
+		wantMaker    int64 // amount of the maker item left in the order book
+		cancelTaker  bool
+		wantCancels  int
+	}{
+		{types.SelfTradeCancelNewest, 60, 0, 100, true, 1},
+		{types.SelfTradeCancelOldest, 60, 60, 0, false, 1},
+		{types.SelfTradeCancelBoth, 60, 0, 0, true, 2},
+		{types.SelfTradeDecrementAndCancel, 60, 0, 40, true, 1},
+		{types.SelfTradeDecrementAndCancel, 150, 50, 0, false, 1},
+	}
+	for _, tt := range tests {
+		lendingStateDb, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(rawdb.NewMemoryDatabase()))
+		maker := lendingstate.LendingItem{
+			UserAddress: user,
+			Side:        lendingstate.Investing,
+			Interest:    interest,
+			Quantity:    big.NewInt(100),
+			LendingId:   1,
+		}
+		lendingStateDb.InsertLendingItem(lendingBook, makerId, maker)
+		taker := &lendingstate.LendingItem{
+			UserAddress: user,
+			Side:        lendingstate.Borrowing,
+			Interest:    interest,
+			Quantity:    big.NewInt(tt.quantity),
+		}
+		taker.SetSelfTradePrevention(tt.mode)
+		if err := taker.VerifyLendingSelfTradePrevention(); err != nil {
+			t.Fatalf("%s: failed to verify self-trade prevention: %v", tt.mode, err)
+		}
+		quantity, cancelTaker, cancels, err := preventSelfTrade(lendingStateDb, lendingBook, lendingstate.Investing, interest, makerId, big.NewInt(100), taker.Quantity, taker, &maker, taker.SelfTradePrevention())
+		if err != nil {
+			t.Fatalf("%s %d: failed to prevent self-trade: %v", tt.mode, tt.quantity, err)
+		}
+		if quantity.Int64() != tt.wantQuantity || cancelTaker != tt.cancelTaker || len(cancels) != tt.wantCancels {
+			t.Errorf("%s %d: result mismatch: quantity %v, cancel taker %v, cancels %d", tt.mode, tt.quantity, quantity, cancelTaker, len(cancels))
+		}
+		for _, cancel := range cancels {
+			if cancel.Status != lendingstate.LendingStatusCancelled {
+				t.Errorf("%s %d: cancellation status mismatch: have %s, want %s", tt.mode, tt.quantity, cancel.Status, lendingstate.LendingStatusCancelled)
+			}
+		}
+		_, amount, _ := lendingStateDb.GetBestLendingIdAndAmount(lendingBook, interest, lendingstate.Investing)
+		if amount.Int64() != tt.wantMaker {
+			t.Errorf("%s %d: maker amount mismatch: have %v, want %d", tt.mode, tt.quantity, amount, tt.wantMaker)
+		}
+	}
+	invalid := &lendingstate.LendingItem{}
+	invalid.SetSelfTradePrevention("XX")
+	if err := invalid.VerifyLendingSelfTradePrevention(); err == nil {
+		t.Errorf("unsupported self-trade prevention mode accepted")
+	}
+}
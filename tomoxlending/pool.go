@@ -0,0 +1,181 @@
+package tomoxlending
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// LendingPoolInfo describes the variable-rate pool of a lending token
+type LendingPoolInfo struct {
+	LendingToken  common.Address `json:"lendingToken"`
+	TotalDeposits *big.Int       `json:"totalDeposits"`
+	TotalBorrows  *big.Int       `json:"totalBorrows"`
+	Liquidity     *big.Int       `json:"liquidity"`
+	Utilization   *big.Int       `json:"utilization"` // in basis points
+	BorrowRate    *big.Int       `json:"borrowRate"`  // annual, in basis points
+	SupplyRate    *big.Int       `json:"supplyRate"`  // annual, in basis points
+	BorrowAPY     float64        `json:"borrowAPY"`   // in percent
+	SupplyAPY     float64        `json:"supplyAPY"`   // in percent
+}
+
+// LendingPoolPosition describes the position of a user in a variable-rate pool
+type LendingPoolPosition struct {
+	LendingToken     common.Address `json:"lendingToken"`
+	Deposit          *big.Int       `json:"deposit"`
+	Debt             *big.Int       `json:"debt"`
+	CollateralToken  common.Address `json:"collateralToken"`
+	CollateralAmount *big.Int       `json:"collateralAmount"`
+}
+
+// poolBlocksPerYear returns the number of blocks interest is accrued for in a year
+func poolBlocksPerYear(chain consensus.ChainContext) uint64 {
+	if chain.Config().Posv == nil || chain.Config().Posv.Period == 0 {
+		return 0
+	}
+	return common.OneYear / chain.Config().Posv.Period
+}
+
+// accruedLendingPool returns the pool of lendingToken with the interest
+// accrued up to header. The state isn't changed.
+func accruedLendingPool(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, lendingToken common.Address) (*lendingstate.LendingPool, lendingstate.PoolConfig) {
+	config := lendingstate.GetLendingPoolConfig(statedb, lendingToken)
+	pool := lendingStateDB.GetLendingPool(lendingToken)
+	pool.Accrue(config, header.Number.Uint64(), poolBlocksPerYear(chain))
+	return pool, config
+}
+
+// ProcessPoolItem applies a deposit, withdrawal, borrow or repayment to the
+// variable-rate pool of the lending token of the item
+func (l *Lending) ProcessPoolItem(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, order *lendingstate.LendingItem) error {
+	if !chain.Config().IsTIPTomoXLendingPool(header.Number) {
+		return fmt.Errorf("ProcessPoolItem: lending pools are not enabled. Type: %s", order.Type)
+	}
+	pool, config := accruedLendingPool(header, chain, statedb, lendingStateDB, order.LendingToken)
+	if !config.Enabled() {
+		return lendingstate.ErrPoolNotEnabled
+	}
+	var err error
+	switch order.Type {
+	case lendingstate.PoolDeposit:
+		err = lendingstate.DepositToPool(statedb, lendingStateDB, pool, order.UserAddress, order.Quantity)
+	case lendingstate.PoolWithdraw:
+		_, err = lendingstate.WithdrawFromPool(statedb, lendingStateDB, pool, order.UserAddress, order.Quantity)
+	case lendingstate.PoolBorrow:
+		var collateralTokenDecimal, collateralPrice *big.Int
+		collateralTokenDecimal, err = l.tomox.GetTokenDecimal(chain, statedb, order.CollateralToken)
+		if err != nil || collateralTokenDecimal == nil || collateralTokenDecimal.Sign() <= 0 {
+			return fmt.Errorf("ProcessPoolItem: failed to get collateral decimal. CollateralToken: %s. Err: %v", order.CollateralToken.Hex(), err)
+		}
+		_, collateralPrice, err = l.GetCollateralPrices(header, chain, statedb, tradingStateDb, order.CollateralToken, order.LendingToken)
+		if err != nil || collateralPrice == nil || collateralPrice.Sign() <= 0 {
+			return lendingstate.ErrInvalidCollateralPrice
+		}
+		depositRate, _, _ := lendingstate.GetCollateralDetail(statedb, order.CollateralToken)
+		err = lendingstate.BorrowFromPool(statedb, lendingStateDB, pool, order.UserAddress, order.Quantity, order.CollateralToken, collateralTokenDecimal, collateralPrice, depositRate)
+	case lendingstate.PoolRepay:
+		_, err = lendingstate.RepayToPool(statedb, lendingStateDB, pool, order.UserAddress, order.Quantity)
+	default:
+		err = fmt.Errorf("ProcessPoolItem: invalid type %s", order.Type)
+	}
+	if err != nil {
+		return err
+	}
+	log.Debug("ProcessPoolItem", "type", order.Type, "user", order.UserAddress.Hex(), "lendingToken", order.LendingToken.Hex(), "quantity", order.Quantity, "deposits", pool.TotalDeposits, "borrows", pool.TotalBorrows)
+	return nil
+}
+
+// ProcessPoolLiquidation liquidates the pool borrows whose collateral is worth
+// less than the liquidation rate of their debt at the collateral price. With a
+// liquidation penalty only the debt and the penalty are seized, the whole
+// collateral otherwise. Pairs whose price is unavailable or paused are skipped.
+func (l *Lending) ProcessPoolLiquidation(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB) error {
+	for _, lendingToken := range lendingstate.GetLendingPoolTokens(statedb) {
+		pool, _ := accruedLendingPool(header, chain, statedb, lendingStateDB, lendingToken)
+		if pool.TotalBorrowShares.Sign() == 0 {
+			continue
+		}
+		users, positions := lendingStateDB.GetPoolPositions(lendingToken)
+		for _, user := range users {
+			position := positions[user]
+			if position.BorrowShares.Sign() == 0 {
+				continue
+			}
+			collateralToken := position.CollateralToken
+			collateralPrice, err := l.liquidationCollateralPrice(header, chain, statedb, tradingStateDb, collateralToken, lendingToken)
+			if err != nil || collateralPrice == nil || collateralPrice.Sign() <= 0 {
+				log.Debug("ProcessPoolLiquidation: no collateral price", "collateralToken", collateralToken.Hex(), "lendingToken", lendingToken.Hex(), "err", err)
+				continue
+			}
+			collateralTokenDecimal, err := l.tomox.GetTokenDecimal(chain, statedb, collateralToken)
+			if err != nil || collateralTokenDecimal == nil || collateralTokenDecimal.Sign() <= 0 {
+				log.Debug("ProcessPoolLiquidation: no collateral decimal", "collateralToken", collateralToken.Hex(), "err", err)
+				continue
+			}
+			_, liquidationRate, _ := lendingstate.GetCollateralDetail(statedb, collateralToken)
+			debt := pool.BorrowValue(position.BorrowShares)
+			if lendingstate.PoolCollateralAmount(debt, collateralTokenDecimal, collateralPrice, liquidationRate).Cmp(position.CollateralAmount) <= 0 {
+				continue
+			}
+			penalty, err := l.liquidationPenalty(header, chain, statedb, collateralToken)
+			if err != nil {
+				log.Debug("ProcessPoolLiquidation: no liquidation penalty", "collateralToken", collateralToken.Hex(), "err", err)
+				continue
+			}
+			var liquidationAmount *big.Int
+			if penalty.Sign() > 0 {
+				penaltyAmount := new(big.Int).Mul(debt, penalty)
+				penaltyAmount = new(big.Int).Div(penaltyAmount, lendingstate.BaseLiquidationPenalty)
+				// the collateral worth the debt and the penalty
+				liquidationAmount = lendingstate.PoolCollateralAmount(new(big.Int).Add(debt, penaltyAmount), collateralTokenDecimal, collateralPrice, lendingstate.BaseCollateralRate)
+			}
+			debt, seized, err := lendingstate.LiquidatePoolPosition(statedb, lendingStateDB, pool, user, liquidationAmount)
+			if err != nil {
+				return fmt.Errorf("ProcessPoolLiquidation: failed to liquidate %s in the pool of %s: %v", user.Hex(), lendingToken.Hex(), err)
+			}
+			log.Debug("ProcessPoolLiquidation", "user", user.Hex(), "lendingToken", lendingToken.Hex(), "collateralToken", collateralToken.Hex(), "debt", debt, "seized", seized, "price", collateralPrice)
+		}
+	}
+	return nil
+}
+
+// GetLendingPoolInfo returns the variable-rate pool of lendingToken with the
+// interest accrued up to header
+func (l *Lending) GetLendingPoolInfo(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, lendingToken common.Address) *LendingPoolInfo {
+	pool, config := accruedLendingPool(header, chain, statedb, lendingStateDB, lendingToken)
+	utilization := pool.Utilization()
+	borrowRate, supplyRate := config.BorrowRate(utilization), config.SupplyRate(utilization)
+	blocksPerYear := poolBlocksPerYear(chain)
+	return &LendingPoolInfo{
+		LendingToken:  lendingToken,
+		TotalDeposits: pool.TotalDeposits,
+		TotalBorrows:  pool.TotalBorrows,
+		Liquidity:     pool.Liquidity(),
+		Utilization:   utilization,
+		BorrowRate:    borrowRate,
+		SupplyRate:    supplyRate,
+		BorrowAPY:     lendingstate.PoolAPY(borrowRate, blocksPerYear),
+		SupplyAPY:     lendingstate.PoolAPY(supplyRate, blocksPerYear),
+	}
+}
+
+// GetLendingPoolPosition returns the position of user in the variable-rate
+// pool of lendingToken with the interest accrued up to header
+func (l *Lending) GetLendingPoolPosition(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, lendingToken common.Address, user common.Address) *LendingPoolPosition {
+	pool, _ := accruedLendingPool(header, chain, statedb, lendingStateDB, lendingToken)
+	position := lendingStateDB.GetPoolPosition(lendingToken, user)
+	return &LendingPoolPosition{
+		LendingToken:     lendingToken,
+		Deposit:          pool.DepositValue(position.DepositShares),
+		Debt:             pool.BorrowValue(position.BorrowShares),
+		CollateralToken:  position.CollateralToken,
+		CollateralAmount: position.CollateralAmount,
+	}
+}
@@ -0,0 +1,141 @@
+package tomoxlending
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// setTestLendingPool enables the variable-rate pool of lendingToken
+func setTestLendingPool(statedb *state.StateDB, lendingToken common.Address, config lendingstate.PoolConfig) {
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	loc := lendingstate.GetLocMappingAtKey(lendingToken.Hash(), lendingstate.LendingPoolMapSlot)
+	for name, value := range map[string]*big.Int{"baseRate": config.BaseRate, "slope1": config.Slope1, "slope2": config.Slope2, "optimalUtilization": config.OptimalUtilization} {
+		statedb.SetState(contract, state.GetLocOfStructElement(loc, lendingstate.LendingPoolStructSlots[name]), common.BigToHash(value))
+	}
+	bases := state.GetLocSimpleVariable(lendingstate.SupportedBaseSlot)
+	statedb.SetState(contract, bases, common.BigToHash(common.Big1))
+	statedb.SetState(contract, state.GetLocDynamicArrAtElement(bases, 0, 1), lendingToken.Hash())
+}
+
+// Tests that pool deposits, borrows and withdrawals are applied to the pool in
+// the lending state, and that undercollateralized borrows are liquidated.
+func TestApplyPoolItems(t *testing.T) {
+	defer func(block *big.Int) { common.TIPTomoXLendingPoolBlock = block }(common.TIPTomoXLendingPoolBlock)
+	common.TIPTomoXLendingPoolBlock = big.NewInt(0)
+
+	var (
+		investorKey, _  = crypto.GenerateKey()
+		borrowerKey, _  = crypto.GenerateKey()
+		investor        = crypto.PubkeyToAddress(investorKey.PublicKey)
+		borrower        = crypto.PubkeyToAddress(borrowerKey.PublicKey)
+		relayer         = common.HexToAddress("0x0000000000000000000000000000000000000d21")
+		owner           = common.HexToAddress("0x0000000000000000000000000000000000000d22")
+		coinbase        = common.HexToAddress("0x0000000000000000000000000000000000000d23")
+		lendingToken    = common.HexToAddress(common.TomoNativeAddress)
+		collateralToken = common.HexToAddress("0x0000000000000000000000000000000000000e41")
+		poolAddress     = common.HexToAddress(common.LendingPoolAddress)
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+		term            = common.OneYear
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		header          = &types.Header{Number: big.NewInt(1), Time: big.NewInt(1000)}
+		config          = *params.TestChainConfig
+	)
+	config.Posv = &params.PosvConfig{Epoch: 900}
+	chain := testChain{config: &config}
+	units := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), common.BasePrice) }
+
+	tomoX := tomox.New(&tomox.DefaultConfig)
+	tomoX.SetTokenDecimal(collateralToken, common.BasePrice)
+	l := New(tomoX)
+
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	deposit := new(big.Int).Mul(new(big.Int).Add(common.RelayerLockedFund, big.NewInt(10)), common.BasePrice)
+	setTestLendingRelayer(statedb, relayer, owner, deposit, lendingToken, term, collateralToken, common.BasePrice, header.Number)
+	setTestLendingPool(statedb, lendingToken, lendingstate.PoolConfig{
+		BaseRate:           big.NewInt(200),
+		Slope1:             big.NewInt(800),
+		Slope2:             big.NewInt(10000),
+		OptimalUtilization: big.NewInt(8000),
+	})
+	statedb.SetNonce(collateralToken, 1)
+	statedb.SetBalance(investor, units(10))
+	lendingstate.SetTokenBalance(borrower, units(10), collateralToken, statedb)
+
+	newItem := func(key *ecdsa.PrivateKey, nonce uint64, quantity *big.Int, itemType string, collateral common.Address) *lendingstate.LendingItem {
+		tx := types.NewLendingTransaction(nonce, quantity, 0, 0, relayer, crypto.PubkeyToAddress(key.PublicKey), lendingToken, collateral,
+			false, lendingstate.LendingStatusNew, "", itemType, common.Hash{}, 0, 0, "")
+		signer := types.MakeLendingSigner(chain.Config(), header.Number)
+		signed, err := types.LendingSignTx(tx, signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign item: %v", err)
+		}
+		return LendingItemFromTx(signed, signer)
+	}
+	apply := func(name string, item *lendingstate.LendingItem, rejected bool) {
+		_, rejects, err := l.ApplyOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDB, lendingBook, item)
+		if err != nil {
+			t.Fatalf("%s: failed to apply: %v", name, err)
+		}
+		if have := len(rejects) != 0; have != rejected {
+			t.Fatalf("%s: rejection mismatch: have %v, want %v", name, have, rejected)
+		}
+	}
+	checkBalance := func(name string, addr, token common.Address, want *big.Int) {
+		if have := lendingstate.GetTokenBalance(addr, token, statedb); have.Cmp(want) != 0 {
+			t.Errorf("%s: balance mismatch of %s: have %v, want %v", name, addr.Hex(), have, want)
+		}
+	}
+
+	apply("deposit", newItem(investorKey, 0, units(10), lendingstate.PoolDeposit, common.Address{}), false)
+	checkBalance("deposit", poolAddress, lendingToken, units(10))
+	if have := lendingStateDB.GetLendingPool(lendingToken).TotalDeposits; have.Cmp(units(10)) != 0 {
+		t.Errorf("deposits mismatch: have %v, want %v", have, units(10))
+	}
+
+	// collateral = debt at price 1 * 150%
+	apply("borrow", newItem(borrowerKey, 0, units(4), lendingstate.PoolBorrow, collateralToken), false)
+	checkBalance("borrow", borrower, lendingToken, units(4))
+	checkBalance("borrow", lockAddress, collateralToken, units(6))
+	if position := lendingStateDB.GetPoolPosition(lendingToken, borrower); position.CollateralAmount.Cmp(units(6)) != 0 {
+		t.Errorf("locked collateral mismatch: have %v, want %v", position.CollateralAmount, units(6))
+	}
+
+	// only the liquidity left by the borrow can be withdrawn
+	apply("withdraw all", newItem(investorKey, 1, units(10), lendingstate.PoolWithdraw, common.Address{}), true)
+	apply("withdraw", newItem(investorKey, 2, units(5), lendingstate.PoolWithdraw, common.Address{}), false)
+	checkBalance("withdraw", investor, lendingToken, units(5))
+	pool := lendingStateDB.GetLendingPool(lendingToken)
+	if pool.TotalDeposits.Cmp(units(5)) != 0 || pool.TotalBorrows.Cmp(units(4)) != 0 {
+		t.Errorf("pool mismatch: have deposits %v borrows %v, want %v %v", pool.TotalDeposits, pool.TotalBorrows, units(5), units(4))
+	}
+
+	// at a price of 0.5 the collateral covers less than 110% of the debt
+	setTestLendingRelayer(statedb, relayer, owner, deposit, lendingToken, term, collateralToken, new(big.Int).Div(common.BasePrice, big.NewInt(2)), header.Number)
+	if err := l.ProcessPoolLiquidation(header, chain, statedb, lendingStateDB, tradingStateDB); err != nil {
+		t.Fatalf("failed to liquidate: %v", err)
+	}
+	checkBalance("liquidation", investor, collateralToken, units(6))
+	checkBalance("liquidation", lockAddress, collateralToken, common.Big0)
+	if position := lendingStateDB.GetPoolPosition(lendingToken, borrower); position.BorrowShares.Sign() != 0 || position.CollateralAmount.Sign() != 0 {
+		t.Errorf("position not liquidated: %+v", position)
+	}
+	pool = lendingStateDB.GetLendingPool(lendingToken)
+	if pool.TotalBorrows.Sign() != 0 || pool.TotalDeposits.Cmp(units(1)) != 0 {
+		t.Errorf("pool mismatch after liquidation: have deposits %v borrows %v, want %v 0", pool.TotalDeposits, pool.TotalBorrows, units(1))
+	}
+}
@@ -0,0 +1,251 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/log"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// ProcessRollover processes a rollover item: the borrower of the trade proposes
+// a new term and interest, the investor accepts or declines the proposal. A
+// declined rollover is matched against the book of the new term. If the book
+// can't refinance the trade, the item is rejected and the proposal is kept to
+// be refinanced when the trade matures.
+func (l *Lending) ProcessRollover(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, order *lendingstate.LendingItem) ([]*lendingstate.LendingTrade, []*lendingstate.LendingItem, error) {
+	if !chain.Config().IsTIPTomoXLendingRollover(header.Number) {
+		return nil, nil, fmt.Errorf("ProcessRollover: rollover is not enabled")
+	}
+	data, err := lendingstate.DecodeRolloverData(order.ExtraData)
+	if err != nil {
+		return nil, nil, err
+	}
+	lendingBook := lendingstate.GetLendingOrderBookHash(order.LendingToken, order.Term)
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(order.LendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, nil, fmt.Errorf("ProcessRollover for emptyLendingTrade is not allowed. lendingTradeId: %v", order.LendingTradeId)
+	}
+	if order.Side == lendingstate.Borrowing {
+		if order.UserAddress != lendingTrade.Borrower || data.Decline {
+			return nil, nil, fmt.Errorf("ProcessRollover: invalid borrower %s", order.UserAddress.Hex())
+		}
+		lendingStateDB.SetRolloverProposal(lendingBook, order.LendingTradeId, &lendingstate.RolloverProposal{
+			Term:     data.Term,
+			Interest: order.Interest,
+			Relayer:  order.Relayer,
+		})
+		return nil, nil, nil
+	}
+	if order.UserAddress != lendingTrade.Investor {
+		return nil, nil, fmt.Errorf("ProcessRollover: invalid investor %s", order.UserAddress.Hex())
+	}
+	proposal := lendingStateDB.GetRolloverProposal(lendingBook, order.LendingTradeId)
+	if proposal == nil {
+		return nil, nil, lendingstate.ErrRolloverNotProposed
+	}
+	if data.Decline {
+		trades, rejects, err := l.RefinanceLendingTrade(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingBook, order.LendingTradeId, proposal)
+		if err != nil {
+			return nil, nil, err
+		}
+		lendingStateDB.DeleteRolloverProposal(lendingBook, order.LendingTradeId)
+		return trades, rejects, nil
+	}
+	if data.Term != proposal.Term || order.Interest.Cmp(proposal.Interest) != 0 {
+		return nil, nil, lendingstate.ErrRolloverMismatch
+	}
+	lendingStateDB.DeleteRolloverProposal(lendingBook, order.LendingTradeId)
+	newLendingTrade, err := l.ExtendLendingTrade(header, lendingStateDB, statedb, tradingStateDb, lendingBook, order.LendingTradeId, proposal.Term, proposal.Interest.Uint64())
+	if err != nil {
+		return nil, nil, err
+	}
+	return []*lendingstate.LendingTrade{newLendingTrade}, nil, nil
+}
+
+// ExtendLendingTrade rolls a lending trade over to a new term and interest. The
+// borrower pays the interest accrued so far to the investor, then the trade
+// moves to the book of the new term with its amount and collateral unchanged.
+func (l *Lending) ExtendLendingTrade(header *types.Header, lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64, term uint64, interest uint64) (*lendingstate.LendingTrade, error) {
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, fmt.Errorf("ExtendLendingTrade for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+	}
+	time := header.Time.Uint64()
+	paymentBalance := lendingstate.CalculateTotalRepayValue(time, lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+	interestPaid := new(big.Int).Sub(paymentBalance, lendingTrade.Amount)
+	if tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb); tokenBalance.Cmp(interestPaid) < 0 {
+		return nil, fmt.Errorf("Not enough balance need : %s , have : %s ", interestPaid, tokenBalance)
+	}
+	lendingstate.SubTokenBalance(lendingTrade.Borrower, interestPaid, lendingTrade.LendingToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, interestPaid, lendingTrade.LendingToken, statedb)
+
+	if err := l.removeLendingTrade(lendingStateDB, statedb, tradingStateDb, lendingBook, &lendingTrade); err != nil {
+		return nil, err
+	}
+
+	newLendingBook := lendingstate.GetLendingOrderBookHash(lendingTrade.LendingToken, term)
+	newLendingTrade := lendingTrade
+	newLendingTrade.TradeId = lendingStateDB.GetTradeNonce(newLendingBook) + 1
+	newLendingTrade.Term = term
+	newLendingTrade.Interest = interest
+	newLendingTrade.LiquidationTime = time + term
+	newLendingTrade.Status = lendingstate.TradeStatusOpen
+	extraData, _ := json.Marshal(lendingstate.RolloverTradeData{
+		PreviousTerm:    lendingTrade.Term,
+		PreviousTradeId: lendingTradeId,
+		InterestPaid:    interestPaid,
+	})
+	newLendingTrade.ExtraData = string(extraData)
+
+	log.Debug("ExtendLendingTrade", "lendingBook", newLendingBook.Hex(), "tradeId", newLendingTrade.TradeId, "liquidationTime", newLendingTrade.LiquidationTime, "interestPaid", interestPaid)
+	lendingStateDB.InsertTradingItem(newLendingBook, newLendingTrade.TradeId, newLendingTrade)
+	lendingStateDB.InsertLiquidationTime(newLendingBook, new(big.Int).SetUint64(newLendingTrade.LiquidationTime), newLendingTrade.TradeId)
+	lendingStateDB.SetTradeNonce(newLendingBook, newLendingTrade.TradeId)
+	// the trade stays in the margin account of its borrower
+	if owner := lendingStateDB.GetMarginTradeOwner(lendingBook, lendingTradeId); owner != (common.Address{}) {
+		lendingStateDB.AddMarginTrade(owner, newLendingBook, newLendingTrade.TradeId)
+	}
+	liquidationBook := lendingstate.GetLiquidationPriceBook(lendingStateDB, newLendingBook, &newLendingTrade)
+	tradingStateDb.InsertLiquidationPrice(liquidationBook, newLendingTrade.LiquidationPrice, newLendingBook, newLendingTrade.TradeId)
+	return &newLendingTrade, nil
+}
+
+// RefinanceLendingTrade matches a borrowing of the debt of a lending trade, at
+// most at the proposed interest, against the investing side of the book of the
+// proposed term. The trade is repaid from the new borrowing if it is fully
+// filled, otherwise nothing changes. It returns the new trades, the closed
+// trade and the rejected makers.
+func (l *Lending) RefinanceLendingTrade(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64, proposal *lendingstate.RolloverProposal) (trades []*lendingstate.LendingTrade, rejects []*lendingstate.LendingItem, err error) {
+	lendingTrade := lendingStateDB.GetLendingTrade(lendingBook, common.Uint64ToHash(lendingTradeId))
+	if lendingTrade == lendingstate.EmptyLendingTrade {
+		return nil, nil, fmt.Errorf("RefinanceLendingTrade for emptyLendingTrade is not allowed. lendingTradeId: %v", lendingTradeId)
+	}
+	lendingSnap := lendingStateDB.Snapshot()
+	tradingSnap := tradingStateDb.Snapshot()
+	dbSnap := statedb.Snapshot()
+	defer func() {
+		if err != nil {
+			lendingStateDB.RevertToSnapshot(lendingSnap)
+			tradingStateDb.RevertToSnapshot(tradingSnap)
+			statedb.RevertToSnapshot(dbSnap)
+		}
+	}()
+
+	// borrow enough to pay the debt after the borrowing fee
+	debt := lendingstate.CalculateTotalRepayValue(header.Time.Uint64(), lendingTrade.LiquidationTime, lendingTrade.Term, lendingTrade.Interest, lendingTrade.Amount)
+	netRate := new(big.Int).Sub(common.TomoXBaseFee, lendingstate.GetFee(statedb, proposal.Relayer))
+	if netRate.Sign() <= 0 {
+		return nil, nil, fmt.Errorf("invalid borrowing fee of relayer %s", proposal.Relayer.Hex())
+	}
+	quantity := new(big.Int).Mul(debt, common.TomoXBaseFee)
+	quantity.Add(quantity, new(big.Int).Sub(netRate, common.Big1))
+	quantity.Div(quantity, netRate)
+
+	// the collateral of the trade backs the new borrowing
+	lendingstate.SubTokenBalance(common.HexToAddress(common.LendingLockAddress), lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Borrower, lendingTrade.CollateralLockedAmount, lendingTrade.CollateralToken, statedb)
+
+	order := &lendingstate.LendingItem{
+		Quantity:        quantity,
+		Interest:        proposal.Interest,
+		Side:            lendingstate.Borrowing,
+		Type:            lendingstate.Limit,
+		LendingToken:    lendingTrade.LendingToken,
+		CollateralToken: lendingTrade.CollateralToken,
+		AutoTopUp:       lendingTrade.AutoTopUp,
+		FilledAmount:    new(big.Int),
+		Status:          lendingstate.LendingStatusNew,
+		Relayer:         proposal.Relayer,
+		Term:            proposal.Term,
+		UserAddress:     lendingTrade.Borrower,
+		Hash:            lendingstate.GetRefinanceItemHash(lendingBook, lendingTradeId),
+	}
+	newLendingBook := lendingstate.GetLendingOrderBookHash(lendingTrade.LendingToken, proposal.Term)
+	quantityToTrade := quantity
+	minInterest, _ := lendingStateDB.GetBestInvestingRate(newLendingBook)
+	for quantityToTrade.Sign() > 0 && proposal.Interest.Cmp(minInterest) >= 0 && minInterest.Sign() > 0 {
+		var (
+			newTrades  []*lendingstate.LendingTrade
+			newRejects []*lendingstate.LendingItem
+		)
+		quantityToTrade, newTrades, newRejects, err = l.processOrderList(header, coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingstate.Investing, newLendingBook, minInterest, quantityToTrade, order)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, reject := range newRejects {
+			if reject == order {
+				return nil, nil, fmt.Errorf("refinancing borrowing rejected")
+			}
+		}
+		trades = append(trades, newTrades...)
+		rejects = append(rejects, newRejects...)
+		minInterest, _ = lendingStateDB.GetBestInvestingRate(newLendingBook)
+	}
+	if quantityToTrade.Sign() > 0 {
+		return nil, nil, lendingstate.ErrRolloverNotFilled
+	}
+
+	if tokenBalance := lendingstate.GetTokenBalance(lendingTrade.Borrower, lendingTrade.LendingToken, statedb); tokenBalance.Cmp(debt) < 0 {
+		return nil, nil, fmt.Errorf("Not enough balance need : %s , have : %s ", debt, tokenBalance)
+	}
+	lendingstate.SubTokenBalance(lendingTrade.Borrower, debt, lendingTrade.LendingToken, statedb)
+	lendingstate.AddTokenBalance(lendingTrade.Investor, debt, lendingTrade.LendingToken, statedb)
+	if err = l.removeLendingTrade(lendingStateDB, statedb, tradingStateDb, lendingBook, &lendingTrade); err != nil {
+		return nil, nil, err
+	}
+	lendingTrade.Status = lendingstate.TradeStatusClosed
+	extraData, _ := json.Marshal(struct {
+		Profit *big.Int
+	}{
+		Profit: new(big.Int).Sub(debt, lendingTrade.Amount),
+	})
+	lendingTrade.ExtraData = string(extraData)
+	for _, trade := range trades {
+		extraData, _ := json.Marshal(lendingstate.RolloverTradeData{
+			PreviousTerm:    lendingTrade.Term,
+			PreviousTradeId: lendingTradeId,
+			Refinanced:      true,
+		})
+		trade.ExtraData = string(extraData)
+		lendingStateDB.InsertTradingItem(newLendingBook, trade.TradeId, *trade)
+	}
+	log.Debug("RefinanceLendingTrade", "lendingBook", newLendingBook.Hex(), "quantity", quantity, "trades", len(trades), "debt", debt)
+	return append(trades, &lendingTrade), rejects, nil
+}
+
+// RefinanceMaturedTrade refinances a matured trade whose rollover the investor
+// didn't answer. The proposal is dropped either way, it returns nil if the
+// trade couldn't be refinanced so that it is repaid or liquidated as usual.
+func (l *Lending) RefinanceMaturedTrade(header *types.Header, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTradeId uint64) []*lendingstate.LendingTrade {
+	proposal := lendingStateDB.GetRolloverProposal(lendingBook, lendingTradeId)
+	if proposal == nil {
+		return nil
+	}
+	lendingStateDB.DeleteRolloverProposal(lendingBook, lendingTradeId)
+	trades, rejects, err := l.RefinanceLendingTrade(header, header.Coinbase, chain, statedb, lendingStateDB, tradingStateDb, lendingBook, lendingTradeId, proposal)
+	if err != nil {
+		log.Debug("Can not refinance matured lending trade", "lendingBook", lendingBook.Hex(), "lendingTradeId", lendingTradeId, "err", err)
+		return nil
+	}
+	log.Debug("RefinanceMaturedTrade", "lendingBook", lendingBook.Hex(), "lendingTradeId", lendingTradeId, "trades", len(trades), "rejects", len(rejects))
+	return trades
+}
+
+// removeLendingTrade takes a trade out of its book and the liquidation trees
+func (l *Lending) removeLendingTrade(lendingStateDB *lendingstate.LendingStateDB, statedb *state.StateDB, tradingStateDb *tradingstate.TradingStateDB, lendingBook common.Hash, lendingTrade *lendingstate.LendingTrade) error {
+	if err := lendingStateDB.RemoveLiquidationTime(lendingBook, lendingTrade.TradeId, lendingTrade.LiquidationTime); err != nil {
+		return err
+	}
+	liquidationBook := lendingstate.GetLiquidationPriceBook(lendingStateDB, lendingBook, lendingTrade)
+	if err := tradingStateDb.RemoveLiquidationPrice(liquidationBook, lendingTrade.LiquidationPrice, lendingBook, lendingTrade.TradeId); err != nil {
+		return err
+	}
+	return lendingStateDB.CancelLendingTrade(lendingBook, lendingTrade.TradeId)
+}
@@ -0,0 +1,171 @@
+package tomoxlending
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestExtendLendingTrade(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingState, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingState, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000e21")
+		investor        = common.HexToAddress("0x0000000000000000000000000000000000000e22")
+		lendingToken    = common.HexToAddress("0x0000000000000000000000000000000000000e23")
+		collateralToken = common.HexToAddress(common.TomoNativeAddress)
+		term            = common.OneYear
+		newTerm         = 2 * common.OneYear
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		newLendingBook  = lendingstate.GetLendingOrderBookHash(lendingToken, newTerm)
+		pairBook        = tradingstate.GetTradingOrderBookHash(collateralToken, lendingToken)
+		tradeId         = uint64(1)
+		start           = uint64(1000)
+	)
+	statedb.SetNonce(lendingToken, 1)
+	lendingstate.AddTokenBalance(borrower, big.NewInt(100), lendingToken, statedb)
+
+	trade := lendingstate.LendingTrade{
+		Borrower:               borrower,
+		Investor:               investor,
+		LendingToken:           lendingToken,
+		CollateralToken:        collateralToken,
+		Term:                   term,
+		Interest:               10 * common.BaseLendingInterest.Uint64(),
+		LiquidationTime:        start + term,
+		LiquidationPrice:       big.NewInt(400),
+		CollateralLockedAmount: big.NewInt(800),
+		Amount:                 big.NewInt(1000),
+		TradeId:                tradeId,
+		Status:                 lendingstate.TradeStatusOpen,
+	}
+	trade.Hash = trade.ComputeHash()
+	lendingState.InsertTradingItem(lendingBook, tradeId, trade)
+	lendingState.SetTradeNonce(lendingBook, tradeId)
+	lendingState.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(trade.LiquidationTime), tradeId)
+	tradingState.InsertLiquidationPrice(pairBook, trade.LiquidationPrice, lendingBook, tradeId)
+
+	// rolled over at the end of the term: 10% of interest is due
+	header := &types.Header{Number: big.NewInt(1), Time: new(big.Int).SetUint64(trade.LiquidationTime)}
+	l := &Lending{}
+	extended, err := l.ExtendLendingTrade(header, lendingState, statedb, tradingState, lendingBook, tradeId, newTerm, 12*common.BaseLendingInterest.Uint64())
+	if err != nil {
+		t.Fatalf("failed to extend: %v", err)
+	}
+	if extended.TradeId != 1 || extended.Term != newTerm || extended.LiquidationTime != trade.LiquidationTime+newTerm || extended.Hash != trade.Hash {
+		t.Errorf("extended trade mismatch: %+v", extended)
+	}
+	if have := lendingstate.GetTokenBalance(investor, lendingToken, statedb); have.Int64() != 100 {
+		t.Errorf("interest paid to the investor mismatch: have %v, want 100", have)
+	}
+	var extra lendingstate.RolloverTradeData
+	if err := json.Unmarshal([]byte(extended.ExtraData), &extra); err != nil {
+		t.Fatalf("failed to decode extra data: %v", err)
+	}
+	if extra.PreviousTerm != term || extra.PreviousTradeId != tradeId || extra.InterestPaid.Int64() != 100 {
+		t.Errorf("extra data mismatch: %+v", extra)
+	}
+	if old := lendingState.GetLendingTrade(lendingBook, common.Uint64ToHash(tradeId)); old != lendingstate.EmptyLendingTrade {
+		t.Errorf("trade still open in the book of the previous term")
+	}
+	if stored := lendingState.GetLendingTrade(newLendingBook, common.Uint64ToHash(1)); stored.Amount.Int64() != 1000 || stored.CollateralLockedAmount.Int64() != 800 {
+		t.Errorf("trade in the book of the new term mismatch: %+v", stored)
+	}
+	maxTime := new(big.Int).SetUint64(^uint64(0))
+	if lowest, ids := lendingState.GetLowestLiquidationTime(newLendingBook, maxTime); lowest.Uint64() != extended.LiquidationTime || len(ids) != 1 {
+		t.Errorf("liquidation time mismatch: have %v %v, want %d", lowest, ids, extended.LiquidationTime)
+	}
+	if lowest, _ := lendingState.GetLowestLiquidationTime(lendingBook, maxTime); lowest.Sign() != 0 {
+		t.Errorf("trade still in the liquidation times of the previous term at %v", lowest)
+	}
+	tradingState.Commit()
+	price, data := tradingState.GetHighestLiquidationPriceData(pairBook, common.Big0)
+	if price.Int64() != 400 || len(data) != 1 || len(data[newLendingBook]) != 1 {
+		t.Errorf("liquidation price mismatch: have %v %v", price, data)
+	}
+}
+
+// TestRefinanceNotFilled checks that a declined rollover the book of the new
+// term can't refinance is rejected with its proposal kept, and that the
+// proposal is dropped when the trade matures.
+func TestRefinanceNotFilled(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingState, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingState, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	var (
+		borrower        = common.HexToAddress("0x0000000000000000000000000000000000000e24")
+		investor        = common.HexToAddress("0x0000000000000000000000000000000000000e25")
+		lendingToken    = common.HexToAddress("0x0000000000000000000000000000000000000e26")
+		collateralToken = common.HexToAddress(common.TomoNativeAddress)
+		lockAddress     = common.HexToAddress(common.LendingLockAddress)
+		term            = common.OneYear
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		tradeId         = uint64(1)
+	)
+	lendingstate.AddTokenBalance(lockAddress, big.NewInt(800), collateralToken, statedb)
+	trade := lendingstate.LendingTrade{
+		Borrower:               borrower,
+		Investor:               investor,
+		LendingToken:           lendingToken,
+		CollateralToken:        collateralToken,
+		Term:                   term,
+		Interest:               10 * common.BaseLendingInterest.Uint64(),
+		LiquidationTime:        1000 + term,
+		LiquidationPrice:       big.NewInt(400),
+		CollateralLockedAmount: big.NewInt(800),
+		Amount:                 big.NewInt(1000),
+		TradeId:                tradeId,
+		Status:                 lendingstate.TradeStatusOpen,
+	}
+	trade.Hash = trade.ComputeHash()
+	lendingState.InsertTradingItem(lendingBook, tradeId, trade)
+	lendingState.SetTradeNonce(lendingBook, tradeId)
+	lendingState.InsertLiquidationTime(lendingBook, new(big.Int).SetUint64(trade.LiquidationTime), tradeId)
+	lendingState.SetRolloverProposal(lendingBook, tradeId, &lendingstate.RolloverProposal{Term: 2 * term, Interest: big.NewInt(12)})
+
+	config := *params.TestChainConfig
+	chain := testChain{config: &config}
+	header := &types.Header{Number: common.TIPTomoXLendingRolloverBlock, Time: new(big.Int).SetUint64(trade.LiquidationTime)}
+	l := &Lending{}
+	decline := &lendingstate.LendingItem{
+		Side:           lendingstate.Investing,
+		Type:           lendingstate.Rollover,
+		LendingToken:   lendingToken,
+		Term:           term,
+		UserAddress:    investor,
+		LendingTradeId: tradeId,
+		ExtraData:      `{"decline":true}`,
+	}
+	if _, _, err := l.ProcessRollover(header, common.Address{}, chain, statedb, lendingState, tradingState, decline); err != lendingstate.ErrRolloverNotFilled {
+		t.Fatalf("declined rollover error mismatch: have %v, want %v", err, lendingstate.ErrRolloverNotFilled)
+	}
+	if lendingState.GetRolloverProposal(lendingBook, tradeId) == nil {
+		t.Fatalf("proposal dropped by a rejected refinancing")
+	}
+	if have := lendingstate.GetTokenBalance(lockAddress, collateralToken, statedb); have.Int64() != 800 {
+		t.Errorf("locked collateral mismatch: have %v, want 800", have)
+	}
+
+	if trades := l.RefinanceMaturedTrade(header, chain, statedb, lendingState, tradingState, lendingBook, tradeId); trades != nil {
+		t.Fatalf("matured trade refinanced by an empty book: %v", trades)
+	}
+	if lendingState.GetRolloverProposal(lendingBook, tradeId) != nil {
+		t.Errorf("proposal kept after maturity")
+	}
+	if open := lendingState.GetLendingTrade(lendingBook, common.Uint64ToHash(tradeId)); open.Hash != trade.Hash {
+		t.Errorf("trade not left for repayment after a failed refinancing")
+	}
+}
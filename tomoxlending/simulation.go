@@ -0,0 +1,126 @@
+package tomoxlending
+
+import (
+	"math/big"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+// LendingSimulation is the expected outcome of a single simulated lending item.
+type LendingSimulation struct {
+	Order   *lendingstate.LendingItem    `json:"order"`
+	Trades  []*lendingstate.LendingTrade `json:"trades"`
+	Rejects []*lendingstate.LendingItem  `json:"rejects"`
+	Error   string                       `json:"error,omitempty"`
+}
+
+// SimulationResult is the expected outcome of a batch of simulated lending items.
+type SimulationResult struct {
+	Orders   []LendingSimulation          `json:"orders"`
+	Balances []tradingstate.BalanceChange `json:"balances"`
+	Relayers []tradingstate.DepositChange `json:"relayers"`
+}
+
+// LendingItemFromTx converts a lending transaction into the lending item
+// processed by the matching engine. Transactions without signature are
+// converted into unsigned items, their hash is derived from the content if
+// missing.
+func LendingItemFromTx(tx *types.LendingTransaction, signer types.LendingSigner) *lendingstate.LendingItem {
+	order := &lendingstate.LendingItem{
+		Nonce:           new(big.Int).SetUint64(tx.Nonce()),
+		Quantity:        tx.Quantity(),
+		Interest:        new(big.Int).SetUint64(tx.Interest()),
+		Relayer:         tx.RelayerAddress(),
+		Term:            tx.Term(),
+		UserAddress:     tx.UserAddress(),
+		LendingToken:    tx.LendingToken(),
+		CollateralToken: tx.CollateralToken(),
+		AutoTopUp:       tx.AutoTopUp(),
+		Status:          tx.Status(),
+		Side:            tx.Side(),
+		Type:            tx.Type(),
+		Hash:            tx.LendingHash(),
+		LendingId:       tx.LendingId(),
+		LendingTradeId:  tx.LendingTradeId(),
+		ExtraData:       tx.ExtraData(),
+	}
+	order.SetSelfTradePrevention(tx.SelfTradePrevention())
+	if V, R, S := tx.Signature(); V.Sign() != 0 || R.Sign() != 0 || S.Sign() != 0 {
+		order.Signature = &lendingstate.Signature{
+			V: byte(V.Uint64()),
+			R: common.BigToHash(R),
+			S: common.BigToHash(S),
+		}
+	}
+	if order.Hash == (common.Hash{}) {
+		order.Hash = signer.Hash(tx)
+	}
+	return order
+}
+
+// SimulateLendings applies the lending items in sequence on copies of the
+// given states and reports the lending trades, rejections and balance
+// settlements they would lead to. The signature of unsigned items is not
+// verified. The given states are left untouched.
+func (l *Lending) SimulateLendings(header *types.Header, coinbase common.Address, chain consensus.ChainContext, statedb *state.StateDB, lendingStateDB *lendingstate.LendingStateDB, tradingStateDB *tradingstate.TradingStateDB, orders []*lendingstate.LendingItem) *SimulationResult {
+	var (
+		simState   = statedb.Copy()
+		simLending = lendingStateDB.Copy()
+		simTrading = tradingStateDB.Copy()
+		tracker    = tradingstate.NewBalanceTracker(statedb)
+		lockAddr   = common.HexToAddress(common.LendingLockAddress)
+		result     = &SimulationResult{Orders: make([]LendingSimulation, 0, len(orders))}
+	)
+	tracker.TrackBalance(statedb.GetOwner(coinbase), common.HexToAddress(common.TomoNativeAddress))
+
+	for _, order := range orders {
+		sim := LendingSimulation{Order: order}
+		trackLending(tracker, statedb, order.UserAddress, order.Relayer, order.LendingToken, order.CollateralToken)
+		if order.CollateralToken != (common.Address{}) {
+			tracker.TrackBalance(lockAddr, order.CollateralToken)
+		}
+		// Mirror CommitOrder, rolling back the states if the item fails
+		lendingSnap, tradingSnap, dbSnap := simLending.Snapshot(), simTrading.Snapshot(), simState.Snapshot()
+		orderBook := lendingstate.GetLendingOrderBookHash(order.LendingToken, order.Term)
+		trades, rejects, err := l.applyOrder(header, coinbase, chain, simState, simLending, simTrading, orderBook, order, order.Signature != nil)
+		if err != nil {
+			simLending.RevertToSnapshot(lendingSnap)
+			simTrading.RevertToSnapshot(tradingSnap)
+			simState.RevertToSnapshot(dbSnap)
+			sim.Error = err.Error()
+		}
+		sim.Rejects = rejects
+		for _, trade := range trades {
+			// Top ups and repayments report a nil trade if they failed
+			if trade == nil {
+				continue
+			}
+			trackLending(tracker, statedb, trade.Borrower, trade.BorrowingRelayer, trade.LendingToken, trade.CollateralToken)
+			trackLending(tracker, statedb, trade.Investor, trade.InvestingRelayer, trade.LendingToken, trade.CollateralToken)
+			tracker.TrackBalance(lockAddr, trade.CollateralToken)
+			sim.Trades = append(sim.Trades, trade)
+		}
+		result.Orders = append(result.Orders, sim)
+	}
+	result.Balances, result.Relayers = tracker.Changes(simState)
+	return result
+}
+
+// trackLending tracks the balances touched when settling a lending item of
+// user placed through the given relayer.
+func trackLending(tracker *tradingstate.BalanceTracker, statedb *state.StateDB, user, relayer, lendingToken, collateralToken common.Address) {
+	owner := lendingstate.GetRelayerOwner(relayer, statedb)
+	for _, token := range []common.Address{lendingToken, collateralToken} {
+		if token == (common.Address{}) {
+			continue
+		}
+		tracker.TrackBalance(user, token)
+		tracker.TrackBalance(owner, token)
+	}
+	tracker.TrackRelayer(relayer)
+}
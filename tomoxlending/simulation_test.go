@@ -0,0 +1,212 @@
+package tomoxlending
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/tomochain/tomochain/common"
+	"github.com/tomochain/tomochain/consensus"
+	"github.com/tomochain/tomochain/core/rawdb"
+	"github.com/tomochain/tomochain/core/state"
+	"github.com/tomochain/tomochain/core/types"
+	"github.com/tomochain/tomochain/crypto"
+	"github.com/tomochain/tomochain/params"
+	"github.com/tomochain/tomochain/tomox"
+	"github.com/tomochain/tomochain/tomox/tradingstate"
+	"github.com/tomochain/tomochain/tomoxlending/lendingstate"
+)
+
+func TestLendingItemFromTx(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	newTx := func() *types.LendingTransaction {
+		return types.NewLendingTransaction(1, big.NewInt(1000), 10, common.OneYear, common.HexToAddress("0x01"), user,
+			common.HexToAddress("0x02"), common.HexToAddress("0x03"), true, lendingstate.LendingStatusNew, lendingstate.Borrowing, lendingstate.Limit, common.Hash{}, 0, 0, "")
+	}
+	// Unsigned items carry no signature but get a hash derived from the content
+	signer := types.LendingTxSigner{}
+	tx := newTx()
+	item := LendingItemFromTx(tx, signer)
+	if item.Signature != nil {
+		t.Fatalf("unsigned item has signature: %v", item.Signature)
+	}
+	if want := signer.Hash(tx); item.Hash != want {
+		t.Fatalf("item hash mismatch: have %x, want %x", item.Hash, want)
+	}
+	if item.Interest.Uint64() != 10 || item.Term != common.OneYear || item.Relayer != common.HexToAddress("0x01") || item.UserAddress != user ||
+		item.LendingToken != common.HexToAddress("0x02") || item.CollateralToken != common.HexToAddress("0x03") || !item.AutoTopUp ||
+		item.Side != lendingstate.Borrowing || item.Type != lendingstate.Limit || item.Quantity.Int64() != 1000 || item.Nonce.Uint64() != 1 {
+		t.Fatalf("item mismatch: %+v", item)
+	}
+	if err := item.VerifyLendingSignature(signer); err == nil {
+		t.Fatalf("unsigned item verified")
+	}
+	// Signed items keep their signature, which must verify
+	signed, err := types.LendingSignTx(newTx(), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign item: %v", err)
+	}
+	item = LendingItemFromTx(signed, signer)
+	if item.Signature == nil {
+		t.Fatalf("signed item lost its signature")
+	}
+	if err := item.VerifyLendingSignature(signer); err != nil {
+		t.Fatalf("signed item verification failed: %v", err)
+	}
+	item.Quantity = big.NewInt(2000)
+	if err := item.VerifyLendingSignature(signer); err == nil {
+		t.Fatalf("altered item verified")
+	}
+}
+
+// testChain is the chain context the test items are matched in.
+type testChain struct{ config *params.ChainConfig }
+
+func (c testChain) Engine() consensus.Engine                    { return nil }
+func (c testChain) GetHeader(common.Hash, uint64) *types.Header { return nil }
+func (c testChain) CurrentHeader() *types.Header                { return nil }
+func (c testChain) Config() *params.ChainConfig                 { return c.config }
+
+// setTestLendingRelayer registers relayer in the relayer and lending contracts
+// with the given owner and deposit, listing a single lending pair accepting
+// collateralToken at the given price in lendingToken.
+func setTestLendingRelayer(statedb *state.StateDB, relayer, owner common.Address, deposit *big.Int, lendingToken common.Address, term uint64, collateralToken common.Address, price *big.Int, number *big.Int) {
+	relayers := common.HexToAddress(common.RelayerRegistrationSMC)
+	loc := tradingstate.GetLocMappingAtKey(relayer.Hash(), tradingstate.RelayerMappingSlot["RELAYER_LIST"])
+	statedb.SetState(relayers, common.BigToHash(new(big.Int).Add(loc, tradingstate.RelayerStructMappingSlot["_deposit"])), common.BigToHash(deposit))
+	statedb.SetState(relayers, common.BigToHash(new(big.Int).Add(loc, tradingstate.RelayerStructMappingSlot["_owner"])), owner.Hash())
+	statedb.AddBalance(relayers, deposit)
+
+	contract := common.HexToAddress(common.LendingRegistrationSMC)
+	loc = lendingstate.GetLocMappingAtKey(relayer.Hash(), lendingstate.LendingRelayerListSlot)
+	statedb.SetState(contract, state.GetLocOfStructElement(loc, lendingstate.LendingRelayerStructSlots["fee"]), common.BigToHash(big.NewInt(100)))
+	bases := state.GetLocOfStructElement(loc, lendingstate.LendingRelayerStructSlots["bases"])
+	statedb.SetState(contract, bases, common.BigToHash(common.Big1))
+	statedb.SetState(contract, state.GetLocDynamicArrAtElement(bases, 0, 1), lendingToken.Hash())
+	terms := state.GetLocOfStructElement(loc, lendingstate.LendingRelayerStructSlots["terms"])
+	statedb.SetState(contract, terms, common.BigToHash(common.Big1))
+	statedb.SetState(contract, state.GetLocDynamicArrAtElement(terms, 0, 1), common.BigToHash(new(big.Int).SetUint64(term)))
+
+	collaterals := state.GetLocSimpleVariable(lendingstate.DefaultCollateralSlot)
+	statedb.SetState(contract, collaterals, common.BigToHash(common.Big1))
+	statedb.SetState(contract, state.GetLocDynamicArrAtElement(collaterals, 0, 1), collateralToken.Hash())
+
+	loc = lendingstate.GetLocMappingAtKey(collateralToken.Hash(), lendingstate.CollateralMapSlot)
+	for name, rate := range map[string]int64{"depositRate": 150, "liquidationRate": 110, "recallRate": 200} {
+		statedb.SetState(contract, state.GetLocOfStructElement(loc, lendingstate.CollateralStructSlots[name]), common.BigToHash(big.NewInt(rate)))
+	}
+	prices := common.BigToHash(new(big.Int).Add(loc, lendingstate.CollateralStructSlots["price"]))
+	locPrice := new(big.Int).SetBytes(crypto.Keccak256(lendingToken.Hash().Bytes(), prices.Bytes()))
+	statedb.SetState(contract, common.BigToHash(new(big.Int).Add(locPrice, lendingstate.PriceStructSlots["price"])), common.BigToHash(price))
+	statedb.SetState(contract, common.BigToHash(new(big.Int).Add(locPrice, lendingstate.PriceStructSlots["blockNumber"])), common.BigToHash(number))
+}
+
+// Tests that simulated lending items lead to the lending trades, rejections
+// and settlements of actually applying them, without touching the given states.
+func TestSimulateLendings(t *testing.T) {
+	var (
+		investorKey, _  = crypto.GenerateKey()
+		borrowerKey, _  = crypto.GenerateKey()
+		relayer         = common.HexToAddress("0x0000000000000000000000000000000000000d11")
+		owner           = common.HexToAddress("0x0000000000000000000000000000000000000d12")
+		coinbase        = common.HexToAddress("0x0000000000000000000000000000000000000d13")
+		lendingToken    = common.HexToAddress(common.TomoNativeAddress)
+		collateralToken = common.HexToAddress("0x0000000000000000000000000000000000000e31")
+		term            = common.OneYear
+		lendingBook     = lendingstate.GetLendingOrderBookHash(lendingToken, term)
+		header          = &types.Header{Number: big.NewInt(1), Time: big.NewInt(1000)}
+		funds           = new(big.Int).Mul(big.NewInt(100), common.BasePrice)
+		config          = *params.TestChainConfig
+	)
+	config.Posv = &params.PosvConfig{Epoch: 900}
+	chain := testChain{config: &config}
+
+	tomoX := tomox.New(&tomox.DefaultConfig)
+	tomoX.SetTokenDecimal(collateralToken, common.BasePrice)
+	l := New(tomoX)
+
+	db := rawdb.NewMemoryDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	lendingStateDB, _ := lendingstate.New(common.Hash{}, lendingstate.NewDatabase(db))
+	tradingStateDB, _ := tradingstate.New(common.Hash{}, tradingstate.NewDatabase(db))
+
+	deposit := new(big.Int).Mul(new(big.Int).Add(common.RelayerLockedFund, big.NewInt(10)), common.BasePrice)
+	setTestLendingRelayer(statedb, relayer, owner, deposit, lendingToken, term, collateralToken, common.BasePrice, header.Number)
+	statedb.SetNonce(collateralToken, 1)
+	statedb.SetBalance(crypto.PubkeyToAddress(investorKey.PublicKey), funds)
+	lendingstate.SetTokenBalance(crypto.PubkeyToAddress(borrowerKey.PublicKey), funds, collateralToken, statedb)
+
+	newItem := func(key *ecdsa.PrivateKey, nonce uint64, quantity int64, side string, collateral common.Address) func() *lendingstate.LendingItem {
+		tx := types.NewLendingTransaction(nonce, new(big.Int).Mul(big.NewInt(quantity), common.BasePrice), 10*common.BaseLendingInterest.Uint64(), term, relayer,
+			crypto.PubkeyToAddress(key.PublicKey), lendingToken, collateral, false, lendingstate.LendingStatusNew, side, lendingstate.Limit, common.Hash{}, 0, 0, "")
+		signer := types.MakeLendingSigner(chain.Config(), header.Number)
+		signed, err := types.LendingSignTx(tx, signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign item: %v", err)
+		}
+		// Matching updates the item, every run gets its own copy
+		return func() *lendingstate.LendingItem { return LendingItemFromTx(signed, signer) }
+	}
+	invest := newItem(investorKey, 0, 10, lendingstate.Investing, common.Address{})
+	if _, rejects, err := l.ApplyOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDB, lendingBook, invest()); err != nil || len(rejects) != 0 {
+		t.Fatalf("failed to place investing item: rejects %v, err %v", rejects, err)
+	}
+	borrow := newItem(borrowerKey, 0, 4, lendingstate.Borrowing, collateralToken)
+	invalid := newItem(borrowerKey, 1, 4, lendingstate.Borrowing, common.HexToAddress("0x0000000000000000000000000000000000000e32"))
+
+	root, lendingRoot := statedb.IntermediateRoot(false), lendingStateDB.IntermediateRoot()
+	result := l.SimulateLendings(header, coinbase, chain, statedb, lendingStateDB, tradingStateDB, []*lendingstate.LendingItem{borrow(), invalid()})
+	if have := statedb.IntermediateRoot(false); have != root {
+		t.Errorf("simulation changed the state: have root %x, want %x", have, root)
+	}
+	if have := lendingStateDB.IntermediateRoot(); have != lendingRoot {
+		t.Errorf("simulation changed the lending state: have root %x, want %x", have, lendingRoot)
+	}
+	if len(result.Orders) != 2 {
+		t.Fatalf("simulated item count mismatch: have %d, want 2", len(result.Orders))
+	}
+	// Apply the same items for real and compare the outcomes
+	for i, item := range []*lendingstate.LendingItem{borrow(), invalid()} {
+		trades, rejects, err := l.ApplyOrder(header, coinbase, chain, statedb, lendingStateDB, tradingStateDB, lendingBook, item)
+		if err != nil {
+			t.Fatalf("item %d: failed to apply: %v", i, err)
+		}
+		sim := result.Orders[i]
+		if sim.Error != "" {
+			t.Errorf("item %d: simulation failed: %v", i, sim.Error)
+		}
+		if len(trades) != len(sim.Trades) || (len(trades) > 0 && !reflect.DeepEqual(trades, sim.Trades)) {
+			t.Errorf("item %d: trades mismatch: have %v, want %v", i, sim.Trades, trades)
+		}
+		if len(rejects) != len(sim.Rejects) {
+			t.Fatalf("item %d: rejects mismatch: have %d, want %d", i, len(sim.Rejects), len(rejects))
+		}
+		for j := range rejects {
+			if sim.Rejects[j].Hash != rejects[j].Hash {
+				t.Errorf("item %d: reject %d mismatch: have %x, want %x", i, j, sim.Rejects[j].Hash, rejects[j].Hash)
+			}
+		}
+	}
+	if trades := result.Orders[0].Trades; len(trades) != 1 || trades[0].Amount.Cmp(new(big.Int).Mul(big.NewInt(4), common.BasePrice)) != 0 {
+		t.Errorf("borrowing item trades mismatch: %v", trades)
+	}
+	if rejects := result.Orders[1].Rejects; len(rejects) != 1 || rejects[0].Hash != invalid().Hash {
+		t.Errorf("invalid item not rejected: %v", rejects)
+	}
+	// The reported settlements are the balances after applying the items
+	if len(result.Balances) == 0 || len(result.Relayers) != 1 {
+		t.Fatalf("settlement count mismatch: have %d balances, %d relayers", len(result.Balances), len(result.Relayers))
+	}
+	for _, change := range result.Balances {
+		if have := lendingstate.GetTokenBalance(change.Address, change.Token, statedb); have.Cmp(change.After) != 0 {
+			t.Errorf("balance of %x in %x mismatch: have %v, simulated %v", change.Address, change.Token, have, change.After)
+		}
+	}
+	for _, change := range result.Relayers {
+		if have := tradingstate.GetRelayerDeposit(change.Relayer, statedb); have.Cmp(change.After) != 0 {
+			t.Errorf("deposit of %x mismatch: have %v, simulated %v", change.Relayer, have, change.After)
+		}
+	}
+}
@@ -104,7 +104,7 @@ func (l *Lending) ProcessOrderPending(header *types.Header, coinbase common.Addr
 	lendingItems := []*lendingstate.LendingItem{}
 	matchingResults := map[common.Hash]lendingstate.MatchingResult{}
 
-	txs := types.NewLendingTransactionByNonce(types.LendingTxSigner{}, pending)
+	txs := types.NewLendingTransactionByNonce(types.MakeLendingSigner(chain.Config(), header.Number), pending)
 	for {
 		tx := txs.Peek()
 		if tx == nil {
@@ -143,6 +143,7 @@ func (l *Lending) ProcessOrderPending(header *types.Header, coinbase common.Addr
 				S: common.BigToHash(S),
 			},
 		}
+		order.SetSelfTradePrevention(tx.SelfTradePrevention())
 		cancel := false
 		if order.Status == lendingstate.LendingStatusCancelled {
 			cancel = true
@@ -288,9 +289,11 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 				updatedTakerLendingItem.AutoTopUp = false
 			case lendingstate.Repay:
 				updatedTakerLendingItem.Status = lendingstate.Repay
-				paymentBalance := lendingstate.CalculateTotalRepayValue(block.Time().Uint64(), tradeRecord.LiquidationTime, tradeRecord.Term, tradeRecord.Interest, tradeRecord.Amount)
-				updatedTakerLendingItem.Quantity = paymentBalance
-				updatedTakerLendingItem.FilledAmount = paymentBalance
+				if tradeRecord.Status != lendingstate.TradeStatusPartialRepaid {
+					paymentBalance := lendingstate.CalculateTotalRepayValue(block.Time().Uint64(), tradeRecord.LiquidationTime, tradeRecord.Term, tradeRecord.Interest, tradeRecord.Amount)
+					updatedTakerLendingItem.Quantity = paymentBalance
+					updatedTakerLendingItem.FilledAmount = paymentBalance
+				}
 				// manual repay item
 				updatedTakerLendingItem.AutoTopUp = false
 			case lendingstate.Recall:
@@ -304,6 +307,17 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 			continue
 
 		}
+		if updatedTakerLendingItem.Type == lendingstate.Rollover {
+			// extended and closed trades keep their hash, only the trades of a
+			// refinancing fill maker items
+			var rollover lendingstate.RolloverTradeData
+			if json.Unmarshal([]byte(tradeRecord.ExtraData), &rollover) != nil || !rollover.Refinanced {
+				tradeRecord.UpdatedAt = txMatchTime
+				tradeRecord.TxHash = txHash
+				tradeList[tradeRecord.Hash] = tradeRecord
+				continue
+			}
+		}
 		if tradeRecord.CreatedAt.IsZero() {
 			tradeRecord.CreatedAt = txMatchTime
 		}
@@ -320,7 +334,8 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		// maker dirty order
 		makerFilledAmount := big.NewInt(0)
 		makerOrderHash := common.Hash{}
-		if updatedTakerLendingItem.Side == lendingstate.Borrowing {
+		if updatedTakerLendingItem.Side == lendingstate.Borrowing || updatedTakerLendingItem.Type == lendingstate.Rollover {
+			// a refinancing borrows from the investing makers
 			makerOrderHash = tradeRecord.InvestingOrderHash
 		} else {
 			makerOrderHash = tradeRecord.BorrowingOrderHash
@@ -357,6 +372,23 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 			updatedTakerLendingItem.Status = lendingstate.LendingStatusReject
 		}
 	}
+	// pool, margin and auction bid items are settled at once unless rejected, rejected ones are updated below
+	if lendingstate.IsPoolLendingType(updatedTakerLendingItem.Type) || lendingstate.IsMarginLendingType(updatedTakerLendingItem.Type) || updatedTakerLendingItem.Type == lendingstate.AuctionBid {
+		takerRejected := false
+		for _, r := range rejectedItems {
+			if r.Hash == updatedTakerLendingItem.Hash {
+				takerRejected = true
+				break
+			}
+		}
+		if !takerRejected {
+			updatedTakerLendingItem.Status = lendingstate.LendingStatusFilled
+			updatedTakerLendingItem.FilledAmount = updatedTakerLendingItem.Quantity
+		}
+	}
+	if updatedTakerLendingItem.Type == lendingstate.Rollover {
+		updatedTakerLendingItem.Status = lendingstate.LendingStatusFilled
+	}
 
 	log.Debug("PutObject processed takerLendingItem",
 		"term", updatedTakerLendingItem.Term, "userAddr", updatedTakerLendingItem.UserAddress.Hex(), "side", updatedTakerLendingItem.Side,
@@ -403,14 +435,19 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 		}
 	}
 
-	// 3. put rejected orders to leveldb and update status REJECTED
+	// 3. put rejected orders to leveldb and update status REJECTED, or CANCELLED
+	// for the items cancelled by the self-trade prevention
 	log.Debug("Got rejected lendingItems", "number", len(rejectedItems), "rejectedLendingItems", rejectedItems)
 
 	if len(rejectedItems) > 0 {
 		var rejectedHashes []string
+		cancelled := make(map[common.Hash]bool)
 		// updateRejectedOrders
 		for _, r := range rejectedItems {
 			rejectedHashes = append(rejectedHashes, r.Hash.Hex())
+			if r.Status == lendingstate.LendingStatusCancelled {
+				cancelled[r.Hash] = true
+			}
 			if updatedTakerLendingItem.Hash == r.Hash && !txMatchTime.Before(r.UpdatedAt) {
 				// cache r history for handling reorg
 				historyRecord := lendingstate.LendingItemHistoryItem{
@@ -422,7 +459,9 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 				l.UpdateLendingItemCache(updatedTakerLendingItem.LendingToken, updatedTakerLendingItem.CollateralToken, updatedTakerLendingItem.Hash, txHash, historyRecord)
 				// if whole order is rejected, status = REJECTED
 				// otherwise, status = FILLED
-				if updatedTakerLendingItem.FilledAmount.Sign() > 0 {
+				if cancelled[updatedTakerLendingItem.Hash] {
+					updatedTakerLendingItem.Status = lendingstate.LendingStatusCancelled
+				} else if updatedTakerLendingItem.FilledAmount.Sign() > 0 {
 					updatedTakerLendingItem.Status = lendingstate.LendingStatusFilled
 				} else {
 					updatedTakerLendingItem.Status = lendingstate.LendingStatusReject
@@ -456,7 +495,9 @@ func (l *Lending) SyncDataToSDKNode(chain consensus.ChainContext, statedb *state
 				}
 				// if whole order is rejected, status = REJECTED
 				// otherwise, status = FILLED
-				if r.FilledAmount.Sign() > 0 {
+				if cancelled[r.Hash] {
+					r.Status = lendingstate.LendingStatusCancelled
+				} else if r.FilledAmount.Sign() > 0 {
 					r.Status = lendingstate.LendingStatusFilled
 				} else {
 					r.Status = lendingstate.LendingStatusReject
@@ -636,6 +677,7 @@ func (l *Lending) UpdateLendingTrade(trades map[common.Hash]*lendingstate.Lendin
 		for _, trade := range items.([]*lendingstate.LendingTrade) {
 			history := lendingstate.LendingTradeHistoryItem{
 				TxHash:                 trade.TxHash,
+				Amount:                 trade.Amount,
 				CollateralLockedAmount: trade.CollateralLockedAmount,
 				LiquidationPrice:       trade.LiquidationPrice,
 				Status:                 trade.Status,
@@ -646,6 +688,7 @@ func (l *Lending) UpdateLendingTrade(trades map[common.Hash]*lendingstate.Lendin
 			trade.UpdatedAt = txTime
 
 			newTrade := trades[trade.Hash]
+			trade.Amount = newTrade.Amount
 			trade.CollateralLockedAmount = newTrade.CollateralLockedAmount
 			trade.Status = newTrade.Status
 			trade.LiquidationPrice = newTrade.LiquidationPrice
@@ -806,6 +849,9 @@ func (l *Lending) RollbackLendingData(txhash common.Hash) error {
 			}
 			trade.TxHash = lendingTradeHistoryItem.TxHash
 			trade.Status = lendingTradeHistoryItem.Status
+			if lendingTradeHistoryItem.Amount != nil {
+				trade.Amount = lendingstate.CloneBigInt(lendingTradeHistoryItem.Amount)
+			}
 			trade.CollateralLockedAmount = lendingstate.CloneBigInt(lendingTradeHistoryItem.CollateralLockedAmount)
 			trade.LiquidationPrice = lendingstate.CloneBigInt(lendingTradeHistoryItem.LiquidationPrice)
 			trade.UpdatedAt = lendingTradeHistoryItem.UpdatedAt
@@ -835,6 +881,14 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 	autoTopUpTrades = []*lendingstate.LendingTrade{}
 	autoRecallTrades = []*lendingstate.LendingTrade{}
 
+	// settle the liquidation auctions opened in the previous epochs
+	if chain.Config().IsTIPTomoXLendingAuction(header.Number) {
+		for _, trade := range l.ProcessLiquidationAuctions(header, chain, statedb, lendingState) {
+			liquidatedTrades = append(liquidatedTrades, trade)
+			updatedTrades[trade.Hash] = trade
+		}
+	}
+
 	allPairs, err := lendingstate.GetAllLendingPairs(statedb)
 	if err != nil {
 		log.Debug("Not found all trading pairs", "error", err)
@@ -852,6 +906,18 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 		log.Debug("ProcessLiquidationData time", "tradeIds", len(tradingIds))
 		for lowestTime.Sign() > 0 && lowestTime.Cmp(time) < 0 {
 			for _, tradingId := range tradingIds {
+				// a rollover the investor didn't answer is refinanced at maturity
+				if chain.Config().IsTIPTomoXLendingRollover(header.Number) {
+					if trades := l.RefinanceMaturedTrade(header, chain, statedb, lendingState, tradingState, lendingBook, tradingId.Big().Uint64()); len(trades) > 0 {
+						for _, trade := range trades {
+							updatedTrades[trade.Hash] = trade
+							if trade.Status == lendingstate.TradeStatusClosed {
+								autoRepayTrades = append(autoRepayTrades, trade)
+							}
+						}
+						continue
+					}
+				}
 				log.Debug("ProcessRepay", "lowestTime", lowestTime, "time", time, "lendingBook", lendingBook.Hex(), "tradingId", tradingId.Hex())
 				trade, err := l.ProcessRepayLendingTrade(header, chain, lendingState, statedb, tradingState, lendingBook, tradingId.Big().Uint64())
 				if err != nil {
@@ -873,12 +939,24 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 
 	for _, lendingPair := range allPairs {
 		orderbook := tradingstate.GetTradingOrderBookHash(lendingPair.CollateralToken, lendingPair.LendingToken)
+		if report := l.UpdateOraclePrice(header, chain, statedb, tradingState, lendingPair.CollateralToken, lendingPair.LendingToken); report.Paused {
+			// circuit breaker: neither liquidate nor recall this pair in this epoch
+			log.Warn("Collateral oracle paused liquidations", "CollateralToken", lendingPair.CollateralToken.Hex(), "LendingToken", lendingPair.LendingToken.Hex(), "reason", report.Reason)
+			continue
+		}
 		_, collateralPrice, err := l.GetCollateralPrices(header, chain, statedb, tradingState, lendingPair.CollateralToken, lendingPair.LendingToken)
 		if err != nil || collateralPrice == nil || collateralPrice.Sign() == 0 {
 			log.Error("Fail when get price collateral/lending ", "CollateralToken", lendingPair.CollateralToken.Hex(), "LendingToken", lendingPair.LendingToken.Hex(), "error", err)
 			// ignore this pair, do not throw error
 			continue
 		}
+		// collaterals with a liquidation penalty are liquidated by auction
+		penalty, err := l.liquidationPenalty(header, chain, statedb, lendingPair.CollateralToken)
+		if err != nil {
+			log.Error("Fail when get liquidation penalty", "CollateralToken", lendingPair.CollateralToken.Hex(), "LendingToken", lendingPair.LendingToken.Hex(), "error", err)
+			// ignore this pair, do not throw error
+			continue
+		}
 		// liquidate trades
 		highestLiquidatePrice, liquidationData := tradingState.GetHighestLiquidationPriceData(orderbook, collateralPrice)
 		for highestLiquidatePrice.Sign() > 0 && collateralPrice.Cmp(highestLiquidatePrice) < 0 {
@@ -894,6 +972,15 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 							continue
 						}
 					}
+					if penalty.Sign() > 0 {
+						// the trade is liquidated when its auction is settled
+						log.Debug("OpenLiquidationAuction", "highestLiquidatePrice", highestLiquidatePrice, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "penalty", penalty)
+						if err := l.OpenLiquidationAuction(header, lendingState, statedb, tradingState, lendingBook, tradingIdHash.Big().Uint64(), collateralPrice, penalty, lendingstate.LiquidatedByPrice); err != nil {
+							log.Error("Fail when open liquidation auction", "time", time, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex(), "error", err)
+							return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+						}
+						continue
+					}
 					log.Debug("LiquidationTrade", "highestLiquidatePrice", highestLiquidatePrice, "lendingBook", lendingBook.Hex(), "tradingIdHash", tradingIdHash.Hex())
 					newTrade, err := l.LiquidationTrade(lendingState, statedb, tradingState, lendingBook, tradingIdHash.Big().Uint64())
 					if err != nil {
@@ -949,6 +1036,27 @@ func (l *Lending) ProcessLiquidationData(header *types.Header, chain consensus.C
 		}
 	}
 
+	// liquidate margin accounts as a whole, their trades are not in the books above
+	if chain.Config().IsTIPTomoXLendingMargin(header.Number) {
+		marginTrades, err := l.ProcessMarginLiquidation(header, chain, statedb, lendingState, tradingState)
+		if err != nil {
+			log.Error("Fail when liquidate margin accounts", "time", time, "error", err)
+			return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+		}
+		for _, trade := range marginTrades {
+			liquidatedTrades = append(liquidatedTrades, trade)
+			updatedTrades[trade.Hash] = trade
+		}
+	}
+
+	// pool borrows are not trades, they are liquidated in the pools
+	if chain.Config().IsTIPTomoXLendingPool(header.Number) {
+		if err := l.ProcessPoolLiquidation(header, chain, statedb, lendingState, tradingState); err != nil {
+			log.Error("Fail when liquidate pool borrows", "time", time, "error", err)
+			return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, err
+		}
+	}
+
 	log.Debug("ProcessLiquidationData", "updatedTrades", len(updatedTrades), "liquidated", len(liquidatedTrades), "autoRepay", len(autoRepayTrades), "autoTopUp", len(autoTopUpTrades), "autoRecall", len(autoRecallTrades))
 	return updatedTrades, liquidatedTrades, autoRepayTrades, autoTopUpTrades, autoRecallTrades, nil
 }
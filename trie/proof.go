@@ -393,7 +393,10 @@ func hasRightElement(node Node, key []byte) bool {
 // (unless firstProof is an existent proof).
 //
 // Expect the normal case, this function can also be used to verify the following
-// range proofs(note this function doesn't accept zero element proof):
+// range proofs:
+//
+// - Zero element proof. In this case the first edge proof must prove that no
+//   leaf exists at or after firstKey.
 //
 // - All elements proof. In this case the left and right proof can be nil, but the
 //   range should be all the leaves in the trie.
@@ -407,8 +410,20 @@ func VerifyRangeProof(rootHash common.Hash, firstKey []byte, keys [][]byte, valu
 	if len(keys) != len(values) {
 		return fmt.Errorf("inconsistent proof data, keys: %d, values: %d", len(keys), len(values)), false
 	}
+	// Special case, there is a provided edge proof but zero key/value pairs,
+	// ensure there are no more leaves in the trie.
 	if len(keys) == 0 {
-		return errors.New("empty proof"), false
+		if firstProof == nil {
+			return errors.New("empty proof"), false
+		}
+		root, val, err := proofToPath(rootHash, nil, firstKey, firstProof, true)
+		if err != nil {
+			return err, false
+		}
+		if val != nil || hasRightElement(root, firstKey) {
+			return errors.New("more entries available"), false
+		}
+		return nil, false
 	}
 	// Ensure the received batch is monotonic increasing.
 	for i := 0; i < len(keys)-1; i++ {
@@ -124,6 +124,17 @@ func (t *SecureTrie) TryDelete(key []byte) error {
 	return t.trie.TryDelete(hk)
 }
 
+// TryUpdateHashed associates an already hashed key with value in the trie.
+// It is used to restore trie content for which the key preimages are unknown.
+func (t *SecureTrie) TryUpdateHashed(hashedKey, value []byte) error {
+	return t.trie.TryUpdate(hashedKey, value)
+}
+
+// TryDeleteHashed removes any existing value for an already hashed key.
+func (t *SecureTrie) TryDeleteHashed(hashedKey []byte) error {
+	return t.trie.TryDelete(hashedKey)
+}
+
 // GetKey returns the sha3 Preimage of a hashed key that was
 // previously used to store a value.
 func (t *SecureTrie) GetKey(shaKey []byte) []byte {